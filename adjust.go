@@ -77,6 +77,7 @@ func (f *File) adjustHelper(sheet string, dir adjustDirection, num, offset int)
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	sheetID := f.getSheetID(sheet)
 	if dir == rows {
 		err = f.adjustRowDimensions(sheet, ws, num, offset)
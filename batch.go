@@ -3,7 +3,6 @@ package excelize
 import (
 	"context"
 	"fmt"
-	"log"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -208,7 +207,7 @@ func (f *File) RecalculateAll() error {
 		return nil
 	}
 
-	log.Printf("📊 [RecalculateAll] Starting: %d formulas to calculate", len(calcChain.C))
+	f.logger().Infof("📊 [RecalculateAll] Starting: %d formulas to calculate", len(calcChain.C))
 
 	// === 批量SUMIFS/AVERAGEIFS优化 ===
 	// 在逐个计算之前，先检测并批量计算SUMIFS/AVERAGEIFS公式
@@ -218,7 +217,7 @@ func (f *File) RecalculateAll() error {
 
 	batchCount := len(batchResults)
 	if batchCount > 0 {
-		log.Printf("⚡ [RecalculateAll] Batch SUMIFS/AVERAGEIFS/SUMPRODUCT optimization: %d formulas calculated in %v (avg: %v/formula)",
+		f.logger().Infof("⚡ [RecalculateAll] Batch SUMIFS/AVERAGEIFS/SUMPRODUCT optimization: %d formulas calculated in %v (avg: %v/formula)",
 			batchCount, batchDuration, batchDuration/time.Duration(batchCount))
 
 		// 将批量结果存入calcCache，这样后续逐个计算时会直接使用缓存
@@ -439,7 +438,7 @@ func (f *File) RecalculateAll() error {
 
 			// Log first occurrence
 			if len(circularRefColumns) == 1 {
-				log.Printf("  🔄 [RecalculateAll] Circular reference detected: %s!%s (formula references itself)", sheetName, c.R)
+				f.logger().Debugf("  🔄 [RecalculateAll] Circular reference detected: %s!%s (formula references itself)", sheetName, c.R)
 			}
 			continue
 		}
@@ -653,7 +652,7 @@ func (f *File) RecalculateAll() error {
 			elapsed := time.Since(totalStart)
 			avgPerFormula := elapsed / time.Duration(formulaCount)
 			remaining := time.Duration(len(calcChain.C)-formulaCount) * avgPerFormula
-			log.Printf("  ⏳ [RecalculateAll] Progress: %.0f%% (%d/%d), sheet: '%s', elapsed: %v, avg: %v/formula, remaining: ~%v, slow formulas: %d",
+			f.logger().Debugf("  ⏳ [RecalculateAll] Progress: %.0f%% (%d/%d), sheet: '%s', elapsed: %v, avg: %v/formula, remaining: ~%v, slow formulas: %d",
 				progress, formulaCount, len(calcChain.C), currentSheetName, elapsed, avgPerFormula, remaining, slowFormulaCount)
 
 			// 🔥 MEMORY OPTIMIZATION: Force GC at progress checkpoints to free memory
@@ -669,56 +668,56 @@ func (f *File) RecalculateAll() error {
 	currentWs = nil
 
 	totalDuration := time.Since(totalStart)
-	log.Printf("✅ [RecalculateAll] Completed: %d formulas in %v", formulaCount, totalDuration)
+	f.logger().Infof("✅ [RecalculateAll] Completed: %d formulas in %v", formulaCount, totalDuration)
 
 	// Avoid division by zero
 	avgPerFormula := time.Duration(0)
 	if formulaCount > 0 {
 		avgPerFormula = calcTime / time.Duration(formulaCount)
 	}
-	log.Printf("  📊 Breakdown: CellMap build: %v, Formula calc: %v, Avg per formula: %v",
+	f.logger().Debugf("  📊 Breakdown: CellMap build: %v, Formula calc: %v, Avg per formula: %v",
 		sheetBuildTime, calcTime, avgPerFormula)
 
 	// Log slow formula statistics
 	if slowFormulaCount > 0 {
-		log.Printf("  ⚠️  Slow formulas detected: %d formulas took >100ms to calculate", slowFormulaCount)
+		f.logger().Debugf("  ⚠️  Slow formulas detected: %d formulas took >100ms to calculate", slowFormulaCount)
 
 		// Print top slow formulas
 		if len(slowFormulas) > 0 {
-			log.Printf("  📋 Top %d slow formulas:", len(slowFormulas))
+			f.logger().Debugf("  📋 Top %d slow formulas:", len(slowFormulas))
 			for i, sf := range slowFormulas {
 				if i >= 20 { // Only show top 20
-					log.Printf("  ... and %d more slow formulas", len(slowFormulas)-20)
+					f.logger().Debugf("  ... and %d more slow formulas", len(slowFormulas)-20)
 					break
 				}
-				log.Printf("    %2d. %s!%s - %v - %s", i+1, sf.sheet, sf.cell, sf.duration, sf.formula)
+				f.logger().Debugf("    %2d. %s!%s - %v - %s", i+1, sf.sheet, sf.cell, sf.duration, sf.formula)
 			}
 		}
 	}
 
 	// Log timeout statistics
 	if timeoutCount > 0 {
-		log.Printf("  ⏱️  Timeout formulas: %d formulas exceeded 5s timeout or depend on timed-out columns", timeoutCount)
+		f.logger().Debugf("  ⏱️  Timeout formulas: %d formulas exceeded 5s timeout or depend on timed-out columns", timeoutCount)
 		if len(timeoutColumns) > 0 {
-			log.Printf("  📋 Timed-out columns: %v", timeoutColumns)
+			f.logger().Debugf("  📋 Timed-out columns: %v", timeoutColumns)
 		}
 	}
 
 	// Log skipped complex formulas
 	if skippedComplexFormulas > 0 {
-		log.Printf("  🚫 Skipped formulas with circular references: %d formulas", skippedComplexFormulas)
+		f.logger().Debugf("  🚫 Skipped formulas with circular references: %d formulas", skippedComplexFormulas)
 		if len(circularRefColumns) > 0 {
-			log.Printf("  📋 Circular reference columns: %v", getMapKeys(circularRefColumns))
+			f.logger().Debugf("  📋 Circular reference columns: %v", getMapKeys(circularRefColumns))
 		}
 	}
 
 	// Log batch optimization statistics
 	if batchCount > 0 {
-		log.Printf("  ⚡ Batch SUMIFS/AVERAGEIFS/SUMPRODUCT stats: %d formulas batched, %d cache hits during calculation",
+		f.logger().Debugf("  ⚡ Batch SUMIFS/AVERAGEIFS/SUMPRODUCT stats: %d formulas batched, %d cache hits during calculation",
 			batchCount, batchHitCount)
 		if batchHitCount > 0 {
 			batchSavings := batchDuration
-			log.Printf("  💰 Estimated time saved by batch optimization: %v", batchSavings)
+			f.logger().Debugf("  💰 Estimated time saved by batch optimization: %v", batchSavings)
 		}
 	}
 
@@ -1023,6 +1022,7 @@ func (f *File) BatchSetFormulasAndRecalculate(formulas []FormulaUpdate) error {
 
 	// 7. 只排除那些不依赖于同批其他公式的被设置单元格
 	// 如果 C1 依赖 B1，且 B1 和 C1 都被设置，则保留 C1
+	names := f.definedNameRefs()
 	for sheet, cells := range setFormulaCells {
 		for cell := range cells {
 			cellKey := sheet + "!" + cell
@@ -1042,7 +1042,7 @@ func (f *File) BatchSetFormulasAndRecalculate(formulas []FormulaUpdate) error {
 
 					if formula != "" {
 						// 检查公式是否引用了同批的其他单元格
-						isDependentOnOthers = f.formulaReferencesUpdatedCells(formula, sheet, setFormulaCells, setFormulaColumns)
+						isDependentOnOthers = f.formulaReferencesUpdatedCells(formula, sheet, names, setFormulaCells, setFormulaColumns)
 					}
 				}
 			}
@@ -1186,6 +1186,7 @@ func (f *File) recalculateAllSheetsWithTracking(calcChain *xlsxCalcChain) ([]Aff
 // 通过解析公式中的单元格引用，找出哪些公式依赖于被更新的单元格
 func (f *File) findAffectedFormulas(calcChain *xlsxCalcChain, updatedCells map[string]map[string]bool, updatedColumns map[string]map[string]bool) map[string]bool {
 	affected := make(map[string]bool)
+	names := f.definedNameRefs()
 	currentSheetID := -1
 
 	// 第一轮：找出直接依赖
@@ -1222,7 +1223,7 @@ func (f *File) findAffectedFormulas(calcChain *xlsxCalcChain, updatedCells map[s
 		}
 
 		// 检查公式是否引用了被更新的单元格
-		if f.formulaReferencesUpdatedCells(formula, sheetName, updatedCells, updatedColumns) {
+		if f.formulaReferencesUpdatedCells(formula, sheetName, names, updatedCells, updatedColumns) {
 			cellKey := sheetName + "!" + c.R
 			affected[cellKey] = true
 		}
@@ -1272,7 +1273,7 @@ func (f *File) findAffectedFormulas(calcChain *xlsxCalcChain, updatedCells map[s
 			}
 
 			// 检查公式是否引用了受影响的单元格
-			if f.formulaReferencesAffectedCells(formula, sheetName, affected) {
+			if f.formulaReferencesAffectedCells(formula, sheetName, names, affected) {
 				affected[cellKey] = true
 				changed = true
 			}
@@ -1307,6 +1308,7 @@ func (f *File) findAffectedFormulasOptimized(calcChain *xlsxCalcChain, updatedCe
 
 	// 预加载所有工作表
 	wsCache := make(map[string]*xlsxWorksheet)
+	names := f.definedNameRefs()
 
 	for i := range calcChain.C {
 		c := calcChain.C[i]
@@ -1347,7 +1349,7 @@ func (f *File) findAffectedFormulasOptimized(calcChain *xlsxCalcChain, updatedCe
 		cellKey := sheetName + "!" + c.R
 
 		// 提取公式依赖并构建反向索引
-		deps := extractDependencies(formula, sheetName, "")
+		deps := extractDependencies(names.expand(formula, sheetName), sheetName, "")
 		for _, dep := range deps {
 			parts := strings.SplitN(dep, "!", 2)
 			if len(parts) != 2 {
@@ -1441,10 +1443,10 @@ func (f *File) findAffectedFormulasOptimized(calcChain *xlsxCalcChain, updatedCe
 }
 
 // formulaReferencesUpdatedCells 检查公式是否引用了被更新的单元格
-// 使用 extractDependencies 函数解析公式依赖
-func (f *File) formulaReferencesUpdatedCells(formula, currentSheet string, updatedCells map[string]map[string]bool, updatedColumns map[string]map[string]bool) bool {
+// 使用 extractDependencies 函数解析公式依赖，names 为调用方构建一次的定义名称引用
+func (f *File) formulaReferencesUpdatedCells(formula, currentSheet string, names definedNameRefs, updatedCells map[string]map[string]bool, updatedColumns map[string]map[string]bool) bool {
 	// 使用公式解析器提取依赖
-	deps := extractDependencies(formula, currentSheet, "")
+	deps := extractDependencies(names.expand(formula, currentSheet), currentSheet, "")
 
 	for _, dep := range deps {
 		// dep 格式: "Sheet!Cell" 或 "Sheet!Col:COLUMN_RANGE"
@@ -1492,10 +1494,10 @@ func (f *File) formulaReferencesUpdatedCells(formula, currentSheet string, updat
 }
 
 // formulaReferencesAffectedCells 检查公式是否引用了受影响的单元格
-// 使用 extractDependencies 函数解析公式依赖
-func (f *File) formulaReferencesAffectedCells(formula, currentSheet string, affectedCells map[string]bool) bool {
+// 使用 extractDependencies 函数解析公式依赖，names 为调用方构建一次的定义名称引用
+func (f *File) formulaReferencesAffectedCells(formula, currentSheet string, names definedNameRefs, affectedCells map[string]bool) bool {
 	// 使用公式解析器提取依赖
-	deps := extractDependencies(formula, currentSheet, "")
+	deps := extractDependencies(names.expand(formula, currentSheet), currentSheet, "")
 
 	for _, dep := range deps {
 		// dep 格式: "Sheet!Cell" 或 "Sheet!Col:COLUMN_RANGE"
@@ -1937,6 +1939,7 @@ func (f *File) BatchUpdateValuesAndFormulasWithRecalcV2(valueUpdates []CellUpdat
 // 这个方法不依赖 calcChain，适用于 calcChain 不完整或不存在的情况
 func (f *File) findAffectedFormulasByScanning(updatedCells map[string]map[string]bool, updatedColumns map[string]map[string]bool) map[string]bool {
 	affected := make(map[string]bool)
+	names := f.definedNameRefs()
 
 	// 遍历所有工作表
 	sheetList := f.GetSheetList()
@@ -1962,7 +1965,7 @@ func (f *File) findAffectedFormulasByScanning(updatedCells map[string]map[string
 				}
 
 				// 检查公式是否引用了被更新的单元格
-				if f.formulaReferencesUpdatedCells(formula, sheetName, updatedCells, updatedColumns) {
+				if f.formulaReferencesUpdatedCells(formula, sheetName, names, updatedCells, updatedColumns) {
 					cellKey := sheetName + "!" + cell.R
 					affected[cellKey] = true
 				}
@@ -2002,7 +2005,7 @@ func (f *File) findAffectedFormulasByScanning(updatedCells map[string]map[string
 					}
 
 					// 检查公式是否引用了受影响的单元格
-					if f.formulaReferencesAffectedCells(formula, sheetName, affected) {
+					if f.formulaReferencesAffectedCells(formula, sheetName, names, affected) {
 						affected[cellKey] = true
 						changed = true
 					}
@@ -0,0 +1,72 @@
+package excelize
+
+import (
+	"context"
+	"sort"
+)
+
+// sortedCells returns the sorted cells of a set.
+func sortedCells(cells map[string]bool) []string {
+	sorted := make([]string, 0, len(cells))
+	for cell := range cells {
+		sorted = append(sorted, cell)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// AffectedCellsByCells 试运行 RecalculateAffectedByCells：使用相同的反向依赖和
+// BFS 传播找出直接或间接依赖于更新单元格的公式，以及每次都重算的易失性公式（如
+// NOW()、OFFSET(...)）和依赖于它们的公式，返回按字母排序的 "Sheet!Cell" 列表，
+// 但不清除缓存也不重算。可以据此在增量重算和全量重算之间选择，或向用户展示
+// 将要变化的单元格。例如：
+//
+//	cells, err := f.AffectedCellsByCells(map[string]bool{"Data!C5": true})
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	fmt.Printf("%d formulas will be recalculated\n", len(cells))
+func (f *File) AffectedCellsByCells(updatedCells map[string]bool) ([]string, error) {
+	if len(updatedCells) == 0 {
+		return nil, nil
+	}
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	ctx := context.Background()
+	index, err := f.cachedIncrementalIndex(ctx, f.definedNameRefs())
+	if err != nil {
+		return nil, err
+	}
+	affected, err := f.findAffectedCellsInIndex(ctx, index, updatedCells)
+	if err != nil {
+		return nil, err
+	}
+	for cell := range index.volatile {
+		affected[cell] = true
+	}
+	return sortedCells(affected), nil
+}
+
+// AffectedCellsByColumns 试运行 RecalculateAffectedByColumns：使用相同的依赖图和
+// BFS 传播找出直接或间接依赖于更新列 ("Sheet!Col" -> true) 的公式，返回按字母
+// 排序的 "Sheet!Cell" 列表，但不清除缓存也不重算。
+func (f *File) AffectedCellsByColumns(updatedColumns map[string]bool) ([]string, error) {
+	if len(updatedColumns) == 0 {
+		return nil, nil
+	}
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	ctx := context.Background()
+	graph, err := f.cachedDependencyGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := f.findAffectedCellsByColumns(ctx, graph, updatedColumns)
+	if err != nil {
+		return nil, err
+	}
+	return sortedCells(affected), nil
+}
@@ -0,0 +1,74 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestAffectedCells(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 5; row++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{
+		"B1": "Data!A1*2",
+		"B2": "B1+1",
+		"B3": "SUM(Data!A:A)",
+		"B4": "Data!A5",
+		"C1": "1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+
+	if err := f.SetCellValue("Data", "A1", 10); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	cells, err := f.AffectedCellsByCells(map[string]bool{"Data!A1": true})
+	if err != nil {
+		t.Fatalf("affected cells by cells: %v", err)
+	}
+	if want := []string{"Sheet1!B1", "Sheet1!B2", "Sheet1!B3"}; !reflect.DeepEqual(cells, want) {
+		t.Fatalf("unexpected affected cells %v, want %v", cells, want)
+	}
+	cells, err = f.AffectedCellsByColumns(map[string]bool{"Data!A": true})
+	if err != nil {
+		t.Fatalf("affected cells by columns: %v", err)
+	}
+	if want := []string{"Sheet1!B1", "Sheet1!B2", "Sheet1!B3", "Sheet1!B4"}; !reflect.DeepEqual(cells, want) {
+		t.Fatalf("unexpected affected cells %v, want %v", cells, want)
+	}
+	if cells, err = f.AffectedCellsByCells(nil); err != nil || cells != nil {
+		t.Fatalf("unexpected affected cells %v, error %v without updated cells", cells, err)
+	}
+
+	// 试运行不重算，受影响的公式保留旧值，直到真正的增量重算
+	if value, _ := f.GetCellValue("Sheet1", "B2"); value != "3" {
+		t.Fatalf("unexpected B2 value %q after the dry run, want 3", value)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Data!A1": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	for cell, want := range map[string]string{"B1": "20", "B2": "21", "B3": "24", "B4": "5"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %s", cell, value, want)
+		}
+	}
+}
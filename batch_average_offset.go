@@ -2,8 +2,6 @@ package excelize
 
 import (
 	"fmt"
-	"log"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -460,19 +458,19 @@ func (f *File) calculateAverageOffsetPattern(pattern *averageOffsetPattern) map[
 
 	startTime := time.Now()
 
-	log.Printf("  🔍 [AVERAGE(OFFSET) Batch] Processing %d formulas, source='%s', offset=(%d,%d), size=(%d,%d)",
+	f.logger().Debugf("  🔍 [AVERAGE(OFFSET) Batch] Processing %d formulas, source='%s', offset=(%d,%d), size=(%d,%d)",
 		len(pattern.formulas), pattern.sourceSheet, pattern.colOffset, 0, pattern.height, pattern.width)
 
 	// Step 1: Build MATCH lookup index
 	// Read the match column from source sheet and build value -> rowIndex map
 	matchIndex := f.buildMatchIndex(pattern.sourceSheet, pattern.matchRangeCol)
 	if matchIndex == nil {
-		log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to build match index for %s!%s",
+		f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to build match index for %s!%s",
 			pattern.sourceSheet, pattern.matchRangeCol)
 		return results
 	}
 
-	log.Printf("  📊 [AVERAGE(OFFSET) Batch] Built match index with %d entries", len(matchIndex))
+	f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Built match index with %d entries", len(matchIndex))
 
 	// Step 2: Read source data for the range we'll be averaging
 	// We need columns from (offsetBaseCol + colOffset) to (offsetBaseCol + colOffset + width - 1)
@@ -480,20 +478,20 @@ func (f *File) calculateAverageOffsetPattern(pattern *averageOffsetPattern) map[
 	endCol := startCol + pattern.width - 1
 
 	if startCol < 1 {
-		log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Invalid start column: %d", startCol)
+		f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Invalid start column: %d", startCol)
 		return results
 	}
 
 	sourceData := f.readSourceColumns(pattern.sourceSheet, startCol, endCol)
 	if sourceData == nil {
-		log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to read source data")
+		f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to read source data")
 		return results
 	}
 
-	log.Printf("  📊 [AVERAGE(OFFSET) Batch] Read source data: %d rows", len(sourceData))
+	f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Read source data: %d rows", len(sourceData))
 
 	// Step 3: Calculate each formula
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numWorkers > len(pattern.formulas) {
 		numWorkers = len(pattern.formulas)
 	}
@@ -601,7 +599,7 @@ func (f *File) calculateAverageOffsetPattern(pattern *averageOffsetPattern) map[
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("  ⚡ [AVERAGE(OFFSET) Batch] Completed %d/%d formulas in %v (avg: %v/formula)",
+	f.logger().Debugf("  ⚡ [AVERAGE(OFFSET) Batch] Completed %d/%d formulas in %v (avg: %v/formula)",
 		successCount, len(pattern.formulas), duration, duration/time.Duration(max(len(pattern.formulas), 1)))
 
 	return results
@@ -610,7 +608,7 @@ func (f *File) calculateAverageOffsetPattern(pattern *averageOffsetPattern) map[
 // buildMatchIndex builds a lookup index for MATCH function
 // Returns map[lookupValue] -> rowNumber (1-based)
 func (f *File) buildMatchIndex(sheet, col string) map[string]int {
-	rows, err := f.GetRows(sheet)
+	rows, err := f.getUsedRows(sheet)
 	if err != nil {
 		return nil
 	}
@@ -640,7 +638,7 @@ func (f *File) buildMatchIndex(sheet, col string) map[string]int {
 // readSourceColumns reads specified columns from a sheet
 // Returns [][]string where [rowIndex][colIndex] = value
 func (f *File) readSourceColumns(sheet string, startCol, endCol int) [][]string {
-	rows, err := f.GetRows(sheet, Options{RawCellValue: true})
+	rows, err := f.getCachedRawRows(sheet)
 	if err != nil {
 		return nil
 	}
@@ -669,7 +667,7 @@ func (f *File) calculateAverageOffsetPatternWithCache(pattern *averageOffsetPatt
 
 	startTime := time.Now()
 
-	log.Printf("  🔍 [AVERAGE(OFFSET) Batch] Processing %d formulas, source='%s', offset=(%d,%d), size=(%d,%d)",
+	f.logger().Debugf("  🔍 [AVERAGE(OFFSET) Batch] Processing %d formulas, source='%s', offset=(%d,%d), size=(%d,%d)",
 		len(pattern.formulas), pattern.sourceSheet, pattern.colOffset, 0, pattern.height, pattern.width)
 
 	// Step 1: Get or build MATCH lookup index (cached)
@@ -681,16 +679,16 @@ func (f *File) calculateAverageOffsetPatternWithCache(pattern *averageOffsetPatt
 	if !found {
 		matchIndex = f.buildMatchIndex(pattern.sourceSheet, pattern.matchRangeCol)
 		if matchIndex == nil {
-			log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to build match index for %s!%s",
+			f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to build match index for %s!%s",
 				pattern.sourceSheet, pattern.matchRangeCol)
 			return results
 		}
 		cache.mu.Lock()
 		cache.matchIndexCache[matchCacheKey] = matchIndex
 		cache.mu.Unlock()
-		log.Printf("  📊 [AVERAGE(OFFSET) Batch] Built match index with %d entries (cached)", len(matchIndex))
+		f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Built match index with %d entries (cached)", len(matchIndex))
 	} else {
-		log.Printf("  📊 [AVERAGE(OFFSET) Batch] Using cached match index with %d entries", len(matchIndex))
+		f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Using cached match index with %d entries", len(matchIndex))
 	}
 
 	// Step 2: Get or build source data cache
@@ -699,18 +697,18 @@ func (f *File) calculateAverageOffsetPatternWithCache(pattern *averageOffsetPatt
 	cache.mu.RUnlock()
 
 	if !found {
-		rows, err := f.GetRows(pattern.sourceSheet, Options{RawCellValue: true})
+		rows, err := f.getCachedRawRows(pattern.sourceSheet)
 		if err != nil {
-			log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to read source data")
+			f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Failed to read source data")
 			return results
 		}
 		sourceData = rows
 		cache.mu.Lock()
 		cache.sourceDataCache[pattern.sourceSheet] = sourceData
 		cache.mu.Unlock()
-		log.Printf("  📊 [AVERAGE(OFFSET) Batch] Loaded source data: %d rows (cached)", len(sourceData))
+		f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Loaded source data: %d rows (cached)", len(sourceData))
 	} else {
-		log.Printf("  📊 [AVERAGE(OFFSET) Batch] Using cached source data: %d rows", len(sourceData))
+		f.logger().Debugf("  📊 [AVERAGE(OFFSET) Batch] Using cached source data: %d rows", len(sourceData))
 	}
 
 	// Calculate column range for averaging
@@ -718,12 +716,12 @@ func (f *File) calculateAverageOffsetPatternWithCache(pattern *averageOffsetPatt
 	startColIdx := startCol - 1 // 0-based
 
 	if startCol < 1 {
-		log.Printf("  ⚠️ [AVERAGE(OFFSET) Batch] Invalid start column: %d", startCol)
+		f.logger().Debugf("  ⚠️ [AVERAGE(OFFSET) Batch] Invalid start column: %d", startCol)
 		return results
 	}
 
 	// Step 3: Calculate each formula
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numWorkers > len(pattern.formulas) {
 		numWorkers = len(pattern.formulas)
 	}
@@ -833,7 +831,7 @@ func (f *File) calculateAverageOffsetPatternWithCache(pattern *averageOffsetPatt
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("  ⚡ [AVERAGE(OFFSET) Batch] Completed %d/%d formulas in %v (avg: %v/formula)",
+	f.logger().Debugf("  ⚡ [AVERAGE(OFFSET) Batch] Completed %d/%d formulas in %v (avg: %v/formula)",
 		successCount, len(pattern.formulas), duration, duration/time.Duration(max(len(pattern.formulas), 1)))
 
 	return results
@@ -937,7 +935,7 @@ func (f *File) calculateAverageOffsetPatternWithWorksheetCache(pattern *averageO
 	cache.mu.RUnlock()
 
 	if !found {
-		rows, err := f.GetRows(pattern.sourceSheet, Options{RawCellValue: true})
+		rows, err := f.getCachedRawRows(pattern.sourceSheet)
 		if err != nil {
 			return results
 		}
@@ -0,0 +1,143 @@
+package excelize
+
+import (
+	"context"
+	"time"
+)
+
+// buildDependencyGraphFromCalcChain builds the dependency graph with the
+// levels seeded from the calculation chain saved by Excel, so the formulas in
+// the chain are not parsed. A chain cell flagged as starting a new dependency
+// level starts a new level of the graph, and the cells of a level are
+// calculated concurrently like Excel does. The stale entries, whose worksheet
+// was deleted or whose cell has no formula anymore in the worksheet, are
+// skipped. The formulas not in the chain, e.g. set after the workbook was
+// opened, are parsed and put after the levels of their dependencies. It
+// returns a nil graph if the workbook has no calculation chain or the chain
+// has no dependency levels, the full graph should be built then.
+func (f *File) buildDependencyGraphFromCalcChain(ctx context.Context) (*dependencyGraph, error) {
+	startTime := time.Now()
+	calcChain, err := f.calcChainReader()
+	if err != nil {
+		f.logger().Infof("⚠️  [Calc Chain] Failed to read the calculation chain, parse all formulas: %v", err)
+		return nil, nil
+	}
+	if calcChain == nil || len(calcChain.C) == 0 {
+		f.logger().Debugf("  📊 [Calc Chain] No calculation chain to seed the levels, parse all formulas")
+		return nil, nil
+	}
+	hasLevels := false
+	for _, c := range calcChain.C[1:] {
+		if c.L {
+			hasLevels = true
+			break
+		}
+	}
+	if !hasLevels {
+		f.logger().Debugf("  📊 [Calc Chain] The calculation chain has no dependency levels, parse all formulas")
+		return nil, nil
+	}
+
+	graph := &dependencyGraph{
+		nodes:             make(map[string]*formulaNode),
+		columnMetadata:    make(map[string]*columnMeta),
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
+	}
+	formulas, err := f.collectFormulaNodes(ctx, graph)
+	if err != nil {
+		return graph, err
+	}
+
+	// Step 1: 按计算链顺序分配层级，l 标记开始新的依赖层
+	sheetMap := f.GetSheetMap()
+	ordered := make([]string, 0, len(graph.nodes))
+	level, sheetID, stale := -1, 0, 0
+	for i, c := range calcChain.C {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return graph, ctx.Err()
+		}
+		if c.I != 0 {
+			sheetID = c.I // 省略 i 时与上一个单元格相同
+		}
+		if c.L || level < 0 {
+			level++
+		}
+		node, ok := graph.nodes[sheetMap[sheetID]+"!"+c.R]
+		if !ok || node.level != -1 {
+			stale++ // 已删除的工作表或单元格、不再是公式、或重复的条目
+			continue
+		}
+		node.level = level
+		ordered = append(ordered, node.cell)
+	}
+
+	// Step 2: 解析不在计算链中的公式，放在其依赖之后
+	pending := make([]pendingFormula, 0)
+	for _, info := range formulas {
+		if graph.nodes[info.fullCell].level == -1 {
+			pending = append(pending, info)
+		}
+	}
+	if len(pending) > 0 {
+		numWorkers := min(f.calcWorkers(), 16)
+		deps := f.extractDependenciesParallel(ctx, pending, graph.formulaColumnIndex(), graph.columnMetadata, numWorkers)
+		if err := ctx.Err(); err != nil {
+			return graph, err
+		}
+		for i, info := range pending {
+			graph.nodes[info.fullCell].dependencies = deps[i]
+		}
+		visiting := make(map[string]bool)
+		for _, info := range pending {
+			graph.placeAfterDependencies(info.fullCell, visiting)
+			ordered = append(ordered, info.fullCell)
+		}
+	}
+
+	// Step 3: 生成层级列表，跳过只有过期条目的空层
+	var levels [][]string
+	for _, cell := range ordered {
+		node := graph.nodes[cell]
+		for len(levels) <= node.level {
+			levels = append(levels, nil)
+		}
+		levels[node.level] = append(levels[node.level], cell)
+	}
+	for _, cells := range levels {
+		if len(cells) == 0 {
+			continue
+		}
+		for _, cell := range cells {
+			graph.nodes[cell].level = len(graph.levels)
+		}
+		graph.levels = append(graph.levels, cells)
+	}
+
+	graph.markConstantFormulas()
+
+	f.logger().Debugf("  ✅ [Calc Chain] Seeded %d formulas in %d levels from the calculation chain, parsed %d formulas not in the chain, skipped %d stale entries in %v",
+		len(ordered)-len(pending), len(graph.levels), len(pending), stale, time.Since(startTime))
+	f.levelHistogram.Store(graph.levelHistogram())
+	return graph, nil
+}
+
+// placeAfterDependencies sets the level of a formula which is not in the
+// calculation chain after the levels of its dependencies and returns it. The
+// formulas in a circular reference start from level 0.
+func (g *dependencyGraph) placeAfterDependencies(cell string, visiting map[string]bool) int {
+	node := g.nodes[cell]
+	if node.level != -1 || visiting[cell] {
+		return max(node.level, 0)
+	}
+	visiting[cell] = true
+	level := 0
+	for _, dep := range node.dependencies {
+		if _, ok := g.nodes[dep]; ok {
+			level = max(level, g.placeAfterDependencies(dep, visiting)+1)
+		}
+	}
+	node.level = level
+	return level
+}
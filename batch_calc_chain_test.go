@@ -0,0 +1,67 @@
+package excelize
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecalculateWithCalcChain(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{"B1": "A1*2", "C1": "B1+1", "E1": "A1+100", "D1": "C1*10"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// D1 不在计算链中；Z9 已不是公式，工作表 99 不存在
+	f.CalcChain = &xlsxCalcChain{C: []xlsxCalcChainC{
+		{R: "B1", I: 1}, {R: "Z9"}, {R: "C1", L: true}, {R: "E1"}, {R: "E1"}, {R: "A1", I: 99, L: true},
+	}}
+
+	graph, err := f.buildDependencyGraphFromCalcChain(context.Background())
+	if err != nil || graph == nil {
+		t.Fatalf("build graph: %v, %v", graph, err)
+	}
+	want := [][]string{{"Sheet1!B1"}, {"Sheet1!C1", "Sheet1!E1"}, {"Sheet1!D1"}}
+	if !reflect.DeepEqual(graph.levels, want) {
+		t.Fatalf("unexpected levels %v, want %v", graph.levels, want)
+	}
+	if deps := graph.nodes["Sheet1!C1"].dependencies; deps != nil {
+		t.Fatalf("unexpected parsed dependencies of a chain formula: %v", deps)
+	}
+	if deps := graph.nodes["Sheet1!D1"].dependencies; !reflect.DeepEqual(deps, []string{"Sheet1!C1"}) {
+		t.Fatalf("unexpected dependencies of D1: %v", deps)
+	}
+
+	f.SetCalcTuning(CalcTuning{UseCalcChain: true})
+	if err := f.SetCellValue("Sheet1", "A1", 5); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range map[string]string{"B1": "10", "C1": "11", "D1": "110", "E1": "105"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, value, want)
+		}
+	}
+	if histogram, _ := f.LevelHistogram(); !reflect.DeepEqual(histogram, []int{1, 2, 1}) {
+		t.Fatalf("unexpected level histogram %v", histogram)
+	}
+
+	// 没有依赖层标记的计算链回退到解析全部公式
+	f.CalcChain = &xlsxCalcChain{C: []xlsxCalcChainC{{R: "B1", I: 1}, {R: "C1"}, {R: "E1"}}}
+	if graph, err := f.buildDependencyGraphFromCalcChain(context.Background()); graph != nil || err != nil {
+		t.Fatalf("expected fallback without dependency levels: %v, %v", graph, err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if histogram, _ := f.LevelHistogram(); !reflect.DeepEqual(histogram, []int{2, 1, 1}) {
+		t.Fatalf("unexpected level histogram %v", histogram)
+	}
+}
@@ -0,0 +1,295 @@
+package excelize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// batchCalcMinFormulas is the minimum number of pure SUMIFS or INDEX-MATCH
+// formulas of a level which BatchCalcFormulas calculates in batch, like the
+// batch patterns of a recalculation.
+const batchCalcMinFormulas = 10
+
+// BatchCalcFormulas calculates the ad-hoc formulas by the cells of a
+// worksheet without storing them in the worksheet, for the what-if
+// calculations which shouldn't change the workbook. The formulas may
+// reference each other's cells, they are calculated in the order of their
+// dependencies with the values of the referenced ad-hoc formulas instead of
+// the values of the cells in the worksheet. The SUMIFS, AVERAGEIFS and
+// INDEX-MATCH formulas are calculated in batch, and the identical SUMIFS and
+// AVERAGEIFS sub-expressions are calculated once. The formulas are given
+// without the leading "=", and the result of a formula is the value returned
+// by CalcCellValue with the raw cell value if the formula was set in the
+// cell. The results of the formulas failed to calculate, like "#DIV/0!", are
+// returned with an error of all failed formulas. For example:
+//
+//	results, err := f.BatchCalcFormulas("Sheet1", map[string]string{
+//	    "E2": "SUMIFS(Data!C:C,Data!A:A,A2,Data!B:B,B2)",
+//	    "F2": "E2*1.1",
+//	})
+//	if err != nil {
+//	    fmt.Println(err)
+//	}
+//	fmt.Println(results["E2"], results["F2"])
+func (f *File) BatchCalcFormulas(sheet string, formulas map[string]string) (map[string]string, error) {
+	results := make(map[string]string, len(formulas))
+	if len(formulas) == 0 {
+		return results, nil
+	}
+	f.mu.Lock()
+	_, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	graph, keys, err := f.buildAdHocGraph(sheet, formulas)
+	if err != nil {
+		return nil, err
+	}
+	graph.assignLevels()
+
+	// 被其他公式引用的临时公式单元格，其值只存在于 overlay 中，
+	// 计算前后都清除包含这些单元格的区域缓存和子表达式缓存
+	referenced := make(map[string]bool)
+	for _, node := range graph.nodes {
+		for _, dep := range node.dependencies {
+			referenced[dep] = true
+		}
+	}
+	f.clearAdHocCache(sheet, graph, referenced)
+	defer f.clearAdHocCache(sheet, graph, referenced)
+
+	overlay := NewWorksheetCache()
+	opts := Options{RawCellValue: true, MaxCalcIterations: 100}
+	var errs []string
+	for levelIdx, levelCells := range graph.levels {
+		batchResults, subExprCache := f.batchCalcAdHocLevel(sheet, levelCells, graph, overlay, opts)
+		f.logger().Debugf("  ⚡ [BatchCalcFormulas Level %d] %d formulas, %d calculated in batch, %d sub-expressions",
+			levelIdx, len(levelCells), len(batchResults), subExprCache.Len())
+		for _, ref := range levelCells {
+			cell := strings.TrimPrefix(ref, sheet+"!")
+			value, ok := batchResults[ref]
+			if !ok {
+				var err error
+				if value, err = f.evalWithSubExprCache(sheet, cell, graph.nodes[ref].formula, subExprCache, overlay, opts); err != nil {
+					errs = append(errs, fmt.Sprintf("failed to calculate %s: %v", keys[ref], err))
+				}
+			}
+			results[keys[ref]] = value
+			overlay.Set(sheet, cell, inferFormulaResultType(value))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return results, nil
+}
+
+// buildAdHocGraph builds the dependency graph of the ad-hoc formulas of
+// BatchCalcFormulas, the dependencies of a formula are the cells of the other
+// ad-hoc formulas it references, directly or in a range. It returns the
+// graph and the keys of the formulas by their nodes.
+func (f *File) buildAdHocGraph(sheet string, formulas map[string]string) (*dependencyGraph, map[string]string, error) {
+	graph := &dependencyGraph{
+		nodes:             make(map[string]*formulaNode, len(formulas)),
+		columnMetadata:    make(map[string]*columnMeta),
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
+	}
+	keys := make(map[string]string, len(formulas))
+	coordinates := make(map[string][2]int, len(formulas))
+	for key, formula := range formulas {
+		col, row, err := CellNameToCoordinates(strings.ReplaceAll(key, "$", ""))
+		if err != nil {
+			return nil, nil, err
+		}
+		cell, _ := CoordinatesToCellName(col, row)
+		ref := sheet + "!" + cell
+		if _, ok := keys[ref]; ok {
+			return nil, nil, fmt.Errorf("duplicate formula cell %s", key)
+		}
+		keys[ref] = key
+		coordinates[ref] = [2]int{col, row}
+		graph.nodes[ref] = &formulaNode{
+			cell:    ref,
+			formula: strings.TrimPrefix(strings.TrimSpace(formula), "="),
+			level:   -1,
+		}
+	}
+	for ref, node := range graph.nodes {
+		deps := make(map[string]bool)
+		for _, precedent := range adHocReferences(node.formula, sheet) {
+			if precedent.sheet != sheet {
+				continue
+			}
+			for other, coordinate := range coordinates {
+				if other != ref && !deps[other] && refContainsCell(precedent.ref, coordinate[0], coordinate[1]) {
+					deps[other] = true
+					node.dependencies = append(node.dependencies, other)
+				}
+			}
+		}
+	}
+	return graph, keys, nil
+}
+
+// adHocReferences returns the cell and range references of a formula, like
+// explainPrecedents without expanding the ranges.
+func adHocReferences(formula, currentSheet string) []explainPrecedent {
+	var references []explainPrecedent
+	ps := efp.ExcelParser()
+	for _, token := range expandImplicitIntersection(ps.Parse(formula)) {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		sheet, ref := currentSheet, token.TValue
+		if strings.Contains(ref, "!") {
+			var ok bool
+			if sheet, ref, ok = splitSheetReference(ref); !ok {
+				continue
+			}
+		}
+		references = append(references, explainPrecedent{sheet: sheet, ref: strings.ReplaceAll(ref, "$", "")})
+	}
+	return references
+}
+
+// refContainsCell returns if a cell reference, or a range reference like
+// "A1:C3", "A:C" or "1:3", contains the cell at the coordinates. The defined
+// names and other references which aren't cells don't contain any cell.
+func refContainsCell(ref string, col, row int) bool {
+	start, end, isRange := strings.Cut(ref, ":")
+	if !isRange {
+		end = start
+	}
+	startCol, startRow, ok1 := refBound(start, 1, 1)
+	endCol, endRow, ok2 := refBound(end, MaxColumns, TotalRows)
+	if !ok1 || !ok2 || (!isRange && (startCol != endCol || startRow != endRow)) {
+		return false
+	}
+	if startCol > endCol {
+		startCol, endCol = endCol, startCol
+	}
+	if startRow > endRow {
+		startRow, endRow = endRow, startRow
+	}
+	return col >= startCol && col <= endCol && row >= startRow && row <= endRow
+}
+
+// refBound returns the coordinates of a bound of a range reference, a column
+// or a row bound like "C" or "3" takes the given row or column.
+func refBound(bound string, col, row int) (int, int, bool) {
+	if c, r, err := CellNameToCoordinates(bound); err == nil {
+		return c, r, true
+	}
+	if c, err := ColumnNameToNumber(bound); err == nil {
+		return c, row, true
+	}
+	if r, err := strconv.Atoi(bound); err == nil && r > 0 {
+		return col, r, true
+	}
+	return 0, 0, false
+}
+
+// batchCalcAdHocLevel calculates the pure SUMIFS and INDEX-MATCH formulas of
+// a level of ad-hoc formulas in batch, and the SUMIFS, AVERAGEIFS and
+// INDEX-MATCH sub-expressions of the other formulas into a sub-expression
+// cache. Only the formulas which don't reference other ad-hoc formulas are
+// calculated in batch, since the batch patterns read the worksheet. Nothing
+// is written to the worksheet or calcCache.
+func (f *File) batchCalcAdHocLevel(sheet string, levelCells []string, graph *dependencyGraph, overlay *WorksheetCache, opts Options) (map[string]string, *SubExpressionCache) {
+	results := make(map[string]string)
+	subExprCache := NewSubExpressionCache()
+	pureSUMIFS := make(map[string]string)
+	indexMatchFormulas := make(map[string]string)
+	subExprs := make(map[string]string) // SUMIFS/AVERAGEIFS 表达式 -> 计算所用的单元格
+	for _, ref := range levelCells {
+		node := graph.nodes[ref]
+		sumifsExpr := extractSUMIFSFromFormula(node.formula)
+		if sumifsExpr == "" {
+			sumifsExpr = extractAVERAGEIFSFromFormula(node.formula)
+		}
+		switch {
+		case sumifsExpr != "" && sumifsExpr == node.formula && len(node.dependencies) == 0:
+			pureSUMIFS[ref] = sumifsExpr
+		case sumifsExpr != "":
+			if _, ok := subExprs[sumifsExpr]; !ok {
+				subExprs[sumifsExpr] = strings.TrimPrefix(ref, sheet+"!")
+			}
+		case batchPatternOf(node.formula) == "INDEX-MATCH" && len(node.dependencies) == 0:
+			if extractINDEXMATCHFromFormula(node.formula) != "" {
+				indexMatchFormulas[ref] = node.formula
+			}
+		}
+	}
+
+	if len(pureSUMIFS) >= batchCalcMinFormulas {
+		for ref, value := range f.batchCalculateSUMIFS(pureSUMIFS) {
+			results[ref] = value
+		}
+	}
+	// 未被批量计算的纯 SUMIFS 也作为子表达式，相同的表达式只计算一次
+	for ref, expr := range pureSUMIFS {
+		if _, ok := results[ref]; !ok {
+			if _, ok := subExprs[expr]; !ok {
+				subExprs[expr] = strings.TrimPrefix(ref, sheet+"!")
+			}
+		}
+	}
+
+	if len(indexMatchFormulas) >= batchCalcMinFormulas {
+		for ref, value := range f.batchCalculateINDEXMATCHWithCache(indexMatchFormulas, overlay) {
+			expr := extractINDEXMATCHFromFormula(indexMatchFormulas[ref])
+			if expr == indexMatchFormulas[ref] {
+				results[ref] = value
+			}
+			subExprCache.Store(expr, value)
+		}
+	}
+
+	ps := efp.ExcelParser()
+	for expr, cell := range subExprs {
+		ctx := &calcContext{
+			entry:             fmt.Sprintf("%s!SUBEXPR", sheet),
+			maxCalcIterations: opts.MaxCalcIterations,
+			iterations:        make(map[string]uint),
+			iterationsCache:   make(map[string]formulaArg),
+			worksheetCache:    overlay,
+		}
+		if result, err := f.evalInfixExp(ctx, sheet, cell, ps.Parse(expr)); err == nil {
+			subExprCache.Store(expr, result.Value())
+		}
+	}
+	return results, subExprCache
+}
+
+// clearAdHocCache removes the sub-expression results of the ad-hoc formula
+// cells from calcCache, and the cached ranges of the worksheet if an ad-hoc
+// formula references another one, since these hold the values of the
+// ad-hoc formulas instead of the values of the worksheet.
+func (f *File) clearAdHocCache(sheet string, graph *dependencyGraph, referenced map[string]bool) {
+	var keys []interface{}
+	f.calcCache.Range(func(key, _ interface{}) bool {
+		if k, ok := key.(string); ok {
+			if ref, _, found := strings.Cut(k, "!subexpr:"); found {
+				if _, isAdHoc := graph.nodes[ref]; isAdHoc {
+					keys = append(keys, key)
+				}
+			}
+		}
+		return true
+	})
+	for _, key := range keys {
+		f.calcCache.Delete(key)
+	}
+	for ref := range referenced {
+		if _, cell, ok := splitSheetReference(ref); ok {
+			f.clearCellCache(sheet, cell)
+			break // clearCellCache 清除整个工作表的区域缓存
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package excelize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBatchCalcFormulas(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	for idx := 0; idx < 60; idx++ {
+		row := []interface{}{fmt.Sprintf("SKU%02d", idx%12), idx%3 + 1, idx * 10}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", idx+1), &row); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	formulas := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("SKU%02d", row-1), (row-1)%3 + 1}); err != nil {
+			t.Fatalf("set criteria row: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("I%d", row), row*40); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		// 纯 SUMIFS 和 INDEX-MATCH 各 12 个，按批量计算
+		formulas[fmt.Sprintf("C%d", row)] = fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,$B%d)", row, row)
+		formulas[fmt.Sprintf("D%d", row)] = fmt.Sprintf("INDEX(Data!$B:$B,MATCH($I%d,Data!$C:$C,0))", row)
+		// 引用其他临时公式的公式
+		formulas[fmt.Sprintf("E%d", row)] = fmt.Sprintf("C%d+D%d", row, row)
+		formulas[fmt.Sprintf("F%d", row)] = fmt.Sprintf("IF(SUMIFS(Data!$C:$C,Data!$A:$A,$A%d)>300,E%d,0)", row, row)
+	}
+	formulas["G1"] = "SUM(E1:E12)"
+	formulas["G2"] = "=G1/COUNT(E:E)"
+	formulas["G3"] = "IFERROR(INDEX(Data!$C:$C,MATCH(\"none\",Data!$A:$A,0)),-1)"
+	// 单元格中已有的值和公式不影响临时公式
+	if err := f.SetCellValue("Sheet1", "C1", 12345); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.SetCellFormula("Sheet1", "H1", "C1*2"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if value, err := f.CalcCellValue("Sheet1", "H1"); err != nil || value != "24690" {
+		t.Fatalf("expected H1 24690, got %q, %v", value, err)
+	}
+	formulas["H1"] = "C1*3"
+	formulas["G4"] = "COUNT(E:E)"
+
+	results, err := f.BatchCalcFormulas("Sheet1", formulas)
+	if err != nil {
+		t.Fatalf("batch calc formulas: %v", err)
+	}
+	if len(results) != len(formulas) {
+		t.Fatalf("expected %d results, got %d", len(formulas), len(results))
+	}
+
+	// 工作簿不被修改
+	if value, err := f.GetCellValue("Sheet1", "C1"); err != nil || value != "12345" {
+		t.Fatalf("expected C1 unchanged, got %q, %v", value, err)
+	}
+	for _, cell := range []string{"C2", "E1", "G1"} {
+		if formula, err := f.GetCellFormula("Sheet1", cell); err != nil || formula != "" {
+			t.Fatalf("expected no formula in %s, got %q, %v", cell, formula, err)
+		}
+		if value, err := f.GetCellValue("Sheet1", cell); err != nil || value != "" {
+			t.Fatalf("expected no value in %s, got %q, %v", cell, value, err)
+		}
+	}
+	if formula, err := f.GetCellFormula("Sheet1", "H1"); err != nil || formula != "C1*2" {
+		t.Fatalf("expected H1 formula unchanged, got %q, %v", formula, err)
+	}
+	if value, err := f.CalcCellValue("Sheet1", "H1"); err != nil || value != "24690" {
+		t.Fatalf("expected H1 24690, got %q, %v", value, err)
+	}
+
+	f.calcCache.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), "Sheet1!E1!") {
+			t.Fatalf("unexpected calculation cache %v", key)
+		}
+		return true
+	})
+
+	// 结果与公式写入单元格后的 CalcCellValue 一致
+	for cell, formula := range formulas {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	for cell := range formulas {
+		expected, err := f.CalcCellValue("Sheet1", cell, Options{RawCellValue: true})
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		if results[cell] != expected {
+			t.Fatalf("expected %s = %q, got %q", cell, expected, results[cell])
+		}
+	}
+	if results["G3"] != "-1" || results["E1"] == "0" {
+		t.Fatalf("unexpected results G3 %q, E1 %q", results["G3"], results["E1"])
+	}
+}
+
+func TestBatchCalcFormulasErrors(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	results, err := f.BatchCalcFormulas("Sheet1", map[string]string{"A1": "1/0", "A2": "A1+1", "A3": "2*3"})
+	if err == nil {
+		t.Fatal("expected error of the failed formulas")
+	}
+	if results["A1"] != formulaErrorDIV || results["A3"] != "6" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if _, err := f.BatchCalcFormulas("SheetN", map[string]string{"A1": "1"}); err == nil {
+		t.Fatal("expected error for a missing sheet")
+	}
+	if _, err := f.BatchCalcFormulas("Sheet1", map[string]string{"A0": "1"}); err == nil {
+		t.Fatal("expected error for an invalid cell")
+	}
+	if _, err := f.BatchCalcFormulas("Sheet1", map[string]string{"A1": "1", "$A$1": "2"}); err == nil {
+		t.Fatal("expected error for a duplicate cell")
+	}
+	if results, err := f.BatchCalcFormulas("Sheet1", nil); err != nil || len(results) != 0 {
+		t.Fatalf("expected no results, got %v, %v", results, err)
+	}
+
+	if !refContainsCell("B:C", 3, 100) || refContainsCell("B:C", 4, 1) || !refContainsCell("2:3", 9, 2) ||
+		!refContainsCell("C3:A1", 2, 2) || refContainsCell("ABC", 731, 1) || refContainsCell("A1", 1, 2) {
+		t.Fatal("unexpected range containment")
+	}
+}
@@ -0,0 +1,237 @@
+package excelize
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// columnAggregateRange identifies a single-column range referenced by
+// MAX/MIN formulas, e.g. Data!$B:$B or Data!$B$2:$B$500
+type columnAggregateRange struct {
+	sheet    string
+	col      int
+	startRow int // 1-based, inclusive
+	endRow   int // 1-based, inclusive; TotalRows for whole-column references
+}
+
+// columnAggregateStats holds the aggregates of one column range calculated by
+// a single scan
+type columnAggregateStats struct {
+	max, min float64
+	count    int  // number of numeric cells
+	hasError bool // the range contains an error value or can't be read, left to the regular evaluation
+}
+
+// isColumnAggregateFunc reports whether the function name is batched by the
+// column aggregate calculator
+func isColumnAggregateFunc(name string) bool {
+	return name == "MAX" || name == "MIN"
+}
+
+// extractColumnAggregates extracts all MAX(...)/MIN(...) expressions whose only
+// argument is a single-column range from a formula, e.g. "MAX(Data!$B:$B)"
+func extractColumnAggregates(formula string) []string {
+	var exprs []string
+	inQuote := false
+	for i := 0; i < len(formula); i++ {
+		if formula[i] == '"' {
+			inQuote = !inQuote
+			continue
+		}
+		if inQuote || formula[i] != '(' || i < 3 {
+			continue
+		}
+		name := formula[i-3 : i]
+		if !isColumnAggregateFunc(name) {
+			continue
+		}
+		// Make sure the name is not the tail of another function, like DMAX or _xlfn.MAXIFS
+		if i > 3 {
+			prev := formula[i-4]
+			if prev == '_' || prev == '.' || (prev >= 'A' && prev <= 'Z') || (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9') {
+				continue
+			}
+		}
+		end := strings.IndexByte(formula[i:], ')')
+		if end == -1 {
+			continue
+		}
+		expr := formula[i-3 : i+end+1]
+		if _, ok := parseColumnAggregate(expr, ""); ok {
+			exprs = append(exprs, expr)
+		}
+	}
+	return exprs
+}
+
+// parseColumnAggregate parses a MAX/MIN expression over a single-column range,
+// the sheet of an unqualified range defaults to currentSheet
+func parseColumnAggregate(expr, currentSheet string) (columnAggregateRange, bool) {
+	var rng columnAggregateRange
+	open := strings.IndexByte(expr, '(')
+	if open == -1 || !strings.HasSuffix(expr, ")") || !isColumnAggregateFunc(expr[:open]) {
+		return rng, false
+	}
+	ref := strings.TrimSpace(expr[open+1 : len(expr)-1])
+	if ref == "" || strings.ContainsAny(ref, ",()\"") {
+		return rng, false
+	}
+	rng.sheet = currentSheet
+	if idx := strings.LastIndex(ref, "!"); idx != -1 {
+		rng.sheet = extractSheetName(ref)
+		ref = ref[idx+1:]
+	}
+	parts := strings.Split(strings.ReplaceAll(ref, "$", ""), ":")
+	if len(parts) != 2 {
+		return rng, false
+	}
+	startCol, startRow, err1 := SplitCellName(parts[0])
+	endCol, endRow, err2 := SplitCellName(parts[1])
+	if err1 != nil || err2 != nil {
+		// Whole-column reference, like B:B
+		startCol, endCol = parts[0], parts[1]
+		startRow, endRow = 1, TotalRows
+	}
+	if !strings.EqualFold(startCol, endCol) {
+		return rng, false
+	}
+	col, err := ColumnNameToNumber(startCol)
+	if err != nil {
+		return rng, false
+	}
+	rng.col, rng.startRow, rng.endRow = col, min(startRow, endRow), max(startRow, endRow)
+	return rng, true
+}
+
+// columnAggregateKey returns the sub-expression cache key of a MAX/MIN
+// expression, the sheet is part of the key because an unqualified range
+// depends on the sheet of the formula
+func columnAggregateKey(sheet, expr string) string {
+	return sheet + "!" + expr
+}
+
+// batchCalculateColumnAggregatesWithCache calculates MAX/MIN expressions over
+// shared single-column ranges. Each distinct range is scanned once no matter
+// how many formulas reference it. The formulas parameter maps "Sheet!Cell" to
+// formula, the result maps each expression to its value per sheet as
+// columnAggregateKey -> value, and the number of range scans is returned as well.
+func (f *File) batchCalculateColumnAggregatesWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string)
+	stats := make(map[columnAggregateRange]*columnAggregateStats)
+	for cell, formula := range formulas {
+		sheet, _, ok := strings.Cut(cell, "!")
+		if !ok {
+			continue
+		}
+		for _, expr := range extractColumnAggregates(formula) {
+			rng, ok := parseColumnAggregate(expr, sheet)
+			if !ok {
+				continue
+			}
+			if _, exists := stats[rng]; !exists {
+				stats[rng] = f.scanColumnAggregate(rng, worksheetCache)
+			}
+			if stats[rng].hasError {
+				continue
+			}
+			value := stats[rng].max
+			if strings.HasPrefix(expr, "MIN") {
+				value = stats[rng].min
+			}
+			if stats[rng].count == 0 {
+				value = 0
+			}
+			results[columnAggregateKey(sheet, expr)] = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	}
+	f.logger().Debugf("  ⚡ [Column Aggregate Batch] %d MAX/MIN expressions over %d distinct ranges", len(results), len(stats))
+	return results, len(stats)
+}
+
+// scanColumnAggregate scans a column range once and collects its maximum and
+// minimum numeric values. Text, logical and empty cells are ignored like the
+// MAX and MIN functions do for range arguments, by the types of the cells as
+// the raw values of a text "50" or a logical TRUE read as numbers.
+func (f *File) scanColumnAggregate(rng columnAggregateRange, worksheetCache *WorksheetCache) *columnAggregateStats {
+	stats := &columnAggregateStats{max: math.Inf(-1), min: math.Inf(1)}
+	rows, err := f.getCachedRawRows(rng.sheet)
+	if err != nil {
+		stats.hasError = true
+		return stats
+	}
+	sheetCache := worksheetCache.GetSheet(rng.sheet)
+	text, logical, err := f.columnTextAndLogicalRows(rng.sheet, rng.col, sheetCache)
+	if err != nil {
+		stats.hasError = true
+		return stats
+	}
+	rows = mergeSheetCacheIntoRows(rows, sheetCache)
+	for rowIdx := rng.startRow - 1; rowIdx < len(rows) && rowIdx < rng.endRow; rowIdx++ {
+		if rng.col > len(rows[rowIdx]) {
+			continue
+		}
+		value := rows[rowIdx][rng.col-1]
+		if value == "" {
+			continue
+		}
+		if isFormulaErrorValue(value) {
+			stats.hasError = true
+			return stats
+		}
+		if text[rowIdx+1] || logical[rowIdx+1] {
+			continue
+		}
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		stats.max, stats.min = math.Max(stats.max, num), math.Min(stats.min, num)
+		stats.count++
+	}
+	return stats
+}
+
+// columnTextAndLogicalRows returns the row numbers of the text and the
+// logical cells of a column of a worksheet, as the raw values of these cells
+// may read as numbers, like "70" or the 1 of TRUE. The calculated values in
+// sheetCache decide the types of their cells.
+func (f *File) columnTextAndLogicalRows(sheet string, col int, sheetCache map[string]formulaArg) (map[int]bool, map[int]bool, error) {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	text, logical := make(map[int]bool), make(map[int]bool)
+	ws.mu.RLock()
+	for r := range ws.SheetData.Row {
+		row := &ws.SheetData.Row[r]
+		for i := range row.C {
+			c := &row.C[i]
+			cellCol, rowNum := i+1, row.R
+			if c.R != "" {
+				if cellCol, rowNum, err = CellNameToCoordinates(c.R); err != nil {
+					continue
+				}
+			}
+			if cellCol != col {
+				continue
+			}
+			if cellType := f.shadowCellType(sheet, cellCol, rowNum, c); cellType == "b" {
+				logical[rowNum] = true
+			} else if isTextCell(&xlsxC{T: cellType}) {
+				text[rowNum] = true
+			}
+		}
+	}
+	ws.mu.RUnlock()
+	for cell, arg := range sheetCache {
+		cellCol, rowNum, err := CellNameToCoordinates(cell)
+		if err != nil || cellCol != col {
+			continue
+		}
+		text[rowNum], logical[rowNum] = arg.Type == ArgString, arg.Type == ArgNumber && arg.Boolean
+	}
+	return text, logical, nil
+}
@@ -0,0 +1,151 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExtractColumnAggregates(t *testing.T) {
+	cases := []struct {
+		formula string
+		want    []string
+	}{
+		{"MAX(Data!$B:$B)", []string{"MAX(Data!$B:$B)"}},
+		{"=MAX(B:B)-MIN(Data!$B$2:$B$100)", []string{"MAX(B:B)", "MIN(Data!$B$2:$B$100)"}},
+		{"MAX('My Data'!C:C)*2", []string{"MAX('My Data'!C:C)"}},
+		{"DMAX(A1:C10,2,E1:E2)", nil},
+		{"_xlfn.MAXIFS(B:B,A:A,1)", nil},
+		{"MAX(A:B)", nil},
+		{"MAX(B:B,C:C)", nil},
+		{"MAX(B1)", nil},
+		{`"MAX(B:B)"&A1`, nil},
+	}
+	for _, c := range cases {
+		if got := extractColumnAggregates(c.formula); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("extractColumnAggregates(%q) = %v, want %v", c.formula, got, c.want)
+		}
+	}
+}
+
+func TestBatchColumnAggregatesSingleScan(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	if err := f.SetCellValue("Data", "B1", "Amount"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for r := 2; r <= 100; r++ {
+		if r == 50 {
+			continue // 空单元格应被忽略
+		}
+		if err := f.SetCellValue("Data", fmt.Sprintf("B%d", r), (r*37)%101-20); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+
+	formulas := make(map[string]string)
+	for r := 1; r <= 20; r++ {
+		cells := map[string]string{
+			fmt.Sprintf("A%d", r): "MAX(Data!$B:$B)",
+			fmt.Sprintf("B%d", r): "MIN(Data!$B:$B)",
+			fmt.Sprintf("C%d", r): fmt.Sprintf("MAX(Data!$B$2:$B$60)+%d", r),
+		}
+		for cell, formula := range cells {
+			if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			formulas["Sheet1!"+cell] = formula
+		}
+	}
+
+	// 期望值由普通计算得出
+	want := make(map[string]string)
+	for cell := range formulas {
+		value, err := f.CalcCellValue("Sheet1", cell[len("Sheet1!"):])
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		want[cell] = value
+	}
+
+	results, scans := f.batchCalculateColumnAggregatesWithCache(formulas, NewWorksheetCache())
+	if scans != 2 {
+		t.Fatalf("expected one scan per distinct range (2), got %d", scans)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 distinct expressions, got %v", results)
+	}
+	if results[columnAggregateKey("Sheet1", "MAX(Data!$B:$B)")] != want["Sheet1!A1"] ||
+		results[columnAggregateKey("Sheet1", "MIN(Data!$B:$B)")] != want["Sheet1!B1"] {
+		t.Fatalf("unexpected batch results %v, want MAX %s MIN %s", results, want["Sheet1!A1"], want["Sheet1!B1"])
+	}
+
+	f.calcCache.Clear()
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalc failed: %v", err)
+	}
+	for cell, expected := range want {
+		sheet, ref := "Sheet1", cell[len("Sheet1!"):]
+		if got, _ := f.GetCellValue(sheet, ref); got != expected {
+			t.Fatalf("%s = %q, want %q", cell, got, expected)
+		}
+	}
+}
+
+func TestBatchColumnAggregatesErrorInRange(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for r, v := range []string{"1", "#N/A", "3"} {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", r+1), v); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	results, _ := f.batchCalculateColumnAggregatesWithCache(map[string]string{"Sheet1!B1": "MAX(A:A)"}, NewWorksheetCache())
+	if len(results) != 0 {
+		t.Fatalf("expected ranges with errors to be left to the regular evaluation, got %v", results)
+	}
+}
+
+func TestBatchColumnAggregatesTypedCells(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	// 逻辑值和文本数字的原始值可被解析为数值，但 MAX 和 MIN 忽略它们
+	for r, v := range []interface{}{true, "50", -3, -7} {
+		if err := f.SetCellValue("Data", fmt.Sprintf("B%d", r+1), v); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	formulas := map[string]string{
+		"Sheet1!A1": "MAX(Data!$B:$B)",
+		"Sheet1!A2": "MIN(Data!$B:$B)",
+		"Sheet1!A3": "MAX(Nope!$B:$B)",
+	}
+	results, _ := f.batchCalculateColumnAggregatesWithCache(formulas, NewWorksheetCache())
+	want := map[string]string{
+		columnAggregateKey("Sheet1", "MAX(Data!$B:$B)"): "-3",
+		columnAggregateKey("Sheet1", "MIN(Data!$B:$B)"): "-7",
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("unexpected batch results %v, want %v", results, want)
+	}
+	for cell, formula := range formulas {
+		if err := f.SetCellFormula("Sheet1", cell[len("Sheet1!"):], formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if value, err := f.CalcCellValue("Sheet1", "A1"); err != nil || value != "-3" {
+		t.Fatalf("unexpected calculated MAX %q, %v", value, err)
+	}
+}
@@ -0,0 +1,102 @@
+package excelize
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/xuri/efp"
+)
+
+// constantFormulaExcludedFuncs are the functions without references whose
+// result depends on the calculated cell or changes from call to call, and the
+// array constants and functions whose element in a cell depends on its
+// position, the formulas using them are not constant.
+var constantFormulaExcludedFuncs = map[string]bool{
+	"ARRAY": true, "CELL": true, "COLUMN": true, "INDIRECT": true, "INFO": true,
+	"MUNIT": true, "OFFSET": true, "RAND": true, "RANDARRAY": true,
+	"RANDBETWEEN": true, "ROW": true, "SEQUENCE": true, "SHEET": true,
+}
+
+// isConstantFormula reports whether the formula references no cell, range or
+// name, such as =42, ="N/A" or =TODAY()-TODAY(), so it gives the same value in
+// every cell within a recalculation.
+func isConstantFormula(formula string) bool {
+	formula = strings.TrimPrefix(strings.TrimSpace(formula), "=")
+	if formula == "" {
+		return false
+	}
+	ps := efp.ExcelParser()
+	tokens := ps.Parse(formula)
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, token := range tokens {
+		if token.TType == efp.TokenTypeOperand && token.TSubType == efp.TokenSubTypeRange {
+			return false
+		}
+		if token.TType == efp.TokenTypeFunction && token.TSubType == efp.TokenSubTypeStart &&
+			constantFormulaExcludedFuncs[strings.TrimPrefix(strings.ToUpper(token.TValue), "_XLFN.")] {
+			return false
+		}
+	}
+	return true
+}
+
+// markConstantFormulas marks the formulas without dependencies which are
+// constant, so each distinct constant formula is calculated once.
+func (g *dependencyGraph) markConstantFormulas() {
+	for _, node := range g.nodes {
+		node.constant = len(node.dependencies) == 0 && isConstantFormula(node.formula)
+	}
+}
+
+// calculateConstantFormulas calculates the constant formulas of a level once
+// per distinct formula and stores the shared result into all their cells. It
+// returns the other cells of the level, the calculated constant cells and the
+// number of the distinct constant formulas.
+func (f *File) calculateConstantFormulas(ctx context.Context, levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) ([]string, []string, int) {
+	constants := make(map[string][]string) // 公式 -> 单元格
+	pending := make([]string, 0, len(levelCells))
+	for _, cell := range levelCells {
+		if node, exists := graph.nodes[cell]; exists && node.constant {
+			constants[node.formula] = append(constants[node.formula], cell)
+			continue
+		}
+		pending = append(pending, cell)
+	}
+	var calculated []string
+	profile := calcProfileFromContext(ctx)
+	for formula, cells := range constants {
+		if ctx.Err() != nil {
+			return pending, calculated, len(constants)
+		}
+		sheet, cellName, ok := splitSheetReference(cells[0])
+		if !ok {
+			pending = append(pending, cells...)
+			continue
+		}
+		opts := Options{RawCellValue: true, MaxCalcIterations: 100}
+		start := time.Now()
+		value, err := f.CalcCellValueWithSubExprCache(sheet, cellName, formula, nil, worksheetCache, opts)
+		if profile != nil {
+			profile.addFormula(cells[0], formula, time.Since(start))
+		}
+		if err != nil && value == "" {
+			pending = append(pending, cells...)
+			continue
+		}
+		// 相同公式的单元格共享同一个结果
+		arg := inferFormulaResultType(value)
+		for _, cell := range cells {
+			if sheet, cellName, ok := splitSheetReference(cell); ok {
+				f.storeCalculatedArg(sheet, cellName, value, arg, worksheetCache)
+				calculated = append(calculated, cell)
+			}
+		}
+	}
+	if len(calculated) > 0 {
+		f.logger().Debugf("  ⚡ [Constant] Calculated %d constant formulas as %d distinct formulas", len(calculated), len(constants))
+	}
+	return pending, calculated, len(constants)
+}
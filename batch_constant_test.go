@@ -0,0 +1,86 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestIsConstantFormula(t *testing.T) {
+	for formula, want := range map[string]bool{
+		`"N/A"`:             true,
+		"=42":               true,
+		"TODAY()-TODAY()":   true,
+		"ROUND(PI()*2,2)":   true,
+		"":                  false,
+		"A1":                false,
+		"SUM(Data!A:A)":     false,
+		"MyName*2":          false,
+		"ROW()":             false,
+		"RAND()":            false,
+		"_xlfn.SEQUENCE(3)": false,
+		"SUM({1,2,3})":      false,
+		`INDIRECT("A1")`:    false,
+	} {
+		if got := isConstantFormula(formula); got != want {
+			t.Fatalf("isConstantFormula(%q) = %t, want %t", formula, got, want)
+		}
+	}
+}
+
+func TestRecalculateConstantFormulas(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	const rows = 5000
+	for row := 1; row <= rows; row++ {
+		for col, formula := range map[string]string{
+			"A": `"N/A"`,
+			"B": "42",
+			"C": "ROW()",
+			"D": fmt.Sprintf(`A%d&"-"&B%d`, row, row),
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "E1", "TODAY()-TODAY()"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for row := 1; row <= rows; row += 999 {
+		for col, want := range map[string]string{"A": "N/A", "B": "42", "C": fmt.Sprint(row), "D": "N/A-42"} {
+			cell := fmt.Sprintf("%s%d", col, row)
+			if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+				t.Fatalf("unexpected %s value %q, want %q", cell, got, want)
+			}
+		}
+	}
+	if got, _ := f.GetCellValue("Sheet1", "E1"); got != "0" {
+		t.Fatalf("unexpected E1 value %q, want 0", got)
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.ConstantFormulas != 2*rows+1 || plan.DistinctConstants != 3 {
+		t.Fatalf("unexpected plan constant formulas %d, distinct %d", plan.ConstantFormulas, plan.DistinctConstants)
+	}
+	optimizers := make(map[string]string)
+	for _, entry := range f.CalcAuditTrail() {
+		optimizers[entry.Cell] = entry.Optimizer
+	}
+	for cell, want := range map[string]string{"Sheet1!A1": auditOptimizerConstant, "Sheet1!B9": auditOptimizerConstant, "Sheet1!C1": auditOptimizerPreCalc} {
+		if optimizers[cell] != want {
+			t.Fatalf("unexpected optimizer %q of %s, want %q", optimizers[cell], cell, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// countifExpr represents a COUNTIF or COUNTA over a single column range, e.g.
+// COUNTIF(Data!$A:$A,$B2) or COUNTA(Data!$A:$A)
+type countifExpr struct {
+	column   lookupTable // the counted column range
+	criteria string      // criteria argument of COUNTIF: literal or cell reference without $
+	countA   bool        // COUNTA counts the non-empty cells without a criteria
+}
+
+// countifStats holds the counts of a column range collected by a single scan,
+// shared by all COUNTIF and COUNTA formulas over the range
+type countifStats struct {
+	frequency map[string]float64 // non-empty number or logical value -> number of cells
+	text      map[string]float64 // non-empty text value -> number of cells
+	nonEmpty  int                // number of non-empty cells
+	rows      int                // number of rows of the range, a whole column ends at the last used row
+	hasError  bool               // the range contains an error value or can't be read, left to the regular evaluation
+}
+
+// parseCOUNTIFExpr parses a COUNTIF expression over a single column range with
+// a literal or a single cell criteria, or a COUNTA expression whose only
+// argument is a single column range. The sheet of an unqualified range
+// defaults to currentSheet.
+func parseCOUNTIFExpr(expr, currentSheet string) (*countifExpr, bool) {
+	expr = strings.TrimSpace(expr)
+	name, argc := "COUNTIF", 2
+	if strings.HasPrefix(expr, "COUNTA(") {
+		name, argc = "COUNTA", 1
+	} else if !strings.HasPrefix(expr, "COUNTIF(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, name)
+	if name+"("+content+")" != expr {
+		return nil, false
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != argc {
+		return nil, false
+	}
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if strings.ContainsAny(args[0], "(),\"") {
+		return nil, false
+	}
+	column, ok := parseLookupTable(args[0], currentSheet)
+	if !ok || column.startCol != column.endCol {
+		return nil, false
+	}
+	if name == "COUNTA" {
+		return &countifExpr{column: column, countA: true}, true
+	}
+	criteria := args[1]
+	if criteria == "" || strings.ContainsAny(criteria, "()") || !isBatchCriteriaArg(criteria) {
+		return nil, false
+	}
+	if criteria[0] != '"' {
+		criteria = strings.ReplaceAll(criteria, "$", "")
+	}
+	return &countifExpr{column: column, criteria: criteria}, true
+}
+
+// isCOUNTIFFormula reports whether the formula is a single COUNTIF or COUNTA
+// over a column range which can be calculated in batch
+func isCOUNTIFFormula(formula string) bool {
+	_, ok := parseCOUNTIFExpr(strings.TrimPrefix(strings.TrimSpace(formula), "="), "")
+	return ok
+}
+
+// batchCalculateCOUNTIFWithCache calculates COUNTIF and COUNTA formulas over
+// single column ranges. Each distinct range is scanned once into a frequency
+// map of its values and the number of its non-empty cells, no matter how many
+// formulas count it. The formulas parameter maps "Sheet!Cell" to formula, it
+// returns the results by cell and the number of scanned ranges.
+//
+// The criteria are matched like the batch SUMIFS does, the values keep the
+// cell types: a number stored as text doesn't meet the numeric criteria and
+// the logical values only meet the TRUE and FALSE criteria. The ""
+// criterion counts the blank cells and the empty strings of the range, the
+// formulas with another CalcTuning.BlankEqualsEmptyString mode, with a
+// criteria which may match the blank cells like "<>x", or with a blank
+// criteria cell are calculated one by one.
+func (f *File) batchCalculateCOUNTIFWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	stats := make(map[lookupTable]*countifStats)
+	for fullCell, formula := range formulas {
+		sheet, _, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		expr, ok := parseCOUNTIFExpr(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+		if !ok {
+			continue
+		}
+		if _, exists := stats[expr.column]; !exists {
+			stats[expr.column] = f.scanCOUNTIFColumn(expr.column, worksheetCache)
+		}
+		columnStats := stats[expr.column]
+		if columnStats.hasError {
+			continue
+		}
+		if expr.countA {
+			results[fullCell] = strconv.Itoa(columnStats.nonEmpty)
+			continue
+		}
+		if expr.criteria == `""` {
+			// 空白单元格和空字符串都计数，其他模式逐个计算
+			if f.calcTuning.BlankEqualsEmptyString != BlankMatchesBoth {
+				continue
+			}
+			results[fullCell] = strconv.Itoa(columnStats.rows - columnStats.nonEmpty)
+			continue
+		}
+		criterion, ok := parseSUMIFSCriterion(f.resolveCriteriaValue(sheet, expr.criteria, worksheetCache))
+		if !ok {
+			continue
+		}
+		count := sumifs1DSum(columnStats.frequency, criterion)
+		for value, n := range columnStats.text {
+			if criterion.matchText(value) {
+				count += n
+			}
+		}
+		results[fullCell] = strconv.FormatFloat(count, 'f', -1, 64)
+	}
+	f.logger().Debugf("  ⚡ [COUNTIF Batch] %d COUNTIF/COUNTA formulas over %d distinct ranges", len(results), len(stats))
+	return results, len(stats)
+}
+
+// scanCOUNTIFColumn scans a column range once and counts its values. The
+// blank cells and the empty strings are not counted as values, a whole column
+// range ends at the last row with a value or a formula like the regular
+// evaluation of the range.
+func (f *File) scanCOUNTIFColumn(column lookupTable, worksheetCache *WorksheetCache) *countifStats {
+	stats := &countifStats{frequency: make(map[string]float64), text: make(map[string]float64)}
+	valueRange := f.optimizeValueRange(column.sheet, []int{column.startRow, column.endRow, column.startCol, column.startCol})
+	stats.rows = valueRange[1] - valueRange[0] + 1
+	rows, err := f.getCachedRawRows(column.sheet)
+	if err != nil {
+		stats.hasError = true
+		return stats
+	}
+	sheetCache := worksheetCache.GetSheet(column.sheet)
+	text, logical, err := f.columnTextAndLogicalRows(column.sheet, column.startCol, sheetCache)
+	if err != nil {
+		stats.hasError = true
+		return stats
+	}
+	rows = mergeSheetCacheIntoRows(rows, sheetCache)
+	for rowIdx := column.startRow - 1; rowIdx < len(rows) && rowIdx < valueRange[1]; rowIdx++ {
+		if column.startCol > len(rows[rowIdx]) {
+			continue
+		}
+		value := rows[rowIdx][column.startCol-1]
+		if value == "" {
+			continue
+		}
+		if isFormulaErrorValue(value) {
+			stats.hasError = true
+			return stats
+		}
+		switch {
+		case text[rowIdx+1]:
+			stats.text[value]++
+		case logical[rowIdx+1]:
+			// 逻辑值按 TRUE 和 FALSE 区别于数值
+			if canonical := sumifsBooleanValue(value); canonical != "" {
+				value = canonical
+			}
+			stats.frequency[strings.ToUpper(value)]++
+		default:
+			stats.frequency[value]++
+		}
+		stats.nonEmpty++
+	}
+	return stats
+}
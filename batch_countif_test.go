@@ -0,0 +1,249 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestParseCOUNTIFExpr(t *testing.T) {
+	for formula, want := range map[string]bool{
+		"=COUNTIF(Data!$A:$A,$B2)":          true,
+		"COUNTIF(A:A,\">5\")":               true,
+		"COUNTIF(Data!$A$2:$A$50,\"\")":     true,
+		"COUNTIF(Data!$A:$A,Config!$B$1)":   true,
+		"COUNTA(Data!$A:$A)":                true,
+		"COUNTA('My Data'!C2:C9)":           true,
+		"COUNTIF(Data!$A:$B,$B2)":           false,
+		"COUNTIF(Data!$A:$A,Data!$B:$B)":    false,
+		"COUNTIF(Data!$A:$A,LEFT(B2,1))":    false,
+		"COUNTIF(Data!$A:$A,$B2)+1":         false,
+		"COUNTIFS(Data!$A:$A,$B2)":          false,
+		"COUNTA(Data!$A:$A,Data!$B:$B)":     false,
+		"COUNTA(Data!$A:$B)":                false,
+		"COUNTA(\"x\")":                     false,
+		"COUNT(Data!$A:$A)":                 false,
+		"SUM(COUNTIF(Data!$A:$A,$B2))":      false,
+		"COUNTIF(Data!$A:$A)":               false,
+		"COUNTIF(OFFSET(A1,0,0,9,1),\"x\")": false,
+	} {
+		if got := isCOUNTIFFormula(formula); got != want {
+			t.Fatalf("isCOUNTIFFormula(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	expr, ok := parseCOUNTIFExpr("COUNTIF(A$2:A$9,\"x\")", "Sheet1")
+	if !ok || expr.column != (lookupTable{sheet: "Sheet1", startCol: 1, endCol: 1, startRow: 2, endRow: 9}) || expr.criteria != `"x"` {
+		t.Fatalf("unexpected expression %+v", expr)
+	}
+}
+
+func TestBatchCalculateCOUNTIF(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 区域列包含空单元格，数量列由公式计算，C 列的数据更长，决定整列范围的行数
+	regions := []interface{}{"Region", "North", "South", nil, "North", "East", 10, nil, "South", "North", 2.5, nil, "West"}
+	for i, region := range regions {
+		row := i + 1
+		if region != nil {
+			if err := f.SetCellValue("Data", fmt.Sprintf("A%d", row), region); err != nil {
+				t.Fatalf("set value: %v", err)
+			}
+		}
+		if err := f.SetCellValue("Data", fmt.Sprintf("B%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Data", fmt.Sprintf("D%d", row), fmt.Sprintf("IF(B%d>6,B%d,\"\")", row, row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Data", "C20", "end"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	keys := []interface{}{"North", "South", "East", "West", "Nowhere", 10, 2.5, 7, nil}
+	for i, key := range keys {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": "COUNTIF(Data!$A:$A,$A%d)",
+			"C": "COUNTIF(Data!$A$2:$A$9,$A%d)",
+			"D": "COUNTIF(Data!$D:$D,$A%d)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+	for cell, formula := range map[string]string{
+		"F1": `COUNTIF(Data!$A:$A,"")`,
+		"F2": `COUNTIF(Data!$A$1:$A$30,"")`,
+		"F3": `COUNTIF(Data!$A:$A,">5")`,
+		"F4": `COUNTIF(Data!$A:$A,"<>")`,
+		"F5": `COUNTIF(Data!$A:$A,"North")`,
+		"F6": "COUNTA(Data!$A:$A)",
+		"F7": "COUNTA(Data!$A$2:$A$9)",
+		"F8": "COUNTA(Data!$D:$D)",
+		"F9": `COUNTIF(Data!$D:$D,"")`,
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 批量结果与逐个计算的结果一致
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	got := make(map[string]string)
+	cells := []string{"F1", "F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9"}
+	for i := range keys {
+		for _, col := range []string{"B", "C", "D"} {
+			cells = append(cells, fmt.Sprintf("%s%d", col, i+2))
+		}
+	}
+	for _, cell := range cells {
+		got[cell], _ = f.GetCellValue("Sheet1", cell)
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.COUNTIFFormulas != len(cells) || plan.COUNTIFRanges != 4 {
+		t.Fatalf("unexpected plan COUNTIF formulas %d, ranges %d", plan.COUNTIFFormulas, plan.COUNTIFRanges)
+	}
+	// 空白的条件单元格逐个计算
+	optimizers := make(map[string]string)
+	for _, entry := range f.CalcAuditTrail() {
+		optimizers[entry.Cell] = entry.Optimizer
+	}
+	if optimizers["Sheet1!B2"] != "COUNTIF" || optimizers["Sheet1!F1"] != "COUNTIF" || optimizers["Sheet1!B10"] != "cell" {
+		t.Fatalf("unexpected optimizers %v", optimizers)
+	}
+	f.ClearFormulaCache()
+	for _, cell := range cells {
+		want, err := f.CalcCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		if got[cell] != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got[cell], want)
+		}
+	}
+	// COUNTA 不计数空单元格和空字符串，"" 条件计数整列范围中最后一行之前的空白单元格
+	for cell, value := range map[string]string{
+		"B2": "3", "C2": "2", "B7": "1", "B8": "1", "B9": "0", "D9": "1", "F1": "10", "F2": "20",
+		"F3": "1", "F4": "10", "F5": "3", "F6": "10", "F7": "6", "F8": "7", "F9": "13",
+	} {
+		if got[cell] != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got[cell], value)
+		}
+	}
+
+	// 文本数字不满足数值条件，与逐个计算一致
+	if err := f.SetCellValue("Data", "A4", "10"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	results, ranges := f.batchCalculateCOUNTIFWithCache(map[string]string{
+		"Sheet1!B7": "COUNTIF(Data!$A:$A,$A7)",
+		"Sheet1!F6": "COUNTA(Data!$A:$A)",
+		"Sheet1!F1": `COUNTIF(Data!$A:$A,"")`,
+	}, NewWorksheetCache())
+	if ranges != 1 || results["Sheet1!B7"] != "1" || results["Sheet1!F6"] != "11" || results["Sheet1!F1"] != "9" {
+		t.Fatalf("unexpected results %v over %d ranges", results, ranges)
+	}
+
+	// 可能匹配空白单元格的条件、空白的条件单元格和包含错误值的范围逐个计算
+	if err := f.SetCellValue("Data", "E2", "#N/A"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	results, _ = f.batchCalculateCOUNTIFWithCache(map[string]string{
+		"Sheet1!B10": "COUNTIF(Data!$A:$A,$A10)",
+		"Sheet1!F1":  `COUNTIF(Data!$A:$A,"<>North")`,
+		"Sheet1!F2":  `COUNTIF(Data!$A:$A,"=")`,
+		"Sheet1!F3":  "COUNTA(Data!$E:$E)",
+		"Sheet1!F4":  "COUNTA(Missing!$E:$E)",
+	}, NewWorksheetCache())
+	if len(results) != 0 {
+		t.Fatalf("unexpected results %v", results)
+	}
+	f.SetCalcTuning(CalcTuning{BlankEqualsEmptyString: BlankMatchesBlankOnly})
+	results, _ = f.batchCalculateCOUNTIFWithCache(map[string]string{
+		"Sheet1!F1": `COUNTIF(Data!$A:$A,"")`,
+		"Sheet1!F6": "COUNTA(Data!$A:$A)",
+	}, NewWorksheetCache())
+	if _, ok := results["Sheet1!F1"]; ok || results["Sheet1!F6"] != "11" {
+		t.Fatalf("unexpected results %v", results)
+	}
+}
+
+func TestBatchCalculateCOUNTIFTypedCells(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 文本 "70" 和逻辑值的原始值可被解析为数值，按单元格类型匹配条件
+	for i := 1; i <= 10; i++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("C%d", i), i*10); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for cell, value := range map[string]interface{}{"C11": "70", "C12": "abc", "D1": true, "D2": false, "D3": 1, "D4": 0} {
+		if err := f.SetCellValue("Data", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	formulas := []string{
+		`COUNTIF(Data!$C:$C,">"&1)`, `COUNTIF(Data!$C:$C,"<=70")`, `COUNTIF(Data!$C:$C,70)`,
+		`COUNTIF(Data!$C:$C,"70")`, `COUNTIF(Data!$D:$D,1)`, `COUNTIF(Data!$D:$D,TRUE)`,
+		`COUNTIF(Data!$C:$C,"<a")`, `COUNTIF(Data!$C:$C,"7*")`, `COUNTIF(Data!$C:$C,"<>")`,
+		`COUNTIF(Data!$C:$C,"abc")`, `COUNTIF(Data!$D:$D,"FALSE")`, `COUNTIF(Data!$D:$D,0)`,
+	}
+	for i, formula := range formulas {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("A%d", i+1), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	// 逻辑值字面量条件逐个计算
+	for _, entry := range f.CalcAuditTrail() {
+		if entry.Optimizer != "COUNTIF" && entry.Cell != "Sheet1!A6" {
+			t.Fatalf("unexpected audit entry %v, want COUNTIF", entry)
+		}
+	}
+	got := make(map[string]string)
+	for i := range formulas {
+		cell := fmt.Sprintf("A%d", i+1)
+		got[cell], _ = f.GetCellValue("Sheet1", cell)
+	}
+	if got["A1"] != "10" {
+		t.Fatalf("unexpected A1 value %q, want 10", got["A1"])
+	}
+	f.ClearFormulaCache()
+	for i, formula := range formulas {
+		cell := fmt.Sprintf("A%d", i+1)
+		want, err := f.CalcCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		if got[cell] != want {
+			t.Fatalf("unexpected %s value %q of %s, want %q", cell, got[cell], formula, want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package excelize
 
 import (
-	"log"
+	"context"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,6 +26,9 @@ type DAGScheduler struct {
 	queueClosed     atomic.Bool         // 标记队列是否已关闭
 	subExprCache    *SubExpressionCache // 子表达式缓存（用于复合公式）
 	worksheetCache  *WorksheetCache     // 统一的worksheet缓存（用于存储所有计算结果）
+	ctx             context.Context     // 取消后剩余公式只通知依赖、不再计算
+	cacheHits       atomic.Int64        // 直接使用批量预计算结果的公式数量
+	cacheMisses     atomic.Int64        // 逐个计算的公式数量
 }
 
 // NewDAGScheduler creates a new DAG scheduler
@@ -146,34 +149,42 @@ func (f *File) NewDAGSchedulerForLevel(graph *dependencyGraph, levelIdx int, lev
 
 // Run executes the DAG scheduler
 func (scheduler *DAGScheduler) Run() {
+	_ = scheduler.RunWithContext(context.Background())
+}
+
+// RunWithContext executes the DAG scheduler until all formulas are processed
+// or ctx is canceled. After cancellation the workers drain the remaining
+// formulas without calculating them, and the context error is returned.
+func (scheduler *DAGScheduler) RunWithContext(ctx context.Context) error {
+	scheduler.ctx = ctx
 	startTime := time.Now()
-	log.Printf("🚀 [DAG Scheduler] Starting: %d formulas with %d workers", scheduler.totalFormulas, scheduler.numWorkers)
+	scheduler.f.logger().Debugf("🚀 [DAG Scheduler] Starting: %d formulas with %d workers", scheduler.totalFormulas, scheduler.numWorkers)
 
 	// 统计初始 ready queue 中有多少公式
 	initialReady := len(scheduler.readyQueue)
-	log.Printf("  📊 [DAG Scheduler] Initial ready queue size: %d formulas (no dependencies)", initialReady)
+	scheduler.f.logger().Debugf("  📊 [DAG Scheduler] Initial ready queue size: %d formulas (no dependencies)", initialReady)
 
 	// 边界情况：空图直接返回
 	if scheduler.totalFormulas == 0 {
-		log.Printf("✅ [DAG Scheduler] No formulas to calculate, exiting immediately")
-		return
+		scheduler.f.logger().Debugf("✅ [DAG Scheduler] No formulas to calculate, exiting immediately")
+		return nil
 	}
 
 	// 检查是否有依赖问题（如果没有任何公式准备好）
 	if initialReady == 0 && scheduler.totalFormulas > 0 {
-		log.Printf("⚠️ [DAG Scheduler] WARNING: No formulas ready! Possible circular dependency or dependency issue")
+		scheduler.f.logger().Infof("⚠️ [DAG Scheduler] WARNING: No formulas ready! Possible circular dependency or dependency issue")
 		// 打印一些有依赖的公式示例
 		count := 0
 		for cell, depCount := range scheduler.dependencyCount {
 			if depCount > 0 && count < 5 {
 				if node, exists := scheduler.graph.nodes[cell]; exists {
-					log.Printf("    Example blocked formula: %s (waiting for %d deps) = %s", cell, depCount, node.formula[:min(100, len(node.formula))])
-					log.Printf("      Dependencies: %v", node.dependencies[:min(5, len(node.dependencies))])
+					scheduler.f.logger().Debugf("    Example blocked formula: %s (waiting for %d deps) = %s", cell, depCount, node.formula[:min(100, len(node.formula))])
+					scheduler.f.logger().Debugf("      Dependencies: %v", node.dependencies[:min(5, len(node.dependencies))])
 				}
 				count++
 			}
 		}
-		return
+		return nil
 	}
 
 	// 确保在函数退出时关闭队列，防止 goroutine 泄漏
@@ -205,7 +216,7 @@ func (scheduler *DAGScheduler) Run() {
 				elapsed := time.Since(startTime)
 				rate := float64(currentCompleted) / elapsed.Seconds()
 
-				log.Printf("  📊 [Progress] %d/%d (%.1f%%) completed, %d in-flight, %d queued, %.1f/sec",
+				scheduler.f.logger().Debugf("  📊 [Progress] %d/%d (%.1f%%) completed, %d in-flight, %d queued, %.1f/sec",
 					currentCompleted, scheduler.totalFormulas,
 					float64(currentCompleted)*100/float64(scheduler.totalFormulas),
 					inFlight, queueLen, rate)
@@ -213,10 +224,10 @@ func (scheduler *DAGScheduler) Run() {
 				// 检查是否停滞
 				if currentCompleted == lastCompleted && inFlight == 0 && currentCompleted < int64(scheduler.totalFormulas) {
 					stallCount++
-					log.Printf("  ⚠️ [Progress] Stall detected: no progress for %d checks", stallCount)
+					scheduler.f.logger().Debugf("  ⚠️ [Progress] Stall detected: no progress for %d checks", stallCount)
 
 					if stallCount >= 6 { // 30秒后强制关闭
-						log.Printf("⚠️ [DAG Scheduler] Forcing close after stall")
+						scheduler.f.logger().Infof("⚠️ [DAG Scheduler] Forcing close after stall")
 						scheduler.closeReadyQueue()
 						return
 					}
@@ -234,11 +245,12 @@ func (scheduler *DAGScheduler) Run() {
 
 	duration := time.Since(startTime)
 	if scheduler.totalFormulas > 0 {
-		log.Printf("✅ [DAG Scheduler] Completed %d formulas in %v (avg: %v/formula)",
+		scheduler.f.logger().Debugf("✅ [DAG Scheduler] Completed %d formulas in %v (avg: %v/formula)",
 			scheduler.totalFormulas, duration, duration/time.Duration(scheduler.totalFormulas))
 	} else {
-		log.Printf("✅ [DAG Scheduler] Completed in %v", duration)
+		scheduler.f.logger().Debugf("✅ [DAG Scheduler] Completed in %v", duration)
 	}
+	return ctx.Err()
 }
 
 // worker processes formulas from the ready queue
@@ -255,20 +267,25 @@ func (scheduler *DAGScheduler) executeFormula(cell string) {
 	scheduler.inFlightCount.Add(1)
 	defer scheduler.inFlightCount.Add(-1)
 
-	// Parse cell reference
-	parts := strings.Split(cell, "!")
-	if len(parts) != 2 {
+	// 已取消：不再计算，只推进依赖计数让队列尽快排空
+	if scheduler.ctx != nil && scheduler.ctx.Err() != nil {
 		scheduler.notifyDependents(cell)
 		scheduler.markFormulaDone()
 		return
 	}
 
-	sheet := parts[0]
-	cellName := parts[1]
+	// Parse cell reference
+	sheet, cellName, ok := splitSheetReference(cell)
+	if !ok {
+		scheduler.notifyDependents(cell)
+		scheduler.markFormulaDone()
+		return
+	}
 
 	// 优化：先检查 worksheetCache 是否已有批量预计算的结果
 	if scheduler.worksheetCache != nil {
 		if cachedArg, found := scheduler.worksheetCache.Get(sheet, cellName); found {
+			scheduler.cacheHits.Add(1)
 			value := cachedArg.Value()
 			scheduler.results.Store(cell, value)
 			scheduler.f.setFormulaValue(sheet, cellName, value)
@@ -282,6 +299,7 @@ func (scheduler *DAGScheduler) executeFormula(cell string) {
 	cacheKey := cell + "!raw=true"
 	if cached, ok := scheduler.f.calcCache.Load(cacheKey); ok {
 		if value, isStr := cached.(string); isStr {
+			scheduler.cacheHits.Add(1)
 			scheduler.results.Store(cell, value)
 			scheduler.f.setFormulaValue(sheet, cellName, value)
 			scheduler.notifyDependents(cell)
@@ -297,14 +315,22 @@ func (scheduler *DAGScheduler) executeFormula(cell string) {
 	}
 
 	// 使用带子表达式缓存的计算
+	scheduler.cacheMisses.Add(1)
 	opts := Options{RawCellValue: true, MaxCalcIterations: 100}
 
+	start := time.Now()
 	value, err := scheduler.f.CalcCellValueWithSubExprCache(sheet, cellName, formula, scheduler.subExprCache, scheduler.worksheetCache, opts)
+	if profile := calcProfileFromContext(scheduler.ctx); profile != nil {
+		profile.addFormula(cell, formula, time.Since(start))
+	}
 
 	// CRITICAL: Even if err != nil, value may contain error string like "#DIV/0!"
 	// We should still store and write back error values so they display in Excel
 	if err != nil && value == "" {
 		// True error case: calculation failed without producing error value
+		if scheduler.ctx != nil {
+			reportCalcFailure(scheduler.ctx, cell, formula, value, err)
+		}
 		scheduler.notifyDependents(cell)
 		scheduler.markFormulaDone()
 		return
@@ -341,7 +367,7 @@ func (scheduler *DAGScheduler) notifyDependents(completedCell string) {
 			case scheduler.readyQueue <- dependent:
 			default:
 				// Queue full, this shouldn't happen with large buffer
-				log.Printf("⚠️ [DAG Scheduler] Ready queue full, dropping %s", dependent)
+				scheduler.f.logger().Infof("⚠️ [DAG Scheduler] Ready queue full, dropping %s", dependent)
 			}
 		}
 	}
@@ -376,8 +402,12 @@ func (scheduler *DAGScheduler) closeReadyQueue() {
 func (f *File) storeCalculatedValue(sheet, cellName, value string, worksheetCache *WorksheetCache) {
 	// Phase 1: 对于公式计算结果，应该根据返回值本身推断类型，而不是根据单元格类型
 	// 因为公式单元格的 cellType 始终是 CellTypeUnset
-	arg := inferFormulaResultType(value)
+	f.storeCalculatedArg(sheet, cellName, value, inferFormulaResultType(value), worksheetCache)
+}
 
+// storeCalculatedArg 与 storeCalculatedValue 相同，使用已推断类型的 arg，
+// 相同结果的多个单元格可共享同一个 arg
+func (f *File) storeCalculatedArg(sheet, cellName, value string, arg formulaArg, worksheetCache *WorksheetCache) {
 	// Phase 1: 存储 formulaArg 而不是字符串
 	if worksheetCache != nil {
 		worksheetCache.Set(sheet, cellName, arg)
@@ -420,11 +450,19 @@ func inferFormulaResultType(value string) formulaArg {
 }
 
 func (f *File) setFormulaValue(sheet, cellName, value string) {
+	// The shadow recalculation keeps the computed values apart from the cells
+	if shadow := f.shadow.Load(); shadow != nil {
+		if rowsCache := f.sheetDataCache.Load(); shadow.set(sheet, cellName, value) && rowsCache != nil {
+			rowsCache.Invalidate(sheet)
+		}
+		return
+	}
+
 	f.mu.Lock()
 	ws, err := f.workSheetReader(sheet)
 	f.mu.Unlock()
 	if err != nil {
-		log.Printf("  ⚠️  [setFormulaValue] workSheetReader failed for %s!%s: %v", sheet, cellName, err)
+		f.logger().Debugf("  ⚠️  [setFormulaValue] workSheetReader failed for %s!%s: %v", sheet, cellName, err)
 		return
 	}
 
@@ -432,7 +470,7 @@ func (f *File) setFormulaValue(sheet, cellName, value string) {
 	c, _, _, err := ws.prepareCell(cellName)
 	if err != nil {
 		ws.mu.Unlock()
-		log.Printf("  ⚠️  [setFormulaValue] prepareCell failed for %s!%s: %v", sheet, cellName, err)
+		f.logger().Debugf("  ⚠️  [setFormulaValue] prepareCell failed for %s!%s: %v", sheet, cellName, err)
 		return
 	}
 
@@ -441,6 +479,11 @@ func (f *File) setFormulaValue(sheet, cellName, value string) {
 	c.T = inferXMLCellType(value)
 	ws.mu.Unlock()
 
+	// The sheet changed, rows decoded earlier in this recalculation are stale
+	if rowsCache := f.sheetDataCache.Load(); rowsCache != nil && oldValue != value {
+		rowsCache.Invalidate(sheet)
+	}
+
 	if f.OnCellCalculated != nil && oldValue != value {
 		f.OnCellCalculated(sheet, cellName, oldValue, value)
 	}
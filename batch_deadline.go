@@ -0,0 +1,79 @@
+package excelize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RecalcDeadlineError is the error returned by RecalculateAllWithDeadline when
+// the deadline expires before all dependency levels are calculated. The
+// formulas of the completed levels have their new values, the formulas of the
+// level running at the deadline are partially calculated, the others keep
+// their previous values.
+type RecalcDeadlineError struct {
+	Deadline        time.Duration
+	CompletedLevels []int // indexes of the fully calculated levels, in order
+	TotalLevels     int   // number of levels, 0 if the graph wasn't built in time
+}
+
+// Error returns the error message of the expired deadline.
+func (e *RecalcDeadlineError) Error() string {
+	if e.TotalLevels == 0 {
+		return fmt.Sprintf("recalculation deadline %v exceeded while building the dependency graph", e.Deadline)
+	}
+	return fmt.Sprintf("recalculation deadline %v exceeded: completed %d of %d levels", e.Deadline, len(e.CompletedLevels), e.TotalLevels)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *RecalcDeadlineError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// calcProgressKey is the context key of the calcProgress of a recalculation.
+type calcProgressKey struct{}
+
+// calcProgress records the completed levels of a dependency recalculation, it
+// is written by the goroutine running the levels only.
+type calcProgress struct {
+	completedLevels []int
+	totalLevels     int
+}
+
+// calcProgressFromContext returns the calcProgress carried by ctx, or nil.
+func calcProgressFromContext(ctx context.Context) *calcProgress {
+	progress, _ := ctx.Value(calcProgressKey{}).(*calcProgress)
+	return progress
+}
+
+// RecalculateAllWithDeadline recalculates all formulas like
+// RecalculateAllWithDependency, but gives up once the total wall-clock time
+// exceeds d, for the best effort calculations within a time limit. It returns
+// a *RecalcDeadlineError listing the completed levels if the deadline expires,
+// which matches context.DeadlineExceeded with errors.Is. A formula being
+// calculated at the deadline isn't interrupted, so the call returns after the
+// running formulas complete. For example:
+//
+//	err := f.RecalculateAllWithDeadline(5 * time.Second)
+//	var deadlineErr *excelize.RecalcDeadlineError
+//	if errors.As(err, &deadlineErr) {
+//	    fmt.Printf("completed %d of %d levels\n", len(deadlineErr.CompletedLevels), deadlineErr.TotalLevels)
+//	}
+func (f *File) RecalculateAllWithDeadline(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return f.recalculateAllWithDeadline(ctx, d)
+}
+
+// recalculateAllWithDeadline recalculates all formulas until ctx expires with
+// the deadline d, and returns a *RecalcDeadlineError listing the completed
+// levels when it does.
+func (f *File) recalculateAllWithDeadline(ctx context.Context, d time.Duration) error {
+	progress := &calcProgress{}
+	err := f.RecalculateAllWithDependencyContext(context.WithValue(ctx, calcProgressKey{}, progress))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &RecalcDeadlineError{Deadline: d, CompletedLevels: progress.completedLevels, TotalLevels: progress.totalLevels}
+	}
+	return err
+}
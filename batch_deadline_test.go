@@ -0,0 +1,111 @@
+package excelize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expiringContext is a context whose deadline expires when expire is called,
+// so the tests stop a recalculation at a given formula regardless of the
+// speed of the machine.
+type expiringContext struct {
+	context.Context
+	done chan struct{}
+	once sync.Once
+}
+
+func newExpiringContext() *expiringContext {
+	return &expiringContext{Context: context.Background(), done: make(chan struct{})}
+}
+
+func (c *expiringContext) Done() <-chan struct{} { return c.done }
+
+func (c *expiringContext) Err() error {
+	select {
+	case <-c.done:
+		return context.DeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+func (c *expiringContext) expire() { c.once.Do(func() { close(c.done) }) }
+
+func TestRecalculateAllWithDeadline(t *testing.T) {
+	const rows = 20
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	// 每一行一个级别，计算 A3 时期限到期
+	for r := 2; r <= rows; r++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("A%d", r), fmt.Sprintf("A%d+1", r-1)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", r), fmt.Sprintf("SUM(A$1:A%d)", r)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	ctx := newExpiringContext()
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) {
+		if cell == "A3" {
+			ctx.expire()
+		}
+	}
+
+	const deadline = time.Second
+	err := f.recalculateAllWithDeadline(ctx, deadline)
+	var deadlineErr *RecalcDeadlineError
+	if !errors.As(err, &deadlineErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a *RecalcDeadlineError, got %v", err)
+	}
+	if deadlineErr.Deadline != deadline || len(deadlineErr.CompletedLevels) == 0 ||
+		len(deadlineErr.CompletedLevels) >= deadlineErr.TotalLevels {
+		t.Fatalf("expected a partial recalculation, got %+v", deadlineErr)
+	}
+	for i, level := range deadlineErr.CompletedLevels {
+		if level != i {
+			t.Fatalf("expected the completed levels in order, got %v", deadlineErr.CompletedLevels)
+		}
+	}
+	if value, _ := f.GetCellValue("Sheet1", "A2"); value != "2" {
+		t.Fatalf("expected the first level to be calculated, got A2=%q", value)
+	}
+	if value, _ := f.GetCellValue("Sheet1", fmt.Sprintf("A%d", rows)); value != "" {
+		t.Fatalf("expected the last level to stay uncalculated, got %q", value)
+	}
+
+	// 开始前已到期的期限不计算任何公式
+	f.OnCellCalculated = nil
+	if err := f.SetCellValue("Sheet1", "A1", 5); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	err = f.RecalculateAllWithDeadline(-time.Second)
+	if !errors.As(err, &deadlineErr) || len(deadlineErr.CompletedLevels) != 0 {
+		t.Fatalf("expected no completed levels with an expired deadline, got %v", err)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "A2"); value != "2" {
+		t.Fatalf("expected A2 to keep its value, got %q", value)
+	}
+
+	// 期限内完成时与 RecalculateAllWithDependency 一致
+	f2 := NewFile()
+	t.Cleanup(func() { _ = f2.Close() })
+	if err := f2.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f2.SetCellFormula("Sheet1", "B1", "A1*3"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f2.RecalculateAllWithDeadline(time.Minute); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if value, _ := f2.GetCellValue("Sheet1", "B1"); value != "6" {
+		t.Fatalf("expected B1=6, got %q", value)
+	}
+}
@@ -0,0 +1,106 @@
+package excelize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxDefinedNameDepth is the maximum number of the nested defined names
+// expanded in a formula.
+const maxDefinedNameDepth = 8
+
+// definedNameRefs maps the defined names of the workbook to their references
+// by scope, which is a sheet name or "Workbook", for the dependency extraction
+// which only sees the references in the formula text.
+type definedNameRefs map[string]map[string]string
+
+// definedNameRefs returns the references of the defined names of the
+// workbook, or nil if the workbook has none.
+func (f *File) definedNameRefs() definedNameRefs {
+	var refs definedNameRefs
+	for _, definedName := range f.GetDefinedName() {
+		refTo := strings.TrimPrefix(definedName.RefersTo, "=")
+		if refTo == "" {
+			continue
+		}
+		if refs == nil {
+			refs = make(definedNameRefs)
+		}
+		if refs[definedName.Name] == nil {
+			refs[definedName.Name] = make(map[string]string)
+		}
+		refs[definedName.Name][definedName.Scope] = refTo
+	}
+	return refs
+}
+
+// refTo returns the reference of a defined name used on the sheet, the
+// worksheet scope takes precedence over the workbook scope like
+// getDefinedNameRefTo.
+func (refs definedNameRefs) refTo(name, sheet string) string {
+	scopes := refs[name]
+	if refTo, ok := scopes[sheet]; ok {
+		return refTo
+	}
+	return scopes["Workbook"]
+}
+
+// expand replaces the defined names of a formula on the sheet with their
+// references, e.g. SUM(SalesCol) becomes SUM(Data!$C:$C) if the name SalesCol
+// refers to Data!$C:$C, so the dependencies extracted from the formula include
+// the referenced cells and columns. The string literals, quoted sheet names,
+// function names and the sheet names and cells of the references are kept.
+// The names referring to other names are expanded up to
+// maxDefinedNameDepth levels, which also stops the names referring to each
+// other.
+func (refs definedNameRefs) expand(formula, sheet string) string {
+	return refs.expandDepth(formula, sheet, 0)
+}
+
+// expandDepth expands the defined names of a formula like expand, depth is
+// the number of the names being expanded around the formula.
+func (refs definedNameRefs) expandDepth(formula, sheet string, depth int) string {
+	if len(refs) == 0 || depth >= maxDefinedNameDepth {
+		return formula
+	}
+	var expanded strings.Builder
+	runes := []rune(formula)
+	last := 0
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case r == '"' || r == '\'':
+			// 跳过字符串和带引号的工作表名称，连续两个引号表示转义
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+		case unicode.IsLetter(r) || r == '_' || r == '\\':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_.\\", runes[i])) {
+				i++
+			}
+			if start > 0 && (runes[start-1] == '!' || runes[start-1] == '$') ||
+				i < len(runes) && strings.ContainsRune("(!", runes[i]) {
+				continue
+			}
+			if refTo := refs.refTo(string(runes[start:i]), sheet); refTo != "" {
+				expanded.WriteString(string(runes[last:start]))
+				expanded.WriteString(refs.expandDepth(refTo, sheet, depth+1))
+				last = i
+			}
+		default:
+			i++
+		}
+	}
+	if last == 0 {
+		return formula
+	}
+	expanded.WriteString(string(runes[last:]))
+	return expanded.String()
+}
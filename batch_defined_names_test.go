@@ -0,0 +1,98 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestDefinedNameRefsExpand(t *testing.T) {
+	names := definedNameRefs{
+		"SalesCol": {"Workbook": "Data!$C:$C"},
+		"Rate":     {"Workbook": "Data!$F$1", "Sheet2": "Sheet2!$B$1"},
+		"税率":       {"Workbook": "'My Data'!$A$1"},
+	}
+	for _, c := range []struct{ formula, sheet, want string }{
+		{"SUM(SalesCol)", "Sheet1", "SUM(Data!$C:$C)"},
+		{"SalesCol", "Sheet1", "Data!$C:$C"},
+		{"SUM(SalesCol)*Rate", "Sheet1", "SUM(Data!$C:$C)*Data!$F$1"},
+		{"SUM(SalesCol)*Rate", "Sheet2", "SUM(Data!$C:$C)*Sheet2!$B$1"},
+		{"A1*税率", "Sheet1", "A1*'My Data'!$A$1"},
+		{`IF(A1="SalesCol",SalesCol2,'SalesCol'!A1)`, "Sheet1", `IF(A1="SalesCol",SalesCol2,'SalesCol'!A1)`},
+		{`CONCAT("say ""Rate""",Rate)`, "Sheet1", `CONCAT("say ""Rate""",Data!$F$1)`},
+		{"SalesCol!A1+Rate(1)", "Sheet1", "SalesCol!A1+Rate(1)"},
+		{"SUM(A1:B2)", "Sheet1", "SUM(A1:B2)"},
+	} {
+		if got := names.expand(c.formula, c.sheet); got != c.want {
+			t.Fatalf("expand(%q, %q) = %q, want %q", c.formula, c.sheet, got, c.want)
+		}
+	}
+	// 引用其他名称的名称递归展开，相互引用的名称在达到深度限制后停止
+	nested := definedNameRefs{
+		"Total":    {"Workbook": "SUM(Sales)"},
+		"Sales":    {"Workbook": "SalesCol"},
+		"SalesCol": {"Workbook": "Data!$C:$C"},
+		"Ping":     {"Workbook": "Pong"},
+		"Pong":     {"Workbook": "Ping"},
+	}
+	if got := nested.expand("Total*2", "Sheet1"); got != "SUM(Data!$C:$C)*2" {
+		t.Fatalf("unexpected expanded formula %q with nested defined names", got)
+	}
+	if got := nested.expand("Ping", "Sheet1"); got != "Ping" {
+		t.Fatalf("unexpected expanded formula %q with cyclic defined names", got)
+	}
+	if got := definedNameRefs(nil).expand("SUM(SalesCol)", "Sheet1"); got != "SUM(SalesCol)" {
+		t.Fatalf("unexpected expanded formula %q without defined names", got)
+	}
+}
+
+func TestDefinedNameColumnDependency(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 10; row++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("C%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	if err := f.SetDefinedName(&DefinedName{Name: "SalesCol", RefersTo: "Data!$C:$C"}); err != nil {
+		t.Fatalf("set defined name: %v", err)
+	}
+	for cell, formula := range map[string]string{"A1": "SUM(SalesCol)", "A2": "A1*2"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	graph := f.buildDependencyGraph()
+	assertValidDependencyGraph(t, graph)
+	if deps := graph.nodes["Sheet1!A1"].dependencies; len(deps) != 1 || deps[0] != "COLUMN:Data!C" {
+		t.Fatalf("unexpected dependencies %v of the formula using the defined name", deps)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "A2"); value != "110" {
+		t.Fatalf("unexpected A2 value %q, want 110", value)
+	}
+
+	// 修改名称引用的列中的单元格，增量重算使用名称的公式及其依赖
+	if err := f.SetCellValue("Data", "C5", 105); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Data!C5": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	for cell, want := range map[string]string{"A1": "155", "A2": "310"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %s", cell, value, want)
+		}
+	}
+}
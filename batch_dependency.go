@@ -1,10 +1,12 @@
 package excelize
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +21,8 @@ type formulaNode struct {
 	formula      string   // The formula content
 	dependencies []string // List of cells this formula depends on
 	level        int      // Dependency level (0 = no dependencies, 1 = depends on level 0, etc.)
+	cost         int64    // Estimated cost: number of referenced cells plus one (0 = not estimated yet)
+	constant     bool     // Whether the formula references nothing, like ="N/A", and is calculated once per distinct formula
 }
 
 // columnMeta stores metadata about a column to avoid unnecessary dependency expansion
@@ -33,26 +37,179 @@ type dependencyGraph struct {
 	nodes          map[string]*formulaNode // cell -> node
 	levels         [][]string              // level -> list of cells at that level
 	columnMetadata map[string]*columnMeta  // "Sheet!Col" -> metadata for smart dependency resolution
+	calcLogger     Logger                  // Logger of the workbook, no-op if nil
+	// maxMergeCostRatio is CalcTuning.MaxMergeCostRatio of the workbook
+	maxMergeCostRatio float64
+	// calcWorkers is the concurrency set by SetCalcConcurrency of the
+	// workbook, runtime.NumCPU() if 0
+	calcWorkers int
+	// unmergedLevels is the number of levels before mergeLevels, 0 if the
+	// levels weren't merged
+	unmergedLevels int
+}
+
+// logger returns the logger of the workbook the graph was built for.
+func (g *dependencyGraph) logger() Logger {
+	if g.calcLogger == nil {
+		return nopLogger{}
+	}
+	return g.calcLogger
+}
+
+// pendingFormula is a formula collected by the graph build whose
+// dependencies are not extracted yet
+type pendingFormula struct {
+	fullCell string
+	sheet    string
+	cellRef  string
+	formula  string
+}
+
+// extractDependenciesChunkSize is the number of formulas a worker extracts
+// before it takes the next chunk
+const extractDependenciesChunkSize = 1024
+
+// extractDependenciesParallel extracts the dependencies of the formulas with a
+// pool of workers. The workers take chunks of the formulas in turn and each
+// writes into its own part of the result, so no locking is needed: the column
+// index and metadata are read-only at this point. The result is indexed like
+// formulas, the entries are left nil once ctx is canceled.
+func (f *File) extractDependenciesParallel(ctx context.Context, formulas []pendingFormula, columnIndex map[string][]string, columnMetadata map[string]*columnMeta, numWorkers int) [][]string {
+	deps := make([][]string, len(formulas))
+	names := f.definedNameRefs()
+	numChunks := (len(formulas) + extractDependenciesChunkSize - 1) / extractDependenciesChunkSize
+	numWorkers = max(min(numWorkers, numChunks), 1)
+
+	var nextChunk, processed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				chunk := int(nextChunk.Add(1)) - 1
+				if chunk >= numChunks || ctx.Err() != nil {
+					return // 已取消：不再提取依赖
+				}
+				start := chunk * extractDependenciesChunkSize
+				end := min(start+extractDependenciesChunkSize, len(formulas))
+				for j := start; j < end; j++ {
+					info := formulas[j]
+					deps[j] = extractDependenciesOptimized(names.expand(info.formula, info.sheet), info.sheet, info.cellRef, columnIndex, columnMetadata)
+				}
+				// Progress logging
+				if done := processed.Add(int64(end - start)); done/500000 != (done-int64(end-start))/500000 {
+					f.logger().Debugf("    📊 [Dependency Extraction] Processed %d/%d formulas...", done, len(formulas))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return deps
 }
 
 // buildDependencyGraph analyzes all formulas and builds a dependency graph
 // Optimized: Uses column metadata to avoid expanding column ranges to individual cells
 func (f *File) buildDependencyGraph() *dependencyGraph {
+	graph, _ := f.buildDependencyGraphWithContext(context.Background())
+	return graph
+}
+
+// buildDependencyGraphWithContext builds the dependency graph like
+// buildDependencyGraph, the formula collection and the dependency extraction
+// stop early and return the context error once ctx is canceled.
+func (f *File) buildDependencyGraphWithContext(ctx context.Context) (*dependencyGraph, error) {
 	startTime := time.Now()
+	ctx, span := f.tracer().StartSpan(ctx, "excelize.build_dependency_graph")
+	defer span.End()
 
 	graph := &dependencyGraph{
-		nodes:          make(map[string]*formulaNode),
-		columnMetadata: make(map[string]*columnMeta),
+		nodes:             make(map[string]*formulaNode),
+		columnMetadata:    make(map[string]*columnMeta),
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
 	}
 
 	// Step 1: First pass - collect all formulas and build column metadata simultaneously
+	formulasToProcess, err := f.collectFormulaNodes(ctx, graph)
+	if err != nil {
+		return graph, err
+	}
+
+	// Count columns with formulas vs pure data
+	formulaCols, dataCols := 0, 0
+	for _, meta := range graph.columnMetadata {
+		if meta.hasFormulas {
+			formulaCols++
+		} else {
+			dataCols++
+		}
+	}
+
+	f.logger().Debugf("  📊 [Dependency Analysis] Collected %d formulas, %d columns (%d with formulas, %d pure data)",
+		len(graph.nodes), len(graph.columnMetadata), formulaCols, dataCols)
+
+	// Step 2: Build column index for efficient column range expansion (only formula columns matter)
+	columnIndex := graph.formulaColumnIndex()
+
+	f.logger().Debugf("  📊 [Dependency Analysis] Built column index: %d columns with formulas", len(columnIndex))
+
+	// Step 3: Extract dependencies with smart column resolution (PARALLELIZED)
+	f.logger().Debugf("  📊 [Dependency Analysis] Extracting dependencies for %d formulas (parallel)...", len(formulasToProcess))
+	extractStart := time.Now()
+
+	// Use worker pool for parallel dependency extraction
+	numWorkers := f.calcWorkers()
+	if numWorkers > 16 {
+		numWorkers = 16 // Cap at 16 workers
+	}
+	allDeps := f.extractDependenciesParallel(ctx, formulasToProcess, columnIndex, graph.columnMetadata, numWorkers)
+	for i, info := range formulasToProcess {
+		graph.nodes[info.fullCell].dependencies = allDeps[i]
+	}
+
+	f.logger().Debugf("  📊 [Dependency Analysis] Extracted dependencies in %v (parallel with %d workers)", time.Since(extractStart), numWorkers)
+	if err := ctx.Err(); err != nil {
+		return graph, err
+	}
+
+	// Step 4: Assign levels using topological sort
+	graph.assignLevels()
+
+	duration := time.Since(startTime)
+	f.logger().Debugf("  ✅ [Dependency Analysis] Completed in %v", duration)
+	f.logger().Debugf("  📈 [Dependency Analysis] Dependency levels: %d levels", len(graph.levels))
+	for i, cells := range graph.levels {
+		f.logger().Debugf("      Level %d: %d formulas", i, len(cells))
+	}
+	f.levelHistogram.Store(graph.levelHistogram())
+	span.SetAttribute("formulas", len(graph.nodes))
+	span.SetAttribute("levels", len(graph.levels))
+
+	return graph, nil
+}
+
+// resolvedFormula returns the formula of a cell, the shared formula of the
+// master cell is translated for the slave cells which only store the shared
+// index. The dependency scans resolve the formulas with it, so the full graph,
+// the sheet graph and the incremental index see the same formulas.
+func resolvedFormula(ws *xlsxWorksheet, cell *xlsxC) string {
+	if cell.F == nil {
+		return ""
+	}
+	formula := cell.F.Content
+	if formula == "" && cell.F.T == STCellFormulaTypeShared && cell.F.Si != nil {
+		formula, _ = getSharedFormula(ws, *cell.F.Si, cell.R)
+	}
+	return formula
+}
+
+// collectFormulaNodes collects the formula cells of all worksheets into the
+// nodes of the graph without dependencies, and builds the column metadata.
+func (f *File) collectFormulaNodes(ctx context.Context, graph *dependencyGraph) ([]pendingFormula, error) {
 	sheetList := f.GetSheetList()
-	formulasToProcess := make([]struct {
-		fullCell string
-		sheet    string
-		cellRef  string
-		formula  string
-	}, 0)
+	formulasToProcess := make([]pendingFormula, 0)
 
 	for _, sheet := range sheetList {
 		ws, err := f.workSheetReader(sheet)
@@ -60,7 +217,10 @@ func (f *File) buildDependencyGraph() *dependencyGraph {
 			continue
 		}
 
-		for _, row := range ws.SheetData.Row {
+		for rowIdx, row := range ws.SheetData.Row {
+			if rowIdx%cancelCheckInterval == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			for _, cell := range row.C {
 				// Extract column and row info for metadata
 				col, rowNum, err := CellNameToCoordinates(cell.R)
@@ -86,20 +246,9 @@ func (f *File) buildDependencyGraph() *dependencyGraph {
 				}
 
 				if cell.F != nil {
-					formula := cell.F.Content
-					// Handle shared formulas
-					if formula == "" && cell.F.T == STCellFormulaTypeShared && cell.F.Si != nil {
-						formula, _ = getSharedFormula(ws, *cell.F.Si, cell.R)
-					}
-
-					if formula != "" {
+					if formula := resolvedFormula(ws, &cell); formula != "" {
 						fullCell := sheet + "!" + cell.R
-						formulasToProcess = append(formulasToProcess, struct {
-							fullCell string
-							sheet    string
-							cellRef  string
-							formula  string
-						}{fullCell, sheet, cell.R, formula})
+						formulasToProcess = append(formulasToProcess, pendingFormula{fullCell, sheet, cell.R, formula})
 
 						// Create node without dependencies yet
 						graph.nodes[fullCell] = &formulaNode{
@@ -120,23 +269,14 @@ func (f *File) buildDependencyGraph() *dependencyGraph {
 			}
 		}
 	}
+	return formulasToProcess, nil
+}
 
-	// Count columns with formulas vs pure data
-	formulaCols, dataCols := 0, 0
-	for _, meta := range graph.columnMetadata {
-		if meta.hasFormulas {
-			formulaCols++
-		} else {
-			dataCols++
-		}
-	}
-
-	log.Printf("  📊 [Dependency Analysis] Collected %d formulas, %d columns (%d with formulas, %d pure data)",
-		len(graph.nodes), len(graph.columnMetadata), formulaCols, dataCols)
-
-	// Step 2: Build column index for efficient column range expansion (only formula columns matter)
+// formulaColumnIndex indexes the formula cells of the graph by "Sheet!Col"
+// for the expansion of column ranges.
+func (g *dependencyGraph) formulaColumnIndex() map[string][]string {
 	columnIndex := make(map[string][]string)
-	for cellRef := range graph.nodes {
+	for cellRef := range g.nodes {
 		parts := strings.Split(cellRef, "!")
 		if len(parts) == 2 {
 			sheetName := parts[0]
@@ -158,260 +298,210 @@ func (f *File) buildDependencyGraph() *dependencyGraph {
 			}
 		}
 	}
+	return columnIndex
+}
 
-	log.Printf("  📊 [Dependency Analysis] Built column index: %d columns with formulas", len(columnIndex))
-
-	// Step 3: Extract dependencies with smart column resolution (PARALLELIZED)
-	log.Printf("  📊 [Dependency Analysis] Extracting dependencies for %d formulas (parallel)...", len(formulasToProcess))
-	extractStart := time.Now()
-
-	// Use worker pool for parallel dependency extraction
-	numWorkers := runtime.NumCPU()
-	if numWorkers > 16 {
-		numWorkers = 16 // Cap at 16 workers
+// levelHistogram returns the number of formulas at each dependency level.
+func (g *dependencyGraph) levelHistogram() *[]int {
+	histogram := make([]int, len(g.levels))
+	for i, cells := range g.levels {
+		histogram[i] = len(cells)
 	}
+	return &histogram
+}
+
+// LevelHistogram returns the number of formulas per dependency level of the
+// last dependency graph built by a full workbook or sheet recalculation. Wide
+// and shallow histograms parallelize well, deep and narrow ones are mostly
+// calculated level by level. For example:
+//
+//	if err := f.RecalculateAllWithDependency(); err != nil {
+//	    fmt.Println(err)
+//	}
+//	histogram, err := f.LevelHistogram()
+func (f *File) LevelHistogram() ([]int, error) {
+	histogram := f.levelHistogram.Load()
+	if histogram == nil {
+		return nil, ErrDependencyGraphNotBuilt
+	}
+	return append([]int(nil), (*histogram)...), nil
+}
 
-	type depResult struct {
-		fullCell string
-		deps     []string
+// minInt returns the minimum of two integers
+func minInt(a, b int) int {
+	if a < b {
+		return a
 	}
+	return b
+}
 
-	// Channel for work distribution
-	workChan := make(chan struct {
-		fullCell string
-		sheet    string
-		cellRef  string
-		formula  string
-	}, len(formulasToProcess))
+// parallelLevelChunk is the minimum number of the nodes processed by a worker
+// of the level assignment, smaller inputs are processed in the calling
+// goroutine.
+const parallelLevelChunk = 2048
 
-	// Channel for results
-	resultChan := make(chan depResult, len(formulasToProcess))
+// workers returns the number of the workers of the level assignment.
+func (g *dependencyGraph) workers() int {
+	if g.calcWorkers > 0 {
+		return g.calcWorkers
+	}
+	return runtime.NumCPU()
+}
 
-	// Start workers
+// collectParallel splits n nodes into chunks processed by the workers of the
+// graph, and returns the nodes collected by fn for each chunk concatenated in
+// the order of the chunks.
+func (g *dependencyGraph) collectParallel(n int, fn func(start, end int) []int32) []int32 {
+	chunks := min(g.workers(), (n+parallelLevelChunk-1)/parallelLevelChunk)
+	if chunks <= 1 {
+		return fn(0, n)
+	}
+	chunkSize := (n + chunks - 1) / chunks
+	results := make([][]int32, chunks)
 	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
+	for id := 0; id < chunks; id++ {
 		wg.Add(1)
-		go func() {
+		go func(id int) {
 			defer wg.Done()
-			for info := range workChan {
-				deps := extractDependenciesOptimized(info.formula, info.sheet, info.cellRef, columnIndex, graph.columnMetadata)
-				resultChan <- depResult{fullCell: info.fullCell, deps: deps}
-			}
-		}()
-	}
-
-	// Send work to workers
-	go func() {
-		for _, info := range formulasToProcess {
-			workChan <- info
-		}
-		close(workChan)
-	}()
-
-	// Wait for all workers to finish, then close result channel
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	// Collect results
-	processedCount := 0
-	for result := range resultChan {
-		graph.nodes[result.fullCell].dependencies = result.deps
-		processedCount++
-
-		// Progress logging
-		if processedCount%500000 == 0 {
-			log.Printf("    📊 [Dependency Extraction] Processed %d/%d formulas...", processedCount, len(formulasToProcess))
-		}
-	}
-
-	log.Printf("  📊 [Dependency Analysis] Extracted dependencies in %v (parallel with %d workers)", time.Since(extractStart), numWorkers)
-
-	// Step 4: Assign levels using topological sort
-	graph.assignLevels()
-
-	duration := time.Since(startTime)
-	log.Printf("  ✅ [Dependency Analysis] Completed in %v", duration)
-	log.Printf("  📈 [Dependency Analysis] Dependency levels: %d levels", len(graph.levels))
-	for i, cells := range graph.levels {
-		log.Printf("      Level %d: %d formulas", i, len(cells))
+			start := id * chunkSize
+			results[id] = fn(start, min(start+chunkSize, n))
+		}(id)
 	}
-
-	return graph
-}
-
-// minInt returns the minimum of two integers
-func minInt(a, b int) int {
-	if a < b {
-		return a
+	wg.Wait()
+	var collected []int32
+	for _, result := range results {
+		collected = append(collected, result...)
 	}
-	return b
+	return collected
 }
 
 // assignLevels assigns each node a level based on its dependencies
-// Optimized: Uses BFS-based topological sort with reverse dependency index for O(n) complexity
+// Optimized: Uses BFS-based topological sort with reverse dependency index for O(n) complexity.
+// The nodes are numbered, so the scans of the nodes and the assignment of each
+// level run in parallel over the chunks of the nodes with atomic counters of the
+// unresolved dependencies.
 func (g *dependencyGraph) assignLevels() {
 	startTime := time.Now()
-	log.Printf("  📊 [Level Assignment] Starting parallel level assignment for %d nodes...", len(g.nodes))
-
-	// Step 1: Build column membership map and reverse dependency index
-	cellToColumn := make(map[string]string)        // cell -> column key
-	columnMaxLevel := make(map[string]int)         // column -> max level
-	columnUnresolvedCount := make(map[string]int)  // column -> count of unresolved cells
-	reverseDeps := make(map[string][]string)       // dependency -> list of cells that depend on it
-	reverseColumnDeps := make(map[string][]string) // column -> list of cells that have COLUMN: dependency on it
+	g.logger().Debugf("  📊 [Level Assignment] Starting parallel level assignment for %d nodes...", len(g.nodes))
+	g.markConstantFormulas()
 
-	// Pre-compute column keys and count cells per column
+	// Step 1: Number the nodes and their columns
+	cells := make([]string, 0, len(g.nodes))
 	for cellRef := range g.nodes {
-		parts := strings.Split(cellRef, "!")
-		if len(parts) == 2 {
-			col := ""
-			for _, ch := range parts[1] {
-				if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') {
-					col += string(ch)
-				} else {
-					break
-				}
-			}
-			colKey := parts[0] + "!" + col
-			cellToColumn[cellRef] = colKey
-			columnMaxLevel[colKey] = -1
-			columnUnresolvedCount[colKey]++ // Count cells per column
-		}
+		cells = append(cells, cellRef)
 	}
-
-	// Build reverse dependency index
-	for cell, node := range g.nodes {
-		for _, dep := range node.dependencies {
-			if strings.HasPrefix(dep, "COLUMN:") {
-				colKey := strings.TrimPrefix(dep, "COLUMN:")
-				reverseColumnDeps[colKey] = append(reverseColumnDeps[colKey], cell)
-			} else {
-				reverseDeps[dep] = append(reverseDeps[dep], cell)
-			}
-		}
+	nodes := make([]*formulaNode, len(cells))
+	nodeIndex := make(map[string]int32, len(cells)) // cell -> node number
+	for i, cellRef := range cells {
+		nodes[i] = g.nodes[cellRef]
+		nodeIndex[cellRef] = int32(i)
 	}
-
-	log.Printf("    📊 [Level Assignment] Built reverse index in %v", time.Since(startTime))
-
-	// Step 2: Calculate unresolved dependency count for each node
-	unresolvedCount := make(map[string]int)               // cell -> number of unresolved dependencies
-	unresolvedColDeps := make(map[string]map[string]bool) // cell -> set of unresolved column dependencies
-
-	for cell, node := range g.nodes {
-		count := 0
-		colDeps := make(map[string]bool)
-
-		for _, dep := range node.dependencies {
-			if strings.HasPrefix(dep, "COLUMN:") {
-				colKey := strings.TrimPrefix(dep, "COLUMN:")
-				// Check if this column has any formula cells
-				if _, hasFormulas := columnMaxLevel[colKey]; hasFormulas {
-					colDeps[colKey] = true
-					count++
-				}
-			} else {
-				// Regular cell dependency
-				if _, isFormula := g.nodes[dep]; isFormula {
-					count++
+	colKeys := make([]string, len(cells))
+	g.collectParallel(len(cells), func(start, end int) []int32 {
+		for i := start; i < end; i++ {
+			colKeys[i] = cellColumnKey(cells[i])
+		}
+		return nil
+	})
+	columnIndex := make(map[string]int32) // "Sheet!Col" -> column number
+	cellColumn := make([]int32, len(cells))
+	var columnUnresolved []int32 // column -> count of unresolved cells
+	for i, colKey := range colKeys {
+		cellColumn[i] = -1
+		if colKey == "" {
+			continue
+		}
+		col, ok := columnIndex[colKey]
+		if !ok {
+			col = int32(len(columnUnresolved))
+			columnIndex[colKey] = col
+			columnUnresolved = append(columnUnresolved, 0)
+		}
+		cellColumn[i] = col
+		columnUnresolved[col]++ // Count cells per column
+	}
+
+	// Step 2: Resolve the dependencies on the formula cells and the columns
+	// with formula cells, and count the unresolved dependencies of each node
+	formulaDeps := make([][]int32, len(cells))
+	columnDeps := make([][]int32, len(cells))
+	unresolvedCount := make([]int32, len(cells))
+	g.collectParallel(len(cells), func(start, end int) []int32 {
+		for i := start; i < end; i++ {
+			for _, dep := range nodes[i].dependencies {
+				if colKey, ok := strings.CutPrefix(dep, "COLUMN:"); ok {
+					// 同一列只计数一次
+					if col, hasFormulas := columnIndex[colKey]; hasFormulas && !slices.Contains(columnDeps[i], col) {
+						columnDeps[i] = append(columnDeps[i], col)
+					}
+				} else if depIdx, isFormula := nodeIndex[dep]; isFormula {
+					formulaDeps[i] = append(formulaDeps[i], depIdx)
 				}
 			}
+			unresolvedCount[i] = int32(len(formulaDeps[i]) + len(columnDeps[i]))
 		}
+		return nil
+	})
 
-		unresolvedCount[cell] = count
-		if len(colDeps) > 0 {
-			unresolvedColDeps[cell] = colDeps
+	// Build reverse dependency index
+	reverseDeps := make([][]int32, len(cells))                  // dependency -> cells that depend on it
+	reverseColumnDeps := make([][]int32, len(columnUnresolved)) // column -> cells that have COLUMN: dependency on it
+	for i := range cells {
+		for _, dep := range formulaDeps[i] {
+			reverseDeps[dep] = append(reverseDeps[dep], int32(i))
+		}
+		for _, col := range columnDeps[i] {
+			reverseColumnDeps[col] = append(reverseColumnDeps[col], int32(i))
 		}
 	}
 
-	log.Printf("    📊 [Level Assignment] Calculated unresolved counts in %v", time.Since(startTime))
+	g.logger().Debugf("    📊 [Level Assignment] Built reverse index and unresolved counts in %v", time.Since(startTime))
 
 	// Step 3: BFS-based level assignment
-	currentLevel := make([]string, 0)
-	for cell := range g.nodes {
-		if unresolvedCount[cell] == 0 {
-			currentLevel = append(currentLevel, cell)
+	currentLevel := g.collectParallel(len(cells), func(start, end int) []int32 {
+		var candidates []int32
+		for i := start; i < end; i++ {
+			if unresolvedCount[i] == 0 {
+				candidates = append(candidates, int32(i))
+			}
 		}
-	}
+		return candidates
+	})
 
 	level := 0
 	processedCount := 0
 
 	for len(currentLevel) > 0 {
-		// Assign level to all nodes in current batch
-		for _, cell := range currentLevel {
-			g.nodes[cell].level = level
-
-			// Update column tracking
-			if colKey := cellToColumn[cell]; colKey != "" {
-				if level > columnMaxLevel[colKey] {
-					columnMaxLevel[colKey] = level
-				}
-				columnUnresolvedCount[colKey]-- // Decrement unresolved count for this column
-			}
-		}
-
-		g.levels = append(g.levels, currentLevel)
-		processedCount += len(currentLevel)
-
-		if level%20 == 0 || len(currentLevel) > 100000 {
-			log.Printf("    📊 [Level Assignment] Level %d: %d nodes (total: %d/%d)",
-				level, len(currentLevel), processedCount, len(g.nodes))
-		}
-
-		// Find next level candidates
-		nextLevel := make([]string, 0)
-		nextLevelSet := make(map[string]bool)
-
-		// Process direct cell dependencies
-		for _, resolvedCell := range currentLevel {
-			for _, dependent := range reverseDeps[resolvedCell] {
-				if g.nodes[dependent].level != -1 {
-					continue
-				}
-				unresolvedCount[dependent]--
-				if unresolvedCount[dependent] == 0 && !nextLevelSet[dependent] {
-					nextLevel = append(nextLevel, dependent)
-					nextLevelSet[dependent] = true
+		// 先收集完整的当前层，再赋值层级并找出下一层：每个依赖计数只会有一个 worker 减到 0
+		levelCells := make([]string, len(currentLevel))
+		nextLevel := g.collectParallel(len(currentLevel), func(start, end int) []int32 {
+			var candidates []int32
+			resolve := func(dependents []int32) {
+				for _, dependent := range dependents {
+					if atomic.AddInt32(&unresolvedCount[dependent], -1) == 0 {
+						candidates = append(candidates, dependent)
+					}
 				}
 			}
-		}
-
-		// Check which columns became fully resolved (using counter instead of full scan)
-		columnsNowResolved := make([]string, 0)
-		for _, cell := range currentLevel {
-			if colKey := cellToColumn[cell]; colKey != "" {
-				if columnUnresolvedCount[colKey] == 0 {
-					// This column just became fully resolved
-					columnsNowResolved = append(columnsNowResolved, colKey)
+			for j := start; j < end; j++ {
+				i := currentLevel[j]
+				levelCells[j] = cells[i]
+				nodes[i].level = level
+				resolve(reverseDeps[i])
+				// The column is fully resolved with its last cell
+				if col := cellColumn[i]; col != -1 && atomic.AddInt32(&columnUnresolved[col], -1) == 0 {
+					resolve(reverseColumnDeps[col])
 				}
 			}
-		}
+			return candidates
+		})
 
-		// Deduplicate and notify dependents
-		seenCols := make(map[string]bool)
-		for _, colKey := range columnsNowResolved {
-			if seenCols[colKey] {
-				continue
-			}
-			seenCols[colKey] = true
+		g.levels = append(g.levels, levelCells)
+		processedCount += len(levelCells)
 
-			for _, dependent := range reverseColumnDeps[colKey] {
-				if g.nodes[dependent].level != -1 {
-					continue
-				}
-				if colDeps, exists := unresolvedColDeps[dependent]; exists {
-					if colDeps[colKey] {
-						delete(colDeps, colKey)
-						unresolvedCount[dependent]--
-						if unresolvedCount[dependent] == 0 && !nextLevelSet[dependent] {
-							nextLevel = append(nextLevel, dependent)
-							nextLevelSet[dependent] = true
-						}
-					}
-				}
-			}
+		if level%20 == 0 || len(levelCells) > 100000 {
+			g.logger().Debugf("    📊 [Level Assignment] Level %d: %d nodes (total: %d/%d)",
+				level, len(levelCells), processedCount, len(g.nodes))
 		}
 
 		currentLevel = nextLevel
@@ -420,19 +510,19 @@ func (g *dependencyGraph) assignLevels() {
 
 	// Handle circular dependencies
 	circularCells := make([]string, 0)
-	for cell, node := range g.nodes {
+	for i, node := range nodes {
 		if node.level == -1 {
 			node.level = len(g.levels)
-			circularCells = append(circularCells, cell)
+			circularCells = append(circularCells, cells[i])
 		}
 	}
 
 	if len(circularCells) > 0 {
 		g.levels = append(g.levels, circularCells)
-		log.Printf("  ⚠️  [Level Assignment] Found %d formulas with circular dependencies", len(circularCells))
+		g.logger().Debugf("  ⚠️  [Level Assignment] Found %d formulas with circular dependencies", len(circularCells))
 	}
 
-	log.Printf("  ✅ [Level Assignment] Completed in %v (%d levels)", time.Since(startTime), len(g.levels))
+	g.logger().Debugf("  ✅ [Level Assignment] Completed in %v (%d levels)", time.Since(startTime), len(g.levels))
 
 	// 优化：合并没有相互依赖的级别
 	g.mergeLevels()
@@ -445,6 +535,7 @@ func (g *dependencyGraph) mergeLevels() {
 	}
 
 	originalLevelCount := len(g.levels)
+	g.unmergedLevels = originalLevelCount
 
 	// 为每个公式建立快速查找map，记录它在哪个原始级别
 	cellToOriginalLevel := make(map[string]int)
@@ -463,24 +554,49 @@ func (g *dependencyGraph) mergeLevels() {
 	columnMaxOrigLevel := make(map[string]int) // "Sheet!Col" -> max original level
 	for levelIdx, cells := range g.levels {
 		for _, cell := range cells {
-			parts := strings.Split(cell, "!")
-			if len(parts) == 2 {
+			if sheet, cellRef, ok := splitSheetReference(cell); ok {
 				col := ""
-				for _, ch := range parts[1] {
+				for _, ch := range cellRef {
 					if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') {
 						col += string(ch)
 					} else {
 						break
 					}
 				}
-				colKey := parts[0] + "!" + col
-				if levelIdx > columnMaxOrigLevel[colKey] {
+				colKey := sheet + "!" + col
+				// 只有第 0 层公式的列也要记录，否则对它的列依赖会被当作数据单元格
+				if maxLevel, ok := columnMaxOrigLevel[colKey]; !ok || levelIdx > maxLevel {
 					columnMaxOrigLevel[colKey] = levelIdx
 				}
 			}
 		}
 	}
 
+	// 预先计算每个级别的阻塞级别：依赖的更早级别的公式单元格中的最高级别，以及
+	// 依赖的列中公式的最高级别。起始于 startLevel 的合并级别只能合并阻塞级别早于
+	// startLevel 的级别，避免对每对级别重复扫描依赖 (O(级别数²×依赖数))
+	blockingLevel := make([]int, len(g.levels))
+	for levelIdx, cells := range g.levels {
+		blocking := -1
+		for _, cell := range cells {
+			for _, dep := range g.nodes[cell].dependencies {
+				// 处理虚拟列依赖 (COLUMN:Sheet!Col)：该列所有公式的最高级别必须
+				// 严格早于合并的起始级别，否则可能读到尚未计算的值
+				if colKey, ok := strings.CutPrefix(dep, "COLUMN:"); ok {
+					if depOrigLevel, exists := columnMaxOrigLevel[colKey]; exists && depOrigLevel > blocking {
+						blocking = depOrigLevel
+					}
+					continue
+				}
+				// 数据单元格不影响；依赖于startLevel到nextLevel-1之间的任何级别则不能合并
+				if depOrigLevel, exists := cellToOriginalLevel[dep]; exists && depOrigLevel < levelIdx && depOrigLevel > blocking {
+					blocking = depOrigLevel
+				}
+			}
+		}
+		blockingLevel[levelIdx] = blocking
+	}
+
 	merged := make([][]string, 0)
 	processed := make(map[int]bool) // 已处理的原始级别
 
@@ -493,6 +609,10 @@ func (g *dependencyGraph) mergeLevels() {
 		mergedLevel := make([]string, 0)
 		mergedLevel = append(mergedLevel, g.levels[startLevel]...)
 		processed[startLevel] = true
+		var mergedCost int64
+		if g.maxMergeCostRatio > 0 {
+			mergedCost = g.maxLevelCost(g.levels[startLevel])
+		}
 
 		// 尝试合并后续级别
 		for nextLevel := startLevel + 1; nextLevel < len(g.levels); nextLevel++ {
@@ -501,40 +621,22 @@ func (g *dependencyGraph) mergeLevels() {
 			}
 
 			// 检查nextLevel的公式是否依赖于当前mergedLevel中的公式
-			canMerge := true
-			for _, cell := range g.levels[nextLevel] {
-				node := g.nodes[cell]
-				for _, dep := range node.dependencies {
-					var depOrigLevel int
-					var exists bool
-
-					// 处理虚拟列依赖 (COLUMN:Sheet!Col)
-					if strings.HasPrefix(dep, "COLUMN:") {
-						colKey := strings.TrimPrefix(dep, "COLUMN:")
-						depOrigLevel, exists = columnMaxOrigLevel[colKey]
-					} else {
-						depOrigLevel, exists = cellToOriginalLevel[dep]
-					}
-
-					if !exists {
-						continue // 数据单元格，不影响
-					}
+			canMerge := blockingLevel[nextLevel] < startLevel
 
-					// 如果依赖于startLevel到nextLevel-1之间的任何级别，不能合并
-					if depOrigLevel >= startLevel && depOrigLevel < nextLevel {
-						canMerge = false
-						break
-					}
-				}
-				if !canMerge {
-					break
-				}
+			// 避免合并出代价严重不均衡的级别（如百万行 SUMIFS 与简单算术混在一起）
+			var nextCost int64
+			if canMerge && g.maxMergeCostRatio > 0 {
+				nextCost = g.maxLevelCost(g.levels[nextLevel])
+				canMerge = !g.isSkewedMerge(mergedCost, nextCost)
 			}
 
 			if canMerge {
 				// 可以合并
 				mergedLevel = append(mergedLevel, g.levels[nextLevel]...)
 				processed[nextLevel] = true
+				if nextCost > mergedCost {
+					mergedCost = nextCost
+				}
 			}
 		}
 
@@ -542,18 +644,46 @@ func (g *dependencyGraph) mergeLevels() {
 	}
 
 	g.levels = merged
-	log.Printf("  🔧 [Level Optimization] Merged %d levels into %d levels (reduction: %.1f%%)",
+	g.logger().Debugf("  🔧 [Level Optimization] Merged %d levels into %d levels (reduction: %.1f%%)",
 		originalLevelCount, len(g.levels),
 		float64(originalLevelCount-len(g.levels))*100/float64(originalLevelCount))
 }
 
+// splitSheetReference splits a reference like 'Sales 2024'!$A:$A into the
+// sheet name and the cell part. The sheet names can't contain colons, and the
+// cell part never contains an exclamation mark, so each side of a range is
+// split at its last one to keep the sheet names containing it, and the sheet
+// repeated after the colon, like Sheet2!A1:Sheet2!A3, is removed. The quoted
+// sheet name is unquoted and its doubled apostrophes are unescaped, the name
+// from the efp parser is already unquoted. It returns false if the reference
+// has no sheet name.
+func splitSheetReference(ref string) (string, string, bool) {
+	start, end, isRange := strings.Cut(ref, ":")
+	idx := strings.LastIndex(start, "!")
+	if idx == -1 {
+		return "", ref, false
+	}
+	sheet, cellPart := start[:idx], start[idx+1:]
+	if isRange {
+		if idx := strings.LastIndex(end, "!"); idx != -1 {
+			end = end[idx+1:]
+		}
+		cellPart += ":" + end
+	}
+	// Excel 不允许工作表名称以撇号开头或结尾，两端都有撇号时为引号
+	if len(sheet) >= 2 && strings.HasPrefix(sheet, "'") && strings.HasSuffix(sheet, "'") {
+		sheet = strings.ReplaceAll(sheet[1:len(sheet)-1], "''", "'")
+	}
+	return sheet, cellPart, true
+}
+
 // extractDependencies extracts all cell references from a formula using the efp parser
 func extractDependencies(formula, currentSheet, currentCell string) []string {
 	deps := make(map[string]bool)
 
 	// Use the same parser that CalcCellValue uses
 	ps := efp.ExcelParser()
-	tokens := ps.Parse(formula)
+	tokens := expandImplicitIntersection(ps.Parse(formula))
 	if tokens == nil {
 		return []string{}
 	}
@@ -572,10 +702,7 @@ func extractDependencies(formula, currentSheet, currentCell string) []string {
 			// Check if it's a cross-sheet reference (contains !)
 			if strings.Contains(ref, "!") {
 				// Cross-sheet reference
-				parts := strings.SplitN(ref, "!", 2)
-				if len(parts) == 2 {
-					sheetName := strings.Trim(parts[0], "'")
-					cellPart := parts[1]
+				if sheetName, cellPart, ok := splitSheetReference(ref); ok {
 
 					// Handle ranges (A1:B2 or $B:$B)
 					if strings.Contains(cellPart, ":") {
@@ -670,7 +797,7 @@ func extractDependenciesOptimized(formula, currentSheet, currentCell string, col
 	}
 
 	ps := efp.ExcelParser()
-	tokens := ps.Parse(formula)
+	tokens := expandImplicitIntersection(ps.Parse(formula))
 	if tokens == nil {
 		return []string{}
 	}
@@ -684,12 +811,7 @@ func extractDependenciesOptimized(formula, currentSheet, currentCell string, col
 		var sheetName, cellPart string
 
 		if strings.Contains(ref, "!") {
-			parts := strings.SplitN(ref, "!", 2)
-			if len(parts) != 2 {
-				continue
-			}
-			sheetName = strings.Trim(parts[0], "'")
-			cellPart = parts[1]
+			sheetName, cellPart, _ = splitSheetReference(ref)
 		} else {
 			sheetName = currentSheet
 			cellPart = ref
@@ -772,11 +894,14 @@ func extractDependenciesOptimized(formula, currentSheet, currentCell string, col
 						}
 					}
 				} else {
-					// For large ranges, only add virtual column dependencies for formula columns
+					// For large ranges, only add virtual column dependencies for formula columns.
+					// Without columnIndex the dependencies build the reverse index of the
+					// incremental recalculation, which needs the data columns and the columns
+					// not scanned yet as well, like the column ranges
 					for col := startCol; col <= endCol; col++ {
 						colName, _ := ColumnNumberToName(col)
 						colKey := sheetName + "!" + colName
-						if meta := columnMetadata[colKey]; meta != nil && meta.hasFormulas {
+						if meta := columnMetadata[colKey]; columnIndex == nil || meta != nil && meta.hasFormulas {
 							deps["COLUMN:"+colKey] = true
 						}
 					}
@@ -803,18 +928,19 @@ func extractDependenciesOptimized(formula, currentSheet, currentCell string, col
 func extractSheetReferences(formula string) []string {
 	sheets := make(map[string]bool)
 
-	// Pattern 1: 'Sheet Name'!
-	re1 := regexp.MustCompile(`'([^']+)'!`)
+	// Pattern 1: 'Sheet Name'! (doubled apostrophes escape an apostrophe)
+	re1 := regexp.MustCompile(`'((?:[^']|'')+)'!`)
 	matches1 := re1.FindAllStringSubmatch(formula, -1)
 	for _, m := range matches1 {
 		if len(m) > 1 {
-			sheets[m[1]] = true
+			sheets[strings.ReplaceAll(m[1], "''", "'")] = true
 		}
 	}
 
 	// Pattern 2: SheetName! (without quotes, alphanumeric and Chinese characters)
+	// 先移除带引号的名称，避免把 'Sales 2024'! 中的 2024 当作工作表名称
 	re2 := regexp.MustCompile(`([A-Za-z0-9_\x{4e00}-\x{9fff}]+)!`)
-	matches2 := re2.FindAllStringSubmatch(formula, -1)
+	matches2 := re2.FindAllStringSubmatch(re1.ReplaceAllString(formula, ""), -1)
 	for _, m := range matches2 {
 		if len(m) > 1 {
 			sheets[m[1]] = true
@@ -835,7 +961,7 @@ func extractDependenciesWithColumnIndex(formula, currentSheet, currentCell strin
 
 	// Use the same parser that CalcCellValue uses
 	ps := efp.ExcelParser()
-	tokens := ps.Parse(formula)
+	tokens := expandImplicitIntersection(ps.Parse(formula))
 	if tokens == nil {
 		return []string{}
 	}
@@ -854,10 +980,7 @@ func extractDependenciesWithColumnIndex(formula, currentSheet, currentCell strin
 			// Check if it's a cross-sheet reference (contains !)
 			if strings.Contains(ref, "!") {
 				// Cross-sheet reference
-				parts := strings.SplitN(ref, "!", 2)
-				if len(parts) == 2 {
-					sheetName := strings.Trim(parts[0], "'")
-					cellPart := parts[1]
+				if sheetName, cellPart, ok := splitSheetReference(ref); ok {
 
 					// Handle ranges (A1:B2 or $B:$B)
 					if strings.Contains(cellPart, ":") {
@@ -1003,7 +1126,7 @@ func (f *File) calculateByDependencyLevels(graph *dependencyGraph) {
 		totalFormulas += len(cells)
 	}
 
-	log.Printf("📊 [Dependency-Based Calculation] Starting: %d formulas in %d levels", totalFormulas, len(graph.levels))
+	f.logger().Infof("📊 [Dependency-Based Calculation] Starting: %d formulas in %d levels", totalFormulas, len(graph.levels))
 	overallStart := time.Now()
 
 	processedCount := 0
@@ -1014,7 +1137,7 @@ func (f *File) calculateByDependencyLevels(graph *dependencyGraph) {
 		}
 
 		levelStart := time.Now()
-		log.Printf("  ⚡ [Level %d/%d] Processing %d formulas...", levelIdx, len(graph.levels)-1, len(cells))
+		f.logger().Debugf("  ⚡ [Level %d/%d] Processing %d formulas...", levelIdx, len(graph.levels)-1, len(cells))
 
 		// Try batch optimization for this level
 		// This now also returns a SubExpressionCache with pre-calculated SUMIFS parts
@@ -1034,7 +1157,7 @@ func (f *File) calculateByDependencyLevels(graph *dependencyGraph) {
 		individualDuration := time.Duration(0)
 		if len(remainingCells) > 0 {
 			individualStart := time.Now()
-			individualResults := f.parallelCalculateCells(remainingCells, subExprCache, nil, graph)
+			individualResults := f.parallelCalculateCells(context.Background(), remainingCells, subExprCache, nil, graph)
 			individualDuration = time.Since(individualStart)
 
 			// Count how many cells used the cache
@@ -1055,14 +1178,14 @@ func (f *File) calculateByDependencyLevels(graph *dependencyGraph) {
 				}
 			}
 
-			log.Printf("      [Cache Usage] %d/%d individual formulas could use cache", usedCacheCount, len(remainingCells))
+			f.logger().Debugf("      [Cache Usage] %d/%d individual formulas could use cache", usedCacheCount, len(remainingCells))
 
 			for cell, value := range individualResults {
 				batchResults[cell] = value
 			}
 		}
 
-		log.Printf("      [Timing] Batch: %d formulas in %v (cache: %d SUMIFS), Individual: %d formulas in %v",
+		f.logger().Debugf("      [Timing] Batch: %d formulas in %v (cache: %d SUMIFS), Individual: %d formulas in %v",
 			len(cells)-len(remainingCells), batchDuration, subExprCache.Len(), len(remainingCells), individualDuration)
 
 		// Cache all results (no writeback to worksheet)
@@ -1076,14 +1199,14 @@ func (f *File) calculateByDependencyLevels(graph *dependencyGraph) {
 
 		processedCount += len(cells)
 		levelDuration := time.Since(levelStart)
-		log.Printf("  ✅ [Level %d/%d] Completed %d formulas in %v - Batch: %v, Individual: %v, Writeback: %v - Progress: %d/%d (%.1f%%)",
+		f.logger().Debugf("  ✅ [Level %d/%d] Completed %d formulas in %v - Batch: %v, Individual: %v, Writeback: %v - Progress: %d/%d (%.1f%%)",
 			levelIdx, len(graph.levels)-1, len(cells), levelDuration,
 			batchDuration, individualDuration, writebackDuration,
 			processedCount, totalFormulas, float64(processedCount)*100/float64(totalFormulas))
 	}
 
 	overallDuration := time.Since(overallStart)
-	log.Printf("✅ [Dependency-Based Calculation] Completed all %d formulas in %v (avg: %v/formula)",
+	f.logger().Infof("✅ [Dependency-Based Calculation] Completed all %d formulas in %v (avg: %v/formula)",
 		totalFormulas, overallDuration, overallDuration/time.Duration(totalFormulas))
 }
 
@@ -1130,13 +1253,13 @@ func (f *File) batchCalculateLevel(cells []string, graph *dependencyGraph) (map[
 	}
 
 	// Log SUMIFS statistics
-	log.Printf("      [SubExpr] Found %d pure SUMIFS, %d composite SUMIFS, %d total expressions",
+	f.logger().Debugf("      [SubExpr] Found %d pure SUMIFS, %d composite SUMIFS, %d total expressions",
 		len(pureSUMIFS), len(compositeSUMIFS), len(sumifsExpressions))
 
 	// Batch calculate pure SUMIFS expressions if we have enough
 	if len(pureSUMIFS) >= 10 {
 		batchResults := f.batchCalculateSUMIFS(pureSUMIFS)
-		log.Printf("      [SubExpr] Batch calculated %d pure SUMIFS", len(batchResults))
+		f.logger().Debugf("      [SubExpr] Batch calculated %d pure SUMIFS", len(batchResults))
 		for cell, value := range batchResults {
 			results[cell] = value
 		}
@@ -1149,7 +1272,7 @@ func (f *File) batchCalculateLevel(cells []string, graph *dependencyGraph) (map[
 		uniqueSUMIFS[expr] = append(uniqueSUMIFS[expr], cell)
 	}
 
-	log.Printf("      [SubExpr] Found %d unique SUMIFS expressions in composite formulas", len(uniqueSUMIFS))
+	f.logger().Debugf("      [SubExpr] Found %d unique SUMIFS expressions in composite formulas", len(uniqueSUMIFS))
 
 	// Calculate each unique SUMIFS expression
 	cachedCount := 0
@@ -1192,22 +1315,23 @@ func (f *File) batchCalculateLevel(cells []string, graph *dependencyGraph) (map[
 		cachedCount++
 	}
 
-	log.Printf("      [SubExpr] Successfully cached %d SUMIFS expressions", cachedCount)
-	log.Printf("      [SubExpr] SubExprCache size: %d", subExprCache.Len())
+	f.logger().Debugf("      [SubExpr] Successfully cached %d SUMIFS expressions", cachedCount)
+	f.logger().Debugf("      [SubExpr] SubExprCache size: %d", subExprCache.Len())
 
 	return results, subExprCache
 }
 
 // parallelCalculateCells calculates a list of cells in parallel
 // Now accepts a SubExpressionCache for composite formulas and graph for lock-free formula access
-func (f *File) parallelCalculateCells(cells []string, subExprCache *SubExpressionCache, worksheetCache *WorksheetCache, graph *dependencyGraph) map[string]string {
+// The workers stop taking cells once ctx is canceled, the results calculated so far are returned
+func (f *File) parallelCalculateCells(ctx context.Context, cells []string, subExprCache *SubExpressionCache, worksheetCache *WorksheetCache, graph *dependencyGraph) map[string]string {
 	results := make(map[string]string)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	var cacheHits, cacheMisses int64
 
 	// Use worker pool to limit concurrency
-	numWorkers := 10
+	numWorkers := f.calcWorkers()
 	cellChan := make(chan string, len(cells))
 
 	for _, cell := range cells {
@@ -1220,6 +1344,10 @@ func (f *File) parallelCalculateCells(cells []string, subExprCache *SubExpressio
 		go func() {
 			defer wg.Done()
 			for cell := range cellChan {
+				// 取消后不再计算，剩余单元格留在已关闭的缓冲通道中，worker 直接退出
+				if ctx.Err() != nil {
+					return
+				}
 				// Parse sheet and cell name
 				parts := strings.Split(cell, "!")
 				if len(parts) != 2 {
@@ -1252,6 +1380,7 @@ func (f *File) parallelCalculateCells(cells []string, subExprCache *SubExpressio
 				value, err := f.CalcCellValueWithSubExprCache(sheet, cellName, formula, subExprCache, worksheetCache, opts)
 
 				if err != nil {
+					reportCalcFailure(ctx, cell, formula, value, err)
 					continue
 				}
 
@@ -1265,7 +1394,7 @@ func (f *File) parallelCalculateCells(cells []string, subExprCache *SubExpressio
 	wg.Wait()
 
 	if cacheHits > 0 || cacheMisses > 0 {
-		log.Printf("      [Cache Stats] Hits: %d, Misses: %d, Hit rate: %.1f%%",
+		f.logger().Debugf("      [Cache Stats] Hits: %d, Misses: %d, Hit rate: %.1f%%",
 			cacheHits, cacheMisses, float64(cacheHits)*100/float64(cacheHits+cacheMisses))
 	}
 
@@ -1353,55 +1482,30 @@ func (f *File) batchCalculateSUMPRODUCT(formulas map[string]string) map[string]s
 				results[cell] = formatFloat(value)
 			}
 		}
+
+		// 两个条件的 SUMPRODUCT((range=cell)*(range=cell)*range) 按 SUMIFS 2D 模式计算
+		for _, pattern := range f.groupSUMPRODUCT2DByPattern(formulas) {
+			if len(pattern.formulas) < 10 {
+				continue
+			}
+			for cell, value := range f.calculateSUMPRODUCT2DPatternWithCache(pattern, nil) {
+				results[cell] = formatFloat(value)
+			}
+		}
 	}
 
 	return results
 }
 
-// formatFloat formats a float64 to string
+// formatFloat formats a float64 to string the same way CalcCellValue formats
+// a raw numeric result: values with more than 15 significant digits are
+// rounded to 15 like Excel, so 0.1+0.2 gives "0.3" instead of
+// "0.30000000000000004"
 func formatFloat(value float64) string {
-	// Use simple string formatting
-	result := ""
-	// Handle negative numbers
-	if value < 0 {
-		result = "-"
-		value = -value
-	}
-
-	// Get integer part
-	intPart := int64(value)
-	fracPart := value - float64(intPart)
-
-	// Format integer part
-	if intPart == 0 {
-		result += "0"
-	} else {
-		digits := make([]byte, 0, 20)
-		temp := intPart
-		for temp > 0 {
-			digits = append(digits, byte('0'+temp%10))
-			temp /= 10
-		}
-		// Reverse
-		for i := len(digits) - 1; i >= 0; i-- {
-			result += string(digits[i])
-		}
-	}
-
-	// Add fractional part if needed
-	if fracPart > 0.0000001 {
-		result += "."
-		for i := 0; i < 10; i++ {
-			fracPart *= 10
-			digit := int(fracPart)
-			result += string(byte('0' + digit))
-			fracPart -= float64(digit)
-			if fracPart < 0.0000001 {
-				break
-			}
-		}
+	result := strconv.FormatFloat(value, 'f', -1, 64)
+	if len(strings.ReplaceAll(result, ".", "")) > 15 {
+		return strings.ToUpper(strconv.FormatFloat(value, 'G', 15, 64))
 	}
-
 	return result
 }
 
@@ -1410,12 +1514,33 @@ func formatFloat(value float64) string {
 //
 // Thread Safety: This method uses a mutex to prevent concurrent recalculation on the same File object.
 // If called concurrently, subsequent calls will block until the current recalculation completes.
+//
+// Circular references are calculated in a final level by default, set
+// CalcTuning.FailOnCircularDependency to get a *CircularDependencyError instead.
 func (f *File) RecalculateAllWithDependency() error {
+	return f.RecalculateAllWithDependencyContext(context.Background())
+}
+
+// RecalculateAllWithDependencyContext is the cancelable version of
+// RecalculateAllWithDependency. The context is checked while building the
+// dependency graph, between the levels and by the calculation workers, which
+// stop taking new formulas and exit once it is canceled, and ctx.Err() is
+// returned. The formulas calculated before the cancellation keep their new
+// values, the others keep their previous values. For example, give up the
+// recalculation with the timeout of an HTTP request:
+//
+//	if err := f.RecalculateAllWithDependencyContext(r.Context()); err != nil {
+//	    http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//	    return
+//	}
+func (f *File) RecalculateAllWithDependencyContext(ctx context.Context) error {
 	// Acquire lock to prevent concurrent recalculation
 	f.recalcMu.Lock()
 	defer f.recalcMu.Unlock()
 
-	log.Printf("📊 [RecalculateAll] Starting recalculation with DAG-based concurrent execution")
+	f.logger().Infof("📊 [RecalculateAll] Starting recalculation with DAG-based concurrent execution")
+	ctx, release := f.withCalcFailure(ctx)
+	defer release()
 
 	// ========================================
 	// 清理旧缓存,避免内存泄漏
@@ -1432,18 +1557,44 @@ func (f *File) RecalculateAllWithDependency() error {
 	if rangeCacheCount > 0 {
 		f.rangeCache.Clear()
 	}
+	f.retainedSubExprs.clear()
 
 	if calcCacheCount > 0 || rangeCacheCount > 0 {
-		log.Printf("  🧹 [Cache Cleanup] Cleared %d calcCache entries and %d rangeCache entries", calcCacheCount, rangeCacheCount)
+		f.logger().Debugf("  🧹 [Cache Cleanup] Cleared %d calcCache entries and %d rangeCache entries", calcCacheCount, rangeCacheCount)
 	}
 
 	// Build dependency graph
-	graph := f.buildDependencyGraph()
-
-	// Calculate using true DAG concurrency
-	f.calculateByDAG(graph)
+	var graph *dependencyGraph
+	var err error
+	if f.calcTuning.UseCalcChain {
+		graph, err = f.buildDependencyGraphFromCalcChain(ctx)
+	}
+	if graph == nil {
+		graph, err = f.buildDependencyGraphWithContext(ctx)
+	}
+	if err != nil {
+		f.logger().Infof("⚠️  [RecalculateAll] Canceled while building dependency graph: %v", err)
+		return err
+	}
 
-	log.Printf("✅ [RecalculateAll] Completed")
+	if f.calcTuning.FailOnCircularDependency {
+		if cycles := graph.circularReferences(); len(cycles) > 0 {
+			f.logger().Infof("❌ [RecalculateAll] Found %d circular references, skip calculation", len(cycles))
+			return &CircularDependencyError{Cycles: cycles}
+		}
+	}
+
+	// A full shadow recalculation replaces the values of the former ones
+	if f.calcTuning.ShadowOutput {
+		f.computedValues.Store(nil)
+	}
+
+	// Calculate using true DAG concurrency
+	if err = calcFailureError(ctx, f.calculateByDAGWithContext(ctx, graph)); err != nil {
+		return err
+	}
+
+	f.logger().Infof("✅ [RecalculateAll] Completed")
 	return nil
 }
 
@@ -1474,7 +1625,7 @@ func (f *File) RecalculateSheetWithDependency(sheet string) error {
 		return newNotWorksheetError(sheet)
 	}
 
-	log.Printf("📊 [RecalculateSheet] Starting recalculation for sheet '%s' with DAG-based concurrent execution", sheet)
+	f.logger().Infof("📊 [RecalculateSheet] Starting recalculation for sheet '%s' with DAG-based concurrent execution", sheet)
 
 	// Clear caches for the target sheet only (prefix-based cleanup)
 	calcCacheCount := 0
@@ -1493,21 +1644,21 @@ func (f *File) RecalculateSheetWithDependency(sheet string) error {
 	}
 
 	if calcCacheCount > 0 || rangeCacheCount > 0 {
-		log.Printf("  🧹 [Cache Cleanup] Cleared %d calcCache entries (sheet-scoped) and %d rangeCache entries", calcCacheCount, rangeCacheCount)
+		f.logger().Debugf("  🧹 [Cache Cleanup] Cleared %d calcCache entries (sheet-scoped) and %d rangeCache entries", calcCacheCount, rangeCacheCount)
 	}
 
 	// Build sheet-scoped dependency graph
 	graph := f.buildDependencyGraphForSheet(sheet)
 
 	if len(graph.nodes) == 0 {
-		log.Printf("✅ [RecalculateSheet] No formulas found in sheet '%s', nothing to recalculate", sheet)
+		f.logger().Infof("✅ [RecalculateSheet] No formulas found in sheet '%s', nothing to recalculate", sheet)
 		return nil
 	}
 
 	// Calculate using the same DAG concurrency engine
 	f.calculateByDAG(graph)
 
-	log.Printf("✅ [RecalculateSheet] Completed for sheet '%s'", sheet)
+	f.logger().Infof("✅ [RecalculateSheet] Completed for sheet '%s'", sheet)
 	return nil
 }
 
@@ -1520,18 +1671,16 @@ func (f *File) buildDependencyGraphForSheet(targetSheet string) *dependencyGraph
 	startTime := time.Now()
 
 	graph := &dependencyGraph{
-		nodes:          make(map[string]*formulaNode),
-		columnMetadata: make(map[string]*columnMeta),
+		nodes:             make(map[string]*formulaNode),
+		columnMetadata:    make(map[string]*columnMeta),
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
 	}
 
 	// Step 1: First pass - collect column metadata from ALL sheets, but formulas only from targetSheet
 	sheetList := f.GetSheetList()
-	formulasToProcess := make([]struct {
-		fullCell string
-		sheet    string
-		cellRef  string
-		formula  string
-	}, 0)
+	formulasToProcess := make([]pendingFormula, 0)
 
 	for _, sheet := range sheetList {
 		ws, err := f.workSheetReader(sheet)
@@ -1566,19 +1715,9 @@ func (f *File) buildDependencyGraphForSheet(targetSheet string) *dependencyGraph
 
 				// Only collect formulas from the target sheet
 				if isTargetSheet && cell.F != nil {
-					formula := cell.F.Content
-					if formula == "" && cell.F.T == STCellFormulaTypeShared && cell.F.Si != nil {
-						formula, _ = getSharedFormula(ws, *cell.F.Si, cell.R)
-					}
-
-					if formula != "" {
+					if formula := resolvedFormula(ws, &cell); formula != "" {
 						fullCell := sheet + "!" + cell.R
-						formulasToProcess = append(formulasToProcess, struct {
-							fullCell string
-							sheet    string
-							cellRef  string
-							formula  string
-						}{fullCell, sheet, cell.R, formula})
+						formulasToProcess = append(formulasToProcess, pendingFormula{fullCell, sheet, cell.R, formula})
 
 						graph.nodes[fullCell] = &formulaNode{
 							cell:         fullCell,
@@ -1607,7 +1746,7 @@ func (f *File) buildDependencyGraphForSheet(targetSheet string) *dependencyGraph
 		}
 	}
 
-	log.Printf("  📊 [Sheet Dependency] Collected %d formulas from '%s', %d columns metadata (%d with formulas, %d pure data)",
+	f.logger().Debugf("  📊 [Sheet Dependency] Collected %d formulas from '%s', %d columns metadata (%d with formulas, %d pure data)",
 		len(graph.nodes), targetSheet, len(graph.columnMetadata), formulaCols, dataCols)
 
 	if len(graph.nodes) == 0 {
@@ -1635,70 +1774,33 @@ func (f *File) buildDependencyGraphForSheet(targetSheet string) *dependencyGraph
 		}
 	}
 
-	log.Printf("  📊 [Sheet Dependency] Built column index: %d columns with formulas", len(columnIndex))
+	f.logger().Debugf("  📊 [Sheet Dependency] Built column index: %d columns with formulas", len(columnIndex))
 
 	// Step 3: Extract dependencies (PARALLELIZED)
-	log.Printf("  📊 [Sheet Dependency] Extracting dependencies for %d formulas (parallel)...", len(formulasToProcess))
+	f.logger().Debugf("  📊 [Sheet Dependency] Extracting dependencies for %d formulas (parallel)...", len(formulasToProcess))
 	extractStart := time.Now()
 
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numWorkers > 16 {
 		numWorkers = 16
 	}
-
-	type depResult struct {
-		fullCell string
-		deps     []string
-	}
-
-	workChan := make(chan struct {
-		fullCell string
-		sheet    string
-		cellRef  string
-		formula  string
-	}, len(formulasToProcess))
-
-	resultChan := make(chan depResult, len(formulasToProcess))
-
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for info := range workChan {
-				deps := extractDependenciesOptimized(info.formula, info.sheet, info.cellRef, columnIndex, graph.columnMetadata)
-				resultChan <- depResult{fullCell: info.fullCell, deps: deps}
-			}
-		}()
-	}
-
-	go func() {
-		for _, info := range formulasToProcess {
-			workChan <- info
-		}
-		close(workChan)
-	}()
-
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	for result := range resultChan {
-		graph.nodes[result.fullCell].dependencies = result.deps
+	allDeps := f.extractDependenciesParallel(context.Background(), formulasToProcess, columnIndex, graph.columnMetadata, numWorkers)
+	for i, info := range formulasToProcess {
+		graph.nodes[info.fullCell].dependencies = allDeps[i]
 	}
 
-	log.Printf("  📊 [Sheet Dependency] Extracted dependencies in %v (parallel with %d workers)", time.Since(extractStart), numWorkers)
+	f.logger().Debugf("  📊 [Sheet Dependency] Extracted dependencies in %v (parallel with %d workers)", time.Since(extractStart), numWorkers)
 
 	// Step 4: Assign levels using topological sort
 	graph.assignLevels()
 
 	duration := time.Since(startTime)
-	log.Printf("  ✅ [Sheet Dependency] Completed in %v", duration)
-	log.Printf("  📈 [Sheet Dependency] %d levels for sheet '%s'", len(graph.levels), targetSheet)
+	f.logger().Debugf("  ✅ [Sheet Dependency] Completed in %v", duration)
+	f.logger().Debugf("  📈 [Sheet Dependency] %d levels for sheet '%s'", len(graph.levels), targetSheet)
 	for i, cells := range graph.levels {
-		log.Printf("      Level %d: %d formulas", i, len(cells))
+		f.logger().Debugf("      Level %d: %d formulas", i, len(cells))
 	}
+	f.levelHistogram.Store(graph.levelHistogram())
 
 	return graph
 }
@@ -1727,23 +1829,135 @@ func (f *File) ClearFormulaCache() {
 	}
 
 	if calcCacheCount > 0 || rangeCacheCount > 0 {
-		log.Printf("🧹 [Cache Cleanup] Cleared %d calcCache entries and %d rangeCache entries", calcCacheCount, rangeCacheCount)
+		f.logger().Debugf("🧹 [Cache Cleanup] Cleared %d calcCache entries and %d rangeCache entries", calcCacheCount, rangeCacheCount)
+	}
+}
+
+// ClearFormulaCacheForSheet clears the formula calculation caches of the cells
+// and ranges on a worksheet, which is cheaper than ClearFormulaCache after
+// changing one worksheet of a multi-sheet workbook. The caches of the other
+// worksheets are kept, so the formulas on other worksheets referencing the
+// worksheet may still hold stale values unless their worksheets are cleared
+// as well.
+//
+// Example usage:
+//
+//	f.SetCellValue("Sheet1", "A1", "new value")
+//	f.ClearFormulaCacheForSheet("Sheet1")
+//	f.RecalculateSheetWithDependency("Sheet1")
+func (f *File) ClearFormulaCacheForSheet(sheet string) {
+	calcCacheCount := 0
+	f.calcCache.Range(func(key, value interface{}) bool {
+		if k, ok := key.(string); ok && isSheetCacheKey(k, sheet) {
+			f.calcCache.Delete(key)
+			calcCacheCount++
+		}
+		return true
+	})
+
+	// Collect keys to delete first to avoid deadlock
+	// (Range holds RLock, Delete needs Lock)
+	var keysToDelete []string
+	f.rangeCache.Range(func(key string, value interface{}) bool {
+		if isSheetCacheKey(key, sheet) {
+			keysToDelete = append(keysToDelete, key)
+		}
+		return true
+	})
+	for _, key := range keysToDelete {
+		f.rangeCache.Delete(key)
+	}
+
+	if calcCacheCount > 0 || len(keysToDelete) > 0 {
+		f.logger().Debugf("🧹 [Cache Cleanup] Cleared %d calcCache entries and %d rangeCache entries of %s", calcCacheCount, len(keysToDelete), sheet)
+	}
+}
+
+// isSheetCacheKey reports whether a key of the calculation caches, like
+// "Sheet1!A1", "Sheet1!A1!raw=true", "Sheet1!A1!subexpr:SUM(B:B)!raw=true"
+// or the range key "Sheet1!R1C1:R9C2", belongs to the worksheet. A cell or
+// range reference must follow the sheet name, so the keys of a worksheet
+// whose name starts with the sheet name and "!", like "Sheet1!Copy", don't
+// match.
+func isSheetCacheKey(key, sheet string) bool {
+	rest, ok := strings.CutPrefix(key, sheet+"!")
+	if !ok {
+		return false
+	}
+	ref, suffix, hasSuffix := strings.Cut(rest, "!")
+	if hasSuffix && !strings.HasPrefix(suffix, "subexpr:") {
+		if !strings.HasPrefix(suffix, "raw=") || strings.Contains(suffix, "!") {
+			return false
+		}
+	}
+	if _, _, err := CellNameToCoordinates(ref); err == nil {
+		return true
+	}
+	return !hasSuffix && isRangeCacheKeyRef(ref)
+}
+
+// isRangeCacheKeyRef reports whether a reference is the range part of a key
+// generated by generateRangeCacheKey, like "R1C1:R9C2".
+func isRangeCacheKeyRef(ref string) bool {
+	from, to, ok := strings.Cut(ref, ":")
+	if !ok {
+		return false
+	}
+	for _, part := range []string{from, to} {
+		row, col, ok := strings.Cut(strings.TrimPrefix(part, "R"), "C")
+		if !ok || !strings.HasPrefix(part, "R") {
+			return false
+		}
+		if _, err := strconv.Atoi(row); err != nil {
+			return false
+		}
+		if _, err := strconv.Atoi(col); err != nil {
+			return false
+		}
 	}
+	return true
 }
 
 // calculateByDAG executes formulas using per-level batch optimization with shared data cache
 // Each level is batch-optimized before calculation, with data sources cached globally
 func (f *File) calculateByDAG(graph *dependencyGraph) {
+	_ = f.calculateByDAGWithContext(context.Background(), graph)
+}
+
+// cancelCheckInterval is the number of rows or formulas processed between two
+// cancellation checks in the long running loops of a recalculation.
+const cancelCheckInterval = 1024
+
+// calculateByDAGWithContext calculates the graph level by level like
+// calculateByDAG. Cancellation is checked before each level and by the DAG
+// scheduler workers, the remaining formulas keep their previous values and
+// the context error is returned.
+func (f *File) calculateByDAGWithContext(ctx context.Context, graph *dependencyGraph) (err error) {
 	totalFormulas := 0
 	for _, cells := range graph.levels {
 		totalFormulas += len(cells)
 	}
 
-	log.Printf("📊 [DAG Calculation] Starting: %d formulas across %d levels", totalFormulas, len(graph.levels))
+	f.logger().Infof("📊 [DAG Calculation] Starting: %d formulas across %d levels", totalFormulas, len(graph.levels))
+	ctx, span := f.tracer().StartSpan(ctx, "excelize.calculate")
+	defer span.End()
+	span.SetAttribute("formulas", totalFormulas)
+	span.SetAttribute("levels", len(graph.levels))
+
+	// 本次重算期间共享已解码的原始行数据（共享字符串只解码一次），写入时按工作表失效
+	rowsCache := NewSheetDataCache()
+	if f.sheetDataCache.CompareAndSwap(nil, rowsCache) {
+		defer f.sheetDataCache.CompareAndSwap(rowsCache, nil)
+	}
+
+	// CalcTuning.ShadowOutput 模式下计算结果写入影子表，不修改单元格的值
+	if shadow := f.beginShadowOutput(); shadow != nil {
+		defer f.endShadowOutput(shadow, graph)
+	}
 
-	// 使用 CPU 核心数作为 worker 数量
-	numWorkers := runtime.NumCPU()
-	log.Printf("  🔧 Using %d workers (CPU cores: %d)", numWorkers, runtime.NumCPU())
+	// 使用 SetCalcConcurrency 设置的并发数作为 worker 数量，默认为 CPU 核心数
+	numWorkers := f.calcWorkers()
+	f.logger().Debugf("  🔧 Using %d workers (CPU cores: %d)", numWorkers, runtime.NumCPU())
 
 	// ========================================
 	// 预处理：确保 setArrayFormulaCells 已执行
@@ -1762,32 +1976,89 @@ func (f *File) calculateByDAG(graph *dependencyGraph) {
 	// 关键优化：创建全局数据源缓存（懒加载模式）
 	// 所有层级的批量SUMIFS计算共享同一份数据源，避免重复读取
 	// ========================================
-	log.Printf("⚡ [Worksheet Cache] Initializing lazy cache...")
+	f.logger().Debugf("⚡ [Worksheet Cache] Initializing lazy cache...")
 	cacheStart := time.Now()
 	worksheetCache := f.buildWorksheetCache(graph)
+	defer func() {
+		if evictions := worksheetCache.Evictions(); evictions > 0 {
+			f.logger().Debugf("⚡ [Worksheet Cache] Evicted %d columns to keep within %d bytes, size %d bytes",
+				evictions, f.calcTuning.WorksheetCacheMaxBytes, worksheetCache.Size())
+		}
+		if closeErr := worksheetCache.Close(); err == nil {
+			err = closeErr
+		}
+	}()
 	cacheDuration := time.Since(cacheStart)
-	log.Printf("✅ [Worksheet Cache] Initialized in %v (lazy loading enabled)", cacheDuration)
+	f.logger().Debugf("✅ [Worksheet Cache] Initialized in %v (lazy loading enabled)", cacheDuration)
+
+	// 审计模式：记录每层新计算出的值及其来源
+	var audit *calcAuditLog
+	recorded := make(map[string]bool)
+	if f.calcTuning.AuditTrail {
+		audit = newCalcAuditLog(f.calcTuning.AuditTrailLimit)
+		f.calcAudit.Store(audit)
+		defer func() {
+			if audit.dropped > 0 {
+				f.logger().Infof("⚠️  [Audit Trail] Dropped %d entries beyond the limit of %d", audit.dropped, audit.limit)
+			}
+		}()
+	}
+
+	// 性能分析模式：记录逐个计算的公式和各个批量模式的耗时
+	if f.calcTuning.Profile {
+		profile := newCalcProfile()
+		f.calcProfile.Store(profile)
+		ctx = context.WithValue(ctx, calcProfileKey{}, profile)
+	}
 
 	// 全局进度跟踪
 	totalCompleted := int64(0)
+	plan := CalcPlan{Levels: len(graph.levels), Formulas: totalFormulas}
+	progress := calcProgressFromContext(ctx)
+	if progress != nil {
+		progress.totalLevels = len(graph.levels)
+	}
+	stats := recalcStatsFromContext(ctx)
+	if stats != nil {
+		calcStart := time.Now()
+		stats.startGraph(graph)
+		defer func() { stats.finish(time.Since(calcStart)) }()
+	}
 
 	// 逐层处理：批量优化 -> 动态调度计算
 	for levelIdx, levelCells := range graph.levels {
 		if len(levelCells) == 0 {
-			log.Printf("\n⚠️  [Level %d] Skipping empty level", levelIdx)
+			f.logger().Infof("⚠️  [Level %d] Skipping empty level", levelIdx)
+			if progress != nil {
+				progress.completedLevels = append(progress.completedLevels, levelIdx)
+			}
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			f.logger().Infof("⚠️  [DAG Calculation] Canceled before level %d: %v", levelIdx, err)
+			return err
+		}
 
 		levelStart := time.Now()
-		log.Printf("\n🔄 [Level %d] Processing %d formulas", levelIdx, len(levelCells))
+		f.logger().Infof("🔄 [Level %d] Processing %d formulas", levelIdx, len(levelCells))
+		levelCtx, levelSpan := f.tracer().StartSpan(ctx, "excelize.level")
+		levelSpan.SetAttribute("level", levelIdx)
+		levelSpan.SetAttribute("formulas", len(levelCells))
+
+		// ========================================
+		// 步骤0：常量公式（如 ="N/A"）按不同的公式各计算一次，其余步骤只处理 pendingCells
+		// ========================================
+		pendingCells, constantCells, distinctConstants := f.calculateConstantFormulas(ctx, levelCells, graph, worksheetCache)
+		if audit != nil {
+			f.recordAuditLevel(audit, levelIdx, constantCells, graph, worksheetCache, recorded, auditOptimizerConstant)
+		}
 
-		// Debug: 检查这个 level 是否包含 补货汇总!I 或 补货汇总!J 列的公式
 		// ========================================
 		// 步骤1：自动检测并预读取列范围模式
 		// ========================================
 		// Detect if this level has formulas accessing the same column range across multiple rows
 		// If detected, preload the entire column range to avoid repeated single-row reads
-		columnRangePatterns := f.detectColumnRangePatterns(levelCells, graph)
+		columnRangePatterns := f.detectColumnRangePatterns(pendingCells, graph)
 		for sheet, patterns := range columnRangePatterns {
 			for _, pattern := range patterns {
 				// Find min and max row numbers
@@ -1803,7 +2074,7 @@ func (f *File) calculateByDAG(graph *dependencyGraph) {
 
 				// Preload this column range
 				if err := f.PreloadColumnRange(sheet, minRow, maxRow, pattern.key.startCol, pattern.key.endCol, worksheetCache); err != nil {
-					log.Printf("  ⚠️  [Level %d Preload] Failed to preload %s C%d:C%d: %v",
+					f.logger().Debugf("  ⚠️  [Level %d Preload] Failed to preload %s C%d:C%d: %v",
 						levelIdx, sheet, pattern.key.startCol, pattern.key.endCol, err)
 				}
 			}
@@ -1813,63 +2084,129 @@ func (f *File) calculateByDAG(graph *dependencyGraph) {
 		// 步骤2：先计算当前层的"简单公式"（非批量优化类型）
 		// 这些公式的结果会被后续的批量SUMIFS/INDEX-MATCH使用
 		// ========================================
-		log.Printf("  🔄 [Level %d] Pre-calculating simple formulas...", levelIdx)
+		f.logger().Debugf("  🔄 [Level %d] Pre-calculating simple formulas...", levelIdx)
 		preCalcStart := time.Now()
-		simpleFormulas := f.preCalculateSimpleFormulas(levelCells, graph, worksheetCache)
+		_, preCalcSpan := f.tracer().StartSpan(levelCtx, "excelize.level.precalc")
+		simpleFormulas := f.preCalculateSimpleFormulas(ctx, pendingCells, graph, worksheetCache)
+		preCalcSpan.SetAttribute("formulas", simpleFormulas)
+		preCalcSpan.End()
 		preCalcDuration := time.Since(preCalcStart)
-		log.Printf("  ✅ [Level %d] Pre-calculated %d simple formulas in %v", levelIdx, simpleFormulas, preCalcDuration)
+		f.logger().Debugf("  ✅ [Level %d] Pre-calculated %d simple formulas in %v", levelIdx, simpleFormulas, preCalcDuration)
+		if err := ctx.Err(); err != nil {
+			f.logger().Infof("⚠️  [DAG Calculation] Canceled in level %d: %v", levelIdx, err)
+			levelSpan.End()
+			return err
+		}
 
 		// ========================================
 		// 步骤3：为当前层批量优化 SUMIFS（使用共享数据缓存）
 		// ========================================
-		log.Printf("  🔧 [Level %d] Starting batch optimization...", levelIdx)
+		f.logger().Debugf("  🔧 [Level %d] Starting batch optimization...", levelIdx)
 		batchOptStart := time.Now()
-		subExprCache := f.batchOptimizeLevelWithCache(levelIdx, levelCells, graph, worksheetCache)
+		batchCtx, batchSpan := f.tracer().StartSpan(levelCtx, "excelize.level.batch")
+		batchSpan.SetAttribute("formulas", len(pendingCells)-simpleFormulas)
+		subExprCache, levelPlan := f.batchOptimizeLevelWithCache(batchCtx, levelIdx, pendingCells, graph, worksheetCache)
+		batchSpan.End()
+		levelPlan.ConstantFormulas, levelPlan.DistinctConstants = len(constantCells), distinctConstants
+		plan.add(levelPlan)
 		batchOptDuration := time.Since(batchOptStart)
-		log.Printf("  ✅ [Level %d] Batch optimization completed in %v", levelIdx, batchOptDuration)
+		f.logger().Debugf("  ✅ [Level %d] Batch optimization completed in %v", levelIdx, batchOptDuration)
+		if audit != nil {
+			f.recordAuditLevel(audit, levelIdx, levelCells, graph, worksheetCache, recorded, auditOptimizerPreCalc)
+		}
 
 		// ========================================
 		// 步骤3：使用 DAG 调度器动态计算当前层
 		// ========================================
-		log.Printf("  🚀 [Level %d] Creating DAG scheduler...", levelIdx)
+		f.logger().Debugf("  🚀 [Level %d] Creating DAG scheduler...", levelIdx)
 		dagStart := time.Now()
-		scheduler, ok := f.NewDAGSchedulerForLevel(graph, levelIdx, levelCells, numWorkers, subExprCache, worksheetCache)
+		_, dagSpan := f.tracer().StartSpan(levelCtx, "excelize.level.dag")
+		dagSpan.SetAttribute("formulas", len(levelCells))
+		// 按估计代价从高到低排队，避免耗时的公式在层末单独执行
+		scheduler, ok := f.NewDAGSchedulerForLevel(graph, levelIdx, graph.orderByCost(pendingCells), numWorkers, subExprCache, worksheetCache)
 		dagDuration := time.Duration(0)
 		if !ok || scheduler == nil {
-			log.Printf("  ⚠️  [Level %d] 检测到循环依赖，退回顺序计算", levelIdx)
-			results := f.parallelCalculateCells(levelCells, subExprCache, worksheetCache, graph)
+			f.logger().Debugf("  ⚠️  [Level %d] 检测到循环依赖，退回顺序计算", levelIdx)
+			results := f.parallelCalculateCells(ctx, pendingCells, subExprCache, worksheetCache, graph)
 			for cell, value := range results {
 				parts := strings.Split(cell, "!")
 				if len(parts) == 2 {
 					f.storeCalculatedValue(parts[0], parts[1], value, worksheetCache)
 				}
 			}
+			if err := ctx.Err(); err != nil {
+				f.logger().Infof("⚠️  [DAG Calculation] Canceled in level %d: %v", levelIdx, err)
+				dagSpan.End()
+				levelSpan.End()
+				return err
+			}
 			dagDuration = time.Since(dagStart)
 		} else {
-			log.Printf("  🚀 [Level %d] DAG scheduler created, starting execution with %d workers...", levelIdx, numWorkers)
-			scheduler.Run()
+			f.logger().Debugf("  🚀 [Level %d] DAG scheduler created, starting execution with %d workers...", levelIdx, numWorkers)
+			err := scheduler.RunWithContext(ctx)
+			if stats != nil {
+				stats.CacheHits += scheduler.cacheHits.Load()
+				stats.CacheMisses += scheduler.cacheMisses.Load()
+			}
+			if err != nil {
+				f.logger().Infof("⚠️  [DAG Calculation] Canceled in level %d: %v", levelIdx, err)
+				dagSpan.End()
+				levelSpan.End()
+				return err
+			}
 			dagDuration = time.Since(dagStart)
-			log.Printf("  ✅ [Level %d] DAG execution completed in %v", levelIdx, dagDuration)
+			f.logger().Debugf("  ✅ [Level %d] DAG execution completed in %v", levelIdx, dagDuration)
+		}
+
+		dagSpan.End()
+
+		// ========================================
+		// 步骤4：动态数组的锚点更新溢出区域，后续层级读取新的溢出值
+		// ========================================
+		if spilled := f.updateSpillRanges(levelCells, graph, worksheetCache); spilled > 0 {
+			f.logger().Debugf("  ✅ [Level %d] Updated the spill ranges of %d dynamic arrays", levelIdx, spilled)
+		}
+		levelSpan.End()
+		if audit != nil {
+			f.recordAuditLevel(audit, levelIdx, levelCells, graph, worksheetCache, recorded, auditOptimizerCell)
 		}
 
 		// 更新全局进度
 		totalCompleted += int64(len(levelCells))
+		if progress != nil {
+			progress.completedLevels = append(progress.completedLevels, levelIdx)
+		}
 		levelDuration := time.Since(levelStart)
+		if stats != nil {
+			stats.addLevel(LevelTiming{
+				Level: levelIdx, Formulas: len(levelCells),
+				PreCalc: preCalcDuration, Batch: batchOptDuration, DAG: dagDuration, Total: levelDuration,
+			}, levelCells, graph, levelPlan)
+		}
 
-		log.Printf("✅ [Level %d] Completed %d formulas in %v (batch: %v, dag: %v, avg: %v/formula)",
+		f.logger().Infof("✅ [Level %d] Completed %d formulas in %v (batch: %v, dag: %v, avg: %v/formula)",
 			levelIdx, len(levelCells), levelDuration, batchOptDuration, dagDuration, levelDuration/time.Duration(len(levelCells)))
-		log.Printf("  📈 Global Progress: %d/%d (%.1f%%)",
+		f.logger().Debugf("  📈 Global Progress: %d/%d (%.1f%%)",
 			totalCompleted, totalFormulas, float64(totalCompleted)*100/float64(totalFormulas))
 	}
 
-	log.Printf("\n✅ [DAG Calculation] Completed all %d formulas", totalFormulas)
+	f.logger().Infof("✅ [DAG Calculation] Completed all %d formulas", totalFormulas)
+	f.lastCalcPlan.Store(&plan)
+	return nil
 }
 
 // buildWorksheetCache creates a worksheet cache with lazy loading
 // OPTIMIZATION: Does NOT pre-load entire sheets - only tracks which sheets might be needed
 // Actual data loading happens on-demand through PreloadColumnRange or individual cell reads
 func (f *File) buildWorksheetCache(graph *dependencyGraph) *WorksheetCache {
-	worksheetCache := NewWorksheetCache()
+	worksheetCache := NewWorksheetCacheWithMaxBytes(f.calcTuning.WorksheetCacheMaxBytes)
+	if f.calcTuning.NewWorksheetCacheBackend != nil {
+		if backend, err := f.calcTuning.NewWorksheetCacheBackend(); err != nil {
+			f.logger().Infof("⚠️  [Worksheet Cache] Failed to create the cache backend, keep the values in memory: %v", err)
+		} else {
+			worksheetCache = NewWorksheetCacheWithBackend(backend)
+		}
+	}
 	sheetsToTrack := make(map[string]bool)
 
 	// Collect all sheets that might be referenced (for tracking, not loading)
@@ -1924,7 +2261,7 @@ func (f *File) buildWorksheetCache(graph *dependencyGraph) *WorksheetCache {
 		}
 	}
 
-	log.Printf("  📦 [Worksheet Cache] Tracking %d sheets (lazy loading enabled)", len(sheetsToTrack))
+	f.logger().Debugf("  📦 [Worksheet Cache] Tracking %d sheets (lazy loading enabled)", len(sheetsToTrack))
 
 	// DO NOT pre-load sheets - let PreloadColumnRange and on-demand loading handle it
 	// This is the key optimization to prevent memory explosion
@@ -1933,13 +2270,26 @@ func (f *File) buildWorksheetCache(graph *dependencyGraph) *WorksheetCache {
 }
 
 // getRowsRaw reads all rows from a sheet with raw cell values (unformatted)
-// This is crucial for SUMIFS to match date values correctly
+// This is crucial for SUMIFS to match date values correctly: the dates are
+// kept as their serial numbers rather than formatted by the cell styles. The
+// values are read from the cells of the worksheet directly, resolving the
+// shared strings and the inline strings like GetCellValue with RawCellValue,
+// without resolving the worksheet for each cell.
 func (f *File) getRowsRaw(sheet string) ([][]string, error) {
+	f.mu.Lock()
 	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sst, err := f.sharedStringsReader()
 	if err != nil {
 		return nil, err
 	}
 
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
 	rows := [][]string{}
 	if ws.SheetData.Row == nil || len(ws.SheetData.Row) == 0 {
 		return rows, nil
@@ -1966,29 +2316,47 @@ func (f *File) getRowsRaw(sheet string) ([][]string, error) {
 	}
 
 	// Fill in values using raw cell value
+	hasMergeCells := ws.MergeCells != nil && len(ws.MergeCells.Cells) > 0
+	merged := make(map[[2]int]string) // 合并区域内的单元格 -> 左上角单元格
 	for _, row := range ws.SheetData.Row {
 		rowIdx := int(row.R) - 1
 		if rowIdx < 0 || rowIdx >= len(rows) {
 			continue
 		}
 
-		for _, cell := range row.C {
+		for i := range row.C {
+			cell := &row.C[i]
 			col, rowNum, _ := CellNameToCoordinates(cell.R)
 			if rowNum-1 != rowIdx || col <= 0 || col > maxCol {
 				continue
 			}
+			if hasMergeCells {
+				if topLeft, err := ws.mergeCellsParser(cell.R); err == nil && topLeft != cell.R {
+					merged[[2]int{rowIdx, col - 1}] = topLeft
+					continue
+				}
+			}
 
 			// Get raw cell value (unformatted)
-			value, _ := f.GetCellValue(sheet, cell.R, Options{RawCellValue: true})
+			value, _ := cell.getValueFrom(f, sst, true)
 			rows[rowIdx][col-1] = value
 		}
 	}
 
+	// 合并区域内的单元格取左上角单元格的值，与 GetCellValue 一致
+	for pos, topLeft := range merged {
+		col, row, err := CellNameToCoordinates(topLeft)
+		if err == nil && row <= len(rows) && col <= maxCol {
+			rows[pos[0]][pos[1]] = rows[row-1][col-1]
+		}
+	}
+
 	return rows, nil
 }
 
-// batchOptimizeLevelWithCache performs batch SUMIFS and INDEX-MATCH optimization for a specific level using worksheetCache
-func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) *SubExpressionCache {
+// batchOptimizeLevelWithCache performs batch SUMIFS and INDEX-MATCH optimization for a specific level using worksheetCache,
+// the returned plan holds the pattern counts detected in the level
+func (f *File) batchOptimizeLevelWithCache(ctx context.Context, levelIdx int, levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) (*SubExpressionCache, CalcPlan) {
 	subExprCache := NewSubExpressionCache()
 
 	// 收集当前层的所有公式
@@ -2002,6 +2370,14 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 	uniqueSUMIFSExprs := make(map[string][]string)     // 唯一的 SUMIFS 表达式 -> 使用它的单元格列表
 	indexMatchFormulas := make(map[string]string)      // INDEX-MATCH 公式
 	uniqueIndexMatchExprs := make(map[string][]string) // 唯一的 INDEX-MATCH 表达式 -> 使用它的单元格列表
+	columnAggregateFormulas := make(map[string]string) // 引用整列/单列范围的 MAX/MIN 公式
+	lookupChainFormulas := make(map[string]string)     // IFERROR(VLOOKUP(...),VLOOKUP(...)) 查找链公式
+	vlookupFormulas := make(map[string]string)         // 纯 VLOOKUP 精确匹配公式
+	xlookupFormulas := make(map[string]string)         // 纯 XLOOKUP 精确匹配公式
+	hlookupFormulas := make(map[string]string)         // 纯 HLOOKUP 精确匹配公式
+	lookupFormulas := make(map[string]string)          // 纯 LOOKUP 近似匹配公式
+	sumproductFormulas := make(map[string]string)      // 条件乘积的 SUMPRODUCT 公式
+	countifFormulas := make(map[string]string)         // 单列范围上的纯 COUNTIF/COUNTA 公式
 
 	// 遍历当前层的所有公式
 	for cell := range levelCellsMap {
@@ -2018,6 +2394,46 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 			continue
 		}
 
+		// 检查是否包含单列范围上的 MAX/MIN
+		if len(extractColumnAggregates(formula)) > 0 {
+			columnAggregateFormulas[cell] = formula
+		}
+
+		// 检查是否是两侧都是 VLOOKUP 的 IFERROR/IFNA 查找链
+		if len(extractErrorGuardLookups(formula)) > 0 {
+			lookupChainFormulas[cell] = formula
+		}
+
+		// 检查是否是纯 VLOOKUP 精确匹配
+		if isVLOOKUPFormula(formula) {
+			vlookupFormulas[cell] = formula
+		}
+
+		// 检查是否是纯 XLOOKUP 精确匹配
+		if isXLOOKUPFormula(formula) {
+			xlookupFormulas[cell] = formula
+		}
+
+		// 检查是否是纯 HLOOKUP 精确匹配
+		if isHLOOKUPFormula(formula) {
+			hlookupFormulas[cell] = formula
+		}
+
+		// 检查是否是查找列上的纯 LOOKUP
+		if isLOOKUPFormula(formula) {
+			lookupFormulas[cell] = formula
+		}
+
+		// 检查是否是 SUMPRODUCT((range=cell)*(range=cell)*range) 或其他条件乘积的 SUMPRODUCT
+		if isSUMPRODUCT2DFormula(formula) || isSUMPRODUCTConditionsFormula(formula) {
+			sumproductFormulas[cell] = formula
+		}
+
+		// 检查是否是单列范围上的纯 COUNTIF/COUNTA
+		if isCOUNTIFFormula(formula) {
+			countifFormulas[cell] = formula
+		}
+
 		// 检查是否包含 INDEX-MATCH
 		if strings.Contains(formula, "INDEX(") && strings.Contains(formula, "MATCH(") {
 			indexMatchExpr := extractINDEXMATCHFromFormula(formula)
@@ -2047,6 +2463,9 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 
 			// 无论是纯的还是复合的，都记录这个唯一的表达式
 			uniqueSUMIFSExprs[sumifsExpr] = append(uniqueSUMIFSExprs[sumifsExpr], cell)
+		} else if isPureSUMIFFormula(formula) {
+			// 纯 SUMIF 与单条件 SUMIFS 一起批量计算
+			pureSUMIFS[cell] = strings.TrimSpace(strings.TrimPrefix(formula, "="))
 		}
 	}
 
@@ -2064,60 +2483,111 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 		}
 	}
 
-	// 如果没有 SUMIFS、INDEX-MATCH 和 AVERAGE(OFFSET)，直接返回空缓存
-	if len(pureSUMIFS) == 0 && len(uniqueSUMIFSExprs) == 0 && len(indexMatchFormulas) == 0 && avgOffsetCount == 0 {
-		return subExprCache
+	// 如果没有 SUMIFS、INDEX-MATCH、MAX/MIN 和 AVERAGE(OFFSET)，直接返回空缓存
+	if len(pureSUMIFS) == 0 && len(uniqueSUMIFSExprs) == 0 && len(indexMatchFormulas) == 0 && len(columnAggregateFormulas) == 0 &&
+		len(lookupChainFormulas) == 0 && len(vlookupFormulas) == 0 && len(xlookupFormulas) == 0 && len(hlookupFormulas) == 0 && len(lookupFormulas) == 0 && len(sumproductFormulas) == 0 && len(countifFormulas) == 0 && avgOffsetCount == 0 {
+		return subExprCache, CalcPlan{}
+	}
+	plan := CalcPlan{
+		PureSUMIFS:              len(pureSUMIFS),
+		DistinctSUMIFS:          len(uniqueSUMIFSExprs),
+		INDEXMATCHFormulas:      len(indexMatchFormulas),
+		DistinctINDEXMATCH:      len(uniqueIndexMatchExprs),
+		VLOOKUPFormulas:         len(vlookupFormulas),
+		XLOOKUPFormulas:         len(xlookupFormulas),
+		HLOOKUPFormulas:         len(hlookupFormulas),
+		LOOKUPFormulas:          len(lookupFormulas),
+		SUMPRODUCTFormulas:      len(sumproductFormulas),
+		ColumnAggregateFormulas: len(columnAggregateFormulas),
+		COUNTIFFormulas:         len(countifFormulas),
+		AverageOffsetFormulas:   avgOffsetCount,
 	}
 
-	log.Printf("  ⚡ [Level %d Batch] Found %d pure SUMIFS, %d unique SUMIFS expressions, %d INDEX-MATCH formulas (collect: %v)",
+	f.logger().Debugf("  ⚡ [Level %d Batch] Found %d pure SUMIFS, %d unique SUMIFS expressions, %d INDEX-MATCH formulas (collect: %v)",
 		levelIdx, len(pureSUMIFS), len(uniqueSUMIFSExprs), len(indexMatchFormulas), collectDuration)
 
+	// 复用之前重算保留的子表达式结果：源数据未变的 SUMIFS/AVERAGEIFS 和 INDEX-MATCH 不再批量计算，
+	// 纯 INDEX-MATCH 公式仍由批量计算写回单元格
+	var retainedSUMIFS, retainedIndexMatch map[string]*retainableSubExpr
+	if f.calcTuning.RetainSubExpressions {
+		fingerprinter := f.newSubExprFingerprinter(worksheetCache)
+		retainedSUMIFS = f.reuseRetainedSubExprs(fingerprinter, uniqueSUMIFSExprs, subExprCache)
+		retainedIndexMatch = f.reuseRetainedSubExprs(fingerprinter, uniqueIndexMatchExprs, subExprCache)
+		for expr, entry := range retainedSUMIFS {
+			if entry.reused {
+				delete(uniqueSUMIFSExprs, expr)
+				plan.RetainedSubExpressions++
+			}
+		}
+		for expr, entry := range retainedIndexMatch {
+			if !entry.reused {
+				continue
+			}
+			plan.RetainedSubExpressions++
+			for _, cell := range uniqueIndexMatchExprs[expr] {
+				if isCompositeSubExpr(indexMatchFormulas[cell], expr) {
+					delete(indexMatchFormulas, cell)
+				}
+			}
+		}
+		f.logger().Debugf("  ♻️  [Level %d Batch] Reused %d retained sub-expressions", levelIdx, plan.RetainedSubExpressions)
+	}
+
 	batchStart := time.Now()
 
+	// 各个模式（纯 SUMIFS、每个 SUMIFS 数据源组、INDEX-MATCH、AVERAGE(OFFSET)）之间相互独立：
+	// 它们只读取低层级的结果，写入的 subExprCache / worksheetCache / calcCache 都是并发安全的，
+	// 因此收集为任务后并发执行
+	var batchTasks []func()
+
 	// 批量计算纯 SUMIFS（使用 worksheetCache）
 	if len(pureSUMIFS) >= 10 {
-		batchResults := f.batchCalculateSUMIFSWithCache(pureSUMIFS, worksheetCache)
-		log.Printf("  ⚡ [Level %d Batch] Calculated %d pure SUMIFS", levelIdx, len(batchResults))
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "sumifs", len(pureSUMIFS), func() {
+			batchResults := f.batchCalculateSUMIFSWithCache(pureSUMIFS, worksheetCache)
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d pure SUMIFS", levelIdx, len(batchResults))
 
-		// 将批量结果存入 worksheetCache 和 calcCache
-		storedCount := 0
-		for cell, value := range batchResults {
-			// Store in worksheetCache for subsequent reads
-			// Phase 1: 需要将字符串转换为 formulaArg
-			parts := strings.Split(cell, "!")
-			if len(parts) == 2 {
-				cellType, _ := f.GetCellType(parts[0], parts[1])
-				arg := inferCellValueType(value, cellType)
-				worksheetCache.Set(parts[0], parts[1], arg)
-				storedCount++
-			}
-
-			// Store in calcCache for compatibility
-			cacheKey := cell + "!raw=true"
-			f.calcCache.Store(cacheKey, value)
-		}
-		log.Printf("  ⚡ [Level %d Batch] Stored %d results to worksheetCache", levelIdx, storedCount)
+			// 将批量结果存入 worksheetCache 和 calcCache，并写回 worksheet
+			storedCount := 0
+			for cell, value := range batchResults {
+				// Store in worksheetCache for subsequent reads
+				// Phase 1: 需要将字符串转换为 formulaArg
+				parts := strings.Split(cell, "!")
+				if len(parts) == 2 {
+					cellType, _ := f.GetCellType(parts[0], parts[1])
+					arg := inferCellValueType(value, cellType)
+					worksheetCache.Set(parts[0], parts[1], arg)
+					// 与纯 INDEX-MATCH 一致：写入 <v>，缺失的单元格节点会被创建，<f> 保持不变
+					f.setFormulaValue(parts[0], parts[1], value)
+					storedCount++
+				}
 
-		// 验证缓存是否正确存储（抽样检查）
-		sampleCount := 0
-		for cell := range batchResults {
-			if sampleCount >= 3 {
-				break
+				// Store in calcCache for compatibility
+				cacheKey := cell + "!raw=true"
+				f.calcCache.Store(cacheKey, value)
 			}
-			parts := strings.Split(cell, "!")
-			if len(parts) == 2 {
-				if cached, found := worksheetCache.Get(parts[0], parts[1]); found {
-					val := cached.Value()
-					if len(val) > 20 {
-						val = val[:20]
+			f.logger().Debugf("  ⚡ [Level %d Batch] Stored %d results to worksheetCache", levelIdx, storedCount)
+
+			// 验证缓存是否正确存储（抽样检查）
+			sampleCount := 0
+			for cell := range batchResults {
+				if sampleCount >= 3 {
+					break
+				}
+				parts := strings.Split(cell, "!")
+				if len(parts) == 2 {
+					if cached, found := worksheetCache.Get(parts[0], parts[1]); found {
+						val := cached.Value()
+						if len(val) > 20 {
+							val = val[:20]
+						}
+						f.logger().Debugf("  ✅ [Cache Verify] %s found in cache, value=%s", cell, val)
+					} else {
+						f.logger().Debugf("  ❌ [Cache Verify] %s NOT found in cache!", cell)
 					}
-					log.Printf("  ✅ [Cache Verify] %s found in cache, value=%s", cell, val)
-				} else {
-					log.Printf("  ❌ [Cache Verify] %s NOT found in cache!", cell)
+					sampleCount++
 				}
-				sampleCount++
 			}
-		}
+		}))
 	}
 
 	// 批量计算所有唯一的 SUMIFS 表达式（供复合公式使用）
@@ -2126,15 +2596,15 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 	// 2. 为每个数据源组合预先构建 resultMap
 	// 3. 每个公式使用自己正确的条件值从 resultMap 查询结果
 	if len(uniqueSUMIFSExprs) > 0 {
-		// 按数据源范围分组：key = "sumRange|criteriaRange1|criteriaRange2"
+		// 按数据源范围分组：key = 求和列和所有条件列（不含行号），整体平移若干行的范围属于同一数据源，
+		// 无法按列归一化的范围退回 "sumRange|criteriaRange1|criteriaRange2|..." 并扫描所有行
 		type sumifsGroup struct {
 			sumRangeRef       string
-			criteriaRange1Ref string
-			criteriaRange2Ref string
-			formulas          map[string]struct { // cell -> criteria info
+			criteriaRangeRefs []string
+			exprs             map[string]struct { // expr -> criteria info
 				sheet         string
-				criteria1Cell string
-				criteria2Cell string
+				criteriaCells []string
+				span          [2]int
 			}
 		}
 		groups := make(map[string]*sumifsGroup)
@@ -2146,251 +2616,508 @@ func (f *File) batchOptimizeLevelWithCache(levelIdx int, levelCells []string, gr
 			}
 			inner := expr[7 : len(expr)-1]
 			parts := splitFormulaArgs(inner)
-			if len(parts) != 5 {
+			// 支持 2 个及以上条件：sum_range 后跟成对的 criteria_range, criteria
+			if len(parts) < 5 || len(parts)%2 == 0 {
 				continue
 			}
 
 			sumRange := strings.TrimSpace(parts[0])
-			criteriaRange1 := strings.TrimSpace(parts[1])
-			criteria1Cell := strings.TrimSpace(parts[2])
-			criteriaRange2 := strings.TrimSpace(parts[3])
-			criteria2Cell := strings.TrimSpace(parts[4])
-
-			// 检查是否是支持的模式（外部范围引用 + 本地条件单元格）
-			if !strings.Contains(sumRange, "!") || !strings.Contains(criteriaRange1, "!") || !strings.Contains(criteriaRange2, "!") {
+			var criteriaRanges, criteriaCells []string
+			supported := strings.Contains(sumRange, "!")
+			for i := 1; i < len(parts) && supported; i += 2 {
+				criteriaRange := strings.TrimSpace(parts[i])
+				criteriaCell := strings.TrimSpace(parts[i+1])
+				// 检查是否是支持的模式（外部范围引用 + 本地或其他工作表的条件单元格）
+				supported = strings.Contains(criteriaRange, "!") && isBatchCriteriaArg(criteriaCell)
+				criteriaRanges = append(criteriaRanges, criteriaRange)
+				criteriaCells = append(criteriaCells, criteriaCell)
+			}
+			if !supported || len(cells) == 0 {
 				continue
 			}
-			if strings.Contains(criteria1Cell, "!") || strings.Contains(criteria2Cell, "!") {
+			cellParts := strings.Split(cells[0], "!")
+			if len(cellParts) != 2 {
 				continue
 			}
 
 			// 按数据源分组
-			groupKey := sumRange + "|" + criteriaRange1 + "|" + criteriaRange2
+			groupKey, span, ok := sumifsSourceKey(sumRange, criteriaRanges)
+			if !ok {
+				groupKey, span = sumRange+"|"+strings.Join(criteriaRanges, "|"), [2]int{1, TotalRows}
+			}
 			if groups[groupKey] == nil {
 				groups[groupKey] = &sumifsGroup{
 					sumRangeRef:       sumRange,
-					criteriaRange1Ref: criteriaRange1,
-					criteriaRange2Ref: criteriaRange2,
-					formulas: make(map[string]struct {
+					criteriaRangeRefs: criteriaRanges,
+					exprs: make(map[string]struct {
 						sheet         string
-						criteria1Cell string
-						criteria2Cell string
+						criteriaCells []string
+						span          [2]int
 					}),
 				}
 			}
 
-			// 添加每个使用这个表达式的单元格
-			for _, cell := range cells {
-				cellParts := strings.Split(cell, "!")
-				if len(cellParts) != 2 {
-					continue
-				}
-				groups[groupKey].formulas[cell] = struct {
-					sheet         string
-					criteria1Cell string
-					criteria2Cell string
-				}{
-					sheet:         cellParts[0],
-					criteria1Cell: criteria1Cell,
-					criteria2Cell: criteria2Cell,
-				}
+			// 使用第一个引用这个表达式的单元格的 sheet 解析条件
+			groups[groupKey].exprs[expr] = struct {
+				sheet         string
+				criteriaCells []string
+				span          [2]int
+			}{
+				sheet:         cellParts[0],
+				criteriaCells: criteriaCells,
+				span:          span,
 			}
 		}
 
-		log.Printf("  ⚡ [Level %d Batch SUMIFS] Found %d unique data source patterns for composite formulas", levelIdx, len(groups))
+		f.logger().Debugf("  ⚡ [Level %d Batch SUMIFS] Found %d unique data source patterns for composite formulas", levelIdx, len(groups))
+		plan.SUMIFSSourceGroups = len(groups)
 
-		// 为每个数据源组合预先构建 resultMap 并计算结果
+		// 为每个数据源组合预先构建 resultMap 并计算结果，每个数据源组作为一个独立任务
 		for groupKey, group := range groups {
-			if len(group.formulas) < 5 { // 至少5个公式才值得批量优化
-				continue
+			formulaCount := 0
+			for expr := range group.exprs {
+				formulaCount += len(uniqueSUMIFSExprs[expr])
 			}
-
-			sourceSheet := extractSheetName(group.sumRangeRef)
-			if sourceSheet == "" {
+			if formulaCount < 5 { // 至少5个公式才值得批量优化
 				continue
 			}
 
-			sumCol := extractColumnFromRange(group.sumRangeRef)
-			criteria1Col := extractColumnFromRange(group.criteriaRange1Ref)
-			criteria2Col := extractColumnFromRange(group.criteriaRange2Ref)
-
-			if sumCol == "" || criteria1Col == "" || criteria2Col == "" {
-				continue
-			}
+			batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "sumifs_group", formulaCount, func() {
+				sourceSheet := extractSheetName(group.sumRangeRef)
+				if sourceSheet == "" {
+					return
+				}
 
-			// 获取数据源 - 直接从文件读取原始数据
-			// 注意：worksheetCache 只存储计算结果，不存储原始数据
-			// 所以这里必须从文件读取
-			rows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
-			if err != nil {
-				continue
-			}
+				sumCol := extractColumnFromRange(group.sumRangeRef)
+				criteriaCols := make([]string, len(group.criteriaRangeRefs))
+				for i, ref := range group.criteriaRangeRefs {
+					if criteriaCols[i] = extractColumnFromRange(ref); criteriaCols[i] == "" {
+						return
+					}
+				}
+				if sumCol == "" {
+					return
+				}
 
-			// 构建 resultMap (只扫描一次)
-			resultMap := f.scanRowsAndBuildResultMap(sourceSheet, rows, sumCol, criteria1Col, criteria2Col)
+				// 获取数据源 - 直接从文件读取原始数据
+				// 注意：worksheetCache 只存储计算结果，不存储原始数据
+				// 所以这里必须从文件读取
+				rows, err := f.getCachedRawRows(sourceSheet)
+				if err != nil {
+					return
+				}
+				sumColIdx, _ := ColumnNameToNumber(sumCol)
+				rows = f.sumifsTypedRows(sourceSheet, rows, sumColIdx)
 
-			// 为每个公式计算结果
-			calculatedCount := 0
-			for _, info := range group.formulas {
-				criteria1CellClean := strings.ReplaceAll(info.criteria1Cell, "$", "")
-				criteria2CellClean := strings.ReplaceAll(info.criteria2Cell, "$", "")
+				// 平移后不影响匹配行的行范围共享同一次扫描
+				spans := make(map[[2]int]bool)
+				for _, info := range group.exprs {
+					spans[info.span] = true
+				}
+				scanSpans, _ := shiftedSUMIFSScanSpans(rows, sumColIdx-1, spans)
+
+				// 构建 resultMap (每个扫描范围只扫描一次)：2 个条件使用二维 map，3 个及以上使用组合键 map
+				lookups := make(map[[2]int]func(criteria []sumifsCriterion) float64)
+				lookupFor := func(span [2]int) func(criteria []sumifsCriterion) float64 {
+					scanSpan := scanSpans[span]
+					if lookup, ok := lookups[scanSpan]; ok {
+						return lookup
+					}
+					spanRows := rowsInSpan(rows, scanSpan)
+					var lookup func(criteria []sumifsCriterion) float64
+					if len(criteriaCols) == 2 && isSameSUMIFSColumn(group.criteriaRangeRefs[0], group.criteriaRangeRefs[1]) {
+						// 两个条件限定同一列的上下界，如日期区间，按区间扫描
+						resultMap := make(map[string]float64)
+						criteriaColIdx, _ := ColumnNameToNumber(criteriaCols[0])
+						accumulateSUMIFS1D(resultMap, spanRows, sumColIdx-1, criteriaColIdx-1)
+						window := newSUMIFSWindow(resultMap)
+						lookup = func(criteria []sumifsCriterion) float64 { return window.sum(criteria[0], criteria[1]) }
+					} else if len(criteriaCols) == 2 {
+						resultMap := f.scanRowsAndBuildResultMap(sourceSheet, spanRows, sumCol, criteriaCols[0], criteriaCols[1])
+						lookup = func(criteria []sumifsCriterion) float64 { return sumifs2DSum(resultMap, criteria[0], criteria[1]) }
+					} else {
+						resultMap := scanRowsAndBuildNDResultMap(spanRows, sumCol, criteriaCols)
+						lookup = func(criteria []sumifsCriterion) float64 { return sumifsNDSum(resultMap, criteria) }
+					}
+					lookups[scanSpan] = lookup
+					return lookup
+				}
 
-				// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "-"）
-				c1 := f.resolveCriteriaValue(info.sheet, criteria1CellClean, worksheetCache)
-				c2 := f.resolveCriteriaValue(info.sheet, criteria2CellClean, worksheetCache)
+				// 为每个表达式计算结果
+				calculatedCount := 0
+				for expr, info := range group.exprs {
+					// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "-"）
+					values := make([]string, len(info.criteriaCells))
+					for i, criteriaCell := range info.criteriaCells {
+						values[i] = f.sumifsCriteriaValue(info.sheet, strings.ReplaceAll(criteriaCell, "$", ""), worksheetCache)
+					}
 
-				var result float64 = 0
-				if resultMap[c1] != nil {
-					if val, ok := resultMap[c1][c2]; ok {
-						result = val
+					// 比较运算符和通配符条件匹配 resultMap 的键，无法分类的条件逐个单元格计算
+					criteria, ok := parseSUMIFSCriteria(values...)
+					if !ok {
+						continue
 					}
-				}
 
-				// 构造原始表达式 key 用于 subExprCache
-				exprKey := fmt.Sprintf("SUMIFS(%s,%s,%s,%s,%s)",
-					group.sumRangeRef, group.criteriaRange1Ref, info.criteria1Cell,
-					group.criteriaRange2Ref, info.criteria2Cell)
-				subExprCache.Store(exprKey, fmt.Sprintf("%.0f", result))
-				calculatedCount++
-			}
+					// 以原始表达式为 key 存入 subExprCache，与复合公式中提取的表达式一致
+					subExprCache.Store(expr, fmt.Sprintf("%.0f", lookupFor(info.span)(criteria)))
+					calculatedCount += len(uniqueSUMIFSExprs[expr])
+				}
 
-			log.Printf("  ⚡ [Level %d Batch SUMIFS] Pattern %s: calculated %d formulas", levelIdx, groupKey[:min(40, len(groupKey))], calculatedCount)
+				f.logger().Debugf("  ⚡ [Level %d Batch SUMIFS] Pattern %s: calculated %d formulas with %d scans", levelIdx, groupKey[:min(40, len(groupKey))], calculatedCount, len(lookups))
+			}))
 		}
 	}
 
 	// 批量计算 INDEX-MATCH 公式（使用 worksheetCache）
 	if len(indexMatchFormulas) >= 10 {
-		indexMatchStart := time.Now()
-		batchResults := f.batchCalculateINDEXMATCHWithCache(indexMatchFormulas, worksheetCache)
-		indexMatchCalcDuration := time.Since(indexMatchStart)
-		log.Printf("  ⚡ [Level %d Batch] Calculated %d INDEX-MATCH formulas in %v",
-			levelIdx, len(batchResults), indexMatchCalcDuration)
-
-		// 将 INDEX-MATCH 结果存入 worksheetCache 和 calcCache（仅针对纯 INDEX-MATCH 公式）
-		// 对于复合公式（如 IF(INDEX-MATCH=0, ...)），只存入 SubExpressionCache
-		cacheStoreStart := time.Now()
-		pureIndexMatchCount := 0
-		for cell, value := range batchResults {
-			node, exists := graph.nodes[cell]
-			if !exists {
-				continue
-			}
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "index_match", len(indexMatchFormulas), func() {
+			indexMatchStart := time.Now()
+			batchResults := f.batchCalculateINDEXMATCHWithCache(indexMatchFormulas, worksheetCache)
+			indexMatchCalcDuration := time.Since(indexMatchStart)
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d INDEX-MATCH formulas in %v",
+				levelIdx, len(batchResults), indexMatchCalcDuration)
+
+			// 将 INDEX-MATCH 结果存入 worksheetCache 和 calcCache（仅针对纯 INDEX-MATCH 公式）
+			// 对于复合公式（如 IF(INDEX-MATCH=0, ...)），只存入 SubExpressionCache
+			cacheStoreStart := time.Now()
+			pureIndexMatchCount := 0
+			for cell, value := range batchResults {
+				node, exists := graph.nodes[cell]
+				if !exists {
+					continue
+				}
 
-			// 提取 INDEX-MATCH 表达式
-			indexMatchExpr := extractINDEXMATCHFromFormula(node.formula)
-			if indexMatchExpr == "" {
-				continue
-			}
+				// 提取 INDEX-MATCH 表达式
+				indexMatchExpr := extractINDEXMATCHFromFormula(node.formula)
+				if indexMatchExpr == "" {
+					continue
+				}
 
-			// 检查是否是纯 INDEX-MATCH（整个公式就是 INDEX-MATCH）
-			cleanFormula := strings.TrimSpace(strings.TrimPrefix(node.formula, "="))
-			// 移除可能的 IFERROR 包装
-			if strings.HasPrefix(cleanFormula, "IFERROR(") {
-				// 提取 IFERROR 的第一个参数
-				inner := strings.TrimPrefix(cleanFormula, "IFERROR(")
-				if commaIdx := strings.LastIndex(inner, ","); commaIdx > 0 {
-					cleanFormula = strings.TrimSpace(inner[:commaIdx])
+				// 检查是否是纯 INDEX-MATCH（整个公式就是 INDEX-MATCH）
+				cleanFormula := strings.TrimSpace(strings.TrimPrefix(node.formula, "="))
+				// 移除可能的错误保护包装：IFERROR / IFNA / IF(ISERROR(...)) / IF(ISNA(...))
+				// 被保护捕获的错误（如查找不到的 #N/A）不存入缓存，由 DAG scheduler 计算默认值
+				trapped := false
+				if guard := extractErrorGuard(cleanFormula); guard != nil {
+					cleanFormula, trapped = guard.expr, guard.traps(value)
+				} else if strings.HasPrefix(cleanFormula, "IFERROR(") {
+					// 提取 IFERROR 的第一个参数
+					inner := strings.TrimPrefix(cleanFormula, "IFERROR(")
+					if commaIdx := strings.LastIndex(inner, ","); commaIdx > 0 {
+						cleanFormula = strings.TrimSpace(inner[:commaIdx])
+					}
+					trapped = isFormulaErrorValue(value)
 				}
-			}
-			cleanExpr := strings.TrimSpace(indexMatchExpr)
-			parts := strings.Split(cell, "!")
+				if trapped {
+					continue
+				}
+				cleanExpr := strings.TrimSpace(indexMatchExpr)
+				parts := strings.Split(cell, "!")
 
-			// 只有纯 INDEX-MATCH 公式才存入 worksheetCache 和 calcCache
-			// 复合公式（如 IF(IFERROR(INDEX-MATCH...),0)=0,"断货",SUMIFS(...))）
-			// 只把 INDEX-MATCH 子表达式结果存入 subExprCache，让 DAG scheduler 重新计算完整公式
-			if cleanFormula == cleanExpr || cleanFormula == "IFERROR("+cleanExpr {
-				// 纯 INDEX-MATCH - 存入 worksheetCache 和 calcCache，并写入 worksheet
-				if len(parts) == 2 {
-					cellType, _ := f.GetCellType(parts[0], parts[1])
-					arg := inferCellValueType(value, cellType)
-					worksheetCache.Set(parts[0], parts[1], arg)
-					// 关键修复：写入实际的 worksheet 数据结构
-					f.setFormulaValue(parts[0], parts[1], value)
+				// 只有纯 INDEX-MATCH 公式才存入 worksheetCache 和 calcCache
+				// 复合公式（如 IF(IFERROR(INDEX-MATCH...),0)=0,"断货",SUMIFS(...))）
+				// 只把 INDEX-MATCH 子表达式结果存入 subExprCache，让 DAG scheduler 重新计算完整公式
+				if cleanFormula == cleanExpr || cleanFormula == "IFERROR("+cleanExpr {
+					// 纯 INDEX-MATCH - 存入 worksheetCache 和 calcCache，并写入 worksheet
+					if len(parts) == 2 {
+						cellType, _ := f.GetCellType(parts[0], parts[1])
+						arg := inferCellValueType(value, cellType)
+						worksheetCache.Set(parts[0], parts[1], arg)
+						// 关键修复：写入实际的 worksheet 数据结构
+						f.setFormulaValue(parts[0], parts[1], value)
+					}
+					cacheKey := cell + "!raw=true"
+					f.calcCache.Store(cacheKey, value)
+					pureIndexMatchCount++
 				}
-				cacheKey := cell + "!raw=true"
-				f.calcCache.Store(cacheKey, value)
-				pureIndexMatchCount++
+				// 复合公式 - 不存入 worksheetCache 和 calcCache，只存入 subExprCache（后面处理）
 			}
-			// 复合公式 - 不存入 worksheetCache 和 calcCache，只存入 subExprCache（后面处理）
-		}
-		cacheStoreDuration := time.Since(cacheStoreStart)
-		log.Printf("  📊 [Level %d Batch] Stored %d pure INDEX-MATCH in calcCache (skipped %d composite)",
-			levelIdx, pureIndexMatchCount, len(batchResults)-pureIndexMatchCount)
-
-		// 构建反向映射：expr -> cell（避免双重循环）
-		exprToCellStart := time.Now()
-		exprToCell := make(map[string]string)
-		for cell := range indexMatchFormulas {
-			expr := extractINDEXMATCHFromFormula(graph.nodes[cell].formula)
-			if expr != "" {
-				if _, exists := exprToCell[expr]; !exists {
-					exprToCell[expr] = cell
+			cacheStoreDuration := time.Since(cacheStoreStart)
+			f.logger().Debugf("  📊 [Level %d Batch] Stored %d pure INDEX-MATCH in calcCache (skipped %d composite)",
+				levelIdx, pureIndexMatchCount, len(batchResults)-pureIndexMatchCount)
+
+			// 构建反向映射：expr -> cell（避免双重循环）
+			exprToCellStart := time.Now()
+			exprToCell := make(map[string]string)
+			for cell := range indexMatchFormulas {
+				expr := extractINDEXMATCHFromFormula(graph.nodes[cell].formula)
+				if expr != "" {
+					if _, exists := exprToCell[expr]; !exists {
+						exprToCell[expr] = cell
+					}
 				}
 			}
-		}
 
-		// 将 INDEX-MATCH 表达式存入 SubExpressionCache（供复合公式使用）
-		for expr, cell := range exprToCell {
-			if value, ok := batchResults[cell]; ok {
-				subExprCache.Store(expr, value)
+			// 将 INDEX-MATCH 表达式存入 SubExpressionCache（供复合公式使用）
+			for expr, cell := range exprToCell {
+				if value, ok := batchResults[cell]; ok {
+					subExprCache.Store(expr, value)
+				}
 			}
-		}
-		exprToCellDuration := time.Since(exprToCellStart)
-
-		log.Printf("  📊 [Level %d Batch] Cache store: %v, SubExpr mapping: %v",
-			levelIdx, cacheStoreDuration, exprToCellDuration)
-	}
+			exprToCellDuration := time.Since(exprToCellStart)
 
-	// 批量计算 AVERAGE(OFFSET) 公式（使用 worksheetCache）
-	// 收集 AVERAGE(OFFSET) 公式
-	avgOffsetFormulas := make(map[string]string)
-	for cell := range levelCellsMap {
-		node, exists := graph.nodes[cell]
-		if !exists {
-			continue
-		}
-		formula := node.formula
-		if isAverageOffsetFormula(formula) {
-			avgOffsetFormulas[cell] = formula
-		}
+			f.logger().Debugf("  📊 [Level %d Batch] Cache store: %v, SubExpr mapping: %v",
+				levelIdx, cacheStoreDuration, exprToCellDuration)
+		}))
 	}
 
-	if len(avgOffsetFormulas) >= 5 {
-		avgOffsetStart := time.Now()
-		batchResults := f.batchCalculateAverageOffsetWithCache(avgOffsetFormulas, worksheetCache)
-		avgOffsetDuration := time.Since(avgOffsetStart)
-		log.Printf("  ⚡ [Level %d Batch] Calculated %d AVERAGE(OFFSET) formulas in %v",
-			levelIdx, len(batchResults), avgOffsetDuration)
-
-		// 将 AVERAGE(OFFSET) 结果存入 worksheetCache 和 calcCache
-		for cell, value := range batchResults {
-			parts := strings.Split(cell, "!")
-			if len(parts) == 2 {
+	// 批量计算纯 VLOOKUP 公式：相同查找表的首列只索引一次
+	if len(vlookupFormulas) >= 10 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "vlookup", len(vlookupFormulas), func() {
+			vlookupStart := time.Now()
+			batchResults, tables := f.batchCalculateVLOOKUPWithCache(vlookupFormulas, worksheetCache)
+			plan.VLOOKUPTables = tables // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d VLOOKUP formulas over %d tables in %v",
+				levelIdx, len(batchResults), tables, time.Since(vlookupStart))
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
 				cellType, _ := f.GetCellType(parts[0], parts[1])
-				valueStr := fmt.Sprintf("%g", value)
-				arg := inferCellValueType(valueStr, cellType)
-				worksheetCache.Set(parts[0], parts[1], arg)
-				// 写入实际的 worksheet 数据结构
-				f.setFormulaValue(parts[0], parts[1], valueStr)
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, cellType))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
 			}
-			cacheKey := cell + "!raw=true"
-			f.calcCache.Store(cacheKey, fmt.Sprintf("%g", value))
-		}
+		}))
 	}
 
-	batchDuration := time.Since(batchStart)
-	log.Printf("  ✅ [Level %d Batch] Completed in %v, cache size: %d", levelIdx, batchDuration, subExprCache.Len())
+	// 批量计算纯 XLOOKUP 公式：相同查找列只索引一次
+	if len(xlookupFormulas) >= 10 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "xlookup", len(xlookupFormulas), func() {
+			xlookupStart := time.Now()
+			batchResults, columns := f.batchCalculateXLOOKUPWithCache(xlookupFormulas, worksheetCache)
+			plan.XLOOKUPColumns = columns // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d XLOOKUP formulas over %d lookup columns in %v",
+				levelIdx, len(batchResults), columns, time.Since(xlookupStart))
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				cellType, _ := f.GetCellType(parts[0], parts[1])
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, cellType))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// 批量计算纯 HLOOKUP 公式：相同查找表的首行只索引一次
+	if len(hlookupFormulas) >= 10 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "hlookup", len(hlookupFormulas), func() {
+			hlookupStart := time.Now()
+			batchResults, tables := f.batchCalculateHLOOKUPWithCache(hlookupFormulas, worksheetCache)
+			plan.HLOOKUPTables = tables // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d HLOOKUP formulas over %d tables in %v",
+				levelIdx, len(batchResults), tables, time.Since(hlookupStart))
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				cellType, _ := f.GetCellType(parts[0], parts[1])
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, cellType))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// 批量计算纯 LOOKUP 公式：已排序的查找列只扫描一次，之后二分查找
+	if len(lookupFormulas) >= 10 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "lookup", len(lookupFormulas), func() {
+			lookupStart := time.Now()
+			batchResults, vectors := f.batchCalculateLOOKUPWithCache(lookupFormulas, worksheetCache)
+			plan.LOOKUPVectors = vectors // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d LOOKUP formulas over %d lookup vectors in %v",
+				levelIdx, len(batchResults), vectors, time.Since(lookupStart))
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				cellType, _ := f.GetCellType(parts[0], parts[1])
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, cellType))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// 批量计算两个条件的 SUMPRODUCT 公式：相同范围的公式共享一次扫描
+	if len(sumproductFormulas) >= 10 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "sumproduct", len(sumproductFormulas), func() {
+			sumproductStart := time.Now()
+			batchResults, patterns := f.batchCalculateSUMPRODUCT2DWithCache(sumproductFormulas, worksheetCache)
+			conditionsResults, conditionsPatterns := f.batchCalculateSUMPRODUCTConditionsWithCache(sumproductFormulas, worksheetCache)
+			for cell, value := range conditionsResults {
+				batchResults[cell] = value
+			}
+			patterns += conditionsPatterns
+			plan.SUMPRODUCTPatterns = patterns // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d SUMPRODUCT formulas over %d patterns in %v",
+				levelIdx, len(batchResults), patterns, time.Since(sumproductStart))
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, CellTypeNumber))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// 批量计算 AVERAGE(OFFSET) 公式（使用 worksheetCache）
+	// 收集 AVERAGE(OFFSET) 公式
+	avgOffsetFormulas := make(map[string]string)
+	for cell := range levelCellsMap {
+		node, exists := graph.nodes[cell]
+		if !exists {
+			continue
+		}
+		formula := node.formula
+		if isAverageOffsetFormula(formula) {
+			avgOffsetFormulas[cell] = formula
+		}
+	}
+
+	if len(avgOffsetFormulas) >= 5 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "average_offset", len(avgOffsetFormulas), func() {
+			avgOffsetStart := time.Now()
+			batchResults := f.batchCalculateAverageOffsetWithCache(avgOffsetFormulas, worksheetCache)
+			avgOffsetDuration := time.Since(avgOffsetStart)
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d AVERAGE(OFFSET) formulas in %v",
+				levelIdx, len(batchResults), avgOffsetDuration)
+
+			// 将 AVERAGE(OFFSET) 结果存入 worksheetCache 和 calcCache
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) == 2 {
+					cellType, _ := f.GetCellType(parts[0], parts[1])
+					valueStr := fmt.Sprintf("%g", value)
+					arg := inferCellValueType(valueStr, cellType)
+					worksheetCache.Set(parts[0], parts[1], arg)
+					// 写入实际的 worksheet 数据结构
+					f.setFormulaValue(parts[0], parts[1], valueStr)
+				}
+				cacheKey := cell + "!raw=true"
+				f.calcCache.Store(cacheKey, fmt.Sprintf("%g", value))
+			}
+		}))
+	}
+
+	// 批量计算单列范围上的 MAX/MIN：相同范围只扫描一次
+	// 纯 MAX/MIN 公式直接写回结果，复合公式只存入 subExprCache，由 DAG scheduler 替换后计算
+	if len(columnAggregateFormulas) > 0 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "column_aggregate", len(columnAggregateFormulas), func() {
+			batchResults, scans := f.batchCalculateColumnAggregatesWithCache(columnAggregateFormulas, worksheetCache)
+			plan.ColumnAggregateRanges = scans // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d MAX/MIN expressions with %d range scans", levelIdx, len(batchResults), scans)
+			for cell, formula := range columnAggregateFormulas {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				exprs := extractColumnAggregates(formula)
+				for _, expr := range exprs {
+					if value, ok := batchResults[columnAggregateKey(parts[0], expr)]; ok {
+						subExprCache.Store(columnAggregateKey(parts[0], expr), value)
+					}
+				}
+				if len(exprs) != 1 || strings.TrimSpace(strings.TrimPrefix(formula, "=")) != exprs[0] {
+					continue
+				}
+				value, ok := batchResults[columnAggregateKey(parts[0], exprs[0])]
+				if !ok {
+					continue
+				}
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, CellTypeNumber))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// 批量计算单列范围上的纯 COUNTIF/COUNTA 公式：相同范围只扫描一次
+	if len(countifFormulas) > 0 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "countif", len(countifFormulas), func() {
+			batchResults, ranges := f.batchCalculateCOUNTIFWithCache(countifFormulas, worksheetCache)
+			plan.COUNTIFRanges = ranges // runBatchTasks 返回前写入，之后才读取
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d COUNTIF/COUNTA formulas with %d range scans", levelIdx, len(batchResults), ranges)
+			for cell, value := range batchResults {
+				parts := strings.Split(cell, "!")
+				if len(parts) != 2 {
+					continue
+				}
+				worksheetCache.Set(parts[0], parts[1], inferCellValueType(value, CellTypeNumber))
+				f.calcCache.Store(cell+"!raw=true", value)
+				f.setFormulaValue(parts[0], parts[1], value)
+			}
+		}))
+	}
+
+	// IFERROR/IFNA 查找链：两侧的 VLOOKUP 都写入缓存，由 foldErrorGuard 使用缓存结果求值
+	if len(lookupChainFormulas) > 0 {
+		batchTasks = append(batchTasks, f.tracedBatchTask(ctx, "lookup_chain", len(lookupChainFormulas), func() {
+			batchResults := f.batchCalculateErrorGuardLookupsWithCache(lookupChainFormulas, worksheetCache)
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d VLOOKUP expressions of %d lookup chains", levelIdx, len(batchResults), len(lookupChainFormulas))
+			for key, value := range batchResults {
+				subExprCache.Store(key, value)
+			}
+		}))
+	}
+
+	runBatchTasks(batchTasks, maxConcurrentBatchPatterns)
+	f.retainSubExprs(retainedSUMIFS, subExprCache)
+	f.retainSubExprs(retainedIndexMatch, subExprCache)
+
+	batchDuration := time.Since(batchStart)
+	f.logger().Debugf("  ✅ [Level %d Batch] Completed in %v, cache size: %d", levelIdx, batchDuration, subExprCache.Len())
 
 	// 添加详细统计：哪些公式被批量优化了，哪些没有
-	optimizedCount := len(pureSUMIFS) + len(indexMatchFormulas) + len(avgOffsetFormulas)
+	optimizedCount := len(pureSUMIFS) + len(indexMatchFormulas) + len(columnAggregateFormulas) + len(lookupChainFormulas) + len(vlookupFormulas) + len(xlookupFormulas) + len(hlookupFormulas) + len(lookupFormulas) + len(sumproductFormulas) + len(countifFormulas) + len(avgOffsetFormulas)
 	totalCount := len(levelCells)
 	unoptimizedCount := totalCount - optimizedCount
 
-	log.Printf("  📊 [Level %d Stats] Total: %d, Optimized: %d (%.1f%%), Unoptimized: %d (%.1f%%)",
+	f.logger().Debugf("  📊 [Level %d Stats] Total: %d, Optimized: %d (%.1f%%), Unoptimized: %d (%.1f%%)",
 		levelIdx, totalCount, optimizedCount, float64(optimizedCount)*100/float64(totalCount),
 		unoptimizedCount, float64(unoptimizedCount)*100/float64(totalCount))
 
-	return subExprCache
+	return subExprCache, plan
+}
+
+// maxConcurrentBatchPatterns limits how many independent batch patterns of a
+// level are calculated at the same time. Each pattern scan is already
+// internally parallel, so a small outer bound avoids oversubscribing the CPU.
+const maxConcurrentBatchPatterns = 4
+
+// runBatchTasks runs independent batch pattern tasks with bounded concurrency
+// and waits for all of them to finish.
+func runBatchTasks(tasks []func(), limit int) {
+	if len(tasks) == 0 {
+		return
+	}
+	if limit <= 1 || len(tasks) == 1 {
+		for _, task := range tasks {
+			task()
+		}
+		return
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(task func()) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			task()
+		}(task)
+	}
+	wg.Wait()
 }
 
 // batchOptimizeLevel performs batch SUMIFS optimization for a specific level
@@ -2442,7 +3169,7 @@ func (f *File) batchOptimizeLevel(levelIdx int, levelCells []string, graph *depe
 		return subExprCache
 	}
 
-	log.Printf("  ⚡ [Level %d Batch] Found %d pure SUMIFS, %d unique SUMIFS expressions",
+	f.logger().Debugf("  ⚡ [Level %d Batch] Found %d pure SUMIFS, %d unique SUMIFS expressions",
 		levelIdx, len(pureSUMIFS), len(uniqueSUMIFSExprs))
 
 	batchStart := time.Now()
@@ -2450,7 +3177,7 @@ func (f *File) batchOptimizeLevel(levelIdx int, levelCells []string, graph *depe
 	// 批量计算纯 SUMIFS
 	if len(pureSUMIFS) >= 10 {
 		batchResults := f.batchCalculateSUMIFS(pureSUMIFS)
-		log.Printf("  ⚡ [Level %d Batch] Calculated %d pure SUMIFS", levelIdx, len(batchResults))
+		f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d pure SUMIFS", levelIdx, len(batchResults))
 
 		// 将批量结果存入 calcCache
 		for cell, value := range batchResults {
@@ -2480,7 +3207,7 @@ func (f *File) batchOptimizeLevel(levelIdx int, levelCells []string, graph *depe
 		// 批量计算这些子表达式
 		if len(tempFormulas) >= 10 {
 			batchResults := f.batchCalculateSUMIFS(tempFormulas)
-			log.Printf("  ⚡ [Level %d Batch] Calculated %d SUMIFS sub-expressions", levelIdx, len(batchResults))
+			f.logger().Debugf("  ⚡ [Level %d Batch] Calculated %d SUMIFS sub-expressions", levelIdx, len(batchResults))
 
 			// 将子表达式结果存入 SubExpressionCache
 			for tempCell, value := range batchResults {
@@ -2495,7 +3222,7 @@ func (f *File) batchOptimizeLevel(levelIdx int, levelCells []string, graph *depe
 	}
 
 	batchDuration := time.Since(batchStart)
-	log.Printf("  ✅ [Level %d Batch] Completed in %v, cache size: %d", levelIdx, batchDuration, subExprCache.Len())
+	f.logger().Debugf("  ✅ [Level %d Batch] Completed in %v, cache size: %d", levelIdx, batchDuration, subExprCache.Len())
 
 	return subExprCache
 }
@@ -2554,10 +3281,41 @@ func parseCell(cellRef string) (int, int) {
 	return row, col
 }
 
+// batchPatternOf returns the name of the batch pattern calculating a formula
+// at the level, or an empty string if the formula is a simple formula.
+func batchPatternOf(formula string) string {
+	switch {
+	// AVERAGE(OFFSET(...MATCH...)) 包含 MATCH，需要先于 INDEX-MATCH 检查
+	case isAverageOffsetFormula(formula):
+		return "AVERAGE-OFFSET"
+	case extractSUMIFSFromFormula(formula) != "" || extractAVERAGEIFSFromFormula(formula) != "" || isPureSUMIFFormula(formula):
+		return "SUMIFS"
+	case strings.Contains(formula, "INDEX(") && strings.Contains(formula, "MATCH("):
+		return "INDEX-MATCH"
+	case len(extractColumnAggregates(formula)) > 0:
+		return "MAX/MIN"
+	case len(extractErrorGuardLookups(formula)) > 0:
+		return "LOOKUP-CHAIN"
+	case isVLOOKUPFormula(formula):
+		return "VLOOKUP"
+	case isXLOOKUPFormula(formula):
+		return "XLOOKUP"
+	case isHLOOKUPFormula(formula):
+		return "HLOOKUP"
+	case isLOOKUPFormula(formula):
+		return "LOOKUP"
+	case isSUMPRODUCT2DFormula(formula) || isSUMPRODUCTConditionsFormula(formula):
+		return "SUMPRODUCT"
+	case isCOUNTIFFormula(formula):
+		return "COUNTIF"
+	}
+	return ""
+}
+
 // preCalculateSimpleFormulas 预先计算当前层中的"简单公式"
 // 简单公式是指非 SUMIFS/AVERAGEIFS/INDEX-MATCH 的公式，如 MAX, SUM, 算术运算等
-// 这些公式的结果会被后续的批量优化使用
-func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) int {
+// 这些公式的结果会被后续的批量优化使用，ctx 取消后剩余的公式不再计算
+func (f *File) preCalculateSimpleFormulas(ctx context.Context, levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) int {
 	// 识别简单公式（非批量优化类型）
 	simpleFormulas := make([]string, 0)
 
@@ -2569,24 +3327,7 @@ func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependency
 		formula := node.formula
 
 		// 检查是否是批量优化类型
-		isBatchType := false
-
-		// SUMIFS/AVERAGEIFS
-		if extractSUMIFSFromFormula(formula) != "" || extractAVERAGEIFSFromFormula(formula) != "" {
-			isBatchType = true
-		}
-
-		// INDEX-MATCH
-		if strings.Contains(formula, "INDEX(") && strings.Contains(formula, "MATCH(") {
-			isBatchType = true
-		}
-
-		// AVERAGE(OFFSET)
-		if isAverageOffsetFormula(formula) {
-			isBatchType = true
-		}
-
-		if !isBatchType {
+		if batchPatternOf(formula) == "" {
 			simpleFormulas = append(simpleFormulas, cell)
 		}
 	}
@@ -2610,9 +3351,10 @@ func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependency
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	calculatedCount := 0
+	profile := calcProfileFromContext(ctx)
 
 	// 使用 worker pool
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numWorkers > len(simpleFormulas) {
 		numWorkers = len(simpleFormulas)
 	}
@@ -2629,6 +3371,9 @@ func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependency
 			defer wg.Done()
 
 			for cell := range cellChan {
+				if ctx.Err() != nil {
+					continue
+				}
 				parts := strings.Split(cell, "!")
 				if len(parts) != 2 {
 					continue
@@ -2645,8 +3390,13 @@ func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependency
 
 				// 计算公式
 				opts := Options{RawCellValue: true, MaxCalcIterations: 100}
+				start := time.Now()
 				value, err := f.CalcCellValueWithSubExprCache(sheet, cellName, formula, nil, worksheetCache, opts)
+				if profile != nil {
+					profile.addFormula(cell, formula, time.Since(start))
+				}
 				if err != nil {
+					reportCalcFailure(ctx, cell, formula, value, err)
 					continue
 				}
 
@@ -2677,6 +3427,13 @@ func (f *File) preCalculateSimpleFormulas(levelCells []string, graph *dependency
 //  3. 过滤依赖图，只保留受影响的公式
 //  4. 复用 calculateByDAG 进行分层并行计算
 func (f *File) RecalculateAffectedByColumns(updatedColumns map[string]bool) error {
+	return f.RecalculateAffectedByColumnsWithContext(context.Background(), updatedColumns)
+}
+
+// RecalculateAffectedByColumnsWithContext 是 RecalculateAffectedByColumns 的可取消版本，
+// 在依赖图构建、BFS 传播和 DAG 分层计算过程中检查 ctx，取消后尽快返回 ctx.Err()。
+// 取消时已计算的公式保留新值，其余公式保留旧值。
+func (f *File) RecalculateAffectedByColumnsWithContext(ctx context.Context, updatedColumns map[string]bool) error {
 	if len(updatedColumns) == 0 {
 		return nil
 	}
@@ -2684,33 +3441,46 @@ func (f *File) RecalculateAffectedByColumns(updatedColumns map[string]bool) erro
 	f.recalcMu.Lock()
 	defer f.recalcMu.Unlock()
 
-	log.Printf("📊 [IncrementalRecalc] Starting incremental recalculation")
-	log.Printf("  📋 Updated columns: %v", updatedColumns)
+	f.logger().Infof("📊 [IncrementalRecalc] Starting incremental recalculation")
+	f.logger().Debugf("  📋 Updated columns: %v", updatedColumns)
 	startTime := time.Now()
 
 	// ========================================
-	// 步骤1：构建完整依赖图
+	// 步骤1：构建完整依赖图（公式未变化时复用缓存）
 	// ========================================
-	graph := f.buildDependencyGraph()
+	graph, err := f.cachedDependencyGraph(ctx)
+	if err != nil {
+		return err
+	}
 	if len(graph.nodes) == 0 {
-		log.Printf("  ⚠️  No formulas found, skipping recalculation")
+		f.logger().Debugf("  ⚠️  No formulas found, skipping recalculation")
 		return nil
 	}
 
 	// ========================================
 	// 步骤2：找出所有受影响的公式（BFS传播）
 	// ========================================
-	affectedCells := f.findAffectedCellsByColumns(graph, updatedColumns)
-	log.Printf("  📊 Found %d affected formulas (out of %d total)", len(affectedCells), len(graph.nodes))
+	affectedCells, err := f.findAffectedCellsByColumns(ctx, graph, updatedColumns)
+	if err != nil {
+		return err
+	}
+	f.logger().Debugf("  📊 Found %d affected formulas (out of %d total)", len(affectedCells), len(graph.nodes))
 
 	if len(affectedCells) == 0 {
-		log.Printf("  ✅ No affected formulas, skipping recalculation")
+		f.logger().Debugf("  ✅ No affected formulas, skipping recalculation")
 		return nil
 	}
 
-	// 如果受影响的公式超过50%，直接全量重算更快
-	if float64(len(affectedCells)) > float64(len(graph.nodes))*0.5 {
-		log.Printf("  ⚠️  Too many affected formulas (%.1f%%), using full graph for calculation",
+	return f.calculateAffectedCells(ctx, graph, affectedCells, startTime)
+}
+
+// calculateAffectedCells 计算依赖图中受影响的公式：受影响的公式超过阈值（默认一半，
+// 见 SetIncrementalFallbackThreshold）时直接用完整依赖图全量计算，否则只清除受影响
+// 公式的缓存并计算过滤后的依赖图
+func (f *File) calculateAffectedCells(ctx context.Context, graph *dependencyGraph, affectedCells map[string]bool, startTime time.Time) error {
+	// 如果受影响的公式超过阈值，直接全量重算更快
+	if f.incrementalFallback(len(affectedCells), len(graph.nodes), defaultColumnFallbackThreshold) {
+		f.logger().Debugf("  ⚠️  Too many affected formulas (%.1f%%), using full graph for calculation",
 			float64(len(affectedCells))/float64(len(graph.nodes))*100)
 		// 直接使用已构建的 graph 进行计算，避免重复构建和死锁
 		// 清除所有缓存
@@ -2719,9 +3489,11 @@ func (f *File) RecalculateAffectedByColumns(updatedColumns map[string]bool) erro
 			return true
 		})
 		f.rangeCache.Clear()
-		f.calculateByDAG(graph)
+		if err := f.calculateByDAGWithContext(ctx, graph); err != nil {
+			return err
+		}
 		duration := time.Since(startTime)
-		log.Printf("✅ [IncrementalRecalc] Completed (full) in %v", duration)
+		f.logger().Infof("✅ [IncrementalRecalc] Completed (full) in %v", duration)
 		return nil
 	}
 
@@ -2729,7 +3501,7 @@ func (f *File) RecalculateAffectedByColumns(updatedColumns map[string]bool) erro
 	// 步骤3：过滤依赖图，只保留受影响的公式
 	// ========================================
 	filteredGraph := f.filterDependencyGraph(graph, affectedCells)
-	log.Printf("  📊 Filtered graph: %d formulas, %d levels", len(filteredGraph.nodes), len(filteredGraph.levels))
+	f.logger().Debugf("  📊 Filtered graph: %d formulas, %d levels", len(filteredGraph.nodes), len(filteredGraph.levels))
 
 	// ========================================
 	// 步骤4：只清除受影响公式的缓存
@@ -2744,15 +3516,17 @@ func (f *File) RecalculateAffectedByColumns(updatedColumns map[string]bool) erro
 	// ========================================
 	// 步骤5：使用 DAG 分层并行计算
 	// ========================================
-	f.calculateByDAG(filteredGraph)
+	if err := f.calculateByDAGWithContext(ctx, filteredGraph); err != nil {
+		return err
+	}
 
 	duration := time.Since(startTime)
-	log.Printf("✅ [IncrementalRecalc] Completed in %v (calculated %d formulas)", duration, len(affectedCells))
+	f.logger().Infof("✅ [IncrementalRecalc] Completed in %v (calculated %d formulas)", duration, len(affectedCells))
 	return nil
 }
 
-// findAffectedCellsByColumns 通过 BFS 找出所有依赖于更新列的公式
-func (f *File) findAffectedCellsByColumns(graph *dependencyGraph, updatedColumns map[string]bool) map[string]bool {
+// findAffectedCellsByColumns 通过 BFS 找出所有依赖于更新列的公式，ctx 取消时返回 ctx.Err()
+func (f *File) findAffectedCellsByColumns(ctx context.Context, graph *dependencyGraph, updatedColumns map[string]bool) (map[string]bool, error) {
 	affected := make(map[string]bool)
 
 	// 构建反向依赖：谁依赖于这个单元格/列
@@ -2812,7 +3586,10 @@ func (f *File) findAffectedCellsByColumns(graph *dependencyGraph, updatedColumns
 	}
 
 	// BFS 传播
-	for len(queue) > 0 {
+	for visited := 0; len(queue) > 0; visited++ {
+		if visited%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return affected, ctx.Err()
+		}
 		current := queue[0]
 		queue = queue[1:]
 
@@ -2841,14 +3618,17 @@ func (f *File) findAffectedCellsByColumns(graph *dependencyGraph, updatedColumns
 		}
 	}
 
-	return affected
+	return affected, nil
 }
 
 // filterDependencyGraph 过滤依赖图，只保留受影响的公式
 func (f *File) filterDependencyGraph(graph *dependencyGraph, affectedCells map[string]bool) *dependencyGraph {
 	filtered := &dependencyGraph{
-		nodes:          make(map[string]*formulaNode),
-		columnMetadata: graph.columnMetadata, // 复用列元数据
+		nodes:             make(map[string]*formulaNode),
+		columnMetadata:    graph.columnMetadata, // 复用列元数据
+		calcLogger:        graph.calcLogger,
+		maxMergeCostRatio: graph.maxMergeCostRatio,
+		calcWorkers:       graph.calcWorkers,
 	}
 
 	// 只复制受影响的节点
@@ -2885,7 +3665,14 @@ func (f *File) filterDependencyGraph(graph *dependencyGraph, affectedCells map[s
 //
 //	updatedCells: 被更新的单元格，格式 "Sheet!Cell" -> true
 func (f *File) RecalculateAffectedByCells(updatedCells map[string]bool) error {
-	return f.RecalculateAffectedByCellsWithExclusion(updatedCells, nil)
+	return f.RecalculateAffectedByCellsWithContext(context.Background(), updatedCells)
+}
+
+// RecalculateAffectedByCellsWithContext 是 RecalculateAffectedByCells 的可取消版本，
+// 在工作表扫描、BFS 传播、依赖图构建和 DAG 分层计算过程中检查 ctx，取消后尽快返回 ctx.Err()。
+// 取消时已计算的公式保留新值，其余公式保留旧值。
+func (f *File) RecalculateAffectedByCellsWithContext(ctx context.Context, updatedCells map[string]bool) error {
+	return f.recalculateAffectedByCells(ctx, updatedCells, nil)
 }
 
 // RecalculateAffectedByCellsWithExclusion 增量重算依赖于更新单元格的公式，但排除指定的单元格
@@ -2898,6 +3685,11 @@ func (f *File) RecalculateAffectedByCells(updatedCells map[string]bool) error {
 //   - 当调用方已经为某些公式单元格提供了预计算值时，这些单元格不需要重新计算
 //   - 避免预计算值被增量重算覆盖
 func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]bool, excludeCells map[string]bool) error {
+	return f.recalculateAffectedByCells(context.Background(), updatedCells, excludeCells)
+}
+
+// recalculateAffectedByCells 是单元格级增量重算的实现，ctx 取消时返回 ctx.Err()
+func (f *File) recalculateAffectedByCells(ctx context.Context, updatedCells map[string]bool, excludeCells map[string]bool) error {
 	if len(updatedCells) == 0 {
 		return nil
 	}
@@ -2905,42 +3697,225 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 	f.recalcMu.Lock()
 	defer f.recalcMu.Unlock()
 
-	log.Printf("📊 [IncrementalRecalc] Starting optimized cell-level incremental recalculation")
-	log.Printf("  📋 Updated cells: %d cells", len(updatedCells))
+	f.logger().Infof("📊 [IncrementalRecalc] Starting optimized cell-level incremental recalculation")
+	f.logger().Debugf("  📋 Updated cells: %d cells", len(updatedCells))
 	for cell := range updatedCells {
-		log.Printf("    - %s", cell)
+		f.logger().Debugf("    - %s", cell)
 		if len(updatedCells) > 5 {
-			log.Printf("    ... and %d more", len(updatedCells)-5)
+			f.logger().Debugf("    ... and %d more", len(updatedCells)-5)
 			break
 		}
 	}
 	startTime := time.Now()
+	names := f.definedNameRefs()
+
+	index, err := f.cachedIncrementalIndex(ctx, names)
+	if err != nil {
+		return err
+	}
+	formulaMap, columnMetadata, totalFormulas := index.formulaMap, index.columnMetadata, len(index.formulaMap)
+	if totalFormulas == 0 {
+		f.logger().Debugf("  ⚠️  No formulas found, skipping recalculation")
+		return nil
+	}
+	// 易失性公式及其依赖占多数时，增量重算几乎重算所有公式，直接全量重算，省去查找
+	// 受影响公式的开销。排除单元格时仍然增量重算，避免覆盖预计算值
+	volatileRecommended := float64(len(index.volatile)) > float64(totalFormulas)*volatileFullRecalcRatio
+	if volatileRecommended && len(excludeCells) == 0 && !f.calcTuning.ForceIncremental {
+		f.logger().Debugf("  ⚠️  Volatile formulas dominate the workbook (%.1f%%), falling back to full recalculation",
+			float64(len(index.volatile))/float64(totalFormulas)*100)
+		if err := f.fullRecalculateAffected(ctx, startTime); err != nil {
+			return err
+		}
+		f.recordVolatilePlan(len(index.volatile), volatileRecommended)
+		return nil
+	}
+	affected, err := f.findAffectedCellsInIndex(ctx, index, updatedCells)
+	if err != nil {
+		return err
+	}
+	// 易失性公式及其依赖每次都重算
+	for cell := range index.volatile {
+		affected[cell] = true
+	}
 
 	// ========================================
-	// 步骤1：解析更新单元格的列信息
+	// 排除指定的单元格（这些单元格已有预计算值，不需要重算）
 	// ========================================
-	updatedCellsByCol := make(map[string]map[int]bool) // "Sheet!Col" -> row numbers
-	for cell := range updatedCells {
-		parts := strings.SplitN(cell, "!", 2)
+	if len(excludeCells) > 0 {
+		excludedCount := 0
+		for cell := range excludeCells {
+			if affected[cell] {
+				delete(affected, cell)
+				excludedCount++
+			}
+		}
+		if excludedCount > 0 {
+			f.logger().Debugf("  🚫 [Exclusion] Excluded %d cells with pre-calculated values", excludedCount)
+		}
+	}
+
+	if len(affected) == 0 {
+		f.logger().Debugf("  ✅ No affected formulas, skipping recalculation")
+		return nil
+	}
+
+	// 如果受影响的公式超过阈值（默认70%，见 SetIncrementalFallbackThreshold），直接全量重算
+	if f.incrementalFallback(len(affected), totalFormulas, defaultCellFallbackThreshold) {
+		f.logger().Debugf("  ⚠️  Too many affected formulas (%.1f%%), falling back to full recalculation",
+			float64(len(affected))/float64(totalFormulas)*100)
+		if err := f.fullRecalculateAffected(ctx, startTime); err != nil {
+			return err
+		}
+		f.recordVolatilePlan(len(index.volatile), volatileRecommended)
+		return nil
+	}
+
+	// ========================================
+	// 步骤4：为受影响的公式构建小型依赖图
+	// ========================================
+	graphStart := time.Now()
+	graph := &dependencyGraph{
+		nodes:             make(map[string]*formulaNode),
+		columnMetadata:    columnMetadata,
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
+	}
+
+	// 构建列索引（只针对受影响公式的列）
+	columnIndex := make(map[string][]string)
+	for cellRef := range affected {
+		parts := strings.Split(cellRef, "!")
 		if len(parts) != 2 {
 			continue
 		}
-		sheet, cellRef := parts[0], parts[1]
-		col, row, err := CellNameToCoordinates(cellRef)
-		if err != nil {
+		sheetName := parts[0]
+		cell := parts[1]
+		cellCol := ""
+		for _, ch := range cell {
+			if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') {
+				cellCol += string(ch)
+			} else {
+				break
+			}
+		}
+		if cellCol != "" {
+			key := sheetName + "!" + cellCol
+			columnIndex[key] = append(columnIndex[key], cellRef)
+		}
+	}
+
+	// 为每个受影响的公式创建节点
+	built := 0
+	for cell := range affected {
+		built++
+		if built%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		formula, exists := formulaMap[cell]
+		if !exists {
 			continue
 		}
-		colName, _ := ColumnNumberToName(col)
-		colKey := sheet + "!" + colName
-		if updatedCellsByCol[colKey] == nil {
-			updatedCellsByCol[colKey] = make(map[int]bool)
+
+		parts := strings.Split(cell, "!")
+		if len(parts) != 2 {
+			continue
 		}
-		updatedCellsByCol[colKey][row] = true
+
+		deps := extractDependenciesOptimized(names.expand(formula, parts[0]), parts[0], parts[1], columnIndex, columnMetadata)
+		graph.nodes[cell] = &formulaNode{
+			cell:         cell,
+			formula:      formula,
+			dependencies: deps,
+			level:        -1,
+		}
+	}
+
+	// 分配层级
+	graph.assignLevels()
+	graphDuration := time.Since(graphStart)
+	f.logger().Debugf("  📊 [Graph] Built filtered graph: %d formulas, %d levels in %v",
+		len(graph.nodes), len(graph.levels), graphDuration)
+
+	// ========================================
+	// 步骤5：清除受影响公式的缓存
+	// ========================================
+	// 需要清除多种格式的缓存：
+	// 1. "Sheet!Cell!raw=false" - CalcCellValue 字符串缓存
+	// 2. "Sheet!Cell!raw=true" - CalcCellValue 字符串缓存
+	// 3. "Sheet!Cell!subexpr:..." - evalFormulaString 的子表达式缓存
+	// 4. "Sheet!Cell" - formulaArg 类型缓存
+	for cell := range affected {
+		// 清除基本缓存
+		f.calcCache.Delete(cell)
+		f.calcCache.Delete(cell + "!raw=false")
+		f.calcCache.Delete(cell + "!raw=true")
 	}
+	// 遍历整个 calcCache，删除所有受影响单元格的 subexpr 缓存
+	f.calcCache.Range(func(key, value interface{}) bool {
+		keyStr := key.(string)
+		for cell := range affected {
+			// 检查是否是该单元格的 subexpr 缓存
+			if strings.HasPrefix(keyStr, cell+"!subexpr:") {
+				f.calcCache.Delete(key)
+				break
+			}
+		}
+		return true
+	})
 
 	// ========================================
-	// 步骤2：一次遍历构建反向依赖和公式元数据
+	// 步骤6：使用 DAG 分层并行计算
 	// ========================================
+	if err := f.calculateByDAGWithContext(ctx, graph); err != nil {
+		return err
+	}
+
+	f.recordVolatilePlan(len(index.volatile), volatileRecommended)
+	duration := time.Since(startTime)
+	f.logger().Infof("✅ [IncrementalRecalc] Completed in %v (calculated %d formulas)", duration, len(affected))
+	return nil
+}
+
+// fullRecalculateAffected is the fallback of the cell-level incremental
+// recalculation, which clears the caches and recalculates all formulas with
+// the cached full dependency graph.
+func (f *File) fullRecalculateAffected(ctx context.Context, startTime time.Time) error {
+	// 构建完整依赖图并计算
+	graph, err := f.cachedDependencyGraph(ctx)
+	if err != nil {
+		return err
+	}
+	f.calcCache.Range(func(key, value interface{}) bool {
+		f.calcCache.Delete(key)
+		return true
+	})
+	f.rangeCache.Clear()
+	if err := f.calculateByDAGWithContext(ctx, graph); err != nil {
+		return err
+	}
+	duration := time.Since(startTime)
+	f.logger().Infof("✅ [IncrementalRecalc] Completed (full) in %v", duration)
+	return nil
+}
+
+// incrementalIndex is the reverse dependency index of all formulas built by
+// scanning the worksheets for the cell-level incremental recalculations.
+// Unlike the dependency graph, the small ranges are expanded to all their
+// cells, so the formulas referencing an updated data cell are found.
+type incrementalIndex struct {
+	reverseDeps    map[string][]string    // cell -> formulas that depend on it
+	reverseColDeps map[string][]string    // COLUMN:col -> formulas that depend on it
+	formulaMap     map[string]string      // cell -> formula content
+	columnMetadata map[string]*columnMeta // 列元数据
+	cellToColKey   map[string]string      // formula cell -> COLUMN:col
+	volatile       map[string]bool        // volatile formulas and their dependents
+}
+
+// buildIncrementalIndex 一次遍历所有工作表，构建反向依赖和公式元数据，ctx 取消时
+// 返回 ctx.Err()
+func (f *File) buildIncrementalIndex(ctx context.Context, names definedNameRefs) (*incrementalIndex, error) {
 	scanStart := time.Now()
 	reverseDeps := make(map[string][]string)    // cell -> formulas that depend on it
 	reverseColDeps := make(map[string][]string) // COLUMN:col -> formulas that depend on it
@@ -2955,7 +3930,10 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 			continue
 		}
 
-		for _, row := range ws.SheetData.Row {
+		for rowIdx, row := range ws.SheetData.Row {
+			if rowIdx%cancelCheckInterval == 0 && ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			for _, cell := range row.C {
 				// 提取列和行信息
 				col, rowNum, err := CellNameToCoordinates(cell.R)
@@ -2982,10 +3960,7 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 					continue
 				}
 
-				formula := cell.F.Content
-				if formula == "" && cell.F.T == STCellFormulaTypeShared && cell.F.Si != nil {
-					formula, _ = getSharedFormula(ws, *cell.F.Si, cell.R)
-				}
+				formula := resolvedFormula(ws, &cell)
 				if formula == "" {
 					continue
 				}
@@ -3002,7 +3977,7 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 				meta.formulaRows[rowNum] = true
 
 				// 提取依赖并构建反向索引
-				deps := extractDependenciesOptimized(formula, sheet, cell.R, nil, columnMetadata)
+				deps := extractDependenciesOptimized(names.expand(formula, sheet), sheet, cell.R, nil, columnMetadata)
 				for _, dep := range deps {
 					if strings.HasPrefix(dep, "COLUMN:") {
 						reverseColDeps[dep] = append(reverseColDeps[dep], fullCell)
@@ -3014,19 +3989,7 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 		}
 	}
 	scanDuration := time.Since(scanStart)
-	log.Printf("  📊 [Scan] Scanned %d formulas in %v", totalFormulas, scanDuration)
-
-	if totalFormulas == 0 {
-		log.Printf("  ⚠️  No formulas found, skipping recalculation")
-		return nil
-	}
-
-	// ========================================
-	// 步骤3：使用 BFS 找出受影响的公式
-	// 完整的 BFS 传播确保所有依赖链都被正确追踪
-	// ========================================
-	bfsStart := time.Now()
-	affected := make(map[string]bool, len(formulaMap)/2)
+	f.logger().Debugf("  📊 [Scan] Scanned %d formulas in %v", totalFormulas, scanDuration)
 
 	// 预计算 cell -> colKey 映射，避免在 BFS 循环中重复计算
 	cellToColKey := make(map[string]string, len(formulaMap))
@@ -3047,6 +4010,48 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 		}
 	}
 
+	index := &incrementalIndex{
+		reverseDeps:    reverseDeps,
+		reverseColDeps: reverseColDeps,
+		formulaMap:     formulaMap,
+		columnMetadata: columnMetadata,
+		cellToColKey:   cellToColKey,
+	}
+	volatile, err := f.findVolatileCells(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+	index.volatile = volatile
+	return index, nil
+}
+
+// findAffectedCellsInIndex 使用 BFS 找出直接或间接依赖于更新单元格的公式，
+// 完整的 BFS 传播确保所有依赖链都被正确追踪，ctx 取消时返回 ctx.Err()
+func (f *File) findAffectedCellsInIndex(ctx context.Context, index *incrementalIndex, updatedCells map[string]bool) (map[string]bool, error) {
+	reverseDeps, reverseColDeps, cellToColKey := index.reverseDeps, index.reverseColDeps, index.cellToColKey
+	// 解析更新单元格的列信息
+	updatedCellsByCol := make(map[string]map[int]bool) // "Sheet!Col" -> row numbers
+	for cell := range updatedCells {
+		parts := strings.SplitN(cell, "!", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		sheet, cellRef := parts[0], parts[1]
+		col, row, err := CellNameToCoordinates(cellRef)
+		if err != nil {
+			continue
+		}
+		colName, _ := ColumnNumberToName(col)
+		colKey := sheet + "!" + colName
+		if updatedCellsByCol[colKey] == nil {
+			updatedCellsByCol[colKey] = make(map[int]bool)
+		}
+		updatedCellsByCol[colKey][row] = true
+	}
+
+	bfsStart := time.Now()
+	affected := make(map[string]bool, len(index.formulaMap)/2)
+
 	// 使用双缓冲区 BFS：避免在迭代过程中修改队列
 	currentQueue := make([]string, 0, 1000)
 	nextQueue := make([]string, 0, 1000)
@@ -3075,6 +4080,9 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 	// 完整 BFS 传播
 	iterations := 0
 	for len(currentQueue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		iterations++
 		nextQueue = nextQueue[:0] // 清空下一个队列
 
@@ -3103,141 +4111,9 @@ func (f *File) RecalculateAffectedByCellsWithExclusion(updatedCells map[string]b
 	}
 
 	bfsDuration := time.Since(bfsStart)
-	log.Printf("  📊 [BFS] Found %d affected formulas (%.1f%%) in %v (%d iterations)",
-		len(affected), float64(len(affected))/float64(totalFormulas)*100, bfsDuration, iterations)
-
-	// ========================================
-	// 排除指定的单元格（这些单元格已有预计算值，不需要重算）
-	// ========================================
-	if len(excludeCells) > 0 {
-		excludedCount := 0
-		for cell := range excludeCells {
-			if affected[cell] {
-				delete(affected, cell)
-				excludedCount++
-			}
-		}
-		if excludedCount > 0 {
-			log.Printf("  🚫 [Exclusion] Excluded %d cells with pre-calculated values", excludedCount)
-		}
-	}
-
-	if len(affected) == 0 {
-		log.Printf("  ✅ No affected formulas, skipping recalculation")
-		return nil
-	}
-
-	// 如果受影响的公式超过70%，直接全量重算
-	if float64(len(affected)) > float64(totalFormulas)*0.7 {
-		log.Printf("  ⚠️  Too many affected formulas (%.1f%%), falling back to full recalculation",
-			float64(len(affected))/float64(totalFormulas)*100)
-		// 构建完整依赖图并计算
-		graph := f.buildDependencyGraph()
-		f.calcCache.Range(func(key, value interface{}) bool {
-			f.calcCache.Delete(key)
-			return true
-		})
-		f.rangeCache.Clear()
-		f.calculateByDAG(graph)
-		duration := time.Since(startTime)
-		log.Printf("✅ [IncrementalRecalc] Completed (full) in %v", duration)
-		return nil
-	}
-
-	// ========================================
-	// 步骤4：为受影响的公式构建小型依赖图
-	// ========================================
-	graphStart := time.Now()
-	graph := &dependencyGraph{
-		nodes:          make(map[string]*formulaNode),
-		columnMetadata: columnMetadata,
-	}
-
-	// 构建列索引（只针对受影响公式的列）
-	columnIndex := make(map[string][]string)
-	for cellRef := range affected {
-		parts := strings.Split(cellRef, "!")
-		if len(parts) != 2 {
-			continue
-		}
-		sheetName := parts[0]
-		cell := parts[1]
-		cellCol := ""
-		for _, ch := range cell {
-			if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') {
-				cellCol += string(ch)
-			} else {
-				break
-			}
-		}
-		if cellCol != "" {
-			key := sheetName + "!" + cellCol
-			columnIndex[key] = append(columnIndex[key], cellRef)
-		}
-	}
-
-	// 为每个受影响的公式创建节点
-	for cell := range affected {
-		formula, exists := formulaMap[cell]
-		if !exists {
-			continue
-		}
-
-		parts := strings.Split(cell, "!")
-		if len(parts) != 2 {
-			continue
-		}
-
-		deps := extractDependenciesOptimized(formula, parts[0], parts[1], columnIndex, columnMetadata)
-		graph.nodes[cell] = &formulaNode{
-			cell:         cell,
-			formula:      formula,
-			dependencies: deps,
-			level:        -1,
-		}
-	}
-
-	// 分配层级
-	graph.assignLevels()
-	graphDuration := time.Since(graphStart)
-	log.Printf("  📊 [Graph] Built filtered graph: %d formulas, %d levels in %v",
-		len(graph.nodes), len(graph.levels), graphDuration)
-
-	// ========================================
-	// 步骤5：清除受影响公式的缓存
-	// ========================================
-	// 需要清除多种格式的缓存：
-	// 1. "Sheet!Cell!raw=false" - CalcCellValue 字符串缓存
-	// 2. "Sheet!Cell!raw=true" - CalcCellValue 字符串缓存
-	// 3. "Sheet!Cell!subexpr:..." - evalFormulaString 的子表达式缓存
-	// 4. "Sheet!Cell" - formulaArg 类型缓存
-	for cell := range affected {
-		// 清除基本缓存
-		f.calcCache.Delete(cell)
-		f.calcCache.Delete(cell + "!raw=false")
-		f.calcCache.Delete(cell + "!raw=true")
-	}
-	// 遍历整个 calcCache，删除所有受影响单元格的 subexpr 缓存
-	f.calcCache.Range(func(key, value interface{}) bool {
-		keyStr := key.(string)
-		for cell := range affected {
-			// 检查是否是该单元格的 subexpr 缓存
-			if strings.HasPrefix(keyStr, cell+"!subexpr:") {
-				f.calcCache.Delete(key)
-				break
-			}
-		}
-		return true
-	})
-
-	// ========================================
-	// 步骤6：使用 DAG 分层并行计算
-	// ========================================
-	f.calculateByDAG(graph)
-
-	duration := time.Since(startTime)
-	log.Printf("✅ [IncrementalRecalc] Completed in %v (calculated %d formulas)", duration, len(affected))
-	return nil
+	f.logger().Debugf("  📊 [BFS] Found %d affected formulas (%.1f%%) in %v (%d iterations)",
+		len(affected), float64(len(affected))/float64(len(index.formulaMap))*100, bfsDuration, iterations)
+	return affected, nil
 }
 
 // findAffectedCellsByCells 精确找出依赖于更新单元格的公式
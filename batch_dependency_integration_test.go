@@ -35,6 +35,7 @@ func TestDependencyRecalculationPaths(t *testing.T) {
 	if len(graph.nodes) == 0 {
 		t.Fatalf("expected dependency nodes")
 	}
+	assertValidDependencyGraph(t, graph)
 	f.calculateByDependencyLevels(graph)
 
 	if val, ok := f.calcCache.Load("Sheet1!B1!raw=true"); !ok || val.(string) == "" {
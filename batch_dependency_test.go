@@ -1,9 +1,18 @@
 package excelize
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDependencyGraphAssignLevelsAndMerge(t *testing.T) {
@@ -22,6 +31,7 @@ func TestDependencyGraphAssignLevelsAndMerge(t *testing.T) {
 	if len(graph.levels) == 0 {
 		t.Fatalf("expected levels assigned")
 	}
+	assertValidDependencyGraph(t, graph)
 
 	levelMap := make(map[string]int)
 	for idx, cells := range graph.levels {
@@ -105,6 +115,69 @@ func TestFormatFloat(t *testing.T) {
 	}
 }
 
+func TestFormatFloatMatchesCalcCellValue(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	groups := []struct {
+		values []float64
+		want   string
+	}{
+		{[]float64{0.1, 0.2}, "0.3"},
+		{[]float64{0.1, 0.1, 0.1}, "0.3"},
+		{[]float64{1.1, 2.2}, "3.3"},
+		{[]float64{0.7, 0.1}, "0.8"},
+		{[]float64{0.0000001}, "0.0000001"},
+		{[]float64{2.0 / 3}, "0.666666666666667"},
+		{[]float64{-0.5, -0.25}, "-0.75"},
+		{[]float64{123456789.123, 0.000000001}, "123456789.123"},
+		{[]float64{1e15, 0.5}, "1E+15"},
+		{[]float64{40, 60}, "100"},
+	}
+	dataRow := 1
+	formulas := make(map[string]string)
+	for i, group := range groups {
+		key := string(rune('A' + i))
+		for _, value := range group.values {
+			if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", dataRow), &[]interface{}{key, value}); err != nil {
+				t.Fatalf("set data row: %v", err)
+			}
+			dataRow++
+		}
+		formula := fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,A%d)", i+1)
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", i+1), key); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", i+1), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		formulas[fmt.Sprintf("Sheet1!B%d", i+1)] = formula
+	}
+
+	results := f.batchCalculateSUMIFS(formulas)
+	if len(results) != len(groups) {
+		t.Fatalf("expected %d batch results, got %d", len(groups), len(results))
+	}
+	for i, group := range groups {
+		cell := fmt.Sprintf("B%d", i+1)
+		expected, err := f.CalcCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		if expected != group.want {
+			t.Fatalf("CalcCellValue %s: got %q, want %q", cell, expected, group.want)
+		}
+		if got := results["Sheet1!"+cell]; got != expected {
+			t.Fatalf("batch %s: got %q, CalcCellValue got %q", cell, got, expected)
+		}
+	}
+}
+
 func containsDep(deps []string, want string) bool {
 	for _, dep := range deps {
 		if dep == want {
@@ -370,6 +443,59 @@ func TestRecalculateAffectedByCells(t *testing.T) {
 			t.Errorf("expected B1=140 after update, got %s", b1After)
 		}
 	})
+
+	t.Run("SharedFormula", func(t *testing.T) {
+		f := NewFile()
+
+		// C1:C5 共享 C1 的公式，从属单元格 C2:C5 只存储共享索引
+		f.SetCellValue("Sheet1", "B1", 2)
+		for row := 1; row <= 5; row++ {
+			f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row)
+		}
+		formulaType, ref := STCellFormulaTypeShared, "C1:C5"
+		if err := f.SetCellFormula("Sheet1", "C1", "A1*$B$1", FormulaOpts{Ref: &ref, Type: &formulaType}); err != nil {
+			t.Fatalf("SetCellFormula failed: %v", err)
+		}
+		f.SetCellFormula("Sheet1", "D1", "SUM(C1:C5)")
+		// 无关的公式避免回退到全量重算
+		for row := 1; row <= 20; row++ {
+			f.SetCellValue("Sheet1", fmt.Sprintf("F%d", row), row)
+			f.SetCellFormula("Sheet1", fmt.Sprintf("G%d", row), fmt.Sprintf("F%d+1", row))
+		}
+		ws, err := f.workSheetReader("Sheet1")
+		if err != nil {
+			t.Fatalf("workSheetReader failed: %v", err)
+		}
+		if c := ws.SheetData.Row[2].C[2]; c.F == nil || c.F.T != STCellFormulaTypeShared || c.F.Content != "" {
+			t.Fatalf("expected C3 to be a shared formula slave cell, got %+v", c.F)
+		}
+		f.RecalculateAllWithDependency()
+
+		// 编辑从属单元格共同引用的单元格，所有从属单元格都要重算
+		f.SetCellValue("Sheet1", "B1", 10)
+		if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!B1": true}); err != nil {
+			t.Fatalf("RecalculateAffectedByCells failed: %v", err)
+		}
+		if plan, _ := f.LastCalcPlan(); plan.Formulas != 6 {
+			t.Errorf("expected 6 formulas recalculated incrementally, got %d", plan.Formulas)
+		}
+		for cell, expected := range map[string]string{"C1": "10", "C2": "20", "C3": "30", "C4": "40", "C5": "50", "D1": "150"} {
+			if value, _ := f.GetCellValue("Sheet1", cell); value != expected {
+				t.Errorf("expected %s=%s after update, got %s", cell, expected, value)
+			}
+		}
+
+		// 编辑只被一个从属单元格引用的单元格
+		f.SetCellValue("Sheet1", "A4", 7)
+		if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A4": true}); err != nil {
+			t.Fatalf("RecalculateAffectedByCells failed: %v", err)
+		}
+		for cell, expected := range map[string]string{"C4": "70", "D1": "180"} {
+			if value, _ := f.GetCellValue("Sheet1", cell); value != expected {
+				t.Errorf("expected %s=%s after update, got %s", cell, expected, value)
+			}
+		}
+	})
 }
 
 // TestRecalculateAffectedByCellsWithExclusion tests the incremental recalculation with exclusion API
@@ -1734,3 +1860,871 @@ func itoa(i int) string {
 	}
 	return result
 }
+
+func TestRunBatchTasksBoundedConcurrency(t *testing.T) {
+	var running, peak, done int32
+	tasks := make([]func(), 12)
+	for i := range tasks {
+		tasks[i] = func() {
+			cur := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&done, 1)
+		}
+	}
+
+	runBatchTasks(tasks, 3)
+
+	if done != 12 {
+		t.Fatalf("expected all 12 tasks to run, got %d", done)
+	}
+	if peak > 3 {
+		t.Fatalf("expected at most 3 concurrent tasks, got %d", peak)
+	}
+}
+
+// newIndependentPatternsWorkbook builds a workbook whose single formula level
+// contains several independent batch patterns: one composite SUMIFS group per
+// source sheet plus a block of INDEX-MATCH lookups against a unique key column.
+func newIndependentPatternsWorkbook(t testing.TB, sources, dataRows, formulaRows int) *File {
+	f := NewFile()
+	for s := 0; s < sources; s++ {
+		sheet := fmt.Sprintf("Src%d", s)
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create %s failed: %v", sheet, err)
+		}
+		for r := 1; r <= dataRows; r++ {
+			row := []interface{}{fmt.Sprintf("SKU%d", r%formulaRows), fmt.Sprintf("R%d", r%2), r + s, fmt.Sprintf("SKU%d", r)}
+			if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", r), &row); err != nil {
+				t.Fatalf("set %s row %d failed: %v", sheet, r, err)
+			}
+		}
+	}
+	for r := 1; r <= formulaRows; r++ {
+		row := []interface{}{fmt.Sprintf("SKU%d", r), "R1"}
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", r), &row); err != nil {
+			t.Fatalf("set Sheet1 row %d failed: %v", r, err)
+		}
+		for s := 0; s < sources; s++ {
+			col, _ := ColumnNumberToName(3 + s)
+			formula := fmt.Sprintf("SUMIFS(Src%d!$C:$C,Src%d!$A:$A,$A%d,Src%d!$B:$B,$B%d)+0", s, s, r, s, r)
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, r), formula); err != nil {
+				t.Fatalf("set formula failed: %v", err)
+			}
+		}
+		col, _ := ColumnNumberToName(3 + sources)
+		formula := fmt.Sprintf("INDEX(Src0!$C:$C,MATCH($A%d,Src0!$D:$D,0))", r)
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, r), formula); err != nil {
+			t.Fatalf("set formula failed: %v", err)
+		}
+	}
+	return f
+}
+
+func TestBatchOptimizeLevelIndependentPatterns(t *testing.T) {
+	const sources, dataRows, formulaRows = 3, 60, 12
+	f := newIndependentPatternsWorkbook(t, sources, dataRows, formulaRows)
+	defer f.Close()
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+
+	for r := 1; r <= formulaRows; r++ {
+		sku := fmt.Sprintf("SKU%d", r)
+		for s := 0; s < sources; s++ {
+			expected := 0
+			for d := 1; d <= dataRows; d++ {
+				if fmt.Sprintf("SKU%d", d%formulaRows) == sku && d%2 == 1 {
+					expected += d + s
+				}
+			}
+			col, _ := ColumnNumberToName(3 + s)
+			got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("%s%d", col, r))
+			if got != strconv.Itoa(expected) {
+				t.Errorf("Sheet1!%s%d: expected %d, got %s", col, r, expected, got)
+			}
+		}
+		col, _ := ColumnNumberToName(3 + sources)
+		got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("%s%d", col, r))
+		if got != strconv.Itoa(r) {
+			t.Errorf("Sheet1!%s%d: expected %d, got %s", col, r, r, got)
+		}
+	}
+}
+
+func BenchmarkBatchOptimizeLevelIndependentPatterns(b *testing.B) {
+	f := newIndependentPatternsWorkbook(b, 4, 5000, 50)
+	defer f.Close()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	graph := f.buildDependencyGraph()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for idx, levelCells := range graph.levels {
+			f.batchOptimizeLevelWithCache(context.Background(), idx, levelCells, graph, NewWorksheetCache())
+		}
+	}
+}
+
+func TestGetRowsRaw(t *testing.T) {
+	f := NewFile()
+	defer f.Close()
+	style, err := f.NewStyle(&Style{NumFmt: 14})
+	if err != nil {
+		t.Fatalf("new style: %v", err)
+	}
+	for row := 1; row <= 4; row++ {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{
+			fmt.Sprintf("k%d", row), row * 10, time.Date(2024, 1, row, 0, 0, 0, 0, time.UTC), row%2 == 0, 1.5,
+		}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	if err := f.SetCellStyle("Sheet1", "E1", "E4", style); err != nil {
+		t.Fatalf("set style: %v", err)
+	}
+	if err := f.SetCellFormula("Sheet1", "F2", `A2&"x"`); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "G1", "merged"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "H2", "hidden"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.MergeCell("Sheet1", "G1", "H2"); err != nil {
+		t.Fatalf("merge cell: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "B6", "inline"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	ws, err := f.workSheetReader("Sheet1")
+	if err != nil {
+		t.Fatalf("worksheet: %v", err)
+	}
+	c, _, _, _ := ws.prepareCell("B6")
+	c.T, c.V, c.IS = "inlineStr", "", &xlsxSI{T: &xlsxT{Val: "inline"}}
+	f.setFormulaValue("Sheet1", "F2", "k2x")
+
+	// 与逐个单元格读取原始值的结果一致：日期为序列号，合并区域取左上角的值，
+	// 不存在的单元格 G2 为空
+	rows, err := f.getRowsRaw("Sheet1")
+	if err != nil {
+		t.Fatalf("get rows raw: %v", err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("expected 6 rows, got %d", len(rows))
+	}
+	for rowIdx, row := range rows {
+		for colIdx, value := range row {
+			cell, _ := CoordinatesToCellName(colIdx+1, rowIdx+1)
+			if want, _ := f.GetCellValue("Sheet1", cell, Options{RawCellValue: true}); cell != "G2" && value != want {
+				t.Fatalf("unexpected %s value %q, want %q", cell, value, want)
+			}
+		}
+	}
+	for cell, want := range map[string]string{"C1": "45292", "E1": "1.5", "D2": "1", "F2": "k2x", "H2": "merged", "B6": "inline"} {
+		col, row, _ := CellNameToCoordinates(cell)
+		if got := rows[row-1][col-1]; got != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, want)
+		}
+	}
+	if _, err := f.getRowsRaw("Missing"); err == nil {
+		t.Fatalf("expected an error reading a missing sheet")
+	}
+}
+
+// BenchmarkGetRowsRaw compares reading the raw values of a 50k x 50 sheet
+// from the cells directly with calling GetCellValue for each cell.
+func BenchmarkGetRowsRaw(b *testing.B) {
+	f := NewFile()
+	defer f.Close()
+	values := make([]interface{}, 50)
+	for row := 1; row <= 50000; row++ {
+		for col := range values {
+			if col%2 == 0 {
+				values[col] = row*50 + col
+			} else {
+				values[col] = fmt.Sprintf("v%d", (row+col)%1000)
+			}
+		}
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &values); err != nil {
+			b.Fatalf("set row: %v", err)
+		}
+	}
+
+	b.Run("Direct", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := f.getRowsRaw("Sheet1"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetCellValue", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rows := make([][]string, 50000)
+			for row := range rows {
+				rows[row] = make([]string, 50)
+				for col := range rows[row] {
+					cell, _ := CoordinatesToCellName(col+1, row+1)
+					rows[row][col], _ = f.GetCellValue("Sheet1", cell, Options{RawCellValue: true})
+				}
+			}
+		}
+	})
+}
+
+func TestClearFormulaCacheForSheet(t *testing.T) {
+	f := NewFile()
+	defer f.Close()
+	sheets := []string{"Sheet1", "Sheet2", "Sheet1!Copy"}
+	for _, sheet := range sheets[1:] {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("new sheet %s: %v", sheet, err)
+		}
+	}
+	for i, sheet := range sheets {
+		for row := 1; row <= 3; row++ {
+			if err := f.SetCellValue(sheet, fmt.Sprintf("A%d", row), row*(i+1)); err != nil {
+				t.Fatalf("set value: %v", err)
+			}
+		}
+		if err := f.SetCellFormula(sheet, "B1", "SUM(A1:A3)"); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	for _, sheet := range sheets {
+		if _, err := f.CalcCellValue(sheet, "B1"); err != nil {
+			t.Fatalf("calc %s!B1: %v", sheet, err)
+		}
+	}
+	countKeys := func(sheet string) (calcKeys, rangeKeys int) {
+		f.calcCache.Range(func(key, _ interface{}) bool {
+			if isSheetCacheKey(key.(string), sheet) {
+				calcKeys++
+			}
+			return true
+		})
+		f.rangeCache.Range(func(key string, _ interface{}) bool {
+			if isSheetCacheKey(key, sheet) {
+				rangeKeys++
+			}
+			return true
+		})
+		return
+	}
+	for _, sheet := range sheets {
+		if calcKeys, rangeKeys := countKeys(sheet); calcKeys == 0 || rangeKeys == 0 {
+			t.Fatalf("expected the caches of %s to be filled, got %d and %d keys", sheet, calcKeys, rangeKeys)
+		}
+	}
+
+	// 只清除 Sheet1 的缓存，名称以 "Sheet1!" 开头的工作表不受影响
+	f.ClearFormulaCacheForSheet("Sheet1")
+	if calcKeys, rangeKeys := countKeys("Sheet1"); calcKeys != 0 || rangeKeys != 0 {
+		t.Fatalf("expected the caches of Sheet1 to be cleared, got %d and %d keys", calcKeys, rangeKeys)
+	}
+	for _, sheet := range sheets[1:] {
+		if calcKeys, rangeKeys := countKeys(sheet); calcKeys == 0 || rangeKeys == 0 {
+			t.Fatalf("expected the caches of %s to be kept, got %d and %d keys", sheet, calcKeys, rangeKeys)
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "A1", 100); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	f.ClearFormulaCacheForSheet("Sheet1")
+	if value, err := f.CalcCellValue("Sheet1", "B1"); err != nil || value != "105" {
+		t.Fatalf("unexpected Sheet1!B1 value %q, want 105 (%v)", value, err)
+	}
+
+	for key, want := range map[string]bool{
+		"Sheet1!A1":                 true,
+		"Sheet1!A1!raw=true":        true,
+		"Sheet1!R1C1:R3C1":          true,
+		"Sheet1!Copy!A1!raw=true":   false,
+		"Sheet1!Copy!R1C1:R3C1":     false,
+		"Sheet1!A1!R1C1:R3C1":       false,
+		"Sheet1!R1C1:R3C1!raw=true": false,
+		"Sheet10!A1":                false,
+		"Sheet1!":                   false,
+	} {
+		if got := isSheetCacheKey(key, "Sheet1"); got != want {
+			t.Fatalf("isSheetCacheKey(%q) = %t, want %t", key, got, want)
+		}
+	}
+	if !isSheetCacheKey("Sheet1!Copy!A1!raw=false", "Sheet1!Copy") || isSheetCacheKey("Sheet1!A1", "Sheet1!A1") {
+		t.Fatalf("unexpected cache key of the sheet Sheet1!Copy")
+	}
+}
+
+func TestLevelHistogram(t *testing.T) {
+	f := NewFile()
+	defer f.Close()
+
+	if _, err := f.LevelHistogram(); err != ErrDependencyGraphNotBuilt {
+		t.Fatalf("expected ErrDependencyGraphNotBuilt, got %v", err)
+	}
+
+	// Diamond: B1 and C1 both depend on A1, D1 joins them, E1 depends on D1
+	_ = f.SetCellValue("Sheet1", "A1", 1)
+	_ = f.SetCellFormula("Sheet1", "B1", "A1+1")
+	_ = f.SetCellFormula("Sheet1", "C1", "A1*2")
+	_ = f.SetCellFormula("Sheet1", "D1", "B1+C1")
+	_ = f.SetCellFormula("Sheet1", "E1", "D1*10")
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+	histogram, err := f.LevelHistogram()
+	if err != nil {
+		t.Fatalf("LevelHistogram failed: %v", err)
+	}
+	if fmt.Sprint(histogram) != "[2 1 1]" {
+		t.Fatalf("expected histogram [2 1 1], got %v", histogram)
+	}
+
+	// The returned slice is a copy
+	histogram[0] = 100
+	again, _ := f.LevelHistogram()
+	if again[0] != 2 {
+		t.Fatalf("expected internal histogram to be unaffected, got %v", again)
+	}
+}
+
+func TestNumericSheetNameReferences(t *testing.T) {
+	for _, formula := range []string{"'2024'!A1+2024", "2024!A1+2024"} {
+		deps := extractDependencies(formula, "Sheet1", "B1")
+		if len(deps) != 1 || deps[0] != "2024!A1" {
+			t.Fatalf("%s: expected dependency 2024!A1, got %v", formula, deps)
+		}
+		if refs := extractSheetReferences(formula); len(refs) != 1 || refs[0] != "2024" {
+			t.Fatalf("%s: expected sheet reference 2024, got %v", formula, refs)
+		}
+	}
+	for _, ref := range []string{"'2024'!$C:$C", "2024!$C:$C"} {
+		if sheet := extractSheetName(ref); sheet != "2024" {
+			t.Fatalf("%s: expected sheet 2024, got %q", ref, sheet)
+		}
+	}
+
+	f := NewFile()
+	defer f.Close()
+	if _, err := f.NewSheet("2024"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	for r := 1; r <= 20; r++ {
+		if err := f.SetSheetRow("2024", fmt.Sprintf("A%d", r), &[]interface{}{fmt.Sprintf("K%d", r), "X", r}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	// Enough formulas per pattern to go through the batch calculators
+	for r := 1; r <= 12; r++ {
+		_ = f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", r), &[]interface{}{fmt.Sprintf("K%d", r), "X"})
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", r), fmt.Sprintf("SUMIFS('2024'!$C:$C,'2024'!$A:$A,$A%d,'2024'!$B:$B,$B%d)", r, r))
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("D%d", r), fmt.Sprintf("SUMIFS(2024!$C:$C,2024!$A:$A,$A%d,2024!$B:$B,$B%d)+0", r, r))
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("E%d", r), fmt.Sprintf("INDEX(2024!$C:$C,MATCH($A%d,2024!$A:$A,0))", r))
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("F%d", r), fmt.Sprintf("INDEX('2024'!$C:$C,MATCH($A%d,'2024'!$A:$A,0))", r))
+		_ = f.SetCellFormula("Sheet1", fmt.Sprintf("G%d", r), fmt.Sprintf("2024!C%d*2", r))
+	}
+
+	check := func(row int, expected string, doubled string) {
+		t.Helper()
+		for _, col := range []string{"C", "D", "E", "F"} {
+			if got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("%s%d", col, row)); got != expected {
+				t.Errorf("Sheet1!%s%d: expected %s, got %s", col, row, expected, got)
+			}
+		}
+		if got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("G%d", row)); got != doubled {
+			t.Errorf("Sheet1!G%d: expected %s, got %s", row, doubled, got)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+	check(3, "3", "6")
+
+	_ = f.SetCellValue("2024", "C3", 100)
+	if err := f.RecalculateAffectedByCells(map[string]bool{"2024!C3": true}); err != nil {
+		t.Fatalf("RecalculateAffectedByCells failed: %v", err)
+	}
+	check(3, "100", "200")
+}
+
+func TestQuotedSheetNameReferences(t *testing.T) {
+	for formula, expected := range map[string][]string{
+		"SUM('Sales 2024'!$A:$A)":          {"Sales 2024!A:COLUMN_RANGE"},
+		"'Bob''s Data'!A1+1":               {"Bob's Data!A1"},
+		"SUM('Q1!Sales'!B2:B3)+C1":         {"Q1!Sales!B2", "Q1!Sales!B3", "Sheet1!C1"},
+		"'Bob''s Data'!$B$2*'Q1!Sales'!B1": {"Bob's Data!B2", "Q1!Sales!B1"},
+	} {
+		deps := extractDependencies(formula, "Sheet1", "D1")
+		sort.Strings(deps)
+		if strings.Join(deps, "|") != strings.Join(expected, "|") {
+			t.Fatalf("%s: expected dependencies %v, got %v", formula, expected, deps)
+		}
+		optimized := extractDependenciesOptimized(formula, "Sheet1", "D1", nil, nil)
+		for _, dep := range expected {
+			if strings.HasSuffix(dep, ":COLUMN_RANGE") {
+				dep = "COLUMN:" + strings.TrimSuffix(dep, ":COLUMN_RANGE")
+			}
+			if !containsDep(optimized, dep) {
+				t.Fatalf("%s: missing optimized dependency %s in %v", formula, dep, optimized)
+			}
+		}
+	}
+	refs := extractSheetReferences("OFFSET('Bob''s Data'!A1,0,0)+SUM('Sales 2024'!A1:A2)+'Q1!Sales'!A1")
+	sort.Strings(refs)
+	if strings.Join(refs, "|") != "Bob's Data|Q1!Sales|Sales 2024" {
+		t.Fatalf("unexpected sheet references %v", refs)
+	}
+	for ref, expected := range map[string][2]string{
+		"'Sales 2024'!$H:$H":   {"Sales 2024", "H"},
+		"'Bob''s Data'!$C:$C":  {"Bob's Data", "C"},
+		"'Q1!Sales'!$D2:$D100": {"Q1!Sales", "D"},
+	} {
+		if sheet, col := extractSheetName(ref), extractColumnFromRange(ref); sheet != expected[0] || col != expected[1] {
+			t.Fatalf("%s: expected %v, got %s, %s", ref, expected, sheet, col)
+		}
+	}
+
+	f := NewFile()
+	defer f.Close()
+	// 计算引擎不支持包含感叹号的工作表名称，只验证依赖提取
+	for _, sheet := range []string{"Sales 2024", "Bob's Data", "Q2 Sales"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create sheet %s: %v", sheet, err)
+		}
+	}
+	for r := 1; r <= 3; r++ {
+		_ = f.SetCellValue("Sales 2024", fmt.Sprintf("A%d", r), r)
+	}
+	_ = f.SetCellFormula("Bob's Data", "A1", "SUM('Sales 2024'!$A:$A)")
+	_ = f.SetCellFormula("Q2 Sales", "A1", "'Bob''s Data'!A1*2")
+	_ = f.SetCellFormula("Sheet1", "A1", "'Q2 Sales'!A1+1")
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+	graph := f.buildDependencyGraph()
+	assertValidDependencyGraph(t, graph)
+	for _, key := range []string{"Bob's Data!A1", "Q2 Sales!A1", "Sheet1!A1"} {
+		if _, ok := graph.nodes[key]; !ok {
+			t.Fatalf("missing node %s in the dependency graph", key)
+		}
+	}
+	if deps := graph.nodes["Sheet1!A1"].dependencies; !containsDep(deps, "Q2 Sales!A1") {
+		t.Fatalf("unexpected dependencies of Sheet1!A1: %v", deps)
+	}
+	if len(graph.levels) != 3 {
+		t.Fatalf("expected 3 levels, got %v", graph.levels)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "A1"); got != "13" {
+		t.Fatalf("Sheet1!A1: expected 13, got %s", got)
+	}
+
+	_ = f.SetCellValue("Sales 2024", "A2", 10)
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sales 2024!A2": true}); err != nil {
+		t.Fatalf("RecalculateAffectedByCells failed: %v", err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "A1"); got != "29" {
+		t.Fatalf("Sheet1!A1: expected 29, got %s", got)
+	}
+}
+
+func TestExtractColumnRangeFromRange(t *testing.T) {
+	for ref, expected := range map[string][3]string{
+		"Data!H:H":             {"H", "H", "true"},
+		"Data!$C:$O":           {"C", "O", "true"},
+		"'Q1 Sales'!$C$2:$O$9": {"C", "O", "true"},
+		"Data!$AD:$AP":         {"AD", "AP", "true"},
+		"Data!$O:$C":           {"C", "O", "true"},
+		"Data!B7":              {"B", "B", "true"},
+		"Data!$1:$1":           {"", "", "false"},
+		"Data!2:5":             {"", "", "false"},
+		"$C:$O":                {"", "", "false"},
+	} {
+		startCol, endCol, ok := extractColumnRangeFromRange(ref)
+		if startCol != expected[0] || endCol != expected[1] || fmt.Sprint(ok) != expected[2] {
+			t.Fatalf("%s: expected %v, got %s, %s, %t", ref, expected, startCol, endCol, ok)
+		}
+		if col := extractColumnFromRange(ref); col != expected[0] {
+			t.Fatalf("%s: expected first column %q, got %q", ref, expected[0], col)
+		}
+	}
+}
+
+func TestRecalculateAffectedWithContextCancel(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const rows = 3000
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for r := 1; r <= rows; r++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", r), fmt.Sprintf("$A$1*%d", r)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", r), fmt.Sprintf("B%d+1", r)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalc failed: %v", err)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "C10"); v != "11" {
+		t.Fatalf("expected C10=11 before the update, got %q", v)
+	}
+
+	// 已取消的 ctx：不计算任何公式
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	var calculated atomic.Int64
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) { calculated.Add(1) }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.RecalculateAffectedByColumnsWithContext(ctx, map[string]bool{"Sheet1!A": true}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := f.RecalculateAffectedByCellsWithContext(ctx, map[string]bool{"Sheet1!A1": true}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := calculated.Load(); n != 0 {
+		t.Fatalf("expected no formulas calculated with a canceled context, got %d", n)
+	}
+
+	// 计算过程中取消：当前层结束后立即返回，后续层保持旧值
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) {
+		calculated.Add(1)
+		cancel()
+	}
+	start := time.Now()
+	if err := f.RecalculateAffectedByCellsWithContext(ctx, map[string]bool{"Sheet1!A1": true}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("canceled recalculation took too long: %v", elapsed)
+	}
+	if n := calculated.Load(); n == 0 || n >= 2*rows {
+		t.Fatalf("expected a partial recalculation, got %d of %d formulas", n, 2*rows)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "C10"); v != "11" {
+		t.Fatalf("expected the next level to keep its old value, got C10=%q", v)
+	}
+
+	// 未取消时与原方法一致
+	f.OnCellCalculated = nil
+	if err := f.RecalculateAffectedByCellsWithContext(context.Background(), map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalc failed: %v", err)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "C10"); v != "21" {
+		t.Fatalf("expected C10=21 after the update, got %q", v)
+	}
+}
+
+func TestRecalculateAllWithDependencyContextCancel(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	const rows = 3000
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for r := 1; r <= rows; r++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", r), fmt.Sprintf("$A$1*%d", r)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", r), fmt.Sprintf("B%d+1", r)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	goroutines := runtime.NumGoroutine()
+
+	// 已取消的 ctx：不计算任何公式
+	var calculated atomic.Int64
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) { calculated.Add(1) }
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.RecalculateAllWithDependencyContext(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if n := calculated.Load(); n != 0 {
+		t.Fatalf("expected no formulas calculated with a canceled context, got %d", n)
+	}
+
+	// 计算过程中取消：后续层保持旧值
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) {
+		calculated.Add(1)
+		cancel()
+	}
+	start := time.Now()
+	if err := f.RecalculateAllWithDependencyContext(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Fatalf("canceled recalculation took too long: %v", elapsed)
+	}
+	if n := calculated.Load(); n == 0 || n >= 2*rows {
+		t.Fatalf("expected a partial recalculation, got %d of %d formulas", n, 2*rows)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "C10"); v != "" {
+		t.Fatalf("expected the next level to stay uncalculated, got C10=%q", v)
+	}
+
+	// parallelCalculateCells 的 worker 在取消后直接退出
+	graph := f.buildDependencyGraph()
+	if results := f.parallelCalculateCells(ctx, graph.levels[0], NewSubExpressionCache(), nil, graph); len(results) != 0 {
+		t.Fatalf("expected no results with a canceled context, got %d", len(results))
+	}
+
+	// 未取消时与原方法一致
+	f.OnCellCalculated = nil
+	if err := f.RecalculateAllWithDependencyContext(context.Background()); err != nil {
+		t.Fatalf("recalc failed: %v", err)
+	}
+	if v, _ := f.GetCellValue("Sheet1", "C10"); v != "11" {
+		t.Fatalf("expected C10=11, got %q", v)
+	}
+	for i := 0; i < 50 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Fatalf("expected the workers to exit, %d goroutines left of %d", n, goroutines)
+	}
+}
+
+// newPendingFormulas generates n formulas over a data sheet and a formula
+// column, along with the column index and metadata of the formula cells
+func newPendingFormulas(n int) ([]pendingFormula, map[string][]string, map[string]*columnMeta) {
+	formulas := make([]pendingFormula, 0, n)
+	columnIndex := make(map[string][]string)
+	columnMetadata := map[string]*columnMeta{
+		"Data!A":   {maxRow: n},
+		"Data!B":   {maxRow: n},
+		"Sheet1!C": {hasFormulas: true, formulaRows: make(map[int]bool), maxRow: n},
+	}
+	templates := []string{
+		"SUMIFS(Data!$B:$B,Data!$A:$A,A%[2]d)",
+		"C%[1]d+Data!B%[2]d",
+		"SUM(C1:C300)+A%[2]d",
+		"IFERROR(INDEX(Data!$B:$B,MATCH(A%[2]d,Data!$A:$A,0)),0)",
+	}
+	for i := 0; i < n; i++ {
+		row := i + 2
+		cell := "C" + strconv.Itoa(row)
+		formula := fmt.Sprintf(templates[i%len(templates)], row-1, row)
+		formulas = append(formulas, pendingFormula{fullCell: "Sheet1!" + cell, sheet: "Sheet1", cellRef: cell, formula: formula})
+		columnIndex["Sheet1!C"] = append(columnIndex["Sheet1!C"], "Sheet1!"+cell)
+		columnMetadata["Sheet1!C"].formulaRows[row] = true
+	}
+	return formulas, columnIndex, columnMetadata
+}
+
+func TestExtractDependenciesParallelMatchesSerial(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	formulas, columnIndex, columnMetadata := newPendingFormulas(5000)
+	serial := f.extractDependenciesParallel(context.Background(), formulas, columnIndex, columnMetadata, 1)
+	parallel := f.extractDependenciesParallel(context.Background(), formulas, columnIndex, columnMetadata, 8)
+	if len(serial) != len(formulas) || len(parallel) != len(formulas) {
+		t.Fatalf("unexpected result lengths %d and %d for %d formulas", len(serial), len(parallel), len(formulas))
+	}
+	for i, info := range formulas {
+		want := append([]string(nil), serial[i]...)
+		got := append([]string(nil), parallel[i]...)
+		sort.Strings(want)
+		sort.Strings(got)
+		if len(want) == 0 || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("dependencies of %s differ: serial %v, parallel %v", info.fullCell, want, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, deps := range f.extractDependenciesParallel(ctx, formulas, columnIndex, columnMetadata, 8) {
+		if deps != nil {
+			t.Fatalf("expected no dependencies after cancel, got %v for %s", deps, formulas[i].fullCell)
+		}
+	}
+}
+
+func BenchmarkExtractDependenciesParallel(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	defer f.Close()
+	formulas, columnIndex, columnMetadata := newPendingFormulas(500000)
+	for _, workers := range []int{1, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.extractDependenciesParallel(context.Background(), formulas, columnIndex, columnMetadata, workers)
+			}
+		})
+	}
+}
+
+// newChainedGraph creates a dependency graph of the columns of formula chains,
+// each formula depends on the formula above and a data cell. Every tenth
+// column sums the former column, which depends on all formula cells of it.
+func newChainedGraph(columns, rows, workers int) *dependencyGraph {
+	g := &dependencyGraph{nodes: make(map[string]*formulaNode, columns*rows), calcWorkers: workers}
+	for col := 1; col <= columns; col++ {
+		name, _ := ColumnNumberToName(col)
+		prev, _ := ColumnNumberToName(max(col-1, 1))
+		for row := 1; row <= rows; row++ {
+			cell := fmt.Sprintf("Sheet1!%s%d", name, row)
+			deps := []string{fmt.Sprintf("Data!A%d", row)}
+			if row > 1 {
+				deps = append(deps, fmt.Sprintf("Sheet1!%s%d", name, row-1))
+			}
+			if col > 1 && col%10 == 0 {
+				deps = append(deps, "COLUMN:Sheet1!"+prev, "COLUMN:Sheet1!"+prev)
+			}
+			g.nodes[cell] = &formulaNode{cell: cell, formula: "X", dependencies: deps, level: -1}
+		}
+	}
+	return g
+}
+
+func TestAssignLevelsParallel(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// 多个 worker 的层级与单个 worker 一致，包括循环引用的公式链
+	for _, circular := range []bool{false, true} {
+		graphs := make([]*dependencyGraph, 2)
+		for i, workers := range []int{1, 8} {
+			graphs[i] = newChainedGraph(20, 500, workers)
+			if circular {
+				graphs[i].nodes["Sheet1!A1"].dependencies = append(graphs[i].nodes["Sheet1!A1"].dependencies, "Sheet1!A3")
+			}
+			graphs[i].assignLevels()
+		}
+		sequential, parallel := graphs[0], graphs[1]
+		if len(parallel.levels) != len(sequential.levels) {
+			t.Fatalf("got %d levels, want %d", len(parallel.levels), len(sequential.levels))
+		}
+		for cell, node := range parallel.nodes {
+			if want := sequential.nodes[cell].level; node.level != want {
+				t.Fatalf("%s: got level %d, want %d", cell, node.level, want)
+			}
+		}
+		for level, cells := range parallel.levels {
+			if len(cells) != len(sequential.levels[level]) {
+				t.Fatalf("got %d cells in level %d, want %d", len(cells), level, len(sequential.levels[level]))
+			}
+		}
+		if circular {
+			continue
+		}
+		for level, cells := range parallel.levels {
+			for _, cell := range cells {
+				if parallel.nodes[cell].level != level {
+					t.Fatalf("%s listed in level %d, assigned level %d", cell, level, parallel.nodes[cell].level)
+				}
+			}
+		}
+		// The sum of a column is after all of its cells
+		if got, prev := parallel.nodes["Sheet1!J1"].level, parallel.nodes["Sheet1!I500"].level; got != prev+1 {
+			t.Fatalf("got level %d of J1 after I500 of level %d", got, prev)
+		}
+	}
+}
+
+func BenchmarkAssignLevelsChainedGraph(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, workers := range []int{1, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			g := newChainedGraph(100, 1000, workers)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.levels = nil
+				for _, node := range g.nodes {
+					node.level = -1
+				}
+				g.assignLevels()
+			}
+		})
+	}
+}
+
+func TestRecalculateDATEDIFAndYEARFRACWithDependency(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", time.Date(2015, 6, 16, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("set start date: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "B1", time.Date(2021, 2, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("set end date: %v", err)
+	}
+	// The start date of the second row is calculated by a formula in a former level
+	if err := f.SetCellFormula("Sheet1", "A2", "DATE(2018,1,31)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	expected := map[string]string{}
+	for i, unit := range []string{"Y", "M", "D", "YM", "YD", "MD"} {
+		col, _ := ColumnNumberToName(i + 3)
+		for row, want := range map[int]string{
+			1: []string{"5", "67", "2071", "7", "244", "30"}[i],
+			2: []string{"3", "36", "1111", "0", "15", "15"}[i],
+		} {
+			cell := col + strconv.Itoa(row)
+			if err := f.SetCellFormula("Sheet1", cell, fmt.Sprintf(`DATEDIF(A%d,B1,"%s")`, row, unit)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			expected[cell] = want
+		}
+	}
+	for basis, want := range []string{"2.625", "2.62833675564682", "2.66666666666667", "2.63013698630137", "2.62222222222222"} {
+		col, _ := ColumnNumberToName(basis + 3)
+		if err := f.SetCellFormula("Sheet1", col+"3", fmt.Sprintf("YEARFRAC(A1,A2,%d)", basis)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		expected[col+"3"] = want
+	}
+
+	graph, err := f.BuildDependencyGraph()
+	if err != nil {
+		t.Fatalf("build dependency graph: %v", err)
+	}
+	deps := graph.Dependencies("Sheet1!C2")
+	sort.Strings(deps)
+	if strings.Join(deps, ",") != "Sheet1!A2,Sheet1!B1" {
+		t.Fatalf("unexpected DATEDIF dependencies %v", deps)
+	}
+	if deps := graph.Dependents("Sheet1!A2"); len(deps) != 11 {
+		t.Fatalf("expected 6 DATEDIF and 5 YEARFRAC dependents of A2, got %v", deps)
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("%s: got %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package excelize
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FormulaCalcError is the error returned by RecalculateAllWithDependency with
+// CalcTuning.FailFast when a formula fails to calculate with an internal
+// error, such as an invalid formula or a reference to a missing worksheet,
+// rather than producing an Excel error value like #DIV/0!. Err is the error
+// of the calculation.
+type FormulaCalcError struct {
+	Sheet   string
+	Cell    string
+	Formula string
+	Err     error
+}
+
+// Error returns the error message of the failed formula.
+func (e *FormulaCalcError) Error() string {
+	return fmt.Sprintf("calculate %s!%s =%s: %v", e.Sheet, e.Cell, e.Formula, e.Err)
+}
+
+// Unwrap returns the error of the calculation.
+func (e *FormulaCalcError) Unwrap() error {
+	return e.Err
+}
+
+// calcFailureKey is the context key of the calcFailure of a recalculation.
+type calcFailureKey struct{}
+
+// calcFailure cancels a recalculation with CalcTuning.FailFast on the first
+// formula failing with an internal error, it may be reported by the
+// calculation workers concurrently.
+type calcFailure struct {
+	once   sync.Once
+	cancel context.CancelCauseFunc
+}
+
+// withCalcFailure returns the context of a recalculation canceled by the
+// first formula failing with an internal error if CalcTuning.FailFast is
+// enabled, and the function releasing it.
+func (f *File) withCalcFailure(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !f.calcTuning.FailFast {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	ctx = context.WithValue(ctx, calcFailureKey{}, &calcFailure{cancel: cancel})
+	return ctx, func() { cancel(nil) }
+}
+
+// reportCalcFailure cancels the recalculation of ctx with a *FormulaCalcError
+// if it runs with CalcTuning.FailFast and the calculation of the formula
+// failed without producing a value, the later failures are ignored.
+func reportCalcFailure(ctx context.Context, cell, formula, value string, err error) {
+	if err == nil || value != "" {
+		return
+	}
+	failure, _ := ctx.Value(calcFailureKey{}).(*calcFailure)
+	if failure == nil {
+		return
+	}
+	sheet, cellName, _ := splitSheetReference(cell)
+	failure.once.Do(func() {
+		failure.cancel(&FormulaCalcError{Sheet: sheet, Cell: cellName, Formula: formula, Err: err})
+	})
+}
+
+// calcFailureError returns the *FormulaCalcError which canceled the
+// recalculation of ctx, or err if it wasn't canceled by a failed formula.
+func calcFailureError(ctx context.Context, err error) error {
+	if calcErr, ok := context.Cause(ctx).(*FormulaCalcError); ok && err != nil {
+		return calcErr
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package excelize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecalculateAllFailFast(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	newFile := func() *File {
+		f := NewFile()
+		for row := 1; row <= 200; row++ {
+			assert.NoError(t, f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row))
+			assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)))
+			assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("B%d+1", row)))
+		}
+		// Excel 错误值不会中止重算，引用不存在的工作表的公式才会中止
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "1/0"))
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D2", "SUM(Missing!A1)+B2"))
+		return f
+	}
+
+	f := newFile()
+	t.Cleanup(func() { _ = f.Close() })
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	value, err := f.GetCellValue("Sheet1", "C200")
+	assert.NoError(t, err)
+	assert.Equal(t, "401", value)
+
+	f = newFile()
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetCalcTuning(CalcTuning{FailFast: true})
+	err = f.RecalculateAllWithDependency()
+	var calcErr *FormulaCalcError
+	if assert.True(t, errors.As(err, &calcErr), "unexpected error %v", err) {
+		assert.Equal(t, "Sheet1", calcErr.Sheet)
+		assert.Equal(t, "D2", calcErr.Cell)
+		assert.Equal(t, "SUM(Missing!A1)+B2", calcErr.Formula)
+		assert.Error(t, errors.Unwrap(err))
+		assert.Contains(t, err.Error(), "calculate Sheet1!D2 =SUM(Missing!A1)+B2")
+	}
+	// 中止后工作簿仍可正常使用
+	f.SetCalcTuning(CalcTuning{})
+	assert.NoError(t, f.RecalculateAllWithDependency())
+}
@@ -0,0 +1,75 @@
+package excelize
+
+import "context"
+
+// dependencyGraphCache keeps the full dependency graph and the incremental
+// index of the workbook between the incremental recalculations, as long as
+// the formulas and the sheet structure don't change. It's guarded by
+// recalcMu, the cached values are built lazily at the formula generation
+// stored with them.
+type dependencyGraphCache struct {
+	generation uint64
+	graph      *dependencyGraph
+	index      *incrementalIndex
+}
+
+// invalidateDependencyGraph marks the cached dependency graph stale. It's
+// called when a formula is added, removed or changed, or the rows, columns,
+// sheets or defined names change, without taking recalcMu, so it's safe to
+// call while a recalculation is running.
+func (f *File) invalidateDependencyGraph() {
+	f.formulaGeneration.Add(1)
+}
+
+// InvalidateDependencyGraph 使缓存的依赖图失效，下一次增量重算会重新扫描工作表
+// 并构建依赖图。SetCellFormula、覆盖公式的 SetCellValue、插入删除行列、移动行列、
+// 重命名删除工作表以及修改定义名称都会自动使其失效，直接修改工作表 XML 等其他方式
+// 改变公式后需要手动调用。
+func (f *File) InvalidateDependencyGraph() {
+	f.invalidateDependencyGraph()
+}
+
+// validDependencyGraphCache returns the cache of the current formula
+// generation, the stale cached values are dropped. The caller must hold
+// recalcMu.
+func (f *File) validDependencyGraphCache() *dependencyGraphCache {
+	if generation := f.formulaGeneration.Load(); f.depGraphCache.generation != generation {
+		f.depGraphCache = dependencyGraphCache{generation: generation}
+	}
+	return &f.depGraphCache
+}
+
+// cachedDependencyGraph returns the full dependency graph of the workbook,
+// which is built and cached on the first call after the formulas change. The
+// graph is shared by the incremental recalculations and must not be modified.
+// The caller must hold recalcMu.
+func (f *File) cachedDependencyGraph(ctx context.Context) (*dependencyGraph, error) {
+	cache := f.validDependencyGraphCache()
+	if cache.graph != nil {
+		f.logger().Debugf("  ♻️  [Graph Cache] Reusing the dependency graph of %d formulas", len(cache.graph.nodes))
+		return cache.graph, nil
+	}
+	graph, err := f.buildDependencyGraphWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache.graph = graph
+	return graph, nil
+}
+
+// cachedIncrementalIndex returns the incremental index of the workbook, which
+// is built and cached on the first call after the formulas change. The caller
+// must hold recalcMu.
+func (f *File) cachedIncrementalIndex(ctx context.Context, names definedNameRefs) (*incrementalIndex, error) {
+	cache := f.validDependencyGraphCache()
+	if cache.index != nil {
+		f.logger().Debugf("  ♻️  [Graph Cache] Reusing the incremental index of %d formulas", len(cache.index.formulaMap))
+		return cache.index, nil
+	}
+	index, err := f.buildIncrementalIndex(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	cache.index = index
+	return index, nil
+}
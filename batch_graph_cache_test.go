@@ -0,0 +1,120 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestDependencyGraphCache(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 5; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "C1", "SUM(B:B)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+
+	// 连续的增量重算复用同一个依赖图和增量索引
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	index := f.depGraphCache.index
+	if index == nil {
+		t.Fatalf("expected the incremental index to be cached")
+	}
+	if err := f.SetCellValue("Sheet1", "A2", 20); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A2": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	if f.depGraphCache.index != index {
+		t.Fatalf("expected the incremental index to be reused")
+	}
+	if err := f.RecalculateAffectedByColumns(map[string]bool{"Sheet1!A": true}); err != nil {
+		t.Fatalf("recalculate affected columns: %v", err)
+	}
+	graph := f.depGraphCache.graph
+	if graph == nil {
+		t.Fatalf("expected the dependency graph to be cached")
+	}
+	assertValidDependencyGraph(t, graph)
+	if _, err := f.AffectedCellsByColumns(map[string]bool{"Sheet1!A": true}); err != nil {
+		t.Fatalf("affected cells: %v", err)
+	}
+	if f.depGraphCache.graph != graph || f.depGraphCache.index != index {
+		t.Fatalf("expected the cached dependency graph and index to be reused")
+	}
+	if value, _ := f.GetCellValue("Sheet1", "C1"); value != "66" {
+		t.Fatalf("unexpected C1 value %q, want 66", value)
+	}
+
+	// 修改公式后缓存失效，新公式参与增量重算
+	if err := f.SetCellFormula("Sheet1", "D1", "A1+100"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "A1", 10); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	if f.depGraphCache.index == index {
+		t.Fatalf("expected the incremental index to be rebuilt after a formula change")
+	}
+	for cell, want := range map[string]string{"B1": "20", "C1": "84", "D1": "110"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %s", cell, value, want)
+		}
+	}
+
+	// 结构性编辑和手动调用同样使缓存失效
+	for name, edit := range map[string]func() error{
+		"InsertRows":                func() error { return f.InsertRows("Sheet1", 10, 1) },
+		"RemoveCol":                 func() error { return f.RemoveCol("Sheet1", "Z") },
+		"SetCellValue over formula": func() error { return f.SetCellValue("Sheet1", "D1", 1) },
+		"InvalidateDependencyGraph": func() error { f.InvalidateDependencyGraph(); return nil },
+	} {
+		if _, err := f.AffectedCellsByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+			t.Fatalf("affected cells: %v", err)
+		}
+		index := f.depGraphCache.index
+		generation := f.formulaGeneration.Load()
+		if err := edit(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if f.formulaGeneration.Load() == generation {
+			t.Fatalf("expected %s to invalidate the dependency graph", name)
+		}
+		if _, err := f.AffectedCellsByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+			t.Fatalf("affected cells: %v", err)
+		}
+		if f.depGraphCache.index == index {
+			t.Fatalf("expected the incremental index to be rebuilt after %s", name)
+		}
+	}
+
+	// 修改单元格值不影响缓存
+	generation := f.formulaGeneration.Load()
+	if err := f.SetCellValue("Sheet1", "A3", 30); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if f.formulaGeneration.Load() != generation {
+		t.Fatalf("expected a value change to keep the dependency graph")
+	}
+}
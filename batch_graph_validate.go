@@ -0,0 +1,140 @@
+package excelize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Validate checks the invariants of a leveled dependency graph and returns
+// the violations, sorted by message, or nil if the graph is consistent:
+//
+//   - every formula is in exactly one level and every cell of the levels is a
+//     formula of the graph;
+//   - every dependency which isn't a COLUMN dependency is a formula of the
+//     graph or a data cell reference like "Sheet!A1";
+//   - the formulas a formula depends on, directly or by a COLUMN dependency,
+//     are in earlier levels, so no level reads a value calculated in the same
+//     level. The formulas which can't be resolved because of circular
+//     references are exempt among themselves;
+//   - a formula depends on itself only if it's unresolved.
+//
+// It guards the level assignment against regressions in the tests.
+func (g *dependencyGraph) Validate() []error {
+	var errs []error
+	levelOf := make(map[string]int, len(g.nodes))
+	for levelIdx, cells := range g.levels {
+		for _, cell := range cells {
+			if _, ok := g.nodes[cell]; !ok {
+				errs = append(errs, fmt.Errorf("level %d: cell %s is not a formula of the graph", levelIdx, cell))
+				continue
+			}
+			if prev, ok := levelOf[cell]; ok {
+				errs = append(errs, fmt.Errorf("level %d: cell %s is already in level %d", levelIdx, cell, prev))
+				continue
+			}
+			levelOf[cell] = levelIdx
+		}
+	}
+	unresolved := g.unresolvedCells()
+	// 列中已解析公式的最高级别，未解析的公式之间互不检查
+	columnMaxLevel := make(map[string]int)
+	for cell, level := range levelOf {
+		if unresolved[cell] {
+			continue
+		}
+		colKey := cellColumnKey(cell)
+		if maxLevel, ok := columnMaxLevel[colKey]; !ok || level > maxLevel {
+			columnMaxLevel[colKey] = level
+		}
+	}
+	for cell, node := range g.nodes {
+		level, leveled := levelOf[cell]
+		if !leveled {
+			errs = append(errs, fmt.Errorf("cell %s is not in any level", cell))
+		}
+		for _, dep := range node.dependencies {
+			if dep == cell && !unresolved[cell] {
+				errs = append(errs, fmt.Errorf("cell %s: depends on itself but isn't in a cycle", cell))
+				continue
+			}
+			if colKey, ok := strings.CutPrefix(dep, "COLUMN:"); ok {
+				if depLevel, ok := columnMaxLevel[colKey]; ok && leveled && depLevel >= level {
+					errs = append(errs, fmt.Errorf("cell %s at level %d: dependency %s has a formula at level %d", cell, level, dep, depLevel))
+				}
+				continue
+			}
+			if _, ok := g.nodes[dep]; !ok {
+				if !isDataCellReference(dep) {
+					errs = append(errs, fmt.Errorf("cell %s: dependency %s is neither a formula nor a cell reference", cell, dep))
+				}
+				continue
+			}
+			if depLevel, ok := levelOf[dep]; ok && leveled && !(unresolved[cell] && unresolved[dep]) && depLevel >= level {
+				errs = append(errs, fmt.Errorf("cell %s at level %d: dependency %s at level %d", cell, level, dep, depLevel))
+			}
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// unresolvedCells returns the formulas of the graph which can't be ordered
+// because they are in or depend on a circular reference, independently of
+// the level assignment: a formula is resolved once the formulas and the
+// columns it depends on are, a column is resolved once all its formulas are.
+func (g *dependencyGraph) unresolvedCells() map[string]bool {
+	pending := make(map[string]int, len(g.nodes))         // formula or "COLUMN:" key -> unresolved precedents
+	dependents := make(map[string][]string, len(g.nodes)) // formula or "COLUMN:" key -> dependents
+	for cell := range g.nodes {
+		pending["COLUMN:"+cellColumnKey(cell)]++
+	}
+	for cell, node := range g.nodes {
+		for _, dep := range node.dependencies {
+			if _, ok := pending[dep]; ok && strings.HasPrefix(dep, "COLUMN:") {
+				pending[cell]++
+				dependents[dep] = append(dependents[dep], cell)
+			} else if _, ok := g.nodes[dep]; ok {
+				pending[cell]++
+				dependents[dep] = append(dependents[dep], cell)
+			}
+		}
+	}
+	var queue []string
+	for cell := range g.nodes {
+		if pending[cell] == 0 {
+			queue = append(queue, cell)
+		}
+	}
+	unresolved := make(map[string]bool, len(g.nodes))
+	for cell := range g.nodes {
+		unresolved[cell] = true
+	}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+		if !strings.HasPrefix(key, "COLUMN:") {
+			delete(unresolved, key)
+			colKey := "COLUMN:" + cellColumnKey(key)
+			if pending[colKey]--; pending[colKey] == 0 {
+				queue = append(queue, colKey)
+			}
+		}
+		for _, dependent := range dependents[key] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	return unresolved
+}
+
+// isDataCellReference returns if a dependency is a "Sheet!Cell" reference.
+func isDataCellReference(dep string) bool {
+	idx := strings.LastIndex(dep, "!")
+	if idx <= 0 {
+		return false
+	}
+	_, _, err := CellNameToCoordinates(dep[idx+1:])
+	return err == nil
+}
@@ -0,0 +1,77 @@
+package excelize
+
+import (
+	"strings"
+	"testing"
+)
+
+// assertValidDependencyGraph fails the test if the dependency graph violates
+// any invariant checked by Validate.
+func assertValidDependencyGraph(tb testing.TB, graph *dependencyGraph) {
+	tb.Helper()
+	if errs := graph.Validate(); len(errs) > 0 {
+		tb.Fatalf("invalid dependency graph: %v", errs)
+	}
+}
+
+func TestDependencyGraphValidate(t *testing.T) {
+	// 循环引用中的公式及其依赖者位于同一级别，不视为违反
+	graph := &dependencyGraph{
+		nodes: map[string]*formulaNode{
+			"S1!A1": {dependencies: []string{"S1!B1"}, level: -1},
+			"S1!B1": {dependencies: []string{"S1!A1"}, level: -1},
+			"S1!C1": {dependencies: []string{"S1!A1", "S1!D1"}, level: -1},
+			"S1!E1": {dependencies: []string{"S1!E1"}, level: -1},
+			"S1!F1": {dependencies: []string{"S1!D1"}, level: -1},
+			"S1!F2": {dependencies: []string{"COLUMN:S1!F", "COLUMN:S2!A"}, level: -1},
+			"S1!G1": {dependencies: []string{"COLUMN:S1!F"}, level: -1},
+		},
+	}
+	graph.assignLevels()
+	assertValidDependencyGraph(t, graph)
+	unresolved := graph.unresolvedCells()
+	for _, cell := range []string{"S1!A1", "S1!B1", "S1!C1", "S1!E1", "S1!F2", "S1!G1"} {
+		if !unresolved[cell] {
+			t.Fatalf("expected %s to be unresolved, got %v", cell, unresolved)
+		}
+	}
+	if unresolved["S1!F1"] {
+		t.Fatalf("expected S1!F1 to be resolved")
+	}
+
+	// 故意违反各个不变量
+	graph = &dependencyGraph{
+		nodes: map[string]*formulaNode{
+			"S1!A1": {dependencies: []string{"S1!D1"}},
+			"S1!A2": {dependencies: []string{"S1!A1"}},
+			"S1!B1": {dependencies: []string{"COLUMN:S1!A"}},
+			"S1!C1": {dependencies: []string{"S1!SalesCol"}},
+			"S1!C2": {dependencies: []string{}},
+		},
+		levels: [][]string{{"S1!A1", "S1!A2", "S1!B1", "S1!C1"}, {"S1!A2", "S1!X9"}},
+	}
+	errs := graph.Validate()
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	for _, want := range []string{
+		"cell S1!A2 at level 0: dependency S1!A1 at level 0",
+		"cell S1!B1 at level 0: dependency COLUMN:S1!A has a formula at level 0",
+		"cell S1!C1: dependency S1!SalesCol is neither a formula nor a cell reference",
+		"cell S1!C2 is not in any level",
+		"level 1: cell S1!A2 is already in level 0",
+		"level 1: cell S1!X9 is not a formula of the graph",
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || message == want
+		}
+		if !found {
+			t.Fatalf("expected violation %q, got:\n%s", want, strings.Join(messages, "\n"))
+		}
+	}
+	if len(errs) != 6 {
+		t.Fatalf("expected 6 violations, got:\n%s", strings.Join(messages, "\n"))
+	}
+}
@@ -0,0 +1,182 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// hlookupPattern is a group of exact match HLOOKUP formulas over the same
+// table, e.g. HLOOKUP(A$1,Data!$A$1:$Z$100,5,FALSE) filled across a crosstab.
+// The formulas may return different rows of the table, the header row is
+// indexed once for all of them.
+type hlookupPattern struct {
+	table    lookupTable
+	formulas map[string]*hlookupFormula // "Sheet!Cell" -> formula info
+}
+
+// hlookupFormula is an HLOOKUP formula of a pattern
+type hlookupFormula struct {
+	sheet    string
+	lookup   string // lookup value argument: cell reference or literal
+	rowIndex int    // 1-based row of the table returned
+}
+
+// hlookupExact represents an exact match HLOOKUP with a constant row index,
+// e.g. HLOOKUP(A$1,Data!$A$1:$Z$100,5,FALSE)
+type hlookupExact struct {
+	lookup   string
+	table    lookupTable
+	rowIndex int
+}
+
+// parseHLOOKUPExact parses an exact match HLOOKUP expression, the sheet of an
+// unqualified table range defaults to currentSheet
+func parseHLOOKUPExact(expr, currentSheet string) (*hlookupExact, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "HLOOKUP(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "HLOOKUP")
+	if "HLOOKUP("+content+")" != expr {
+		return nil, false
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != 4 {
+		return nil, false
+	}
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if rangeLookup := strings.ToUpper(args[3]); rangeLookup != "0" && rangeLookup != "FALSE" {
+		return nil, false
+	}
+	rowIndex, err := strconv.Atoi(args[2])
+	if err != nil || rowIndex < 1 {
+		return nil, false
+	}
+	table, ok := parseLookupTable(args[1], currentSheet)
+	if !ok || rowIndex > table.endRow-table.startRow+1 {
+		return nil, false
+	}
+	if args[0] == "" || strings.ContainsAny(args[0], "(),:") {
+		return nil, false
+	}
+	return &hlookupExact{lookup: args[0], table: table, rowIndex: rowIndex}, true
+}
+
+// extractHLOOKUPPattern extracts the pattern of a formula which is a single
+// exact match HLOOKUP with a constant row index. Approximate match lookups
+// return nil and are calculated one by one.
+func (f *File) extractHLOOKUPPattern(sheet, cell, formula string) *hlookupPattern {
+	lookup, ok := parseHLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+	if !ok {
+		return nil
+	}
+	return &hlookupPattern{
+		table: lookup.table,
+		formulas: map[string]*hlookupFormula{
+			sheet + "!" + cell: {sheet: sheet, lookup: lookup.lookup, rowIndex: lookup.rowIndex},
+		},
+	}
+}
+
+// isHLOOKUPFormula reports whether the formula is a single exact match HLOOKUP
+func isHLOOKUPFormula(formula string) bool {
+	_, ok := parseHLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), "")
+	return ok
+}
+
+// groupHLOOKUPByPattern groups HLOOKUP formulas by their table
+func (f *File) groupHLOOKUPByPattern(formulas map[string]string) []*hlookupPattern {
+	patterns := make(map[lookupTable]*hlookupPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		pattern := f.extractHLOOKUPPattern(sheet, cell, formula)
+		if pattern == nil {
+			continue
+		}
+		if existing, exists := patterns[pattern.table]; exists {
+			for c, info := range pattern.formulas {
+				existing.formulas[c] = info
+			}
+			continue
+		}
+		patterns[pattern.table] = pattern
+	}
+	result := make([]*hlookupPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+// buildHeaderRowIndex maps the keys of the values of the first row of the
+// table to their column index, the first matching column wins like HLOOKUP
+// does
+func (f *File) buildHeaderRowIndex(rows [][]string, table lookupTable) map[string]int {
+	index := make(map[string]int)
+	if table.startRow > len(rows) {
+		return index
+	}
+	header := rows[table.startRow-1]
+	for colIdx := table.startCol - 1; colIdx < len(header) && colIdx < table.endCol; colIdx++ {
+		value := header[colIdx]
+		if value == "" {
+			continue
+		}
+		key := f.lookupTableKey(value)
+		if _, exists := index[key]; !exists {
+			index[key] = colIdx
+		}
+	}
+	return index
+}
+
+// calculateHLOOKUPPatternWithCache calculates the formulas of a pattern with a
+// single scan of the header row, the results calculated in former levels are
+// read from worksheetCache. A lookup value which is not found gives #N/A.
+// Lookup values with wildcards are left out of the result and calculated one
+// by one.
+func (f *File) calculateHLOOKUPPatternWithCache(pattern *hlookupPattern, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string, len(pattern.formulas))
+	fileRows, err := f.getCachedRawRows(pattern.table.sheet)
+	if err != nil {
+		return results
+	}
+	rows := mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(pattern.table.sheet))
+	index := f.buildHeaderRowIndex(rows, pattern.table)
+	for fullCell, info := range pattern.formulas {
+		value := f.resolveLookupValue(info.sheet, info.lookup, worksheetCache)
+		if strings.ContainsAny(value, "*?~") {
+			continue
+		}
+		colIdx, found := index[f.lookupTableKey(value)]
+		if !found {
+			results[fullCell] = formulaErrorNA
+			continue
+		}
+		results[fullCell] = ""
+		if rowIdx := pattern.table.startRow + info.rowIndex - 2; rowIdx < len(rows) && colIdx < len(rows[rowIdx]) {
+			results[fullCell] = rows[rowIdx][colIdx]
+		}
+	}
+	return results
+}
+
+// batchCalculateHLOOKUPWithCache calculates exact match HLOOKUP formulas
+// grouped by their table. The formulas parameter maps "Sheet!Cell" to formula,
+// it returns the results by cell and the number of scanned tables.
+func (f *File) batchCalculateHLOOKUPWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupHLOOKUPByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateHLOOKUPPatternWithCache(pattern, worksheetCache) {
+			results[cell] = value
+		}
+	}
+	f.logger().Debugf("  ⚡ [HLOOKUP Batch] %d HLOOKUP formulas over %d distinct tables", len(results), len(patterns))
+	return results, len(patterns)
+}
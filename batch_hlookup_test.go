@@ -0,0 +1,104 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestExtractHLOOKUPPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for formula, want := range map[string]bool{
+		"=HLOOKUP(A$1,Data!$A$1:$Z$100,5,FALSE)": true,
+		"HLOOKUP(A1,Data!$A:$Z,2,0)":             true,
+		"HLOOKUP(A1,$A$1:$D$3,3,0)":              true,
+		"HLOOKUP(A1,$A$1:$D$3,4,0)":              false,
+		"HLOOKUP(A1,Data!$A$1:$D$9,2,TRUE)":      false,
+		"HLOOKUP(A1,Data!$A$1:$D$9,2)":           false,
+		"HLOOKUP(A1,Data!$A$1:$D$9,B1,0)":        false,
+		"HLOOKUP(A1,Data!$A$1:$D$9,2,0)*2":       false,
+		"VLOOKUP(A1,Data!$A$1:$D$9,2,0)":         false,
+	} {
+		if got := f.extractHLOOKUPPattern("Sheet1", "B2", formula) != nil; got != want {
+			t.Fatalf("extractHLOOKUPPattern(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	pattern := f.extractHLOOKUPPattern("Sheet1", "B2", "HLOOKUP(A1,$A$1:$D$3,3,0)")
+	if pattern.table.sheet != "Sheet1" || pattern.formulas["Sheet1!B2"].rowIndex != 3 {
+		t.Fatalf("unexpected pattern %+v", pattern)
+	}
+}
+
+func TestBatchCalculateHLOOKUP(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"Q1", "Q2", "Q3", "Q2", "Q4", "Q5"},
+		{10, 20, 30, 99, 40},
+		{"a", "b", nil, "dup", "d", "e"},
+		{1.5, 2.5, 3.5, 9.5, 4.5, 5.5},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	headers := []string{"Q1", "q2", "Q3", "Q4", "Q5", "Q9"}
+	for i, header := range headers {
+		col, _ := ColumnNumberToName(i + 2)
+		if err := f.SetCellValue("Sheet1", col+"1", header); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for row, formula := range map[int]string{
+			2: "HLOOKUP(%s$1,Data!$A$1:$F$4,2,FALSE)",
+			3: "HLOOKUP(%s$1,Data!$A$1:$F$4,3,0)",
+			4: "HLOOKUP(%s$1,Data!$A:$F,4,0)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, col)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 批量结果与逐个计算的结果一致
+	want := make(map[string]string)
+	for i := range headers {
+		col, _ := ColumnNumberToName(i + 2)
+		for row := 2; row <= 4; row++ {
+			cell := fmt.Sprintf("%s%d", col, row)
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorNA {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[cell] = value
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{"C2": "20", "C3": "b", "D3": "", "F4": "5.5", "G2": formulaErrorNA} {
+		if want[cell] != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, want[cell], value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.HLOOKUPFormulas != 3*len(headers) || plan.HLOOKUPTables != 2 {
+		t.Fatalf("unexpected plan HLOOKUP formulas %d, tables %d", plan.HLOOKUPFormulas, plan.HLOOKUPTables)
+	}
+}
@@ -15,10 +15,9 @@ type indexMatch1DPattern struct {
 }
 
 type indexMatch1DFormula struct {
-	cell          string
-	sheet         string
-	lookupCell    string // e.g., "A2"
-	fallbackValue string // IFERROR fallback value
+	cell       string
+	sheet      string
+	lookupCell string // e.g., "A2"
 }
 
 // averageIndexMatchPattern represents AVERAGE(INDEX(range, MATCH(...), 0)) pattern
@@ -256,6 +255,17 @@ func (f *File) extractINDEXMATCH2DPattern(sheet, cell, formula string) *indexMat
 		// Not a 2D INDEX-MATCH pattern
 		return nil
 	}
+	// 省略工作表名称的查找范围属于公式所在的工作表。行查找范围可以与数组在不同的
+	// 工作表，列标题从数组工作表的第一行读取，列查找范围需要与数组在同一工作表
+	if !strings.Contains(matchRange1, "!") {
+		matchRange1 = escapeSheetName(sheet) + "!" + matchRange1
+	}
+	if !strings.Contains(matchRange2, "!") {
+		matchRange2 = escapeSheetName(sheet) + "!" + matchRange2
+	}
+	if arraySheet := extractSheetName(arrayRange); arraySheet != "" && extractSheetName(matchRange2) != arraySheet {
+		return nil
+	}
 
 	// Create pattern
 	pattern := &indexMatch2DPattern{
@@ -305,28 +315,20 @@ func (f *File) calculateINDEXMATCH2DPattern(pattern *indexMatch2DPattern) map[st
 	endCol := colParts[1]
 
 	// Read the array data (entire range)
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return results
 	}
 
 	// Build row lookup map (first MATCH dimension)
-	// Parse matchRange1: e.g., "日销预测!$A:$A"
-	matchCol1 := extractColumnFromRange(pattern.matchRange1)
-	matchCol1Idx, _ := ColumnNameToNumber(matchCol1)
-	matchCol1Idx-- // Convert to 0-based
-
-	rowLookupMap := make(map[string]int) // value -> row index
-	if matchCol1Idx >= 0 {
-		for rowIdx, row := range rows {
-			if matchCol1Idx < len(row) {
-				value := row[matchCol1Idx]
-				if value != "" {
-					rowLookupMap[value] = rowIdx
-				}
-			}
+	// Parse matchRange1: e.g., "日销预测!$A:$A", which may be on another sheet
+	matchRows := rows
+	if matchSheet := extractSheetName(pattern.matchRange1); matchSheet != "" && matchSheet != sourceSheet {
+		if matchRows, err = f.getUsedRows(matchSheet); err != nil {
+			return results
 		}
 	}
+	rowLookupMap := f.indexMatchRowLookup(matchRows, pattern.matchRange1, pattern.arrayRange) // value -> row index
 
 	// Build column lookup map (second MATCH dimension)
 	// Parse matchRange2: e.g., "日销预测!$G$1:$ZZ$1"
@@ -343,7 +345,7 @@ func (f *File) calculateINDEXMATCH2DPattern(pattern *indexMatch2DPattern) map[st
 		for colIdx := startColIdx; colIdx <= endColIdx && colIdx < len(headerRow); colIdx++ {
 			value := headerRow[colIdx]
 			if value != "" {
-				colLookupMap[value] = colIdx - startColIdx
+				colLookupMap[f.normalizeLookupKey(value)] = colIdx - startColIdx
 			}
 		}
 	}
@@ -427,19 +429,21 @@ func (f *File) calculateINDEXMATCH2DPattern(pattern *indexMatch2DPattern) map[st
 		}
 
 		// Lookup in the 2D array
-		if rowIdx, ok := rowLookupMap[lookup1Value]; ok {
-			if colOffset, ok := colLookupMap[lookup2Value]; ok {
+		if rowIdx, ok := rowLookupMap[f.normalizeLookupKey(lookup1Value)]; ok {
+			if colOffset, ok := colLookupMap[f.normalizeLookupKey(lookup2Value)]; ok {
 				actualColIdx := startColIdx + colOffset
-				if rowIdx < len(rows) && actualColIdx < len(rows[rowIdx]) {
+				if rowIdx < 0 {
+					results[fullCell] = formulaErrorREF
+				} else if rowIdx < len(rows) && actualColIdx < len(rows[rowIdx]) {
 					results[fullCell] = rows[rowIdx][actualColIdx]
 				} else {
-					results[fullCell] = "0"
+					results[fullCell] = ""
 				}
 			} else {
-				results[fullCell] = "0"
+				results[fullCell] = formulaErrorNA
 			}
 		} else {
-			results[fullCell] = "0"
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -526,6 +530,91 @@ func splitFunctionArgs(argsStr string) []string {
 	return result
 }
 
+// errorGuard describes an error-guard wrapper around a lookup expression.
+// Supported forms:
+//
+//	IFERROR(expr, default)
+//	IFNA(expr, default)
+//	IF(ISERROR(expr), default, expr)
+//	IF(ISNA(expr), default, expr)
+type errorGuard struct {
+	function string // IFERROR, IFNA, ISERROR or ISNA
+	expr     string // guarded lookup expression
+	fallback string // default argument as written in the formula
+}
+
+// naOnly reports whether the guard only traps #N/A (IFNA / ISNA).
+func (g *errorGuard) naOnly() bool {
+	return g.function == "IFNA" || g.function == "ISNA"
+}
+
+// traps reports whether the given lookup result is caught by the guard, in
+// which case the formula evaluates to the default argument.
+func (g *errorGuard) traps(value string) bool {
+	if g.naOnly() {
+		return value == formulaErrorNA
+	}
+	return isFormulaErrorValue(value)
+}
+
+// isFormulaErrorValue reports whether the cell value is a formula error
+func isFormulaErrorValue(value string) bool {
+	switch value {
+	case formulaErrorDIV, formulaErrorNAME, formulaErrorNA, formulaErrorNUM,
+		formulaErrorVALUE, formulaErrorREF, formulaErrorNULL, formulaErrorSPILL,
+		formulaErrorCALC, formulaErrorGETTINGDATA:
+		return true
+	}
+	return false
+}
+
+// extractErrorGuard recognizes a formula that is entirely an error-guard
+// wrapper around a single expression, returns nil for any other formula.
+func extractErrorGuard(formula string) *errorGuard {
+	work := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(formula), "="))
+	for _, fn := range []string{"IFERROR", "IFNA"} {
+		if !strings.HasPrefix(work, fn+"(") {
+			continue
+		}
+		content := extractFunctionCall(work, fn)
+		if fn+"("+content+")" != work {
+			return nil
+		}
+		args := splitFunctionArgs(content)
+		if len(args) != 2 {
+			return nil
+		}
+		return &errorGuard{function: fn, expr: strings.TrimSpace(args[0]), fallback: strings.TrimSpace(args[1])}
+	}
+	if !strings.HasPrefix(work, "IF(") {
+		return nil
+	}
+	content := extractFunctionCall(work, "IF")
+	if "IF("+content+")" != work {
+		return nil
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != 3 {
+		return nil
+	}
+	test := strings.TrimSpace(args[0])
+	for _, fn := range []string{"ISERROR", "ISNA"} {
+		if !strings.HasPrefix(test, fn+"(") {
+			continue
+		}
+		inner := extractFunctionCall(test, fn)
+		if fn+"("+inner+")" != test {
+			return nil
+		}
+		expr := strings.TrimSpace(inner)
+		if expr == "" || expr != strings.TrimSpace(args[2]) {
+			return nil
+		}
+		return &errorGuard{function: fn, expr: expr, fallback: strings.TrimSpace(args[1])}
+	}
+	return nil
+}
+
 // extractINDEXMATCHFromFormula extracts INDEX-MATCH expression from a formula
 func extractINDEXMATCHFromFormula(formula string) string {
 	// Find "INDEX(" in the formula (may be nested in IFERROR)
@@ -570,23 +659,14 @@ func (f *File) extractINDEXMATCH1DPattern(sheet, cell, formula string) *indexMat
 		return nil
 	}
 
-	// Extract IFERROR fallback value if present
-	var fallbackValue string
+	// Unwrap IFERROR / IFNA / IF(ISERROR(...)) / IF(ISNA(...)), the fallback
+	// value is applied by the caller on a missed lookup
 	workFormula := strings.TrimPrefix(formula, "=")
 	originalFormula := workFormula
 
-	if strings.HasPrefix(workFormula, "IFERROR(") {
-		// Extract the full IFERROR expression
-		iferrorContent := extractFunctionCall(workFormula, "IFERROR")
-		if iferrorContent != "" {
-			// Split IFERROR arguments: first is the formula, second is the fallback
-			iferrorArgs := splitFunctionArgs(iferrorContent)
-			if len(iferrorArgs) >= 2 {
-				fallbackValue = strings.TrimSpace(iferrorArgs[1])
-				// Remove quotes if it's a literal string
-				fallbackValue = strings.Trim(fallbackValue, `"'`)
-			}
-		}
+	if guard := extractErrorGuard(workFormula); guard != nil {
+		// IF(ISERROR(x),d,x) repeats the lookup, keep only the guarded expression
+		originalFormula = guard.expr
 	}
 
 	// Remove wrapper functions (IFERROR, AVERAGE, etc.) to find INDEX
@@ -660,6 +740,10 @@ func (f *File) extractINDEXMATCH1DPattern(sheet, cell, formula string) *indexMat
 
 	lookupCell := strings.TrimSpace(matchArgs[0])
 	matchRange := strings.TrimSpace(matchArgs[1])
+	// 省略工作表名称的查找范围属于公式所在的工作表，可以与数组在不同的工作表
+	if !strings.Contains(matchRange, "!") {
+		matchRange = escapeSheetName(sheet) + "!" + matchRange
+	}
 
 	// Create pattern
 	pattern := &indexMatch1DPattern{
@@ -669,15 +753,52 @@ func (f *File) extractINDEXMATCH1DPattern(sheet, cell, formula string) *indexMat
 	}
 
 	pattern.formulas[sheet+"!"+cell] = &indexMatch1DFormula{
-		cell:          cell,
-		sheet:         sheet,
-		lookupCell:    lookupCell,
-		fallbackValue: fallbackValue,
+		cell:       cell,
+		sheet:      sheet,
+		lookupCell: lookupCell,
 	}
 
 	return pattern
 }
 
+// indexMatchRowLookup indexes the values of the MATCH range of an INDEX-MATCH
+// pattern by the 0-based rows of the INDEX array. The match range may be on
+// another sheet than the array, the matchRows are the rows of its sheet, and
+// the n-th cell of the match range is aligned to the n-th row of the array.
+// The values matching beyond the last row of the array map to -1, INDEX gives
+// #REF! for them. It keeps the first row of the duplicate values like MATCH.
+func (f *File) indexMatchRowLookup(matchRows [][]string, matchRange, arrayRange string) map[string]int {
+	lookupMap := make(map[string]int)
+	matchColIdx, err := ColumnNameToNumber(extractColumnFromRange(matchRange))
+	if err != nil {
+		return lookupMap
+	}
+	matchColIdx--
+	match, ok := parseLookupTable(matchRange, "")
+	if !ok {
+		match = lookupTable{startRow: 1, endRow: TotalRows}
+	}
+	array, ok := parseLookupTable(arrayRange, "")
+	if !ok {
+		array = lookupTable{startRow: match.startRow, endRow: TotalRows}
+	}
+	for rowIdx := match.startRow - 1; rowIdx < len(matchRows) && rowIdx < match.endRow; rowIdx++ {
+		if matchColIdx >= len(matchRows[rowIdx]) || matchRows[rowIdx][matchColIdx] == "" {
+			continue
+		}
+		key := f.normalizeLookupKey(matchRows[rowIdx][matchColIdx])
+		if _, exists := lookupMap[key]; exists {
+			continue
+		}
+		arrayRowIdx := rowIdx - match.startRow + array.startRow
+		if arrayRowIdx >= array.endRow {
+			arrayRowIdx = -1
+		}
+		lookupMap[key] = arrayRowIdx
+	}
+	return lookupMap
+}
+
 // calculateINDEXMATCH1DPattern calculates a batch of 1D INDEX-MATCH formulas
 func (f *File) calculateINDEXMATCH1DPattern(pattern *indexMatch1DPattern) map[string]string {
 	results := make(map[string]string)
@@ -688,47 +809,29 @@ func (f *File) calculateINDEXMATCH1DPattern(pattern *indexMatch1DPattern) map[st
 		return results
 	}
 
-	// Parse array range to get column
-	arrayParts := strings.Split(pattern.arrayRange, "!")
-	if len(arrayParts) != 2 {
+	// Parse array range to get column, e.g. "Data!$B:$B" or "Data!$B$5:$B$14" -> B
+	arrayColIdx, err := ColumnNameToNumber(extractColumnFromRange(pattern.arrayRange))
+	if err != nil {
 		return results
 	}
-
-	arrayColPart := strings.ReplaceAll(arrayParts[1], "$", "")
-	// Handle column range like "B:B" - extract just the first column
-	if strings.Contains(arrayColPart, ":") {
-		parts := strings.Split(arrayColPart, ":")
-		if len(parts) > 0 {
-			arrayColPart = parts[0]
-		}
-	}
-	arrayColIdx, _ := ColumnNameToNumber(arrayColPart)
 	arrayColIdx-- // Convert to 0-based
 
-	// Build lookup map from match range
-	matchCol := extractColumnFromRange(pattern.matchRange)
-	matchColIdx, _ := ColumnNameToNumber(matchCol)
-	matchColIdx-- // Convert to 0-based
-
 	// Read source data
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return results
 	}
-
-	// Build lookup map: value -> row index
-	lookupMap := make(map[string]int)
-	if matchColIdx >= 0 {
-		for rowIdx, row := range rows {
-			if matchColIdx < len(row) {
-				value := row[matchColIdx]
-				if value != "" {
-					lookupMap[value] = rowIdx
-				}
-			}
+	// The match range may be on another sheet
+	matchRows := rows
+	if matchSheet := extractSheetName(pattern.matchRange); matchSheet != "" && matchSheet != sourceSheet {
+		if matchRows, err = f.getUsedRows(matchSheet); err != nil {
+			return results
 		}
 	}
 
+	// Build lookup map: value -> row index
+	lookupMap := f.indexMatchRowLookup(matchRows, pattern.matchRange, pattern.arrayRange)
+
 	// Note: This function doesn't have worksheetCache available, so it uses the old approach
 	// It's only used in non-optimized batch calculations
 	// Calculate results for all formulas
@@ -738,27 +841,17 @@ func (f *File) calculateINDEXMATCH1DPattern(pattern *indexMatch1DPattern) map[st
 		lookupValue, _ := f.GetCellValue(info.sheet, lookupCell)
 
 		// Lookup in the array
-		if rowIdx, ok := lookupMap[lookupValue]; ok {
-			if rowIdx < len(rows) && arrayColIdx < len(rows[rowIdx]) {
+		if rowIdx, ok := lookupMap[f.normalizeLookupKey(lookupValue)]; ok {
+			if rowIdx < 0 {
+				results[fullCell] = formulaErrorREF
+			} else if rowIdx < len(rows) && arrayColIdx < len(rows[rowIdx]) {
 				results[fullCell] = rows[rowIdx][arrayColIdx]
 			} else {
 				results[fullCell] = ""
 			}
 		} else {
-			// No match found - use fallback value if available
-			if info.fallbackValue != "" {
-				// Fallback value can be a cell reference or a literal value
-				if isCellReference(info.fallbackValue) {
-					// It's a cell reference like "A1"
-					fallbackVal, _ := f.GetCellValue(info.sheet, info.fallbackValue)
-					results[fullCell] = fallbackVal
-				} else {
-					// It's a literal value
-					results[fullCell] = info.fallbackValue
-				}
-			} else {
-				results[fullCell] = ""
-			}
+			// No match found, the IFERROR fallback value is applied by the caller
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -868,20 +961,10 @@ func (f *File) calculateAverageIndexMatchPatternWithCache(pattern *averageIndexM
 	}
 
 	// Parse array range to get column range (e.g., "$C:$O" -> C to O)
-	arrayParts := strings.Split(pattern.arrayRange, "!")
-	if len(arrayParts) != 2 {
+	startCol, endCol, ok := extractColumnRangeFromRange(pattern.arrayRange)
+	if !ok {
 		return results
 	}
-
-	// Parse column range like "$C:$O"
-	rangePart := strings.ReplaceAll(arrayParts[1], "$", "")
-	rangeParts := strings.Split(rangePart, ":")
-	if len(rangeParts) != 2 {
-		return results
-	}
-
-	startCol := rangeParts[0]
-	endCol := rangeParts[1]
 	startColIdx, _ := ColumnNameToNumber(startCol)
 	endColIdx, _ := ColumnNameToNumber(endCol)
 
@@ -891,28 +974,13 @@ func (f *File) calculateAverageIndexMatchPatternWithCache(pattern *averageIndexM
 
 	// Read data: First read from file, then merge cached results
 	// This is critical: worksheetCache has recalculated formula results that override original data
-	fileRows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
+	fileRows, err := f.getCachedRawRows(sourceSheet)
 	if err != nil || len(fileRows) == 0 {
 		return results
 	}
 
 	// Merge cached formula results into rows
-	sheetData := worksheetCache.GetSheet(sourceSheet)
-	for cellRef, argValue := range sheetData {
-		col, row, err := CellNameToCoordinates(cellRef)
-		if err != nil {
-			continue
-		}
-		// Ensure fileRows array is large enough
-		for len(fileRows) < row {
-			fileRows = append(fileRows, make([]string, 0))
-		}
-		// Ensure row is large enough
-		for len(fileRows[row-1]) < col {
-			fileRows[row-1] = append(fileRows[row-1], "")
-		}
-		fileRows[row-1][col-1] = argValue.Value()
-	}
+	fileRows = mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(sourceSheet))
 
 	// Build lookup map: value -> row index (0-based)
 	lookupMap := make(map[string]int)
@@ -920,7 +988,7 @@ func (f *File) calculateAverageIndexMatchPatternWithCache(pattern *averageIndexM
 		if matchColIdx-1 < len(row) {
 			value := row[matchColIdx-1]
 			if value != "" {
-				lookupMap[value] = rowIdx
+				lookupMap[f.normalizeLookupKey(value)] = rowIdx
 			}
 		}
 	}
@@ -932,7 +1000,7 @@ func (f *File) calculateAverageIndexMatchPatternWithCache(pattern *averageIndexM
 		lookupValue := f.getCellValueOrCalcCache(info.sheet, lookupCell, worksheetCache)
 
 		// Lookup in the map
-		if rowIdx, ok := lookupMap[lookupValue]; ok {
+		if rowIdx, ok := lookupMap[f.normalizeLookupKey(lookupValue)]; ok {
 			if rowIdx >= 0 && rowIdx < len(fileRows) {
 				// Calculate average of the row range (startColIdx to endColIdx, 1-based)
 				sum := 0.0
@@ -952,13 +1020,13 @@ func (f *File) calculateAverageIndexMatchPatternWithCache(pattern *averageIndexM
 					avg := sum / float64(count)
 					results[fullCell] = fmt.Sprintf("%g", avg)
 				} else {
-					results[fullCell] = "0"
+					results[fullCell] = formulaErrorDIV
 				}
 			} else {
-				results[fullCell] = "0"
+				results[fullCell] = formulaErrorNA
 			}
 		} else {
-			results[fullCell] = "0"
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -976,20 +1044,10 @@ func (f *File) calculateAverageIndexMatchPattern(pattern *averageIndexMatchPatte
 	}
 
 	// Parse array range to get column range (e.g., "$C:$O" -> C to O)
-	arrayParts := strings.Split(pattern.arrayRange, "!")
-	if len(arrayParts) != 2 {
+	startCol, endCol, ok := extractColumnRangeFromRange(pattern.arrayRange)
+	if !ok {
 		return results
 	}
-
-	// Parse column range like "$C:$O"
-	rangePart := strings.ReplaceAll(arrayParts[1], "$", "")
-	rangeParts := strings.Split(rangePart, ":")
-	if len(rangeParts) != 2 {
-		return results
-	}
-
-	startCol := rangeParts[0]
-	endCol := rangeParts[1]
 	startColIdx, _ := ColumnNameToNumber(startCol)
 	endColIdx, _ := ColumnNameToNumber(endCol)
 
@@ -998,7 +1056,7 @@ func (f *File) calculateAverageIndexMatchPattern(pattern *averageIndexMatchPatte
 	matchColIdx, _ := ColumnNameToNumber(matchCol)
 
 	// Read data using GetRows (legacy method)
-	rows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
+	rows, err := f.getCachedRawRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return results
 	}
@@ -1009,7 +1067,7 @@ func (f *File) calculateAverageIndexMatchPattern(pattern *averageIndexMatchPatte
 		if matchColIdx-1 < len(row) {
 			value := row[matchColIdx-1]
 			if value != "" {
-				lookupMap[value] = rowIdx
+				lookupMap[f.normalizeLookupKey(value)] = rowIdx
 			}
 		}
 	}
@@ -1021,7 +1079,7 @@ func (f *File) calculateAverageIndexMatchPattern(pattern *averageIndexMatchPatte
 		lookupValue, _ := f.GetCellValue(info.sheet, lookupCell)
 
 		// Lookup in the map
-		if rowIdx, ok := lookupMap[lookupValue]; ok {
+		if rowIdx, ok := lookupMap[f.normalizeLookupKey(lookupValue)]; ok {
 			if rowIdx >= 0 && rowIdx < len(rows) {
 				// Calculate average of the row range (startColIdx to endColIdx, 1-based)
 				sum := 0.0
@@ -1041,13 +1099,13 @@ func (f *File) calculateAverageIndexMatchPattern(pattern *averageIndexMatchPatte
 					avg := sum / float64(count)
 					results[fullCell] = fmt.Sprintf("%g", avg)
 				} else {
-					results[fullCell] = "0"
+					results[fullCell] = formulaErrorDIV
 				}
 			} else {
-				results[fullCell] = "0"
+				results[fullCell] = formulaErrorNA
 			}
 		} else {
-			results[fullCell] = "0"
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -1105,6 +1163,7 @@ func (f *File) batchCalculateINDEXMATCHWithCache(formulas map[string]string, wor
 	patterns2D := make(map[string]*indexMatch2DPattern)
 	patternsAvg := make(map[string]*averageIndexMatchPattern)
 	patternsMultiCond := make(map[string]*indexMatchMultiCondPattern)
+	patternsColumn := make(map[[2]lookupTable]*indexMatchColumnPattern)
 
 	for fullCell, formula := range formulas {
 		parts := strings.Split(fullCell, "!")
@@ -1163,6 +1222,19 @@ func (f *File) batchCalculateINDEXMATCHWithCache(formulas map[string]string, wor
 			continue
 		}
 
+		// Try multi-column array with the column argument resolved per formula
+		if patternColumn := f.extractINDEXMATCHColumnPattern(sheet, cell, formula); patternColumn != nil {
+			key := [2]lookupTable{patternColumn.array, patternColumn.match}
+			if existing, exists := patternsColumn[key]; exists {
+				for k, v := range patternColumn.formulas {
+					existing.formulas[k] = v
+				}
+			} else {
+				patternsColumn[key] = patternColumn
+			}
+			continue
+		}
+
 		// Try 1D pattern
 		pattern1D := f.extractINDEXMATCH1DPattern(sheet, cell, formula)
 		if pattern1D != nil {
@@ -1209,6 +1281,13 @@ func (f *File) batchCalculateINDEXMATCHWithCache(formulas map[string]string, wor
 		}
 	}
 
+	// Calculate multi-column patterns (use worksheetCache)
+	for _, pattern := range patternsColumn {
+		for cell, value := range f.calculateINDEXMATCHColumnPatternWithCache(pattern, worksheetCache) {
+			results[cell] = value
+		}
+	}
+
 	return results
 }
 
@@ -1243,46 +1322,25 @@ func (f *File) calculateINDEXMATCH2DPatternWithCache(pattern *indexMatch2DPatter
 	// For INDEX-MATCH, we need the original data (e.g., A column for MATCH lookup)
 	sheetData := worksheetCache.GetSheet(sourceSheet)
 
-	// Build lookup maps
-	matchCol1 := extractColumnFromRange(pattern.matchRange1)
-	matchCol1Idx, _ := ColumnNameToNumber(matchCol1)
-	matchCol1Idx--
-
 	// Always read from file to get original data
-	fileRows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
+	fileRows, err := f.getCachedRawRows(sourceSheet)
 	if err != nil || len(fileRows) == 0 {
 		return results
 	}
-	rows := fileRows
 
 	// Merge cached formula results into rows
-	if len(sheetData) > 0 {
-		for cellRef, argValue := range sheetData {
-			col, row, err := CellNameToCoordinates(cellRef)
-			if err != nil {
-				continue
-			}
-			for len(rows) < row {
-				rows = append(rows, make([]string, 0))
-			}
-			for len(rows[row-1]) < col {
-				rows[row-1] = append(rows[row-1], "")
-			}
-			rows[row-1][col-1] = argValue.Value()
-		}
-	}
+	rows := mergeSheetCacheIntoRows(fileRows, sheetData)
 
-	rowLookupMap := make(map[string]int)
-	if matchCol1Idx >= 0 {
-		for rowIdx, row := range rows {
-			if matchCol1Idx < len(row) {
-				value := row[matchCol1Idx]
-				if value != "" {
-					rowLookupMap[value] = rowIdx
-				}
-			}
+	// Build lookup maps, the row match range may be on another sheet
+	matchRows := rows
+	if matchSheet := extractSheetName(pattern.matchRange1); matchSheet != "" && matchSheet != sourceSheet {
+		matchFileRows, err := f.getCachedRawRows(matchSheet)
+		if err != nil {
+			return results
 		}
+		matchRows = mergeSheetCacheIntoRows(matchFileRows, worksheetCache.GetSheet(matchSheet))
 	}
+	rowLookupMap := f.indexMatchRowLookup(matchRows, pattern.matchRange1, pattern.arrayRange)
 
 	colLookupMap := make(map[string]int)
 	if len(rows) > 0 {
@@ -1295,7 +1353,7 @@ func (f *File) calculateINDEXMATCH2DPatternWithCache(pattern *indexMatch2DPatter
 		for colIdx := startColIdx; colIdx <= endColIdx && colIdx < len(headerRow); colIdx++ {
 			value := headerRow[colIdx]
 			if value != "" {
-				colLookupMap[value] = colIdx - startColIdx
+				colLookupMap[f.normalizeLookupKey(value)] = colIdx - startColIdx
 			}
 		}
 	}
@@ -1367,19 +1425,21 @@ func (f *File) calculateINDEXMATCH2DPatternWithCache(pattern *indexMatch2DPatter
 			lookup2Value = lookupValueCache[cacheKey2]
 		}
 
-		if rowIdx, ok := rowLookupMap[lookup1Value]; ok {
-			if colOffset, ok := colLookupMap[lookup2Value]; ok {
+		if rowIdx, ok := rowLookupMap[f.normalizeLookupKey(lookup1Value)]; ok {
+			if colOffset, ok := colLookupMap[f.normalizeLookupKey(lookup2Value)]; ok {
 				actualColIdx := startColIdx + colOffset
-				if rowIdx < len(rows) && actualColIdx < len(rows[rowIdx]) {
+				if rowIdx < 0 {
+					results[fullCell] = formulaErrorREF
+				} else if rowIdx < len(rows) && actualColIdx < len(rows[rowIdx]) {
 					results[fullCell] = rows[rowIdx][actualColIdx]
 				} else {
-					results[fullCell] = "0"
+					results[fullCell] = ""
 				}
 			} else {
-				results[fullCell] = "0"
+				results[fullCell] = formulaErrorNA
 			}
 		} else {
-			results[fullCell] = "0"
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -1395,24 +1455,12 @@ func (f *File) calculateINDEXMATCH1DPatternWithCache(pattern *indexMatch1DPatter
 		return results
 	}
 
-	arrayParts := strings.Split(pattern.arrayRange, "!")
-	if len(arrayParts) != 2 {
+	// Parse array range to get column, e.g. "Data!$B:$B" or "Data!$B$5:$B$14" -> B
+	arrayColIdx, err := ColumnNameToNumber(extractColumnFromRange(pattern.arrayRange))
+	if err != nil {
 		return results
 	}
-
-	arrayColPart := strings.ReplaceAll(arrayParts[1], "$", "")
-	if strings.Contains(arrayColPart, ":") {
-		parts := strings.Split(arrayColPart, ":")
-		if len(parts) > 0 {
-			arrayColPart = parts[0]
-		}
-	}
-	arrayColIdx, _ := ColumnNameToNumber(arrayColPart)
-	arrayColIdx--
-
-	matchCol := extractColumnFromRange(pattern.matchRange)
-	matchColIdx, _ := ColumnNameToNumber(matchCol)
-	matchColIdx--
+	arrayColIdx-- // Convert to 0-based
 
 	// CRITICAL FIX: Always read from file first, then merge cached formula results
 	// The worksheetCache only contains formula calculation results, NOT original data.
@@ -1421,72 +1469,45 @@ func (f *File) calculateINDEXMATCH1DPatternWithCache(pattern *indexMatch1DPatter
 	sheetData := worksheetCache.GetSheet(sourceSheet)
 
 	// Always read from file to get original data
-	fileRows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
+	fileRows, err := f.getCachedRawRows(sourceSheet)
 	if err != nil || len(fileRows) == 0 {
 		return results
 	}
-	rows := fileRows
 
 	// Merge cached formula results into rows
 	// This ensures we use calculated values for formula columns (e.g., G column)
 	// while keeping original data for data columns (e.g., A column for MATCH lookup)
-	if len(sheetData) > 0 {
-		for cellRef, argValue := range sheetData {
-			col, row, err := CellNameToCoordinates(cellRef)
-			if err != nil {
-				continue
-			}
-			// Ensure rows array is large enough
-			for len(rows) < row {
-				rows = append(rows, make([]string, 0))
-			}
-			// Ensure row is large enough
-			for len(rows[row-1]) < col {
-				rows[row-1] = append(rows[row-1], "")
-			}
-			rows[row-1][col-1] = argValue.Value()
+	rows := mergeSheetCacheIntoRows(fileRows, sheetData)
+
+	// The match range may be on another sheet, like INDEX(Data!$B:$B,MATCH(A2,Keys!$A:$A,0))
+	matchRows := rows
+	if matchSheet := extractSheetName(pattern.matchRange); matchSheet != "" && matchSheet != sourceSheet {
+		matchFileRows, err := f.getCachedRawRows(matchSheet)
+		if err != nil {
+			return results
 		}
+		matchRows = mergeSheetCacheIntoRows(matchFileRows, worksheetCache.GetSheet(matchSheet))
 	}
 
 	// Build lookup map
-	lookupMap := make(map[string]int)
-	if matchColIdx >= 0 {
-		for rowIdx, row := range rows {
-			if matchColIdx < len(row) {
-				value := row[matchColIdx]
-				if value != "" {
-					lookupMap[value] = rowIdx
-				}
-			}
-		}
-	}
+	lookupMap := f.indexMatchRowLookup(matchRows, pattern.matchRange, pattern.arrayRange)
 
 	// Calculate results
 	for fullCell, info := range pattern.formulas {
 		lookupCell := strings.ReplaceAll(info.lookupCell, "$", "")
 		lookupValue := f.getCellValueOrCalcCache(info.sheet, lookupCell, worksheetCache)
 
-		if rowIdx, ok := lookupMap[lookupValue]; ok {
-			if rowIdx < len(rows) && arrayColIdx < len(rows[rowIdx]) {
+		if rowIdx, ok := lookupMap[f.normalizeLookupKey(lookupValue)]; ok {
+			if rowIdx < 0 {
+				results[fullCell] = formulaErrorREF
+			} else if rowIdx < len(rows) && arrayColIdx < len(rows[rowIdx]) {
 				results[fullCell] = rows[rowIdx][arrayColIdx]
 			} else {
 				results[fullCell] = ""
 			}
 		} else {
-			// No match found - use fallback value if available
-			if info.fallbackValue != "" {
-				// Fallback value can be a cell reference or a literal value
-				if isCellReference(info.fallbackValue) {
-					// It's a cell reference like "A1"
-					fallbackVal := f.getCellValueOrCalcCache(info.sheet, info.fallbackValue, worksheetCache)
-					results[fullCell] = fallbackVal
-				} else {
-					// It's a literal value
-					results[fullCell] = info.fallbackValue
-				}
-			} else {
-				results[fullCell] = ""
-			}
+			// No match found, the IFERROR fallback value is applied by the caller
+			results[fullCell] = formulaErrorNA
 		}
 	}
 
@@ -0,0 +1,153 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// indexMatchColumnPattern represents a batch INDEX-MATCH pattern over a
+// multi-column array whose column argument is resolved per formula
+// Pattern: INDEX(Data!$C:$O, MATCH(lookup, Data!$A:$A, 0), colNum)
+// where colNum is a cell reference or an arithmetic expression like $B$1+1
+type indexMatchColumnPattern struct {
+	array    lookupTable // the multi-column array of INDEX
+	match    lookupTable // the lookup column of MATCH
+	formulas map[string]*indexMatchColumnFormula
+}
+
+type indexMatchColumnFormula struct {
+	cell    string
+	sheet   string
+	lookup  string // lookup value argument: cell reference or literal
+	colExpr string // column argument, e.g. "$B$1" or "K$1-1"
+}
+
+// isIndexMatchColumnExpr reports whether the column argument of INDEX can be
+// resolved per formula: a number, a cell reference, or arithmetic of them.
+// The expressions with functions or ranges are left to the formula engine,
+// like COLUMN() which depends on the cell of the formula.
+func isIndexMatchColumnExpr(expr string) bool {
+	return expr != "" && !strings.ContainsAny(expr, `(),:"{}`)
+}
+
+// extractINDEXMATCHColumnPattern extracts the INDEX-MATCH pattern with a
+// column argument resolved per formula from the first INDEX expression of
+// the formula. The MATCH must be an exact match over a single column with the
+// same rows as the array, unqualified ranges belong to the sheet of the
+// formula.
+func (f *File) extractINDEXMATCHColumnPattern(sheet, cell, formula string) *indexMatchColumnPattern {
+	indexExpr := extractINDEXMATCHFromFormula(formula)
+	if indexExpr == "" {
+		return nil
+	}
+	args := splitFunctionArgs(extractFunctionCall(indexExpr, "INDEX"))
+	if len(args) != 3 {
+		return nil
+	}
+	arrayRange, rowExpr, colExpr := strings.TrimSpace(args[0]), strings.TrimSpace(args[1]), strings.TrimSpace(args[2])
+	if !strings.HasPrefix(rowExpr, "MATCH(") || !isIndexMatchColumnExpr(colExpr) {
+		return nil
+	}
+	matchContent := extractFunctionCall(rowExpr, "MATCH")
+	if "MATCH("+matchContent+")" != rowExpr {
+		return nil
+	}
+	matchArgs := splitFunctionArgs(matchContent)
+	if len(matchArgs) != 3 {
+		return nil
+	}
+	if matchType := strings.ToUpper(strings.TrimSpace(matchArgs[2])); matchType != "0" && matchType != "FALSE" {
+		return nil
+	}
+	lookup := strings.TrimSpace(matchArgs[0])
+	if lookup == "" || strings.ContainsAny(lookup, "(),:") {
+		return nil
+	}
+	array, ok := parseLookupTable(arrayRange, sheet)
+	if !ok {
+		return nil
+	}
+	match, ok := parseLookupTable(strings.TrimSpace(matchArgs[1]), sheet)
+	if !ok || match.startCol != match.endCol || match.sheet != array.sheet ||
+		match.startRow != array.startRow || match.endRow != array.endRow {
+		return nil
+	}
+	return &indexMatchColumnPattern{
+		array: array,
+		match: match,
+		formulas: map[string]*indexMatchColumnFormula{
+			sheet + "!" + cell: {cell: cell, sheet: sheet, lookup: lookup, colExpr: colExpr},
+		},
+	}
+}
+
+// resolveIndexMatchColumn resolves the column argument of a formula of the
+// pattern. It returns the column number, or the error value of the column
+// argument, and false if the column argument isn't a number, then the
+// formula is calculated one by one.
+func (f *File) resolveIndexMatchColumn(info *indexMatchColumnFormula, worksheetCache *WorksheetCache) (int, string, bool) {
+	value := info.colExpr
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		value, _ = f.evalFormulaString(info.sheet, info.cell, info.colExpr, worksheetCache, Options{RawCellValue: true})
+	}
+	if isFormulaErrorValue(value) {
+		return 0, value, true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return int(number), "", true
+}
+
+// calculateINDEXMATCHColumnPatternWithCache calculates the formulas of a
+// pattern, the lookup column is indexed once and each formula reads the
+// matched row at its own column. The results calculated in former levels are
+// read from worksheetCache. It gives #N/A for a missed lookup and #REF! for a
+// column out of the array like INDEX does. The formulas whose column argument
+// is 0 (the whole row) or isn't a number are left out of the result and
+// calculated one by one.
+func (f *File) calculateINDEXMATCHColumnPatternWithCache(pattern *indexMatchColumnPattern, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string, len(pattern.formulas))
+	fileRows, err := f.getCachedRawRows(pattern.array.sheet)
+	if err != nil {
+		return results
+	}
+	rows := mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(pattern.array.sheet))
+
+	// MATCH 返回第一个匹配的行
+	rowLookupMap := make(map[string]int)
+	matchColIdx := pattern.match.startCol - 1
+	for rowIdx := pattern.match.startRow - 1; rowIdx < len(rows) && rowIdx < pattern.match.endRow; rowIdx++ {
+		if matchColIdx >= len(rows[rowIdx]) || rows[rowIdx][matchColIdx] == "" {
+			continue
+		}
+		key := f.normalizeLookupKey(rows[rowIdx][matchColIdx])
+		if _, exists := rowLookupMap[key]; !exists {
+			rowLookupMap[key] = rowIdx
+		}
+	}
+
+	width := pattern.array.endCol - pattern.array.startCol + 1
+	for fullCell, info := range pattern.formulas {
+		lookupValue := f.resolveLookupValue(info.sheet, info.lookup, worksheetCache)
+		rowIdx, found := rowLookupMap[f.normalizeLookupKey(lookupValue)]
+		col, errValue, ok := f.resolveIndexMatchColumn(info, worksheetCache)
+		switch {
+		case !ok || (found && errValue == "" && col == 0):
+			continue
+		case !found:
+			results[fullCell] = formulaErrorNA
+		case errValue != "":
+			results[fullCell] = errValue
+		case col < 0 || col > width:
+			results[fullCell] = formulaErrorREF
+		default:
+			results[fullCell] = ""
+			if colIdx := pattern.array.startCol + col - 2; colIdx < len(rows[rowIdx]) {
+				results[fullCell] = rows[rowIdx][colIdx]
+			}
+		}
+	}
+	return results
+}
@@ -1,7 +1,6 @@
 package excelize
 
 import (
-	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -34,7 +33,6 @@ type indexMatchMultiCondFormula struct {
 	sheet           string
 	lookupCells     []string // e.g., ["ERP!B2", "ERP!F2"] - cells containing lookup values
 	originalFormula string
-	fallbackValue   string // IFERROR fallback value (e.g., "AE3791" or literal value)
 }
 
 // extractIndexMatchMultiCondPattern extracts multi-condition INDEX-MATCH pattern from formula
@@ -53,22 +51,9 @@ func (f *File) extractIndexMatchMultiCondPattern(sheet, cell, formula string) *i
 		return nil
 	}
 
-	// Extract IFERROR fallback value if present
-	var fallbackValue string
+	// The IFERROR fallback value is applied by the caller on a missed lookup
 	workFormula := strings.TrimPrefix(formula, "=")
 	if strings.HasPrefix(workFormula, "IFERROR(") {
-		// Extract the full IFERROR expression
-		iferrorContent := extractFunctionCall(workFormula, "IFERROR")
-		if iferrorContent != "" {
-			// Split IFERROR arguments: first is the formula, second is the fallback
-			iferrorArgs := splitFunctionArgs(iferrorContent)
-			if len(iferrorArgs) >= 2 {
-				fallbackValue = strings.TrimSpace(iferrorArgs[1])
-				// Remove quotes if it's a literal string
-				fallbackValue = strings.Trim(fallbackValue, `"'`)
-			}
-		}
-
 		// Find the INDEX( position
 		idxPos := strings.Index(workFormula, "INDEX(")
 		if idxPos > 0 {
@@ -144,7 +129,6 @@ func (f *File) extractIndexMatchMultiCondPattern(sheet, cell, formula string) *i
 		sheet:           sheet,
 		lookupCells:     lookupCells,
 		originalFormula: formula,
-		fallbackValue:   fallbackValue,
 	}
 
 	return pattern
@@ -318,20 +302,20 @@ func (f *File) calculateIndexMatchMultiCondPatternWithCache(pattern *indexMatchM
 	}
 
 	startTime := time.Now()
-	log.Printf("⚡ [MultiCond INDEX-MATCH] Starting batch calculation for %d formulas", len(pattern.formulas))
+	f.logger().Debugf("⚡ [MultiCond INDEX-MATCH] Starting batch calculation for %d formulas", len(pattern.formulas))
 
 	// Get source sheet data
 	sourceSheet := pattern.sourceSheet
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil {
-		log.Printf("❌ [MultiCond INDEX-MATCH] Failed to get rows from %s: %v", sourceSheet, err)
+		f.logger().Infof("❌ [MultiCond INDEX-MATCH] Failed to get rows from %s: %v", sourceSheet, err)
 		return results
 	}
 
 	// Get result column index
 	resultColIdx := getRangeColumnIndex(pattern.resultRange)
 	if resultColIdx < 0 {
-		log.Printf("❌ [MultiCond INDEX-MATCH] Invalid result range: %s", pattern.resultRange)
+		f.logger().Infof("❌ [MultiCond INDEX-MATCH] Invalid result range: %s", pattern.resultRange)
 		return results
 	}
 
@@ -421,31 +405,18 @@ func (f *File) calculateIndexMatchMultiCondPatternWithCache(pattern *indexMatchM
 			}
 		}
 
-		// Get result value
-		if matchedRow >= 0 && matchedRow < len(rows) && resultColIdx < len(rows[matchedRow]) {
+		// Get result value, a blank cell gives an empty string
+		if matchedRow < 0 || matchedRow >= len(rows) {
+			// No match found, the IFERROR fallback value is applied by the caller
+			results[fullCell] = formulaErrorNA
+		} else if resultColIdx < len(rows[matchedRow]) {
 			results[fullCell] = rows[matchedRow][resultColIdx]
 		} else {
-			// No match found - use fallback value if available
-			if info.fallbackValue != "" {
-				// Fallback value can be a cell reference or a literal value
-				// If it's a cell reference (contains no operators and looks like a cell), get its value
-				fallback := info.fallbackValue
-				if isCellReference(fallback) {
-					// It's a cell reference like "AE3791"
-					fallbackVal := f.getCellValueOrCalcCache(info.sheet, fallback, worksheetCache)
-					results[fullCell] = fallbackVal
-				} else {
-					// It's a literal value
-					results[fullCell] = fallback
-				}
-			} else {
-				// No fallback specified, return empty string
-				results[fullCell] = ""
-			}
+			results[fullCell] = ""
 		}
 	}
 
-	log.Printf("✅ [MultiCond INDEX-MATCH] Completed %d formulas in %v", len(pattern.formulas), time.Since(startTime))
+	f.logger().Debugf("✅ [MultiCond INDEX-MATCH] Completed %d formulas in %v", len(pattern.formulas), time.Since(startTime))
 
 	return results
 }
@@ -488,7 +459,7 @@ func (f *File) batchCalculateIndexMatchMultiCondWithCache(formulas map[string]st
 	}
 
 	if len(patterns) > 0 {
-		log.Printf("📊 [MultiCond INDEX-MATCH] Found %d unique patterns", len(patterns))
+		f.logger().Debugf("📊 [MultiCond INDEX-MATCH] Found %d unique patterns", len(patterns))
 	}
 
 	// Calculate each pattern
@@ -2,6 +2,7 @@ package excelize
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -322,3 +323,382 @@ func TestDetectAndCalculateBatchINDEX(t *testing.T) {
 		t.Fatalf("unexpected batch INDEX result %v %v", ok, val)
 	}
 }
+
+func TestExtractErrorGuard(t *testing.T) {
+	lookup := "INDEX(Data!$B:$B,MATCH(A2,Data!$A:$A,0))"
+	cases := []struct {
+		formula  string
+		function string
+		fallback string
+	}{
+		{"=IFERROR(" + lookup + ",0)", "IFERROR", "0"},
+		{"IFNA(" + lookup + `,"none")`, "IFNA", `"none"`},
+		{"IF(ISERROR(" + lookup + "),-1," + lookup + ")", "ISERROR", "-1"},
+		{"IF(ISNA(" + lookup + "),B2," + lookup + ")", "ISNA", "B2"},
+	}
+	for _, c := range cases {
+		guard := extractErrorGuard(c.formula)
+		if guard == nil {
+			t.Fatalf("expected error guard for %s", c.formula)
+		}
+		if guard.function != c.function || guard.expr != lookup || guard.fallback != c.fallback {
+			t.Fatalf("unexpected guard for %s: %+v", c.formula, guard)
+		}
+	}
+
+	for _, formula := range []string{
+		lookup,
+		"IF(ISERROR(" + lookup + "),0,1)",
+		"IFERROR(" + lookup + ",0)+1",
+		"IF(A1>0,1,2)",
+	} {
+		if guard := extractErrorGuard(formula); guard != nil {
+			t.Fatalf("expected no error guard for %s, got %+v", formula, guard)
+		}
+	}
+
+	guard := extractErrorGuard("IFNA(" + lookup + ",0)")
+	if !guard.traps("#N/A") || guard.traps("#DIV/0!") || guard.traps("5") {
+		t.Fatalf("IFNA should only trap #N/A")
+	}
+	guard = extractErrorGuard("IF(ISERROR(" + lookup + "),0," + lookup + ")")
+	if !guard.traps("#N/A") || !guard.traps("#DIV/0!") || guard.traps("5") {
+		t.Fatalf("ISERROR should trap every error value")
+	}
+}
+
+func TestBatchINDEXMATCHErrorGuardWrappers(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	for i := 1; i <= 6; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{fmt.Sprintf("K%d", i), i * 100}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+
+	// Rows 1-6 hit, rows 7-12 miss
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("K%d", row)); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		lookup := fmt.Sprintf("INDEX(Data!$B:$B,MATCH(A%d,Data!$A:$A,0))", row)
+		formulas := map[string]string{
+			"B": "IF(ISERROR(" + lookup + "),-1," + lookup + ")",
+			"C": "IF(ISNA(" + lookup + "),-2," + lookup + ")",
+			"D": "IFNA(" + lookup + ",-3)",
+		}
+		for col, formula := range formulas {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+
+	for row := 1; row <= 12; row++ {
+		for col, fallback := range map[string]string{"B": "-1", "C": "-2", "D": "-3"} {
+			expected := fallback
+			if row <= 6 {
+				expected = fmt.Sprint(row * 100)
+			}
+			got, err := f.GetCellValue("Sheet1", fmt.Sprintf("%s%d", col, row))
+			if err != nil {
+				t.Fatalf("get %s%d: %v", col, row, err)
+			}
+			if got != expected {
+				t.Errorf("Sheet1!%s%d: expected %s, got %s", col, row, expected, got)
+			}
+		}
+	}
+}
+
+func TestCalcCellValueWithSubExprCacheErrorGuardFold(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	hit := "INDEX(Data!$B:$B,MATCH(A1,Data!$A:$A,0))"
+	miss := "INDEX(Data!$B:$B,MATCH(A2,Data!$A:$A,0))"
+	cache := NewSubExpressionCache()
+	cache.Store(hit, "42")
+	cache.Store(miss, "#N/A")
+
+	for _, c := range []struct {
+		cell, formula, expected string
+	}{
+		{"C1", "IF(ISERROR(" + hit + "),0," + hit + ")", "42"},
+		{"C2", "IF(ISERROR(" + miss + "),0," + miss + ")", "0"},
+		{"C3", "IFNA(" + miss + `,"none")`, "none"},
+		{"C4", "IF(ISNA(" + hit + "),0," + hit + ")", "42"},
+	} {
+		got, err := f.CalcCellValueWithSubExprCache("Sheet1", c.cell, c.formula, cache, NewWorksheetCache(), Options{RawCellValue: true})
+		if err != nil {
+			t.Fatalf("calc %s: %v", c.cell, err)
+		}
+		if got != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.formula, c.expected, got)
+		}
+	}
+}
+
+func TestBatchINDEXMATCHMissedLookup(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	if err := f.SetSheetRow("Data", "A1", &[]interface{}{"Key", "Value", "Q1", "Q2"}); err != nil {
+		t.Fatalf("set header row: %v", err)
+	}
+	// K1 的值为 0，用于区分查找到 0 和查找不到
+	for i := 1; i <= 6; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &[]interface{}{fmt.Sprintf("K%d", i), (i - 1) * 100, i, i * 2}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+
+	if err := f.SetCellValue("Sheet1", "H1", "Q2"); err != nil {
+		t.Fatalf("set header: %v", err)
+	}
+	// Rows 1-6 hit, rows 7-12 miss
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("K%d", row)); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		lookup := fmt.Sprintf("INDEX(Data!$B:$B,MATCH(A%d,Data!$A:$A,0))", row)
+		lookup2D := fmt.Sprintf("INDEX(Data!$C:$D,MATCH(A%d,Data!$A:$A,0),MATCH($H$1,Data!$C$1:$D$1,0))", row)
+		for col, formula := range map[string]string{
+			"B": lookup,
+			"C": "IFERROR(" + lookup + ",0)",
+			"D": lookup + "+1",
+			"E": "IF(IFERROR(" + lookup + `,0)=0,"none",` + lookup + ")",
+			"F": lookup2D,
+			"G": "IFERROR(" + lookup2D + ",0)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+
+	for row := 1; row <= 12; row++ {
+		expected := map[string]string{"B": "#N/A", "C": "0", "D": "#N/A", "E": "none", "F": "#N/A", "G": "0"}
+		if row <= 6 {
+			value := fmt.Sprint((row - 1) * 100)
+			expected = map[string]string{"B": value, "C": value, "D": fmt.Sprint((row-1)*100 + 1), "E": value, "F": fmt.Sprint(row * 2), "G": fmt.Sprint(row * 2)}
+			if row == 1 {
+				expected["E"] = "none"
+			}
+		}
+		for col, value := range expected {
+			got, err := f.GetCellValue("Sheet1", fmt.Sprintf("%s%d", col, row))
+			if err != nil {
+				t.Fatalf("get %s%d: %v", col, row, err)
+			}
+			if got != value {
+				t.Errorf("Sheet1!%s%d: expected %q, got %q", col, row, value, got)
+			}
+		}
+	}
+	// 查找不到的纯 INDEX-MATCH 由批量计算得到 #N/A
+	for _, entry := range f.CalcAuditTrail() {
+		if entry.Cell == "Sheet1!B9" && entry.Optimizer != "INDEX-MATCH" {
+			t.Errorf("unexpected optimizer %q of the missed lookup", entry.Optimizer)
+		}
+	}
+}
+
+func TestBatchINDEXMATCHColumnArgument(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{fmt.Sprintf("K%d", i), "", i*10 + 1, i*10 + 2, i*10 + 3, i*10 + 4}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+
+	// B 列为 INDEX 的列号：第 8 行超出数组，第 9 行为错误值，第 10 行为负数，第 11、12 行查找不到
+	formulas := make(map[string]string)
+	expected := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("K%d", row)); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		col := (row-1)%4 + 1
+		switch row {
+		case 8:
+			col = 5
+		case 10:
+			col = -2
+		}
+		if row == 9 {
+			if err := f.SetCellFormula("Sheet1", "B9", "NA()"); err != nil {
+				t.Fatalf("set column formula: %v", err)
+			}
+		} else if err := f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), col); err != nil {
+			t.Fatalf("set column: %v", err)
+		}
+		lookup := fmt.Sprintf("INDEX(Data!$C:$F,MATCH($A%d,Data!$A:$A,0),$B%d)", row, row)
+		lookupNext := fmt.Sprintf("INDEX(Data!$C:$F,MATCH($A%d,Data!$A:$A,0),$B%d+1)", row, row)
+		for column, formula := range map[string]string{
+			"C": lookup,
+			"D": lookupNext,
+			"E": "IFERROR(" + lookup + `,"-")`,
+		} {
+			cell := fmt.Sprintf("%s%d", column, row)
+			if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			formulas["Sheet1!"+cell] = formula
+		}
+		value := func(col int) string {
+			switch {
+			case row > 10:
+				return formulaErrorNA
+			case row == 9:
+				return formulaErrorNA
+			case col < 0 || col > 4:
+				return formulaErrorREF
+			}
+			return fmt.Sprint(row*10 + col)
+		}
+		expected[fmt.Sprintf("C%d", row)] = value(col)
+		expected[fmt.Sprintf("D%d", row)] = value(col + 1)
+		expected[fmt.Sprintf("E%d", row)] = value(col)
+		if isFormulaErrorValue(value(col)) {
+			expected[fmt.Sprintf("E%d", row)] = "-"
+		}
+	}
+
+	// 列号为各公式单独计算的 INDEX-MATCH 由批量计算得到
+	results := f.batchCalculateINDEXMATCHWithCache(formulas, NewWorksheetCache())
+	for row := 1; row <= 12; row++ {
+		cell := fmt.Sprintf("C%d", row)
+		if got, ok := results["Sheet1!"+cell]; !ok || got != expected[cell] {
+			t.Errorf("batch Sheet1!%s: expected %q, got %q (%t)", cell, expected[cell], got, ok)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+	for cell, value := range expected {
+		got, err := f.GetCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("get %s: %v", cell, err)
+		}
+		if got != value {
+			t.Errorf("Sheet1!%s: expected %q, got %q", cell, value, got)
+		}
+	}
+}
+
+func TestBatchINDEXMATCHSplitSheets(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	for _, sheet := range []string{"Data", "Keys"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create sheet: %v", err)
+		}
+	}
+	if err := f.SetSheetRow("Data", "B1", &[]interface{}{"Val", "X", "Y", "Z"}); err != nil {
+		t.Fatalf("set header row: %v", err)
+	}
+	for row := 2; row <= 15; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("B%d", row), &[]interface{}{row * 100, row*1000 + 1, row*1000 + 2, row*1000 + 3}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	// 查找键在 Keys 工作表，第 11 行重复 K3，K11 查找不到
+	for row := 2; row <= 11; row++ {
+		key := fmt.Sprintf("K%d", row)
+		if row == 11 {
+			key = "K3"
+		}
+		if err := f.SetCellValue("Keys", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "F1", "Y"); err != nil {
+		t.Fatalf("set header key: %v", err)
+	}
+
+	formulas := make(map[string]string)
+	for row := 2; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("K%d", row)); err != nil {
+			t.Fatalf("set lookup value: %v", err)
+		}
+		for column, formula := range map[string]string{
+			"G": "INDEX(Data!$B:$B,MATCH($A%[1]d,Keys!$A:$A,0))",
+			"H": "INDEX(Data!$B$5:$B$14,MATCH($A%[1]d,Keys!$A$2:$A$11,0))",
+			"I": "INDEX(Data!$B$5:$B$7,MATCH($A%[1]d,Keys!$A$2:$A$11,0))",
+			"J": "INDEX(Data!$B:$B,MATCH($A%[1]d,$D:$D,0))",
+			"K": "INDEX(Data!$C:$E,MATCH($A%[1]d,Keys!$A:$A,0),MATCH($F$1,Data!$C$1:$E$1,0))",
+			"L": "INDEX(Data!$C:$E,MATCH($A%[1]d,Keys!$A:$A,0),MATCH($F$1,Keys!$C$1:$E$1,0))",
+		} {
+			cell := fmt.Sprintf("%s%d", column, row)
+			formula = fmt.Sprintf(formula, row)
+			if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			formulas["Sheet1!"+cell] = formula
+		}
+	}
+
+	// 批量结果与公式引擎逐个计算的结果一致
+	results := f.batchCalculateINDEXMATCHWithCache(formulas, NewWorksheetCache())
+	for fullCell := range formulas {
+		cell := strings.TrimPrefix(fullCell, "Sheet1!")
+		expected, err := f.CalcCellValue("Sheet1", cell)
+		if err != nil && !isFormulaErrorValue(expected) {
+			t.Fatalf("calculate %s: %v", cell, err)
+		}
+		got, ok := results[fullCell]
+		if strings.HasPrefix(cell, "L") {
+			// 列查找范围不在数组所在的工作表，不批量计算
+			if ok {
+				t.Errorf("batch %s: expected the formula to be left to the engine, got %q", fullCell, got)
+			}
+			continue
+		}
+		if !ok || got != expected {
+			t.Errorf("batch %s: expected %q, got %q (%t)", fullCell, expected, got, ok)
+		}
+	}
+	for cell, expected := range map[string]string{
+		"G4": "400", "G11": formulaErrorNA, "G12": formulaErrorNA, "H4": "700",
+		"I4": "700", "I5": formulaErrorREF, "J4": "400", "K4": "4002",
+	} {
+		if got := results["Sheet1!"+cell]; got != expected {
+			t.Errorf("batch Sheet1!%s: expected %q, got %q", cell, expected, got)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("RecalculateAllWithDependency failed: %v", err)
+	}
+	for cell, expected := range map[string]string{"G4": "400", "H4": "700", "K4": "4002", "L4": formulaErrorNA} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != expected {
+			t.Errorf("Sheet1!%s: expected %q, got %q", cell, expected, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package excelize
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -183,6 +184,7 @@ func TestMergeLevelsWithVirtualColumnDependency(t *testing.T) {
 	}
 
 	graph.assignLevels()
+	assertValidDependencyGraph(t, graph)
 
 	// Build level map
 	levelMap := make(map[string]int)
@@ -218,6 +220,88 @@ func TestMergeLevelsWithVirtualColumnDependency(t *testing.T) {
 	}
 }
 
+// TestMergeLevelsColumnDependencyOnFirstLevel tests that a formula depending on
+// a column whose formulas are all at level 0 is not merged into level 0, and
+// the SUMIFS and INDEX formulas over the results of the SUMIFS formulas give
+// the same values on the first and the second recalculation.
+func TestMergeLevelsColumnDependencyOnFirstLevel(t *testing.T) {
+	graph := &dependencyGraph{
+		nodes: map[string]*formulaNode{
+			"Calc!B2":    {cell: "Calc!B2", dependencies: []string{"Data!B2"}, level: -1},
+			"Calc!B3":    {cell: "Calc!B3", dependencies: []string{"Data!B3"}, level: -1},
+			"Summary!B2": {cell: "Summary!B2", dependencies: []string{"COLUMN:Calc!B", "COLUMN:Calc!A"}, level: -1},
+		},
+	}
+	graph.assignLevels()
+	assertValidDependencyGraph(t, graph)
+	if len(graph.levels) != 2 || len(graph.levels[1]) != 1 || graph.levels[1][0] != "Summary!B2" {
+		t.Fatalf("expected Summary!B2 in a level after the Calc!B formulas, got %v", graph.levels)
+	}
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for _, sheet := range []string{"Data", "Calc", "Summary"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create sheet: %v", err)
+		}
+	}
+	for row := 2; row <= 13; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", row%3), row}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		// Calc!B 列的 SUMIFS 公式都在第 0 层，与下一阶段的公式一样批量计算
+		if err := f.SetSheetRow("Calc", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", row%3), nil, fmt.Sprintf("R%d", row)}); err != nil {
+			t.Fatalf("set keys: %v", err)
+		}
+		if err := f.SetCellFormula("Calc", fmt.Sprintf("B%d", row), fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,$A%d)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 每个阶段 12 个 SUMIFS 公式使用批量计算，逐个单元格计算会直接计算引用的公式
+	for i := 0; i < 12; i++ {
+		row := i + 2
+		if err := f.SetSheetRow("Summary", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", i%3), nil, nil, fmt.Sprintf("R%d", row)}); err != nil {
+			t.Fatalf("set keys: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": fmt.Sprintf("SUMIFS(Calc!$B:$B,Calc!$A:$A,$A%d)", row),
+			"C": fmt.Sprintf("INDEX(Calc!$B:$B,MATCH($D%d,Calc!$C:$C,0))", row),
+		} {
+			if err := f.SetCellFormula("Summary", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	values := func() []string {
+		var values []string
+		for row := 2; row <= 13; row++ {
+			for _, col := range []string{"B", "C"} {
+				value, _ := f.GetCellValue("Summary", fmt.Sprintf("%s%d", col, row))
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	first := values()
+	var expected []string
+	for row := 2; row <= 13; row++ {
+		expected = append(expected, []string{"120", "136", "104"}[(row-2)%3], []string{"30", "34", "26"}[row%3])
+	}
+	if !reflect.DeepEqual(first, expected) {
+		t.Fatalf("expected %v on the first recalculation, got %v", expected, first)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if second := values(); !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected the same values on the second recalculation, got %v and %v", first, second)
+	}
+}
+
 // TestINDEXMATCHWithFormulaSourceColumn tests when the INDEX source column contains formulas
 // that need to be calculated first.
 func TestINDEXMATCHWithFormulaSourceColumn(t *testing.T) {
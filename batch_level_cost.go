@@ -0,0 +1,136 @@
+package excelize
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// nodeCost returns the estimated calculation cost of a formula, which is
+// computed on the first call and cached in the node. It should not be called
+// concurrently.
+func (g *dependencyGraph) nodeCost(cell string) int64 {
+	node, ok := g.nodes[cell]
+	if !ok {
+		return 1
+	}
+	if node.cost == 0 {
+		node.cost = g.estimateFormulaCost(node.formula, cell[:max(strings.LastIndex(cell, "!"), 0)])
+	}
+	return node.cost
+}
+
+// estimateFormulaCost estimates the calculation cost of a formula as the
+// number of cells it references plus one. The whole column references count
+// the used rows of the columns in the column metadata, the whole row
+// references count the used columns of the sheet.
+func (g *dependencyGraph) estimateFormulaCost(formula, currentSheet string) int64 {
+	cost := int64(1)
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(formula) {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		sheet, ref := currentSheet, strings.ReplaceAll(token.TValue, "$", "")
+		if idx := strings.LastIndex(ref, "!"); idx != -1 {
+			sheet, ref = strings.Trim(ref[:idx], "'"), ref[idx+1:]
+		}
+		start, end, isRange := strings.Cut(ref, ":")
+		if !isRange {
+			cost++
+			continue
+		}
+		startCol, startRow, err1 := CellNameToCoordinates(start)
+		endCol, endRow, err2 := CellNameToCoordinates(end)
+		if err1 == nil && err2 == nil {
+			cost += int64(spanLength(startCol, endCol)) * int64(spanLength(startRow, endRow))
+			continue
+		}
+		if startRow, err := strconv.Atoi(start); err == nil {
+			if endRow, err := strconv.Atoi(end); err == nil {
+				cost += int64(spanLength(startRow, endRow)) * int64(max(g.sheetColumnCount(sheet), 1))
+			}
+			continue
+		}
+		startCol, err1 = ColumnNameToNumber(start)
+		endCol, err2 = ColumnNameToNumber(end)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		for col := min(startCol, endCol); col <= max(startCol, endCol); col++ {
+			name, _ := ColumnNumberToName(col)
+			if meta := g.columnMetadata[sheet+"!"+name]; meta != nil {
+				cost += int64(meta.maxRow)
+			}
+		}
+	}
+	return cost
+}
+
+// spanLength returns the number of the rows or columns between two indexes,
+// inclusive.
+func spanLength(start, end int) int {
+	if start > end {
+		return start - end + 1
+	}
+	return end - start + 1
+}
+
+// sheetColumnCount returns the number of the used columns of the sheet in the
+// column metadata.
+func (g *dependencyGraph) sheetColumnCount(sheet string) int {
+	count, prefix := 0, sheet+"!"
+	for colKey := range g.columnMetadata {
+		if strings.HasPrefix(colKey, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// orderByCost returns a copy of the cells of a level ordered by descending
+// estimated cost, so the scheduler starts the most expensive formulas first
+// and the cheap ones fill the idle workers at the end of the level instead of
+// leaving a long formula running alone.
+func (g *dependencyGraph) orderByCost(cells []string) []string {
+	ordered := make([]string, len(cells))
+	copy(ordered, cells)
+	costs := make(map[string]int64, len(cells))
+	for _, cell := range cells {
+		costs[cell] = g.nodeCost(cell)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return costs[ordered[i]] > costs[ordered[j]]
+	})
+	return ordered
+}
+
+// maxLevelCost returns the maximum estimated cost of the formulas of the
+// cells.
+func (g *dependencyGraph) maxLevelCost(cells []string) int64 {
+	maxCost := int64(0)
+	for _, cell := range cells {
+		if cost := g.nodeCost(cell); cost > maxCost {
+			maxCost = cost
+		}
+	}
+	return maxCost
+}
+
+// isSkewedMerge checks if merging two levels with the given maximum costs
+// creates a badly skewed level, that is the ratio between the maximum costs
+// exceeds the configured maxMergeCostRatio.
+func (g *dependencyGraph) isSkewedMerge(cost1, cost2 int64) bool {
+	if g.maxMergeCostRatio <= 0 {
+		return false
+	}
+	if cost1 > cost2 {
+		cost1, cost2 = cost2, cost1
+	}
+	if cost1 < 1 {
+		cost1 = 1
+	}
+	return float64(cost2) > float64(cost1)*g.maxMergeCostRatio
+}
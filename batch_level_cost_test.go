@@ -0,0 +1,116 @@
+package excelize
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestEstimateFormulaCost(t *testing.T) {
+	graph := &dependencyGraph{
+		nodes: map[string]*formulaNode{
+			"Sheet1!C1": {cell: "Sheet1!C1", formula: "SUM(Data!A:B)", level: -1},
+			"Sheet1!C2": {cell: "Sheet1!C2", formula: "A1+1", level: -1},
+		},
+		columnMetadata: map[string]*columnMeta{
+			"Data!A": {maxRow: 100}, "Data!B": {maxRow: 50}, "Data!C": {maxRow: 10},
+		},
+	}
+	for formula, want := range map[string]int64{
+		"1+2":                       1,
+		"A1+B2":                     3,
+		"SUM($A$1:B10)":             21,
+		"SUM(B10:A1)":               21,
+		"SUM('Data'!A:B)":           151,
+		"SUM(Data!B:A,Data!C1)":     152,
+		"SUM(Data!2:3)":             7,
+		"SUMIFS(Data!Z:Z,A1,1)":     2,
+		"SUM(A1:A10)+SUM(Data!C:C)": 21,
+	} {
+		if got := graph.estimateFormulaCost(formula, "Sheet1"); got != want {
+			t.Fatalf("estimateFormulaCost(%q) = %d, want %d", formula, got, want)
+		}
+	}
+	if cost := graph.nodeCost("Sheet1!C1"); cost != 151 || graph.nodes["Sheet1!C1"].cost != 151 {
+		t.Fatalf("unexpected cached cost %d", cost)
+	}
+	cells := []string{"Sheet1!C2", "Sheet1!C1", "Sheet1!Z1"}
+	if ordered := graph.orderByCost(cells); !reflect.DeepEqual(ordered, []string{"Sheet1!C1", "Sheet1!C2", "Sheet1!Z1"}) {
+		t.Fatalf("unexpected order %v", ordered)
+	}
+	if cells[0] != "Sheet1!C2" {
+		t.Fatal("orderByCost modified the level")
+	}
+}
+
+func TestMergeLevelsCostRatio(t *testing.T) {
+	levels := func(maxMergeCostRatio float64) [][]string {
+		// Y 依赖 X；Z 独立但引用大范围，可以与 X 合并
+		graph := &dependencyGraph{
+			nodes: map[string]*formulaNode{
+				"Sheet1!X1": {cell: "Sheet1!X1", formula: "A1*2"},
+				"Sheet1!Y1": {cell: "Sheet1!Y1", formula: "X1+1", dependencies: []string{"Sheet1!X1"}},
+				"Sheet1!Z1": {cell: "Sheet1!Z1", formula: "SUM(A1:A1000)"},
+			},
+			levels:            [][]string{{"Sheet1!X1"}, {"Sheet1!Y1"}, {"Sheet1!Z1"}},
+			maxMergeCostRatio: maxMergeCostRatio,
+		}
+		graph.mergeLevels()
+		return graph.levels
+	}
+	if got := levels(0); !reflect.DeepEqual(got, [][]string{{"Sheet1!X1", "Sheet1!Z1"}, {"Sheet1!Y1"}}) {
+		t.Fatalf("unexpected merged levels %v", got)
+	}
+	if got := levels(100); !reflect.DeepEqual(got, [][]string{{"Sheet1!X1"}, {"Sheet1!Y1"}, {"Sheet1!Z1"}}) {
+		t.Fatalf("expected the skewed levels not merged, got %v", got)
+	}
+	if got := levels(1000); !reflect.DeepEqual(got, [][]string{{"Sheet1!X1", "Sheet1!Z1"}, {"Sheet1!Y1"}}) {
+		t.Fatalf("unexpected merged levels %v", got)
+	}
+}
+
+// BenchmarkDAGSchedulerSkewedLevel compares the wall-clock of a level with a
+// few expensive formulas queued after many cheap ones, in the order of the
+// level and ordered by the estimated cost.
+func BenchmarkDAGSchedulerSkewedLevel(b *testing.B) {
+	f := NewFile()
+	defer func() { _ = f.Close() }()
+	if _, err := f.NewSheet("Data"); err != nil {
+		b.Fatal(err)
+	}
+	for row := 1; row <= 20000; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{row, row % 13}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	numWorkers := runtime.NumCPU()
+	var cells []string
+	for row := 1; row <= 1500*numWorkers; row++ {
+		cell := fmt.Sprintf("A%d", row)
+		if err := f.SetCellFormula("Sheet1", cell, fmt.Sprintf("Data!A%d*2+1", row)); err != nil {
+			b.Fatal(err)
+		}
+		cells = append(cells, "Sheet1!"+cell)
+	}
+	for row := 1; row <= max(numWorkers/2, 1); row++ {
+		cell := fmt.Sprintf("B%d", row)
+		if err := f.SetCellFormula("Sheet1", cell, fmt.Sprintf("SUMPRODUCT(Data!A1:A20000*Data!B1:B20000)+%d", row)); err != nil {
+			b.Fatal(err)
+		}
+		cells = append(cells, "Sheet1!"+cell)
+	}
+	graph := f.buildDependencyGraph()
+	for name, levelCells := range map[string][]string{"InOrder": cells, "ByCost": graph.orderByCost(cells)} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.ClearFormulaCache()
+				scheduler, ok := f.NewDAGSchedulerForLevel(graph, 0, levelCells, numWorkers, NewSubExpressionCache(), nil)
+				if !ok {
+					b.Fatal("unexpected circular dependency")
+				}
+				scheduler.Run()
+			}
+		})
+	}
+}
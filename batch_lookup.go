@@ -0,0 +1,222 @@
+package excelize
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lookupVectorPattern is a group of LOOKUP formulas over the same lookup
+// vector, e.g. LOOKUP(B2,Rates!$A:$A,Rates!$C:$C) filled down a column. The
+// formulas may return different result vectors, the lookup vector is scanned
+// once for all of them.
+type lookupVectorPattern struct {
+	vector   lookupTable                     // the lookup vector
+	formulas map[string]*lookupVectorFormula // "Sheet!Cell" -> formula info
+}
+
+// lookupVectorFormula is a LOOKUP formula of a pattern
+type lookupVectorFormula struct {
+	sheet  string
+	lookup string      // lookup value argument: cell reference or literal
+	result lookupTable // the result vector
+}
+
+// lookupVectorExpr represents a LOOKUP over a column, e.g.
+// LOOKUP(B2,Rates!$A:$A,Rates!$C:$C) in the vector form, or LOOKUP(B2,$A:$C)
+// in the array form returning the last column of the array
+type lookupVectorExpr struct {
+	lookup string
+	vector lookupTable
+	result lookupTable
+}
+
+// parseLOOKUPVector parses a LOOKUP expression whose lookup vector is a
+// column, and whose result vector is a column of the same number of rows, or
+// an array form LOOKUP over an array not wider than it is tall. The sheet of
+// an unqualified range defaults to currentSheet.
+func parseLOOKUPVector(expr, currentSheet string) (*lookupVectorExpr, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "LOOKUP(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "LOOKUP")
+	if "LOOKUP("+content+")" != expr {
+		return nil, false
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != 2 && len(args) != 3 {
+		return nil, false
+	}
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if args[0] == "" || strings.ContainsAny(args[0], "(),:") {
+		return nil, false
+	}
+	vector, ok := parseLookupTable(args[1], currentSheet)
+	if !ok {
+		return nil, false
+	}
+	result := vector
+	if len(args) == 3 {
+		if result, ok = parseLookupTable(args[2], currentSheet); !ok || vector.startCol != vector.endCol ||
+			result.startCol != result.endCol || result.endRow-result.startRow != vector.endRow-vector.startRow {
+			return nil, false
+		}
+	} else {
+		if vector.endCol-vector.startCol > vector.endRow-vector.startRow {
+			return nil, false
+		}
+		result.startCol = result.endCol
+		vector.endCol = vector.startCol
+	}
+	return &lookupVectorExpr{lookup: args[0], vector: vector, result: result}, true
+}
+
+// extractLOOKUPPattern extracts the pattern of a formula which is a single
+// LOOKUP over a column, other formulas return nil and are calculated one by
+// one.
+func (f *File) extractLOOKUPPattern(sheet, cell, formula string) *lookupVectorPattern {
+	lookup, ok := parseLOOKUPVector(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+	if !ok {
+		return nil
+	}
+	return &lookupVectorPattern{
+		vector: lookup.vector,
+		formulas: map[string]*lookupVectorFormula{
+			sheet + "!" + cell: {sheet: sheet, lookup: lookup.lookup, result: lookup.result},
+		},
+	}
+}
+
+// isLOOKUPFormula reports whether the formula is a single LOOKUP over a
+// column which can be calculated in batch
+func isLOOKUPFormula(formula string) bool {
+	_, ok := parseLOOKUPVector(strings.TrimPrefix(strings.TrimSpace(formula), "="), "")
+	return ok
+}
+
+// groupLOOKUPByPattern groups LOOKUP formulas by their lookup vector
+func (f *File) groupLOOKUPByPattern(formulas map[string]string) []*lookupVectorPattern {
+	patterns := make(map[lookupTable]*lookupVectorPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		pattern := f.extractLOOKUPPattern(sheet, cell, formula)
+		if pattern == nil {
+			continue
+		}
+		if existing, exists := patterns[pattern.vector]; exists {
+			for c, info := range pattern.formulas {
+				existing.formulas[c] = info
+			}
+			continue
+		}
+		patterns[pattern.vector] = pattern
+	}
+	result := make([]*lookupVectorPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+// buildSortedLookupVector returns the numbers of the lookup vector and their
+// row index, skipping the blank cells like LOOKUP does. It returns false if
+// the vector has other values than numbers or isn't sorted in ascending
+// order, then the formulas are calculated one by one.
+func buildSortedLookupVector(rows [][]string, vector lookupTable) ([]float64, []int, bool) {
+	var numbers []float64
+	var rowIndexes []int
+	for rowIdx := vector.startRow - 1; rowIdx < len(rows) && rowIdx < vector.endRow; rowIdx++ {
+		if vector.startCol > len(rows[rowIdx]) || rows[rowIdx][vector.startCol-1] == "" {
+			continue
+		}
+		number, ok := parseLookupNumber(rows[rowIdx][vector.startCol-1])
+		if !ok || (len(numbers) > 0 && number < numbers[len(numbers)-1]) {
+			return nil, nil, false
+		}
+		numbers = append(numbers, number)
+		rowIndexes = append(rowIndexes, rowIdx)
+	}
+	return numbers, rowIndexes, true
+}
+
+// parseLookupNumber parses a cell value or a lookup value of LOOKUP as a
+// finite number.
+func parseLookupNumber(value string) (float64, bool) {
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil && !math.IsNaN(number) && !math.IsInf(number, 0)
+}
+
+// calculateLOOKUPPatternWithCache calculates the formulas of a pattern whose
+// lookup vector is sorted by a binary search of the lookup values, the results
+// calculated in former levels are read from worksheetCache. It gives the
+// first exact match, or else the last value less than the lookup value, and
+// #N/A for a lookup value less than the first value. The lookup values which
+// aren't numbers are left out of the result and calculated one by one.
+func (f *File) calculateLOOKUPPatternWithCache(pattern *lookupVectorPattern, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string, len(pattern.formulas))
+	rowsBySheet := make(map[string][][]string)
+	sheetRows := func(sheet string) ([][]string, bool) {
+		if rows, exists := rowsBySheet[sheet]; exists {
+			return rows, true
+		}
+		fileRows, err := f.getCachedRawRows(sheet)
+		if err != nil {
+			return nil, false
+		}
+		rows := mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(sheet))
+		rowsBySheet[sheet] = rows
+		return rows, true
+	}
+	rows, ok := sheetRows(pattern.vector.sheet)
+	if !ok {
+		return results
+	}
+	numbers, rowIndexes, sorted := buildSortedLookupVector(rows, pattern.vector)
+	if !sorted {
+		return results
+	}
+	for fullCell, info := range pattern.formulas {
+		number, ok := parseLookupNumber(f.resolveLookupValue(info.sheet, info.lookup, worksheetCache))
+		if !ok {
+			continue
+		}
+		idx := sort.SearchFloat64s(numbers, number)
+		if idx == len(numbers) || numbers[idx] != number {
+			idx--
+		}
+		if idx < 0 {
+			results[fullCell] = formulaErrorNA
+			continue
+		}
+		resultRows, ok := sheetRows(info.result.sheet)
+		if !ok {
+			continue
+		}
+		rowIdx := info.result.startRow + rowIndexes[idx] - pattern.vector.startRow
+		results[fullCell] = f.lookupResultValue(info.result.sheet, resultRows, rowIdx, info.result.startCol-1)
+	}
+	return results
+}
+
+// batchCalculateLOOKUPWithCache calculates LOOKUP formulas over sorted columns
+// grouped by their lookup vector. The formulas parameter maps "Sheet!Cell" to
+// formula, it returns the results by cell and the number of scanned lookup
+// vectors.
+func (f *File) batchCalculateLOOKUPWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupLOOKUPByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateLOOKUPPatternWithCache(pattern, worksheetCache) {
+			results[cell] = value
+		}
+	}
+	f.logger().Debugf("  ⚡ [LOOKUP Batch] %d LOOKUP formulas over %d distinct lookup vectors", len(results), len(patterns))
+	return results, len(patterns)
+}
@@ -0,0 +1,204 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// lookupTable identifies the table range of a VLOOKUP, e.g. Data!$A:$E or
+// $A$2:$C$100
+type lookupTable struct {
+	sheet            string
+	startCol, endCol int // 1-based, inclusive
+	startRow, endRow int // 1-based, inclusive; TotalRows for whole-column references
+}
+
+// vlookupExact represents an exact match VLOOKUP with a constant column
+// index, e.g. VLOOKUP(A2,Data!$A:$E,3,FALSE)
+type vlookupExact struct {
+	lookup   string // lookup value argument: cell reference or literal
+	table    lookupTable
+	colIndex int // 1-based column of the table returned
+}
+
+// extractErrorGuardLookups recognizes an IFERROR/IFNA fallback chain with a
+// lookup on both sides, e.g. IFERROR(VLOOKUP(A2,T1,2,0),VLOOKUP(A2,T2,2,0)),
+// and returns the VLOOKUP expressions in evaluation order. The fallback may be
+// another guard, like IFERROR(VLOOKUP(...),IFERROR(VLOOKUP(...),"")). It
+// returns nil if the chain has less than two lookups.
+func extractErrorGuardLookups(formula string) []string {
+	var lookups []string
+	for guard := extractErrorGuard(formula); guard != nil; guard = extractErrorGuard(guard.fallback) {
+		if _, ok := parseVLOOKUPExact(guard.expr, ""); !ok {
+			return nil
+		}
+		lookups = append(lookups, guard.expr)
+		if _, ok := parseVLOOKUPExact(guard.fallback, ""); ok {
+			lookups = append(lookups, guard.fallback)
+			break
+		}
+	}
+	if len(lookups) < 2 {
+		return nil
+	}
+	return lookups
+}
+
+// parseVLOOKUPExact parses an exact match VLOOKUP expression, the sheet of an
+// unqualified table range defaults to currentSheet
+func parseVLOOKUPExact(expr, currentSheet string) (*vlookupExact, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "VLOOKUP(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "VLOOKUP")
+	if "VLOOKUP("+content+")" != expr {
+		return nil, false
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != 4 {
+		return nil, false
+	}
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if rangeLookup := strings.ToUpper(args[3]); rangeLookup != "0" && rangeLookup != "FALSE" {
+		return nil, false
+	}
+	colIndex, err := strconv.Atoi(args[2])
+	if err != nil || colIndex < 1 {
+		return nil, false
+	}
+	table, ok := parseLookupTable(args[1], currentSheet)
+	if !ok || colIndex > table.endCol-table.startCol+1 {
+		return nil, false
+	}
+	if args[0] == "" || strings.ContainsAny(args[0], "(),:") {
+		return nil, false
+	}
+	return &vlookupExact{lookup: args[0], table: table, colIndex: colIndex}, true
+}
+
+// parseLookupTable parses a table range reference like Data!$A:$E or A2:C100
+func parseLookupTable(ref, currentSheet string) (lookupTable, bool) {
+	table := lookupTable{sheet: currentSheet}
+	if idx := strings.LastIndex(ref, "!"); idx != -1 {
+		table.sheet = extractSheetName(ref)
+		ref = ref[idx+1:]
+	}
+	parts := strings.Split(strings.ReplaceAll(ref, "$", ""), ":")
+	if len(parts) != 2 {
+		return table, false
+	}
+	startCol, startRow, err1 := SplitCellName(parts[0])
+	endCol, endRow, err2 := SplitCellName(parts[1])
+	if err1 != nil || err2 != nil {
+		// Whole-column reference, like A:E
+		startCol, endCol = parts[0], parts[1]
+		startRow, endRow = 1, TotalRows
+	}
+	start, err1 := ColumnNameToNumber(startCol)
+	end, err2 := ColumnNameToNumber(endCol)
+	if err1 != nil || err2 != nil {
+		return table, false
+	}
+	table.startCol, table.endCol = min(start, end), max(start, end)
+	table.startRow, table.endRow = min(startRow, endRow), max(startRow, endRow)
+	return table, true
+}
+
+// vlookupKey returns the sub-expression cache key of a VLOOKUP expression,
+// the sheet is part of the key because the lookup value and an unqualified
+// table depend on the sheet of the formula
+func vlookupKey(sheet, expr string) string {
+	return sheet + "!" + expr
+}
+
+// batchCalculateErrorGuardLookupsWithCache calculates the VLOOKUP expressions
+// of IFERROR/IFNA lookup chains. The first column of each distinct table is
+// indexed once and shared by all lookups against the table. The formulas
+// parameter maps "Sheet!Cell" to formula, the result maps vlookupKey to the
+// looked-up value, or #N/A if the lookup value is not found.
+func (f *File) batchCalculateErrorGuardLookupsWithCache(formulas map[string]string, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string)
+	rowsBySheet := make(map[string][][]string)
+	indexes := make(map[lookupTable]map[string]int)
+	for cell, formula := range formulas {
+		sheet, _, ok := strings.Cut(cell, "!")
+		if !ok {
+			continue
+		}
+		for _, expr := range extractErrorGuardLookups(formula) {
+			key := vlookupKey(sheet, expr)
+			if _, exists := results[key]; exists {
+				continue
+			}
+			lookup, ok := parseVLOOKUPExact(expr, sheet)
+			if !ok {
+				continue
+			}
+			rows, exists := rowsBySheet[lookup.table.sheet]
+			if !exists {
+				fileRows, err := f.getCachedRawRows(lookup.table.sheet)
+				if err != nil {
+					continue
+				}
+				rows = mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(lookup.table.sheet))
+				rowsBySheet[lookup.table.sheet] = rows
+			}
+			index, exists := indexes[lookup.table]
+			if !exists {
+				index = f.buildLookupTableIndex(rows, lookup.table)
+				indexes[lookup.table] = index
+			}
+			rowIdx, found := index[f.lookupTableKey(f.resolveLookupValue(sheet, lookup.lookup, worksheetCache))]
+			if !found {
+				results[key] = formulaErrorNA
+				continue
+			}
+			results[key] = f.lookupResultValue(lookup.table.sheet, rows, rowIdx, lookup.table.startCol+lookup.colIndex-2)
+		}
+	}
+	f.logger().Debugf("  ⚡ [Lookup Chain Batch] %d VLOOKUP expressions over %d distinct tables", len(results), len(indexes))
+	return results
+}
+
+// buildLookupTableIndex maps the keys of the values of the first column of the
+// table to their row index, the first matching row wins like VLOOKUP does
+func (f *File) buildLookupTableIndex(rows [][]string, table lookupTable) map[string]int {
+	index := make(map[string]int)
+	for rowIdx := table.startRow - 1; rowIdx < len(rows) && rowIdx < table.endRow; rowIdx++ {
+		if table.startCol > len(rows[rowIdx]) {
+			continue
+		}
+		value := rows[rowIdx][table.startCol-1]
+		if value == "" {
+			continue
+		}
+		key := f.lookupTableKey(value)
+		if _, exists := index[key]; !exists {
+			index[key] = rowIdx
+		}
+	}
+	return index
+}
+
+// lookupTableKey returns the key of a value in a lookup table index: the
+// value is normalized by the configured LookupKeyNormalizer and case-folded,
+// as VLOOKUP matches text case-insensitively
+func (f *File) lookupTableKey(value string) string {
+	return strings.ToLower(f.normalizeLookupKey(value))
+}
+
+// resolveLookupValue resolves the lookup value argument of a VLOOKUP, which
+// may be a literal or a cell reference, optionally on another sheet
+func (f *File) resolveLookupValue(sheet, arg string, worksheetCache *WorksheetCache) string {
+	if strings.HasPrefix(arg, `"`) {
+		return f.resolveCriteriaValue(sheet, arg, worksheetCache)
+	}
+	if idx := strings.LastIndex(arg, "!"); idx != -1 {
+		sheet = extractSheetName(arg)
+		arg = arg[idx+1:]
+	}
+	return f.resolveCriteriaValue(sheet, strings.ReplaceAll(arg, "$", ""), worksheetCache)
+}
@@ -0,0 +1,82 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExtractErrorGuardLookups(t *testing.T) {
+	for formula, want := range map[string][]string{
+		"=IFERROR(VLOOKUP(A2,T!$A:$B,2,0),VLOOKUP(A2,U!$A:$B,2,FALSE))":   {"VLOOKUP(A2,T!$A:$B,2,0)", "VLOOKUP(A2,U!$A:$B,2,FALSE)"},
+		`IFERROR(VLOOKUP(A2,T!A1:B9,2,0),IFNA(VLOOKUP(A2,U!A:C,3,0),""))`: {"VLOOKUP(A2,T!A1:B9,2,0)", "VLOOKUP(A2,U!A:C,3,0)"},
+		`IFERROR(VLOOKUP(A2,T!$A:$B,2,0),"")`:                             nil,
+		"IFERROR(VLOOKUP(A2,T!$A:$B,2,1),VLOOKUP(A2,U!$A:$B,2,0))":        nil,
+		"IFERROR(VLOOKUP(A2,T!$A:$B,3,0),VLOOKUP(A2,U!$A:$B,2,0))":        nil,
+		"SUM(VLOOKUP(A2,T!$A:$B,2,0),VLOOKUP(A2,U!$A:$B,2,0))":            nil,
+	} {
+		if got := extractErrorGuardLookups(formula); !reflect.DeepEqual(got, want) {
+			t.Fatalf("extractErrorGuardLookups(%q) = %v, want %v", formula, got, want)
+		}
+	}
+}
+
+func TestBatchErrorGuardLookupChain(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for sheet, rows := range map[string][][]interface{}{
+		"Primary":  {{"k1", "p1"}, {"k2", "p2"}, {"k5", true}},
+		"Fallback": {{"K2", "f2"}, {"k3", "f3"}, {"k3", "dup"}},
+	} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("new sheet: %v", err)
+		}
+		for i, row := range rows {
+			if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &row); err != nil {
+				t.Fatalf("set row: %v", err)
+			}
+		}
+	}
+	formula := "IFERROR(VLOOKUP(A%d,Primary!$A:$B,2,0),VLOOKUP(A%d,Fallback!$A:$B,2,FALSE))"
+	formulas := make(map[string]string)
+	for i, key := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		cell := fmt.Sprintf("B%d", row)
+		formulas["Sheet1!"+cell] = fmt.Sprintf(formula, row, row)
+		if err := f.SetCellFormula("Sheet1", cell, formulas["Sheet1!"+cell]); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 两侧的查找都被批量计算并缓存：主表未命中时为 #N/A，备用表命中
+	results := f.batchCalculateErrorGuardLookupsWithCache(formulas, NewWorksheetCache())
+	for expr, want := range map[string]string{
+		"VLOOKUP(A3,Primary!$A:$B,2,0)":      "p2",
+		"VLOOKUP(A4,Primary!$A:$B,2,0)":      formulaErrorNA,
+		"VLOOKUP(A4,Fallback!$A:$B,2,FALSE)": "f3",
+		"VLOOKUP(A5,Fallback!$A:$B,2,FALSE)": formulaErrorNA,
+		"VLOOKUP(A6,Primary!$A:$B,2,0)":      "TRUE",
+	} {
+		if got, ok := results[vlookupKey("Sheet1", expr)]; !ok || got != want {
+			t.Fatalf("unexpected cached %s: %q, %t, want %q", expr, got, ok, want)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range map[string]string{"B2": "p1", "B3": "p2", "B4": "f3", "B5": formulaErrorNA, "B6": "TRUE"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, value, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package excelize
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestExtractLOOKUPPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for formula, want := range map[string]bool{
+		"=LOOKUP(B2,Rates!$A:$A,Rates!$C:$C)":  true,
+		"LOOKUP(B2,$A$2:$A$9,$C$12:$C$19)":     true,
+		"LOOKUP(B2,$A$2:$A$9)":                 true,
+		"LOOKUP(B2,$A$2:$C$9)":                 true,
+		"LOOKUP(B2,$A$2:$A$9,$C$2:$C$8)":       false,
+		"LOOKUP(B2,$A$2:$B$9,$C$2:$C$9)":       false,
+		"LOOKUP(B2,$A$1:$I$2)":                 false,
+		"LOOKUP(B2,$A$2:$A$9,$C$2:$C$9)+1":     false,
+		"LOOKUP(SUM(B2),$A$2:$A$9,$C$2:$C$9)":  false,
+		"VLOOKUP(B2,$A$2:$C$9,3,TRUE)":         false,
+		"LOOKUP(B2,$A$2:$A$9,$C$2:$C$9,FALSE)": false,
+	} {
+		if got := f.extractLOOKUPPattern("Sheet1", "D2", formula) != nil; got != want {
+			t.Fatalf("extractLOOKUPPattern(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	pattern := f.extractLOOKUPPattern("Sheet1", "D2", "LOOKUP(B2,$A$2:$C$9)")
+	if info := pattern.formulas["Sheet1!D2"]; pattern.vector.endCol != 1 || info.result.startCol != 3 || info.result.sheet != "Sheet1" {
+		t.Fatalf("unexpected pattern %+v", pattern)
+	}
+}
+
+func TestBatchCalculateLOOKUP(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Rates"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 已排序的查找列，包含重复值和空单元格，结果列由公式计算
+	for i, threshold := range []interface{}{0, 100, 100, nil, 250, 500, 1000} {
+		row := i + 2
+		if err := f.SetSheetRow("Rates", fmt.Sprintf("A%d", row), &[]interface{}{threshold, fmt.Sprintf("band-%d", row)}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		if err := f.SetCellFormula("Rates", fmt.Sprintf("C%d", row), fmt.Sprintf("B%d&\"!\"", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		// 未排序的查找列逐个计算
+		if err := f.SetCellValue("Rates", fmt.Sprintf("E%d", row), 7-i); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	// 逻辑值结果返回 TRUE 和 FALSE
+	if err := f.SetCellValue("Rates", "B7", true); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	amounts := []interface{}{-5, 0, 50, 100, 180, 250, 499.5, 500, 999, 1000, 5000, "text", nil}
+	for i, amount := range amounts {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), amount); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": "LOOKUP(A%d,Rates!$A$2:$A$20,Rates!$C$2:$C$20)",
+			"C": "LOOKUP(A%d,Rates!$A:$A,Rates!$B:$B)",
+			"D": "LOOKUP(A%d,Rates!$A$2:$B$20)",
+			"E": "LOOKUP(A%d,Rates!$E$2:$E$8,Rates!$B$2:$B$8)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 批量结果与逐个计算的结果一致
+	want := make(map[string]string)
+	for i := range amounts {
+		for _, col := range []string{"B", "C", "D", "E"} {
+			cell := fmt.Sprintf("%s%d", col, i+2)
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorNA {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[cell] = value
+		}
+	}
+	f.ClearFormulaCache()
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{
+		"B2": formulaErrorNA, "B3": "band-2!", "B4": "band-2!", "B5": "band-3!", "B6": "band-4!",
+		"B7": "band-6!", "B8": "band-6!", "B12": "band-8!", "C10": "TRUE", "D11": "band-8",
+	} {
+		if want[cell] != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, want[cell], value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.LOOKUPFormulas != 4*len(amounts) || plan.LOOKUPVectors != 4 {
+		t.Fatalf("unexpected plan LOOKUP formulas %d, vectors %d", plan.LOOKUPFormulas, plan.LOOKUPVectors)
+	}
+	// 未排序的查找列和不是数字的查找值逐个计算
+	formulas := make(map[string]string)
+	for i := range amounts {
+		for _, col := range []string{"B", "E"} {
+			cell := fmt.Sprintf("%s%d", col, i+2)
+			formula, _ := f.GetCellFormula("Sheet1", cell)
+			formulas["Sheet1!"+cell] = formula
+		}
+	}
+	if results, vectors := f.batchCalculateLOOKUPWithCache(formulas, NewWorksheetCache()); len(results) != len(amounts)-2 || vectors != 2 {
+		t.Fatalf("unexpected %d batch results over %d vectors", len(results), vectors)
+	}
+	// 查找列和结果列都是依赖
+	graph, err := f.buildDependencyGraphWithContext(context.Background())
+	if err != nil {
+		t.Fatalf("build graph: %v", err)
+	}
+	if node := graph.nodes["Sheet1!B5"]; node == nil || node.level <= graph.nodes["Rates!C4"].level {
+		t.Fatalf("unexpected level of the LOOKUP formula %+v", node)
+	}
+	if err := f.SetCellValue("Rates", "B3", "changed"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Rates!B3": true}); err != nil {
+		t.Fatalf("recalculate affected: %v", err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "B5"); got != "changed!" {
+		t.Fatalf("unexpected B5 value %q after changing the result vector", got)
+	}
+}
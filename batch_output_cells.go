@@ -0,0 +1,158 @@
+package excelize
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cellColumnKey returns the "Sheet!Col" key of the column of a formula cell
+// of the dependency graph.
+func cellColumnKey(cell string) string {
+	idx := strings.LastIndex(cell, "!")
+	if idx == -1 {
+		return ""
+	}
+	return cell[:idx+1] + extractColumnFromRef(cell[idx+1:])
+}
+
+// reverseDependencies returns the reverse dependency map of the graph: the
+// formulas depending on each cell, and the formulas depending on each column
+// with the "COLUMN:Sheet!Col" keys, which depend on all formula cells of the
+// column.
+func (g *dependencyGraph) reverseDependencies() map[string][]string {
+	reverseDeps := make(map[string][]string)
+	for cell, node := range g.nodes {
+		for _, dep := range node.dependencies {
+			reverseDeps[dep] = append(reverseDeps[dep], cell)
+		}
+	}
+	return reverseDeps
+}
+
+// outputCells returns the sorted formula cells of the graph which no other
+// formula depends on, neither directly nor by a column range.
+func (g *dependencyGraph) outputCells() []string {
+	reverseDeps := g.reverseDependencies()
+	hasDependents := func(key, cell string) bool {
+		for _, dependent := range reverseDeps[key] {
+			if dependent != cell {
+				return true
+			}
+		}
+		return false
+	}
+	var outputs []string
+	for cell := range g.nodes {
+		if !hasDependents(cell, cell) && !hasDependents("COLUMN:"+cellColumnKey(cell), cell) {
+			outputs = append(outputs, cell)
+		}
+	}
+	sort.Strings(outputs)
+	return outputs
+}
+
+// precedents returns the given formula cells and all formula cells they
+// depend on, directly or indirectly.
+func (g *dependencyGraph) precedents(cells []string) map[string]bool {
+	columnCells := make(map[string][]string)
+	for cell := range g.nodes {
+		colKey := cellColumnKey(cell)
+		columnCells[colKey] = append(columnCells[colKey], cell)
+	}
+	required := make(map[string]bool, len(cells))
+	queue := append([]string(nil), cells...)
+	for _, cell := range cells {
+		required[cell] = true
+	}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, dep := range g.nodes[cell].dependencies {
+			deps := []string{dep}
+			if colKey, ok := strings.CutPrefix(dep, "COLUMN:"); ok {
+				deps = columnCells[colKey]
+			}
+			for _, dep := range deps {
+				if _, ok := g.nodes[dep]; ok && !required[dep] {
+					required[dep] = true
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+	return required
+}
+
+// OutputCells 返回工作簿中的输出单元格，即没有被任何其他公式直接或通过整列范围
+// 引用的公式单元格，例如报表的最终数值。返回值为按字母排序的 "Sheet!Cell" 列表。
+func (f *File) OutputCells() []string {
+	return f.buildDependencyGraph().outputCells()
+}
+
+// RecalculateOutputs 只重算输出单元格（参见 OutputCells）及其直接或间接依赖的
+// 公式，不被任何输出单元格依赖的公式（例如循环引用中的公式）保留原值。
+// 计算结果写入单元格，返回输出单元格的值 ("Sheet!Cell" -> 值)。例如仪表盘只需
+// 要最终数值：
+//
+//	values, err := f.RecalculateOutputs()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	fmt.Println(values["Summary!B10"])
+func (f *File) RecalculateOutputs() (map[string]string, error) {
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	f.logger().Infof("📊 [RecalculateOutputs] Starting recalculation of the output cells")
+	startTime := time.Now()
+
+	graph := f.buildDependencyGraph()
+	outputs := graph.outputCells()
+	required := graph.precedents(outputs)
+
+	// 为输出单元格及其依赖构建小型依赖图
+	subGraph := &dependencyGraph{
+		nodes:             make(map[string]*formulaNode, len(required)),
+		columnMetadata:    graph.columnMetadata,
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
+	}
+	for cell := range required {
+		node := graph.nodes[cell]
+		subGraph.nodes[cell] = &formulaNode{
+			cell:         cell,
+			formula:      node.formula,
+			dependencies: node.dependencies,
+			level:        -1,
+		}
+	}
+	subGraph.assignLevels()
+	f.logger().Debugf("  📊 [RecalculateOutputs] %d outputs require %d of %d formulas", len(outputs), len(required), len(graph.nodes))
+
+	for cell := range required {
+		f.calcCache.Delete(cell)
+		f.calcCache.Delete(cell + "!raw=false")
+		f.calcCache.Delete(cell + "!raw=true")
+	}
+	f.rangeCache.Clear()
+
+	if err := f.calculateByDAGWithContext(context.Background(), subGraph); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(outputs))
+	for _, cell := range outputs {
+		idx := strings.LastIndex(cell, "!")
+		value, err := f.GetCellValue(cell[:idx], cell[idx+1:])
+		if err != nil {
+			return nil, err
+		}
+		values[cell] = value
+	}
+	f.logger().Infof("✅ [RecalculateOutputs] Completed in %v (calculated %d formulas)", time.Since(startTime), len(required))
+	return values, nil
+}
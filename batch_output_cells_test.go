@@ -0,0 +1,49 @@
+package excelize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutputCells(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	// B1 和 E1 是中间单元格，E1 通过整列范围被 D1 引用；X1 和 Y1 构成循环引用
+	for cell, formula := range map[string]string{
+		"B1": "A1*2", "C1": "B1+1", "E1": "A1*3", "D1": "SUM(E:E)", "F1": "1+1", "X1": "Y1", "Y1": "X1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	outputs := []string{"Sheet1!C1", "Sheet1!D1", "Sheet1!F1"}
+	if got := f.OutputCells(); !reflect.DeepEqual(got, outputs) {
+		t.Fatalf("expected output cells %v, got %v", outputs, got)
+	}
+
+	values, err := f.RecalculateOutputs()
+	if err != nil {
+		t.Fatalf("recalculate outputs: %v", err)
+	}
+	if expected := map[string]string{"Sheet1!C1": "5", "Sheet1!D1": "6", "Sheet1!F1": "2"}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected output values %v, got %v", expected, values)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "B1"); value != "4" {
+		t.Fatalf("expected the intermediate B1 to be calculated, got %q", value)
+	}
+
+	if err := f.SetCellValue("Sheet1", "A1", 3); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if values, err = f.RecalculateOutputs(); err != nil || values["Sheet1!C1"] != "7" || values["Sheet1!D1"] != "9" {
+		t.Fatalf("expected C1=7 and D1=9 after the update, got %v, %v", values, err)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "X1"); value != "" {
+		t.Fatalf("expected the circular references not to be calculated, got X1=%q", value)
+	}
+}
@@ -0,0 +1,145 @@
+package excelize
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/efp"
+)
+
+// RecalculateAffectedByRowShift 增量重算：插入或删除行之后，只重算受行平移影响的公式
+//
+// InsertRows/RemoveRow 会改写所有引用平移行的公式文本，但公式的缓存值不会更新。
+// 此函数在插入或删除行之后调用，重新提取公式的依赖（公式文本可能已被改写），
+// 找出以下公式及依赖于它们的公式，并使用 DAG 分层计算：
+//  1. 引用该工作表中起始行及以下单元格的公式，包括其他工作表中的跨表引用、整列引用，
+//     以及引用到 OFFSET/INDIRECT 目标工作表的公式
+//  2. 位于该工作表中起始行及以下的公式（公式本身被平移）
+//  3. 引用被删除单元格、已变为 #REF! 的公式
+//
+// 参数：
+//
+//	sheet:    插入或删除行的工作表
+//	startRow: 插入或删除的起始行号（从 1 开始）
+//	count:    插入的行数（正数）或删除的行数（负数）
+//
+// 示例：
+//
+//	if err := f.InsertRows("Data", 3, 2); err != nil {
+//	    return err
+//	}
+//	err := f.RecalculateAffectedByRowShift("Data", 3, 2)
+func (f *File) RecalculateAffectedByRowShift(sheet string, startRow, count int) error {
+	return f.RecalculateAffectedByRowShiftWithContext(context.Background(), sheet, startRow, count)
+}
+
+// RecalculateAffectedByRowShiftWithContext 是 RecalculateAffectedByRowShift 的可取消版本，
+// 在依赖图构建、BFS 传播和 DAG 分层计算过程中检查 ctx，取消后尽快返回 ctx.Err()。
+// 取消时已计算的公式保留新值，其余公式保留旧值。
+func (f *File) RecalculateAffectedByRowShiftWithContext(ctx context.Context, sheet string, startRow, count int) error {
+	if count == 0 {
+		return nil
+	}
+	if startRow < 1 {
+		return newInvalidRowNumberError(startRow)
+	}
+	sheetID := f.getSheetID(sheet)
+	if sheetID == -1 {
+		return ErrSheetNotExist{sheet}
+	}
+	sheet = f.GetSheetMap()[sheetID]
+
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	f.logger().Infof("📊 [IncrementalRecalc] Starting recalculation for %d rows shifted at %s!%d", count, sheet, startRow)
+	startTime := time.Now()
+
+	// 步骤1：构建完整依赖图，重新提取所有公式的依赖
+	graph, err := f.cachedDependencyGraph(ctx)
+	if err != nil {
+		return err
+	}
+	if len(graph.nodes) == 0 {
+		f.logger().Debugf("  ⚠️  No formulas found, skipping recalculation")
+		return nil
+	}
+
+	// 步骤2：找出直接受行平移影响的公式
+	shifted := make(map[string]bool)
+	for cell, node := range graph.nodes {
+		formulaSheet, cellRef, _ := strings.Cut(cell, "!")
+		if formulaSheet == sheet {
+			if _, row, err := CellNameToCoordinates(cellRef); err == nil && row >= startRow {
+				shifted[cell] = true
+				continue
+			}
+		}
+		if formulaReferencesRowsFrom(node.formula, formulaSheet, sheet, startRow) {
+			shifted[cell] = true
+		}
+	}
+
+	// 步骤3：BFS 找出依赖于这些公式的公式
+	affectedCells := f.findAffectedCellsByCells(graph, shifted)
+	for cell := range shifted {
+		affectedCells[cell] = true
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.logger().Debugf("  📊 Found %d affected formulas (%d referencing shifted rows, out of %d total)", len(affectedCells), len(shifted), len(graph.nodes))
+	if len(affectedCells) == 0 {
+		f.logger().Debugf("  ✅ No affected formulas, skipping recalculation")
+		return nil
+	}
+
+	return f.calculateAffectedCells(ctx, graph, affectedCells, startTime)
+}
+
+// formulaReferencesRowsFrom 检查公式是否引用了指定工作表中 row 行及以下的单元格。
+// 整列引用、OFFSET/INDIRECT 引用的目标工作表和 #REF! 引用都视为受影响。
+func formulaReferencesRowsFrom(formula, currentSheet, sheet string, row int) bool {
+	if strings.Contains(formula, formulaErrorREF) {
+		return true
+	}
+	upperFormula := strings.ToUpper(formula)
+	if strings.Contains(upperFormula, "OFFSET(") || strings.Contains(upperFormula, "INDIRECT(") {
+		if strings.EqualFold(currentSheet, sheet) {
+			return true
+		}
+		for _, sheetName := range extractSheetReferences(formula) {
+			if strings.EqualFold(sheetName, sheet) {
+				return true
+			}
+		}
+	}
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(formula) {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		refSheet, ref, ok := splitSheetReference(token.TValue)
+		if !ok {
+			refSheet = currentSheet
+		}
+		if !strings.EqualFold(refSheet, sheet) {
+			continue
+		}
+		for _, part := range strings.Split(strings.ReplaceAll(ref, "$", ""), ":") {
+			if _, refRow, err := CellNameToCoordinates(part); err == nil {
+				if refRow >= row {
+					return true
+				}
+				continue
+			}
+			// 整行引用（如 3:5）或整列引用（如 A:A）
+			if refRow, err := strconv.Atoi(part); err != nil || refRow >= row {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package excelize
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecalculateAffectedByRowShift(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 5; row++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for _, formula := range [][3]string{
+		{"Sheet1", "B1", "SUM(Data!A1:A5)"},
+		{"Sheet1", "B2", "Data!A2*10"},
+		{"Sheet1", "B3", "B1+1"},
+		{"Sheet1", "B4", "Data!A4"},
+		{"Data", "B5", "A5*2"},
+	} {
+		if err := f.SetCellFormula(formula[0], formula[1], formula[2]); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 不受影响的公式，使受影响的公式少于一半，只重算过滤后的依赖图
+	for row := 1; row <= 6; row++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("Data!$A$1+%d", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	check := func(want map[string]string) {
+		t.Helper()
+		for ref, value := range want {
+			sheet, cell := "Sheet1", ref
+			if ref == "B5" || ref == "B6" {
+				sheet = "Data"
+			}
+			if got, _ := f.GetCellValue(sheet, cell); got != value {
+				t.Fatalf("unexpected %s!%s value %q, want %q", sheet, cell, got, value)
+			}
+		}
+	}
+
+	// 插入行：A2 的修改不在平移范围内，B2 不会被重算
+	if err := f.InsertRows("Data", 3, 1); err != nil {
+		t.Fatalf("insert rows: %v", err)
+	}
+	for cell, value := range map[string]int{"A2": 7, "A3": 100} {
+		if err := f.SetCellValue("Data", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	if err := f.RecalculateAffectedByRowShift("Data", 3, 1); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	check(map[string]string{"B1": "120", "B2": "20", "B3": "121", "B4": "4", "B6": "10"})
+
+	// 删除行：引用被删除单元格的公式变为 #REF!
+	if err := f.RemoveRow("Data", 5); err != nil {
+		t.Fatalf("remove row: %v", err)
+	}
+	if err := f.RecalculateAffectedByRowShift("Data", 5, -1); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	check(map[string]string{"B1": "116", "B2": "20", "B3": "117", "B4": formulaErrorREF, "B5": "10"})
+
+	if err := f.RecalculateAffectedByRowShift("SheetN", 1, 1); err == nil {
+		t.Fatal("expected error for a missing sheet")
+	}
+	if err := f.RecalculateAffectedByRowShift("Data", 0, 1); err == nil {
+		t.Fatal("expected error for an invalid row number")
+	}
+}
+
+func TestRecalculateAffectedByRowShiftQuotedSheet(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("O'Brien"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 5; row++ {
+		if err := f.SetCellValue("O'Brien", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("'O''Brien'!$A$1+%d", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "B1", "SUM('O''Brien'!A1:A5)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := f.InsertRows("O'Brien", 3, 1); err != nil {
+		t.Fatalf("insert rows: %v", err)
+	}
+	if err := f.SetCellValue("O'Brien", "A3", 100); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByRowShift("O'Brien", 3, 1); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "B1"); got != "115" {
+		t.Fatalf("unexpected B1 value %q, want %q", got, "115")
+	}
+}
+
+func TestFormulaReferencesRowsFrom(t *testing.T) {
+	for formula, want := range map[string]bool{
+		"SUM(Data!A1:A5)":          true,
+		"SUM(Data!$A$1:$A$2)":      false,
+		"'Data'!B9+1":              true,
+		"Data!A:A":                 true,
+		"SUM(Data!1:2)":            false,
+		"SUM(Data!2:3)":            true,
+		"Other!A9+A1":              false,
+		"Data!#REF!":               true,
+		"SUM(OFFSET(Data!A1,0,0))": true,
+		"SUM(Data!A1:Data!A9)":     true,
+		"SUM(Data!A1:Data!A2)":     false,
+	} {
+		if got := formulaReferencesRowsFrom(formula, "Sheet1", "Data", 3); got != want {
+			t.Fatalf("formulaReferencesRowsFrom(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	for formula, want := range map[string]bool{
+		"'O''Brien'!A5*2":                   true,
+		"SUM('O''Brien'!A1:A2)":             false,
+		"SUM('O''Brien'!A1:'O''Brien'!A9)":  true,
+		"SUM(O'Brien!A1:A2)+'Q1!Data'!A9":   false,
+		"SUM('Q1!Data'!A1:'Q1!Data'!A9)+A9": false,
+	} {
+		if got := formulaReferencesRowsFrom(formula, "Sheet1", "O'Brien", 3); got != want {
+			t.Fatalf("formulaReferencesRowsFrom(%q) = %t, want %t", formula, got, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package excelize
+
+import "sync"
+
+// defaultStreamRowsThreshold is the default number of rows of a source sheet
+// from which the batch patterns stream the rows.
+const defaultStreamRowsThreshold = 100000
+
+// streamRowsChunkSize is the number of rows decoded under the worksheet lock
+// and sent to the scanning workers at a time.
+const streamRowsChunkSize = 4096
+
+// shouldStreamRows reports whether the batch patterns stream the rows of a
+// source sheet instead of decoding the whole sheet. The rows already decoded
+// in the SheetDataCache of the recalculation are reused.
+func (f *File) shouldStreamRows(sheet string) bool {
+	threshold := f.calcTuning.StreamRowsThreshold
+	if threshold < 0 {
+		return false
+	}
+	if threshold == 0 {
+		threshold = defaultStreamRowsThreshold
+	}
+	if c := f.sheetDataCache.Load(); c != nil && c.Has(sheet) {
+		return false
+	}
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return lastUsedRow(ws) >= threshold
+}
+
+// lastUsedRow returns the number of the last row of a worksheet with a
+// non-empty cell value, the trailing rows of the formula cells with empty
+// values up to a bloated dimension are skipped. The worksheet must be locked.
+func lastUsedRow(ws *xlsxWorksheet) int {
+	for i := len(ws.SheetData.Row) - 1; i >= 0; i-- {
+		for _, c := range ws.SheetData.Row[i].C {
+			if c.V != "" || (c.IS != nil && c.IS.String() != "") {
+				return ws.SheetData.Row[i].R
+			}
+		}
+	}
+	return 0
+}
+
+// streamRawRows streams the raw cell values of the used rows in span of a
+// worksheet like getUsedRows(sheet, Options{RawCellValue: true}), without
+// materializing the sheet. Each streamed row holds the values of the columns
+// cols in order, overlaid by the calculated values of the cells in overlay
+// and of a running shadow recalculation.
+// The text cells of the sum column sumCol are read as empty like
+// sumifsTypedRows does, 0 keeps the text values of all columns.
+// The rows are sent in chunks and the channel is closed after the last row.
+// The worksheet is locked only while a chunk is decoded, so the batch tasks
+// can store their results in between, and the consumers must drain the
+// channel.
+func (f *File) streamRawRows(sheet string, span [2]int, cols []int, sumCol int, overlay map[string]formulaArg) (<-chan [][]string, error) {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	sst, err := f.sharedStringsReader()
+	if err != nil {
+		return nil, err
+	}
+	overlay = f.mergeShadowIntoOverlay(sheet, overlay)
+	// 只读取到最后一个非空行，叠加的计算结果可能在其后
+	if len(overlay) == 0 {
+		ws.mu.Lock()
+		span[1] = min(span[1], lastUsedRow(ws))
+		ws.mu.Unlock()
+	}
+	maxCol := 0
+	for _, col := range cols {
+		maxCol = max(maxCol, col)
+	}
+	out := make(chan [][]string, f.calcWorkers())
+	go func() {
+		defer close(out)
+		for next, more := 0, true; more; {
+			var chunk [][]string
+			chunk, next, more = f.decodeRowChunk(ws, sst, next, span, cols, maxCol, sumCol, overlay)
+			if len(chunk) > 0 {
+				out <- chunk
+			}
+		}
+	}()
+	return out, nil
+}
+
+// decodeRowChunk decodes the columns cols of up to streamRowsChunkSize rows in
+// span of a worksheet, starting from the row at index next of the sheet data.
+// It returns the decoded rows, the index of the next row and if there are
+// more rows to decode.
+func (f *File) decodeRowChunk(ws *xlsxWorksheet, sst *xlsxSST, next int, span [2]int, cols []int, maxCol, sumCol int, overlay map[string]formulaArg) ([][]string, int, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	rows := ws.SheetData.Row
+	chunk := make([][]string, 0, streamRowsChunkSize)
+	for ; next < len(rows) && len(chunk) < streamRowsChunkSize; next++ {
+		row := &rows[next]
+		if row.R < span[0] {
+			continue
+		}
+		if row.R > span[1] {
+			return chunk, next, false
+		}
+		values := make([]string, len(cols))
+		for i := range row.C {
+			c := &row.C[i]
+			col := i + 1
+			if c.R != "" {
+				var err error
+				if col, _, err = CellNameToCoordinates(c.R); err != nil {
+					continue
+				}
+			}
+			if col > maxCol {
+				break
+			}
+			for j, want := range cols {
+				if col == want && (col != sumCol || !isTextCell(c)) {
+					values[j], _ = c.getValueFrom(f, sst, true)
+					// 逻辑值按 TRUE 和 FALSE 区别于数值
+					if c.T == "b" {
+						values[j] = sumifsBooleanValue(values[j])
+					}
+				}
+			}
+		}
+		if len(overlay) > 0 {
+			for j, col := range cols {
+				cell, err := CoordinatesToCellName(col, row.R)
+				if err != nil {
+					continue
+				}
+				if arg, ok := overlay[cell]; ok {
+					values[j] = arg.Value()
+				}
+			}
+		}
+		chunk = append(chunk, values)
+	}
+	return chunk, next, next < len(rows)
+}
+
+// sliceRowStream sends the rows in chunks to a channel, so the result map
+// builders consuming a row stream scan the decoded rows of small sheets too.
+func sliceRowStream(rows [][]string) <-chan [][]string {
+	out := make(chan [][]string)
+	go func() {
+		defer close(out)
+		for start := 0; start < len(rows); start += streamRowsChunkSize {
+			out <- rows[start:min(start+streamRowsChunkSize, len(rows))]
+		}
+	}()
+	return out
+}
+
+// buildResultMapFromRowStream builds the result map of a two-criteria SUMIFS
+// by the workers consuming the rows from a channel, the indexes are the
+// positions of the sum and criteria values in the streamed rows.
+func (f *File) buildResultMapFromRowStream(rows <-chan [][]string, sumColIdx, criteria1ColIdx, criteria2ColIdx int) map[string]map[string]float64 {
+	numWorkers := f.calcWorkers()
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	results := make([]map[string]map[string]float64, numWorkers)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			localMap := make(map[string]map[string]float64)
+			for chunk := range rows {
+				accumulateSUMIFS2D(localMap, chunk, sumColIdx, criteria1ColIdx, criteria2ColIdx)
+			}
+			results[workerID] = localMap
+		}(i)
+	}
+	wg.Wait()
+
+	// Merge results
+	finalMap := make(map[string]map[string]float64)
+	for _, r := range results {
+		for c1, m := range r {
+			if finalMap[c1] == nil {
+				finalMap[c1] = make(map[string]float64)
+			}
+			for c2, sum := range m {
+				finalMap[c1][c2] += sum
+			}
+		}
+	}
+	return finalMap
+}
+
+// streamColumns returns the column numbers of the column names for
+// streamRawRows.
+func streamColumns(names ...string) ([]int, bool) {
+	cols := make([]int, len(names))
+	for i, name := range names {
+		col, err := ColumnNameToNumber(name)
+		if err != nil {
+			return nil, false
+		}
+		cols[i] = col
+	}
+	return cols, true
+}
@@ -0,0 +1,171 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestStreamRawRows(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 行数超过一个分块，第 5000 行之后留空行
+	regions := []string{"East", "West", "North", "South", "Central", "Overseas", "Online"}
+	for row := 1; row <= 5000; row++ {
+		values := []interface{}{regions[row%len(regions)], row % 3, row, []string{"x", "y"}[row%2]}
+		if row%11 == 0 {
+			values[2] = nil
+		}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &values); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Data", "C6000", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+
+	// 流式读取的行与 GetRows 读取的原始值一致
+	want, err := f.GetRows("Data", Options{RawCellValue: true})
+	if err != nil {
+		t.Fatalf("get rows: %v", err)
+	}
+	rows, err := f.streamRawRows("Data", [2]int{2, 5001}, []int{3, 1}, 0, map[string]formulaArg{"A4100": newStringFormulaArg("Calculated")})
+	if err != nil {
+		t.Fatalf("stream rows: %v", err)
+	}
+	var streamed [][]string
+	chunks := 0
+	for chunk := range rows {
+		streamed = append(streamed, chunk...)
+		chunks++
+	}
+	if len(streamed) != 5000 || chunks != 2 {
+		t.Fatalf("unexpected %d rows in %d chunks, want 5000 rows in 2 chunks", len(streamed), chunks)
+	}
+	for i, row := range streamed {
+		wantRow := make([]string, 2)
+		if len(want[i+1]) >= 1 {
+			wantRow[1] = want[i+1][0]
+		}
+		if len(want[i+1]) >= 3 {
+			wantRow[0] = want[i+1][2]
+		}
+		if i+2 == 4100 {
+			wantRow[1] = "Calculated"
+		}
+		if row[0] != wantRow[0] || row[1] != wantRow[1] {
+			t.Fatalf("unexpected row %d %q, want %q", i+2, row, wantRow)
+		}
+	}
+	if _, err := f.streamRawRows("Missing", [2]int{1, TotalRows}, []int{1}, 0, nil); err == nil {
+		t.Fatalf("expected an error streaming a missing sheet")
+	}
+
+	// 达到阈值的工作表才流式读取，负数阈值不流式读取
+	for threshold, want := range map[int]bool{0: false, 1000: true, 6000: true, 6001: false, -1: false} {
+		f.SetCalcTuning(CalcTuning{StreamRowsThreshold: threshold})
+		if got := f.shouldStreamRows("Data"); got != want {
+			t.Fatalf("shouldStreamRows with threshold %d = %t, want %t", threshold, got, want)
+		}
+	}
+	f.SetCalcTuning(CalcTuning{StreamRowsThreshold: 1000})
+	cache := NewSheetDataCache()
+	f.sheetDataCache.Store(cache)
+	if _, err := cache.GetRows(f, "Data"); err != nil {
+		t.Fatalf("cache rows: %v", err)
+	}
+	if f.shouldStreamRows("Data") {
+		t.Fatalf("expected the rows in the sheet data cache to be reused")
+	}
+	f.sheetDataCache.Store(nil)
+}
+
+func TestBatchSUMIFSWithRowStream(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	regions := []string{"East", "West", "North", "South"}
+	for row := 1; row <= 6000; row++ {
+		values := []interface{}{regions[row%len(regions)], row % 3, row % 97, []string{"x", "y"}[row%2]}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &values); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	formulas := map[string]string{
+		"C%d": "SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,1)",
+		"D%d": "SUMIFS(Data!$C:$C,Data!$A:$A,$A%d)",
+		"E%d": `SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,2,Data!$D:$D,"y")`,
+		"F%d": "SUMIFS(Data!$C$100:$C$4200,Data!$A$100:$A$4200,$A%d,Data!$B$100:$B$4200,0)",
+	}
+	for i, region := range regions {
+		row := i + 1
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), region); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for cell, formula := range formulas {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf(cell, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 同一组公式分别用 GetRows 和流式读取计算，结果一致
+	calc := func(threshold int) map[string]float64 {
+		f.SetCalcTuning(CalcTuning{StreamRowsThreshold: threshold})
+		results := make(map[string]float64)
+		for i := range regions {
+			row := i + 1
+			cache := NewWorksheetCache()
+			for cell, value := range f.calculateSUMIFS2DPatternWithCache(f.extractSUMIFS2DPattern("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf(formulas["C%d"], row)), cache) {
+				results[cell] = value
+			}
+			for cell, value := range f.calculateSUMIFS1DPatternWithCache(f.extractSUMIFS1DPattern("Sheet1", fmt.Sprintf("D%d", row), fmt.Sprintf(formulas["D%d"], row)), cache) {
+				results[cell] = value
+			}
+			for cell, value := range f.calculateSUMIFSNDPatternWithCache(f.extractSUMIFSNDPattern("Sheet1", fmt.Sprintf("E%d", row), fmt.Sprintf(formulas["E%d"], row)), cache) {
+				results[cell] = value
+			}
+			for cell, value := range f.calculateSUMIFS2DPatternWithCache(f.extractSUMIFS2DPattern("Sheet1", fmt.Sprintf("F%d", row), fmt.Sprintf(formulas["F%d"], row)), cache) {
+				results[cell] = value
+			}
+		}
+		return results
+	}
+	want, got := calc(-1), calc(1000)
+	if len(want) != 4*len(regions) || len(got) != len(want) {
+		t.Fatalf("unexpected %d streamed results, want %d", len(got), len(want))
+	}
+	for cell, value := range want {
+		if got[cell] != value {
+			t.Fatalf("unexpected streamed %s value %g, want %g", cell, got[cell], value)
+		}
+	}
+
+	// 重算时批量 SUMIFS 流式读取数据源，结果与逐个单元格计算一致
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for i := range regions {
+		for cell := range formulas {
+			cell = fmt.Sprintf(cell, i+1)
+			value, _ := f.GetCellValue("Sheet1", cell)
+			expected, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil || value != expected {
+				t.Fatalf("unexpected %s value %q, want %q (%v)", cell, value, expected, err)
+			}
+		}
+	}
+}
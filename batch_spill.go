@@ -0,0 +1,198 @@
+package excelize
+
+import (
+	"strings"
+)
+
+// isDynamicArrayFormula reports whether the formula is a single call of a
+// dynamic array function like FILTER(...) or SORT(UNIQUE(...)), whose result
+// spills from the formula cell into the neighboring cells.
+func isDynamicArrayFormula(formula string) bool {
+	formula = strings.TrimPrefix(strings.TrimSpace(formula), "=")
+	name, _, ok := strings.Cut(formula, "(")
+	if !ok {
+		return false
+	}
+	upper := strings.ToUpper(name)
+	upper = strings.TrimPrefix(strings.TrimPrefix(upper, "_XLFN."), "_XLWS.")
+	if !dynamicArrayFuncs[upper] {
+		return false
+	}
+	return name+"("+extractFunctionCall(formula, name)+")" == formula
+}
+
+// calcSpillArray calculates the dynamic array returned by the formula of an
+// anchor cell. The values of the former levels are read from worksheetCache.
+// It returns the matrix of the array, or the value of the formula if it
+// doesn't spill, e.g. the #SPILL! error or a single value.
+func (f *File) calcSpillArray(sheet, cell string, worksheetCache *WorksheetCache) formulaArg {
+	spill := newEmptyFormulaArg()
+	ctx := &calcContext{
+		entry:             sheet + "!" + cell,
+		maxCalcIterations: 100,
+		iterations:        make(map[string]uint),
+		iterationsCache:   make(map[string]formulaArg),
+		worksheetCache:    worksheetCache,
+		spill:             &spill,
+	}
+	result, err := f.calcCellValue(ctx, sheet, cell)
+	if err != nil || spill.Type != ArgMatrix {
+		return result
+	}
+	return spill
+}
+
+// setSpill records the result of the formula of a dynamic array anchor cell
+// calculated by a level, the matrix of the array or the value of the formula
+// if it doesn't spill.
+func (wc *WorksheetCache) setSpill(sheet, cell string, arg formulaArg) {
+	if wc != nil {
+		wc.spills.Store(sheet+"!"+cell, arg)
+	}
+}
+
+// takeSpill returns and forgets the result of the formula of a dynamic array
+// anchor cell recorded by setSpill.
+func (wc *WorksheetCache) takeSpill(sheet, cell string) (formulaArg, bool) {
+	if wc == nil {
+		return newEmptyFormulaArg(), false
+	}
+	arg, ok := wc.spills.LoadAndDelete(sheet + "!" + cell)
+	if !ok {
+		return newEmptyFormulaArg(), false
+	}
+	return arg.(formulaArg), true
+}
+
+// updateSpillRanges updates the spill ranges of the dynamic arrays of the
+// anchor cells of a level, so the formulas of the later levels read the new
+// spilled values.
+func (f *File) updateSpillRanges(levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache) int {
+	if f.calcTuning.ShadowOutput {
+		return 0
+	}
+	updated := 0
+	for _, ref := range levelCells {
+		node, ok := graph.nodes[ref]
+		if !ok || !isDynamicArrayFormula(node.formula) {
+			continue
+		}
+		sheet, cell, ok := strings.Cut(ref, "!")
+		if !ok {
+			continue
+		}
+		if f.spillDynamicArray(sheet, cell, worksheetCache) {
+			updated++
+		}
+	}
+	return updated
+}
+
+// spillDynamicArray writes the dynamic array of an anchor cell into its spill
+// range and clears the cells the former spill range vacated, then stores the
+// new range as the ref of the anchor formula like Excel. All cells are
+// updated under one lock of the worksheet, so no reader sees a partially
+// updated spill range. A formula which doesn't spill, like the #SPILL! error,
+// clears the whole former spill range. It returns whether any cell changed.
+func (f *File) spillDynamicArray(sheet, cell string, worksheetCache *WorksheetCache) bool {
+	col, row, err := CellNameToCoordinates(cell)
+	if err != nil {
+		return false
+	}
+	// 层级计算时已记录动态数组，结果来自缓存时重新计算
+	arg, ok := worksheetCache.takeSpill(sheet, cell)
+	if !ok {
+		arg = f.calcSpillArray(sheet, cell, worksheetCache)
+	}
+	rows, cols := 1, 1
+	if arg.Type == ArgMatrix && len(arg.Matrix) > 0 && len(arg.Matrix[0]) > 0 {
+		rows, cols = len(arg.Matrix), len(arg.Matrix[0])
+	}
+	newRef := cell
+	if rows > 1 || cols > 1 {
+		lastCell, _ := CoordinatesToCellName(col+cols-1, row+rows-1)
+		newRef = cell + ":" + lastCell
+	}
+
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	changed := make(map[string][2]string) // 单元格 -> 原值和新值
+	ws.mu.Lock()
+	anchor, _, _, err := ws.prepareCell(cell)
+	if err != nil || anchor.F == nil {
+		ws.mu.Unlock()
+		return false
+	}
+	oldRange := []int{col, row, col, row}
+	if coordinates, err := rangeRefToCoordinates(anchor.F.Ref); err == nil && anchor.F.T == STCellFormulaTypeArray {
+		_ = sortCoordinates(coordinates)
+		oldRange = coordinates
+	}
+	// 清除原溢出区域中新区域以外的单元格
+	for c := oldRange[0]; c <= oldRange[2]; c++ {
+		for r := oldRange[1]; r <= oldRange[3]; r++ {
+			if (c == col && r == row) || (c < col+cols && r < row+rows && c >= col && r >= row) {
+				continue
+			}
+			// 只读查找，不为空单元格创建元素
+			target := f.getCellFromWorksheet(ws, c, r)
+			if target != nil && target.F == nil && (target.V != "" || target.IS != nil) {
+				name, _ := CoordinatesToCellName(c, r)
+				changed[name] = [2]string{target.V, ""}
+				target.V, target.T, target.IS = "", "", nil
+			}
+		}
+	}
+	// 写入新溢出区域的值，锚点的值由公式计算写入
+	for r := 0; r < rows && arg.Type == ArgMatrix; r++ {
+		for c := 0; c < cols; c++ {
+			if r == 0 && c == 0 {
+				continue
+			}
+			name, _ := CoordinatesToCellName(col+c, row+r)
+			target, _, _, err := ws.prepareCell(name)
+			if err != nil {
+				continue
+			}
+			value := arg.Matrix[r][c].Value()
+			if v := arg.Matrix[r][c]; v.Type == ArgNumber && !v.Boolean {
+				value = formatFloat(v.Number)
+			}
+			if target.V != value || target.IS != nil {
+				changed[name] = [2]string{target.V, value}
+			}
+			target.V, target.T, target.IS = value, inferXMLCellType(value), nil
+		}
+	}
+	if rows > 1 || cols > 1 || anchor.F.T == STCellFormulaTypeArray {
+		anchor.F.T, anchor.F.Ref = STCellFormulaTypeArray, newRef
+	}
+	ws.mu.Unlock()
+
+	if len(changed) == 0 {
+		return false
+	}
+	if rowsCache := f.sheetDataCache.Load(); rowsCache != nil {
+		rowsCache.Invalidate(sheet)
+	}
+	for name, values := range changed {
+		if values[1] == "" {
+			worksheetCache.Set(sheet, name, newEmptyFormulaArg())
+		} else {
+			worksheetCache.Set(sheet, name, inferFormulaResultType(values[1]))
+		}
+		key := sheet + "!" + name
+		f.calcCache.Delete(key)
+		f.calcCache.Delete(key + "!raw=true")
+		f.calcCache.Delete(key + "!raw=false")
+		if f.OnCellCalculated != nil {
+			f.OnCellCalculated(sheet, name, values[0], values[1])
+		}
+	}
+	f.logger().Debugf("  🔁 [Spill] %s!%s spills into %s, %d cells changed", sheet, cell, newRef, len(changed))
+	return true
+}
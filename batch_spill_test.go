@@ -0,0 +1,151 @@
+package excelize
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIsDynamicArrayFormula(t *testing.T) {
+	for formula, want := range map[string]bool{
+		"FILTER(A1:A6,C1:C6)":                   true,
+		"=_xlfn._xlws.FILTER(A1:A6,C1:C6)":      true,
+		"_xlfn._xlws.SORT(_xlfn.UNIQUE(A1:A6))": true,
+		"_xlfn.SEQUENCE(3)":                     true,
+		"FILTER(A1:A6,C1:C6)+1":                 false,
+		"SUM(FILTER(A1:A6,C1:C6))":              false,
+		"TRANSPOSE(A1:C1)":                      false,
+		"A1":                                    false,
+	} {
+		if got := isDynamicArrayFormula(formula); got != want {
+			t.Fatalf("isDynamicArrayFormula(%q) = %t, want %t", formula, got, want)
+		}
+	}
+}
+
+func TestRecalculateDynamicArraySpill(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for i, value := range []int{3, 8, 1, 9, 7, 2} {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", i+1), value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", i+1), fmt.Sprintf("A%d>$D$1", i+1)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 保存的溢出区域 B1:B3 及其中的值，与 Excel 保存的动态数组相同
+	formulaType, ref := STCellFormulaTypeArray, "B1:B3"
+	if err := f.SetCellFormula("Sheet1", "B1", "_xlfn._xlws.FILTER(A1:A6,C1:C6)", FormulaOpts{Type: &formulaType, Ref: &ref}); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	for cell, value := range map[string]int{"B2": 9, "B3": 7, "D1": 5} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "E1", "SUM(B1:B10)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	var notified []string
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) {
+		if cell[0] == 'B' {
+			notified = append(notified, cell+"="+newValue)
+		}
+	}
+
+	check := func(threshold float64, wantRef string, want ...string) {
+		t.Helper()
+		if err := f.SetCellValue("Sheet1", "D1", threshold); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		notified = nil
+		if err := f.RecalculateAllWithDependency(); err != nil {
+			t.Fatalf("recalculate: %v", err)
+		}
+		for row, value := range want {
+			if got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("B%d", row+1)); got != value {
+				t.Fatalf("unexpected B%d value %q with threshold %g, want %q", row+1, got, threshold, value)
+			}
+		}
+		ws, err := f.workSheetReader("Sheet1")
+		if err != nil {
+			t.Fatalf("read worksheet: %v", err)
+		}
+		if got := ws.SheetData.Row[0].C[1].F.Ref; got != wantRef {
+			t.Fatalf("unexpected spill range %q with threshold %g, want %q", got, threshold, wantRef)
+		}
+	}
+
+	check(5, "B1:B3", "8", "9", "7", "")
+	if got, _ := f.GetCellValue("Sheet1", "E1"); got != "24" {
+		t.Fatalf("unexpected E1 value %q", got)
+	}
+	// 溢出区域缩小时清除空出的单元格，后续层级的公式读取新值
+	check(7.5, "B1:B2", "8", "9", "", "")
+	if got, _ := f.GetCellValue("Sheet1", "E1"); got != "17" {
+		t.Fatalf("unexpected E1 value %q", got)
+	}
+	if fmt.Sprint(notified) != "[B3=]" {
+		t.Fatalf("unexpected notified cells %v", notified)
+	}
+	// 溢出区域扩大
+	check(2.5, "B1:B4", "3", "8", "9", "7", "")
+	if got, _ := f.GetCellValue("Sheet1", "E1"); got != "27" {
+		t.Fatalf("unexpected E1 value %q", got)
+	}
+	// 被值阻挡时为 #SPILL!，清除原溢出区域，阻挡的值保留
+	if err := f.SetCellValue("Sheet1", "B6", "x"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	check(0, "B1", formulaErrorSPILL, "", "", "", "", "x")
+	check(8.5, "B1", "9", "", "", "", "", "x")
+}
+
+func TestSpillDynamicArrayRecordedArray(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	// 保存的溢出区域 B1:B10 只有 B2 和 B3 有值
+	formulaType, ref := STCellFormulaTypeArray, "B1:B10"
+	if err := f.SetCellFormula("Sheet1", "B1", "_xlfn._xlws.SORT(A1:A3)", FormulaOpts{Type: &formulaType, Ref: &ref}); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	for cell, value := range map[string]int{"A1": 3, "A2": 1, "A3": 2, "B2": 2, "B3": 3} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+
+	// 层级计算锚点公式时记录动态数组
+	worksheetCache := NewWorksheetCache()
+	if _, err := f.evalFormulaString("Sheet1", "B1", "_xlfn._xlws.SORT(A1:A3)", worksheetCache, Options{RawCellValue: true}); err != nil {
+		t.Fatalf("evaluate: %v", err)
+	}
+	if arg, ok := worksheetCache.takeSpill("Sheet1", "B1"); !ok || arg.Type != ArgMatrix || len(arg.Matrix) != 3 {
+		t.Fatalf("expected the recorded array of SORT, got %+v (%t)", arg, ok)
+	}
+
+	// 更新溢出区域使用记录的动态数组，不重新计算公式
+	worksheetCache.setSpill("Sheet1", "B1", newMatrixFormulaArg([][]formulaArg{{newNumberFormulaArg(7)}, {newNumberFormulaArg(8)}}))
+	if !f.spillDynamicArray("Sheet1", "B1", worksheetCache) {
+		t.Fatal("expected the spill range to change")
+	}
+	if _, ok := worksheetCache.takeSpill("Sheet1", "B1"); ok {
+		t.Fatal("expected the recorded array to be taken")
+	}
+	for cell, want := range map[string]string{"B2": "8", "B3": ""} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, want)
+		}
+	}
+	ws, err := f.workSheetReader("Sheet1")
+	if err != nil {
+		t.Fatalf("read worksheet: %v", err)
+	}
+	if got := ws.SheetData.Row[0].C[1].F.Ref; got != "B1:B2" {
+		t.Fatalf("unexpected spill range %q, want %q", got, "B1:B2")
+	}
+	// 清除原溢出区域时不为空单元格创建元素
+	if len(ws.SheetData.Row) != 3 {
+		t.Fatalf("expected the rows of the empty cells not to be created, got %d rows", len(ws.SheetData.Row))
+	}
+}
@@ -2,8 +2,6 @@ package excelize
 
 import (
 	"fmt"
-	"log"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,7 +37,8 @@ func (f *File) getCellValueOrCalcCache(sheet, cell string, worksheetCache *Works
 // The criteria may be a cell reference (e.g. "B2", "$A$1") or a literal value
 // (e.g. `"-"`, `"abc"`, `123`). Quoted string literals are unquoted and returned
 // directly; numeric literals are returned as-is. Cell references are looked up
-// via getCellValueOrCalcCache.
+// via getCellValueOrCalcCache, on their own sheet for the cross-sheet
+// references like Config!B2.
 func (f *File) resolveCriteriaValue(sheet, criteria string, worksheetCache *WorksheetCache) string {
 	// Quoted string literal: "-", "abc", etc.
 	if len(criteria) >= 2 && criteria[0] == '"' && criteria[len(criteria)-1] == '"' {
@@ -51,7 +50,14 @@ func (f *File) resolveCriteriaValue(sheet, criteria string, worksheetCache *Work
 			return criteria
 		}
 	}
+	// Literal concatenated with a cell reference or a number: ">="&$F$1
+	if prefix, operand, ok := splitCriteriaConcat(criteria); ok {
+		return prefix + f.resolveCriteriaValue(sheet, strings.ReplaceAll(operand, "$", ""), worksheetCache)
+	}
 	// Cell reference: look up the value
+	if criteriaSheet, ref, ok := splitSheetReference(criteria); ok {
+		sheet, criteria = criteriaSheet, ref
+	}
 	return f.getCellValueOrCalcCache(sheet, criteria, worksheetCache)
 }
 
@@ -91,6 +97,23 @@ type sumifs1DFormula struct {
 	criteria1Cell string // e.g., "$A2"
 }
 
+// sumifsNDPattern represents a batch SUMIFS pattern with 3 or more criteria
+type sumifsNDPattern struct {
+	// Common ranges (same for all formulas)
+	sumRangeRef       string
+	criteriaRangeRefs []string
+
+	// Formula mapping: cell -> criteria cells in the order of criteriaRangeRefs
+	formulas map[string]*sumifsNDFormula
+}
+
+// sumifsNDFormula represents a single SUMIFS formula with 3 or more criteria
+type sumifsNDFormula struct {
+	cell          string
+	sheet         string
+	criteriaCells []string // e.g., $A2, C$1 or "Active"
+}
+
 // averageifs2DPattern represents a batch AVERAGEIFS pattern
 type averageifs2DPattern struct {
 	// Common ranges (same for all formulas)
@@ -297,7 +320,7 @@ func ExtractSUMIFSFromFormulaExport(formula string) string {
 
 // batchCalculateSUMIFSWithCache performs batch SUMIFS calculation using worksheetCache
 // This is the REAL solution - we modify batch calculation to use unified worksheetCache
-// Supports both 1D SUMIFS (1 criterion) and 2D SUMIFS (2 criteria)
+// Supports 1D SUMIFS (1 criterion), 2D SUMIFS (2 criteria) and SUMIFS with 3 or more criteria
 func (f *File) batchCalculateSUMIFSWithCache(formulas map[string]string, worksheetCache *WorksheetCache) map[string]string {
 	results := make(map[string]string)
 
@@ -306,6 +329,7 @@ func (f *File) batchCalculateSUMIFSWithCache(formulas map[string]string, workshe
 
 	// Group formulas by pattern (same logic as batchCalculateSUMIFS)
 	patterns2D := make(map[string]*sumifs2DPattern)
+	patternsND := make(map[string]*sumifsNDPattern)
 
 	for fullCell, formula := range formulas {
 		parts := strings.Split(fullCell, "!")
@@ -327,23 +351,50 @@ func (f *File) batchCalculateSUMIFSWithCache(formulas map[string]string, workshe
 					patterns2D[key].formulas[k] = v
 				}
 			}
+		} else if pattern := f.extractSUMIFSNDPattern(sheet, cell, formula); pattern != nil {
+			// 3 or more criteria
+			key := pattern.key()
+			if _, exists := patternsND[key]; !exists {
+				patternsND[key] = pattern
+			} else {
+				for k, v := range pattern.formulas {
+					patternsND[key].formulas[k] = v
+				}
+			}
 		} else {
 			// Not a 2D pattern, try 1D later
 			remaining[fullCell] = formula
 		}
 	}
 
-	// Calculate each 2D pattern using worksheetCache
+	// Calculate each 2D pattern using worksheetCache, row-shifted patterns over
+	// the same source share one scan
+	shared2D := make([]*sumifs2DPattern, 0, len(patterns2D))
 	for _, pattern := range patterns2D {
+		shared2D = append(shared2D, pattern)
+	}
+	for _, pattern := range mergeShiftedSUMIFSPatterns(f, shared2D) {
 		patternResults := f.calculateSUMIFS2DPatternWithCache(pattern, worksheetCache)
 		for cell, value := range patternResults {
 			results[cell] = fmt.Sprintf("%v", value)
 		}
 	}
 
+	// Calculate each pattern with 3 or more criteria
+	sharedND := make([]*sumifsNDPattern, 0, len(patternsND))
+	for _, pattern := range patternsND {
+		sharedND = append(sharedND, pattern)
+	}
+	for _, pattern := range mergeShiftedSUMIFSPatterns(f, sharedND) {
+		patternResults := f.calculateSUMIFSNDPatternWithCache(pattern, worksheetCache)
+		for cell, value := range patternResults {
+			results[cell] = fmt.Sprintf("%v", value)
+		}
+	}
+
 	// Now handle 1D SUMIFS
 	if len(remaining) > 0 {
-		patterns1D := f.groupSUMIFS1DByPattern(remaining)
+		patterns1D := mergeShiftedSUMIFSPatterns(f, f.groupSUMIFS1DByPattern(remaining))
 		for _, pattern := range patterns1D {
 			if len(pattern.formulas) >= 10 {
 				patternResults := f.calculateSUMIFS1DPatternWithCache(pattern, worksheetCache)
@@ -371,19 +422,69 @@ func (f *File) calculateSUMIFS1DPatternWithCache(pattern *sumifs1DPattern, works
 		return map[string]float64{}
 	}
 
-	// Read source data directly from file
-	rows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
+	// Build 1D result map: criteria1Value -> sum
+	_, span, spanOK := sumifsSourceKey(pattern.ranges())
+	resultMap, err := f.buildSUMIFS1DResultMap(sourceSheet, span, spanOK, sumCol, criteria1Col)
 	if err != nil {
 		return map[string]float64{}
 	}
 
-	// Build 1D result map: criteria1Value -> sum
-	sumColIdx, _ := ColumnNameToNumber(sumCol)
-	sumColIdx--
-	criteria1ColIdx, _ := ColumnNameToNumber(criteria1Col)
-	criteria1ColIdx--
+	// Fill results for all formulas, the formulas with the same criteria value share the sum
+	results := make(map[string]float64)
+	groups := newSUMIFSCriteriaGroups(func(criteria []sumifsCriterion) float64 {
+		return sumifs1DSum(resultMap, criteria[0])
+	})
+	for fullCell, info := range pattern.formulas {
+		criteria1Cell := strings.ReplaceAll(info.criteria1Cell, "$", "")
+
+		// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "-"）
+		c1 := f.sumifsCriteriaValue(info.sheet, criteria1Cell, worksheetCache)
+
+		// 比较运算符和通配符条件匹配 resultMap 的键，无法分类的条件逐个单元格计算
+		if sum, ok := groups.sum(c1); ok {
+			results[fullCell] = sum
+		}
+	}
+	f.logger().Debugf("  ⚡ [SUMIFS 1D Batch] %d formulas over %d distinct criteria", len(pattern.formulas), len(groups.groups))
+
+	return results
+}
 
+// buildSUMIFS1DResultMap builds the result map of a one-criteria SUMIFS, the
+// sums of the sum column by the values of the criteria column, over the rows
+// in span, or all rows if the span is unknown.
+func (f *File) buildSUMIFS1DResultMap(sourceSheet string, span [2]int, spanOK bool, sumCol, criteriaCol string) (map[string]float64, error) {
 	resultMap := make(map[string]float64)
+	if cols, ok := streamColumns(sumCol, criteriaCol); ok && spanOK && f.shouldStreamRows(sourceSheet) {
+		// 大表逐块读取行，不一次性解码整个工作表
+		rows, err := f.streamRawRows(sourceSheet, span, cols, cols[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		for chunk := range rows {
+			accumulateSUMIFS1D(resultMap, chunk, 0, 1)
+		}
+		return resultMap, nil
+	}
+	// Read source data directly from file
+	rows, err := f.getCachedRawRows(sourceSheet)
+	if err != nil {
+		return nil, err
+	}
+	sumColIdx, _ := ColumnNameToNumber(sumCol)
+	criteriaColIdx, _ := ColumnNameToNumber(criteriaCol)
+	rows = f.sumifsTypedRows(sourceSheet, rows, sumColIdx)
+	// 只扫描范围覆盖的行，整列引用扫描所有行
+	if spanOK {
+		rows = rowsInSpan(rows, span)
+	}
+	accumulateSUMIFS1D(resultMap, rows, sumColIdx-1, criteriaColIdx-1)
+	return resultMap, nil
+}
+
+// accumulateSUMIFS1D adds the sum values of the rows into the result map of a
+// one-criteria SUMIFS by their criteria values.
+func accumulateSUMIFS1D(resultMap map[string]float64, rows [][]string, sumColIdx, criteria1ColIdx int) {
 	for _, row := range rows {
 		if criteria1ColIdx >= len(row) || sumColIdx >= len(row) {
 			continue
@@ -398,72 +499,479 @@ func (f *File) calculateSUMIFS1DPatternWithCache(pattern *sumifs1DPattern, works
 			}
 		}
 	}
+}
 
-	// Fill results for all formulas
+// calculateSUMIFS2DPatternWithCache calculates SUMIFS using worksheetCache
+func (f *File) calculateSUMIFS2DPatternWithCache(pattern *sumifs2DPattern, worksheetCache *WorksheetCache) map[string]float64 {
+	sourceSheet := extractSheetName(pattern.sumRangeRef)
+	if sourceSheet == "" {
+		return map[string]float64{}
+	}
+
+	sumCol := extractColumnFromRange(pattern.sumRangeRef)
+	criteria1Col := extractColumnFromRange(pattern.criteriaRange1Ref)
+	criteria2Col := extractColumnFromRange(pattern.criteriaRange2Ref)
+
+	if sumCol == "" || criteria1Col == "" || criteria2Col == "" {
+		return map[string]float64{}
+	}
+	_, span, spanOK := sumifsSourceKey(pattern.ranges())
+	// 两个条件限定同一列的上下界，如日期区间，按区间扫描
+	if isSameSUMIFSColumn(pattern.criteriaRange1Ref, pattern.criteriaRange2Ref) {
+		resultMap, err := f.buildSUMIFS1DResultMap(sourceSheet, span, spanOK, sumCol, criteria1Col)
+		if err != nil {
+			return map[string]float64{}
+		}
+		window := newSUMIFSWindow(resultMap)
+		results := make(map[string]float64)
+		groups := newSUMIFSCriteriaGroups(func(criteria []sumifsCriterion) float64 {
+			return window.sum(criteria[0], criteria[1])
+		})
+		for fullCell, info := range pattern.formulas {
+			c1 := f.sumifsCriteriaValue(info.sheet, strings.ReplaceAll(info.criteria1Cell, "$", ""), worksheetCache)
+			c2 := f.sumifsCriteriaValue(info.sheet, strings.ReplaceAll(info.criteria2Cell, "$", ""), worksheetCache)
+			if sum, ok := groups.sum(c1, c2); ok {
+				results[fullCell] = sum
+			}
+		}
+		return results
+	}
+
+	// Build result map by scanning once
+	var resultMap map[string]map[string]float64
+	if cols, ok := streamColumns(sumCol, criteria1Col, criteria2Col); ok && spanOK && f.shouldStreamRows(sourceSheet) {
+		// 大表逐块读取行，由 worker 边读边扫描
+		rows, err := f.streamRawRows(sourceSheet, span, cols, cols[0], nil)
+		if err != nil {
+			return map[string]float64{}
+		}
+		resultMap = f.buildResultMapFromRowStream(rows, 0, 1, 2)
+	} else {
+		// 直接从文件读取原始数据
+		// 注意：worksheetCache 只存储计算结果，不存储原始数据
+		rows, err := f.getCachedRawRows(sourceSheet)
+		if err != nil {
+			return map[string]float64{}
+		}
+		sumColIdx, _ := ColumnNameToNumber(sumCol)
+		rows = f.sumifsTypedRows(sourceSheet, rows, sumColIdx)
+		// 只扫描范围覆盖的行，整列引用扫描所有行
+		if spanOK {
+			rows = rowsInSpan(rows, span)
+		}
+		resultMap = f.scanRowsAndBuildResultMap(sourceSheet, rows, sumCol, criteria1Col, criteria2Col)
+	}
+
+	// Fill results for all formulas, the formulas with the same criteria values share the sum
 	results := make(map[string]float64)
+	groups := newSUMIFSCriteriaGroups(func(criteria []sumifsCriterion) float64 {
+		return sumifs2DSum(resultMap, criteria[0], criteria[1])
+	})
 	for fullCell, info := range pattern.formulas {
 		criteria1Cell := strings.ReplaceAll(info.criteria1Cell, "$", "")
+		criteria2Cell := strings.ReplaceAll(info.criteria2Cell, "$", "")
 
 		// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "-"）
-		c1 := f.resolveCriteriaValue(info.sheet, criteria1Cell, worksheetCache)
+		c1 := f.sumifsCriteriaValue(info.sheet, criteria1Cell, worksheetCache)
+		c2 := f.sumifsCriteriaValue(info.sheet, criteria2Cell, worksheetCache)
 
-		if val, ok := resultMap[c1]; ok {
-			results[fullCell] = val
-		} else {
-			results[fullCell] = 0
+		// 比较运算符和通配符条件匹配 resultMap 的键，无法分类的条件逐个单元格计算
+		if sum, ok := groups.sum(c1, c2); ok {
+			results[fullCell] = sum
 		}
 	}
+	f.logger().Debugf("  ⚡ [SUMIFS 2D Batch] %d formulas over %d distinct criteria", len(pattern.formulas), len(groups.groups))
 
 	return results
 }
 
-// calculateSUMIFS2DPatternWithCache calculates SUMIFS using worksheetCache
-func (f *File) calculateSUMIFS2DPatternWithCache(pattern *sumifs2DPattern, worksheetCache *WorksheetCache) map[string]float64 {
+// extractSUMIFSNDPattern extracts the pattern from a SUMIFS formula with 3 or
+// more criteria, e.g. SUMIFS(data!$H:$H,data!$A:$A,$A2,data!$B:$B,C$1,data!$D:$D,"Active")
+func (f *File) extractSUMIFSNDPattern(sheet, cell, formula string) *sumifsNDPattern {
+	if len(formula) < 8 || formula[:7] != "SUMIFS(" {
+		return nil
+	}
+
+	parts := splitFormulaArgs(formula[7 : len(formula)-1])
+	if len(parts) < 7 || len(parts)%2 == 0 {
+		return nil
+	}
+
+	pattern := &sumifsNDPattern{
+		sumRangeRef: strings.TrimSpace(parts[0]),
+		formulas:    make(map[string]*sumifsNDFormula),
+	}
 	sourceSheet := extractSheetName(pattern.sumRangeRef)
 	if sourceSheet == "" {
-		return map[string]float64{}
+		return nil
 	}
 
-	sumCol := extractColumnFromRange(pattern.sumRangeRef)
-	criteria1Col := extractColumnFromRange(pattern.criteriaRange1Ref)
-	criteria2Col := extractColumnFromRange(pattern.criteriaRange2Ref)
+	criteriaCells := make([]string, 0, len(parts)/2)
+	for i := 1; i < len(parts); i += 2 {
+		criteriaRange := strings.TrimSpace(parts[i])
+		criteriaCell := strings.TrimSpace(parts[i+1])
+		// All ranges must be on the source sheet, criteria are cells or literals
+		if extractSheetName(criteriaRange) != sourceSheet || !isBatchCriteriaArg(criteriaCell) {
+			return nil
+		}
+		pattern.criteriaRangeRefs = append(pattern.criteriaRangeRefs, criteriaRange)
+		criteriaCells = append(criteriaCells, criteriaCell)
+	}
 
-	if sumCol == "" || criteria1Col == "" || criteria2Col == "" {
+	pattern.formulas[sheet+"!"+cell] = &sumifsNDFormula{
+		cell:          cell,
+		sheet:         sheet,
+		criteriaCells: criteriaCells,
+	}
+
+	return pattern
+}
+
+// key returns the grouping key of the pattern, it includes the sum range and
+// all criteria ranges so that patterns over different ranges never merge
+func (p *sumifsNDPattern) key() string {
+	return p.sumRangeRef + "|" + strings.Join(p.criteriaRangeRefs, "|")
+}
+
+// groupSUMIFSNDByPattern groups SUMIFS formulas with 3 or more criteria by their pattern
+func (f *File) groupSUMIFSNDByPattern(formulas map[string]string) []*sumifsNDPattern {
+	patterns := make(map[string]*sumifsNDPattern)
+
+	for fullCell, formula := range formulas {
+		parts := strings.Split(fullCell, "!")
+		if len(parts) != 2 {
+			continue
+		}
+
+		pattern := f.extractSUMIFSNDPattern(parts[0], parts[1], formula)
+		if pattern == nil {
+			continue
+		}
+
+		key := pattern.key()
+		if patterns[key] == nil {
+			patterns[key] = pattern
+		} else {
+			for c, info := range pattern.formulas {
+				patterns[key].formulas[c] = info
+			}
+		}
+	}
+
+	var result []*sumifsNDPattern
+	for _, p := range patterns {
+		result = append(result, p)
+	}
+	return result
+}
+
+// calculateSUMIFSNDPatternWithCache calculates SUMIFS with 3 or more criteria
+// using worksheetCache, the source rows are scanned once for the whole pattern
+func (f *File) calculateSUMIFSNDPatternWithCache(pattern *sumifsNDPattern, worksheetCache *WorksheetCache) map[string]float64 {
+	sourceSheet := extractSheetName(pattern.sumRangeRef)
+	if sourceSheet == "" {
 		return map[string]float64{}
 	}
 
-	// 直接从文件读取原始数据
-	// 注意：worksheetCache 只存储计算结果，不存储原始数据
-	rows, err := f.GetRows(sourceSheet, Options{RawCellValue: true})
-	if err != nil {
+	sumCol := extractColumnFromRange(pattern.sumRangeRef)
+	criteriaCols := make([]string, len(pattern.criteriaRangeRefs))
+	for i, ref := range pattern.criteriaRangeRefs {
+		if criteriaCols[i] = extractColumnFromRange(ref); criteriaCols[i] == "" {
+			return map[string]float64{}
+		}
+	}
+	if sumCol == "" {
 		return map[string]float64{}
 	}
 
 	// Build result map by scanning once
-	resultMap := f.scanRowsAndBuildResultMap(sourceSheet, rows, sumCol, criteria1Col, criteria2Col)
+	var resultMap map[string]float64
+	_, span, spanOK := sumifsSourceKey(pattern.ranges())
+	if cols, ok := streamColumns(append([]string{sumCol}, criteriaCols...)...); ok && spanOK && f.shouldStreamRows(sourceSheet) {
+		// 大表逐块读取行，并叠加 worksheetCache 中的计算结果
+		rows, err := f.streamRawRows(sourceSheet, span, cols, cols[0], worksheetCache.GetSheet(sourceSheet))
+		if err != nil {
+			return map[string]float64{}
+		}
+		criteriaColIdx := make([]int, len(criteriaCols))
+		for i := range criteriaColIdx {
+			criteriaColIdx[i] = i + 1
+		}
+		resultMap = make(map[string]float64)
+		for chunk := range rows {
+			accumulateSUMIFSND(resultMap, chunk, 0, criteriaColIdx)
+		}
+	} else {
+		rows, err := f.getCachedRawRows(sourceSheet)
+		if err != nil {
+			return map[string]float64{}
+		}
+		sumColIdx, _ := ColumnNameToNumber(sumCol)
+		rows = mergeSheetCacheIntoRows(f.sumifsTypedRows(sourceSheet, rows, sumColIdx), worksheetCache.GetSheet(sourceSheet))
+		// 只扫描范围覆盖的行，整列引用扫描所有行
+		if spanOK {
+			rows = rowsInSpan(rows, span)
+		}
+		resultMap = scanRowsAndBuildNDResultMap(rows, sumCol, criteriaCols)
+	}
 
-	// Fill results for all formulas
+	// Fill results for all formulas, the formulas with the same criteria values share the sum
 	results := make(map[string]float64)
+	groups := newSUMIFSCriteriaGroups(func(criteria []sumifsCriterion) float64 {
+		return sumifsNDSum(resultMap, criteria)
+	})
 	for fullCell, info := range pattern.formulas {
-		criteria1Cell := strings.ReplaceAll(info.criteria1Cell, "$", "")
-		criteria2Cell := strings.ReplaceAll(info.criteria2Cell, "$", "")
+		values := make([]string, len(info.criteriaCells))
+		for i, criteriaCell := range info.criteriaCells {
+			// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "Active"）
+			values[i] = f.sumifsCriteriaValue(info.sheet, strings.ReplaceAll(criteriaCell, "$", ""), worksheetCache)
+		}
+		if sum, ok := groups.sum(values...); ok {
+			results[fullCell] = sum
+		}
+	}
+	f.logger().Debugf("  ⚡ [SUMIFS ND Batch] %d formulas over %d distinct criteria", len(pattern.formulas), len(groups.groups))
 
-		// 解析 criteria 值：可能是单元格引用（如 B2）或字面量（如 "-"）
-		c1 := f.resolveCriteriaValue(info.sheet, criteria1Cell, worksheetCache)
-		c2 := f.resolveCriteriaValue(info.sheet, criteria2Cell, worksheetCache)
+	return results
+}
+
+// sumifsNDKey joins the criteria values of a row or a formula into a key of
+// the N-criteria result map
+func sumifsNDKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+// scanRowsAndBuildNDResultMap scans rows once and sums the sum column for each
+// combination of criteria values, rows with an empty criteria value are
+// skipped like the 2D scan does
+func scanRowsAndBuildNDResultMap(rows [][]string, sumCol string, criteriaCols []string) map[string]float64 {
+	sumColIdx, _ := ColumnNameToNumber(sumCol)
+	criteriaColIdx := make([]int, len(criteriaCols))
+	for i, col := range criteriaCols {
+		criteriaColIdx[i], _ = ColumnNameToNumber(col)
+		criteriaColIdx[i]--
+	}
+
+	resultMap := make(map[string]float64)
+	accumulateSUMIFSND(resultMap, rows, sumColIdx-1, criteriaColIdx)
+	return resultMap
+}
+
+// accumulateSUMIFSND adds the sum values of the rows into the result map of a
+// SUMIFS with 3 or more criteria by the combined key of their criteria values.
+func accumulateSUMIFSND(resultMap map[string]float64, rows [][]string, sumColIdx int, criteriaColIdx []int) {
+	values := make([]string, len(criteriaColIdx))
+	for _, row := range rows {
+		if sumColIdx >= len(row) || row[sumColIdx] == "" {
+			continue
+		}
+		matched := true
+		for i, idx := range criteriaColIdx {
+			if idx >= len(row) || row[idx] == "" {
+				matched = false
+				break
+			}
+			values[i] = row[idx]
+		}
+		if !matched {
+			continue
+		}
+		if num, err := strconv.ParseFloat(row[sumColIdx], 64); err == nil {
+			resultMap[sumifsNDKey(values)] += num
+		}
+	}
+}
+
+// sumifsSourceKey returns the key of the sheets and columns of the sum and
+// criteria ranges of a SUMIFS without their rows, along with the row span
+// shared by the ranges. SUMIFS over ranges shifted by a constant number of
+// rows, like Data!C2:C1000 and Data!C3:C1001, have the same key. It returns
+// false if a range is not a single column or the ranges cover different rows.
+func sumifsSourceKey(sumRange string, criteriaRanges []string) (string, [2]int, bool) {
+	var key strings.Builder
+	var span [2]int
+	for i, ref := range append([]string{sumRange}, criteriaRanges...) {
+		table, ok := parseLookupTable(ref, "")
+		if !ok || table.sheet == "" || table.startCol != table.endCol {
+			return "", span, false
+		}
+		if i == 0 {
+			span = [2]int{table.startRow, table.endRow}
+		} else if span != [2]int{table.startRow, table.endRow} {
+			return "", span, false
+		}
+		fmt.Fprintf(&key, "%s!%d|", table.sheet, table.startCol)
+	}
+	return key.String(), span, true
+}
+
+// shiftedSUMIFSScanSpans returns the rows to scan for each row span of the
+// SUMIFS over the same source. A span shares the scan over the union of all
+// spans if the rows of the union outside the span have no number in the sum
+// column, as these rows add nothing to any sum, otherwise it is scanned on
+// its own. The union of the spans is returned as well.
+func shiftedSUMIFSScanSpans(rows [][]string, sumColIdx int, spans map[[2]int]bool) (map[[2]int][2]int, [2]int) {
+	union := [2]int{TotalRows, 1}
+	for span := range spans {
+		union = [2]int{min(union[0], span[0]), max(union[1], span[1])}
+	}
+	hasNumber := func(from, to int) bool {
+		for r := from; r <= to && r <= len(rows); r++ {
+			if sumColIdx >= len(rows[r-1]) || rows[r-1][sumColIdx] == "" {
+				continue
+			}
+			var num float64
+			if _, err := fmt.Sscanf(rows[r-1][sumColIdx], "%f", &num); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	scanSpans := make(map[[2]int][2]int, len(spans))
+	for span := range spans {
+		scanSpans[span] = union
+		if hasNumber(union[0], span[0]-1) || hasNumber(span[1]+1, union[1]) {
+			scanSpans[span] = span
+		}
+	}
+	return scanSpans, union
+}
+
+// shiftableSUMIFSPattern is a batch SUMIFS pattern which can be merged with
+// the patterns over row-shifted ranges of the same source
+type shiftableSUMIFSPattern interface {
+	ranges() (string, []string)
+	mergeFormulas(other shiftableSUMIFSPattern)
+	setRowSpan(span [2]int)
+}
+
+func (p *sumifs1DPattern) ranges() (string, []string) {
+	return p.sumRangeRef, []string{p.criteriaRange1Ref}
+}
+
+func (p *sumifs1DPattern) mergeFormulas(other shiftableSUMIFSPattern) {
+	for cell, info := range other.(*sumifs1DPattern).formulas {
+		p.formulas[cell] = info
+	}
+}
+
+func (p *sumifs1DPattern) setRowSpan(span [2]int) {
+	p.sumRangeRef = rangeWithRowSpan(p.sumRangeRef, span)
+	p.criteriaRange1Ref = rangeWithRowSpan(p.criteriaRange1Ref, span)
+}
 
-		if resultMap[c1] != nil {
-			if val, ok := resultMap[c1][c2]; ok {
-				results[fullCell] = val
+func (p *sumifs2DPattern) ranges() (string, []string) {
+	return p.sumRangeRef, []string{p.criteriaRange1Ref, p.criteriaRange2Ref}
+}
+
+func (p *sumifs2DPattern) mergeFormulas(other shiftableSUMIFSPattern) {
+	for cell, info := range other.(*sumifs2DPattern).formulas {
+		p.formulas[cell] = info
+	}
+}
+
+func (p *sumifs2DPattern) setRowSpan(span [2]int) {
+	p.sumRangeRef = rangeWithRowSpan(p.sumRangeRef, span)
+	p.criteriaRange1Ref = rangeWithRowSpan(p.criteriaRange1Ref, span)
+	p.criteriaRange2Ref = rangeWithRowSpan(p.criteriaRange2Ref, span)
+}
+
+func (p *sumifsNDPattern) ranges() (string, []string) {
+	return p.sumRangeRef, p.criteriaRangeRefs
+}
+
+func (p *sumifsNDPattern) mergeFormulas(other shiftableSUMIFSPattern) {
+	for cell, info := range other.(*sumifsNDPattern).formulas {
+		p.formulas[cell] = info
+	}
+}
+
+func (p *sumifsNDPattern) setRowSpan(span [2]int) {
+	p.sumRangeRef = rangeWithRowSpan(p.sumRangeRef, span)
+	refs := make([]string, len(p.criteriaRangeRefs))
+	for i, ref := range p.criteriaRangeRefs {
+		refs[i] = rangeWithRowSpan(ref, span)
+	}
+	p.criteriaRangeRefs = refs
+}
+
+// rangeWithRowSpan returns the single column range reference with the rows of
+// the span, e.g. data!$C$2:$C$100 with span [2, 101] gives data!$C$2:$C$101
+func rangeWithRowSpan(ref string, span [2]int) string {
+	col := extractColumnFromRange(ref)
+	return fmt.Sprintf("%s$%s$%d:$%s$%d", ref[:strings.LastIndex(ref, "!")+1], col, span[0], col, span[1])
+}
+
+// mergeShiftedSUMIFSPatterns merges the patterns over ranges of the same
+// source shifted by a constant number of rows, if the shift does not affect
+// the matched rows, so that the source is scanned once over the union of
+// their rows. The other patterns are returned unchanged.
+func mergeShiftedSUMIFSPatterns[P shiftableSUMIFSPattern](f *File, patterns []P) []P {
+	type shifted struct {
+		span    [2]int
+		pattern P
+	}
+	sources := make(map[string][]shifted)
+	var sourceKeys []string
+	result := make([]P, 0, len(patterns))
+	for _, pattern := range patterns {
+		sumRange, criteriaRanges := pattern.ranges()
+		key, span, ok := sumifsSourceKey(sumRange, criteriaRanges)
+		if !ok {
+			result = append(result, pattern)
+			continue
+		}
+		if _, exists := sources[key]; !exists {
+			sourceKeys = append(sourceKeys, key)
+		}
+		sources[key] = append(sources[key], shifted{span: span, pattern: pattern})
+	}
+	for _, key := range sourceKeys {
+		group := sources[key]
+		sumRange, _ := group[0].pattern.ranges()
+		rows, err := f.getCachedRawRows(extractSheetName(sumRange))
+		if len(group) == 1 || err != nil {
+			for _, s := range group {
+				result = append(result, s.pattern)
+			}
+			continue
+		}
+		spans := make(map[[2]int]bool, len(group))
+		for _, s := range group {
+			spans[s.span] = true
+		}
+		sumColIdx, _ := ColumnNameToNumber(extractColumnFromRange(sumRange))
+		scanSpans, union := shiftedSUMIFSScanSpans(rows, sumColIdx-1, spans)
+		var shared P
+		sharedCount := 0
+		for _, s := range group {
+			if scanSpans[s.span] != union {
+				result = append(result, s.pattern)
+				continue
+			}
+			if sharedCount == 0 {
+				shared = s.pattern
+				shared.setRowSpan(union)
+				result = append(result, shared)
 			} else {
-				results[fullCell] = 0
+				shared.mergeFormulas(s.pattern)
 			}
-		} else {
-			results[fullCell] = 0
+			sharedCount++
+		}
+		if sharedCount > 1 {
+			sharedRange, _ := shared.ranges()
+			f.logger().Debugf("  ⚡ [SUMIFS Shifted] Merged %d row-shifted patterns over %s", sharedCount, sharedRange)
 		}
 	}
+	return result
+}
 
-	return results
+// rowsInSpan returns the rows of a 1-based inclusive row span
+func rowsInSpan(rows [][]string, span [2]int) [][]string {
+	if span[0] > len(rows) {
+		return nil
+	}
+	return rows[span[0]-1 : min(span[1], len(rows))]
 }
 
 // TestExtractSUMIFS2DPattern is exported for testing
@@ -486,7 +994,8 @@ type Sumifs2DPatternExport struct {
 	CriteriaRange2Ref string
 }
 
-// groupSUMIFS1DByPattern groups SUMIFS formulas with 1 criterion by their pattern
+// groupSUMIFS1DByPattern groups SUMIFS formulas with 1 criterion and SUMIF
+// formulas by their pattern
 func (f *File) groupSUMIFS1DByPattern(formulas map[string]string) []*sumifs1DPattern {
 	patterns := make(map[string]*sumifs1DPattern)
 
@@ -498,8 +1007,11 @@ func (f *File) groupSUMIFS1DByPattern(formulas map[string]string) []*sumifs1DPat
 		}
 		sheet, cell := parts[0], parts[1]
 
-		// Extract 1D pattern (1 criterion)
+		// Extract 1D pattern (1 criterion), SUMIF maps onto the same pattern
 		pattern := f.extractSUMIFS1DPattern(sheet, cell, formula)
+		if pattern == nil {
+			pattern = f.extractSUMIFPattern(sheet, cell, formula)
+		}
 		if pattern == nil {
 			continue
 		}
@@ -553,8 +1065,8 @@ func (f *File) extractSUMIFS1DPattern(sheet, cell, formula string) *sumifs1DPatt
 		return nil
 	}
 
-	// Check if criteria is a cell reference (not external)
-	if strings.Contains(criteria1Cell, "!") {
+	// Check if criteria is a cell reference or a single cell of another sheet
+	if !isBatchCriteriaArg(criteria1Cell) {
 		return nil
 	}
 
@@ -573,6 +1085,83 @@ func (f *File) extractSUMIFS1DPattern(sheet, cell, formula string) *sumifs1DPatt
 	return pattern
 }
 
+// extractSUMIFPattern extracts 1D pattern from SUMIF formula, whose sum range
+// is the optional third argument: SUMIF(range, criteria, sum_range) sums
+// sum_range like SUMIFS(sum_range, range, criteria), and SUMIF(range,
+// criteria) sums the numeric cells of the criteria range itself, the text
+// numbers like "5" match the criteria but are not summed. The ranges without
+// a sheet name belong to the sheet of the formula. The sum range must span
+// the same rows as the criteria range, otherwise Excel resizes it from its
+// first cell.
+func (f *File) extractSUMIFPattern(sheet, cell, formula string) *sumifs1DPattern {
+	if len(formula) < 7 || formula[:6] != "SUMIF(" {
+		return nil
+	}
+
+	parts := splitFormulaArgs(formula[6 : len(formula)-1])
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil
+	}
+
+	criteriaRange := strings.TrimSpace(parts[0])
+	criteriaCell := strings.TrimSpace(parts[1])
+	sumRange := criteriaRange
+	if len(parts) == 3 {
+		sumRange = strings.TrimSpace(parts[2])
+	}
+	// 省略工作表名称的范围属于公式所在的工作表，如 SUMIF(A:A,">0")
+	if !strings.Contains(criteriaRange, "!") {
+		criteriaRange = escapeSheetName(sheet) + "!" + criteriaRange
+	}
+	if !strings.Contains(sumRange, "!") {
+		sumRange = escapeSheetName(sheet) + "!" + sumRange
+	}
+
+	if !isBatchCriteriaArg(criteriaCell) {
+		return nil
+	}
+	if sumifRangeRows(sumRange) != sumifRangeRows(criteriaRange) {
+		return nil
+	}
+
+	pattern := &sumifs1DPattern{
+		sumRangeRef:       sumRange,
+		criteriaRange1Ref: criteriaRange,
+		formulas:          make(map[string]*sumifs1DFormula),
+	}
+
+	pattern.formulas[sheet+"!"+cell] = &sumifs1DFormula{
+		cell:          cell,
+		sheet:         sheet,
+		criteria1Cell: criteriaCell,
+	}
+
+	return pattern
+}
+
+// sumifRangeRows returns the rows of a range reference without the sheet and
+// the columns, e.g. data!$C$2:$C$100 -> 2:100 and data!$C:$C -> ":"
+func sumifRangeRows(rangeRef string) string {
+	_, ref, _ := splitSheetReference(rangeRef)
+	return strings.Map(func(r rune) rune {
+		if r == '$' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+			return -1
+		}
+		return r
+	}, ref)
+}
+
+// isPureSUMIFFormula reports whether the entire formula is a SUMIF batched
+// as a 1D SUMIFS pattern.
+func isPureSUMIFFormula(formula string) bool {
+	formula = strings.TrimSpace(strings.TrimPrefix(formula, "="))
+	if !strings.HasPrefix(formula, "SUMIF(") {
+		return false
+	}
+	args := extractFunctionCall(formula, "SUMIF")
+	return args != "" && len(args)+len("SUMIF()") == len(formula)
+}
+
 // calculateSUMIFS1DPattern calculates a batch of SUMIFS formulas with 1 criterion
 func (f *File) calculateSUMIFS1DPattern(pattern *sumifs1DPattern) map[string]float64 {
 	// Extract sheet from range reference
@@ -590,7 +1179,7 @@ func (f *File) calculateSUMIFS1DPattern(pattern *sumifs1DPattern) map[string]flo
 	}
 
 	// Read all rows from the source sheet
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return map[string]float64{}
 	}
@@ -605,13 +1194,13 @@ func (f *File) calculateSUMIFS1DPattern(pattern *sumifs1DPattern) map[string]flo
 		criteria1Cell := strings.ReplaceAll(info.criteria1Cell, "$", "")
 
 		// Note: This function doesn't have worksheetCache, so use direct GetCellValue as fallback
-		c1, _ := f.GetCellValue(info.sheet, criteria1Cell)
+		c1 := f.formattedCriteriaValue(info.sheet, criteria1Cell)
 
-		if val, ok := resultMap[c1]; ok {
-			results[fullCell] = val
-		} else {
-			results[fullCell] = 0
+		criteria, ok := parseSUMIFSCriteria(c1)
+		if !ok {
+			continue
 		}
+		results[fullCell] = sumifs1DSum(resultMap, criteria[0])
 	}
 
 	return results
@@ -635,7 +1224,7 @@ func (f *File) scanRowsAndBuild1DResultMap(
 	sumColIdx--       // Convert to 0-based
 	criteria1ColIdx-- // Convert to 0-based
 
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	rowCount := len(rows)
 
 	if numWorkers > rowCount {
@@ -787,11 +1376,9 @@ func (f *File) extractSUMIFS2DPattern(sheet, cell, formula string) *sumifs2DPatt
 		return nil
 	}
 
-	// Check if criteria are cell references (not external)
-	if strings.Contains(criteria1Cell, "!") {
-		return nil
-	}
-	if strings.Contains(criteria2Cell, "!") {
+	// Check if criteria are cell references or single cells of other sheets,
+	// like Config!$B$2, resolved per formula
+	if !isBatchCriteriaArg(criteria1Cell) || !isBatchCriteriaArg(criteria2Cell) {
 		return nil
 	}
 
@@ -875,7 +1462,7 @@ func (f *File) calculateSUMIFS2DPattern(pattern *sumifs2DPattern) map[string]flo
 	}
 
 	// Read all rows from the source sheet
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return map[string]float64{} // Return empty map instead of nil
 	}
@@ -891,18 +1478,14 @@ func (f *File) calculateSUMIFS2DPattern(pattern *sumifs2DPattern) map[string]flo
 		criteria2Cell := strings.ReplaceAll(info.criteria2Cell, "$", "")
 
 		// Note: This function doesn't have worksheetCache, so use direct GetCellValue as fallback
-		c1, _ := f.GetCellValue(info.sheet, criteria1Cell)
-		c2, _ := f.GetCellValue(info.sheet, criteria2Cell)
+		c1 := f.formattedCriteriaValue(info.sheet, criteria1Cell)
+		c2 := f.formattedCriteriaValue(info.sheet, criteria2Cell)
 
-		if resultMap[c1] != nil {
-			if val, ok := resultMap[c1][c2]; ok {
-				results[fullCell] = val
-			} else {
-				results[fullCell] = 0 // Add zero result
-			}
-		} else {
-			results[fullCell] = 0 // Add zero result
+		criteria, ok := parseSUMIFSCriteria(c1, c2)
+		if !ok {
+			continue
 		}
+		results[fullCell] = sumifs2DSum(resultMap, criteria[0], criteria[1])
 	}
 
 	return results
@@ -911,29 +1494,46 @@ func (f *File) calculateSUMIFS2DPattern(pattern *sumifs2DPattern) map[string]flo
 // extractSheetName extracts sheet name from range reference
 // e.g., 'sheet'!$H:$H -> sheet
 func extractSheetName(rangeRef string) string {
-	parts := strings.Split(rangeRef, "!")
-	if len(parts) != 2 {
-		return ""
-	}
-	return strings.Trim(parts[0], "'")
+	sheet, _, _ := splitSheetReference(rangeRef)
+	return sheet
 }
 
 // extractColumnFromRange extracts column letter from range reference
-// e.g., 'sheet'!$H:$H -> H
+// e.g., 'sheet'!$H:$H -> H, returns the first column of a multi-column range
+// and an empty string for a whole row range like sheet!$1:$1
 func extractColumnFromRange(rangeRef string) string {
-	parts := strings.Split(rangeRef, "!")
-	if len(parts) != 2 {
-		return ""
-	}
+	startCol, _, _ := extractColumnRangeFromRange(rangeRef)
+	return startCol
+}
 
-	ref := parts[1]
-	// Remove $ and :$H part
-	ref = strings.ReplaceAll(ref, "$", "")
-	if idx := strings.Index(ref, ":"); idx != -1 {
-		ref = ref[:idx]
+// extractColumnRangeFromRange extracts the first and last column letters from
+// range reference, e.g., 'sheet'!$C:$O -> C, O and sheet!H2:H100 -> H, H. It
+// returns false for the references without sheet name and the whole row
+// ranges like sheet!$1:$1.
+func extractColumnRangeFromRange(rangeRef string) (string, string, bool) {
+	_, ref, ok := splitSheetReference(rangeRef)
+	if !ok {
+		return "", "", false
+	}
+	start, end, isRange := strings.Cut(strings.ReplaceAll(ref, "$", ""), ":")
+	if !isRange {
+		end = start
+	}
+	// Remove the row number of a bounded range like H2:H100
+	startCol := strings.TrimRight(start, "0123456789")
+	endCol := strings.TrimRight(end, "0123456789")
+	startColIdx, err := ColumnNameToNumber(startCol)
+	if err != nil {
+		return "", "", false
 	}
-
-	return ref
+	endColIdx, err := ColumnNameToNumber(endCol)
+	if err != nil {
+		return "", "", false
+	}
+	if startColIdx > endColIdx {
+		startCol, endCol = endCol, startCol
+	}
+	return startCol, endCol, true
 }
 
 // scanRowsAndBuildResultMap scans rows and builds result map concurrently
@@ -952,96 +1552,44 @@ func (f *File) scanRowsAndBuildResultMap(
 	criteria1ColIdx, _ := ColumnNameToNumber(criteria1Col)
 	criteria2ColIdx, _ := ColumnNameToNumber(criteria2Col)
 
-	sumColIdx--       // Convert to 0-based
-	criteria1ColIdx-- // Convert to 0-based
-	criteria2ColIdx-- // Convert to 0-based
-
-	numWorkers := runtime.NumCPU()
-	rowCount := len(rows)
-
-	if numWorkers > rowCount {
-		numWorkers = rowCount
-	}
-	if numWorkers < 1 {
-		numWorkers = 1
-	}
-
-	rowsPerWorker := (rowCount + numWorkers - 1) / numWorkers
-
-	// Worker results
-	type workerResult struct {
-		data map[string]map[string]float64
-	}
-	results := make([]workerResult, numWorkers)
-	var wg sync.WaitGroup
-
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-
-			start := workerID * rowsPerWorker
-			end := start + rowsPerWorker
-			if end > rowCount {
-				end = rowCount
-			}
-
-			localMap := make(map[string]map[string]float64)
-
-			for rowIdx := start; rowIdx < end; rowIdx++ {
-				row := rows[rowIdx]
-
-				// Extract values from columns
-				var c1, c2, sumVal string
-
-				if criteria1ColIdx < len(row) {
-					c1 = row[criteria1ColIdx]
-				}
-				if criteria2ColIdx < len(row) {
-					c2 = row[criteria2ColIdx]
-				}
-				if sumColIdx < len(row) {
-					sumVal = row[sumColIdx]
-				}
-
-				if c1 == "" || c2 == "" || sumVal == "" {
-					continue
-				}
+	// 已解码的行同样分块送入通道，由 worker 并发消费
+	return f.buildResultMapFromRowStream(sliceRowStream(rows), sumColIdx-1, criteria1ColIdx-1, criteria2ColIdx-1)
+}
 
-				// Convert sumVal to number
-				var num float64
-				_, err := fmt.Sscanf(sumVal, "%f", &num)
-				if err != nil {
-					continue
-				}
+// accumulateSUMIFS2D adds the sum values of the rows into the result map of a
+// two-criteria SUMIFS by their criteria values.
+func accumulateSUMIFS2D(resultMap map[string]map[string]float64, rows [][]string, sumColIdx, criteria1ColIdx, criteria2ColIdx int) {
+	for _, row := range rows {
+		// Extract values from columns
+		var c1, c2, sumVal string
 
-				// Accumulate
-				if localMap[c1] == nil {
-					localMap[c1] = make(map[string]float64)
-				}
-				localMap[c1][c2] += num
-			}
+		if criteria1ColIdx < len(row) {
+			c1 = row[criteria1ColIdx]
+		}
+		if criteria2ColIdx < len(row) {
+			c2 = row[criteria2ColIdx]
+		}
+		if sumColIdx < len(row) {
+			sumVal = row[sumColIdx]
+		}
 
-			results[workerID] = workerResult{data: localMap}
-		}(i)
-	}
+		if c1 == "" || c2 == "" || sumVal == "" {
+			continue
+		}
 
-	wg.Wait()
+		// Convert sumVal to number
+		var num float64
+		_, err := fmt.Sscanf(sumVal, "%f", &num)
+		if err != nil {
+			continue
+		}
 
-	// Merge results
-	finalMap := make(map[string]map[string]float64)
-	for _, r := range results {
-		for c1, m := range r.data {
-			if finalMap[c1] == nil {
-				finalMap[c1] = make(map[string]float64)
-			}
-			for c2, sum := range m {
-				finalMap[c1][c2] += sum
-			}
+		// Accumulate
+		if resultMap[c1] == nil {
+			resultMap[c1] = make(map[string]float64)
 		}
+		resultMap[c1][c2] += num
 	}
-
-	return finalMap
 }
 
 // detectAndCalculateBatchINDEX detects and batch calculates INDEX formulas
@@ -1132,7 +1680,7 @@ func (f *File) calculateIndexFormulas(sheet string, formulas map[string]string)
 	}
 
 	if calculated > 0 {
-		log.Printf("  ⚡ [INDEX Batch] Calculated %d INDEX formulas in sheet '%s'", calculated, sheet)
+		f.logger().Debugf("  ⚡ [INDEX Batch] Calculated %d INDEX formulas in sheet '%s'", calculated, sheet)
 	}
 
 	return results
@@ -1251,7 +1799,7 @@ func (f *File) calculateAVERAGEIFS2DPattern(pattern *averageifs2DPattern) map[st
 	}
 
 	// Read all rows from the source sheet
-	rows, err := f.GetRows(sourceSheet)
+	rows, err := f.getUsedRows(sourceSheet)
 	if err != nil || len(rows) == 0 {
 		return map[string]float64{}
 	}
@@ -1267,21 +1815,16 @@ func (f *File) calculateAVERAGEIFS2DPattern(pattern *averageifs2DPattern) map[st
 		criteria2Cell := strings.ReplaceAll(info.criteria2Cell, "$", "")
 
 		// Note: This function doesn't have worksheetCache, so use direct GetCellValue as fallback
-		c1, _ := f.GetCellValue(info.sheet, criteria1Cell)
-		c2, _ := f.GetCellValue(info.sheet, criteria2Cell)
-
-		if resultMap[c1] != nil {
-			if avgData, ok := resultMap[c1][c2]; ok {
-				if avgData.count > 0 {
-					results[fullCell] = avgData.sum / float64(avgData.count)
-				} else {
-					results[fullCell] = 0
-				}
-			} else {
-				results[fullCell] = 0
-			}
-		} else {
-			results[fullCell] = 0
+		c1 := f.formattedCriteriaValue(info.sheet, criteria1Cell)
+		c2 := f.formattedCriteriaValue(info.sheet, criteria2Cell)
+
+		criteria, ok := parseSUMIFSCriteria(c1, c2)
+		if !ok {
+			continue
+		}
+		// 没有匹配的数值时逐个单元格计算，与 Excel 一致返回 #DIV/0!
+		if data := averageifs2DData(resultMap, criteria[0], criteria[1]); data.count > 0 {
+			results[fullCell] = data.sum / float64(data.count)
 		}
 	}
 
@@ -1294,6 +1837,30 @@ type avgData struct {
 	count int
 }
 
+// averageifs2DData returns the sum and the count of the numeric values of the
+// 2-criteria average map matching the criteria.
+func averageifs2DData(resultMap map[string]map[string]*avgData, criterion1, criterion2 sumifsCriterion) avgData {
+	var total avgData
+	if criterion1.plain && criterion2.plain {
+		if data := resultMap[criterion1.value][criterion2.value]; data != nil {
+			total = *data
+		}
+		return total
+	}
+	for key1, values := range resultMap {
+		if !criterion1.match(key1) {
+			continue
+		}
+		for key2, data := range values {
+			if criterion2.match(key2) {
+				total.sum += data.sum
+				total.count += data.count
+			}
+		}
+	}
+	return total
+}
+
 // scanRowsAndBuildAverageMap scans rows and builds average map concurrently
 func (f *File) scanRowsAndBuildAverageMap(
 	sheet string,
@@ -1314,7 +1881,7 @@ func (f *File) scanRowsAndBuildAverageMap(
 	criteria1ColIdx-- // Convert to 0-based
 	criteria2ColIdx-- // Convert to 0-based
 
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	rowCount := len(rows)
 
 	if numWorkers > rowCount {
@@ -1367,14 +1934,8 @@ func (f *File) scanRowsAndBuildAverageMap(
 					continue
 				}
 
-				// Skip if value is empty or "<>断货" pattern
-				if avgVal == "" || avgVal == "断货" {
-					continue
-				}
-
-				// Convert avgVal to number
-				var num float64
-				_, err := fmt.Sscanf(avgVal, "%f", &num)
+				// 与 Excel 一致，空值和文本等非数值不参与平均
+				num, err := strconv.ParseFloat(avgVal, 64)
 				if err != nil {
 					continue
 				}
@@ -0,0 +1,462 @@
+package excelize
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sumifsCriterion is a criterion of a batch SUMIFS formula matched against the
+// criteria values of the source rows, which are the keys of the result maps.
+// A plain value is looked up in the result map directly, a comparison or
+// wildcard criterion is matched against each key like Excel does: the
+// wildcards match the whole value case-insensitively, the numeric comparisons
+// only match numbers and the text comparisons only match text, compared
+// case-insensitively. The keys don't keep the cell types, so a number stored
+// as text is compared as a number, but the logical values are kept as TRUE
+// and FALSE, which only match the TRUE and FALSE criteria like Excel.
+type sumifsCriterion struct {
+	value    string         // 普通值，直接查找
+	plain    bool           // 是否为普通值
+	operator string         // 比较运算符：<、<=、>、>=、<>
+	number   float64        // 数值比较的操作数
+	numeric  bool           // 是否为数值比较
+	pattern  *regexp.Regexp // 通配符 * 和 ? 的匹配模式
+}
+
+// parseSUMIFSCriterion parses a resolved criterion value of a batch SUMIFS
+// formula. It returns false if the criterion may match the blank cells, like
+// "", "<>x" or "=", which the scans of the source rows skip, then the formula
+// should be calculated cell by cell.
+func parseSUMIFSCriterion(value string) (sumifsCriterion, bool) {
+	operator, operand := "", value
+	for _, op := range []string{"<=", ">=", "<>", "<", ">", "="} {
+		if strings.HasPrefix(value, op) {
+			operator, operand = op, value[len(op):]
+			break
+		}
+	}
+	switch operator {
+	case "":
+		// "" 匹配的空白单元格和空字符串由 CalcTuning.BlankEqualsEmptyString 决定
+		if operand == "" {
+			return sumifsCriterion{}, false
+		}
+		if hasSUMIFSWildcard(operand) {
+			return sumifsCriterion{pattern: sumifsWildcardPattern(operand)}, true
+		}
+		return sumifsCriterion{value: value, plain: true}, true
+	case "=":
+		if operand == "" {
+			return sumifsCriterion{}, false
+		}
+		if hasSUMIFSWildcard(operand) {
+			return sumifsCriterion{pattern: sumifsWildcardPattern(operand)}, true
+		}
+		return sumifsCriterion{value: operand, plain: true}, true
+	case "<>":
+		// 空操作数匹配所有非空单元格，其他情况会匹配空白单元格
+		if operand != "" {
+			return sumifsCriterion{}, false
+		}
+		return sumifsCriterion{operator: operator}, true
+	}
+	criterion := sumifsCriterion{operator: operator, value: strings.ToLower(operand)}
+	if num, ok := parseSUMIFSNumber(operand); ok {
+		criterion.number, criterion.numeric = num, true
+	}
+	return criterion, true
+}
+
+// hasSUMIFSWildcard returns if the criterion contains the wildcards or the
+// escaped characters, which are matched by a pattern rather than looked up.
+func hasSUMIFSWildcard(value string) bool {
+	return strings.ContainsAny(value, "*?") || strings.Contains(value, "~~")
+}
+
+// sumifsWildcardPattern converts a wildcard criterion to a case-insensitive
+// regular expression matching the whole value, the tilde escapes the next
+// wildcard or tilde.
+func sumifsWildcardPattern(value string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '~' && i+1 < len(value) && strings.IndexByte("*?~", value[i+1]) != -1:
+			i++
+			expr.WriteString(regexp.QuoteMeta(value[i : i+1]))
+		case c == '*':
+			expr.WriteString(".*")
+		case c == '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(value[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// parseSUMIFSNumber parses a numeric criterion operand or source value, the
+// percentages are supported in the operands.
+func parseSUMIFSNumber(value string) (float64, bool) {
+	percentile := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, percentile = strings.TrimSuffix(value, "%"), 0.01
+	}
+	num, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return num * percentile, err == nil && value != ""
+}
+
+// match returns if a criteria value of the source rows meets the criterion.
+func (c sumifsCriterion) match(key string) bool {
+	switch {
+	case c.plain:
+		return key == c.value
+	case c.pattern != nil:
+		return c.pattern.MatchString(key)
+	case c.operator == "<>":
+		return key != ""
+	}
+	if key == "TRUE" || key == "FALSE" {
+		return false
+	}
+	num, err := strconv.ParseFloat(key, 64)
+	if c.numeric {
+		if err != nil {
+			return false
+		}
+		return compareSUMIFSCriterion(c.operator, num < c.number, num == c.number)
+	}
+	if err == nil {
+		return false
+	}
+	key = strings.ToLower(key)
+	return compareSUMIFSCriterion(c.operator, key < c.value, key == c.value)
+}
+
+// matchText returns if a text value of the source cells meets the criterion,
+// for the scans which keep the cell types. A text like "70" is not a number:
+// it only meets the wildcards, the "<>" and the text comparisons and equals
+// the text criteria, like the regular evaluation does.
+func (c sumifsCriterion) matchText(value string) bool {
+	switch {
+	case c.plain:
+		_, numeric := parseSUMIFSNumber(c.value)
+		return !numeric && value == c.value
+	case c.pattern != nil:
+		return c.pattern.MatchString(value)
+	case c.operator == "<>":
+		return value != ""
+	case c.numeric:
+		return false
+	}
+	value = strings.ToLower(value)
+	return compareSUMIFSCriterion(c.operator, value < c.value, value == c.value)
+}
+
+// compareSUMIFSCriterion evaluates a comparison operator with the results of
+// comparing a value with the operand.
+func compareSUMIFSCriterion(operator string, less, equal bool) bool {
+	switch operator {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+// parseSUMIFSCriteria parses the resolved criteria values of a batch SUMIFS
+// formula, it returns false if any criterion should be calculated cell by cell.
+func parseSUMIFSCriteria(values ...string) ([]sumifsCriterion, bool) {
+	criteria := make([]sumifsCriterion, len(values))
+	for i, value := range values {
+		criterion, ok := parseSUMIFSCriterion(value)
+		if !ok {
+			return nil, false
+		}
+		criteria[i] = criterion
+	}
+	return criteria, true
+}
+
+// sumifs1DSum returns the sum of the 1-criterion result map for a criterion.
+func sumifs1DSum(resultMap map[string]float64, criterion sumifsCriterion) float64 {
+	if criterion.plain {
+		return resultMap[criterion.value]
+	}
+	var sum float64
+	for key, value := range resultMap {
+		if criterion.match(key) {
+			sum += value
+		}
+	}
+	return sum
+}
+
+// sumifs2DSum returns the sum of the 2-criteria result map for the criteria.
+func sumifs2DSum(resultMap map[string]map[string]float64, criterion1, criterion2 sumifsCriterion) float64 {
+	if criterion1.plain && criterion2.plain {
+		return resultMap[criterion1.value][criterion2.value]
+	}
+	var sum float64
+	for key1, values := range resultMap {
+		if !criterion1.match(key1) {
+			continue
+		}
+		sum += sumifs1DSum(values, criterion2)
+	}
+	return sum
+}
+
+// sumifsNDSum returns the sum of the N-criteria result map for the criteria.
+func sumifsNDSum(resultMap map[string]float64, criteria []sumifsCriterion) float64 {
+	values, plain := make([]string, len(criteria)), true
+	for i, criterion := range criteria {
+		values[i], plain = criterion.value, plain && criterion.plain
+	}
+	if plain {
+		return resultMap[sumifsNDKey(values)]
+	}
+	var sum float64
+	for key, value := range resultMap {
+		matched := true
+		for i, part := range strings.SplitN(key, "\x00", len(criteria)) {
+			if matched = criteria[i].match(part); !matched {
+				break
+			}
+		}
+		if matched {
+			sum += value
+		}
+	}
+	return sum
+}
+
+// sumifsCriteriaGroup is the sum of a distinct combination of the resolved
+// criteria values of a batch SUMIFS pattern.
+type sumifsCriteriaGroup struct {
+	sum float64
+	ok  bool // false if the criteria should be calculated cell by cell
+}
+
+// sumifsCriteriaGroups materializes the grouped aggregation of a batch SUMIFS
+// pattern over its result map: the formulas of a pattern usually share a few
+// distinct criteria values, like the regions or the months of a report, so
+// the criteria of each distinct combination of the values are parsed and
+// summed once, and the other formulas with the same values are served by a
+// lookup. It saves matching the comparison and the wildcard criteria against
+// all keys of the result map for each formula.
+type sumifsCriteriaGroups struct {
+	groups map[string]sumifsCriteriaGroup // joined criteria values -> sum
+	sumFn  func(criteria []sumifsCriterion) float64
+}
+
+// newSUMIFSCriteriaGroups creates the grouped sums of a pattern, sumFn sums
+// the result map of the pattern for the parsed criteria.
+func newSUMIFSCriteriaGroups(sumFn func(criteria []sumifsCriterion) float64) *sumifsCriteriaGroups {
+	return &sumifsCriteriaGroups{groups: make(map[string]sumifsCriteriaGroup), sumFn: sumFn}
+}
+
+// sum returns the sum of the resolved criteria values of a formula, it
+// returns false if any criterion should be calculated cell by cell.
+func (g *sumifsCriteriaGroups) sum(values ...string) (float64, bool) {
+	key := sumifsNDKey(values)
+	if group, ok := g.groups[key]; ok {
+		return group.sum, group.ok
+	}
+	var group sumifsCriteriaGroup
+	if criteria, ok := parseSUMIFSCriteria(values...); ok {
+		group = sumifsCriteriaGroup{sum: g.sumFn(criteria), ok: true}
+	}
+	g.groups[key] = group
+	return group.sum, group.ok
+}
+
+// sumifsCriteriaValue resolves a criterion argument of a batch SUMIFS like
+// resolveCriteriaValue, with the logical values in the canonical TRUE or
+// FALSE form of the source rows: a boolean cell is read as 1 or 0, which
+// would match the numbers, and the TRUE and FALSE literals are not cell
+// references. A comparison with a boolean cell, like ">="&$A2, returns an
+// empty string to be calculated cell by cell.
+func (f *File) sumifsCriteriaValue(sheet, criteria string, worksheetCache *WorksheetCache) string {
+	switch upper := strings.ToUpper(criteria); upper {
+	case "TRUE", "FALSE":
+		return upper
+	}
+	value := f.resolveCriteriaValue(sheet, criteria, worksheetCache)
+	if _, operand, ok := splitCriteriaConcat(criteria); ok {
+		if f.isBooleanCriteriaCell(sheet, strings.ReplaceAll(operand, "$", "")) {
+			return ""
+		}
+		return value
+	}
+	if upper := strings.ToUpper(value); upper == "TRUE" || upper == "FALSE" {
+		return upper
+	}
+	if canonical := sumifsBooleanValue(value); canonical != "" && f.isBooleanCriteriaCell(sheet, criteria) {
+		return canonical
+	}
+	return value
+}
+
+// sumifsBooleanValue returns TRUE or FALSE for the raw value 1 or 0 of a
+// boolean cell, or an empty string for the other values.
+func sumifsBooleanValue(raw string) string {
+	switch raw {
+	case "1":
+		return "TRUE"
+	case "0":
+		return "FALSE"
+	}
+	return ""
+}
+
+// isBooleanCriteriaCell returns if a criterion argument is a reference to a
+// boolean cell, on its own sheet for a cross-sheet reference.
+func (f *File) isBooleanCriteriaCell(sheet, criteria string) bool {
+	if criteriaSheet, ref, ok := splitSheetReference(criteria); ok {
+		sheet, criteria = criteriaSheet, ref
+	}
+	if _, _, err := CellNameToCoordinates(criteria); err != nil {
+		return false
+	}
+	cellType, err := f.GetCellType(sheet, criteria)
+	return err == nil && cellType == CellTypeBool
+}
+
+// sumifsTypedRows returns the raw rows of a sheet with the values of its
+// boolean cells, which are read as 1 and 0, replaced by TRUE and FALSE like
+// the calculated logical results, so the batch SUMIFS tell the logical values
+// from the numbers. The text cells of the sum column sumCol are blanked, as
+// SUMIFS only sum the numeric cells and a text like "70" would read as a
+// number. The input rows are not modified: the outer slice and every touched
+// row are copied before writing.
+func (f *File) sumifsTypedRows(sheet string, rows [][]string, sumCol int) [][]string {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return rows
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	var canonical [][]string
+	copied := make(map[int]bool)
+	for r := range ws.SheetData.Row {
+		row := &ws.SheetData.Row[r]
+		for i := range row.C {
+			c := &row.C[i]
+			col, rowNum := i+1, row.R
+			if c.R != "" {
+				if col, rowNum, err = CellNameToCoordinates(c.R); err != nil {
+					continue
+				}
+			}
+			cellType := f.shadowCellType(sheet, col, rowNum, c)
+			if cellType != "b" && !isTextCell(&xlsxC{T: cellType}) {
+				continue
+			}
+			if rowNum > len(rows) || col > len(rows[rowNum-1]) {
+				continue
+			}
+			var value string
+			if cellType == "b" {
+				if value = sumifsBooleanValue(rows[rowNum-1][col-1]); value == "" {
+					continue
+				}
+			} else if col != sumCol || rows[rowNum-1][col-1] == "" {
+				continue
+			}
+			if canonical == nil {
+				canonical = make([][]string, len(rows))
+				copy(canonical, rows)
+			}
+			if !copied[rowNum-1] {
+				canonical[rowNum-1] = append([]string(nil), canonical[rowNum-1]...)
+				copied[rowNum-1] = true
+			}
+			canonical[rowNum-1][col-1] = value
+		}
+	}
+	if canonical == nil {
+		return rows
+	}
+	return canonical
+}
+
+// isTextCell returns if the value of a cell is a text, which may read as a
+// number like "70".
+func isTextCell(c *xlsxC) bool {
+	return c.T == "s" || c.T == "str" || c.T == "inlineStr"
+}
+
+// formattedCriteriaValue resolves a criterion argument of a batch SUMIFS
+// formula whose source rows are read with formatted values. The string and
+// numeric literals are returned like resolveCriteriaValue, the formatted
+// value of a cell reference, on its own sheet for a cross-sheet reference, is
+// returned.
+func (f *File) formattedCriteriaValue(sheet, criteria string) string {
+	if len(criteria) >= 2 && criteria[0] == '"' && criteria[len(criteria)-1] == '"' {
+		return criteria[1 : len(criteria)-1]
+	}
+	if len(criteria) > 0 && criteria[0] >= '0' && criteria[0] <= '9' {
+		if _, err := strconv.ParseFloat(criteria, 64); err == nil {
+			return criteria
+		}
+	}
+	if prefix, operand, ok := splitCriteriaConcat(criteria); ok {
+		return prefix + f.formattedCriteriaValue(sheet, strings.ReplaceAll(operand, "$", ""))
+	}
+	if criteriaSheet, ref, ok := splitSheetReference(criteria); ok {
+		sheet, criteria = criteriaSheet, ref
+	}
+	value, _ := f.GetCellValue(sheet, criteria)
+	return value
+}
+
+// isBatchCriteriaArg reports whether a criterion argument of a batch SUMIFS
+// formula can be resolved per formula: a literal or a local reference, or a
+// single cell of another sheet like Config!$B$2. The other cross-sheet
+// arguments, such as ranges, are calculated one by one.
+func isBatchCriteriaArg(criteria string) bool {
+	if !strings.Contains(criteria, "!") {
+		return true
+	}
+	_, ref, ok := splitSheetReference(criteria)
+	if !ok || strings.Contains(ref, ":") {
+		return false
+	}
+	_, _, err := CellNameToCoordinates(strings.ReplaceAll(ref, "$", ""))
+	return err == nil
+}
+
+// splitCriteriaConcat splits a criterion argument concatenating a string
+// literal with a cell reference or a number, like ">="&$F$1, into the
+// unquoted literal and the operand.
+func splitCriteriaConcat(criteria string) (string, string, bool) {
+	if len(criteria) < 2 || criteria[0] != '"' {
+		return "", "", false
+	}
+	end := strings.IndexByte(criteria[1:], '"') + 1
+	if end == 0 {
+		return "", "", false
+	}
+	rest := strings.TrimSpace(criteria[end+1:])
+	if !strings.HasPrefix(rest, "&") {
+		return "", "", false
+	}
+	operand := strings.TrimSpace(rest[1:])
+	if _, err := strconv.ParseFloat(operand, 64); err == nil {
+		return criteria[1:end], operand, true
+	}
+	if _, _, err := CellNameToCoordinates(strings.ReplaceAll(operand, "$", "")); err != nil {
+		return "", "", false
+	}
+	return criteria[1:end], operand, true
+}
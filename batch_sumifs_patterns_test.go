@@ -1,10 +1,14 @@
 package excelize
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDetectAndCalculateBatchSUMIFS(t *testing.T) {
@@ -241,6 +245,339 @@ func TestBatchCalculateSUMIFSWithCache(t *testing.T) {
 	}
 }
 
+func TestBatchCalculateSUMIFSCrossSheetCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	for _, sheet := range []string{"Data", "Config"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create sheet %s: %v", sheet, err)
+		}
+	}
+	for i := 1; i <= 24; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{fmt.Sprintf("R%d", i%2), fmt.Sprintf("P%d", i%4), i}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	// The cross-sheet criterion is a formula calculated in an earlier level
+	if err := f.SetCellFormula("Config", "B2", `"R"&1`); err != nil {
+		t.Fatalf("set config formula: %v", err)
+	}
+
+	formulas := make(map[string]string)
+	expected := make(map[string]int)
+	for row := 1; row <= 4; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("P%d", row%4)); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		cell := fmt.Sprintf("C%d", row)
+		formula := fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,Config!$B$2,Data!$B:$B,$A%d)", row)
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		formulas["Sheet1!"+cell] = formula
+		for i := 1; i <= 24; i++ {
+			if i%2 == 1 && i%4 == row%4 {
+				expected[cell] += i
+			}
+		}
+	}
+
+	if f.extractSUMIFS2DPattern("Sheet1", "C1", formulas["Sheet1!C1"]) == nil {
+		t.Fatal("expected the cross-sheet criterion to be batched")
+	}
+	for _, formula := range []string{
+		"SUMIFS(Data!$C:$C,Data!$A:$A,Config!$B$2:$B$3,Data!$B:$B,$A1)",
+		`SUMIFS(Data!$C:$C,Data!$A:$A,Config!$B$2&"x",Data!$B:$B,$A1)`,
+	} {
+		if f.extractSUMIFS2DPattern("Sheet1", "C1", formula) != nil {
+			t.Fatalf("expected %s not to be batched", formula)
+		}
+	}
+
+	cache := NewWorksheetCache()
+	cache.Set("Config", "B2", newStringFormulaArg("R1"))
+	results := f.batchCalculateSUMIFSWithCache(formulas, cache)
+	for cell, want := range expected {
+		if got := results["Sheet1!"+cell]; got != fmt.Sprint(want) {
+			t.Fatalf("unexpected batch value of %s: %s, want %d", cell, got, want)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != fmt.Sprint(want) {
+			t.Fatalf("unexpected value of %s: %s, want %d", cell, got, want)
+		}
+	}
+}
+
+func TestSUMIFSCriteriaFromCrossSheetLookup(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	for _, sheet := range []string{"Sheet2", "Lookup", "Data"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			t.Fatalf("create sheet %s: %v", sheet, err)
+		}
+	}
+	regions := []string{"East", "West", "North"}
+	for i, region := range regions {
+		if err := f.SetSheetRow("Lookup", fmt.Sprintf("A%d", i+1), &[]interface{}{fmt.Sprintf("C%d", i), region}); err != nil {
+			t.Fatalf("set lookup row: %v", err)
+		}
+	}
+	sums := make(map[[2]string]int)
+	for i := 1; i <= 30; i++ {
+		region, product := regions[i%3], fmt.Sprintf("P%d", i%2)
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{region, product, i}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		sums[[2]string{region, product}] += i
+		sums[[2]string{region, ""}] += i
+	}
+
+	// Sheet1 的 INDEX-MATCH 按代码查找区域，Sheet2 的 SUMIFS 以其结果为条件
+	expected := make(map[string]int)
+	for row := 1; row <= 12; row++ {
+		region := regions[row%3]
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("C%d", row%3)); err != nil {
+			t.Fatalf("set code: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("INDEX(Lookup!$B:$B,MATCH($A%d,Lookup!$A:$A,0))", row)); err != nil {
+			t.Fatalf("set lookup formula: %v", err)
+		}
+		if err := f.SetCellValue("Sheet2", fmt.Sprintf("A%d", row), fmt.Sprintf("P%d", row%2)); err != nil {
+			t.Fatalf("set product: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,Sheet1!$B%d)", row),
+			"C": fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,Sheet1!$B%d,Data!$B:$B,$A%d)", row, row),
+			"D": fmt.Sprintf("IFERROR(SUMIFS(Data!$C:$C,Data!$A:$A,Sheet1!$B%d,Data!$B:$B,$A%d),0)+1", row, row),
+		} {
+			if err := f.SetCellFormula("Sheet2", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+		expected[fmt.Sprintf("B%d", row)] = sums[[2]string{region, ""}]
+		expected[fmt.Sprintf("C%d", row)] = sums[[2]string{region, fmt.Sprintf("P%d", row%2)}]
+		expected[fmt.Sprintf("D%d", row)] = sums[[2]string{region, fmt.Sprintf("P%d", row%2)}] + 1
+	}
+
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet2", cell); got != fmt.Sprint(want) {
+			t.Fatalf("unexpected value of Sheet2!%s: %s, want %d", cell, got, want)
+		}
+	}
+	levels := make(map[string]CalcAuditEntry)
+	for _, entry := range f.CalcAuditTrail() {
+		levels[entry.Cell] = entry
+	}
+	for _, cell := range []string{"Sheet2!B1", "Sheet2!C1"} {
+		if entry := levels[cell]; entry.Optimizer != "SUMIFS" || entry.Level <= levels["Sheet1!B1"].Level {
+			t.Fatalf("unexpected audit entry %v after the lookup %v", entry, levels["Sheet1!B1"])
+		}
+	}
+}
+
+func TestExtractSUMIFPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	// SUMIF 的求和范围是第三个参数，SUMIFS 的求和范围是第一个参数
+	for formula, expected := range map[string][2]string{
+		"SUMIF(Data!$A:$A,$A2,Data!$C:$C)":            {"Data!$C:$C", "Data!$A:$A"},
+		"SUMIF(Data!$A$2:$A$9,\">5\",Data!$C$2:$C$9)": {"Data!$C$2:$C$9", "Data!$A$2:$A$9"},
+		"SUMIF(Data!$C:$C,Config!$B$1)":               {"Data!$C:$C", "Data!$C:$C"},
+		// 省略工作表名称的范围属于公式所在的工作表
+		"SUMIF($A:$A,$A2,$C:$C)": {"Sheet1!$C:$C", "Sheet1!$A:$A"},
+		"SUMIF(A:A,\">0\")":      {"Sheet1!A:A", "Sheet1!A:A"},
+	} {
+		pattern := f.extractSUMIFPattern("Sheet1", "B2", formula)
+		if pattern == nil {
+			t.Fatalf("expected %s to be batched", formula)
+		}
+		if pattern.sumRangeRef != expected[0] || pattern.criteriaRange1Ref != expected[1] {
+			t.Fatalf("%s: unexpected sum range %s and criteria range %s, want %v", formula, pattern.sumRangeRef, pattern.criteriaRange1Ref, expected)
+		}
+	}
+	for _, formula := range []string{
+		"SUMIFS(Data!$C:$C,Data!$A:$A,$A2)",
+		"SUMIF(Data!$A$2:$A$9,$A2,Data!$C$3:$C$10)",
+		"SUMIF(Data!$A:$A,$A2,Data!$C:$C,1)",
+	} {
+		if f.extractSUMIFPattern("Sheet1", "B2", formula) != nil {
+			t.Fatalf("expected %s not to be batched as SUMIF", formula)
+		}
+	}
+	if pattern := f.extractSUMIFS1DPattern("Sheet1", "B2", "SUMIF(Data!$A:$A,$A2,Data!$C:$C)"); pattern != nil {
+		t.Fatalf("expected SUMIF not to be extracted as SUMIFS, got %+v", pattern)
+	}
+	for formula, expected := range map[string]bool{
+		"=SUMIF(Data!$A:$A,$A2,Data!$C:$C)":  true,
+		"SUMIF(Data!$A:$A,$A2,Data!$C:$C)+1": false,
+		"SUMIFS(Data!$C:$C,Data!$A:$A,$A2)":  false,
+		"IFERROR(SUMIF(Data!$A:$A,$A2),0)":   false,
+	} {
+		if got := isPureSUMIFFormula(formula); got != expected {
+			t.Fatalf("isPureSUMIFFormula(%s) = %t, want %t", formula, got, expected)
+		}
+	}
+}
+
+func TestRecalculateSUMIFFormulas(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	sums := make(map[string]int)
+	amounts := make(map[int]int)
+	for i := 1; i <= 40; i++ {
+		key := fmt.Sprintf("K%d", i%6)
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{key, i % 7, i * 10}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		sums[key] += i * 10
+		amounts[i%7] += i % 7
+	}
+	// 文本 "70" 不是数值，SUMIF 不求和
+	if err := f.SetSheetRow("Data", "A41", &[]interface{}{"K1", nil, "70"}); err != nil {
+		t.Fatalf("set text row: %v", err)
+	}
+
+	expected := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		key := fmt.Sprintf("K%d", row%6)
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{key, row % 7}); err != nil {
+			t.Fatalf("set criteria row: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"C": fmt.Sprintf("SUMIF(Data!$A:$A,$A%d,Data!$C:$C)", row),
+			"D": fmt.Sprintf("SUMIF(Data!$B:$B,$B%d)", row),
+			// 参数顺序与 SUMIF 相同的 SUMIFS 对 A 列求和，结果为 0
+			"E": fmt.Sprintf("SUMIFS(Data!$A:$A,$A%d,Data!$C:$C)", row),
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+		expected[fmt.Sprintf("C%d", row)] = fmt.Sprint(sums[key])
+		expected[fmt.Sprintf("D%d", row)] = fmt.Sprint(amounts[row%7])
+		expected[fmt.Sprintf("E%d", row)] = "0"
+	}
+
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("unexpected value of %s: %s, want %s", cell, got, want)
+		}
+	}
+	if got, err := f.CalcCellValue("Sheet1", "C1"); err != nil || got != expected["C1"] {
+		t.Fatalf("unexpected calculated value of C1: %s, %v, want %s", got, err, expected["C1"])
+	}
+	for _, entry := range f.CalcAuditTrail() {
+		if strings.HasPrefix(entry.Cell, "Sheet1!C") || strings.HasPrefix(entry.Cell, "Sheet1!D") {
+			if entry.Optimizer != "SUMIFS" {
+				t.Fatalf("unexpected audit entry %v, want SUMIF batched as SUMIFS", entry)
+			}
+		}
+	}
+}
+
+func TestRecalculateSUMIFWithoutSumRange(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	// A 列包含负数、零、文本和空单元格，SUMIF 只对满足条件的数值求和，文本 "5" 不求和
+	values := make(map[int]float64)
+	for i := 1; i <= 30; i++ {
+		var value interface{} = i - 10
+		switch {
+		case i%9 == 0:
+			value = "text"
+		case i == 15:
+			value = "5"
+		case i%7 == 0:
+			continue
+		case i == 5:
+			value = 2.5
+		}
+		if number, ok := value.(int); ok {
+			values[i] = float64(number)
+		} else if number, ok := value.(float64); ok {
+			values[i] = number
+		}
+		for _, sheet := range []string{"Sheet1", "Data"} {
+			if err := f.SetCellValue(sheet, fmt.Sprintf("A%d", i), value); err != nil {
+				t.Fatalf("set value: %v", err)
+			}
+		}
+	}
+	sumIf := func(match func(float64) bool) string {
+		var sum float64
+		for _, value := range values {
+			if match(value) {
+				sum += value
+			}
+		}
+		return strconv.FormatFloat(sum, 'f', -1, 64)
+	}
+
+	expected := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), row); err != nil {
+			t.Fatalf("set threshold: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"C": `SUMIF(A:A,">0")`,
+			"D": `SUMIF(Data!$A:$A,">0")`,
+			"E": fmt.Sprintf(`SUMIF($A$1:$A$30,">="&$B%d)`, row),
+			"F": fmt.Sprintf("SUMIF(Data!$A:$A,$B%d)", row),
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+		threshold := float64(row)
+		expected[fmt.Sprintf("C%d", row)] = sumIf(func(value float64) bool { return value > 0 })
+		expected[fmt.Sprintf("D%d", row)] = expected[fmt.Sprintf("C%d", row)]
+		expected[fmt.Sprintf("E%d", row)] = sumIf(func(value float64) bool { return value >= threshold })
+		expected[fmt.Sprintf("F%d", row)] = sumIf(func(value float64) bool { return value == threshold })
+	}
+
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Errorf("unexpected value of %s: %s, want %s", cell, got, want)
+		}
+	}
+	if got, err := f.CalcCellValue("Sheet1", "F5"); err != nil || got != "0" {
+		t.Errorf("unexpected calculated value of F5: %s, %v, want 0", got, err)
+	}
+	for _, entry := range f.CalcAuditTrail() {
+		if entry.Optimizer != "SUMIFS" {
+			t.Errorf("unexpected audit entry %v, want SUMIF without sum range batched as SUMIFS", entry)
+		}
+	}
+}
+
 func TestGetCellValueOrCalcCache(t *testing.T) {
 	f := NewFile()
 	t.Cleanup(func() { _ = f.Close() })
@@ -259,3 +596,856 @@ func TestGetCellValueOrCalcCache(t *testing.T) {
 		t.Fatalf("expected cached numeric string, got %s", got)
 	}
 }
+
+func TestBatchOptimizeLevelWritesPureSUMIFSValues(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	for i := 1; i <= 24; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{fmt.Sprintf("P%d", i%12), fmt.Sprintf("R%d", i%2), i}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+
+	expected := make(map[string]int)
+	for row := 1; row <= 12; row++ {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("P%d", row%12), fmt.Sprintf("R%d", row%2)}); err != nil {
+			t.Fatalf("set criteria row: %v", err)
+		}
+		// The formula cell is created by SetCellFormula only, without any cached value
+		formula := fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,$B%d)", row, row)
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		for i := 1; i <= 24; i++ {
+			if i%12 == row%12 && i%2 == row%2 {
+				expected[fmt.Sprintf("C%d", row)] += i
+			}
+		}
+	}
+
+	graph := f.buildDependencyGraph()
+	wc := NewWorksheetCache()
+	for idx, levelCells := range graph.levels {
+		f.batchOptimizeLevelWithCache(context.Background(), idx, levelCells, graph, wc)
+	}
+
+	f.workSheetWriter()
+	content, ok := f.Pkg.Load("xl/worksheets/sheet1.xml")
+	if !ok {
+		t.Fatalf("worksheet XML not found")
+	}
+	sheetXML := string(content.([]byte))
+	for cell, want := range expected {
+		pattern := regexp.MustCompile(`<c r="` + cell + `"[^>]*><f>SUMIFS\([^<]*\)</f><v>([^<]*)</v></c>`)
+		match := pattern.FindStringSubmatch(sheetXML)
+		if match == nil {
+			t.Fatalf("expected %s to keep <f> and have a populated <v>", cell)
+		}
+		if match[1] != fmt.Sprint(want) {
+			t.Errorf("%s: expected <v>%d</v>, got <v>%s</v>", cell, want, match[1])
+		}
+	}
+}
+
+func TestBatchCalculateSUMIFSThreeCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+
+	products := []string{"P0", "P1", "P2"}
+	regions := []string{"East", "West"}
+	statuses := []string{"Active", "Closed"}
+	type key struct{ product, region, status, flag string }
+	sums := make(map[key]float64)
+	for idx := 0; idx < 60; idx++ {
+		row := idx + 1
+		k := key{products[idx%3], regions[idx%2], statuses[idx/5%2], []string{"Y", "N"}[idx/7%2]}
+		qty := float64(idx + 1)
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", row), &[]interface{}{k.product, k.region, nil, k.status, k.flag, nil, nil, qty}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		sums[k] += qty
+		sums[key{k.product, k.region, k.status, ""}] += qty
+	}
+
+	formulas := make(map[string]string)
+	expected := make(map[string]float64)
+	for i, product := range products {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), product); err != nil {
+			t.Fatalf("set product: %v", err)
+		}
+		for j, region := range regions {
+			col, _ := ColumnNumberToName(j + 3)
+			if err := f.SetCellValue("Sheet1", col+"1", region); err != nil {
+				t.Fatalf("set region: %v", err)
+			}
+			cell := fmt.Sprintf("%s%d", col, row)
+			formulas["Sheet1!"+cell] = fmt.Sprintf(`SUMIFS(data!$H:$H,data!$A:$A,$A%d,data!$B:$B,%s$1,data!$D:$D,"Active")`, row, col)
+			expected["Sheet1!"+cell] = sums[key{product, region, "Active", ""}]
+			// 第三个条件范围不同的公式不能与上面的公式合并
+			cell = fmt.Sprintf("%s%d", []string{"E", "F"}[j], row)
+			formulas["Sheet1!"+cell] = fmt.Sprintf(`SUMIFS(data!$H:$H,data!$A:$A,$A%d,data!$B:$B,%s$1,data!$D:$D,"Active",data!$E:$E,"Y")`, row, col)
+			expected["Sheet1!"+cell] = sums[key{product, region, "Active", "Y"}]
+		}
+	}
+
+	if patterns := f.groupSUMIFSNDByPattern(formulas); len(patterns) != 2 {
+		t.Fatalf("expected 2 patterns, got %d", len(patterns))
+	}
+	if f.extractSUMIFSNDPattern("Sheet1", "A1", `SUMIFS(data!$H:$H,data!$A:$A,$A2,other!$B:$B,C$1,data!$D:$D,"Active")`) != nil {
+		t.Fatalf("expected no pattern for criteria ranges on another sheet")
+	}
+
+	results := f.batchCalculateSUMIFSWithCache(formulas, NewWorksheetCache())
+	for cell, want := range expected {
+		if got := results[cell]; got != fmt.Sprintf("%v", want) {
+			t.Fatalf("%s: unexpected batch SUMIFS value %q, want %v", cell, got, want)
+		}
+		sheet, ref, _ := strings.Cut(cell, "!")
+		if err := f.SetCellFormula(sheet, ref, formulas[cell]); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if got, err := f.CalcCellValue(sheet, ref); err != nil || got != fmt.Sprintf("%v", want) {
+			t.Fatalf("%s: CalcCellValue returned %q, %v, want %v", cell, got, err, want)
+		}
+	}
+
+	// 复合公式中的 3 条件 SUMIFS 通过数据源分组批量计算
+	for i := range products {
+		row := i + 2
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("G%d", row), formulas[fmt.Sprintf("Sheet1!C%d", row)]+"*2"); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for i := range products {
+		row := i + 2
+		want := fmt.Sprintf("%v", expected[fmt.Sprintf("Sheet1!C%d", row)]*2)
+		if got, _ := f.GetCellValue("Sheet1", fmt.Sprintf("G%d", row)); got != want {
+			t.Fatalf("G%d: unexpected composite value %q, want %s", row, got, want)
+		}
+	}
+	if plan, err := f.LastCalcPlan(); err != nil || plan.SUMIFSSourceGroups != 2 {
+		t.Fatalf("expected 2 SUMIFS source groups, got %+v, %v", plan, err)
+	}
+}
+
+func TestBatchCalculateSUMIFSShiftedRanges(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	if err := f.SetSheetRow("data", "A1", &[]interface{}{"Key", "Region", "Amount"}); err != nil {
+		t.Fatalf("set header: %v", err)
+	}
+	// 第 2 行为空，数据从第 3 行开始
+	keys := []string{"K0", "K1", "K2", "K3", "K4", "K5"}
+	regions := []string{"East", "West"}
+	sums := make(map[string]float64)
+	firstRowSums := make(map[string]float64)
+	for idx := 0; idx < 24; idx++ {
+		key, region, amount := keys[idx%len(keys)], regions[idx/3%2], float64(idx+1)
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+3), &[]interface{}{key, region, amount}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		sums[key+region] += amount
+		if idx == 0 {
+			firstRowSums[key+region] += amount
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "B1", "East"); err != nil {
+		t.Fatalf("set region: %v", err)
+	}
+	expected := make(map[string]float64)
+	for i, key := range keys {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		for col, rows := range map[string][2]int{"C": {2, 100}, "D": {3, 101}, "E": {4, 102}} {
+			formula := fmt.Sprintf("SUMIFS(data!$C$%[1]d:$C$%[2]d,data!$A$%[1]d:$A$%[2]d,$A%[3]d,data!$B$%[1]d:$B$%[2]d,$B$1)", rows[0], rows[1], row)
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			expected[fmt.Sprintf("%s%d", col, row)] = sums[key+"East"]
+		}
+		// E 列的范围不包含第 3 行的数据
+		expected[fmt.Sprintf("E%d", row)] -= firstRowSums[key+"East"]
+	}
+
+	// 平移后的范围具有相同的数据源，不影响匹配行的平移共享扫描范围
+	key1, span1, ok1 := sumifsSourceKey("data!$C$2:$C$100", []string{"data!$A$2:$A$100"})
+	key2, span2, ok2 := sumifsSourceKey("data!$C$3:$C$101", []string{"data!$A$3:$A$101"})
+	if !ok1 || !ok2 || key1 != key2 || span1 == span2 {
+		t.Fatalf("expected the same source for shifted ranges, got %q %v and %q %v", key1, span1, key2, span2)
+	}
+	if _, _, ok := sumifsSourceKey("data!$C$2:$C$100", []string{"data!$A$3:$A$101"}); ok {
+		t.Fatal("expected no source key for ranges covering different rows")
+	}
+	rows, err := f.getCachedRawRows("data")
+	if err != nil {
+		t.Fatalf("get rows: %v", err)
+	}
+	scanSpans, union := shiftedSUMIFSScanSpans(rows, 2, map[[2]int]bool{{2, 100}: true, {3, 101}: true, {4, 102}: true})
+	if union != [2]int{2, 102} || scanSpans[[2]int{2, 100}] != union || scanSpans[[2]int{3, 101}] != union || scanSpans[[2]int{4, 102}] != [2]int{4, 102} {
+		t.Fatalf("unexpected scan spans %v", scanSpans)
+	}
+
+	formulas := make(map[string]string)
+	for cell := range expected {
+		formulas["Sheet1!"+cell], _ = f.GetCellFormula("Sheet1", cell)
+	}
+	patterns := f.groupSUMIFSByPattern(formulas)
+	merged := mergeShiftedSUMIFSPatterns(f, patterns)
+	if len(patterns) != 3 || len(merged) != 2 {
+		t.Fatalf("expected 3 patterns merged into 2, got %d and %d", len(patterns), len(merged))
+	}
+	for _, pattern := range merged {
+		if len(pattern.formulas) == 2*len(keys) && pattern.sumRangeRef != "data!$C$2:$C$102" {
+			t.Fatalf("unexpected merged sum range %s", pattern.sumRangeRef)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != fmt.Sprintf("%v", want) {
+			t.Fatalf("%s: unexpected SUMIFS value %q, want %v", cell, got, want)
+		}
+		f.calcCache.Delete("Sheet1!" + cell + "!raw=false")
+		if got, err := f.CalcCellValue("Sheet1", cell); err != nil || got != fmt.Sprintf("%v", want) {
+			t.Fatalf("%s: CalcCellValue returned %q, %v, want %v", cell, got, err, want)
+		}
+	}
+	if plan, err := f.LastCalcPlan(); err != nil || plan.SUMIFSSourceGroups != 1 {
+		t.Fatalf("expected 1 SUMIFS source group, got %+v, %v", plan, err)
+	}
+}
+
+func TestBatchCalculateSUMIFSOperatorAndWildcardCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	keys := []interface{}{"Apple", "apricot", "Banana", "a*b", 50, 150, 200, 5}
+	for idx, key := range keys {
+		region := []string{"East", "West"}[idx%2]
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+1), &[]interface{}{key, region, 1 << idx}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "C1", "W*"); err != nil {
+		t.Fatalf("set region criteria: %v", err)
+	}
+
+	criteria := []struct {
+		value     string
+		sum, west float64
+	}{
+		{">100", 96, 32},
+		{">=150", 96, 32},
+		{"<=50", 144, 128},
+		{"<10", 128, 128},
+		{"<>", 255, 170},
+		{"App*", 1, 0},
+		{"?pple", 1, 0},
+		{"a~*b", 8, 8},
+		{"*an*", 4, 0},
+		{"=Banana", 4, 0},
+		{"Apple", 1, 0},
+		{"<b", 11, 10},
+		{"<>Apple", -1, -1}, // 可能匹配空白单元格，逐个单元格计算
+	}
+	formulas := make(map[string]string)
+	for i, criterion := range criteria {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), criterion.value); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		formulas[fmt.Sprintf("Sheet1!B%d", row)] = fmt.Sprintf("SUMIFS(data!$C:$C,data!$A:$A,$A%d)", row)
+		formulas[fmt.Sprintf("Sheet1!C%d", row)] = fmt.Sprintf("SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,C$1)", row)
+	}
+
+	results := f.batchCalculateSUMIFSWithCache(formulas, NewWorksheetCache())
+	for i, criterion := range criteria {
+		row := i + 2
+		for col, want := range map[string]float64{"B": criterion.sum, "C": criterion.west} {
+			got, ok := results[fmt.Sprintf("Sheet1!%s%d", col, row)]
+			if want < 0 {
+				if ok {
+					t.Fatalf("%s%d: expected criterion %q to fall back, got %q", col, row, criterion.value, got)
+				}
+				continue
+			}
+			if got != fmt.Sprintf("%v", want) {
+				t.Fatalf("%s%d: unexpected SUMIFS value for criterion %q, got %q want %v", col, row, criterion.value, got, want)
+			}
+		}
+	}
+
+	for cell, formula := range formulas {
+		sheet, ref, _ := strings.Cut(cell, "!")
+		if err := f.SetCellFormula(sheet, ref, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range map[string]string{"B2": "96", "B4": "144", "B7": "1", "C2": "32", "B14": "254"} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("%s: unexpected recalculated value %q, want %s", cell, got, want)
+		}
+	}
+
+	for _, value := range []string{"<>x", "=", ""} {
+		if criterion, ok := parseSUMIFSCriterion(value); ok {
+			t.Fatalf("unexpected classification of criterion %q: %+v, %v", value, criterion, ok)
+		}
+	}
+	if c, _ := parseSUMIFSCriterion(">50%"); !c.match("0.6") || c.match("0.4") || c.match("text") {
+		t.Fatalf("unexpected percentage criterion %+v", c)
+	}
+}
+
+func TestBatchCalculateSUMIFSGroupedCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	for idx, key := range []interface{}{"East", "West", "Wide", 3, 7, "East", 1} {
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+1), &[]interface{}{key, 1 << idx}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	// 1000 个公式只有 5 个不同的条件值
+	values := []string{"East", "W*", ">2", "North", "<>x"}
+	sums := []float64{33, 6, 24, 0, -1}
+	formulas := make(map[string]string)
+	for row := 1; row <= 1000; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), values[row%len(values)]); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		formulas[fmt.Sprintf("Sheet1!B%d", row)] = fmt.Sprintf("SUMIFS(data!$B:$B,data!$A:$A,$A%d)", row)
+	}
+	results := f.batchCalculateSUMIFSWithCache(formulas, NewWorksheetCache())
+	if len(results) != 800 {
+		t.Fatalf("got %d results, want 800", len(results))
+	}
+	for row := 1; row <= 1000; row++ {
+		want := sums[row%len(values)]
+		if got, ok := results[fmt.Sprintf("Sheet1!B%d", row)]; want < 0 && ok || want >= 0 && got != fmt.Sprintf("%v", want) {
+			t.Fatalf("B%d: unexpected SUMIFS value %q for criterion %q, want %v", row, got, values[row%len(values)], want)
+		}
+	}
+
+	// 每个不同的条件值只解析和汇总一次
+	var computed int
+	groups := newSUMIFSCriteriaGroups(func(criteria []sumifsCriterion) float64 {
+		computed++
+		return sumifs1DSum(map[string]float64{"East": 1, "West": 2, "Wide": 4, "3": 8}, criteria[0])
+	})
+	grouped := map[string]float64{"East": 1, "W*": 6, ">2": 8, "North": 0}
+	for row := 1; row <= 1000; row++ {
+		value := values[row%len(values)]
+		want, batched := grouped[value]
+		if sum, ok := groups.sum(value); ok != batched || sum != want {
+			t.Fatalf("unexpected grouped sum %v, %t for criterion %q", sum, ok, value)
+		}
+	}
+	if computed != 4 || len(groups.groups) != 5 {
+		t.Fatalf("got %d computed sums of %d groups, want 4 of 5", computed, len(groups.groups))
+	}
+}
+
+func TestBatchSUMIFSBooleanAndDateCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	jan, feb := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)
+	for idx, row := range [][]interface{}{
+		{jan, true, 1}, {feb, false, 2}, {jan, true, 4}, {feb, 1, 8}, {45306, 0, 16}, {feb, 2, 64},
+	} {
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+1), &row); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	// 公式计算的日期和逻辑值
+	for cell, formula := range map[string]string{"A7": "DATE(2024,1,15)", "B7": "1=1"} {
+		if err := f.SetCellFormula("data", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellValue("data", "C7", 32); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	// 日期、逻辑值和数值的条件单元格，以及公式计算的条件
+	for idx, value := range []interface{}{jan, feb, true, false, 1, 0, 2, 45337} {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", idx+1), value); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{"A9": "DATE(2024,2,15)", "A10": "1=1", "A11": "1=0"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	cells := []string{}
+	for row := 1; row <= 11; row++ {
+		for col, formula := range map[string]string{
+			"B": "SUMIFS(data!$C:$C,data!$A:$A,$A%d)",
+			"C": "SUMIFS(data!$C:$C,data!$B:$B,$A%d)",
+			"D": "SUMIFS(data!$C:$C,data!$B:$B,$A%d,data!$A:$A,$A$1)",
+			"E": `SUMIFS(data!$C:$C,data!$A:$A,">="&$A%d)`,
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			cells = append(cells, fmt.Sprintf("%s%d", col, row))
+		}
+	}
+	for cell, formula := range map[string]string{"F1": "SUMIFS(data!$C:$C,data!$B:$B,TRUE)", "F2": `SUMIFS(data!$C:$C,data!$B:$B,"FALSE")`} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		cells = append(cells, cell)
+	}
+
+	// 批量结果与逐个计算的结果一致：逻辑值只匹配逻辑值条件，日期按序列号匹配
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	got := make(map[string]string)
+	for _, cell := range cells {
+		got[cell], _ = f.GetCellValue("Sheet1", cell)
+	}
+	optimizers := make(map[string]string)
+	for _, entry := range f.CalcAuditTrail() {
+		optimizers[entry.Cell] = entry.Optimizer
+	}
+	f.ClearFormulaCache()
+	for _, cell := range cells {
+		want, err := f.CalcCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("calc %s: %v", cell, err)
+		}
+		if got[cell] != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got[cell], want)
+		}
+	}
+	for cell, want := range map[string]string{
+		"B1": "53", "B2": "74", "B9": "74", "C3": "37", "C4": "2", "C5": "8", "C6": "16", "C7": "64", "C10": "37",
+		"C11": "2", "D3": "37", "E1": "127", "E2": "74", "F1": "37", "F2": "2",
+	} {
+		if got[cell] != want {
+			t.Fatalf("unexpected %s value %q, want %s", cell, got[cell], want)
+		}
+	}
+	if optimizers["Sheet1!C3"] != "SUMIFS" || optimizers["Sheet1!D3"] != "SUMIFS" || optimizers["Sheet1!E1"] != "SUMIFS" {
+		t.Fatalf("unexpected optimizers %v", optimizers)
+	}
+
+	// 流式读取的逻辑值同样为 TRUE 和 FALSE
+	rows, err := f.streamRawRows("data", [2]int{1, 5}, []int{2}, 0, nil)
+	if err != nil {
+		t.Fatalf("stream rows: %v", err)
+	}
+	var values []string
+	for chunk := range rows {
+		for _, row := range chunk {
+			values = append(values, row[0])
+		}
+	}
+	if strings.Join(values, ",") != "TRUE,FALSE,TRUE,1,0" {
+		t.Fatalf("unexpected streamed values %v", values)
+	}
+}
+
+func TestSUMIFSComparisonCriteriaTextAndNumber(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	keys := []interface{}{"apple", "Mango", "zebra", "melon", 5, 20, "N", "m"}
+	for idx, key := range keys {
+		region := []string{"East", "West"}[idx%2]
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+1), &[]interface{}{key, region, 1 << idx}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "C1", "West"); err != nil {
+		t.Fatalf("set region criteria: %v", err)
+	}
+
+	// 文本比较按不区分大小写的字典序只匹配文本，数值比较只匹配数值
+	criteria := []struct {
+		value     string
+		sum, west string
+	}{
+		{">M", "78", "10"},
+		{"<m", "1", "0"},
+		{">=melon", "76", "8"},
+		{">5", "32", "32"},
+		{"<=20", "48", "32"},
+		{">1e1", "32", "32"},
+		{"<Z", "203", "138"},
+		{"<=apple", "1", "0"},
+		{">=5", "48", "32"},
+		{"<100%", "0", "0"},
+	}
+	formulas := make(map[string]string)
+	for i, criterion := range criteria {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), criterion.value); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		formulas[fmt.Sprintf("Sheet1!B%d", row)] = fmt.Sprintf("SUMIFS(data!$C:$C,data!$A:$A,$A%d)", row)
+		formulas[fmt.Sprintf("Sheet1!C%d", row)] = fmt.Sprintf("SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,C$1)", row)
+	}
+
+	results := f.batchCalculateSUMIFSWithCache(formulas, NewWorksheetCache())
+	for cell, formula := range formulas {
+		sheet, ref, _ := strings.Cut(cell, "!")
+		if err := f.SetCellFormula(sheet, ref, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	for i, criterion := range criteria {
+		row := i + 2
+		for col, want := range map[string]string{"B": criterion.sum, "C": criterion.west} {
+			cell := fmt.Sprintf("%s%d", col, row)
+			if got := results["Sheet1!"+cell]; got != want {
+				t.Fatalf("%s: unexpected batch SUMIFS value for criterion %q, got %q want %s", cell, criterion.value, got, want)
+			}
+			if got, err := f.CalcCellValue("Sheet1", cell); err != nil || got != want {
+				t.Fatalf("%s: unexpected SUMIFS value for criterion %q, got %q want %s, %v", cell, criterion.value, got, want, err)
+			}
+		}
+	}
+
+	// 以文本存储的数字只匹配文本比较
+	if err := f.SetCellStr("data", "A5", "10"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for formula, want := range map[string]string{
+		`SUMIFS(data!$C:$C,data!$A:$A,">5")`:    "32",
+		`SUMIFS(data!$C:$C,data!$A:$A,"<M")`:    "17",
+		`COUNTIFS(data!$A:$A,"<=25")`:           "1",
+		`AVERAGEIF(data!$A:$A,">Z",data!$C:$C)`: "4",
+	} {
+		if err := f.SetCellFormula("Sheet1", "D1", formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if got, err := f.CalcCellValue("Sheet1", "D1"); err != nil || got != want {
+			t.Fatalf("%s: got %q want %s, %v", formula, got, want, err)
+		}
+	}
+}
+
+// newHugeDimensionSUMIFSFile creates a workbook whose data sheet has the given
+// number of rows, a declared dimension of the whole columns and a formatted
+// empty row at the end of the sheet, with the full-column SUMIFS formulas of
+// 50 keys on Sheet1. It returns the expected values of the formulas.
+func newHugeDimensionSUMIFSFile(tb testing.TB, rows int) (*File, map[string]string) {
+	f := NewFile()
+	if _, err := f.NewSheet("Data"); err != nil {
+		tb.Fatalf("create data sheet: %v", err)
+	}
+	sums := make(map[string]int)
+	for row := 1; row <= rows; row++ {
+		key := fmt.Sprintf("K%02d", row%50)
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{key, row}); err != nil {
+			tb.Fatalf("set data row: %v", err)
+		}
+		sums[key] += row
+	}
+	if err := f.SetSheetDimension("Data", fmt.Sprintf("A1:C%d", TotalRows)); err != nil {
+		tb.Fatalf("set dimension: %v", err)
+	}
+	ws, err := f.workSheetReader("Data")
+	if err != nil {
+		tb.Fatalf("read data sheet: %v", err)
+	}
+	ws.SheetData.Row = append(ws.SheetData.Row, xlsxRow{R: TotalRows, Ht: float64Ptr(20), CustomHeight: true})
+
+	expected := make(map[string]string)
+	for row := 1; row <= 50; row++ {
+		key := fmt.Sprintf("K%02d", row%50)
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			tb.Fatalf("set key: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,$A%d)", row)); err != nil {
+			tb.Fatalf("set formula: %v", err)
+		}
+		expected[fmt.Sprintf("B%d", row)] = fmt.Sprint(sums[key])
+	}
+	return f, expected
+}
+
+func TestBatchSUMIFSWithRounding(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	keys := []string{"K0", "K1", "K2", "K3", "K4", "K5"}
+	sums := make(map[string]float64)
+	for idx := 0; idx < 60; idx++ {
+		key, amount := keys[idx%len(keys)], float64(idx*37+13)
+		if err := f.SetSheetRow("data", fmt.Sprintf("A%d", idx+1), &[]interface{}{key, "East", amount}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+		sums[key] += amount
+	}
+	// 按十位、百位取整和按倍数取整的 SUMIFS 复合公式
+	round := map[string]func(float64) float64{
+		"C": func(v float64) float64 { return math.Round(v/100) * 100 },
+		"D": func(v float64) float64 { return math.Round(v/25) * 25 },
+		"E": func(v float64) float64 { return math.Ceil(v/10) * 10 },
+		"F": func(v float64) float64 { return math.Floor(v/100) * 100 },
+	}
+	formats := map[string]string{
+		"C": "ROUND(SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,\"East\"),-2)",
+		"D": "MROUND(SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,\"East\"),25)",
+		"E": "ROUNDUP(SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,\"East\"),-1)",
+		"F": "ROUNDDOWN(SUMIFS(data!$C:$C,data!$A:$A,$A%d,data!$B:$B,\"East\"),-2)",
+	}
+	expected := make(map[string]string)
+	for i, key := range keys {
+		row := i + 1
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		for col, format := range formats {
+			cell := fmt.Sprintf("%s%d", col, row)
+			if err := f.SetCellFormula("Sheet1", cell, fmt.Sprintf(format, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			expected[cell] = fmt.Sprintf("%v", round[col](sums[key]))
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("%s: unexpected rounded SUMIFS value %q, want %s", cell, got, want)
+		}
+	}
+	if plan, err := f.LastCalcPlan(); err != nil || plan.SUMIFSSourceGroups != 1 {
+		t.Fatalf("expected 1 SUMIFS source group, got %+v, %v", plan, err)
+	}
+}
+
+func TestBatchAVERAGEIFSCriteria(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	if err := f.SetSheetRow("Data", "A1", &[]interface{}{"SKU", "Week", "Score"}); err != nil {
+		t.Fatalf("set header: %v", err)
+	}
+	// 分数列混有文本、空值和数字前缀的文本，均不参与平均
+	scores := []interface{}{10, "断货", 20, nil, "n/a", 35, "12abc", 40, 5, "缺货"}
+	for idx := 0; idx < 40; idx++ {
+		row := []interface{}{fmt.Sprintf("SKU%d", idx%4), idx%5 + 1, scores[idx%len(scores)]}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", idx+2), &row); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Sheet1", "J1", 3); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	criteria := [][2]string{
+		{"$A%d", "$B%d"},
+		{"$A%d", `">="&$J$1`},
+		{`"SKU*"`, "$B%d"},
+		{`"<>"`, `"<"&$J$1`},
+		{`"SKU?"`, `">2"`},
+	}
+	formulas := make(map[string]string)
+	for i := 0; i < 5; i++ {
+		row := i + 1
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("SKU%d", i%4), i + 1}); err != nil {
+			t.Fatalf("set criteria row: %v", err)
+		}
+		for j, c := range criteria {
+			col, _ := ColumnNumberToName(j + 3)
+			cell := fmt.Sprintf("%s%d", col, row)
+			c1, c2 := c[0], c[1]
+			if strings.Contains(c1, "%d") {
+				c1 = fmt.Sprintf(c1, row)
+			}
+			if strings.Contains(c2, "%d") {
+				c2 = fmt.Sprintf(c2, row)
+			}
+			formula := fmt.Sprintf("AVERAGEIFS(Data!$C:$C,Data!$A:$A,%s,Data!$B:$B,%s)", c1, c2)
+			if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			formulas["Sheet1!"+cell] = formula
+		}
+	}
+	// SKU4 没有匹配的数值
+	if err := f.SetCellValue("Sheet1", "A5", "SKU4"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+
+	patterns := f.groupAVERAGEIFSByPattern(formulas)
+	if len(patterns) != 1 {
+		t.Fatalf("expected 1 AVERAGEIFS pattern, got %d", len(patterns))
+	}
+	results := f.calculateAVERAGEIFS2DPattern(patterns[0])
+	for fullCell := range formulas {
+		cell := strings.TrimPrefix(fullCell, "Sheet1!")
+		expected, err := f.CalcCellValue("Sheet1", cell)
+		got, ok := results[fullCell]
+		if expected == formulaErrorDIV {
+			if ok {
+				t.Fatalf("%s: unexpected batch value %g without matching numbers", cell, got)
+			}
+			continue
+		}
+		if err != nil || !ok {
+			t.Fatalf("%s: missing batch value, want %q (%v)", cell, expected, err)
+		}
+		if want, _ := strconv.ParseFloat(expected, 64); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("%s: unexpected batch AVERAGEIFS value %g, want %s", cell, got, expected)
+		}
+	}
+	if _, ok := results["Sheet1!C5"]; ok {
+		t.Fatal("expected no batch value of Sheet1!C5 without matching rows")
+	}
+}
+
+func TestFullColumnSUMIFSHugeDimension(t *testing.T) {
+	f, expected := newHugeDimensionSUMIFSFile(t, 200)
+	t.Cleanup(func() { _ = f.Close() })
+
+	// 扫描和逐个单元格计算都只遍历有数据的行
+	if rows, err := f.getCachedRawRows("Data"); err != nil || len(rows) != 200 {
+		t.Fatalf("expected 200 rows to scan, got %d, %v", len(rows), err)
+	}
+	if valueRange := f.optimizeValueRange("Data", []int{1, TotalRows, 2, 2}); valueRange[1] != 200 {
+		t.Fatalf("expected the full column to be bounded to row 200, got %v", valueRange)
+	}
+	if got, err := f.CalcCellValue("Sheet1", "B1"); err != nil || got != expected["B1"] {
+		t.Fatalf("B1: CalcCellValue returned %q, %v, want %s", got, err, expected["B1"])
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != want {
+			t.Fatalf("%s: unexpected value %q, want %s", cell, got, want)
+		}
+	}
+}
+
+func BenchmarkFullColumnSUMIFSHugeDimension(b *testing.B) {
+	f, _ := newHugeDimensionSUMIFSFile(b, 3000)
+	b.Cleanup(func() { _ = f.Close() })
+	b.Run("Batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := f.RecalculateAllWithDependency(); err != nil {
+				b.Fatalf("recalculate: %v", err)
+			}
+		}
+	})
+	b.Run("CalcCellValue", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f.calcCache.Clear()
+			f.rangeCache.Clear()
+			if _, err := f.CalcCellValue("Sheet1", "B1"); err != nil {
+				b.Fatalf("calculate: %v", err)
+			}
+		}
+	})
+}
+
+func TestBatchSUMIFSCompositeErrorPropagation(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create data sheet: %v", err)
+	}
+	for i := 1; i <= 24; i++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{fmt.Sprintf("P%d", i%4), i}); err != nil {
+			t.Fatalf("set data row: %v", err)
+		}
+	}
+
+	// B 列的奇数行为错误值，SUMIFS 与其相加、相乘或作为 IF 条件的结果都应为该错误值
+	expected := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("P%d", row%4)); err != nil {
+			t.Fatalf("set criteria: %v", err)
+		}
+		sum := 0
+		for i := 1; i <= 24; i++ {
+			if i%4 == row%4 {
+				sum += i
+			}
+		}
+		if row%2 == 1 {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), "NA()"); err != nil {
+				t.Fatalf("set error formula: %v", err)
+			}
+			expected[fmt.Sprintf("C%d", row)] = formulaErrorNA
+			expected[fmt.Sprintf("D%d", row)] = formulaErrorNA
+			expected[fmt.Sprintf("E%d", row)] = formulaErrorNA
+		} else {
+			if err := f.SetCellValue("Sheet1", fmt.Sprintf("B%d", row), 2); err != nil {
+				t.Fatalf("set value: %v", err)
+			}
+			expected[fmt.Sprintf("C%d", row)] = strconv.Itoa(sum + 2)
+			expected[fmt.Sprintf("D%d", row)] = strconv.Itoa(sum * 2)
+			expected[fmt.Sprintf("E%d", row)] = "big"
+		}
+		sumifs := fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,$A%d)", row)
+		for col, formula := range map[string]string{
+			"C": fmt.Sprintf("%s+$B%d", sumifs, row),
+			"D": fmt.Sprintf("%s*$B%d", sumifs, row),
+			"E": fmt.Sprintf(`IF(%s>$B%d,"big","small")`, sumifs, row),
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range expected {
+		got, err := f.GetCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatalf("get %s: %v", cell, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %q, got %q", cell, want, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package excelize
+
+import (
+	"sort"
+	"strconv"
+)
+
+// sumifsWindow is the range-predicate index of a SUMIFS which bounds one
+// criteria column twice, like a date window
+// SUMIFS(Data!$C:$C,Data!$D:$D,">="&$F$1,Data!$D:$D,"<="&$G$1). The sums
+// of the numeric criteria values are sorted with their prefix sums, so a
+// window between a lower and an upper bound is summed with two binary
+// searches instead of matching every value.
+type sumifsWindow struct {
+	values map[string]float64 // the sums by the criteria values
+	keys   []float64          // the sorted numeric criteria values
+	prefix []float64          // prefix[i] is the sum of keys[:i]
+}
+
+// newSUMIFSWindow builds the range-predicate index from the result map of a
+// one-criteria SUMIFS over the bounded column. The blank criteria values are
+// left out like the scans of the two-criteria SUMIFS.
+func newSUMIFSWindow(values map[string]float64) *sumifsWindow {
+	type entry struct{ key, sum float64 }
+	entries := make([]entry, 0, len(values))
+	for key, sum := range values {
+		if num, err := strconv.ParseFloat(key, 64); err == nil {
+			entries = append(entries, entry{key: num, sum: sum})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	window := &sumifsWindow{
+		values: values,
+		keys:   make([]float64, len(entries)),
+		prefix: make([]float64, len(entries)+1),
+	}
+	for i, e := range entries {
+		window.keys[i], window.prefix[i+1] = e.key, window.prefix[i]+e.sum
+	}
+	return window
+}
+
+// sum returns the sum of the values whose criteria value meets both criteria.
+func (w *sumifsWindow) sum(criterion1, criterion2 sumifsCriterion) float64 {
+	if lower, upper, ok := sumifsWindowBounds(criterion1, criterion2); ok {
+		from := sort.Search(len(w.keys), func(i int) bool {
+			return compareSUMIFSCriterion(lower.operator, w.keys[i] < lower.number, w.keys[i] == lower.number)
+		})
+		to := sort.Search(len(w.keys), func(i int) bool {
+			return !compareSUMIFSCriterion(upper.operator, w.keys[i] < upper.number, w.keys[i] == upper.number)
+		})
+		if to <= from {
+			return 0
+		}
+		return w.prefix[to] - w.prefix[from]
+	}
+	// 其他条件组合逐个匹配同一列的值
+	var sum float64
+	for key, value := range w.values {
+		if key != "" && criterion1.match(key) && criterion2.match(key) {
+			sum += value
+		}
+	}
+	return sum
+}
+
+// sumifsWindowBounds returns the lower and the upper bound of a window from
+// two numeric comparisons in either order, it returns false if the criteria
+// don't bound a window.
+func sumifsWindowBounds(criterion1, criterion2 sumifsCriterion) (sumifsCriterion, sumifsCriterion, bool) {
+	isLower := func(c sumifsCriterion) bool { return c.numeric && (c.operator == ">" || c.operator == ">=") }
+	isUpper := func(c sumifsCriterion) bool { return c.numeric && (c.operator == "<" || c.operator == "<=") }
+	switch {
+	case isLower(criterion1) && isUpper(criterion2):
+		return criterion1, criterion2, true
+	case isUpper(criterion1) && isLower(criterion2):
+		return criterion2, criterion1, true
+	}
+	return sumifsCriterion{}, sumifsCriterion{}, false
+}
+
+// isSameSUMIFSColumn returns if two criteria ranges of a SUMIFS are the same
+// cells of one column.
+func isSameSUMIFSColumn(criteriaRange1, criteriaRange2 string) bool {
+	table1, ok1 := parseLookupTable(criteriaRange1, "")
+	table2, ok2 := parseLookupTable(criteriaRange2, "")
+	return ok1 && ok2 && table1.sheet != "" && table1.startCol == table1.endCol && table1 == table2
+}
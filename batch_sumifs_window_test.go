@@ -0,0 +1,142 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSUMIFSWindow(t *testing.T) {
+	window := newSUMIFSWindow(map[string]float64{"1": 10, "2": 20, "2.0": 5, "3": 30, "": 100, "x": 1000})
+	for _, c := range []struct {
+		criteria [2]string
+		want     float64
+	}{
+		{[2]string{">=2", "<=3"}, 55},
+		{[2]string{">2", "<=3"}, 30},
+		{[2]string{">=1", "<3"}, 35},
+		{[2]string{"<3", ">1"}, 25},
+		{[2]string{">3", "<1"}, 0},
+		{[2]string{">=0", "<=9"}, 65},
+		{[2]string{">=2", ">1"}, 55},
+		{[2]string{"<>", "<2"}, 10},
+		{[2]string{"x", ">=a"}, 1000},
+	} {
+		criteria, ok := parseSUMIFSCriteria(c.criteria[0], c.criteria[1])
+		if !ok {
+			t.Fatalf("parse criteria %q", c.criteria)
+		}
+		if got := window.sum(criteria[0], criteria[1]); got != c.want {
+			t.Fatalf("sum of %q = %g, want %g", c.criteria, got, c.want)
+		}
+	}
+	for criteria, want := range map[string][2]string{
+		`">="&$F$1`:           {">=", "$F$1"},
+		`"<" & G2`:            {"<", "G2"},
+		`"<="&45000`:          {"<=", "45000"},
+		`">="&F1&""`:          {},
+		`">="&F1:F2`:          {},
+		`">="&DATE(2023,1,1)`: {},
+		`">="`:                {},
+	} {
+		prefix, operand, ok := splitCriteriaConcat(criteria)
+		if ok != (want[0] != "") || prefix != want[0] || operand != want[1] {
+			t.Fatalf("splitCriteriaConcat(%q) = %q, %q, %t", criteria, prefix, operand, ok)
+		}
+	}
+	for refs, want := range map[[2]string]bool{
+		{"Data!$D:$D", "Data!D:D"}:             true,
+		{"Data!$D$2:$D$99", "Data!$D$2:$D$99"}: true,
+		{"Data!$D:$D", "Data!$E:$E"}:           false,
+		{"Data!$D:$D", "Other!$D:$D"}:          false,
+		{"Data!$D$2:$D$99", "Data!$D$3:$D$99"}: false,
+		{"Data!$D:$E", "Data!$D:$E"}:           false,
+	} {
+		if got := isSameSUMIFSColumn(refs[0], refs[1]); got != want {
+			t.Fatalf("isSameSUMIFSColumn(%q) = %t, want %t", refs, got, want)
+		}
+	}
+}
+
+func TestBatchSUMIFSDateWindow(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 60 天的每日数据，每隔 7 天有一个空白日期
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := f.SetSheetRow("Data", "A1", &[]interface{}{"Region", "Amount", "Date"}); err != nil {
+		t.Fatalf("set row: %v", err)
+	}
+	for day := 0; day < 60; day++ {
+		row := []interface{}{[]string{"East", "West"}[day%2], day + 1, start.AddDate(0, 0, day)}
+		if day%7 == 3 {
+			row[2] = nil
+		}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", day+2), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+
+	// 每行一个日期区间：起止日期、开闭区间和条件顺序各不相同
+	formulas := []string{
+		`SUMIFS(Data!$B:$B,Data!$C:$C,">="&$A%[1]d,Data!$C:$C,"<="&$B%[1]d)`,
+		`SUMIFS(Data!$B$2:$B$61,Data!$C$2:$C$61,">"&$A%[1]d,Data!$C$2:$C$61,"<"&$B%[1]d)`,
+		`SUMIFS(Data!$B:$B,Data!$C:$C,"<="&$B%[1]d,Data!$C:$C,">="&$A%[1]d)`,
+		`SUMIFS(Data!$B:$B,Data!$C:$C,">="&$A%[1]d,Data!$C:$C,">"&$B%[1]d)`,
+		`SUMIFS(Data!$B:$B,Data!$C:$C,">="&$A%[1]d,Data!$C:$C,"<="&$B%[1]d,Data!$A:$A,"East")`,
+	}
+	for row := 1; row <= 12; row++ {
+		from := start.AddDate(0, 0, row*3)
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{from, from.AddDate(0, 0, row*2)}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		for i, formula := range formulas {
+			cell, _ := CoordinatesToCellName(3+i, row)
+			if err := f.SetCellFormula("Sheet1", cell, fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+	want := make(map[string]string)
+	for row := 1; row <= 12; row++ {
+		for i := range formulas {
+			cell, _ := CoordinatesToCellName(3+i, row)
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[cell] = value
+		}
+	}
+	// 1 月 4 日到 1 月 6 日：第 4、6 天之和，1 月 7 日为空白日期
+	if want["C1"] != "11" || want["D1"] != "5" {
+		t.Fatalf("unexpected window sums C1 %s, D1 %s, want 11 and 5", want["C1"], want["D1"])
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+
+	// 修改区间的起始日期后增量重算
+	if err := f.SetCellValue("Sheet1", "A1", start); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "C1"); got != "17" {
+		t.Fatalf("unexpected C1 value %q, want 17", got)
+	}
+}
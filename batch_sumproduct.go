@@ -1,8 +1,6 @@
 package excelize
 
 import (
-	"log"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -196,7 +194,7 @@ func (f *File) calculateSUMPRODUCTPattern(pattern *sumproductMatchPattern) map[s
 
 	numCols := endColIdx - startColIdx + 1
 
-	log.Printf("  🔍 [SUMPRODUCT Batch] Processing %d formulas in sheet '%s', scanning columns %s-%s (%d columns)",
+	f.logger().Debugf("  🔍 [SUMPRODUCT Batch] Processing %d formulas in sheet '%s', scanning columns %s-%s (%d columns)",
 		len(pattern.formulas), sheet, pattern.startCol, pattern.endCol, numCols)
 
 	// Read all rows from the sheet
@@ -217,7 +215,7 @@ func (f *File) calculateSUMPRODUCTPattern(pattern *sumproductMatchPattern) map[s
 	var wg sync.WaitGroup
 
 	// Process rows in parallel
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	rowNums := make([]int, 0, len(pattern.formulas))
 	for _, info := range pattern.formulas {
 		rowNums = append(rowNums, info.row)
@@ -315,7 +313,7 @@ func (f *File) calculateSUMPRODUCTPattern(pattern *sumproductMatchPattern) map[s
 	}
 
 	duration := time.Since(startTime)
-	log.Printf("  ⚡ [SUMPRODUCT Batch] Completed %d formulas in %v (avg: %v/formula)",
+	f.logger().Debugf("  ⚡ [SUMPRODUCT Batch] Completed %d formulas in %v (avg: %v/formula)",
 		len(results), duration, duration/time.Duration(len(results)))
 
 	return results
@@ -0,0 +1,257 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sumproduct2DExpr represents a SUMPRODUCT used as a two-criteria SUMIFS,
+// e.g. SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$C)
+type sumproduct2DExpr struct {
+	sumRange       string
+	criteriaRanges [2]string
+	criteria       [2]string // cell references or literals
+}
+
+// parseSUMPRODUCT2D parses a SUMPRODUCT expression which is exactly two
+// (range=criteria) factors followed by the value range. The ranges must be
+// sheet qualified single columns of the same rows on one sheet, the criteria
+// cell references or string or number literals on the formula sheet.
+func parseSUMPRODUCT2D(expr string) (*sumproduct2DExpr, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "SUMPRODUCT(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "SUMPRODUCT")
+	if "SUMPRODUCT("+content+")" != expr || len(splitFunctionArgs(content)) != 1 {
+		return nil, false
+	}
+	factors := splitTopLevelProduct(content)
+	if len(factors) != 3 {
+		return nil, false
+	}
+	parsed := &sumproduct2DExpr{sumRange: factors[2]}
+	for i := 0; i < 2; i++ {
+		criteriaRange, criteria, ok := parseSUMPRODUCTEquality(factors[i])
+		if !ok {
+			return nil, false
+		}
+		parsed.criteriaRanges[i], parsed.criteria[i] = criteriaRange, criteria
+	}
+	sheet := ""
+	for _, ref := range []string{parsed.sumRange, parsed.criteriaRanges[0], parsed.criteriaRanges[1]} {
+		table, ok := parseLookupTable(ref, "")
+		if !ok || strings.ContainsAny(ref, "()") || (sheet != "" && table.sheet != sheet) {
+			return nil, false
+		}
+		sheet = table.sheet
+	}
+	if _, _, ok := sumifsSourceKey(parsed.sumRange, parsed.criteriaRanges[:]); !ok {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// splitTopLevelProduct splits an expression by the multiplications outside
+// of parentheses and quotes.
+func splitTopLevelProduct(s string) []string {
+	var factors []string
+	depth, inQuote, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			inQuote = !inQuote
+		case '(':
+			if !inQuote {
+				depth++
+			}
+		case ')':
+			if !inQuote {
+				depth--
+			}
+		case '*':
+			if !inQuote && depth == 0 {
+				factors = append(factors, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(factors, strings.TrimSpace(s[start:]))
+}
+
+// parseSUMPRODUCTEquality parses a (range=criteria) factor of a SUMPRODUCT.
+func parseSUMPRODUCTEquality(factor string) (string, string, bool) {
+	if len(factor) < 2 || factor[0] != '(' || factor[len(factor)-1] != ')' {
+		return "", "", false
+	}
+	inner := factor[1 : len(factor)-1]
+	inQuote, eq := false, -1
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '"', '\'':
+			inQuote = !inQuote
+		case '(', ')':
+			// 括号内只允许范围和条件
+			if !inQuote {
+				return "", "", false
+			}
+		case '<', '>':
+			if !inQuote {
+				return "", "", false
+			}
+		case '=':
+			if !inQuote {
+				if eq != -1 {
+					return "", "", false
+				}
+				eq = i
+			}
+		}
+	}
+	if eq == -1 || inQuote {
+		return "", "", false
+	}
+	criteriaRange, criteria := strings.TrimSpace(inner[:eq]), strings.TrimSpace(inner[eq+1:])
+	if !strings.Contains(criteriaRange, "!") || !isSUMPRODUCTCriteria(criteria) {
+		return "", "", false
+	}
+	return criteriaRange, criteria, true
+}
+
+// isSUMPRODUCTCriteria returns if a criteria is a local cell reference or a
+// literal which resolveCriteriaValue resolves.
+func isSUMPRODUCTCriteria(criteria string) bool {
+	if len(criteria) >= 2 && criteria[0] == '"' && criteria[len(criteria)-1] == '"' {
+		return !strings.Contains(criteria[1:len(criteria)-1], `"`)
+	}
+	if criteria == "" || strings.ContainsAny(criteria, "!:") {
+		return false
+	}
+	if criteria[0] >= '0' && criteria[0] <= '9' {
+		_, err := strconv.ParseFloat(criteria, 64)
+		return err == nil
+	}
+	_, _, err := CellNameToCoordinates(strings.ReplaceAll(criteria, "$", ""))
+	return err == nil
+}
+
+// isSUMPRODUCT2DFormula reports whether the formula is a SUMPRODUCT used as a
+// two-criteria SUMIFS which can be calculated in batch
+func isSUMPRODUCT2DFormula(formula string) bool {
+	_, ok := parseSUMPRODUCT2D(strings.TrimPrefix(strings.TrimSpace(formula), "="))
+	return ok
+}
+
+// extractSUMPRODUCT2DPattern extracts the 2D pattern from a SUMPRODUCT used
+// as a two-criteria SUMIFS, SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$C)
+// maps onto the pattern of SUMIFS(Data!$C:$C,Data!$A:$A,$A2,Data!$B:$B,C$1)
+func (f *File) extractSUMPRODUCT2DPattern(sheet, cell, formula string) *sumifs2DPattern {
+	parsed, ok := parseSUMPRODUCT2D(strings.TrimPrefix(strings.TrimSpace(formula), "="))
+	if !ok {
+		return nil
+	}
+	return &sumifs2DPattern{
+		sumRangeRef:       parsed.sumRange,
+		criteriaRange1Ref: parsed.criteriaRanges[0],
+		criteriaRange2Ref: parsed.criteriaRanges[1],
+		formulas: map[string]*sumifs2DFormula{
+			sheet + "!" + cell: {
+				cell:          cell,
+				sheet:         sheet,
+				criteria1Cell: parsed.criteria[0],
+				criteria2Cell: parsed.criteria[1],
+			},
+		},
+	}
+}
+
+// groupSUMPRODUCT2DByPattern groups the two-criteria SUMPRODUCT formulas by
+// their value and criteria ranges
+func (f *File) groupSUMPRODUCT2DByPattern(formulas map[string]string) []*sumifs2DPattern {
+	patterns := make(map[string]*sumifs2DPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		pattern := f.extractSUMPRODUCT2DPattern(sheet, cell, formula)
+		if pattern == nil {
+			continue
+		}
+		key := pattern.sumRangeRef + "|" + pattern.criteriaRange1Ref + "|" + pattern.criteriaRange2Ref
+		if existing, exists := patterns[key]; exists {
+			for c, info := range pattern.formulas {
+				existing.formulas[c] = info
+			}
+			continue
+		}
+		patterns[key] = pattern
+	}
+	result := make([]*sumifs2DPattern, 0, len(patterns))
+	for _, p := range patterns {
+		result = append(result, p)
+	}
+	return result
+}
+
+// calculateSUMPRODUCT2DPatternWithCache calculates the two-criteria
+// SUMPRODUCT formulas of a pattern with the result map of the SUMIFS 2D
+// pattern. The criteria are compared with the values of the criteria ranges
+// as they are, without the wildcards and the comparison operators of SUMIFS,
+// and the blank cells of the value range count as 0 like Excel. A text in the
+// value range makes the products #VALUE!, so the pattern and the formulas
+// with an empty criteria are left to the cell by cell calculation.
+func (f *File) calculateSUMPRODUCT2DPatternWithCache(pattern *sumifs2DPattern, worksheetCache *WorksheetCache) map[string]float64 {
+	results := make(map[string]float64)
+	sourceSheet := extractSheetName(pattern.sumRangeRef)
+	_, span, ok := sumifsSourceKey(pattern.ranges())
+	if sourceSheet == "" || !ok {
+		return results
+	}
+	sumCol := extractColumnFromRange(pattern.sumRangeRef)
+	criteria1Col := extractColumnFromRange(pattern.criteriaRange1Ref)
+	criteria2Col := extractColumnFromRange(pattern.criteriaRange2Ref)
+	sumColIdx, err := ColumnNameToNumber(sumCol)
+	if err != nil || criteria1Col == "" || criteria2Col == "" {
+		return results
+	}
+	rows, err := f.getCachedRawRows(sourceSheet)
+	if err != nil {
+		return results
+	}
+	rows = rowsInSpan(rows, span)
+	for _, row := range rows {
+		if sumColIdx > len(row) || row[sumColIdx-1] == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(row[sumColIdx-1], 64); err != nil {
+			f.logger().Debugf("  ⚠️  [SUMPRODUCT 2D Batch] Text %q in %s, skipping %d formulas", row[sumColIdx-1], pattern.sumRangeRef, len(pattern.formulas))
+			return results
+		}
+	}
+
+	resultMap := f.scanRowsAndBuildResultMap(sourceSheet, rows, sumCol, criteria1Col, criteria2Col)
+	for fullCell, info := range pattern.formulas {
+		c1 := f.resolveCriteriaValue(info.sheet, strings.ReplaceAll(info.criteria1Cell, "$", ""), worksheetCache)
+		c2 := f.resolveCriteriaValue(info.sheet, strings.ReplaceAll(info.criteria2Cell, "$", ""), worksheetCache)
+		if c1 == "" || c2 == "" {
+			continue
+		}
+		results[fullCell] = resultMap[c1][c2]
+	}
+	return results
+}
+
+// batchCalculateSUMPRODUCT2DWithCache calculates the two-criteria SUMPRODUCT
+// formulas in batch, it returns the results and the number of patterns.
+func (f *File) batchCalculateSUMPRODUCT2DWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupSUMPRODUCT2DByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateSUMPRODUCT2DPatternWithCache(pattern, worksheetCache) {
+			results[cell] = formatFloat(value)
+		}
+	}
+	f.logger().Debugf("  ⚡ [SUMPRODUCT 2D Batch] %d SUMPRODUCT formulas over %d distinct patterns", len(results), len(patterns))
+	return results, len(patterns)
+}
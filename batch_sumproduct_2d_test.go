@@ -0,0 +1,151 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestExtractSUMPRODUCT2DPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for formula, want := range map[string]bool{
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$C)":                  true,
+		"=SUMPRODUCT((Data!$A:$A=$A2) * (Data!$B:$B=C$1) * Data!$C:$C)":             true,
+		`SUMPRODUCT((Data!$A$2:$A$99=$A2)*(Data!$B$2:$B$99="x")*Data!$C$2:$C$99)`:   true,
+		"SUMPRODUCT(('My Data'!$A:$A=$A2)*('My Data'!$B:$B=3)*'My Data'!$C:$C)":     true,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$D)":                  false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Other!$B:$B=C$1)*Data!$C:$C)":                 false,
+		"SUMPRODUCT((Data!$A$2:$A$99=$A2)*(Data!$B$1:$B$98=C$1)*Data!$C$2:$C$99)":   false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*Data!$C:$C)":                                   false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*(Data!$D:$D=D$1)*Data!$C:$C)": false,
+		"SUMPRODUCT((Data!$A:$A>$A2)*(Data!$B:$B=C$1)*Data!$C:$C)":                  false,
+		"SUMPRODUCT((Data!$A:$A<>$A2)*(Data!$B:$B=C$1)*Data!$C:$C)":                 false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1),Data!$C:$C)":                  false,
+		"SUMPRODUCT((Data!$A:$A=TRIM($A2))*(Data!$B:$B=C$1)*Data!$C:$C)":            false,
+		"SUMPRODUCT((Data!$A:$A=Other!$A2)*(Data!$B:$B=C$1)*Data!$C:$C)":            false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$C)*2":                false,
+		"SUMPRODUCT(($A:$A=$A2)*($B:$B=C$1)*$C:$C)":                                 false,
+		"SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*(Data!$C:$C))":                false,
+		"SUMPRODUCT(($A2=Data!$A:$A)*(Data!$B:$B=C$1)*Data!$C:$C)":                  false,
+		"SUMIFS(Data!$C:$C,Data!$A:$A,$A2,Data!$B:$B,C$1)":                          false,
+	} {
+		if got := f.extractSUMPRODUCT2DPattern("Sheet1", "B2", formula) != nil; got != want {
+			t.Fatalf("extractSUMPRODUCT2DPattern(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	pattern := f.extractSUMPRODUCT2DPattern("Sheet1", "B2", `SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B="x")*Data!$C:$C)`)
+	info := pattern.formulas["Sheet1!B2"]
+	if pattern.sumRangeRef != "Data!$C:$C" || pattern.criteriaRange1Ref != "Data!$A:$A" || pattern.criteriaRange2Ref != "Data!$B:$B" ||
+		info.criteria1Cell != "$A2" || info.criteria2Cell != `"x"` {
+		t.Fatalf("unexpected pattern %+v, formula %+v", pattern, info)
+	}
+}
+
+func TestBatchCalculateSUMPRODUCT2D(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"Region", "Month", "Amount"},
+		{"East", 1, 10},
+		{"East", 1, 5},
+		{"East", 2, 20},
+		{"West", 1, 7},
+		{"West", 3},
+		{"South", 1, 100},
+		{"North", 2, 2.5},
+		{nil, 1, 1000},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	for i, month := range []int{1, 2, 3} {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("%c1", 'B'+i), month); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	regions := []string{"East", "West", "North", "South", ""}
+	for i, region := range regions {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), region); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for _, col := range []string{"B", "C", "D"} {
+			formula := fmt.Sprintf("SUMPRODUCT((Data!$A$2:$A$20=$A%d)*(Data!$B$2:$B$20=%s$1)*Data!$C$2:$C$20)", row, col)
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+		// 整列引用包含标题文本，结果为 #VALUE!，逐个单元格计算
+		formula := fmt.Sprintf("SUMPRODUCT((Data!$A:$A=$A%d)*(Data!$B:$B=1)*Data!$C:$C)", row)
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("E%d", row), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 批量结果与等价的 SUMIFS 一致，空白单元格按 0 计算；空条件和 #VALUE! 逐个单元格计算
+	want := make(map[string]string)
+	for i, region := range regions {
+		for _, col := range []string{"B", "C", "D", "E"} {
+			cell := fmt.Sprintf("%s%d", col, i+2)
+			if region != "" && col != "E" {
+				sumifs := fmt.Sprintf("SUMIFS(Data!$C$2:$C$20,Data!$A$2:$A$20,$A%d,Data!$B$2:$B$20,%s$1)", i+2, col)
+				if err := f.SetCellFormula("Sheet1", "H1", sumifs); err != nil {
+					t.Fatalf("set formula: %v", err)
+				}
+				cell = "H1"
+			}
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorVALUE {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[fmt.Sprintf("%s%d", col, i+2)] = value
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "H1", ""); err != nil {
+		t.Fatalf("remove formula: %v", err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{
+		"B2": "15", "C2": "20", "D2": "0", "B3": "7", "D3": "0", "C4": "2.5", "B5": "100",
+		"E2": formulaErrorVALUE,
+	} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.SUMPRODUCTFormulas != 4*len(regions) || plan.SUMPRODUCTPatterns != 2 {
+		t.Fatalf("unexpected plan SUMPRODUCT formulas %d, patterns %d", plan.SUMPRODUCTFormulas, plan.SUMPRODUCTPatterns)
+	}
+
+	// 修改数据后增量重算使用批量结果
+	if err := f.SetCellValue("Data", "C3", 50); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Data!C3": true}); err != nil {
+		t.Fatalf("recalculate affected cells: %v", err)
+	}
+	if got, _ := f.GetCellValue("Sheet1", "B2"); got != "60" {
+		t.Fatalf("unexpected B2 value %q, want 60", got)
+	}
+}
@@ -0,0 +1,286 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sumproductConditionsExpr represents a SUMPRODUCT multiplying conditions on
+// column ranges with a value range, used as a multi-criteria SUMIFS, e.g.
+// SUMPRODUCT((Data!$A:$A="X")*(Data!$B:$B>0)*Data!$C:$C) or
+// SUMPRODUCT(--(Data!$A:$A=$A2),--(Data!$B:$B>=C$1),Data!$C:$C)
+type sumproductConditionsExpr struct {
+	sumRange       string
+	criteriaRanges []string
+	operators      []string // =, <, <=, > or >= of each condition
+	criteria       []string // cell references or literals
+}
+
+// sumproductConditionsPattern groups the SUMPRODUCT formulas with the same
+// value range and conditions, the criteria of each formula in the order of
+// the conditions
+type sumproductConditionsPattern struct {
+	sumRangeRef       string
+	criteriaRangeRefs []string
+	operators         []string
+	formulas          map[string]*sumifsNDFormula
+}
+
+// parseSUMPRODUCTConditions parses a SUMPRODUCT expression whose arguments
+// are the products of (range op criteria) conditions and a single value
+// range. A condition given as an argument on its own must be coerced with
+// --, as Excel treats the logical values of an argument as 0. The ranges must
+// be single columns of the same rows on one sheet, the sheet of an unqualified
+// range defaults to currentSheet. The two equality conditions form is left to
+// parseSUMPRODUCT2D.
+func parseSUMPRODUCTConditions(expr, currentSheet string) (*sumproductConditionsExpr, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "SUMPRODUCT(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "SUMPRODUCT")
+	if "SUMPRODUCT("+content+")" != expr {
+		return nil, false
+	}
+	if _, ok := parseSUMPRODUCT2D(expr); ok {
+		return nil, false
+	}
+	qualify := func(ref string) string {
+		if strings.Contains(ref, "!") {
+			return ref
+		}
+		return currentSheet + "!" + ref
+	}
+	parsed := &sumproductConditionsExpr{}
+	for _, arg := range splitFunctionArgs(content) {
+		factors := splitTopLevelProduct(arg)
+		for _, factor := range factors {
+			coerced := strings.HasPrefix(factor, "--(")
+			factor = strings.TrimPrefix(factor, "--")
+			if !strings.HasPrefix(factor, "(") {
+				if parsed.sumRange != "" || strings.ContainsAny(factor, "()\"") {
+					return nil, false
+				}
+				parsed.sumRange = qualify(factor)
+				continue
+			}
+			// 单独作为参数的条件需要 -- 转换为数值
+			if len(factors) == 1 && !coerced {
+				return nil, false
+			}
+			criteriaRange, operator, criteria, ok := parseSUMPRODUCTCondition(factor)
+			if !ok {
+				return nil, false
+			}
+			parsed.criteriaRanges = append(parsed.criteriaRanges, qualify(criteriaRange))
+			parsed.operators = append(parsed.operators, operator)
+			parsed.criteria = append(parsed.criteria, criteria)
+		}
+	}
+	if parsed.sumRange == "" || len(parsed.criteriaRanges) == 0 {
+		return nil, false
+	}
+	sheet := extractSheetName(parsed.sumRange)
+	for _, ref := range parsed.criteriaRanges {
+		if extractSheetName(ref) != sheet {
+			return nil, false
+		}
+	}
+	if _, _, ok := sumifsSourceKey(parsed.sumRange, parsed.criteriaRanges); !ok {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// parseSUMPRODUCTCondition parses a (range op criteria) condition of a
+// SUMPRODUCT, the <> conditions match the blank cells and are not supported.
+func parseSUMPRODUCTCondition(factor string) (string, string, string, bool) {
+	if len(factor) < 2 || factor[0] != '(' || factor[len(factor)-1] != ')' {
+		return "", "", "", false
+	}
+	inner := factor[1 : len(factor)-1]
+	inQuote, opStart, opEnd := false, -1, -1
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case c == '"' || c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(' || c == ')':
+			return "", "", "", false
+		case c == '<' || c == '>' || c == '=':
+			if opStart != -1 && opEnd != i {
+				return "", "", "", false
+			}
+			if opStart == -1 {
+				opStart = i
+			}
+			opEnd = i + 1
+		}
+	}
+	if opStart == -1 || inQuote {
+		return "", "", "", false
+	}
+	operator := inner[opStart:opEnd]
+	switch operator {
+	case "=", "<", "<=", ">", ">=":
+	default:
+		return "", "", "", false
+	}
+	criteriaRange, criteria := strings.TrimSpace(inner[:opStart]), strings.TrimSpace(inner[opEnd:])
+	if _, _, err := CellNameToCoordinates(strings.ReplaceAll(criteriaRange, "$", "")); err == nil {
+		return "", "", "", false
+	}
+	if !isSUMPRODUCTCriteria(criteria) {
+		return "", "", "", false
+	}
+	return criteriaRange, operator, criteria, true
+}
+
+// isSUMPRODUCTConditionsFormula reports whether the formula is a SUMPRODUCT
+// of conditions and a value range which can be calculated in batch, the
+// unqualified ranges are checked on a stand-in sheet of the formula.
+func isSUMPRODUCTConditionsFormula(formula string) bool {
+	_, ok := parseSUMPRODUCTConditions(strings.TrimPrefix(strings.TrimSpace(formula), "="), "Sheet1")
+	return ok
+}
+
+// key returns the grouping key of the pattern, it includes the value range
+// and all conditions but the criteria
+func (p *sumproductConditionsPattern) key() string {
+	return p.sumRangeRef + "|" + strings.Join(p.criteriaRangeRefs, "|") + "|" + strings.Join(p.operators, "|")
+}
+
+// groupSUMPRODUCTConditionsByPattern groups the SUMPRODUCT formulas of
+// conditions by their value range and conditions
+func (f *File) groupSUMPRODUCTConditionsByPattern(formulas map[string]string) []*sumproductConditionsPattern {
+	patterns := make(map[string]*sumproductConditionsPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		parsed, ok := parseSUMPRODUCTConditions(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+		if !ok {
+			continue
+		}
+		pattern := &sumproductConditionsPattern{
+			sumRangeRef:       parsed.sumRange,
+			criteriaRangeRefs: parsed.criteriaRanges,
+			operators:         parsed.operators,
+		}
+		if existing, exists := patterns[pattern.key()]; exists {
+			pattern = existing
+		} else {
+			pattern.formulas = make(map[string]*sumifsNDFormula)
+			patterns[pattern.key()] = pattern
+		}
+		pattern.formulas[fullCell] = &sumifsNDFormula{cell: cell, sheet: sheet, criteriaCells: parsed.criteria}
+	}
+	result := make([]*sumproductConditionsPattern, 0, len(patterns))
+	for _, p := range patterns {
+		result = append(result, p)
+	}
+	return result
+}
+
+// calculateSUMPRODUCTConditionsPatternWithCache calculates the SUMPRODUCT
+// formulas of conditions of a pattern with the single scan of the N-criteria
+// SUMIFS. The conditions compare the values as they are, without the
+// wildcards of SUMIFS and case-sensitively like the regular evaluation, the
+// formulas whose criteria is empty, has a wildcard or an operator are left to
+// the cell by cell calculation. The comparisons treat the blank cells as 0
+// and the text differently from SUMIFS, so the formulas comparing a column
+// with a blank or a text next to a non-zero value are left too, as well as the
+// pattern with a text in the value range, which makes the products #VALUE!.
+func (f *File) calculateSUMPRODUCTConditionsPatternWithCache(pattern *sumproductConditionsPattern, worksheetCache *WorksheetCache) map[string]float64 {
+	results := make(map[string]float64)
+	sourceSheet := extractSheetName(pattern.sumRangeRef)
+	_, span, ok := sumifsSourceKey(pattern.sumRangeRef, pattern.criteriaRangeRefs)
+	if sourceSheet == "" || !ok {
+		return results
+	}
+	sumCol := extractColumnFromRange(pattern.sumRangeRef)
+	sumColIdx, err := ColumnNameToNumber(sumCol)
+	if err != nil {
+		return results
+	}
+	criteriaCols := make([]string, len(pattern.criteriaRangeRefs))
+	criteriaColIdx := make([]int, len(criteriaCols))
+	for i, ref := range pattern.criteriaRangeRefs {
+		criteriaCols[i] = extractColumnFromRange(ref)
+		if criteriaColIdx[i], err = ColumnNameToNumber(criteriaCols[i]); err != nil {
+			return results
+		}
+	}
+	rows, err := f.getCachedRawRows(sourceSheet)
+	if err != nil {
+		return results
+	}
+	rows = rowsInSpan(mergeSheetCacheIntoRows(rows, worksheetCache.GetSheet(sourceSheet)), span)
+
+	// 统计每个条件列在非零值的行中是否有空白单元格或文本
+	notNumeric := make([]bool, len(criteriaCols))
+	for _, row := range rows {
+		if sumColIdx > len(row) || row[sumColIdx-1] == "" {
+			continue
+		}
+		num, err := strconv.ParseFloat(row[sumColIdx-1], 64)
+		if err != nil {
+			f.logger().Debugf("  ⚠️  [SUMPRODUCT Conditions Batch] Text %q in %s, skipping %d formulas", row[sumColIdx-1], pattern.sumRangeRef, len(pattern.formulas))
+			return results
+		}
+		if num == 0 {
+			continue
+		}
+		for i, idx := range criteriaColIdx {
+			if idx > len(row) {
+				notNumeric[i] = true
+				continue
+			}
+			if _, err := strconv.ParseFloat(row[idx-1], 64); err != nil {
+				notNumeric[i] = true
+			}
+		}
+	}
+
+	resultMap := scanRowsAndBuildNDResultMap(rows, sumCol, criteriaCols)
+	for fullCell, info := range pattern.formulas {
+		criteria := make([]sumifsCriterion, len(info.criteriaCells))
+		matched := true
+		for i, criteriaCell := range info.criteriaCells {
+			value := f.resolveCriteriaValue(info.sheet, strings.ReplaceAll(criteriaCell, "$", ""), worksheetCache)
+			if operator := pattern.operators[i]; operator == "=" {
+				matched = value != "" && !hasSUMIFSWildcard(value) && !strings.ContainsAny(value[:1], "<>=")
+			} else {
+				_, err := strconv.ParseFloat(value, 64)
+				matched = err == nil && !notNumeric[i]
+				value = operator + value
+			}
+			if matched {
+				criteria[i], matched = parseSUMIFSCriterion(value)
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			results[fullCell] = sumifsNDSum(resultMap, criteria)
+		}
+	}
+	return results
+}
+
+// batchCalculateSUMPRODUCTConditionsWithCache calculates the SUMPRODUCT
+// formulas of conditions in batch, it returns the results and the number of
+// patterns.
+func (f *File) batchCalculateSUMPRODUCTConditionsWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupSUMPRODUCTConditionsByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateSUMPRODUCTConditionsPatternWithCache(pattern, worksheetCache) {
+			results[cell] = formatFloat(value)
+		}
+	}
+	f.logger().Debugf("  ⚡ [SUMPRODUCT Conditions Batch] %d SUMPRODUCT formulas over %d distinct patterns", len(results), len(patterns))
+	return results, len(patterns)
+}
@@ -0,0 +1,179 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestParseSUMPRODUCTConditions(t *testing.T) {
+	for formula, want := range map[string]bool{
+		`SUMPRODUCT((A:A="X")*(B:B>0)*C:C)`:                                                 true,
+		`=SUMPRODUCT((Data!$A:$A=$A2) * (Data!$B:$B>=C$1) * Data!$C:$C)`:                    true,
+		`SUMPRODUCT(--(Data!$A:$A=$A2),--(Data!$B:$B<5),Data!$C:$C)`:                        true,
+		`SUMPRODUCT(--(Data!$A:$A=$A2),(Data!$B:$B<5)*Data!$C:$C)`:                          true,
+		`SUMPRODUCT((Data!$A:$A=$A2)*Data!$C:$C)`:                                           true,
+		`SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*(Data!$D:$D=D$1)*Data!$C:$C)`:         true,
+		`SUMPRODUCT(Data!$C:$C*(Data!$A:$A="x"))`:                                           true,
+		`SUMPRODUCT(('My Data'!$A$2:$A$9=$A2)*('My Data'!$B$2:$B$9>2)*'My Data'!$C$2:$C$9)`: true,
+		`SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B=C$1)*Data!$C:$C)`:                          false,
+		`SUMPRODUCT((Data!$A:$A=$A2),Data!$C:$C)`:                                           false,
+		`SUMPRODUCT((Data!$A:$A<>$A2)*Data!$C:$C)`:                                          false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B>0))`:                                       false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*Data!$C:$C*Data!$D:$D)`:                                false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*Data!$C:$D)`:                                           false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*Other!$C:$C)`:                                          false,
+		`SUMPRODUCT((Data!$A$2:$A$9=$A2)*Data!$C$1:$C$8)`:                                   false,
+		`SUMPRODUCT((Data!$A:$A=TRIM($A2))*Data!$C:$C)`:                                     false,
+		`SUMPRODUCT(($A2=Data!$A:$A)*Data!$C:$C)`:                                           false,
+		`SUMPRODUCT((Data!$A:$A=Other!$A2)*Data!$C:$C)`:                                     false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*Data!$C:$C)*2`:                                         false,
+		`SUMPRODUCT((Data!$A:$A=$A2)*2)`:                                                    false,
+		`SUMIFS(Data!$C:$C,Data!$A:$A,$A2)`:                                                 false,
+	} {
+		if got := isSUMPRODUCTConditionsFormula(formula); got != want {
+			t.Fatalf("isSUMPRODUCTConditionsFormula(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	expr, ok := parseSUMPRODUCTConditions(`SUMPRODUCT(--(A2:A9="x"),--($B2:$B9>=C$1),C2:C9)`, "Sheet1")
+	if !ok || expr.sumRange != "Sheet1!C2:C9" || fmt.Sprint(expr.criteriaRanges) != "[Sheet1!A2:A9 Sheet1!$B2:$B9]" ||
+		fmt.Sprint(expr.operators) != "[= >=]" || fmt.Sprint(expr.criteria) != `["x" C$1]` {
+		t.Fatalf("unexpected expression %+v", expr)
+	}
+}
+
+func TestBatchCalculateSUMPRODUCTConditions(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"Region", "Quantity", "Amount"},
+		{"East", 5, 10},
+		{"East", -1, 5},
+		{"East", 3, 20},
+		{"West", 3, 7},
+		{"West", 0, 100},
+		{"South", 8, 2.5},
+		{"North", 2, 40},
+		{nil, 6, 30},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	for cell, value := range map[string]int{"B1": 0, "C1": 3} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	// 乘积和 -- 两种写法，以及等价的 SUMIFS
+	formulas := map[string][2]string{
+		"B": {`SUMPRODUCT((Data!$A$2:$A$20=$A%[1]d)*(Data!$B$2:$B$20>B$1)*Data!$C$2:$C$20)`,
+			`SUMIFS(Data!$C$2:$C$20,Data!$A$2:$A$20,$A%[1]d,Data!$B$2:$B$20,">"&B$1)`},
+		"C": {`SUMPRODUCT(--(Data!$A$2:$A$20=$A%[1]d),--(Data!$B$2:$B$20<=C$1),Data!$C$2:$C$20)`,
+			`SUMIFS(Data!$C$2:$C$20,Data!$A$2:$A$20,$A%[1]d,Data!$B$2:$B$20,"<="&C$1)`},
+		"D": {`SUMPRODUCT((Data!$A$2:$A$20=$A%[1]d)*Data!$C$2:$C$20)`,
+			`SUMIFS(Data!$C$2:$C$20,Data!$A$2:$A$20,$A%[1]d)`},
+	}
+	regions := []string{"East", "West", "South", "North", ""}
+	for i, region := range regions {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), region); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for col, formula := range formulas {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula[0], row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 批量结果与等价的 SUMIFS 一致，空条件逐个单元格计算
+	want := make(map[string]string)
+	for i, region := range regions {
+		row := i + 2
+		for col, formula := range formulas {
+			cell := fmt.Sprintf("%s%d", col, row)
+			if region != "" {
+				if err := f.SetCellFormula("Sheet1", "H1", fmt.Sprintf(formula[1], row)); err != nil {
+					t.Fatalf("set formula: %v", err)
+				}
+				cell = "H1"
+			}
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorVALUE {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[fmt.Sprintf("%s%d", col, row)] = value
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "H1", ""); err != nil {
+		t.Fatalf("remove formula: %v", err)
+	}
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{"B2": "30", "C2": "25", "D2": "35", "B3": "7", "C3": "107", "B5": "40", "D6": formulaErrorVALUE} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.SUMPRODUCTFormulas != 3*len(regions) || plan.SUMPRODUCTPatterns != 3 {
+		t.Fatalf("unexpected plan SUMPRODUCT formulas %d, patterns %d", plan.SUMPRODUCTFormulas, plan.SUMPRODUCTPatterns)
+	}
+	optimizers := make(map[string]string)
+	for _, entry := range f.CalcAuditTrail() {
+		optimizers[entry.Cell] = entry.Optimizer
+	}
+	if optimizers["Sheet1!B2"] != "SUMPRODUCT" || optimizers["Sheet1!C3"] != "SUMPRODUCT" || optimizers["Sheet1!D6"] != "cell" {
+		t.Fatalf("unexpected optimizers %v", optimizers)
+	}
+
+	// 通配符和运算符按原值比较，逐个单元格计算
+	results, _ := f.batchCalculateSUMPRODUCTConditionsWithCache(map[string]string{
+		"Sheet1!F1": `SUMPRODUCT((Data!$A$2:$A$20="Ea*")*Data!$C$2:$C$20)`,
+		"Sheet1!F2": `SUMPRODUCT((Data!$A$2:$A$20=">A")*Data!$C$2:$C$20)`,
+		"Sheet1!F3": `SUMPRODUCT((Data!$B$2:$B$20>"x")*Data!$C$2:$C$20)`,
+		"Sheet1!F4": `SUMPRODUCT((Data!$B$2:$B$20>=3)*Data!$C$2:$C$20)`,
+	}, NewWorksheetCache())
+	if len(results) != 1 || results["Sheet1!F4"] != "69.5" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	// 比较的列中非零值旁的空白单元格按 0 比较，值范围中的文本使乘积为 #VALUE!
+	if err := f.SetCellValue("Data", "C11", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	results, _ = f.batchCalculateSUMPRODUCTConditionsWithCache(map[string]string{
+		"Sheet1!F1": `SUMPRODUCT((Data!$B$2:$B$20<3)*Data!$C$2:$C$20)`,
+		"Sheet1!F2": `SUMPRODUCT((Data!$A$2:$A$20="East")*Data!$C$2:$C$20)`,
+	}, NewWorksheetCache())
+	if len(results) != 1 || results["Sheet1!F2"] != "35" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if err := f.SetCellValue("Data", "C12", "n/a"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	results, _ = f.batchCalculateSUMPRODUCTConditionsWithCache(map[string]string{
+		"Sheet1!F2": `SUMPRODUCT((Data!$A$2:$A$20="East")*Data!$C$2:$C$20)`,
+	}, NewWorksheetCache())
+	if len(results) != 0 {
+		t.Fatalf("unexpected results %v", results)
+	}
+}
@@ -0,0 +1,181 @@
+package excelize
+
+import (
+	"context"
+	"strings"
+)
+
+// dependencyTracer walks the dependencies of the incremental index from a
+// single cell. The column range dependencies are expanded to the cells of the
+// column which exist in the worksheet.
+type dependencyTracer struct {
+	f       *File
+	index   *incrementalIndex
+	names   definedNameRefs
+	columns map[string][]string // COLUMN:Sheet!Col -> existing cells
+	scanned map[string]bool     // sheets whose columns are collected
+}
+
+// precedents returns the sorted cells read by a formula directly, it returns
+// nil for the cells without a formula.
+func (t *dependencyTracer) precedents(cell string) []string {
+	formula, ok := t.index.formulaMap[cell]
+	if !ok {
+		return nil
+	}
+	sheet, cellRef, _ := strings.Cut(cell, "!")
+	seen := make(map[string]bool)
+	for _, dep := range extractDependenciesOptimized(t.names.expand(formula, sheet), sheet, cellRef, nil, t.index.columnMetadata) {
+		if strings.HasPrefix(dep, "COLUMN:") {
+			for _, colCell := range t.columnCells(dep) {
+				seen[colCell] = true
+			}
+			continue
+		}
+		seen[dep] = true
+	}
+	delete(seen, cell)
+	return sortedCells(seen)
+}
+
+// columnCells returns the cells with a value or a formula of a column
+// dependency, like "COLUMN:Sheet1!A".
+func (t *dependencyTracer) columnCells(colDep string) []string {
+	sheet, _, _ := strings.Cut(strings.TrimPrefix(colDep, "COLUMN:"), "!")
+	if !t.scanned[sheet] {
+		t.scanned[sheet] = true
+		ws, err := t.f.workSheetReader(sheet)
+		if err != nil || ws == nil {
+			return nil
+		}
+		for _, row := range ws.SheetData.Row {
+			for _, c := range row.C {
+				if c.F == nil && c.V == "" && c.IS == nil {
+					continue
+				}
+				col, _, err := CellNameToCoordinates(c.R)
+				if err != nil {
+					continue
+				}
+				colName, _ := ColumnNumberToName(col)
+				key := "COLUMN:" + sheet + "!" + colName
+				t.columns[key] = append(t.columns[key], sheet+"!"+c.R)
+			}
+		}
+	}
+	return t.columns[colDep]
+}
+
+// order returns the cells in calculation order: each cell follows the cells
+// of the set it reads. The cells of a circular reference are ordered by
+// name.
+func (t *dependencyTracer) order(cells map[string]bool) []string {
+	ordered := make([]string, 0, len(cells))
+	visited := make(map[string]bool, len(cells))
+	var visit func(cell string)
+	visit = func(cell string) {
+		visited[cell] = true
+		for _, dep := range t.precedents(cell) {
+			if cells[dep] && !visited[dep] {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, cell)
+	}
+	for _, cell := range sortedCells(cells) {
+		if !visited[cell] {
+			visit(cell)
+		}
+	}
+	return ordered
+}
+
+// newDependencyTracer returns the tracer of the workbook and the "Sheet!Cell"
+// key of the traced cell. The caller must hold recalcMu.
+func (f *File) newDependencyTracer(sheet, cell string) (*dependencyTracer, string, error) {
+	idx, err := f.GetSheetIndex(sheet)
+	if err != nil {
+		return nil, "", err
+	}
+	if idx == -1 {
+		return nil, "", ErrSheetNotExist{SheetName: sheet}
+	}
+	col, row, err := CellNameToCoordinates(cell)
+	if err != nil {
+		return nil, "", err
+	}
+	cellRef, _ := CoordinatesToCellName(col, row)
+	names := f.definedNameRefs()
+	index, err := f.cachedIncrementalIndex(context.Background(), names)
+	if err != nil {
+		return nil, "", err
+	}
+	return &dependencyTracer{
+		f:       f,
+		index:   index,
+		names:   names,
+		columns: make(map[string][]string),
+		scanned: make(map[string]bool),
+	}, f.GetSheetList()[idx] + "!" + cellRef, nil
+}
+
+// TraceDependents 返回直接或间接依赖于指定单元格的所有公式，使用与
+// RecalculateAffectedByCells 相同的反向依赖，整列引用（如 SUM(A:A)）的公式依赖于
+// 该列的任何单元格。结果为 "Sheet!Cell" 列表，按计算顺序排列：每个公式都排在它
+// 读取的受影响公式之后，可以沿着列表追踪错误值的传播。例如：
+//
+//	cells, err := f.TraceDependents("Data", "C5")
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, cell := range cells {
+//	    fmt.Println(cell)
+//	}
+func (f *File) TraceDependents(sheet, cell string) ([]string, error) {
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	tracer, start, err := f.newDependencyTracer(sheet, cell)
+	if err != nil {
+		return nil, err
+	}
+	dependents, err := f.findAffectedCellsInIndex(context.Background(), tracer.index, map[string]bool{start: true})
+	if err != nil {
+		return nil, err
+	}
+	// 循环引用时单元格自身也会被找到，不作为依赖返回
+	delete(dependents, start)
+	if len(dependents) == 0 {
+		return nil, nil
+	}
+	return tracer.order(dependents), nil
+}
+
+// TracePrecedents 返回指定单元格的公式直接或间接读取的所有单元格，包括数据单元格
+// 和公式单元格，整列引用展开为该列中存在值或公式的单元格。结果为 "Sheet!Cell"
+// 列表，按计算顺序排列：每个单元格都排在它读取的单元格之后。单元格没有公式时返回
+// 空列表。
+func (f *File) TracePrecedents(sheet, cell string) ([]string, error) {
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+
+	tracer, start, err := f.newDependencyTracer(sheet, cell)
+	if err != nil {
+		return nil, err
+	}
+	var ordered []string
+	visited := map[string]bool{start: true}
+	var visit func(cell string)
+	visit = func(cell string) {
+		for _, dep := range tracer.precedents(cell) {
+			if !visited[dep] {
+				visited[dep] = true
+				visit(dep)
+				ordered = append(ordered, dep)
+			}
+		}
+	}
+	visit(start)
+	return ordered, nil
+}
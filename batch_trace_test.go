@@ -0,0 +1,85 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceDependencies(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 3; row++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{
+		"A1": "B1+C1",
+		"B1": "D1*2",
+		"C1": "Data!A1+D1",
+		"D1": "SUM(Data!A:A)",
+		"E1": "Data!A2",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 整列引用依赖于该列的任何单元格，结果按计算顺序排列
+	cells, err := f.TraceDependents("Data", "A3")
+	if err != nil {
+		t.Fatalf("trace dependents: %v", err)
+	}
+	if want := []string{"Sheet1!D1", "Sheet1!B1", "Sheet1!C1", "Sheet1!A1"}; !reflect.DeepEqual(cells, want) {
+		t.Fatalf("unexpected dependents %v, want %v", cells, want)
+	}
+	cells, err = f.TraceDependents("data", "$A$1")
+	if err != nil {
+		t.Fatalf("trace dependents: %v", err)
+	}
+	if want := []string{"Sheet1!D1", "Sheet1!B1", "Sheet1!C1", "Sheet1!A1"}; !reflect.DeepEqual(cells, want) {
+		t.Fatalf("unexpected dependents %v, want %v", cells, want)
+	}
+	cells, err = f.TraceDependents("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Nil(t, cells)
+
+	// 整列引用展开为该列中存在的单元格
+	cells, err = f.TracePrecedents("Sheet1", "A1")
+	if err != nil {
+		t.Fatalf("trace precedents: %v", err)
+	}
+	if want := []string{"Data!A1", "Data!A2", "Data!A3", "Sheet1!D1", "Sheet1!B1", "Sheet1!C1"}; !reflect.DeepEqual(cells, want) {
+		t.Fatalf("unexpected precedents %v, want %v", cells, want)
+	}
+	cells, err = f.TracePrecedents("Data", "A1")
+	assert.NoError(t, err)
+	assert.Nil(t, cells)
+
+	// 循环引用不会重复追踪
+	assert.NoError(t, f.SetCellFormula("Sheet1", "F1", "G1+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "G1", "F1+1"))
+	cells, err = f.TraceDependents("Sheet1", "F1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Sheet1!G1"}, cells)
+	cells, err = f.TracePrecedents("Sheet1", "F1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Sheet1!G1"}, cells)
+
+	_, err = f.TraceDependents("SheetN", "A1")
+	assert.EqualError(t, err, "sheet SheetN does not exist")
+	_, err = f.TracePrecedents("Sheet1", "A")
+	assert.Equal(t, newCellNameToCoordinatesError("A", newInvalidCellNameError("A")), err)
+}
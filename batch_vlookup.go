@@ -0,0 +1,139 @@
+package excelize
+
+import (
+	"strings"
+)
+
+// vlookupPattern is a group of exact match VLOOKUP formulas over the same
+// table, e.g. VLOOKUP(A2,Data!$A:$E,3,FALSE) filled down a column. The
+// formulas may return different columns of the table, the first column is
+// indexed once for all of them.
+type vlookupPattern struct {
+	table    lookupTable
+	formulas map[string]*vlookupFormula // "Sheet!Cell" -> formula info
+}
+
+// vlookupFormula is a VLOOKUP formula of a pattern
+type vlookupFormula struct {
+	sheet    string
+	lookup   string // lookup value argument: cell reference or literal
+	colIndex int    // 1-based column of the table returned
+}
+
+// extractVLOOKUPPattern extracts the pattern of a formula which is a single
+// exact match VLOOKUP with a constant column index. Approximate match lookups
+// return nil and are calculated one by one.
+func (f *File) extractVLOOKUPPattern(sheet, cell, formula string) *vlookupPattern {
+	lookup, ok := parseVLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+	if !ok {
+		return nil
+	}
+	return &vlookupPattern{
+		table: lookup.table,
+		formulas: map[string]*vlookupFormula{
+			sheet + "!" + cell: {sheet: sheet, lookup: lookup.lookup, colIndex: lookup.colIndex},
+		},
+	}
+}
+
+// isVLOOKUPFormula reports whether the formula is a single exact match VLOOKUP
+func isVLOOKUPFormula(formula string) bool {
+	_, ok := parseVLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), "")
+	return ok
+}
+
+// groupVLOOKUPByPattern groups VLOOKUP formulas by their table
+func (f *File) groupVLOOKUPByPattern(formulas map[string]string) []*vlookupPattern {
+	patterns := make(map[lookupTable]*vlookupPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		pattern := f.extractVLOOKUPPattern(sheet, cell, formula)
+		if pattern == nil {
+			continue
+		}
+		if existing, exists := patterns[pattern.table]; exists {
+			for c, info := range pattern.formulas {
+				existing.formulas[c] = info
+			}
+			continue
+		}
+		patterns[pattern.table] = pattern
+	}
+	result := make([]*vlookupPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+// calculateVLOOKUPPatternWithCache calculates the formulas of a pattern with a
+// single scan of the table, the results calculated in former levels are read
+// from worksheetCache. A lookup value which is not found gives #N/A. Lookup
+// values with wildcards are left out of the result and calculated one by one.
+func (f *File) calculateVLOOKUPPatternWithCache(pattern *vlookupPattern, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string, len(pattern.formulas))
+	fileRows, err := f.getCachedRawRows(pattern.table.sheet)
+	if err != nil {
+		return results
+	}
+	rows := mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(pattern.table.sheet))
+	index := f.buildLookupTableIndex(rows, pattern.table)
+	for fullCell, info := range pattern.formulas {
+		value := f.resolveLookupValue(info.sheet, info.lookup, worksheetCache)
+		if strings.ContainsAny(value, "*?~") {
+			continue
+		}
+		rowIdx, found := index[f.lookupTableKey(value)]
+		if !found {
+			results[fullCell] = formulaErrorNA
+			continue
+		}
+		results[fullCell] = f.lookupResultValue(pattern.table.sheet, rows, rowIdx, pattern.table.startCol+info.colIndex-2)
+	}
+	return results
+}
+
+// lookupResultValue returns the value of a result cell of the batch lookups
+// by its 0-based row and column indexes in the rows of its sheet. The logical
+// values, which the raw rows read as 1 and 0, are returned as TRUE and FALSE
+// like GetCellValue does.
+func (f *File) lookupResultValue(sheet string, rows [][]string, rowIdx, col int) string {
+	if rowIdx >= len(rows) || col >= len(rows[rowIdx]) {
+		return ""
+	}
+	value := rows[rowIdx][col]
+	if logical := sumifsBooleanValue(value); logical != "" {
+		if cell, err := CoordinatesToCellName(col+1, rowIdx+1); err == nil {
+			if shadow := f.shadow.Load(); shadow != nil {
+				if computed, ok := shadow.value(sheet, cell); ok {
+					if inferXMLCellType(computed) == "b" {
+						return logical
+					}
+					return value
+				}
+			}
+			if cellType, err := f.GetCellType(sheet, cell); err == nil && cellType == CellTypeBool {
+				return logical
+			}
+		}
+	}
+	return value
+}
+
+// batchCalculateVLOOKUPWithCache calculates exact match VLOOKUP formulas
+// grouped by their table. The formulas parameter maps "Sheet!Cell" to formula,
+// it returns the results by cell and the number of scanned tables.
+func (f *File) batchCalculateVLOOKUPWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupVLOOKUPByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateVLOOKUPPatternWithCache(pattern, worksheetCache) {
+			results[cell] = value
+		}
+	}
+	f.logger().Debugf("  ⚡ [VLOOKUP Batch] %d VLOOKUP formulas over %d distinct tables", len(results), len(patterns))
+	return results, len(patterns)
+}
@@ -0,0 +1,137 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtractVLOOKUPPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for formula, want := range map[string]bool{
+		"=VLOOKUP(A2,Data!$A:$D,3,FALSE)":       true,
+		"VLOOKUP(A2,Data!$A$1:$D$99,2,0)":       true,
+		"VLOOKUP(A2,$A:$D,2,0)":                 true,
+		"VLOOKUP(A2,Data!$A:$D,2,TRUE)":         false,
+		"VLOOKUP(A2,Data!$A:$D,2)":              false,
+		"VLOOKUP(A2,Data!$A:$D,B1,0)":           false,
+		"VLOOKUP(A2,Data!$A:$D,2,0)*2":          false,
+		"IFERROR(VLOOKUP(A2,Data!$A:$D,2,0),0)": false,
+	} {
+		if got := f.extractVLOOKUPPattern("Sheet1", "B2", formula) != nil; got != want {
+			t.Fatalf("extractVLOOKUPPattern(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	pattern := f.extractVLOOKUPPattern("Sheet1", "B2", "VLOOKUP(A2,$A:$D,2,0)")
+	if pattern.table.sheet != "Sheet1" {
+		t.Fatalf("unexpected table sheet %q", pattern.table.sheet)
+	}
+}
+
+func TestBatchCalculateVLOOKUP(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"Key", "Name", "Qty", "Note"},
+		{"k1", "n1", 10, "x"},
+		{"k2", "n2", 20},
+		{"k3", "n3", 30, "z"},
+		{"k3", "dup", 99, "dup"},
+		{"k4", "n4", 40, true},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	keys := []string{"k1", "K2", "k3", "k4", "k9", "k1", "k2", "k3", "k4", "k5", "k2", "k3"}
+	for i, key := range keys {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": "VLOOKUP(A%d,Data!$A:$D,2,FALSE)",
+			"C": "VLOOKUP(A%d,Data!$A:$D,3,0)",
+			"D": "VLOOKUP(A%d,Data!$A$1:$D$6,4,0)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 批量结果与逐个计算的结果一致
+	want := make(map[string]string)
+	for i := range keys {
+		for _, col := range []string{"B", "C", "D"} {
+			cell := fmt.Sprintf("%s%d", col, i+2)
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorNA {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[cell] = value
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{"B3": "n2", "C4": "30", "D3": "", "D5": "TRUE", "B6": formulaErrorNA} {
+		if want[cell] != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, want[cell], value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.VLOOKUPFormulas != 3*len(keys) || plan.VLOOKUPTables != 2 {
+		t.Fatalf("unexpected plan VLOOKUP formulas %d, tables %d", plan.VLOOKUPFormulas, plan.VLOOKUPTables)
+	}
+}
+
+func TestBatchCalculateVLOOKUPNormalizer(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for i, row := range [][]interface{}{{"007", "a"}, {" 8", "b"}} {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("D%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	for cell, value := range map[string]string{"A1": "7", "A2": "8 ", "A3": "9"} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	formulas := map[string]string{
+		"Sheet1!B1": "VLOOKUP(A1,$D:$E,2,FALSE)",
+		"Sheet1!B2": "VLOOKUP(A2,$D:$E,2,FALSE)",
+		"Sheet1!B3": "VLOOKUP(A3,$D:$E,2,FALSE)",
+	}
+	f.SetCalcTuning(CalcTuning{LookupKeyNormalizer: func(key string) string {
+		return strings.TrimLeft(strings.TrimSpace(key), "0")
+	}})
+	results, tables := f.batchCalculateVLOOKUPWithCache(formulas, NewWorksheetCache())
+	if tables != 1 {
+		t.Fatalf("unexpected table count %d", tables)
+	}
+	for cell, want := range map[string]string{"Sheet1!B1": "a", "Sheet1!B2": "b", "Sheet1!B3": formulaErrorNA} {
+		if results[cell] != want {
+			t.Fatalf("unexpected %s result %q, want %q", cell, results[cell], want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package excelize
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// volatileFuncs are the volatile functions, the formulas using them are
+// recalculated by every recalculation regardless of the updated cells.
+var volatileFuncs = map[string]bool{
+	"CELL": true, "INDIRECT": true, "INFO": true, "NOW": true, "OFFSET": true,
+	"RAND": true, "RANDARRAY": true, "RANDBETWEEN": true, "TODAY": true,
+}
+
+// volatileFullRecalcRatio is the fraction of the formulas above which the
+// volatile formulas and their dependents dominate the workbook. The
+// cell-level incremental recalculation recalculates them anyway, so it falls
+// back to a full recalculation without finding the affected formulas.
+const volatileFullRecalcRatio = 0.5
+
+// isVolatileFormula reports whether the formula calls a volatile function,
+// such as NOW() or OFFSET(A1,1,0).
+func isVolatileFormula(formula string) bool {
+	upper := strings.ToUpper(formula)
+	found := false
+	for name := range volatileFuncs {
+		if strings.Contains(upper, name+"(") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(strings.TrimPrefix(strings.TrimSpace(formula), "=")) {
+		if token.TType == efp.TokenTypeFunction && token.TSubType == efp.TokenSubTypeStart &&
+			volatileFuncs[strings.TrimPrefix(strings.ToUpper(token.TValue), "_XLFN.")] {
+			return true
+		}
+	}
+	return false
+}
+
+// findVolatileCells returns the volatile formulas of the index and the
+// formulas depending on them directly or indirectly, which are recalculated
+// by every cell-level incremental recalculation. ctx 取消时返回 ctx.Err()
+func (f *File) findVolatileCells(ctx context.Context, index *incrementalIndex) (map[string]bool, error) {
+	volatile := make(map[string]bool)
+	for cell, formula := range index.formulaMap {
+		if isVolatileFormula(formula) {
+			volatile[cell] = true
+		}
+	}
+	if len(volatile) == 0 {
+		return volatile, nil
+	}
+	dependents, err := f.findAffectedCellsInIndex(ctx, index, volatile)
+	if err != nil {
+		return nil, err
+	}
+	for cell := range dependents {
+		volatile[cell] = true
+	}
+	return volatile, nil
+}
+
+// recordVolatilePlan records the volatile formulas of the workbook into the
+// plan of the last recalculation.
+func (f *File) recordVolatilePlan(volatile int, fullRecalcRecommended bool) {
+	if plan := f.lastCalcPlan.Load(); plan != nil {
+		updated := *plan
+		updated.VolatileFormulas, updated.FullRecalcRecommended = volatile, fullRecalcRecommended
+		f.lastCalcPlan.Store(&updated)
+	}
+}
@@ -0,0 +1,84 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsVolatileFormula(t *testing.T) {
+	for formula, volatile := range map[string]bool{
+		"=NOW()":                  true,
+		"TODAY()-A1":              true,
+		"SUM(OFFSET(A1,0,0,3,1))": true,
+		`INDIRECT("A"&B1)`:        true,
+		"_xlfn.RANDARRAY(2)":      true,
+		"SUM(A1:A3)":              false,
+		`"NOW()"&A1`:              false,
+		"SNOW(A1)":                false,
+	} {
+		assert.Equal(t, volatile, isVolatileFormula(formula), formula)
+	}
+}
+
+func TestRecalculateAffectedByCellsVolatile(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// 易失性公式占多数时回退到全量重算
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 10; row++ {
+		assert.NoError(t, f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row))
+		assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("OFFSET(A%d,0,0)*2", row)))
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C1", "B10+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C2", "A1+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "C3", "A2+1"))
+	assert.NoError(t, f.RecalculateAllWithDependency())
+
+	assert.NoError(t, f.SetCellValue("Sheet1", "A10", 100))
+	assert.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A10": true}))
+	plan, err := f.LastCalcPlan()
+	assert.NoError(t, err)
+	assert.True(t, plan.FullRecalcRecommended)
+	assert.Equal(t, 11, plan.VolatileFormulas)
+	assert.Equal(t, 13, plan.Formulas)
+	for cell, want := range map[string]string{"B10": "200", "C1": "201", "C2": "2"} {
+		value, err := f.GetCellValue("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, want, value, cell)
+	}
+
+	// 易失性公式占少数时增量重算，但易失性公式及其依赖每次都重算
+	f = NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", 1))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B1", `INDIRECT("A1")*2`))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B2", "B1+1"))
+	for row := 1; row <= 5; row++ {
+		assert.NoError(t, f.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), row))
+		assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("E%d", row), fmt.Sprintf("D%d*10", row)))
+	}
+	assert.NoError(t, f.RecalculateAllWithDependency())
+
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", 5))
+	cells, err := f.AffectedCellsByCells(map[string]bool{"Sheet1!D1": true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Sheet1!B1", "Sheet1!B2", "Sheet1!E1"}, cells)
+	assert.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Sheet1!D1": true}))
+	plan, err = f.LastCalcPlan()
+	assert.NoError(t, err)
+	assert.False(t, plan.FullRecalcRecommended)
+	assert.Equal(t, 2, plan.VolatileFormulas)
+	assert.Equal(t, 3, plan.Formulas)
+	for cell, want := range map[string]string{"B1": "10", "B2": "11", "E1": "10"} {
+		value, err := f.GetCellValue("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, want, value, cell)
+	}
+}
@@ -0,0 +1,189 @@
+package excelize
+
+import (
+	"strconv"
+	"strings"
+)
+
+// xlookupPattern is a group of exact match XLOOKUP formulas over the same
+// lookup column, e.g. XLOOKUP(A2,Data!$A:$A,Data!$C:$C,"NA") filled down a
+// column. The formulas may return different columns, the lookup column is
+// indexed once for all of them.
+type xlookupPattern struct {
+	table    lookupTable                // the lookup column
+	formulas map[string]*xlookupFormula // "Sheet!Cell" -> formula info
+}
+
+// xlookupFormula is an XLOOKUP formula of a pattern
+type xlookupFormula struct {
+	sheet         string
+	lookup        string // lookup value argument: cell reference or literal
+	returnCol     int    // 1-based column of the return array
+	ifNotFound    string // value of the if_not_found argument
+	hasIfNotFound bool
+}
+
+// xlookupExact represents an exact match XLOOKUP over single column arrays
+// with the same rows, e.g. XLOOKUP(A2,Data!$A:$A,Data!$C:$C,"NA")
+type xlookupExact struct {
+	lookup        string
+	table         lookupTable
+	returnCol     int
+	ifNotFound    string
+	hasIfNotFound bool
+}
+
+// parseXLOOKUPExact parses an XLOOKUP expression with 3 or 4 arguments, whose
+// lookup and return arrays are columns of the same rows and whose
+// if_not_found argument, if any, is a string or number literal. The sheet of
+// an unqualified array defaults to currentSheet.
+func parseXLOOKUPExact(expr, currentSheet string) (*xlookupExact, bool) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "XLOOKUP(") {
+		return nil, false
+	}
+	content := extractFunctionCall(expr, "XLOOKUP")
+	if "XLOOKUP("+content+")" != expr {
+		return nil, false
+	}
+	args := splitFunctionArgs(content)
+	if len(args) != 3 && len(args) != 4 {
+		return nil, false
+	}
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	if args[0] == "" || strings.ContainsAny(args[0], "(),:") {
+		return nil, false
+	}
+	table, ok1 := parseLookupTable(args[1], currentSheet)
+	returnArray, ok2 := parseLookupTable(args[2], currentSheet)
+	if !ok1 || !ok2 || table.startCol != table.endCol || returnArray.startCol != returnArray.endCol ||
+		table.sheet != returnArray.sheet || table.startRow != returnArray.startRow || table.endRow != returnArray.endRow {
+		return nil, false
+	}
+	lookup := &xlookupExact{lookup: args[0], table: table, returnCol: returnArray.startCol}
+	if len(args) == 4 {
+		value, ok := parseXLOOKUPLiteral(args[3])
+		if !ok {
+			return nil, false
+		}
+		lookup.ifNotFound, lookup.hasIfNotFound = value, true
+	}
+	return lookup, true
+}
+
+// parseXLOOKUPLiteral returns the value of a string or number literal
+// argument.
+func parseXLOOKUPLiteral(arg string) (string, bool) {
+	if len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"' {
+		return strings.ReplaceAll(arg[1:len(arg)-1], `""`, `"`), true
+	}
+	if num, err := strconv.ParseFloat(arg, 64); err == nil {
+		return formatFloat(num), true
+	}
+	return "", false
+}
+
+// extractXLOOKUPPattern extracts the pattern of a formula which is a single
+// exact match XLOOKUP over single column arrays, other formulas return nil and
+// are calculated one by one.
+func (f *File) extractXLOOKUPPattern(sheet, cell, formula string) *xlookupPattern {
+	lookup, ok := parseXLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), sheet)
+	if !ok {
+		return nil
+	}
+	return &xlookupPattern{
+		table: lookup.table,
+		formulas: map[string]*xlookupFormula{
+			sheet + "!" + cell: {
+				sheet: sheet, lookup: lookup.lookup, returnCol: lookup.returnCol,
+				ifNotFound: lookup.ifNotFound, hasIfNotFound: lookup.hasIfNotFound,
+			},
+		},
+	}
+}
+
+// isXLOOKUPFormula reports whether the formula is a single exact match
+// XLOOKUP which can be calculated in batch
+func isXLOOKUPFormula(formula string) bool {
+	_, ok := parseXLOOKUPExact(strings.TrimPrefix(strings.TrimSpace(formula), "="), "")
+	return ok
+}
+
+// groupXLOOKUPByPattern groups XLOOKUP formulas by their lookup column
+func (f *File) groupXLOOKUPByPattern(formulas map[string]string) []*xlookupPattern {
+	patterns := make(map[lookupTable]*xlookupPattern)
+	for fullCell, formula := range formulas {
+		sheet, cell, ok := strings.Cut(fullCell, "!")
+		if !ok {
+			continue
+		}
+		pattern := f.extractXLOOKUPPattern(sheet, cell, formula)
+		if pattern == nil {
+			continue
+		}
+		if existing, exists := patterns[pattern.table]; exists {
+			for c, info := range pattern.formulas {
+				existing.formulas[c] = info
+			}
+			continue
+		}
+		patterns[pattern.table] = pattern
+	}
+	result := make([]*xlookupPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		result = append(result, pattern)
+	}
+	return result
+}
+
+// calculateXLOOKUPPatternWithCache calculates the formulas of a pattern with a
+// single scan of the lookup column, the results calculated in former levels
+// are read from worksheetCache. A lookup value which is not found gives the
+// if_not_found value, or #N/A without it. Empty lookup values are left out of
+// the result and calculated one by one.
+func (f *File) calculateXLOOKUPPatternWithCache(pattern *xlookupPattern, worksheetCache *WorksheetCache) map[string]string {
+	results := make(map[string]string, len(pattern.formulas))
+	fileRows, err := f.getCachedRawRows(pattern.table.sheet)
+	if err != nil {
+		return results
+	}
+	rows := mergeSheetCacheIntoRows(fileRows, worksheetCache.GetSheet(pattern.table.sheet))
+	index := f.buildLookupTableIndex(rows, pattern.table)
+	for fullCell, info := range pattern.formulas {
+		value := f.resolveLookupValue(info.sheet, info.lookup, worksheetCache)
+		if value == "" {
+			continue
+		}
+		rowIdx, found := index[f.lookupTableKey(value)]
+		if !found {
+			results[fullCell] = formulaErrorNA
+			if info.hasIfNotFound {
+				results[fullCell] = info.ifNotFound
+			}
+			continue
+		}
+		results[fullCell] = ""
+		if col := info.returnCol - 1; col < len(rows[rowIdx]) {
+			results[fullCell] = rows[rowIdx][col]
+		}
+	}
+	return results
+}
+
+// batchCalculateXLOOKUPWithCache calculates exact match XLOOKUP formulas
+// grouped by their lookup column. The formulas parameter maps "Sheet!Cell" to
+// formula, it returns the results by cell and the number of scanned lookup
+// columns.
+func (f *File) batchCalculateXLOOKUPWithCache(formulas map[string]string, worksheetCache *WorksheetCache) (map[string]string, int) {
+	results := make(map[string]string, len(formulas))
+	patterns := f.groupXLOOKUPByPattern(formulas)
+	for _, pattern := range patterns {
+		for cell, value := range f.calculateXLOOKUPPatternWithCache(pattern, worksheetCache) {
+			results[cell] = value
+		}
+	}
+	f.logger().Debugf("  ⚡ [XLOOKUP Batch] %d XLOOKUP formulas over %d distinct lookup columns", len(results), len(patterns))
+	return results, len(patterns)
+}
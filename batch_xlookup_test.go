@@ -0,0 +1,119 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestExtractXLOOKUPPattern(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for formula, want := range map[string]bool{
+		`=XLOOKUP(A2,Data!$A:$A,Data!$C:$C,"NA")`:          true,
+		"XLOOKUP(A2,Data!$A:$A,Data!$C:$C)":                true,
+		"XLOOKUP(A2,Data!$A$2:$A$99,Data!$B$2:$B$99,0)":    true,
+		"XLOOKUP(A2,$A:$A,$C:$C)":                          true,
+		"XLOOKUP(A2,Data!$A:$A,Data!$C:$C,B1)":             false,
+		`XLOOKUP(A2,Data!$A:$A,Data!$C:$C,"NA",2)`:         false,
+		"XLOOKUP(A2,Data!$A:$A,Data!$C:$D)":                false,
+		"XLOOKUP(A2,Data!$A:$A,Other!$C:$C)":               false,
+		"XLOOKUP(A2,Data!$A$2:$A$99,Data!$B$1:$B$98)":      false,
+		"XLOOKUP(A2,Data!$A:$A,Data!$C:$C)*2":              false,
+		`IFERROR(XLOOKUP(A2,Data!$A:$A,Data!$C:$C),"")`:    false,
+		"XLOOKUP(TRIM(A2),Data!$A:$A,Data!$C:$C)":          false,
+		`XLOOKUP(A2,Data!$A:$A,Data!$C:$C,"say ""hi""")`:   true,
+		"XLOOKUP(A2,Data!$A$2:$A$99,Data!$B$2:$B$99,1.50)": true,
+	} {
+		if got := f.extractXLOOKUPPattern("Sheet1", "B2", formula) != nil; got != want {
+			t.Fatalf("extractXLOOKUPPattern(%q) = %t, want %t", formula, got, want)
+		}
+	}
+	pattern := f.extractXLOOKUPPattern("Sheet1", "B2", `XLOOKUP(A2,$A:$A,$C:$C,"say ""hi""")`)
+	info := pattern.formulas["Sheet1!B2"]
+	if pattern.table.sheet != "Sheet1" || info.returnCol != 3 || info.ifNotFound != `say "hi"` {
+		t.Fatalf("unexpected pattern %+v, formula %+v", pattern.table, info)
+	}
+	pattern = f.extractXLOOKUPPattern("Sheet1", "B2", "XLOOKUP(A2,$A:$A,$C:$C,1.50)")
+	if info = pattern.formulas["Sheet1!B2"]; info.ifNotFound != "1.5" {
+		t.Fatalf("unexpected if_not_found value %q", info.ifNotFound)
+	}
+}
+
+func TestBatchCalculateXLOOKUP(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"Key", "Name", "Qty"},
+		{"k1", "n1", 10},
+		{"k2", "n2"},
+		{"k3", "n3", 30},
+		{"k3", "dup", 99},
+		{"k4", "n4", 40},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	keys := []string{"k1", "K2", "k3", "k4", "k9", "k1", "k2", "k3", "k4", "k5", "k2", "k3"}
+	for i, key := range keys {
+		row := i + 2
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), key); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		for col, formula := range map[string]string{
+			"B": `XLOOKUP(A%d,Data!$A:$A,Data!$B:$B,"NA")`,
+			"C": "XLOOKUP(A%d,Data!$A:$A,Data!$C:$C,0)",
+			"D": "XLOOKUP(A%d,Data!$A$1:$A$6,Data!$B$1:$B$6)",
+		} {
+			if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", col, row), fmt.Sprintf(formula, row)); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+		}
+	}
+
+	// 批量结果与逐个计算的结果一致
+	want := make(map[string]string)
+	for i := range keys {
+		for _, col := range []string{"B", "C", "D"} {
+			cell := fmt.Sprintf("%s%d", col, i+2)
+			value, err := f.CalcCellValue("Sheet1", cell)
+			if err != nil && value != formulaErrorNA {
+				t.Fatalf("calc %s: %v", cell, err)
+			}
+			want[cell] = value
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, value := range want {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	for cell, value := range map[string]string{
+		"B2": "n1", "B3": "n2", "B4": "n3", "B6": "NA", "B11": "NA",
+		"C2": "10", "C3": "", "C4": "30", "C6": "0",
+		"D5": "n4", "D6": formulaErrorNA,
+	} {
+		if got, _ := f.GetCellValue("Sheet1", cell); got != value {
+			t.Fatalf("unexpected %s value %q, want %q", cell, got, value)
+		}
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("last calc plan: %v", err)
+	}
+	if plan.XLOOKUPFormulas != 3*len(keys) || plan.XLOOKUPColumns != 2 {
+		t.Fatalf("unexpected plan XLOOKUP formulas %d, lookup columns %d", plan.XLOOKUPFormulas, plan.XLOOKUPColumns)
+	}
+}
@@ -2,6 +2,8 @@ package excelize
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
@@ -327,6 +329,53 @@ func TestRangeResolverCacheCheck(t *testing.T) {
 	assert.Equal(t, "550", result2)
 }
 
+// TestRangeResolverMixedFormulaRange tests that a SUM over a large range where
+// half the cells are formulas calculated in an earlier level reads the
+// calculated values, rather than the stale stored values of the range cache
+func TestRangeResolverMixedFormulaRange(t *testing.T) {
+	f := NewFile()
+	defer f.Close()
+
+	_, err := f.NewSheet("Data")
+	require.NoError(t, err)
+	const rows = 2000
+	want := 0
+	for row := 1; row <= rows; row++ {
+		require.NoError(t, f.SetCellValue("Data", fmt.Sprintf("B%d", row), row))
+		if row%2 == 1 {
+			require.NoError(t, f.SetCellValue("Data", fmt.Sprintf("A%d", row), row))
+			want += row
+			continue
+		}
+		require.NoError(t, f.SetCellFormula("Data", fmt.Sprintf("A%d", row), fmt.Sprintf("B%d*2", row)))
+		want += row * 2
+	}
+	require.NoError(t, f.SetCellFormula("Sheet1", "A1", fmt.Sprintf("SUM(Data!A1:A%d)", rows)))
+	require.NoError(t, f.SetCellFormula("Data", "D1", fmt.Sprintf("SUM(A1:A%d)", rows)))
+
+	require.NoError(t, f.RecalculateAllWithDependency())
+	for _, cell := range []string{"Sheet1!A1", "Data!D1"} {
+		sheet, ref, _ := strings.Cut(cell, "!")
+		value, err := f.GetCellValue(sheet, ref)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(want), value, cell)
+	}
+
+	// The range cache holds the stored values of the formula cells before the
+	// incremental recalculation, the calculated Data!A2 replaces them
+	require.NoError(t, f.SetCellValue("Data", "B2", 1000))
+	want += 1000*2 - 2*2
+	_, err = f.CalcCellValue("Sheet1", "A1")
+	require.NoError(t, err)
+	require.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Data!B2": true}))
+	for _, cell := range []string{"Sheet1!A1", "Data!D1"} {
+		sheet, ref, _ := strings.Cut(cell, "!")
+		value, err := f.GetCellValue(sheet, ref)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(want), value, cell)
+	}
+}
+
 // TestFormulaArgTypeCaching tests that formulaArg type is preserved in cache
 func TestFormulaArgTypeCaching(t *testing.T) {
 	f := NewFile()
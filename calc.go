@@ -23,7 +23,7 @@ import (
 	"net/url"
 	"reflect"
 	"regexp"
-	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -61,6 +61,7 @@ const (
 	criteriaG
 	criteriaErr
 	criteriaRegexp
+	criteriaBlank
 
 	categoryWeightAndMass
 	categoryDistance
@@ -118,6 +119,15 @@ var (
 		">":  1,
 		">=": 1,
 	}
+	// dynamicArrayFuncs defined the functions which return a dynamic array
+	// spilling into the neighboring cells of the formula cell
+	dynamicArrayFuncs = map[string]bool{
+		"CHOOSECOLS": true, "CHOOSEROWS": true, "DROP": true, "EXPAND": true,
+		"FILTER": true, "HSTACK": true, "RANDARRAY": true, "SEQUENCE": true,
+		"SORT": true, "SORTBY": true, "TAKE": true, "TEXTSPLIT": true,
+		"TOCOL": true, "TOROW": true, "UNIQUE": true, "VSTACK": true,
+		"WRAPCOLS": true, "WRAPROWS": true,
+	}
 	month2num = map[string]int{
 		"january":   1,
 		"february":  2,
@@ -249,6 +259,7 @@ type calcContext struct {
 	iterationsCache   map[string]formulaArg
 	rangeCache        sync.Map        // Cache for range references like "$K2:$AAC2" (thread-safe)
 	worksheetCache    *WorksheetCache // Batch calculation cache for recently calculated values
+	spill             *formulaArg     // Receives the dynamic array returned by the formula of the entry cell
 }
 
 // cellRef defines the structure of a cell reference.
@@ -268,6 +279,7 @@ type cellRange struct {
 type formulaCriteria struct {
 	Type      byte
 	Condition formulaArg
+	blank     BlankCriteriaMode // "" 条件匹配的单元格
 }
 
 // ArgType is the type of formula argument type.
@@ -465,6 +477,7 @@ type formulaFuncs struct {
 //	CEILING
 //	CEILING.MATH
 //	CEILING.PRECISE
+//	CELL
 //	CHAR
 //	CHIDIST
 //	CHIINV
@@ -639,6 +652,7 @@ type formulaFuncs struct {
 //	IMTAN
 //	INDEX
 //	INDIRECT
+//	INFO
 //	INT
 //	INTERCEPT
 //	INTRATE
@@ -785,6 +799,7 @@ type formulaFuncs struct {
 //	SHEET
 //	SHEETS
 //	SIGN
+//	SINGLE
 //	SIN
 //	SINH
 //	SKEW
@@ -1100,12 +1115,10 @@ func newEmptyFormulaArg() formulaArg {
 func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.Token) (formulaArg, error) {
 	var (
 		err                             error
-		inArray, inArrayRow             bool
-		formulaArray                    [][]formulaArg
-		formulaArrayRow                 []formulaArg
 		opdStack, optStack, opfStack    = NewStack(), NewStack(), NewStack()
 		opfdStack, opftStack, argsStack = NewStack(), NewStack(), NewStack()
 	)
+	tokens = expandImplicitIntersection(tokens)
 	for i := 0; i < len(tokens); i++ {
 		token := tokens[i]
 
@@ -1127,11 +1140,19 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 		// function start
 		if isFunctionStartToken(token) {
 			if token.TValue == "ARRAY" {
-				inArray, formulaArray = true, [][]formulaArg{}
-				continue
-			}
-			if token.TValue == "ARRAYROW" {
-				inArrayRow, formulaArrayRow = true, []formulaArg{}
+				// array constant, like {1,2;3,4}: evaluated as a single matrix
+				// operand, so it can be a function argument or an operand of
+				// the arithmetic operators
+				array, stop, err := parseArrayConstant(tokens, i)
+				if err != nil {
+					return newEmptyFormulaArg(), err
+				}
+				i = stop
+				if opfStack.Len() == 0 {
+					opdStack.Push(array)
+					continue
+				}
+				opfdStack.Push(array)
 				continue
 			}
 			opfStack.Push(token)
@@ -1211,20 +1232,6 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 				continue
 			}
 
-			if inArrayRow && isOperand(token) {
-				formulaArrayRow = append(formulaArrayRow, opfdStack.Pop().(formulaArg))
-				continue
-			}
-			if inArrayRow && isFunctionStopToken(token) {
-				formulaArray = append(formulaArray, formulaArrayRow)
-				inArrayRow = false
-				continue
-			}
-			if inArray && isFunctionStopToken(token) {
-				argsStack.Peek().(*list.List).PushBack(newMatrixFormulaArg(formulaArray))
-				inArray = false
-				continue
-			}
 			if errArg := f.evalInfixExpFunc(ctx, sheet, cell, token, nextToken, opfStack, opdStack, opftStack, opfdStack, argsStack); errArg.Type == ArgError {
 				return errArg, errors.New(errArg.Error)
 			}
@@ -1250,6 +1257,90 @@ func (f *File) evalInfixExp(ctx *calcContext, sheet, cell string, tokens []efp.T
 	return result, err
 }
 
+// expandImplicitIntersection rewrites the implicit intersection operator of
+// the reference tokens, like @A:A or @'Sheet 1'!A:A, into the equivalent
+// _xlfn.SINGLE(A:A) function tokens.
+func expandImplicitIntersection(tokens []efp.Token) []efp.Token {
+	isOperator := func(token efp.Token) bool {
+		return (token.TType == efp.TokenTypeUnknown && token.TValue == "@") ||
+			(token.TSubType == efp.TokenSubTypeRange && strings.HasPrefix(token.TValue, "@"))
+	}
+	if !slices.ContainsFunc(tokens, isOperator) {
+		return tokens
+	}
+	expanded := make([]efp.Token, 0, len(tokens)+2)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.TType == efp.TokenTypeUnknown && token.TValue == "@" &&
+			i+1 < len(tokens) && tokens[i+1].TSubType == efp.TokenSubTypeRange {
+			i++
+			token = tokens[i]
+		} else if token.TSubType == efp.TokenSubTypeRange && strings.HasPrefix(token.TValue, "@") {
+			token.TValue = strings.TrimPrefix(token.TValue, "@")
+		} else {
+			expanded = append(expanded, token)
+			continue
+		}
+		expanded = append(expanded,
+			efp.Token{TValue: "_xlfn.SINGLE", TType: efp.TokenTypeFunction, TSubType: efp.TokenSubTypeStart},
+			token,
+			efp.Token{TType: efp.TokenTypeFunction, TSubType: efp.TokenSubTypeStop})
+	}
+	return expanded
+}
+
+// parseArrayConstant parses the tokens of an array constant which starts at
+// the given ARRAY function start token, like {1,2,3} or {1,2;3,4}, columns
+// are separated by commas and rows by semicolons. It returns the matrix and
+// the index of the ARRAY function stop token. The elements of an array
+// constant should be numbers, text, logical values or error values.
+func parseArrayConstant(tokens []efp.Token, start int) (formulaArg, int, error) {
+	var (
+		matrix        [][]formulaArg
+		row           []formulaArg
+		inRow, negate bool
+	)
+	for i := start + 1; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case isFunctionStartToken(token) && token.TValue == "ARRAYROW" && !inRow:
+			inRow, row = true, []formulaArg{}
+		case isFunctionStopToken(token) && inRow:
+			if len(matrix) > 0 && len(matrix[0]) != len(row) {
+				return newEmptyFormulaArg(), i, ErrInvalidFormula
+			}
+			matrix, inRow = append(matrix, row), false
+		case isFunctionStopToken(token):
+			return newMatrixFormulaArg(matrix), i, nil
+		case token.TType == efp.TokenTypeArgument:
+			// column or row separator
+		case token.TType == efp.TokenTypeOperatorPrefix && inRow:
+			if token.TValue == "-" {
+				negate = !negate
+			}
+		case (isOperand(token) || token.TSubType == efp.TokenSubTypeRange) && inRow:
+			arg := tokenToFormulaArg(token)
+			if token.TSubType == efp.TokenSubTypeRange {
+				// TRUE/FALSE 可能被识别为引用，其他引用不能出现在数组常量中
+				if upper := strings.ToUpper(token.TValue); upper != "TRUE" && upper != "FALSE" {
+					return newEmptyFormulaArg(), i, ErrInvalidFormula
+				}
+				arg = newBoolFormulaArg(strings.EqualFold(token.TValue, "TRUE"))
+			}
+			if negate {
+				if arg.Type != ArgNumber || arg.Boolean {
+					return newEmptyFormulaArg(), i, ErrInvalidFormula
+				}
+				arg.Number, negate = -arg.Number, false
+			}
+			row = append(row, arg)
+		default:
+			return newEmptyFormulaArg(), i, ErrInvalidFormula
+		}
+	}
+	return newEmptyFormulaArg(), len(tokens), ErrInvalidFormula
+}
+
 // evalInfixExpFunc evaluate formula function in the infix expression.
 func (f *File) evalInfixExpFunc(ctx *calcContext, sheet, cell string, token, nextToken efp.Token, opfStack, opdStack, opftStack, opfdStack, argsStack *Stack) formulaArg {
 	if !isFunctionStopToken(token) {
@@ -1278,6 +1369,14 @@ func (f *File) evalInfixExpFunc(ctx *calcContext, sheet, cell string, token, nex
 		return newEmptyFormulaArg()
 	}
 	if arg.Type == ArgMatrix && len(arg.Matrix) > 0 && len(arg.Matrix[0]) > 0 {
+		if dynamicArrayFuncs[funcName] && f.isSpillRangeBlocked(sheet, cell, len(arg.Matrix), len(arg.Matrix[0])) {
+			return newErrorFormulaArg(formulaErrorSPILL, formulaErrorSPILL)
+		}
+		if ctx.spill != nil && dynamicArrayFuncs[funcName] && ctx.entry == sheet+"!"+cell {
+			ctx.mu.Lock()
+			*ctx.spill = arg
+			ctx.mu.Unlock()
+		}
 		opdStack.Push(arg.Matrix[0][0])
 		return newEmptyFormulaArg()
 	}
@@ -1285,6 +1384,62 @@ func (f *File) evalInfixExpFunc(ctx *calcContext, sheet, cell string, token, nex
 	return newEmptyFormulaArg()
 }
 
+// isSpillRangeBlocked returns if the range which a dynamic array of given
+// size returned by the formula in the cell spills into contains a value or a
+// formula, the formula gives the #SPILL! error in this case. The cells within
+// the stored range of an array formula hold the former spilled values, they
+// don't block the spill range.
+func (f *File) isSpillRangeBlocked(sheet, cell string, rows, cols int) bool {
+	if cell == "" || (rows == 1 && cols == 1) {
+		return false
+	}
+	col, row, err := CellNameToCoordinates(cell)
+	if err != nil {
+		return false
+	}
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return false
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	own := []int{col, row, col, row}
+	for _, r := range ws.SheetData.Row {
+		if r.R != row {
+			continue
+		}
+		for _, c := range r.C {
+			if c.R == cell && c.F != nil && c.F.T == STCellFormulaTypeArray && c.F.Ref != "" {
+				if coordinates, err := rangeRefToCoordinates(c.F.Ref); err == nil {
+					_ = sortCoordinates(coordinates)
+					own = coordinates
+				}
+			}
+		}
+	}
+	for _, r := range ws.SheetData.Row {
+		if r.R < row || r.R >= row+rows {
+			continue
+		}
+		for _, c := range r.C {
+			if c.V == "" && c.F == nil && c.IS == nil {
+				continue
+			}
+			x, y, err := CellNameToCoordinates(c.R)
+			if err != nil || x < col || x >= col+cols {
+				continue
+			}
+			if x >= own[0] && x <= own[2] && y >= own[1] && y <= own[3] {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
 // prepareEvalInfixExp check the token and stack state for formula function
 // evaluate.
 func prepareEvalInfixExp(opfStack, opftStack, opfdStack, argsStack *Stack) {
@@ -1787,15 +1942,16 @@ func calcGe(rOpd, lOpd formulaArg, opdStack *Stack) error {
 
 // calcSplice evaluate splice '&' operations.
 func calcSplice(rOpd, lOpd formulaArg, opdStack *Stack) error {
-	// 数字转字符串时使用非科学计数法，与 Excel 行为一致
-	// 例如: 22483598035&"" 应该返回 "22483598035" 而不是 "2.2483598035e+10"
+	// 数字按 Excel 常规格式转字符串，与单元格显示的值一致
+	// 例如: 22483598035&"" 应该返回 "22483598035" 而不是 "2.2483598035e+10"，
+	// (0.1+0.2)&"" 应该返回 "0.3" 而不是 "0.30000000000000004"
 	lVal := lOpd.Value()
 	rVal := rOpd.Value()
 	if lOpd.Type == ArgNumber && !lOpd.Boolean {
-		lVal = strconv.FormatFloat(lOpd.Number, 'f', -1, 64)
+		lVal = formatFloat(lOpd.Number)
 	}
 	if rOpd.Type == ArgNumber && !rOpd.Boolean {
-		rVal = strconv.FormatFloat(rOpd.Number, 'f', -1, 64)
+		rVal = formatFloat(rOpd.Number)
 	}
 	opdStack.Push(newStringFormulaArg(lVal + rVal))
 	return nil
@@ -2135,6 +2291,9 @@ func formulaArgToToken(arg formulaArg) efp.Token {
 			return efp.Token{TValue: arg.Value(), TType: efp.TokenTypeOperand, TSubType: efp.TokenSubTypeLogical}
 		}
 		return efp.Token{TValue: arg.Value(), TType: efp.TokenTypeOperand, TSubType: efp.TokenSubTypeNumber}
+	case ArgError:
+		// 引用单元格的错误值按错误值参与运算，由运算符向外传播
+		return efp.Token{TValue: arg.Value(), TType: efp.TokenTypeOperand, TSubType: efp.TokenSubTypeError}
 	default:
 		return efp.Token{TValue: arg.Value(), TType: efp.TokenTypeOperand, TSubType: efp.TokenSubTypeText}
 	}
@@ -2184,6 +2343,10 @@ func (f *File) parseToken(ctx *calcContext, sheet string, token efp.Token, opdSt
 	}
 	if token.TType == efp.TokenTypeOperatorPostfix && !opdStack.Empty() {
 		topOpd := opdStack.Pop().(formulaArg)
+		if topOpd.Type == ArgError {
+			opdStack.Push(topOpd)
+			return nil
+		}
 		opdStack.Push(newNumberFormulaArg(topOpd.Number / 100))
 	}
 	// opd
@@ -2481,7 +2644,9 @@ func generateRangeCacheKey(sheet string, valueRange []int) string {
 }
 
 // optimizeValueRange intelligently truncates full-column references to the actual
-// maximum row with data, avoiding reading millions of empty cells.
+// maximum row with data, avoiding reading millions of empty cells. The rows
+// without any value or formula, such as the formatted empty rows up to a huge
+// declared dimension, are not counted.
 func (f *File) optimizeValueRange(sheet string, valueRange []int) []int {
 	// Check if this is a full-column reference (ends at TotalRows)
 	if valueRange[1] == TotalRows {
@@ -2491,18 +2656,28 @@ func (f *File) optimizeValueRange(sheet string, valueRange []int) []int {
 			// ws.SheetData.Row may be sparse (e.g., [Row1, Row5, Row100])
 			maxRow := 0
 			for _, row := range ws.SheetData.Row {
-				if row.R > maxRow {
+				if row.R > maxRow && rowHasData(&row) {
 					maxRow = row.R
 				}
 			}
 			if maxRow > 0 && maxRow < TotalRows {
-				valueRange[1] = maxRow
+				valueRange[1] = max(maxRow, valueRange[0])
 			}
 		}
 	}
 	return valueRange
 }
 
+// rowHasData returns if any cell of the row has a value or a formula.
+func rowHasData(row *xlsxRow) bool {
+	for _, c := range row.C {
+		if c.V != "" || c.F != nil || c.IS != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // clearCellCache clears cache for a specific cell and related range caches.
 // This is a fine-grained cache invalidation strategy that only clears affected caches
 // instead of clearing the entire cache.
@@ -2525,14 +2700,12 @@ func (f *File) clearCellCache(sheet, cell string) {
 	var keysToDelete []string
 	f.rangeCache.Range(func(key string, value interface{}) bool {
 		// Check if cache key belongs to this sheet and might contain the cell
-		if len(key) > len(sheet) && key[:len(sheet)] == sheet {
-			// Parse range from cache key: "Sheet!R1C1:R100C5"
-			// If the cell is within this range, delete the cache entry
-			// For simplicity, we'll delete all range caches for this sheet
-			// A more sophisticated approach would parse and check exact ranges
-			if strings.HasPrefix(key, sheet+"!") {
-				keysToDelete = append(keysToDelete, key)
-			}
+		// Parse range from cache key: "Sheet!R1C1:R100C5"
+		// If the cell is within this range, delete the cache entry
+		// For simplicity, we'll delete all range caches for this sheet
+		// A more sophisticated approach would parse and check exact ranges
+		if isSheetCacheKey(key, sheet) {
+			keysToDelete = append(keysToDelete, key)
 		}
 		return true
 	})
@@ -2569,7 +2742,7 @@ func (f *File) rangeResolverParallel(ctx *calcContext, sheet string, ws *xlsxWor
 		matrix[i] = make([]formulaArg, numCols)
 	}
 
-	numWorkers := min(runtime.NumCPU(), numRows)
+	numWorkers := min(f.calcWorkers(), numRows)
 	chunkSize := (numRows + numWorkers - 1) / numWorkers
 
 	var wg sync.WaitGroup
@@ -2662,7 +2835,7 @@ func (f *File) rangeResolverParallelCols(ctx *calcContext, sheet string, ws *xls
 		matrix[i] = make([]formulaArg, numCols)
 	}
 
-	numWorkers := min(runtime.NumCPU(), numCols)
+	numWorkers := min(f.calcWorkers(), numCols)
 	chunkSize := (numCols + numWorkers - 1) / numWorkers
 
 	var wg sync.WaitGroup
@@ -2860,6 +3033,53 @@ func (f *File) rangeResolverSerial(ctx *calcContext, sheet string, ws *xlsxWorks
 	return matrix, nil
 }
 
+// refreshRangeMatrix returns the cached matrix of a range with the values of
+// its formula cells replaced by the values in the worksheet cache or calcCache,
+// which are calculated after the matrix was cached. The cached matrix is
+// copied only if a value differs, and stored back into the global range cache.
+func (f *File) refreshRangeMatrix(ctx *calcContext, sheet string, valueRange []int, matrix [][]formulaArg) [][]formulaArg {
+	ws, err := f.workSheetReader(sheet)
+	if err != nil {
+		return matrix
+	}
+	refreshed, copied := matrix, false
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	for row := valueRange[0]; row <= valueRange[1] && row <= len(ws.SheetData.Row) && row-valueRange[0] < len(matrix); row++ {
+		i := row - valueRange[0]
+		for _, c := range ws.SheetData.Row[row-1].C {
+			if c.F == nil {
+				continue
+			}
+			col, _, err := CellNameToCoordinates(c.R)
+			if err != nil || col < valueRange[2] || col > valueRange[3] || col-valueRange[2] >= len(matrix[i]) {
+				continue
+			}
+			value, found := ctx.worksheetCache.Get(sheet, c.R)
+			if !found {
+				if cached, ok := f.calcCache.Load(sheet + "!" + c.R); ok {
+					value, found = cached.(formulaArg)
+				}
+			}
+			j := col - valueRange[2]
+			if !found || refreshed[i][j].Type == value.Type && refreshed[i][j].Value() == value.Value() {
+				continue
+			}
+			if !copied {
+				refreshed, copied = make([][]formulaArg, len(matrix)), true
+				for k := range matrix {
+					refreshed[k] = append([]formulaArg(nil), matrix[k]...)
+				}
+			}
+			refreshed[i][j] = value
+		}
+	}
+	if copied {
+		f.rangeCache.Store(generateRangeCacheKey(sheet, valueRange), refreshed)
+	}
+	return refreshed
+}
+
 // rangeResolver extract value as string from given reference and range list.
 // This function will not ignore the empty cell. For example, A1:A2:A2:B3 will
 // be reference A1:B3.
@@ -2907,56 +3127,15 @@ func (f *File) rangeResolver(ctx *calcContext, cellRefs, cellRanges *list.List)
 
 		// Then check global range cache
 		if cached, ok := f.rangeCache.Load(cacheKey); ok {
-			// CRITICAL FIX: If worksheetCache is available, we need to check if any cells
-			// in the cached range have been updated during batch calculation
-			// If so, we must rebuild the matrix with fresh values
+			// CRITICAL FIX: If worksheetCache is available, the formula cells of the
+			// cached range may have been calculated after the range was cached, such as
+			// by an earlier level of this recalculation, so refresh them with the
+			// calculated values
 			if ctx.worksheetCache != nil {
-				needRebuild := false
-				cachedMatrix := cached.([][]formulaArg)
-
-				// For single-row ranges in 补货计划 row 3, check ALL cells since they're likely formulas
-				// For other ranges, just sample check corners and middle
-				if sheet == "补货计划" && valueRange[0] == 3 && valueRange[1] == 3 {
-					// Check every cell in the row (K3:CV3 or K3:AAC3)
-					for col := valueRange[2]; col <= valueRange[3]; col++ {
-						cellName, _ := CoordinatesToCellName(col, valueRange[0])
-						if _, found := ctx.worksheetCache.Get(sheet, cellName); found {
-							needRebuild = true
-							break
-						}
-					}
-				} else {
-					// Sample check: Check corners and middle for other ranges
-					checkPositions := []struct{ row, col int }{
-						{valueRange[0], valueRange[2]},                       // top-left
-						{valueRange[0], valueRange[3]},                       // top-right
-						{valueRange[1], valueRange[2]},                       // bottom-left
-						{valueRange[1], valueRange[3]},                       // bottom-right
-						{valueRange[0], (valueRange[2] + valueRange[3]) / 2}, // middle
-					}
-
-					for _, pos := range checkPositions {
-						if pos.row < valueRange[0] || pos.row > valueRange[1] ||
-							pos.col < valueRange[2] || pos.col > valueRange[3] {
-							continue
-						}
-						cellName, _ := CoordinatesToCellName(pos.col, pos.row)
-						if _, found := ctx.worksheetCache.Get(sheet, cellName); found {
-							needRebuild = true
-							break
-						}
-					}
-				}
-
-				if !needRebuild {
-					// Cache is still valid, use it
-					arg.Matrix = cachedMatrix
-					ctx.rangeCache.Store(cacheKey, arg)
-					arg.cellRefs, arg.cellRanges = cellRefs, cellRanges
-					return
-				}
-
-				// Need to rebuild with worksheetCache values - fall through to rebuild
+				arg.Matrix = f.refreshRangeMatrix(ctx, sheet, valueRange, cached.([][]formulaArg))
+				ctx.rangeCache.Store(cacheKey, arg)
+				arg.cellRefs, arg.cellRanges = cellRefs, cellRanges
+				return
 			} else {
 				// No worksheetCache, use cached matrix as-is
 				arg.Matrix = cached.([][]formulaArg)
@@ -3072,7 +3251,9 @@ func formulaCriteriaEval(val formulaArg, criteria *formulaCriteria) (result bool
 		criteriaGe: calcGe,
 	}
 	switch criteria.Type {
-	case criteriaEq, criteriaLe, criteriaGe, criteriaNe, criteriaL, criteriaG:
+	case criteriaLe, criteriaGe, criteriaL, criteriaG:
+		return formulaCriteriaCompare(val, criteria), err
+	case criteriaEq, criteriaNe:
 		if fn, ok := tokenCalcFunc[criteria.Type]; ok {
 			if _ = fn(criteria.Condition, val, s); s.Len() > 0 {
 				return s.Pop().(formulaArg).Number == 1, err
@@ -3080,10 +3261,62 @@ func formulaCriteriaEval(val formulaArg, criteria *formulaCriteria) (result bool
 		}
 	case criteriaRegexp:
 		return regexp.MatchString(criteria.Condition.Value(), val.Value())
+	case criteriaBlank:
+		return formulaCriteriaBlank(val, criteria.blank), err
 	}
 	return
 }
 
+// criteriaParser parses a criteria of the conditional functions like
+// formulaCriteriaParser, except the "" criterion matches the blank cells, the
+// empty string results or both by CalcTuning.BlankEqualsEmptyString.
+func (fn *formulaFuncs) criteriaParser(exp formulaArg) *formulaCriteria {
+	if exp.Type == ArgString && exp.String == "" {
+		return &formulaCriteria{Type: criteriaBlank, Condition: exp, blank: fn.f.calcTuning.BlankEqualsEmptyString}
+	}
+	return formulaCriteriaParser(exp)
+}
+
+// formulaCriteriaBlank returns if a value matches the "" criterion: a blank
+// cell, an empty string or both by the mode.
+func formulaCriteriaBlank(val formulaArg, mode BlankCriteriaMode) bool {
+	isBlank := val.Type == ArgEmpty
+	isEmptyString := val.Type == ArgString && val.String == ""
+	switch mode {
+	case BlankMatchesBlankOnly:
+		return isBlank
+	case BlankMatchesEmptyStringOnly:
+		return isEmptyString
+	}
+	return isBlank || isEmptyString
+}
+
+// formulaCriteriaCompare evaluates a comparison criterion like ">5" or "<M"
+// as Excel does: a numeric criterion only matches the numbers and a text
+// criterion only matches the non-empty text, which is compared
+// case-insensitively.
+func formulaCriteriaCompare(val formulaArg, criteria *formulaCriteria) bool {
+	var less, equal bool
+	switch cond := criteria.Condition; {
+	case cond.Type == ArgNumber && val.Type == ArgNumber:
+		less, equal = val.Number < cond.Number, val.Number == cond.Number
+	case cond.Type == ArgString && val.Type == ArgString && val.String != "":
+		lhs, rhs := strings.ToLower(val.String), strings.ToLower(cond.String)
+		less, equal = lhs < rhs, lhs == rhs
+	default:
+		return false
+	}
+	switch criteria.Type {
+	case criteriaL:
+		return less
+	case criteriaLe:
+		return less || equal
+	case criteriaG:
+		return !less && !equal
+	}
+	return !less
+}
+
 // Engineering Functions
 
 // BESSELI function the modified Bessel function, which is equivalent to the
@@ -5183,6 +5416,15 @@ func (fn *formulaFuncs) BASE(argsList *list.List) formulaArg {
 	return newStringFormulaArg(strings.ToUpper(result))
 }
 
+// significanceQuotient returns the quotient of a number divided by a
+// significance rounded to 15 significant digits like Excel, so a multiple of
+// the significance, like 0.3 of 0.1, isn't rounded to the next multiple
+// because of the floating-point error of the division.
+func significanceQuotient(number, significance float64) float64 {
+	quotient, _ := strconv.ParseFloat(strconv.FormatFloat(number/significance, 'G', 15, 64), 64)
+	return quotient
+}
+
 // CEILING function rounds a supplied number away from zero, to the nearest
 // multiple of a given number. The syntax of the function is:
 //
@@ -5211,12 +5453,15 @@ func (fn *formulaFuncs) CEILING(argsList *list.List) formulaArg {
 		significance = s.Number
 	}
 	if significance < 0 && number > 0 {
-		return newErrorFormulaArg(formulaErrorVALUE, "negative sig to CEILING invalid")
+		return newErrorFormulaArg(formulaErrorNUM, "negative sig to CEILING invalid")
 	}
 	if argsList.Len() == 1 {
 		return newNumberFormulaArg(math.Ceil(number))
 	}
-	number, res = math.Modf(number / significance)
+	if significance == 0 {
+		return newNumberFormulaArg(0)
+	}
+	number, res = math.Modf(significanceQuotient(number, significance))
 	if res > 0 {
 		number++
 	}
@@ -5260,7 +5505,10 @@ func (fn *formulaFuncs) CEILINGdotMATH(argsList *list.List) formulaArg {
 		}
 		mode = m.Number
 	}
-	val, res := math.Modf(number / significance)
+	if significance == 0 {
+		return newNumberFormulaArg(0)
+	}
+	val, res := math.Modf(significanceQuotient(number, significance))
 	if res != 0 {
 		if number > 0 {
 			val++
@@ -5306,7 +5554,7 @@ func (fn *formulaFuncs) CEILINGdotPRECISE(argsList *list.List) formulaArg {
 			return newNumberFormulaArg(significance)
 		}
 	}
-	val, res := math.Modf(number / significance)
+	val, res := math.Modf(significanceQuotient(number, significance))
 	if res != 0 {
 		if number > 0 {
 			val++
@@ -5638,8 +5886,10 @@ func (fn *formulaFuncs) FLOOR(argsList *list.List) formulaArg {
 	if significance.Number < 0 && number.Number >= 0 {
 		return newErrorFormulaArg(formulaErrorNUM, "invalid arguments to FLOOR")
 	}
-	val := number.Number
-	val, res := math.Modf(val / significance.Number)
+	if significance.Number == 0 {
+		return newErrorFormulaArg(formulaErrorDIV, "FLOOR divide by zero")
+	}
+	val, res := math.Modf(significanceQuotient(number.Number, significance.Number))
 	if res != 0 {
 		if number.Number < 0 && res < 0 {
 			val--
@@ -5684,7 +5934,10 @@ func (fn *formulaFuncs) FLOORdotMATH(argsList *list.List) formulaArg {
 		}
 		mode = m.Number
 	}
-	val, res := math.Modf(number.Number / significance)
+	if significance == 0 {
+		return newNumberFormulaArg(0)
+	}
+	val, res := math.Modf(significanceQuotient(number.Number, significance))
 	if res != 0 && number.Number < 0 && mode > 0 {
 		val--
 	}
@@ -5724,7 +5977,7 @@ func (fn *formulaFuncs) FLOORdotPRECISE(argsList *list.List) formulaArg {
 			return newNumberFormulaArg(significance)
 		}
 	}
-	val, res := math.Modf(number.Number / significance)
+	val, res := math.Modf(significanceQuotient(number.Number, significance))
 	if res != 0 {
 		if number.Number < 0 {
 			val--
@@ -6225,10 +6478,9 @@ func (fn *formulaFuncs) MROUND(argsList *list.List) formulaArg {
 		multiple.Number > 0 && n.Number < 0 {
 		return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 	}
-	number, res := math.Modf(n.Number / multiple.Number)
-	if math.Trunc(res+0.5) > 0 {
-		number++
-	}
+	// 与 ROUND 一致，正好位于两个倍数中间时向远离零的方向舍入，
+	// 如 MROUND(0.15,0.1) 的商 1.4999999999999998 也舍入到 2
+	number := fn.round(n.Number/multiple.Number, 0, closest)
 	return newNumberFormulaArg(number * multiple.Number)
 }
 
@@ -6348,7 +6600,10 @@ func (fn *formulaFuncs) POWER(argsList *list.List) formulaArg {
 	if x.Number == 0 && y.Number < 0 {
 		return newErrorFormulaArg(formulaErrorDIV, formulaErrorDIV)
 	}
-	return newNumberFormulaArg(math.Pow(x.Number, y.Number))
+	if result := math.Pow(x.Number, y.Number); !math.IsNaN(result) && !math.IsInf(result, 0) {
+		return newNumberFormulaArg(result)
+	}
+	return newErrorFormulaArg(formulaErrorNUM, formulaErrorNUM)
 }
 
 // PRODUCT function returns the product (multiplication) of a supplied set of
@@ -6624,14 +6879,24 @@ const (
 	up
 )
 
-// round rounds a supplied number up or down.
+// round rounds a supplied number up or down. Like Excel, the fractional part
+// of the digits is truncated, and a negative number of digits rounds to the
+// left of the decimal point, such as to the tens or hundreds.
 func (fn *formulaFuncs) round(number, digits float64, mode roundMode) float64 {
+	digits = math.Trunc(digits)
 	var significance float64
 	if digits > 0 {
 		significance = math.Pow(1/10.0, digits)
 	} else {
 		significance = math.Pow(10.0, -digits)
 	}
+	if significance == 0 || math.IsInf(number/significance, 0) {
+		// 位数超出浮点数精度，数值不变
+		return number
+	}
+	if math.IsInf(significance, 0) {
+		return 0
+	}
 	val, res := math.Modf(number / significance)
 	switch mode {
 	case closest:
@@ -6649,6 +6914,10 @@ func (fn *formulaFuncs) round(number, digits float64, mode roundMode) float64 {
 			val--
 		}
 	}
+	if digits > 0 {
+		// 除以 10 的幂得到最接近的小数，如 44/10 而不是 44*0.1
+		return val / math.Pow(10.0, digits)
+	}
 	return val * significance
 }
 
@@ -7081,7 +7350,8 @@ func (fn *formulaFuncs) PROB(argsList *list.List) formulaArg {
 }
 
 // SUBTOTAL function performs a specified calculation (e.g. the sum, product,
-// average, etc.) for a supplied set of values. The syntax of the function is:
+// average, etc.) for a supplied set of values. Function numbers 1-11 include
+// hidden rows, while 101-111 exclude them. The syntax of the function is:
 //
 //	SUBTOTAL(function_num,ref1,[ref2],...)
 func (fn *formulaFuncs) SUBTOTAL(argsList *list.List) formulaArg {
@@ -7110,11 +7380,53 @@ func (fn *formulaFuncs) SUBTOTAL(argsList *list.List) formulaArg {
 	}
 	subArgList := list.New().Init()
 	for arg := argsList.Front().Next(); arg != nil; arg = arg.Next() {
+		if fnNum.Number > 100 {
+			// Function numbers 101-111 ignore the rows hidden by the user or a filter
+			subArgList.PushBack(fn.subtotalVisibleRows(arg.Value.(formulaArg)))
+			continue
+		}
 		subArgList.PushBack(arg.Value.(formulaArg))
 	}
 	return subFn(subArgList)
 }
 
+// subtotalVisibleRows returns the range argument of the SUBTOTAL function
+// without the rows which are hidden in the worksheet.
+func (fn *formulaFuncs) subtotalVisibleRows(arg formulaArg) formulaArg {
+	if arg.Type != ArgMatrix || arg.cellRanges == nil || arg.cellRanges.Len() != 1 {
+		return arg
+	}
+	cr := arg.cellRanges.Front().Value.(cellRange)
+	sheet := cr.From.Sheet
+	if sheet == "" {
+		sheet = fn.sheet
+	}
+	ws, err := fn.f.workSheetReader(sheet)
+	if err != nil {
+		return arg
+	}
+	hiddenRows := make(map[int]bool)
+	ws.mu.RLock()
+	for _, row := range ws.SheetData.Row {
+		if row.Hidden {
+			hiddenRows[row.R] = true
+		}
+	}
+	ws.mu.RUnlock()
+	if len(hiddenRows) == 0 {
+		return arg
+	}
+	fromRow := min(cr.From.Row, cr.To.Row)
+	visible := arg
+	visible.Matrix = make([][]formulaArg, 0, len(arg.Matrix))
+	for idx, row := range arg.Matrix {
+		if !hiddenRows[fromRow+idx] {
+			visible.Matrix = append(visible.Matrix, row)
+		}
+	}
+	return visible
+}
+
 // SUM function adds together a supplied set of numbers and returns the sum of
 // these values. The syntax of the function is:
 //
@@ -7154,7 +7466,7 @@ func (fn *formulaFuncs) SUMIF(argsList *list.List) formulaArg {
 	if argsList.Len() < 2 {
 		return newErrorFormulaArg(formulaErrorVALUE, "SUMIF requires at least 2 arguments")
 	}
-	criteria := formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
+	criteria := fn.criteriaParser(argsList.Front().Next().Value.(formulaArg))
 	rangeMtx := argsList.Front().Value.(formulaArg).Matrix
 	var sumRange [][]formulaArg
 	if argsList.Len() == 3 {
@@ -7165,7 +7477,7 @@ func (fn *formulaFuncs) SUMIF(argsList *list.List) formulaArg {
 	for rowIdx, row := range rangeMtx {
 		for colIdx, cell := range row {
 			arg = cell
-			if arg.Type == ArgEmpty {
+			if arg.Type == ArgEmpty && criteria.Type != criteriaBlank {
 				continue
 			}
 			if ok, _ := formulaCriteriaEval(arg, criteria); ok {
@@ -7214,7 +7526,7 @@ func (fn *formulaFuncs) SUMIFS(argsList *list.List) formulaArg {
 // sumproductParallel performs parallel multiplication for SUMPRODUCT with large arrays
 func (fn *formulaFuncs) sumproductParallel(args []formulaArg, res []float64) {
 	n := len(args)
-	numWorkers := min(runtime.NumCPU(), n/100) // At least 100 elements per worker
+	numWorkers := min(fn.f.calcWorkers(), n/100) // At least 100 elements per worker
 	if numWorkers < 2 {
 		numWorkers = 2
 	}
@@ -7542,7 +7854,7 @@ func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
 		return newErrorFormulaArg(formulaErrorVALUE, "AVERAGEIF requires at least 2 arguments")
 	}
 	var (
-		criteria  = formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
+		criteria  = fn.criteriaParser(argsList.Front().Next().Value.(formulaArg))
 		rangeMtx  = argsList.Front().Value.(formulaArg).Matrix
 		cellRange [][]formulaArg
 		args      []formulaArg
@@ -7556,7 +7868,7 @@ func (fn *formulaFuncs) AVERAGEIF(argsList *list.List) formulaArg {
 	for rowIdx, row := range rangeMtx {
 		for colIdx, col := range row {
 			fromVal := col.Value()
-			if fromVal == "" {
+			if fromVal == "" && criteria.Type != criteriaBlank {
 				continue
 			}
 			if col.Type == ArgString && criteria.Condition.Type != ArgString {
@@ -9265,7 +9577,7 @@ func (fn *formulaFuncs) COUNTIF(argsList *list.List) formulaArg {
 		return newErrorFormulaArg(formulaErrorVALUE, "COUNTIF requires 2 arguments")
 	}
 	var (
-		criteria = formulaCriteriaParser(argsList.Front().Next().Value.(formulaArg))
+		criteria = fn.criteriaParser(argsList.Front().Next().Value.(formulaArg))
 		count    float64
 	)
 	for _, cell := range argsList.Front().Value.(formulaArg).ToList() {
@@ -9367,6 +9679,9 @@ func (fn *formulaFuncs) formulaIfsMatch(args []formulaArg) (cellRefs []cellRef)
 		}
 	}
 
+	// "" 条件匹配的单元格取决于 CalcTuning.BlankEqualsEmptyString
+	cacheKey.WriteString("|blank=")
+	cacheKey.WriteString(strconv.Itoa(int(fn.f.calcTuning.BlankEqualsEmptyString)))
 	key := cacheKey.String()
 
 	// Check cache first
@@ -9380,7 +9695,7 @@ func (fn *formulaFuncs) formulaIfsMatch(args []formulaArg) (cellRefs []cellRef)
 	// Optimization: Build index for each criteria_range for O(1) lookup
 	for i := 0; i < len(args)-1; i += 2 {
 		var match []cellRef
-		matrix, criteria := args[i].Matrix, formulaCriteriaParser(args[i+1])
+		matrix, criteria := args[i].Matrix, fn.criteriaParser(args[i+1])
 
 		if i == 0 {
 			// First criteria - build or use index
@@ -13258,6 +13573,87 @@ func (fn *formulaFuncs) ZTEST(argsList *list.List) formulaArg {
 
 // Information Functions
 
+// CELL function returns information about the formatting, location, or
+// contents of the top-left cell of a supplied reference. The syntax of the
+// function is:
+//
+//	CELL(info_type,[reference])
+//
+// The supported info types are "address", "col", "contents", "row" and
+// "type". The other info types of Excel: "color", "filename", "format",
+// "parentheses", "prefix", "protect" and "width", return the #N/A error. If
+// the reference is omitted, the formula cell is used instead of the last
+// changed cell.
+func (fn *formulaFuncs) CELL(argsList *list.List) formulaArg {
+	if argsList.Len() < 1 || argsList.Len() > 2 {
+		return newErrorFormulaArg(formulaErrorVALUE, "CELL requires 1 or 2 arguments")
+	}
+	infoType := argsList.Front().Value.(formulaArg)
+	if infoType.Type == ArgError {
+		return infoType
+	}
+	ref, value := cellRef{Sheet: fn.sheet}, newEmptyFormulaArg()
+	if argsList.Len() == 2 {
+		arg := argsList.Back().Value.(formulaArg)
+		if arg.cellRanges != nil && arg.cellRanges.Len() > 0 {
+			ref = arg.cellRanges.Front().Value.(cellRange).From
+		} else if arg.cellRefs != nil && arg.cellRefs.Len() > 0 {
+			ref = arg.cellRefs.Front().Value.(cellRef)
+		} else {
+			return newErrorFormulaArg(formulaErrorVALUE, "CELL requires a reference")
+		}
+		if value = arg; arg.Type == ArgMatrix && len(arg.Matrix) > 0 && len(arg.Matrix[0]) > 0 {
+			value = arg.Matrix[0][0]
+		}
+	} else {
+		ref.Col, ref.Row, _ = CellNameToCoordinates(fn.cell)
+	}
+	if ref.Sheet == "" {
+		ref.Sheet = fn.sheet
+	}
+	switch strings.ToLower(infoType.Value()) {
+	case "address":
+		addr, _ := CoordinatesToCellName(ref.Col, ref.Row, true)
+		if ref.Sheet != fn.sheet {
+			addr = escapeSheetName(ref.Sheet) + "!" + addr
+		}
+		return newStringFormulaArg(addr)
+	case "col":
+		return newNumberFormulaArg(float64(ref.Col))
+	case "row":
+		return newNumberFormulaArg(float64(ref.Row))
+	case "contents":
+		if argsList.Len() == 1 {
+			cell, _ := CoordinatesToCellName(ref.Col, ref.Row)
+			value, _ = fn.f.cellResolver(fn.ctx, ref.Sheet, cell)
+		}
+		switch value.Type {
+		case ArgNumber:
+			return formulaArg{Type: ArgNumber, Number: value.Number, Boolean: value.Boolean}
+		case ArgString:
+			return newStringFormulaArg(value.String)
+		case ArgError:
+			return newErrorFormulaArg(value.String, value.Error)
+		}
+		return newNumberFormulaArg(0)
+	case "type":
+		if argsList.Len() == 1 {
+			cell, _ := CoordinatesToCellName(ref.Col, ref.Row)
+			value, _ = fn.f.cellResolver(fn.ctx, ref.Sheet, cell)
+		}
+		switch {
+		case value.Type == ArgEmpty || value.Type == ArgString && value.String == "":
+			return newStringFormulaArg("b")
+		case value.Type == ArgString:
+			return newStringFormulaArg("l")
+		}
+		return newStringFormulaArg("v")
+	case "color", "filename", "format", "parentheses", "prefix", "protect", "width":
+		return newErrorFormulaArg(formulaErrorNA, fmt.Sprintf("CELL does not support info_type %s", infoType.Value()))
+	}
+	return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("invalid CELL info_type %s", infoType.Value()))
+}
+
 // ERRORdotTYPE function receives an error value and returns an integer, that
 // tells you the type of the supplied error. The syntax of the function is:
 //
@@ -13280,6 +13676,36 @@ func (fn *formulaFuncs) ERRORdotTYPE(argsList *list.List) formulaArg {
 	return newErrorFormulaArg(formulaErrorNA, formulaErrorNA)
 }
 
+// INFO function returns information about the current operating environment.
+// The syntax of the function is:
+//
+//	INFO(type_text)
+//
+// The supported type texts are "numfile", which returns the number of
+// worksheets, "recalc", which returns "Automatic", and "system", which returns
+// "pcdos". The other type texts of Excel: "directory", "origin", "osversion"
+// and "release", depend on the running application and return the #N/A error.
+func (fn *formulaFuncs) INFO(argsList *list.List) formulaArg {
+	if argsList.Len() != 1 {
+		return newErrorFormulaArg(formulaErrorVALUE, "INFO requires 1 argument")
+	}
+	typeText := argsList.Front().Value.(formulaArg)
+	if typeText.Type == ArgError {
+		return typeText
+	}
+	switch strings.ToLower(typeText.Value()) {
+	case "numfile":
+		return newNumberFormulaArg(float64(len(fn.f.GetSheetList())))
+	case "recalc":
+		return newStringFormulaArg("Automatic")
+	case "system":
+		return newStringFormulaArg("pcdos")
+	case "directory", "origin", "osversion", "release":
+		return newErrorFormulaArg(formulaErrorNA, fmt.Sprintf("INFO does not support type_text %s", typeText.Value()))
+	}
+	return newErrorFormulaArg(formulaErrorVALUE, fmt.Sprintf("invalid INFO type_text %s", typeText.Value()))
+}
+
 // ISBLANK function tests if a specified cell is blank (empty) and if so,
 // returns TRUE; Otherwise the function returns FALSE. The syntax of the
 // function is:
@@ -15599,9 +16025,9 @@ func (fn *formulaFuncs) concat(argsList *list.List) formulaArg {
 			if cell.Type == ArgError {
 				return cell
 			}
-			// 数字转字符串时使用非科学计数法，与 Excel 行为一致
+			// 数字按 Excel 常规格式转字符串，与 & 运算符一致
 			if cell.Type == ArgNumber && !cell.Boolean {
-				buf.WriteString(strconv.FormatFloat(cell.Number, 'f', -1, 64))
+				buf.WriteString(formatFloat(cell.Number))
 			} else {
 				buf.WriteString(cell.Value())
 			}
@@ -18000,10 +18426,16 @@ func checkLookupArgs(argsList *list.List) (arrayForm bool, lookupValue, lookupVe
 }
 
 // iterateLookupArgs iterate arguments to extract columns and calculate match
-// index for the formula function LOOKUP.
+// index for the formula function LOOKUP. The blank cells of the lookup vector
+// are skipped unless the lookup value is blank, so the nearest match is the
+// last value less than the lookup value.
 func iterateLookupArgs(lookupValue, lookupVector formulaArg) ([]formulaArg, int, bool) {
-	cols, matchIdx, ok := lookupCol(lookupVector, 0), -1, false
+	cols, matchIdx, ok := lookupVectorCells(lookupVector, false), -1, false
+	prevIdx := -1
 	for idx, col := range cols {
+		if col.Value() == "" && lookupValue.Value() != "" {
+			continue
+		}
 		lhs := lookupValue
 		switch col.Type {
 		case ArgNumber:
@@ -18021,11 +18453,15 @@ func iterateLookupArgs(lookupValue, lookupVector formulaArg) ([]formulaArg, int,
 			break
 		}
 		// Find the nearest match if lookup value is more than or equal to the first value in lookup vector
-		if idx == 0 {
+		if prevIdx == -1 {
 			ok = compare == criteriaG
 		} else if ok && compare == criteriaL && matchIdx == -1 {
-			matchIdx = idx - 1
+			matchIdx = prevIdx
 		}
+		prevIdx = idx
+	}
+	if ok && matchIdx == -1 {
+		matchIdx = prevIdx
 	}
 	return cols, matchIdx, ok
 }
@@ -18761,23 +19197,23 @@ func (fn *formulaFuncs) INDIRECT(argsList *list.List) formulaArg {
 
 // LOOKUP function performs an approximate match lookup in a one-column or
 // one-row range, and returns the corresponding value from another one-column
-// or one-row range. The syntax of the function is:
+// or one-row range. In the array form, it searches the first row of an array
+// wider than it is tall, or else the first column, and returns the value of
+// the last row or column. The syntax of the function is:
 //
 //	LOOKUP(lookup_value,lookup_vector,[result_vector])
+//	LOOKUP(lookup_value,array)
 func (fn *formulaFuncs) LOOKUP(argsList *list.List) formulaArg {
 	arrayForm, lookupValue, lookupVector, errArg := checkLookupArgs(argsList)
 	if errArg.Type == ArgError {
 		return errArg
 	}
-	cols, matchIdx, ok := iterateLookupArgs(lookupValue, lookupVector)
-	if ok && matchIdx == -1 {
-		matchIdx = len(cols) - 1
-	}
+	cols, matchIdx, _ := iterateLookupArgs(lookupValue, lookupVector)
 	var column []formulaArg
 	if argsList.Len() == 3 {
-		column = lookupCol(argsList.Back().Value.(formulaArg), 0)
-	} else if arrayForm && len(lookupVector.Matrix[0]) > 1 {
-		column = lookupCol(lookupVector, 1)
+		column = lookupVectorCells(argsList.Back().Value.(formulaArg), false)
+	} else if arrayForm {
+		column = lookupVectorCells(lookupVector, true)
 	} else {
 		column = cols
 	}
@@ -18787,13 +19223,30 @@ func (fn *formulaFuncs) LOOKUP(argsList *list.List) formulaArg {
 	return column[matchIdx]
 }
 
+// lookupVectorCells returns the cells of a vector of LOOKUP: the first row of
+// an array wider than it is tall, or else its first column. With last, it
+// returns the last row or column, the result of the array form.
+func lookupVectorCells(arr formulaArg, last bool) []formulaArg {
+	if arr.Type == ArgMatrix && len(arr.Matrix) > 0 && len(arr.Matrix[0]) > len(arr.Matrix) {
+		if last {
+			return arr.Matrix[len(arr.Matrix)-1]
+		}
+		return arr.Matrix[0]
+	}
+	var idx int
+	if last && arr.Type == ArgMatrix && len(arr.Matrix) > 0 {
+		idx = len(arr.Matrix[0]) - 1
+	}
+	return lookupCol(arr, idx)
+}
+
 // lookupCol extract columns for LOOKUP.
 func lookupCol(arr formulaArg, idx int) []formulaArg {
 	col := arr.List
 	if arr.Type == ArgMatrix {
 		col = nil
 		for _, r := range arr.Matrix {
-			if len(r) > 0 {
+			if idx < len(r) {
 				col = append(col, r[idx])
 				continue
 			}
@@ -18846,6 +19299,61 @@ func (fn *formulaFuncs) ROWS(argsList *list.List) formulaArg {
 	return newNumberFormulaArg(float64(result))
 }
 
+// SINGLE function performs the implicit intersection of a reference with the
+// row or column of the formula cell, it is written by Excel as the @ operator,
+// like =@A:A. A reference of a single column returns the cell of the formula
+// row, a reference of a single row returns the cell of the formula column,
+// and an array returns its first element. The syntax of the function is:
+//
+//	SINGLE(value)
+func (fn *formulaFuncs) SINGLE(argsList *list.List) formulaArg {
+	if argsList.Len() != 1 {
+		return newErrorFormulaArg(formulaErrorVALUE, "SINGLE requires 1 argument")
+	}
+	arg := argsList.Front().Value.(formulaArg)
+	if arg.cellRanges == nil || arg.cellRanges.Len() == 0 {
+		if arg.Type == ArgMatrix {
+			if len(arg.Matrix) == 0 || len(arg.Matrix[0]) == 0 {
+				return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+			}
+			return arg.Matrix[0][0]
+		}
+		return arg
+	}
+	if arg.cellRanges.Len() > 1 {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	cr := arg.cellRanges.Front().Value.(cellRange)
+	col, row, err := CellNameToCoordinates(fn.cell)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+	}
+	fromCol, toCol := min(cr.From.Col, cr.To.Col), max(cr.From.Col, cr.To.Col)
+	fromRow, toRow := min(cr.From.Row, cr.To.Row), max(cr.From.Row, cr.To.Row)
+	if fromCol == toCol {
+		col = fromCol
+	}
+	if fromRow == toRow {
+		row = fromRow
+	}
+	if col < fromCol || col > toCol || row < fromRow || row > toRow {
+		return newErrorFormulaArg(formulaErrorVALUE, formulaErrorVALUE)
+	}
+	cell, err := CoordinatesToCellName(col, row)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+	}
+	sheet := cr.From.Sheet
+	if sheet == "" {
+		sheet = fn.sheet
+	}
+	result, err := fn.f.parseReference(fn.ctx, fn.sheet, sheet+"!"+cell)
+	if err != nil {
+		return newErrorFormulaArg(formulaErrorVALUE, err.Error())
+	}
+	return result
+}
+
 // Web Functions
 
 // ENCODEURL function returns a URL-encoded string, replacing certain
@@ -22234,7 +22742,7 @@ func (f *File) PreloadColumnRange(sheet string, startRow, endRow, startCol, endC
 	}
 
 	// Second pass: load all cell values and cache each row range
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	chunkSize := (endRow - startRow + 1 + numWorkers - 1) / numWorkers
 
 	var wg sync.WaitGroup
@@ -0,0 +1,111 @@
+package excelize
+
+import (
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// batchableShapeHints are the shapes of the formulas of a function which the
+// batch patterns recognize, suggested for the formulas calculated one by one.
+var batchableShapeHints = map[string]string{
+	"AVERAGEIF":  "use AVERAGEIFS with single column ranges of the same rows on one sheet, like AVERAGEIFS(Data!$C:$C,Data!$A:$A,$A2)",
+	"AVERAGEIFS": "use single column ranges of the same rows on one sheet and cell or literal criteria, like AVERAGEIFS(Data!$C:$C,Data!$A:$A,$A2)",
+	"COUNTA":     "count a single column range, like COUNTA(Data!$A:$A)",
+	"COUNTIF":    "count a single column range with a cell or literal criteria, like COUNTIF(Data!$A:$A,$A2)",
+	"HLOOKUP":    "use an exact match with a constant row index, like HLOOKUP($A2,Data!$1:$3,2,FALSE)",
+	"INDEX":      "look up a single column with an exact MATCH, like INDEX(Data!$B:$B,MATCH($A2,Data!$A:$A,0))",
+	"LOOKUP":     "look up a sorted single column vector, like LOOKUP($A2,Data!$A:$A,Data!$B:$B)",
+	"MATCH":      "look up a single column with an exact MATCH in INDEX, like INDEX(Data!$B:$B,MATCH($A2,Data!$A:$A,0))",
+	"MAX":        "aggregate a single column range, like MAX(Data!$C:$C)",
+	"MIN":        "aggregate a single column range, like MIN(Data!$C:$C)",
+	"SUMIF":      "use single column ranges of the same rows on one sheet, like SUMIF(Data!$A:$A,$A2,Data!$C:$C)",
+	"SUMIFS":     "use single column ranges of the same rows on one sheet and cell or literal criteria, like SUMIFS(Data!$C:$C,Data!$A:$A,$A2)",
+	"SUMPRODUCT": "multiply conditions on single columns with a value range, like SUMPRODUCT((Data!$A:$A=$A2)*(Data!$B:$B>0)*Data!$C:$C)",
+	"VLOOKUP":    "use an exact match with a constant column index, like VLOOKUP($A2,Data!$A:$C,3,FALSE)",
+	"XLOOKUP":    "use an exact match over single columns, like XLOOKUP($A2,Data!$A:$A,Data!$C:$C)",
+}
+
+// FormulaReport directly maps a formula cell reported by AnalyzeFormulas.
+// Function is the first function called by the formula, like "SUMIFS", or
+// empty for the formulas without functions. Pattern is the batch pattern
+// which recognizes the formula, like "SUMIFS" or "INDEX-MATCH" as in
+// CalcAuditEntry, "constant" for the formulas referencing nothing, or empty
+// if the formula is calculated one by one. Hint suggests the shape the batch
+// patterns recognize for the formulas of a known function calculated one by
+// one.
+type FormulaReport struct {
+	Sheet    string
+	Cell     string
+	Formula  string
+	Function string
+	Pattern  string
+	Hint     string
+}
+
+// AnalyzeFormulas reports the formula cells of all worksheets with the batch
+// pattern which calculates them in a dependency based recalculation, without
+// calculating the formulas. It helps to find the formulas calculated one by
+// one, which are slower, and restructure them into the shapes recognized by
+// the batch patterns. A batch pattern only applies when a dependency level
+// has at least 10 formulas of it, or 5 for the AVERAGE(OFFSET(...)) formulas,
+// and the batch calculators may still leave some formulas of a pattern to be
+// calculated one by one, like the lookup values with wildcards. The reports
+// are ordered by worksheets and cells. For example:
+//
+//	reports, err := f.AnalyzeFormulas()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, report := range reports {
+//	    if report.Pattern == "" {
+//	        fmt.Printf("%s!%s: %s %s\n", report.Sheet, report.Cell, report.Formula, report.Hint)
+//	    }
+//	}
+func (f *File) AnalyzeFormulas() ([]FormulaReport, error) {
+	var reports []FormulaReport
+	for _, sheet := range f.GetSheetList() {
+		f.mu.Lock()
+		ws, err := f.workSheetReader(sheet)
+		f.mu.Unlock()
+		if err != nil {
+			return reports, err
+		}
+		ws.mu.RLock()
+		for _, row := range ws.SheetData.Row {
+			for i := range row.C {
+				formula := resolvedFormula(ws, &row.C[i])
+				if formula == "" {
+					continue
+				}
+				reports = append(reports, analyzeFormula(sheet, row.C[i].R, formula))
+			}
+		}
+		ws.mu.RUnlock()
+	}
+	return reports, nil
+}
+
+// analyzeFormula reports the function and the batch pattern of a formula with
+// the same checks as the level calculation.
+func analyzeFormula(sheet, cell, formula string) FormulaReport {
+	report := FormulaReport{Sheet: sheet, Cell: cell, Formula: formula, Pattern: batchPatternOf(formula)}
+	if report.Pattern == "INDEX-MATCH" && extractINDEXMATCHFromFormula(formula) == "" {
+		report.Pattern = ""
+	}
+	if report.Pattern == "" && isConstantFormula(formula) {
+		report.Pattern = auditOptimizerConstant
+	}
+	ps := efp.ExcelParser()
+	for _, token := range ps.Parse(strings.TrimPrefix(strings.TrimSpace(formula), "=")) {
+		if token.TType == efp.TokenTypeFunction && token.TSubType == efp.TokenSubTypeStart {
+			report.Function = strings.TrimPrefix(strings.ToUpper(token.TValue), "_XLFN.")
+			break
+		}
+	}
+	if report.Pattern == "" {
+		report.Hint = batchableShapeHints[report.Function]
+	}
+	return report
+}
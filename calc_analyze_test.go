@@ -0,0 +1,60 @@
+package excelize
+
+import "testing"
+
+func TestAnalyzeFormulas(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1": "SUMIFS(Data!$C:$C,Data!$A:$A,$A1)",
+		"C1": "IFERROR(INDEX(Data!$B:$B,MATCH($A1,Data!$A:$A,0)),0)",
+		"D1": "VLOOKUP($A1,Data!$A:$C,3,TRUE)",
+		"E1": `"N/A"`,
+		"F1": "SUM(A1:A3)*2",
+		"G1": "XLOOKUP($A1,Data!$A:$A,Data!$C:$C)",
+		"H1": "A1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 共享公式按各单元格展开后分析
+	formulaType, ref := STCellFormulaTypeShared, "D1:D3"
+	if err := f.SetCellFormula("Data", "D1", "VLOOKUP(A1,Data!$A:$C,B1,FALSE)", FormulaOpts{Type: &formulaType, Ref: &ref}); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	reports, err := f.AnalyzeFormulas()
+	if err != nil {
+		t.Fatalf("analyze formulas: %v", err)
+	}
+	got := make(map[string]FormulaReport)
+	for _, report := range reports {
+		got[report.Sheet+"!"+report.Cell] = report
+	}
+	if len(reports) != 10 || reports[0].Cell != "B1" || reports[7].Sheet != "Data" {
+		t.Fatalf("unexpected reports %v", reports)
+	}
+	for cell, want := range map[string][2]string{
+		"Sheet1!B1": {"SUMIFS", "SUMIFS"},
+		"Sheet1!C1": {"IFERROR", "INDEX-MATCH"},
+		"Sheet1!D1": {"VLOOKUP", ""},
+		"Sheet1!E1": {"", "constant"},
+		"Sheet1!F1": {"SUM", ""},
+		"Sheet1!G1": {"XLOOKUP", "XLOOKUP"},
+		"Sheet1!H1": {"", ""},
+		"Data!D3":   {"VLOOKUP", ""},
+	} {
+		if report := got[cell]; report.Function != want[0] || report.Pattern != want[1] {
+			t.Fatalf("unexpected %s report %+v, want function %q and pattern %q", cell, report, want[0], want[1])
+		}
+	}
+	if got["Sheet1!D1"].Hint != batchableShapeHints["VLOOKUP"] || got["Sheet1!F1"].Hint != "" || got["Sheet1!B1"].Hint != "" {
+		t.Fatalf("unexpected hints %q, %q, %q", got["Sheet1!D1"].Hint, got["Sheet1!F1"].Hint, got["Sheet1!B1"].Hint)
+	}
+	if got["Data!D3"].Formula != "VLOOKUP(A3,Data!$A:$C,B3,FALSE)" {
+		t.Fatalf("unexpected shared formula %q", got["Data!D3"].Formula)
+	}
+}
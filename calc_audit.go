@@ -0,0 +1,115 @@
+package excelize
+
+import "sync"
+
+// defaultAuditTrailLimit is the default maximum number of entries of the
+// audit trail of a recalculation.
+const defaultAuditTrailLimit = 100000
+
+// The optimizers recorded in the audit trail, besides the names of the batch
+// patterns returned by batchPatternOf.
+const (
+	auditOptimizerConstant = "constant" // constant formulas calculated once per distinct formula
+	auditOptimizerPreCalc  = "precalc"  // simple formulas calculated before the batch patterns
+	auditOptimizerCell     = "cell"     // formulas calculated one by one by the DAG scheduler
+)
+
+// CalcAuditEntry directly maps a value computed by a dependency based
+// recalculation with CalcTuning.AuditTrail enabled. Cell is the formula cell
+// like "Sheet1!B2", Level is the dependency level calculating it and
+// Optimizer is the path which produced the value: "constant" for the formulas
+// referencing nothing, like ="N/A", calculated once per distinct formula,
+// "precalc" for the simple formulas calculated at the start of a level, the
+// name of the batch pattern like "SUMIFS", "INDEX-MATCH", "VLOOKUP",
+// "XLOOKUP", "HLOOKUP", "LOOKUP", "SUMPRODUCT", "MAX/MIN", "COUNTIF",
+// "LOOKUP-CHAIN" or "AVERAGE-OFFSET" for the values produced by the batch calculators, and
+// "cell" for the formulas calculated one by one.
+type CalcAuditEntry struct {
+	Level     int
+	Cell      string
+	Value     string
+	Optimizer string
+}
+
+// calcAuditLog is the append-only audit trail of a recalculation, bounded by
+// a maximum number of entries.
+type calcAuditLog struct {
+	mu      sync.Mutex
+	limit   int
+	entries []CalcAuditEntry
+	dropped int
+}
+
+// newCalcAuditLog creates the audit trail of a recalculation, the default
+// limit applies if limit is 0 and a negative limit doesn't bound the trail.
+func newCalcAuditLog(limit int) *calcAuditLog {
+	if limit == 0 {
+		limit = defaultAuditTrailLimit
+	}
+	return &calcAuditLog{limit: limit}
+}
+
+// add appends an entry to the audit trail, the entries beyond the limit are
+// dropped.
+func (l *calcAuditLog) add(entry CalcAuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit > 0 && len(l.entries) >= l.limit {
+		l.dropped++
+		return
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// recordAuditLevel appends the values of the formulas of a level found in the
+// worksheet cache and not recorded yet, and marks them as recorded. Unless
+// the optimizer is the DAG scheduler, the values of the batch pattern
+// formulas are attributed to their pattern and the other formulas to the
+// optimizer.
+func (f *File) recordAuditLevel(audit *calcAuditLog, levelIdx int, levelCells []string, graph *dependencyGraph, worksheetCache *WorksheetCache, recorded map[string]bool, optimizer string) {
+	for _, cell := range levelCells {
+		if recorded[cell] {
+			continue
+		}
+		sheet, cellName, ok := splitSheetReference(cell)
+		if !ok {
+			continue
+		}
+		arg, found := worksheetCache.Get(sheet, cellName)
+		if !found {
+			continue
+		}
+		recorded[cell] = true
+		source := optimizer
+		if node, exists := graph.nodes[cell]; exists && optimizer != auditOptimizerCell {
+			if pattern := batchPatternOf(node.formula); pattern != "" {
+				source = pattern
+			}
+		}
+		audit.add(CalcAuditEntry{Level: levelIdx, Cell: cell, Value: arg.Value(), Optimizer: source})
+	}
+}
+
+// CalcAuditTrail returns the values computed level by level by the last
+// dependency based recalculation with CalcTuning.AuditTrail enabled, in the
+// order of the levels. It helps to replay how a final value was derived from
+// the intermediate values. The trail holds up to CalcTuning.AuditTrailLimit
+// entries, the later entries are dropped. For example:
+//
+//	f.SetCalcTuning(excelize.CalcTuning{AuditTrail: true})
+//	if err := f.RecalculateAllWithDependency(); err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, entry := range f.CalcAuditTrail() {
+//	    fmt.Println(entry.Level, entry.Cell, entry.Value, entry.Optimizer)
+//	}
+func (f *File) CalcAuditTrail() []CalcAuditEntry {
+	audit := f.calcAudit.Load()
+	if audit == nil {
+		return nil
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	return append([]CalcAuditEntry(nil), audit.entries...)
+}
@@ -0,0 +1,92 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestCalcAuditTrail(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	// 一条简单的计算链 B1 -> C1 -> D1，以及一列可批量计算的纯 SUMIFS
+	if err := f.SetCellValue("Sheet1", "A1", 10); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	chain := map[string]string{"B1": "A1*2", "C1": "B1+1", "D1": "C1*3"}
+	for cell, formula := range chain {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 12; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{row % 3, row}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("G%d", row), row%3); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("H%d", row), fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,$G%d)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 未开启审计模式时不记录
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if trail := f.CalcAuditTrail(); trail != nil {
+		t.Fatalf("unexpected audit trail %v without the audit mode", trail)
+	}
+
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	trail := f.CalcAuditTrail()
+	entries := make(map[string]CalcAuditEntry, len(trail))
+	for i, entry := range trail {
+		if _, ok := entries[entry.Cell]; ok {
+			t.Fatalf("duplicate audit entry %v", entry)
+		}
+		if i > 0 && entry.Level < trail[i-1].Level {
+			t.Fatalf("audit entry %v recorded after level %d", entry, trail[i-1].Level)
+		}
+		entries[entry.Cell] = entry
+	}
+	if len(entries) != 15 {
+		t.Fatalf("unexpected %d audit entries, want 15: %v", len(entries), trail)
+	}
+	// 链上每一层的中间值都被记录，且层级递增
+	for i, expected := range []struct{ cell, value string }{
+		{"Sheet1!B1", "20"}, {"Sheet1!C1", "21"}, {"Sheet1!D1", "63"},
+	} {
+		entry := entries[expected.cell]
+		if entry.Value != expected.value || entry.Optimizer != auditOptimizerPreCalc {
+			t.Fatalf("unexpected audit entry %v, want value %s by %s", entry, expected.value, auditOptimizerPreCalc)
+		}
+		if i > 0 && entry.Level <= entries["Sheet1!B1"].Level {
+			t.Fatalf("unexpected level of audit entry %v after B1 at level %d", entry, entries["Sheet1!B1"].Level)
+		}
+	}
+	if entry := entries["Sheet1!H1"]; entry.Value != "22" || entry.Optimizer != "SUMIFS" {
+		t.Fatalf("unexpected audit entry %v, want value 22 by SUMIFS", entry)
+	}
+
+	// 每次重算重新记录，超过上限的值被丢弃
+	f.SetCalcTuning(CalcTuning{AuditTrail: true, AuditTrailLimit: 2})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if trail := f.CalcAuditTrail(); len(trail) != 2 {
+		t.Fatalf("unexpected %d audit entries, want 2 by the limit", len(trail))
+	}
+}
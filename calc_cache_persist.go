@@ -0,0 +1,303 @@
+package excelize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// calcCacheSnapshotVersion is the version of the format written by
+// SaveCalcCache, a snapshot of another version is rejected by LoadCalcCache
+const calcCacheSnapshotVersion = 1
+
+// calcCacheSnapshot is the calculation cache written by SaveCalcCache
+type calcCacheSnapshot struct {
+	Version     int                  `json:"version"`
+	Fingerprint string               `json:"fingerprint"`
+	Entries     []calcCacheEntry     `json:"entries"`
+	Graph       *calcCacheGraphState `json:"graph,omitempty"`
+	Index       *calcCacheIndexState `json:"index,omitempty"`
+}
+
+// calcCacheEntry is a value of the calculation cache, a string or a formula
+// argument without list and matrix
+type calcCacheEntry struct {
+	Key     string  `json:"k"`
+	Arg     bool    `json:"a,omitempty"`
+	Type    ArgType `json:"t,omitempty"`
+	Number  float64 `json:"n,omitempty"`
+	String  string  `json:"s,omitempty"`
+	Boolean bool    `json:"b,omitempty"`
+	Error   string  `json:"e,omitempty"`
+}
+
+// calcCacheGraphState is the dependency graph reused by the incremental
+// recalculations
+type calcCacheGraphState struct {
+	Nodes          []calcCacheNode            `json:"nodes"`
+	Levels         [][]string                 `json:"levels"`
+	Columns        map[string]calcCacheColumn `json:"columns,omitempty"`
+	UnmergedLevels int                        `json:"unmergedLevels,omitempty"`
+}
+
+// calcCacheIndexState is the incremental index reused by the incremental
+// recalculations
+type calcCacheIndexState struct {
+	ReverseDeps    map[string][]string        `json:"reverseDeps,omitempty"`
+	ReverseColDeps map[string][]string        `json:"reverseColDeps,omitempty"`
+	Formulas       map[string]string          `json:"formulas,omitempty"`
+	Columns        map[string]calcCacheColumn `json:"columns,omitempty"`
+	CellToColKey   map[string]string          `json:"cellToColKey,omitempty"`
+}
+
+// calcCacheNode is a formula node of the dependency graph
+type calcCacheNode struct {
+	Cell         string   `json:"cell"`
+	Formula      string   `json:"formula"`
+	Dependencies []string `json:"deps,omitempty"`
+	Level        int      `json:"level"`
+	Cost         int64    `json:"cost,omitempty"`
+	Constant     bool     `json:"constant,omitempty"`
+}
+
+// calcCacheColumn is the column metadata of the dependency graph
+type calcCacheColumn struct {
+	HasFormulas bool  `json:"hasFormulas,omitempty"`
+	FormulaRows []int `json:"formulaRows,omitempty"`
+	MaxRow      int   `json:"maxRow"`
+}
+
+// SaveCalcCache writes the calculated formula values cached by the workbook,
+// and the dependency graph and index cached by the incremental recalculations
+// if any, to w with a fingerprint of the formulas, the input cell values and
+// the defined names of the workbook. A process opening the same workbook again
+// can load them by LoadCalcCache to skip the calculation of the unchanged
+// formulas. The cached ranges, lists, matrices and infinite numbers are not
+// written. It waits for a running dependency based recalculation to finish.
+// For example:
+//
+//	var buf bytes.Buffer
+//	if err := f.SaveCalcCache(&buf); err != nil {
+//	    fmt.Println(err)
+//	}
+func (f *File) SaveCalcCache(w io.Writer) error {
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+	fingerprint, err := f.calcCacheFingerprint()
+	if err != nil {
+		return err
+	}
+	snapshot := calcCacheSnapshot{Version: calcCacheSnapshotVersion, Fingerprint: fingerprint}
+	f.calcCache.Range(func(key, value interface{}) bool {
+		k, ok := key.(string)
+		if !ok {
+			return true
+		}
+		switch v := value.(type) {
+		case string:
+			snapshot.Entries = append(snapshot.Entries, calcCacheEntry{Key: k, String: v})
+		case formulaArg:
+			// JSON has no infinite and NaN numbers, the formulas are calculated again
+			if v.Type == ArgList || v.Type == ArgMatrix || math.IsInf(v.Number, 0) || math.IsNaN(v.Number) {
+				return true
+			}
+			snapshot.Entries = append(snapshot.Entries, calcCacheEntry{
+				Key: k, Arg: true, Type: v.Type, Number: v.Number, String: v.String, Boolean: v.Boolean, Error: v.Error,
+			})
+		}
+		return true
+	})
+	sort.Slice(snapshot.Entries, func(i, j int) bool { return snapshot.Entries[i].Key < snapshot.Entries[j].Key })
+	if f.depGraphCache.generation == f.formulaGeneration.Load() {
+		if f.depGraphCache.graph != nil {
+			snapshot.Graph = newCalcCacheGraphState(f.depGraphCache.graph)
+		}
+		if index := f.depGraphCache.index; index != nil {
+			snapshot.Index = &calcCacheIndexState{
+				ReverseDeps: index.reverseDeps, ReverseColDeps: index.reverseColDeps, Formulas: index.formulaMap,
+				Columns: newCalcCacheColumns(index.columnMetadata), CellToColKey: index.cellToColKey,
+			}
+		}
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// LoadCalcCache reads the calculated formula values, the dependency graph and
+// index written by SaveCalcCache from r into the caches of the workbook. It
+// returns ErrCalcCacheFingerprint without changing the caches if the
+// formulas, the input cell values or the defined names of the workbook differ
+// from the saved workbook. The loaded values are used by the following
+// calculations like the values they calculated, so after changing the cell
+// values of the workbook, recalculate the affected formulas by
+// RecalculateAffectedByCells or the other incremental recalculations as
+// usual. For example:
+//
+//	if err := f.LoadCalcCache(bytes.NewReader(buf.Bytes())); err != nil {
+//	    fmt.Println(err)
+//	}
+func (f *File) LoadCalcCache(r io.Reader) error {
+	var snapshot calcCacheSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+	if snapshot.Version != calcCacheSnapshotVersion {
+		return fmt.Errorf("unsupported calc cache version %d", snapshot.Version)
+	}
+	f.recalcMu.Lock()
+	defer f.recalcMu.Unlock()
+	fingerprint, err := f.calcCacheFingerprint()
+	if err != nil {
+		return err
+	}
+	if fingerprint != snapshot.Fingerprint {
+		return ErrCalcCacheFingerprint
+	}
+	for _, entry := range snapshot.Entries {
+		if !entry.Arg {
+			f.calcCache.Store(entry.Key, entry.String)
+			continue
+		}
+		f.calcCache.Store(entry.Key, formulaArg{
+			Type: entry.Type, Number: entry.Number, String: entry.String, Boolean: entry.Boolean, Error: entry.Error,
+		})
+	}
+	cache := f.validDependencyGraphCache()
+	if snapshot.Graph != nil {
+		cache.graph = snapshot.Graph.dependencyGraph(f)
+	}
+	if index := snapshot.Index; index != nil {
+		cache.index = &incrementalIndex{
+			reverseDeps: index.ReverseDeps, reverseColDeps: index.ReverseColDeps, formulaMap: index.Formulas,
+			columnMetadata: newColumnMetadata(index.Columns), cellToColKey: index.CellToColKey,
+		}
+	}
+	return nil
+}
+
+// newCalcCacheGraphState returns the serializable state of a dependency graph.
+func newCalcCacheGraphState(graph *dependencyGraph) *calcCacheGraphState {
+	state := &calcCacheGraphState{
+		Nodes:          make([]calcCacheNode, 0, len(graph.nodes)),
+		Levels:         graph.levels,
+		Columns:        newCalcCacheColumns(graph.columnMetadata),
+		UnmergedLevels: graph.unmergedLevels,
+	}
+	for _, node := range graph.nodes {
+		state.Nodes = append(state.Nodes, calcCacheNode{
+			Cell: node.cell, Formula: node.formula, Dependencies: node.dependencies,
+			Level: node.level, Cost: node.cost, Constant: node.constant,
+		})
+	}
+	sort.Slice(state.Nodes, func(i, j int) bool { return state.Nodes[i].Cell < state.Nodes[j].Cell })
+	return state
+}
+
+// newCalcCacheColumns returns the serializable state of the column metadata.
+func newCalcCacheColumns(columnMetadata map[string]*columnMeta) map[string]calcCacheColumn {
+	columns := make(map[string]calcCacheColumn, len(columnMetadata))
+	for key, meta := range columnMetadata {
+		column := calcCacheColumn{HasFormulas: meta.hasFormulas, MaxRow: meta.maxRow}
+		for row := range meta.formulaRows {
+			column.FormulaRows = append(column.FormulaRows, row)
+		}
+		sort.Ints(column.FormulaRows)
+		columns[key] = column
+	}
+	return columns
+}
+
+// newColumnMetadata returns the column metadata of the serializable state.
+func newColumnMetadata(columns map[string]calcCacheColumn) map[string]*columnMeta {
+	columnMetadata := make(map[string]*columnMeta, len(columns))
+	for key, column := range columns {
+		meta := &columnMeta{hasFormulas: column.HasFormulas, maxRow: column.MaxRow}
+		if column.FormulaRows != nil {
+			meta.formulaRows = make(map[int]bool, len(column.FormulaRows))
+			for _, row := range column.FormulaRows {
+				meta.formulaRows[row] = true
+			}
+		}
+		columnMetadata[key] = meta
+	}
+	return columnMetadata
+}
+
+// dependencyGraph returns the dependency graph of the state for the workbook.
+func (state *calcCacheGraphState) dependencyGraph(f *File) *dependencyGraph {
+	graph := &dependencyGraph{
+		nodes:             make(map[string]*formulaNode, len(state.Nodes)),
+		levels:            state.Levels,
+		columnMetadata:    newColumnMetadata(state.Columns),
+		calcLogger:        f.calcLogger,
+		maxMergeCostRatio: f.calcTuning.MaxMergeCostRatio,
+		calcWorkers:       f.calcConcurrency,
+		unmergedLevels:    state.UnmergedLevels,
+	}
+	for _, node := range state.Nodes {
+		graph.nodes[node.Cell] = &formulaNode{
+			cell: node.Cell, formula: node.Formula, dependencies: node.Dependencies,
+			level: node.Level, cost: node.Cost, constant: node.Constant,
+		}
+	}
+	return graph
+}
+
+// calcCacheFingerprint returns the SHA-256 hash of the formulas, the values
+// of the other cells and the defined names of the workbook. The cached values
+// of the formula cells are not hashed, so the fingerprint doesn't change by a
+// recalculation.
+func (f *File) calcCacheFingerprint() (string, error) {
+	sst, err := f.sharedStringsReader()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, sheet := range f.GetSheetList() {
+		if err := f.hashWorksheetCells(hash, sheet, sst); err != nil {
+			return "", err
+		}
+	}
+	for _, dn := range f.GetDefinedName() {
+		_, _ = fmt.Fprintf(hash, "name:%q:%q:%q\n", dn.Scope, dn.Name, dn.RefersTo)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashWorksheetCells writes the formulas and the values of the other cells of
+// a worksheet to the fingerprint hash.
+func (f *File) hashWorksheetCells(hash io.Writer, sheet string, sst *xlsxSST) error {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	_, _ = fmt.Fprintf(hash, "sheet:%q\n", sheet)
+	for _, row := range ws.SheetData.Row {
+		for i := range row.C {
+			c := &row.C[i]
+			if c.F != nil {
+				formula := c.F.Content
+				if formula == "" && c.F.T == STCellFormulaTypeShared && c.F.Si != nil {
+					formula, _ = getSharedFormula(ws, *c.F.Si, c.R)
+				}
+				_, _ = fmt.Fprintf(hash, "%s=%q\n", c.R, formula)
+				continue
+			}
+			value, err := c.getValueFrom(f, sst, true)
+			if err != nil {
+				return err
+			}
+			if value != "" {
+				_, _ = fmt.Fprintf(hash, "%s:%s:%q\n", c.R, c.T, value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package excelize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndLoadCalcCache(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 20; row++ {
+		assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{row, fmt.Sprintf("item-%d", row)}))
+		assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("A%d*2", row)))
+	}
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "SUM(C1:C20)"))
+	assert.NoError(t, f.SetDefinedName(&DefinedName{Name: "Items", RefersTo: "Sheet1!$B$1:$B$20"}))
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	assert.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}))
+	value, err := f.CalcCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "420", value)
+
+	var snapshot bytes.Buffer
+	assert.NoError(t, f.SaveCalcCache(&snapshot))
+	workbook, err := f.WriteToBuffer()
+	assert.NoError(t, err)
+
+	// 重新打开相同的工作簿，加载缓存的计算结果和依赖图
+	reopened, err := OpenReader(bytes.NewReader(workbook.Bytes()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = reopened.Close() })
+	assert.NoError(t, reopened.LoadCalcCache(bytes.NewReader(snapshot.Bytes())))
+	assert.Equal(t, f.CalcMemoryUsage().CalcCacheEntries, reopened.CalcMemoryUsage().CalcCacheEntries)
+	index := reopened.validDependencyGraphCache().index
+	if assert.NotNil(t, index) {
+		assert.Len(t, index.formulaMap, 21)
+		assert.Equal(t, []string{"Sheet1!C1"}, index.reverseDeps["Sheet1!A1"])
+	}
+	value, err = reopened.CalcCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "420", value)
+	// 加载后修改单元格的值仍需增量重算
+	assert.NoError(t, reopened.SetCellValue("Sheet1", "A1", 11))
+	assert.NoError(t, reopened.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}))
+	value, err = reopened.GetCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "440", value)
+
+	// 输入单元格、公式或定义名称不同的工作簿拒绝加载
+	for _, change := range []func(f *File) error{
+		func(f *File) error { return f.SetCellValue("Sheet1", "B2", "changed") },
+		func(f *File) error { return f.SetCellFormula("Sheet1", "C2", "A2*3") },
+		func(f *File) error { return f.SetDefinedName(&DefinedName{Name: "Other", RefersTo: "Sheet1!$A$1"}) },
+	} {
+		changed, err := OpenReader(bytes.NewReader(workbook.Bytes()))
+		assert.NoError(t, err)
+		assert.NoError(t, change(changed))
+		assert.Equal(t, ErrCalcCacheFingerprint, changed.LoadCalcCache(bytes.NewReader(snapshot.Bytes())))
+		assert.Zero(t, changed.CalcMemoryUsage().CalcCacheEntries)
+		assert.NoError(t, changed.Close())
+	}
+	// 依赖图与其状态往返转换后保持不变
+	graph, err := f.buildDependencyGraphWithContext(context.Background())
+	assert.NoError(t, err)
+	restored := newCalcCacheGraphState(graph).dependencyGraph(f)
+	assert.Equal(t, graph.levels, restored.levels)
+	assert.Equal(t, graph.columnMetadata, restored.columnMetadata)
+	assert.Equal(t, graph.nodes, restored.nodes)
+
+	assert.EqualError(t, reopened.LoadCalcCache(strings.NewReader(`{"version":0}`)), "unsupported calc cache version 0")
+	assert.Error(t, reopened.LoadCalcCache(strings.NewReader("{")))
+}
+
+func TestSaveCalcCacheInfiniteNumber(t *testing.T) {
+	f := NewFile()
+	defer func() { assert.NoError(t, f.Close()) }()
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", 2))
+	for cell, formula := range map[string]string{"B1": "A1*3", "C1": "1E308*10", "C2": "-1E308*10"} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", cell, formula))
+		_, err := f.CalcCellValue("Sheet1", cell)
+		assert.NoError(t, err)
+	}
+	infinite := func() (count int) {
+		f.calcCache.Range(func(_, value interface{}) bool {
+			if arg, ok := value.(formulaArg); ok && math.IsInf(arg.Number, 0) {
+				count++
+			}
+			return true
+		})
+		return
+	}
+	assert.Equal(t, 2, infinite())
+
+	// 无穷大的数值不写入快照，加载后重新计算
+	var snapshot bytes.Buffer
+	assert.NoError(t, f.SaveCalcCache(&snapshot))
+	f.calcCache.Clear()
+	assert.NoError(t, f.LoadCalcCache(bytes.NewReader(snapshot.Bytes())))
+	assert.Zero(t, infinite())
+	value, ok := f.calcCache.Load("Sheet1!B1")
+	assert.True(t, ok)
+	assert.Equal(t, newNumberFormulaArg(6), value)
+}
@@ -2,7 +2,6 @@ package excelize
 
 import (
 	"fmt"
-	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -135,7 +134,7 @@ func (f *File) CalcCellValuesDependencyAware(sheet string, cells []string, optio
 	}
 
 	// Calculate optimal number of workers and chunk size
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if len(remaining) < numWorkers {
 		numWorkers = len(remaining)
 	}
@@ -336,7 +335,7 @@ func (f *File) CalcAndUpdateCellValuesDependencyAware(sheet string, cells []stri
 	}
 
 	// Calculate optimal number of workers and chunk size
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if len(remaining) < numWorkers {
 		numWorkers = len(remaining)
 	}
@@ -0,0 +1,191 @@
+package excelize
+
+import (
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// defaultExplainDepth and defaultExplainRangeCells are the default limits of
+// the precedent tree built by ExplainCell.
+const (
+	defaultExplainDepth      = 8
+	defaultExplainRangeCells = 100
+)
+
+// ExplainOptions directly maps the limits of the precedent tree built by
+// ExplainCell. MaxDepth specifies the maximum number of the precedent levels
+// below the explained cell, the default 0 explains 8 levels. MaxRangeCells
+// specifies the maximum number of cells of a range reference expanded into
+// the precedent cells, the larger ranges and the whole column or row ranges
+// are kept as one precedent. The default 0 expands the ranges of up to 100
+// cells.
+type ExplainOptions struct {
+	MaxDepth      int
+	MaxRangeCells int
+}
+
+// CalcTree directly maps a node of the precedent tree of a cell built by
+// ExplainCell. Cell is the cell like "Sheet1!B2", or the range like
+// "Data!A:A" for a range reference which isn't expanded. Formula is empty for
+// the cells without a formula. Precedents are the cells and ranges the
+// formula references, in the order of the formula. Cycle is true if the cell
+// is already on the path from the explained cell, the precedents of the cell
+// are omitted in that case. Truncated is true if the precedents were omitted
+// by the depth limit, or the node is a range which isn't expanded.
+type CalcTree struct {
+	Cell       string
+	Formula    string
+	Value      string
+	Precedents []*CalcTree
+	Cycle      bool
+	Truncated  bool
+}
+
+// ExplainCell returns the calculated value of a cell with the tree of its
+// precedent cells, their values and formulas, which shows how the value was
+// calculated. The values are read from the calculation cache, so explaining
+// a cell after a recalculation doesn't calculate the formulas again. For
+// example, print the cells the value of Sheet1!D1 was calculated from:
+//
+//	tree, err := f.ExplainCell("Sheet1", "D1")
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	var print func(node *excelize.CalcTree, indent string)
+//	print = func(node *excelize.CalcTree, indent string) {
+//	    fmt.Printf("%s%s = %s %s\n", indent, node.Cell, node.Value, node.Formula)
+//	    for _, precedent := range node.Precedents {
+//	        print(precedent, indent+"  ")
+//	    }
+//	}
+//	print(tree, "")
+func (f *File) ExplainCell(sheet, cell string, opts ...ExplainOptions) (*CalcTree, error) {
+	options := ExplainOptions{MaxDepth: defaultExplainDepth, MaxRangeCells: defaultExplainRangeCells}
+	for _, opt := range opts {
+		if opt.MaxDepth > 0 {
+			options.MaxDepth = opt.MaxDepth
+		}
+		if opt.MaxRangeCells > 0 {
+			options.MaxRangeCells = opt.MaxRangeCells
+		}
+	}
+	cell = strings.ReplaceAll(cell, "$", "")
+	if _, _, err := CellNameToCoordinates(cell); err != nil {
+		return nil, err
+	}
+	return f.explainCell(sheet, cell, 0, options, make(map[string]bool))
+}
+
+// explainCell builds the precedent tree of a cell, path holds the cells from
+// the explained cell to the parent of the cell for detecting the cycles.
+func (f *File) explainCell(sheet, cell string, depth int, options ExplainOptions, path map[string]bool) (*CalcTree, error) {
+	ref := sheet + "!" + cell
+	formula, err := f.GetCellFormula(sheet, cell)
+	if err != nil {
+		return nil, err
+	}
+	node := &CalcTree{Cell: ref, Formula: formula}
+	if formula == "" {
+		node.Value, err = f.GetCellValue(sheet, cell, Options{RawCellValue: true})
+		return node, err
+	}
+	if cached, ok := f.calcCache.Load(ref + "!raw=true"); ok {
+		node.Value, _ = cached.(string)
+	} else if node.Value, err = f.CalcCellValue(sheet, cell, Options{RawCellValue: true}); err != nil && node.Value == "" {
+		// 计算错误作为单元格的值，如 #NAME?
+		node.Value = err.Error()
+	}
+	if path[ref] {
+		node.Cycle = true
+		return node, nil
+	}
+	precedents := explainPrecedents(formula, sheet, options.MaxRangeCells)
+	if depth >= options.MaxDepth {
+		node.Truncated = len(precedents) > 0
+		return node, nil
+	}
+	path[ref] = true
+	defer delete(path, ref)
+	for _, precedent := range precedents {
+		if precedent.cell == "" {
+			node.Precedents = append(node.Precedents, &CalcTree{Cell: precedent.sheet + "!" + precedent.ref, Truncated: true})
+			continue
+		}
+		child, err := f.explainCell(precedent.sheet, precedent.cell, depth+1, options, path)
+		if err != nil {
+			// 引用了不存在的工作表等无法读取的单元格
+			child = &CalcTree{Cell: precedent.sheet + "!" + precedent.cell, Value: formulaErrorREF}
+		}
+		node.Precedents = append(node.Precedents, child)
+	}
+	return node, nil
+}
+
+// explainPrecedent is a cell or an unexpanded range referenced by a formula.
+type explainPrecedent struct {
+	sheet, cell, ref string
+}
+
+// explainPrecedents returns the cells and the ranges referenced by a formula,
+// in the order of the formula without duplicates. The ranges of up to
+// maxRangeCells cells are expanded into their cells.
+func explainPrecedents(formula, currentSheet string, maxRangeCells int) []explainPrecedent {
+	var precedents []explainPrecedent
+	seen := make(map[explainPrecedent]bool)
+	add := func(p explainPrecedent) {
+		if !seen[p] {
+			seen[p] = true
+			precedents = append(precedents, p)
+		}
+	}
+	ps := efp.ExcelParser()
+	tokens := expandImplicitIntersection(ps.Parse(formula))
+	for _, token := range tokens {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		sheet, ref := currentSheet, token.TValue
+		if strings.Contains(ref, "!") {
+			var ok bool
+			if sheet, ref, ok = splitSheetReference(ref); !ok {
+				continue
+			}
+		}
+		ref = strings.ReplaceAll(ref, "$", "")
+		start, end, isRange := strings.Cut(ref, ":")
+		if !isRange {
+			// 定义名称等非单元格引用不作为引用单元格
+			if _, _, err := CellNameToCoordinates(ref); err == nil {
+				add(explainPrecedent{sheet: sheet, cell: ref})
+			}
+			continue
+		}
+		startCol, startRow, err1 := CellNameToCoordinates(start)
+		endCol, endRow, err2 := CellNameToCoordinates(end)
+		if err1 != nil || err2 != nil {
+			// 整列或整行引用不展开
+			add(explainPrecedent{sheet: sheet, ref: ref})
+			continue
+		}
+		if startCol > endCol {
+			startCol, endCol = endCol, startCol
+		}
+		if startRow > endRow {
+			startRow, endRow = endRow, startRow
+		}
+		if (endCol-startCol+1)*(endRow-startRow+1) > maxRangeCells {
+			add(explainPrecedent{sheet: sheet, ref: ref})
+			continue
+		}
+		for row := startRow; row <= endRow; row++ {
+			for col := startCol; col <= endCol; col++ {
+				if cell, err := CoordinatesToCellName(col, row); err == nil {
+					add(explainPrecedent{sheet: sheet, cell: cell})
+				}
+			}
+		}
+	}
+	return precedents
+}
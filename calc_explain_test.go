@@ -0,0 +1,107 @@
+package excelize
+
+import (
+	"strings"
+	"testing"
+)
+
+// formatCalcTree formats a precedent tree as "cell=value[flags](precedents)".
+func formatCalcTree(node *CalcTree) string {
+	var b strings.Builder
+	b.WriteString(node.Cell + "=" + node.Value)
+	if node.Cycle {
+		b.WriteString("[cycle]")
+	}
+	if node.Truncated {
+		b.WriteString("[truncated]")
+	}
+	if len(node.Precedents) > 0 {
+		precedents := make([]string, len(node.Precedents))
+		for i, precedent := range node.Precedents {
+			precedents[i] = formatCalcTree(precedent)
+		}
+		b.WriteString("(" + strings.Join(precedents, " ") + ")")
+	}
+	return b.String()
+}
+
+func TestExplainCell(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 菱形依赖：D1 依赖 B1 和 C1，二者都依赖 A1
+	if err := f.SetCellValue("Sheet1", "A1", 5); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1": "A1*2", "C1": "$A$1+1", "D1": "B1+C1",
+		"G1": "SUM(A1:A3)+SUM(Data!A:A)", "H1": "Missing!A1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellValue("Data", "A1", 4); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+
+	for _, c := range []struct {
+		cell string
+		opts []ExplainOptions
+		want string
+	}{
+		{"D1", nil, "Sheet1!D1=16(Sheet1!B1=10(Sheet1!A1=5) Sheet1!C1=6(Sheet1!A1=5))"},
+		{"D1", []ExplainOptions{{MaxDepth: 1}}, "Sheet1!D1=16(Sheet1!B1=10[truncated] Sheet1!C1=6[truncated])"},
+		{"A1", nil, "Sheet1!A1=5"},
+		{"G1", nil, "Sheet1!G1=9(Sheet1!A1=5 Sheet1!A2= Sheet1!A3= Data!A:A=[truncated])"},
+		{"G1", []ExplainOptions{{MaxRangeCells: 2}}, "Sheet1!G1=9(Sheet1!A1:A3=[truncated] Data!A:A=[truncated])"},
+		{"H1", nil, "Sheet1!H1=#NAME?(Missing!A1=#REF!)"},
+	} {
+		tree, err := f.ExplainCell("Sheet1", c.cell, c.opts...)
+		if err != nil {
+			t.Fatalf("explain %s: %v", c.cell, err)
+		}
+		if got := formatCalcTree(tree); got != c.want {
+			t.Fatalf("unexpected tree of %s %v:\n%s\nwant:\n%s", c.cell, c.opts, got, c.want)
+		}
+		if c.cell == "D1" && len(c.opts) == 0 && tree.Formula != "B1+C1" {
+			t.Fatalf("unexpected formula %q", tree.Formula)
+		}
+	}
+
+	// 重算后直接使用计算缓存中的值
+	if err := f.SetCellValue("Sheet1", "A1", 7); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if tree, err := f.ExplainCell("Sheet1", "$D$1"); err != nil || tree.Value != "22" || tree.Precedents[0].Value != "14" {
+		t.Fatalf("unexpected tree after recalculation %v, %v", tree, err)
+	}
+
+	// 循环引用在回到路径上的单元格时停止
+	for cell, formula := range map[string]string{"E1": "F1+1", "F1": "E1+1"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	tree, err := f.ExplainCell("Sheet1", "E1")
+	if err != nil {
+		t.Fatalf("explain E1: %v", err)
+	}
+	if len(tree.Precedents) != 1 || len(tree.Precedents[0].Precedents) != 1 {
+		t.Fatalf("unexpected tree of a cycle %s", formatCalcTree(tree))
+	}
+	if cycle := tree.Precedents[0].Precedents[0]; cycle.Cell != "Sheet1!E1" || !cycle.Cycle || len(cycle.Precedents) != 0 {
+		t.Fatalf("unexpected tree of a cycle %s", formatCalcTree(tree))
+	}
+
+	for _, c := range []struct{ sheet, cell string }{{"Sheet1", "A0"}, {"Missing", "A1"}} {
+		if _, err := f.ExplainCell(c.sheet, c.cell); err == nil {
+			t.Fatalf("expected an error explaining %s!%s", c.sheet, c.cell)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package excelize
+
+import "unsafe"
+
+// calcMemoryEntryOverhead is the approximate size of an entry of a map or a
+// cache besides its key and value: the hash bucket slot, the pointers and the
+// string headers
+const calcMemoryEntryOverhead = 48
+
+// CalcMemoryReport directly maps the approximate memory held by the caches a
+// File retains between the formula calculations, returned by
+// CalcMemoryUsage. The sizes are estimated from the lengths of the cached
+// strings and slices, without the allocator overhead.
+//
+// CalcCacheBytes and RangeCacheBytes are the cached cell values and range
+// matrices released by ClearFormulaCache, CalcCacheEntries and
+// RangeCacheEntries their number of entries. DependencyGraphBytes is the
+// dependency graph and the incremental index reused by the incremental
+// recalculations, released when the formulas change. The worksheet caches of
+// the dependency based recalculations are released when they finish, so they
+// are not reported. TotalBytes is the sum of the sizes.
+type CalcMemoryReport struct {
+	CalcCacheEntries     int
+	CalcCacheBytes       int64
+	RangeCacheEntries    int
+	RangeCacheBytes      int64
+	DependencyGraphBytes int64
+	TotalBytes           int64
+}
+
+// CalcMemoryUsage returns the approximate memory held by the formula
+// calculation caches of the workbook, so that a long-lived File can decide
+// when to call ClearFormulaCache. It traverses the caches, which takes time
+// proportional to their entries, and waits for a running dependency based
+// recalculation to finish. For example:
+//
+//	if report := f.CalcMemoryUsage(); report.TotalBytes > 512<<20 {
+//	    f.ClearFormulaCache()
+//	}
+func (f *File) CalcMemoryUsage() CalcMemoryReport {
+	var report CalcMemoryReport
+	f.calcCache.Range(func(key, value interface{}) bool {
+		report.CalcCacheEntries++
+		report.CalcCacheBytes += calcMemoryEntryOverhead + calcMemoryValueSize(key) + calcMemoryValueSize(value)
+		return true
+	})
+	f.rangeCache.Range(func(key string, value interface{}) bool {
+		report.RangeCacheEntries++
+		report.RangeCacheBytes += calcMemoryEntryOverhead + int64(len(key)) + calcMemoryValueSize(value)
+		return true
+	})
+
+	// 依赖图缓存由 recalcMu 保护，公式变化后缓存已失效的依赖图不再计入
+	f.recalcMu.Lock()
+	if f.depGraphCache.generation == f.formulaGeneration.Load() {
+		report.DependencyGraphBytes = f.depGraphCache.graph.memorySize() + f.depGraphCache.index.memorySize()
+	}
+	f.recalcMu.Unlock()
+
+	report.TotalBytes = report.CalcCacheBytes + report.RangeCacheBytes + report.DependencyGraphBytes
+	return report
+}
+
+// calcMemoryValueSize returns the approximate size of a value of the
+// calculation caches: a string, a formula argument or a range matrix.
+func calcMemoryValueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(unsafe.Sizeof(v)) + int64(len(v))
+	case formulaArg:
+		return formulaArgMemorySize(v)
+	case [][]formulaArg:
+		return formulaMatrixMemorySize(v)
+	}
+	return int64(unsafe.Sizeof(value))
+}
+
+// formulaArgMemorySize returns the approximate size of a formula argument,
+// including the elements of its list and matrix.
+func formulaArgMemorySize(arg formulaArg) int64 {
+	size := int64(unsafe.Sizeof(arg)) + int64(len(arg.SheetName)+len(arg.String)+len(arg.Error))
+	for _, item := range arg.List {
+		size += formulaArgMemorySize(item)
+	}
+	return size + formulaMatrixMemorySize(arg.Matrix)
+}
+
+// formulaMatrixMemorySize returns the approximate size of the rows of a range
+// matrix.
+func formulaMatrixMemorySize(matrix [][]formulaArg) int64 {
+	var size int64
+	for _, row := range matrix {
+		size += int64(unsafe.Sizeof(row))
+		for _, cell := range row {
+			size += formulaArgMemorySize(cell)
+		}
+	}
+	return size
+}
+
+// stringsMemorySize returns the approximate size of a slice of strings.
+func stringsMemorySize(values []string) int64 {
+	size := int64(unsafe.Sizeof(values))
+	for _, value := range values {
+		size += int64(unsafe.Sizeof(value)) + int64(len(value))
+	}
+	return size
+}
+
+// columnMetadataMemorySize returns the approximate size of the column
+// metadata of a dependency graph or an incremental index.
+func columnMetadataMemorySize(columnMetadata map[string]*columnMeta) int64 {
+	var size int64
+	for key, meta := range columnMetadata {
+		size += calcMemoryEntryOverhead + int64(len(key)+int(unsafe.Sizeof(*meta))) + int64(len(meta.formulaRows))*calcMemoryEntryOverhead
+	}
+	return size
+}
+
+// memorySize returns the approximate size of the dependency graph, 0 for a
+// nil graph. The cells of the nodes and the levels share the strings of the
+// node keys, so only their headers are counted.
+func (g *dependencyGraph) memorySize() int64 {
+	if g == nil {
+		return 0
+	}
+	var size int64
+	for cell, node := range g.nodes {
+		size += calcMemoryEntryOverhead + int64(len(cell)) + int64(unsafe.Sizeof(*node)) +
+			int64(len(node.formula)) + stringsMemorySize(node.dependencies)
+	}
+	for _, cells := range g.levels {
+		size += int64(unsafe.Sizeof(cells)) + int64(len(cells))*int64(unsafe.Sizeof(""))
+	}
+	return size + columnMetadataMemorySize(g.columnMetadata)
+}
+
+// memorySize returns the approximate size of the incremental index, 0 for a
+// nil index.
+func (idx *incrementalIndex) memorySize() int64 {
+	if idx == nil {
+		return 0
+	}
+	var size int64
+	for _, deps := range []map[string][]string{idx.reverseDeps, idx.reverseColDeps} {
+		for key, cells := range deps {
+			size += calcMemoryEntryOverhead + int64(len(key)) + stringsMemorySize(cells)
+		}
+	}
+	for _, values := range []map[string]string{idx.formulaMap, idx.cellToColKey} {
+		for key, value := range values {
+			size += calcMemoryEntryOverhead + int64(len(key)+len(value))
+		}
+	}
+	return size + columnMetadataMemorySize(idx.columnMetadata)
+}
@@ -0,0 +1,61 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestCalcMemoryUsage(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 200; row++ {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{row, fmt.Sprintf("item-%d", row)}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("A%d*2+SUM($A$1:$A$10)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	empty := f.CalcMemoryUsage()
+	if empty.TotalBytes != 0 || empty.CalcCacheEntries != 0 {
+		t.Fatalf("unexpected usage before recalculation %+v", empty)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "A1", 5); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalculate affected: %v", err)
+	}
+	used := f.CalcMemoryUsage()
+	if used.CalcCacheEntries == 0 || used.CalcCacheBytes <= 0 || used.DependencyGraphBytes <= 0 {
+		t.Fatalf("unexpected usage after recalculation %+v", used)
+	}
+	if used.TotalBytes != used.CalcCacheBytes+used.RangeCacheBytes+used.DependencyGraphBytes {
+		t.Fatalf("unexpected total of %+v", used)
+	}
+
+	f.ClearFormulaCache()
+	cleared := f.CalcMemoryUsage()
+	if cleared.CalcCacheBytes != 0 || cleared.RangeCacheBytes != 0 || cleared.TotalBytes >= used.TotalBytes {
+		t.Fatalf("unexpected usage after clearing %+v, before %+v", cleared, used)
+	}
+	f.InvalidateDependencyGraph()
+	if report := f.CalcMemoryUsage(); report.TotalBytes != 0 {
+		t.Fatalf("unexpected usage after invalidating the graph %+v", report)
+	}
+
+	arg := newMatrixFormulaArg([][]formulaArg{{newStringFormulaArg("ab"), newNumberFormulaArg(1)}})
+	if size := calcMemoryValueSize(arg); size <= formulaMatrixMemorySize(arg.Matrix) {
+		t.Fatalf("unexpected size %d of a matrix argument", size)
+	}
+}
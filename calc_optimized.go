@@ -2,7 +2,6 @@ package excelize
 
 import (
 	"fmt"
-	"runtime"
 	"strings"
 	"sync"
 )
@@ -15,7 +14,7 @@ func (f *File) CalcCellValuesConcurrent(sheet string, cells []string, opts ...Op
 	}
 
 	// Determine optimal worker count
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if len(cells) < numWorkers {
 		numWorkers = len(cells)
 	}
@@ -0,0 +1,97 @@
+package excelize
+
+// CalcPlan reports how the last completed dependency based recalculation was
+// planned: the size of the dependency graph and how many formulas the batch
+// optimizers recognized. The distinct counts show how much work the batch
+// calculation shared, e.g. 1000 SUMIFS formulas with 20 distinct expressions
+// over 2 source groups only scan their source data twice.
+type CalcPlan struct {
+	Levels   int // number of dependency levels
+	Formulas int // number of calculated formulas
+
+	PureSUMIFS         int // formulas which are a single SUMIFS/AVERAGEIFS
+	DistinctSUMIFS     int // distinct SUMIFS/AVERAGEIFS expressions
+	SUMIFSSourceGroups int // distinct sum and criteria columns of composite SUMIFS, row-shifted ranges count once
+
+	INDEXMATCHFormulas int // formulas containing INDEX-MATCH
+	DistinctINDEXMATCH int // distinct INDEX-MATCH expressions
+
+	VLOOKUPFormulas int // formulas which are a single exact match VLOOKUP
+	VLOOKUPTables   int // distinct tables scanned for VLOOKUP
+
+	XLOOKUPFormulas int // formulas which are a single exact match XLOOKUP
+	XLOOKUPColumns  int // distinct lookup columns scanned for XLOOKUP
+
+	HLOOKUPFormulas int // formulas which are a single exact match HLOOKUP
+	HLOOKUPTables   int // distinct tables scanned for HLOOKUP
+
+	LOOKUPFormulas int // formulas which are a single LOOKUP over a column
+	LOOKUPVectors  int // distinct lookup vectors scanned for LOOKUP
+
+	SUMPRODUCTFormulas int // formulas which are a SUMPRODUCT of conditions and a value range like SUMPRODUCT((range=cell)*(range>0)*range)
+	SUMPRODUCTPatterns int // distinct value and criteria ranges scanned for SUMPRODUCT
+
+	ColumnAggregateFormulas int // formulas containing MAX/MIN over a single column range
+	ColumnAggregateRanges   int // distinct column ranges scanned for MAX/MIN
+
+	COUNTIFFormulas int // formulas which are a single COUNTIF or COUNTA over a column range
+	COUNTIFRanges   int // distinct column ranges scanned for COUNTIF and COUNTA
+
+	AverageOffsetFormulas int // AVERAGE(OFFSET(...)) formulas
+
+	ConstantFormulas  int // formulas which reference nothing, like ="N/A"
+	DistinctConstants int // distinct constant formulas calculated
+
+	RetainedSubExpressions int // sub-expressions reused from former recalculations, see CalcTuning.RetainSubExpressions
+
+	// Set by the cell-level incremental recalculations like RecalculateAffectedByCells
+	VolatileFormulas      int  // formulas calling NOW, RAND, OFFSET, INDIRECT or another volatile function and their dependents, recalculated every time
+	FullRecalcRecommended bool // the volatile formulas are more than half of the formulas, the incremental recalculation without excluded cells falls back to a full recalculation
+}
+
+// add accumulates the pattern counts of a level into the plan.
+func (p *CalcPlan) add(level CalcPlan) {
+	p.PureSUMIFS += level.PureSUMIFS
+	p.DistinctSUMIFS += level.DistinctSUMIFS
+	p.SUMIFSSourceGroups += level.SUMIFSSourceGroups
+	p.INDEXMATCHFormulas += level.INDEXMATCHFormulas
+	p.DistinctINDEXMATCH += level.DistinctINDEXMATCH
+	p.VLOOKUPFormulas += level.VLOOKUPFormulas
+	p.VLOOKUPTables += level.VLOOKUPTables
+	p.XLOOKUPFormulas += level.XLOOKUPFormulas
+	p.XLOOKUPColumns += level.XLOOKUPColumns
+	p.HLOOKUPFormulas += level.HLOOKUPFormulas
+	p.HLOOKUPTables += level.HLOOKUPTables
+	p.LOOKUPFormulas += level.LOOKUPFormulas
+	p.LOOKUPVectors += level.LOOKUPVectors
+	p.SUMPRODUCTFormulas += level.SUMPRODUCTFormulas
+	p.SUMPRODUCTPatterns += level.SUMPRODUCTPatterns
+	p.ColumnAggregateFormulas += level.ColumnAggregateFormulas
+	p.ColumnAggregateRanges += level.ColumnAggregateRanges
+	p.COUNTIFFormulas += level.COUNTIFFormulas
+	p.COUNTIFRanges += level.COUNTIFRanges
+	p.AverageOffsetFormulas += level.AverageOffsetFormulas
+	p.ConstantFormulas += level.ConstantFormulas
+	p.DistinctConstants += level.DistinctConstants
+	p.RetainedSubExpressions += level.RetainedSubExpressions
+}
+
+// LastCalcPlan returns the plan of the last completed dependency based
+// recalculation, such as RecalculateAllWithDependency or an incremental
+// recalculation. The pattern counts are summed over all levels. For example:
+//
+//	if err := f.RecalculateAllWithDependency(); err != nil {
+//	    fmt.Println(err)
+//	}
+//	plan, err := f.LastCalcPlan()
+//	if err != nil {
+//	    fmt.Println(err)
+//	}
+//	fmt.Println(plan.PureSUMIFS, plan.DistinctSUMIFS)
+func (f *File) LastCalcPlan() (CalcPlan, error) {
+	plan := f.lastCalcPlan.Load()
+	if plan == nil {
+		return CalcPlan{}, ErrDependencyGraphNotBuilt
+	}
+	return *plan, nil
+}
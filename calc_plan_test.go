@@ -0,0 +1,78 @@
+package excelize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestLastCalcPlan(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.LastCalcPlan(); !errors.Is(err, ErrDependencyGraphNotBuilt) {
+		t.Fatalf("expected ErrDependencyGraphNotBuilt before a recalculation, got %v", err)
+	}
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{
+		{"a", "x", 1}, {"b", "x", 2}, {"a", "y", 3}, {"b", "y", 4},
+	} {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", i+1), &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	// 6 个纯 SUMIFS 公式，3 个不同的表达式
+	for i, key := range []string{"a", "b", "a", "b", "a", "b"} {
+		formula := fmt.Sprintf(`SUMIFS(Data!$C:$C,Data!$A:$A,"%s")`, key)
+		if i >= 4 {
+			formula = `SUMIFS(Data!$C:$C,Data!$B:$B,"x")`
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("A%d", i+1), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 4 个 INDEX-MATCH 公式，2 个不同的表达式
+	for i := 1; i <= 4; i++ {
+		key := []string{"a", "b"}[i%2]
+		formula := fmt.Sprintf(`INDEX(Data!$C:$C,MATCH("%s",Data!$A:$A,0))`, key)
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", i), formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	// 3 个 MAX/MIN 公式，2 个不同的列范围
+	for cell, formula := range map[string]string{
+		"C1": "MAX(Data!C1:C4)", "C2": "MIN(Data!C1:C4)", "C3": "MAX(Data!A:A)",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	plan, err := f.LastCalcPlan()
+	if err != nil {
+		t.Fatalf("LastCalcPlan failed: %v", err)
+	}
+	want := CalcPlan{
+		Levels: 1, Formulas: 13,
+		PureSUMIFS: 6, DistinctSUMIFS: 3,
+		INDEXMATCHFormulas: 4, DistinctINDEXMATCH: 2,
+		ColumnAggregateFormulas: 3, ColumnAggregateRanges: 2,
+	}
+	if plan != want {
+		t.Fatalf("unexpected plan %+v, want %+v", plan, want)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "A1"); value != "4" {
+		t.Fatalf("unexpected SUMIFS result %q", value)
+	}
+}
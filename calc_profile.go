@@ -0,0 +1,149 @@
+package excelize
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// calcProfileLimit is the number of the slowest formulas kept by the profile
+// of a recalculation, the faster formulas are dropped as they are timed.
+const calcProfileLimit = 1000
+
+// FormulaTiming directly maps the time spent by the last dependency based
+// recalculation with CalcTuning.Profile enabled on a formula or a batch
+// pattern. For a formula calculated one by one, Cell is the formula cell like
+// "Sheet1!B2" and Formula its formula, the cells sharing a constant formula
+// like ="N/A" are calculated once at the first cell. For a batch pattern, Cell
+// is empty, Pattern is the name of the pattern like "sumifs" or "vlookup", as
+// in the "excelize.batch.<pattern>" spans of the Tracer, Formulas is the
+// number of the formulas it recognized and Duration the total time of its
+// scans over all levels.
+type FormulaTiming struct {
+	Cell     string
+	Formula  string
+	Pattern  string
+	Formulas int
+	Duration time.Duration
+}
+
+// formulaTimingHeap is a min-heap of the formula timings by duration, the
+// root is the fastest of the kept formulas.
+type formulaTimingHeap []FormulaTiming
+
+func (h formulaTimingHeap) Len() int            { return len(h) }
+func (h formulaTimingHeap) Less(i, j int) bool  { return h[i].Duration < h[j].Duration }
+func (h formulaTimingHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *formulaTimingHeap) Push(x interface{}) { *h = append(*h, x.(FormulaTiming)) }
+func (h *formulaTimingHeap) Pop() interface{} {
+	old := *h
+	timing := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return timing
+}
+
+// calcProfile holds the timings of a recalculation: the slowest formulas
+// calculated one by one and the total time of each batch pattern.
+type calcProfile struct {
+	mu       sync.Mutex
+	formulas formulaTimingHeap
+	patterns map[string]*FormulaTiming
+}
+
+// calcProfileKey is the context key of the calcProfile of a recalculation.
+type calcProfileKey struct{}
+
+// calcProfileFromContext returns the calcProfile carried by ctx, or nil.
+func calcProfileFromContext(ctx context.Context) *calcProfile {
+	if ctx == nil {
+		return nil
+	}
+	profile, _ := ctx.Value(calcProfileKey{}).(*calcProfile)
+	return profile
+}
+
+// newCalcProfile creates the profile of a recalculation.
+func newCalcProfile() *calcProfile {
+	return &calcProfile{patterns: make(map[string]*FormulaTiming)}
+}
+
+// addFormula records the time of a formula calculated one by one, only the
+// slowest formulas are kept.
+func (p *calcProfile) addFormula(cell, formula string, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.formulas) >= calcProfileLimit {
+		if duration <= p.formulas[0].Duration {
+			return
+		}
+		heap.Pop(&p.formulas)
+	}
+	heap.Push(&p.formulas, FormulaTiming{Cell: cell, Formula: formula, Duration: duration})
+}
+
+// addPattern adds the time of a batch pattern task of a level to the total
+// of the pattern.
+func (p *calcProfile) addPattern(pattern string, formulas int, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	timing, ok := p.patterns[pattern]
+	if !ok {
+		timing = &FormulaTiming{Pattern: pattern}
+		p.patterns[pattern] = timing
+	}
+	timing.Formulas += formulas
+	timing.Duration += duration
+}
+
+// sortFormulaTimings sorts the timings from the slowest, the timings of the
+// same duration by cell and pattern.
+func sortFormulaTimings(timings []FormulaTiming) {
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].Duration != timings[j].Duration {
+			return timings[i].Duration > timings[j].Duration
+		}
+		if timings[i].Cell != timings[j].Cell {
+			return timings[i].Cell < timings[j].Cell
+		}
+		return timings[i].Pattern < timings[j].Pattern
+	})
+}
+
+// SlowestFormulas returns the n formulas calculated one by one which took the
+// longest in the last dependency based recalculation with CalcTuning.Profile
+// enabled, followed by the n slowest batch patterns, each sorted from the
+// slowest. It helps to find why a recalculation is slow: a few expensive
+// formulas, or a batch pattern scanning large source data. The profile keeps
+// up to 1000 formulas, and returns nil if no recalculation was profiled. For
+// example:
+//
+//	f.SetCalcTuning(excelize.CalcTuning{Profile: true})
+//	if err := f.RecalculateAllWithDependency(); err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, timing := range f.SlowestFormulas(10) {
+//	    if timing.Cell != "" {
+//	        fmt.Println(timing.Cell, timing.Formula, timing.Duration)
+//	        continue
+//	    }
+//	    fmt.Println(timing.Pattern, timing.Formulas, timing.Duration)
+//	}
+func (f *File) SlowestFormulas(n int) []FormulaTiming {
+	profile := f.calcProfile.Load()
+	if profile == nil || n <= 0 {
+		return nil
+	}
+	profile.mu.Lock()
+	formulas := append([]FormulaTiming(nil), profile.formulas...)
+	patterns := make([]FormulaTiming, 0, len(profile.patterns))
+	for _, timing := range profile.patterns {
+		patterns = append(patterns, *timing)
+	}
+	profile.mu.Unlock()
+	sortFormulaTimings(formulas)
+	sortFormulaTimings(patterns)
+	return append(formulas[:min(n, len(formulas))], patterns[:min(n, len(patterns))]...)
+}
@@ -0,0 +1,79 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSlowestFormulas(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	// 一列简单公式和一个构造 1000 万字符文本的慢公式，以及批量计算的 MAX
+	for row := 1; row <= 20; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{
+		"C1": `LEN(REPT("ab",5000000))`, "C2": "MAX(Sheet1!$A:$A)", "C3": "MAX(Sheet1!$B:$B)",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 未开启性能分析时不记录
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if timings := f.SlowestFormulas(3); timings != nil {
+		t.Fatalf("unexpected timings %v without the profile", timings)
+	}
+
+	f.SetCalcTuning(CalcTuning{Profile: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	timings := f.SlowestFormulas(3)
+	if len(timings) != 4 {
+		t.Fatalf("unexpected timings %v", timings)
+	}
+	if slowest := timings[0]; slowest.Cell != "Sheet1!C1" || slowest.Formula != `LEN(REPT("ab",5000000))` || slowest.Duration <= timings[1].Duration {
+		t.Fatalf("unexpected slowest formula %+v", slowest)
+	}
+	for i, timing := range timings[:3] {
+		if timing.Cell == "" || timing.Pattern != "" || i > 0 && timing.Duration > timings[i-1].Duration {
+			t.Fatalf("unexpected formula timing %+v", timing)
+		}
+	}
+	if pattern := timings[3]; pattern.Cell != "" || pattern.Pattern != "column_aggregate" || pattern.Formulas != 2 || pattern.Duration <= 0 {
+		t.Fatalf("unexpected pattern timing %+v", pattern)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "C1"); value != "10000000" {
+		t.Fatalf("unexpected C1 value %q", value)
+	}
+	if timings := f.SlowestFormulas(0); timings != nil {
+		t.Fatalf("unexpected timings %v", timings)
+	}
+
+	// 只保留最慢的公式
+	profile := newCalcProfile()
+	for i := 1; i <= calcProfileLimit+10; i++ {
+		profile.addFormula(fmt.Sprintf("Sheet1!A%d", i), "A1", time.Duration(i))
+	}
+	f.calcProfile.Store(profile)
+	timings = f.SlowestFormulas(calcProfileLimit + 10)
+	if len(timings) != calcProfileLimit || timings[0].Duration != calcProfileLimit+10 || timings[len(timings)-1].Duration != 11 {
+		t.Fatalf("unexpected %d timings from %v to %v", len(timings), timings[0], timings[len(timings)-1])
+	}
+}
@@ -0,0 +1,259 @@
+package excelize
+
+import (
+	"strconv"
+
+	"github.com/xuri/nfp"
+)
+
+// CalcResultType is the type of a calculated cell value.
+type CalcResultType byte
+
+// Calculated cell value type enumeration.
+const (
+	CalcResultNumber CalcResultType = iota
+	CalcResultText
+	CalcResultBool
+	CalcResultError
+	CalcResultDate
+)
+
+// String returns the name of the calculated cell value type.
+func (t CalcResultType) String() string {
+	switch t {
+	case CalcResultNumber:
+		return "number"
+	case CalcResultText:
+		return "text"
+	case CalcResultBool:
+		return "bool"
+	case CalcResultError:
+		return "error"
+	case CalcResultDate:
+		return "date"
+	}
+	return "unknown"
+}
+
+// CalcCellResult is the result of a cell calculated by CalcCellValuesDetailed.
+// Value is the same string returned by CalcCellValue. Type is detected from
+// the result of the formula: a number with a date or time number format of
+// the cell is a date. Err is the calculation error of the cell, if any, and
+// Type is CalcResultError in that case.
+type CalcCellResult struct {
+	Value string
+	Type  CalcResultType
+	Err   error
+}
+
+// CalcCellValuesDetailed calculates multiple cell values like CalcCellValues,
+// returning the value with its detected type and the calculation error of
+// each cell, so the callers storing typed values don't need to infer the
+// types again from the strings. It returns an error only if the worksheet
+// doesn't exist. For example:
+//
+//	results, err := f.CalcCellValuesDetailed("Sheet1", []string{"B1", "B2"})
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for cell, result := range results {
+//	    if result.Type == excelize.CalcResultError {
+//	        fmt.Printf("%s: %s %v\n", cell, result.Value, result.Err)
+//	    }
+//	}
+func (f *File) CalcCellValuesDetailed(sheet string, cells []string, opts ...Options) (map[string]CalcCellResult, error) {
+	f.mu.Lock()
+	_, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]CalcCellResult, len(cells))
+	for _, cell := range cells {
+		value, err := f.CalcCellValue(sheet, cell, opts...)
+		result := CalcCellResult{Value: value, Type: f.detectCalcResultType(sheet, cell, value), Err: err}
+		if err != nil {
+			result.Type = CalcResultError
+		}
+		results[cell] = result
+	}
+	return results, nil
+}
+
+// detectCalcResultType returns the type of a calculated cell value. The
+// formula token cached by the calculation is used if present, otherwise the
+// type is inferred from the value, e.g. the value stored by a batch
+// calculation.
+func (f *File) detectCalcResultType(sheet, cell, value string) CalcResultType {
+	typ := inferCalcResultType(value)
+	if cached, ok := f.calcCache.Load(sheet + "!" + cell); ok {
+		if token, ok := cached.(formulaArg); ok {
+			switch token.Type {
+			case ArgError:
+				typ = CalcResultError
+			case ArgNumber:
+				if typ = CalcResultNumber; token.Boolean {
+					typ = CalcResultBool
+				}
+			case ArgString:
+				if typ != CalcResultError {
+					typ = CalcResultText
+				}
+			}
+		}
+	}
+	if typ == CalcResultNumber && f.isDateNumFmtCell(sheet, cell) {
+		return CalcResultDate
+	}
+	return typ
+}
+
+// TypeMismatch is a formula cell reported by DetectTypeMismatches, whose value
+// stored in the worksheet has a type different from the type of the value
+// calculated from its formula.
+type TypeMismatch struct {
+	Sheet         string
+	Cell          string
+	Formula       string
+	StoredValue   string
+	StoredType    CalcResultType
+	ComputedValue string
+	ComputedType  CalcResultType
+}
+
+// DetectTypeMismatches calculates the formula cells of all worksheets and
+// reports those whose stored value type differs from the calculated value
+// type, e.g. a formula yielding a number whose stored value is text, which
+// usually indicates a data-entry or formula error. The formula cells without
+// stored values are skipped. The stored values are not changed. The
+// mismatches are ordered by worksheets and cells. For example:
+//
+//	mismatches, err := f.DetectTypeMismatches()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, m := range mismatches {
+//	    fmt.Printf("%s!%s: stored %s %q, computed %s %q\n",
+//	        m.Sheet, m.Cell, m.StoredType, m.StoredValue, m.ComputedType, m.ComputedValue)
+//	}
+func (f *File) DetectTypeMismatches() ([]TypeMismatch, error) {
+	var mismatches []TypeMismatch
+	for _, sheet := range f.GetSheetList() {
+		stored, err := f.storedFormulaValueTypes(sheet)
+		if err != nil {
+			return mismatches, err
+		}
+		for _, cell := range stored {
+			value, err := f.CalcCellValue(sheet, cell.ref, Options{RawCellValue: true})
+			computedType := f.detectCalcResultType(sheet, cell.ref, value)
+			if err != nil {
+				computedType = CalcResultError
+			}
+			if computedType == cell.typ {
+				continue
+			}
+			storedValue, _ := f.GetCellValue(sheet, cell.ref, Options{RawCellValue: true})
+			formula, _ := f.GetCellFormula(sheet, cell.ref)
+			mismatches = append(mismatches, TypeMismatch{
+				Sheet: sheet, Cell: cell.ref, Formula: formula,
+				StoredValue: storedValue, StoredType: cell.typ,
+				ComputedValue: value, ComputedType: computedType,
+			})
+		}
+	}
+	return mismatches, nil
+}
+
+// storedFormulaValueType is the type of the value stored in a formula cell.
+type storedFormulaValueType struct {
+	ref string
+	typ CalcResultType
+}
+
+// storedFormulaValueTypes returns the types of the values stored in the
+// formula cells of the worksheet, which are detected from the cell types.
+func (f *File) storedFormulaValueTypes(sheet string) ([]storedFormulaValueType, error) {
+	f.mu.Lock()
+	ws, err := f.workSheetReader(sheet)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	ws.mu.RLock()
+	var cells []storedFormulaValueType
+	for _, row := range ws.SheetData.Row {
+		for _, c := range row.C {
+			if c.F == nil || (c.V == "" && c.IS == nil) {
+				continue
+			}
+			typ := CalcResultNumber
+			switch c.T {
+			case "s", "str", "inlineStr":
+				typ = CalcResultText
+			case "b":
+				typ = CalcResultBool
+			case "e":
+				typ = CalcResultError
+			}
+			cells = append(cells, storedFormulaValueType{ref: c.R, typ: typ})
+		}
+	}
+	ws.mu.RUnlock()
+	for i := range cells {
+		if cells[i].typ == CalcResultNumber && f.isDateNumFmtCell(sheet, cells[i].ref) {
+			cells[i].typ = CalcResultDate
+		}
+	}
+	return cells, nil
+}
+
+// inferCalcResultType infers the type of a calculated cell value from the
+// string.
+func inferCalcResultType(value string) CalcResultType {
+	switch value {
+	case formulaErrorDIV, formulaErrorNAME, formulaErrorNA, formulaErrorNUM,
+		formulaErrorVALUE, formulaErrorREF, formulaErrorNULL, formulaErrorSPILL,
+		formulaErrorCALC, formulaErrorGETTINGDATA:
+		return CalcResultError
+	case "TRUE", "FALSE":
+		return CalcResultBool
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return CalcResultNumber
+	}
+	return CalcResultText
+}
+
+// isDateNumFmtCell returns if the number format of the cell is a date or time
+// format.
+func (f *File) isDateNumFmtCell(sheet, cell string) bool {
+	styleIdx, err := f.GetCellStyleReadOnly(sheet, cell)
+	if err != nil {
+		return false
+	}
+	styleSheet, err := f.stylesReader()
+	if err != nil || styleSheet.CellXfs == nil || styleIdx < 0 || styleIdx >= len(styleSheet.CellXfs.Xf) {
+		return false
+	}
+	var numFmtID int
+	if styleSheet.CellXfs.Xf[styleIdx].NumFmtID != nil {
+		numFmtID = *styleSheet.CellXfs.Xf[styleIdx].NumFmtID
+	}
+	fmtCode, ok := styleSheet.getCustomNumFmtCode(numFmtID)
+	if !ok {
+		if fmtCode, ok = f.getBuiltInNumFmtCode(numFmtID); !ok {
+			return false
+		}
+	}
+	p := nfp.NumberFormatParser()
+	for _, section := range p.Parse(fmtCode) {
+		for _, token := range section.Items {
+			if token.TType == nfp.TokenTypeDateTimes {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package excelize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCalcCellValuesDetailed(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1": "A1*1.5",
+		"B2": `"x"&A1`,
+		"B3": "A1>1",
+		"B4": "A1/0",
+		"B5": `VLOOKUP("k",C1:D2,2,FALSE)`,
+		"B6": "DATE(2024,1,15)",
+		"B7": `"TRUE"`,
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	style, err := f.NewStyle(&Style{NumFmt: 14})
+	if err != nil {
+		t.Fatalf("new style: %v", err)
+	}
+	if err := f.SetCellStyle("Sheet1", "B6", "B6", style); err != nil {
+		t.Fatalf("set style: %v", err)
+	}
+
+	results, err := f.CalcCellValuesDetailed("Sheet1", []string{"B1", "B2", "B3", "B4", "B5", "B6", "B7"}, Options{RawCellValue: true})
+	if err != nil {
+		t.Fatalf("calc: %v", err)
+	}
+	for cell, want := range map[string]struct {
+		value string
+		typ   CalcResultType
+	}{
+		"B1": {"3", CalcResultNumber},
+		"B2": {"x2", CalcResultText},
+		"B3": {"TRUE", CalcResultBool},
+		"B4": {formulaErrorDIV, CalcResultError},
+		"B5": {formulaErrorNA, CalcResultError},
+		"B6": {"45306", CalcResultDate},
+		"B7": {"TRUE", CalcResultText},
+	} {
+		if got := results[cell]; got.Value != want.value || got.Type != want.typ {
+			t.Fatalf("%s: got %q (%s), want %q (%s)", cell, got.Value, got.Type, want.value, want.typ)
+		}
+	}
+	if results["B1"].Err != nil || results["B5"].Err == nil {
+		t.Fatalf("unexpected errors: B1 %v, B5 %v", results["B1"].Err, results["B5"].Err)
+	}
+
+	// 批量计算写入缓存的值没有公式结果，根据字符串推断类型
+	for value, want := range map[string]CalcResultType{
+		"12.5": CalcResultNumber, "abc": CalcResultText, "FALSE": CalcResultBool, formulaErrorREF: CalcResultError,
+	} {
+		if got := inferCalcResultType(value); got != want {
+			t.Fatalf("inferCalcResultType(%q) = %s, want %s", value, got, want)
+		}
+	}
+	if _, err := f.CalcCellValuesDetailed("SheetN", []string{"A1"}); err == nil {
+		t.Fatal("expected error for a missing sheet")
+	}
+}
+
+func TestDetectTypeMismatches(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{"B1": "A1*2", "B2": `"x"&A1`, "B3": "A1>1", "B4": "A1+1"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if mismatches, err := f.DetectTypeMismatches(); err != nil || len(mismatches) != 0 {
+		t.Fatalf("unexpected mismatches after recalculation: %v, %v", mismatches, err)
+	}
+
+	// B1 的公式结果为数字，但存储的值为文本；B4 没有存储值
+	ws, err := f.workSheetReader("Sheet1")
+	if err != nil {
+		t.Fatalf("read worksheet: %v", err)
+	}
+	for _, row := range ws.SheetData.Row {
+		for i := range row.C {
+			switch row.C[i].R {
+			case "B1":
+				row.C[i].T, row.C[i].V = "str", "four"
+			case "B4":
+				row.C[i].T, row.C[i].V = "", ""
+			}
+		}
+	}
+	f.calcCache.Clear()
+	mismatches, err := f.DetectTypeMismatches()
+	if err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+	want := []TypeMismatch{{
+		Sheet: "Sheet1", Cell: "B1", Formula: "A1*2",
+		StoredValue: "four", StoredType: CalcResultText,
+		ComputedValue: "4", ComputedType: CalcResultNumber,
+	}}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Fatalf("unexpected mismatches %+v", mismatches)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "B1"); value != "four" {
+		t.Fatalf("unexpected stored value %q after detection", value)
+	}
+}
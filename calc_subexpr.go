@@ -69,6 +69,19 @@ func (f *File) CalcCellValueWithSubExprCache(sheet, cell, formula string, subExp
 	if cachedResult, found := f.calcCache.Load(cacheKey); found {
 		return cachedResult.(string), nil
 	}
+	return f.evalWithSubExprCache(sheet, cell, formula, subExprCache, worksheetCache, opts)
+}
+
+// evalWithSubExprCache evaluates a formula with the cached sub-expressions
+// replaced by their values, without looking up the calculated value of the
+// cell in calcCache.
+func (f *File) evalWithSubExprCache(sheet, cell, formula string, subExprCache *SubExpressionCache, worksheetCache *WorksheetCache, opts Options) (string, error) {
+	// Fold error-guard wrappers (IFERROR / IFNA / IF(ISERROR(...)) / IF(ISNA(...)))
+	// around a cached lookup: the default is only evaluated when the cached
+	// lookup result is an error trapped by the guard
+	if result, ok, err := f.foldErrorGuard(sheet, cell, formula, subExprCache, worksheetCache, opts); ok {
+		return result, err
+	}
 
 	// Try to replace ALL SUMIFS/AVERAGEIFS/INDEX-MATCH in the formula with cached values
 	modifiedFormula := formula
@@ -90,8 +103,12 @@ func (f *File) CalcCellValueWithSubExprCache(sheet, cell, formula string, subExp
 			// in comparisons like IFERROR("0",0)=0 which returns FALSE
 
 			// Always quote the value to preserve string type from cell data
-			// This ensures Excel's type coercion works correctly
+			// This ensures Excel's type coercion works correctly. A missed
+			// lookup is cached as #N/A, the error values are kept as errors.
 			replacementValue := `"` + strings.ReplaceAll(cachedValue, `"`, `""`) + `"`
+			if isFormulaErrorValue(cachedValue) {
+				replacementValue = cachedValue
+			}
 
 			modifiedFormula = strings.Replace(modifiedFormula, indexMatchExpr, replacementValue, 1)
 			replacements++
@@ -118,8 +135,12 @@ func (f *File) CalcCellValueWithSubExprCache(sheet, cell, formula string, subExp
 
 		if cachedValue, ok := subExprCache.Load(sumifsExpr); ok {
 			// Replace SUMIFS expression with its cached numeric value
-			// Always quote to preserve string type
+			// Always quote to preserve string type, the error values are kept
+			// as errors to propagate through the operators
 			replacementValue := `"` + strings.ReplaceAll(cachedValue, `"`, `""`) + `"`
+			if isFormulaErrorValue(cachedValue) {
+				replacementValue = cachedValue
+			}
 
 			modifiedFormula = strings.Replace(modifiedFormula, sumifsExpr, replacementValue, 1)
 			replacements++
@@ -160,6 +181,17 @@ func (f *File) CalcCellValueWithSubExprCache(sheet, cell, formula string, subExp
 		}
 	}
 
+	// Replace MAX/MIN over single-column ranges, the cache key carries the
+	// sheet because an unqualified range belongs to the formula's sheet
+	for _, aggregateExpr := range extractColumnAggregates(modifiedFormula) {
+		if cachedValue, ok := subExprCache.Load(columnAggregateKey(sheet, aggregateExpr)); ok {
+			modifiedFormula = strings.Replace(modifiedFormula, aggregateExpr, cachedValue, 1)
+			replacements++
+		} else {
+			missedCount++
+		}
+	}
+
 	// Evaluate the formula
 	var result string
 	var err error
@@ -178,6 +210,41 @@ func (f *File) CalcCellValueWithSubExprCache(sheet, cell, formula string, subExp
 	return result, err
 }
 
+// foldErrorGuard evaluates an error-guard formula whose guarded lookup is
+// cached. A default which is a cached lookup or another guard, like
+// IFERROR(VLOOKUP(...),VLOOKUP(...)), is resolved from the cache as well. It
+// returns false if the formula is not a guard or the lookup is not cached.
+func (f *File) foldErrorGuard(sheet, cell, formula string, subExprCache *SubExpressionCache, worksheetCache *WorksheetCache, opts Options) (string, bool, error) {
+	guard := extractErrorGuard(formula)
+	if guard == nil {
+		return "", false, nil
+	}
+	cachedValue, ok := loadGuardedLookup(subExprCache, sheet, guard.expr)
+	if !ok {
+		return "", false, nil
+	}
+	if !guard.traps(cachedValue) {
+		return cachedValue, true, nil
+	}
+	if fallbackValue, ok := loadGuardedLookup(subExprCache, sheet, guard.fallback); ok {
+		return fallbackValue, true, nil
+	}
+	if result, ok, err := f.foldErrorGuard(sheet, cell, guard.fallback, subExprCache, worksheetCache, opts); ok {
+		return result, true, err
+	}
+	result, err := f.evalFormulaString(sheet, cell, guard.fallback, worksheetCache, opts)
+	return result, true, err
+}
+
+// loadGuardedLookup loads a cached lookup expression, INDEX-MATCH results are
+// keyed by the expression and VLOOKUP results by vlookupKey
+func loadGuardedLookup(subExprCache *SubExpressionCache, sheet, expr string) (string, bool) {
+	if value, ok := subExprCache.Load(expr); ok {
+		return value, true
+	}
+	return subExprCache.Load(vlookupKey(sheet, expr))
+}
+
 // evalFormulaString evaluates a formula string directly (without reading from cell)
 // This is used when the formula has been modified (e.g., SUMIFS replaced with value)
 func (f *File) evalFormulaString(sheet, cell, formula string, worksheetCache *WorksheetCache, opts Options) (string, error) {
@@ -209,8 +276,20 @@ func (f *File) evalFormulaString(sheet, cell, formula string, worksheetCache *Wo
 		// rangeCache is sync.Map, no initialization needed
 		worksheetCache: worksheetCache, // Pass worksheetCache to formula engine
 	}
+	// Keep the array of a dynamic array anchor for updating its spill range
+	spill := newEmptyFormulaArg()
+	if worksheetCache != nil && isDynamicArrayFormula(formula) {
+		ctx.spill = &spill
+	}
 
 	result, err := f.evalInfixExp(ctx, sheet, cell, tokens)
+	if ctx.spill != nil {
+		if spill.Type == ArgMatrix {
+			worksheetCache.setSpill(sheet, cell, spill)
+		} else {
+			worksheetCache.setSpill(sheet, cell, result)
+		}
+	}
 
 	// Convert result to string - result is formulaArg with String/Number/etc fields
 	// CRITICAL: Even if err != nil, result may contain error value like "#DIV/0!"
@@ -0,0 +1,228 @@
+package excelize
+
+import (
+	"crypto/sha256"
+	"strings"
+	"sync"
+
+	"github.com/xuri/efp"
+)
+
+// retainedSubExpr is a sub-expression result kept between the
+// recalculations, with the fingerprint of the source data it was calculated
+// from.
+type retainedSubExpr struct {
+	fingerprint [sha256.Size]byte
+	value       string
+}
+
+// retainedSubExprCache keeps the SUMIFS, AVERAGEIFS and INDEX-MATCH
+// sub-expression results between the recalculations with
+// CalcTuning.RetainSubExpressions. The key is the sheet of the formulas and
+// the expression, a result is reused only if the fingerprint of the cells
+// referenced by the expression is unchanged. It's safe for concurrent use.
+type retainedSubExprCache struct {
+	mu      sync.RWMutex
+	entries map[string]retainedSubExpr
+}
+
+// load returns the retained result of the key calculated from the source
+// data of the given fingerprint.
+func (c *retainedSubExprCache) load(key string, fingerprint [sha256.Size]byte) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.fingerprint != fingerprint {
+		return "", false
+	}
+	return entry.value, true
+}
+
+// store retains the result of the key, replacing the result calculated from
+// former source data.
+func (c *retainedSubExprCache) store(key string, fingerprint [sha256.Size]byte, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]retainedSubExpr)
+	}
+	c.entries[key] = retainedSubExpr{fingerprint: fingerprint, value: value}
+}
+
+// clear drops all retained results.
+func (c *retainedSubExprCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// len returns the number of retained results.
+func (c *retainedSubExprCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// subExprFingerprinter calculates the fingerprints of the source data of the
+// sub-expressions of a level. The referenced columns are read and hashed
+// once per level, the cells calculated in former levels are read from the
+// worksheet cache.
+type subExprFingerprinter struct {
+	f              *File
+	worksheetCache *WorksheetCache
+	rows           map[string][][]string
+	columns        map[lookupTable][sha256.Size]byte
+}
+
+// newSubExprFingerprinter creates a fingerprinter of the source data of a
+// level.
+func (f *File) newSubExprFingerprinter(worksheetCache *WorksheetCache) *subExprFingerprinter {
+	return &subExprFingerprinter{
+		f:              f,
+		worksheetCache: worksheetCache,
+		rows:           make(map[string][][]string),
+		columns:        make(map[lookupTable][sha256.Size]byte),
+	}
+}
+
+// fingerprint returns the SHA-256 hash of the values of the cells and ranges
+// referenced by an expression of a formula on the sheet. It returns false for
+// the expressions referencing defined names, whole rows or other references
+// which can't be fingerprinted, whose results are not retained.
+func (p *subExprFingerprinter) fingerprint(sheet, expr string) ([sha256.Size]byte, bool) {
+	ps := efp.ExcelParser()
+	hash := sha256.New()
+	for _, token := range ps.Parse(expr) {
+		if token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		if upper := strings.ToUpper(token.TValue); upper == "TRUE" || upper == "FALSE" {
+			continue
+		}
+		_, _ = hash.Write([]byte(token.TValue + "\x00"))
+		if strings.Contains(token.TValue, ":") {
+			table, ok := parseLookupTable(token.TValue, sheet)
+			if !ok {
+				return [sha256.Size]byte{}, false
+			}
+			for col := table.startCol; col <= table.endCol; col++ {
+				column := table
+				column.startCol, column.endCol = col, col
+				sum, ok := p.column(column)
+				if !ok {
+					return [sha256.Size]byte{}, false
+				}
+				_, _ = hash.Write(sum[:])
+			}
+			continue
+		}
+		cellSheet, cell := sheet, token.TValue
+		if idx := strings.LastIndex(cell, "!"); idx != -1 {
+			cellSheet, cell = extractSheetName(cell), cell[idx+1:]
+		}
+		cell = strings.ReplaceAll(cell, "$", "")
+		if _, _, err := CellNameToCoordinates(cell); err != nil {
+			return [sha256.Size]byte{}, false
+		}
+		_, _ = hash.Write([]byte(p.f.getCellValueOrCalcCache(cellSheet, cell, p.worksheetCache) + "\x00"))
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum, true
+}
+
+// column returns the SHA-256 hash of the values of a single column range.
+func (p *subExprFingerprinter) column(column lookupTable) ([sha256.Size]byte, bool) {
+	if sum, ok := p.columns[column]; ok {
+		return sum, true
+	}
+	rows, ok := p.rows[column.sheet]
+	if !ok {
+		fileRows, err := p.f.getCachedRawRows(column.sheet)
+		if err != nil {
+			return [sha256.Size]byte{}, false
+		}
+		rows = mergeSheetCacheIntoRows(fileRows, p.worksheetCache.GetSheet(column.sheet))
+		p.rows[column.sheet] = rows
+	}
+	hash := sha256.New()
+	for rowIdx := column.startRow - 1; rowIdx < len(rows) && rowIdx < column.endRow; rowIdx++ {
+		var value string
+		if column.startCol <= len(rows[rowIdx]) {
+			value = rows[rowIdx][column.startCol-1]
+		}
+		_, _ = hash.Write([]byte(value + "\x00"))
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	p.columns[column] = sum
+	return sum, true
+}
+
+// retainableSubExpr is a sub-expression of a level whose result can be
+// retained, or was reused from a former recalculation.
+type retainableSubExpr struct {
+	key         string
+	fingerprint [sha256.Size]byte
+	reused      bool
+}
+
+// reuseRetainedSubExprs looks up the retained results of the distinct
+// sub-expressions of a level, which maps the expressions to the cells using
+// them. The reused results are stored into the sub-expression cache. It
+// returns the sub-expressions which can be retained after the level is
+// calculated, the expressions used by the formulas on more than one sheet are
+// left out since the ranges without a sheet name differ by sheet.
+func (f *File) reuseRetainedSubExprs(fingerprinter *subExprFingerprinter, exprs map[string][]string, subExprCache *SubExpressionCache) map[string]*retainableSubExpr {
+	retainable := make(map[string]*retainableSubExpr, len(exprs))
+	for expr, cells := range exprs {
+		if len(cells) == 0 {
+			continue
+		}
+		sheet, _, _ := strings.Cut(cells[0], "!")
+		sameSheet := true
+		for _, cell := range cells[1:] {
+			if !strings.HasPrefix(cell, sheet+"!") {
+				sameSheet = false
+				break
+			}
+		}
+		if !sameSheet {
+			continue
+		}
+		fingerprint, ok := fingerprinter.fingerprint(sheet, expr)
+		if !ok {
+			continue
+		}
+		entry := &retainableSubExpr{key: sheet + "!" + expr, fingerprint: fingerprint}
+		if value, ok := f.retainedSubExprs.load(entry.key, fingerprint); ok {
+			subExprCache.Store(expr, value)
+			entry.reused = true
+		}
+		retainable[expr] = entry
+	}
+	return retainable
+}
+
+// retainSubExprs keeps the results of the sub-expressions calculated in a
+// level for the later recalculations.
+func (f *File) retainSubExprs(retainable map[string]*retainableSubExpr, subExprCache *SubExpressionCache) {
+	for expr, entry := range retainable {
+		if entry.reused {
+			continue
+		}
+		if value, ok := subExprCache.Load(expr); ok {
+			f.retainedSubExprs.store(entry.key, entry.fingerprint, value)
+		}
+	}
+}
+
+// isCompositeSubExpr reports whether a formula calculates more than the
+// sub-expression, or an error guard around it.
+func isCompositeSubExpr(formula, expr string) bool {
+	clean := strings.TrimSpace(strings.TrimPrefix(formula, "="))
+	if guard := extractErrorGuard(clean); guard != nil {
+		clean = guard.expr
+	}
+	return clean != strings.TrimSpace(expr)
+}
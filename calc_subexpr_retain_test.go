@@ -0,0 +1,92 @@
+package excelize
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetainSubExpressions(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	_, err := f.NewSheet("Data")
+	assert.NoError(t, err)
+	regions, products := []string{"East", "West"}, []string{"P1", "P2", "P3", "P4", "P5"}
+	for i := 1; i <= 40; i++ {
+		assert.NoError(t, f.SetSheetRow("Data", fmt.Sprintf("A%d", i), &[]interface{}{regions[i%2], products[i%5], i}))
+	}
+	sum := func(row int) int {
+		var total int
+		for i := 1; i <= 40; i++ {
+			if i%2 == row%2 && i%5 == row%5 {
+				total += i
+			}
+		}
+		return total
+	}
+	for row := 1; row <= 10; row++ {
+		assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{regions[row%2], products[row%5]}))
+		assert.NoError(t, f.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), 1))
+		assert.NoError(t, f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row),
+			fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,$B%d)+$D%d", row, row, row)))
+	}
+	assertValues := func(offset int) {
+		for row := 1; row <= 10; row++ {
+			value, err := f.GetCellValue("Sheet1", fmt.Sprintf("C%d", row))
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprint(sum(row)+offset), value, row)
+		}
+	}
+	updateOperands := func(value int) map[string]bool {
+		updated := make(map[string]bool)
+		for row := 1; row <= 10; row++ {
+			assert.NoError(t, f.SetCellValue("Sheet1", fmt.Sprintf("D%d", row), value))
+			updated[fmt.Sprintf("Sheet1!D%d", row)] = true
+		}
+		return updated
+	}
+
+	f.SetCalcTuning(CalcTuning{RetainSubExpressions: true})
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	assertValues(1)
+	plan, err := f.LastCalcPlan()
+	assert.NoError(t, err)
+	assert.Zero(t, plan.RetainedSubExpressions)
+	assert.Equal(t, 10, f.retainedSubExprs.len())
+
+	// 只有 SUMIFS 之外的运算数改变，两次增量重算都复用保留的 SUMIFS 结果
+	for _, value := range []int{2, 3} {
+		assert.NoError(t, f.RecalculateAffectedByCells(updateOperands(value)))
+		assertValues(value)
+		plan, err = f.LastCalcPlan()
+		assert.NoError(t, err)
+		assert.Equal(t, 10, plan.RetainedSubExpressions)
+	}
+
+	// 源数据改变后重新计算
+	assert.NoError(t, f.SetCellValue("Data", "C1", 101))
+	assert.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Data!C1": true}))
+	plan, err = f.LastCalcPlan()
+	assert.NoError(t, err)
+	assert.Zero(t, plan.RetainedSubExpressions)
+	for row := 1; row <= 10; row++ {
+		expected := sum(row) + 3
+		if row%2 == 1 && row%5 == 1 {
+			expected += 100
+		}
+		value, err := f.GetCellValue("Sheet1", fmt.Sprintf("C%d", row))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprint(expected), value, row)
+	}
+
+	// 修改调优选项丢弃保留的结果
+	f.SetCalcTuning(CalcTuning{})
+	assert.Zero(t, f.retainedSubExprs.len())
+	assert.NoError(t, f.RecalculateAffectedByCells(updateOperands(1)))
+	plan, err = f.LastCalcPlan()
+	assert.NoError(t, err)
+	assert.Zero(t, plan.RetainedSubExpressions)
+	assert.Zero(t, f.retainedSubExprs.len())
+}
@@ -483,6 +483,11 @@ func TestCalcCellValue(t *testing.T) {
 		"CEILING(-22.25,-5)":              "-25",
 		"CEILING(22.25)":                  "23",
 		"CEILING(CEILING(22.25,0.1),0.1)": "22.3",
+		"CEILING(13,6)":                   "18",
+		"CEILING(-13,6)":                  "-12",
+		"CEILING(0.3,0.1)":                "0.3",
+		"CEILING(4.35,0.05)":              "4.35",
+		"CEILING(22.25,0)":                "0",
 		// _xlfn.CEILING.MATH
 		"_xlfn.CEILING.MATH(15.25,1)":                       "16",
 		"_xlfn.CEILING.MATH(15.25,0.1)":                     "15.3",
@@ -590,6 +595,9 @@ func TestCalcCellValue(t *testing.T) {
 		"FLOOR(-26.75,-5)":        "-25",
 		"FLOOR(-2.05,2)":          "-4",
 		"FLOOR(FLOOR(26.75,1),1)": "26",
+		"FLOOR(-13,6)":            "-18",
+		"FLOOR(0.3,0.1)":          "0.3",
+		"FLOOR(4.35,0.05)":        "4.35",
 		// _xlfn.FLOOR.MATH
 		"_xlfn.FLOOR.MATH(58.55)":                  "58",
 		"_xlfn.FLOOR.MATH(58.55,0.1)":              "58.5",
@@ -693,6 +701,10 @@ func TestCalcCellValue(t *testing.T) {
 		"MOD(6,1.333)":    "0.668",
 		"MOD(-10.23,1)":   "0.77",
 		"MOD(MOD(1,1),1)": "0",
+		"MOD(-3,2)":       "1",
+		"MOD(3,-2)":       "-1",
+		"MOD(-3,-2)":      "-1",
+		"MOD(-7,3)":       "2",
 		// MROUND
 		"MROUND(333.7,0.5)":     "333.5",
 		"MROUND(333.8,1)":       "334",
@@ -703,6 +715,12 @@ func TestCalcCellValue(t *testing.T) {
 		"MROUND(-555.4,-1)":     "-555",
 		"MROUND(-1555,-1000)":   "-2000",
 		"MROUND(MROUND(1,1),1)": "1",
+		"MROUND(112,25)":        "100",
+		"MROUND(37.5,25)":       "50",
+		"MROUND(-37.5,-25)":     "-50",
+		"MROUND(1.3,0.2)":       "1.4",
+		"MROUND(0.15,0.1)":      "0.2",
+		"MROUND(6.05,0.1)":      "6.1",
 		// MULTINOMIAL
 		"MULTINOMIAL(3,1,2,5)":        "27720",
 		"MULTINOMIAL(\"\",3,1,2,5)":   "27720",
@@ -723,6 +741,8 @@ func TestCalcCellValue(t *testing.T) {
 		// POWER
 		"POWER(4,2)":          "16",
 		"POWER(4,POWER(1,1))": "4",
+		"POWER(-8,3)":         "-512",
+		"POWER(2,-2)":         "0.25",
 		// PRODUCT
 		"PRODUCT(3,6)":            "18",
 		"PRODUCT(\"3\",\"6\")":    "18",
@@ -760,6 +780,14 @@ func TestCalcCellValue(t *testing.T) {
 		"ROUND(999,-1)":          "1000",
 		"ROUND(991,-1)":          "990",
 		"ROUND(ROUND(100,1),-1)": "100",
+		"ROUND(1234.5678,-2)":    "1200",
+		"ROUND(1250,-2)":         "1300",
+		"ROUND(-1250,-2)":        "-1300",
+		"ROUND(12345.6789,-5)":   "0",
+		"ROUND(1234.5678,-2.7)":  "1200",
+		"ROUND(1234.5678,2.9)":   "1234.57",
+		"ROUND(1.5,400)":         "1.5",
+		"ROUND(1.5,-400)":        "0",
 		// ROUNDDOWN
 		"ROUNDDOWN(99.999,1)":            "99.9",
 		"ROUNDDOWN(99.999,2)":            "99.99",
@@ -768,6 +796,8 @@ func TestCalcCellValue(t *testing.T) {
 		"ROUNDDOWN(-99.999,2)":           "-99.99",
 		"ROUNDDOWN(-99.999,-1)":          "-90",
 		"ROUNDDOWN(ROUNDDOWN(100,1),-1)": "100",
+		"ROUNDDOWN(1299,-2)":             "1200",
+		"ROUNDDOWN(-1299,-2.5)":          "-1200",
 		// ROUNDUP
 		"ROUNDUP(11.111,1)":          "11.2",
 		"ROUNDUP(11.111,2)":          "11.12",
@@ -776,6 +806,8 @@ func TestCalcCellValue(t *testing.T) {
 		"ROUNDUP(-11.111,2)":         "-11.12",
 		"ROUNDUP(-11.111,-1)":        "-20",
 		"ROUNDUP(ROUNDUP(100,1),-1)": "100",
+		"ROUNDUP(1201,-2)":           "1300",
+		"ROUNDUP(-1201,-2)":          "-1300",
 		// SEARCH
 		"SEARCH(\"s\",F1)":           "1",
 		"SEARCH(\"s\",F1,2)":         "5",
@@ -2632,7 +2664,7 @@ func TestCalcCellValue(t *testing.T) {
 		// CEILING
 		"CEILING()":        {"#VALUE!", "CEILING requires at least 1 argument"},
 		"CEILING(1,2,3)":   {"#VALUE!", "CEILING allows at most 2 arguments"},
-		"CEILING(1,-1)":    {"#VALUE!", "negative sig to CEILING invalid"},
+		"CEILING(1,-1)":    {"#NUM!", "negative sig to CEILING invalid"},
 		"CEILING(\"X\",0)": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"CEILING(0,\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		// _xlfn.CEILING.MATH
@@ -2706,6 +2738,7 @@ func TestCalcCellValue(t *testing.T) {
 		"FLOOR(\"X\",-1)": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"FLOOR(1,\"X\")":  {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"FLOOR(1,-1)":     {"#NUM!", "invalid arguments to FLOOR"},
+		"FLOOR(5,0)":      {"#DIV/0!", "FLOOR divide by zero"},
 		// _xlfn.FLOOR.MATH
 		"_xlfn.FLOOR.MATH()":          {"#VALUE!", "FLOOR.MATH requires at least 1 argument"},
 		"_xlfn.FLOOR.MATH(1,2,3,4)":   {"#VALUE!", "FLOOR.MATH allows at most 3 arguments"},
@@ -2789,6 +2822,8 @@ func TestCalcCellValue(t *testing.T) {
 		"POWER(1,\"X\")": {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
 		"POWER(0,0)":     {"#NUM!", "#NUM!"},
 		"POWER(0,-1)":    {"#DIV/0!", "#DIV/0!"},
+		"POWER(-8,1/3)":  {"#NUM!", "#NUM!"},
+		"POWER(10,400)":  {"#NUM!", "#NUM!"},
 		"POWER(1)":       {"#VALUE!", "POWER requires 2 numeric arguments"},
 		// PRODUCT
 		"PRODUCT(\"X\")":    {"#VALUE!", "strconv.ParseFloat: parsing \"X\": invalid syntax"},
@@ -5150,6 +5185,93 @@ func TestCalcMAXMIN(t *testing.T) {
 	}
 }
 
+func TestCalcSUBTOTALHiddenRows(t *testing.T) {
+	cellData := [][]interface{}{{1}, {2}, {3}, {4}, {5}}
+	f := prepareCalcData(cellData)
+	assert.NoError(t, f.SetRowVisible("Sheet1", 2, false))
+	assert.NoError(t, f.SetRowVisible("Sheet1", 4, false))
+	formulaList := map[string]string{
+		"SUBTOTAL(9,A1:A5)":   "15",
+		"SUBTOTAL(109,A1:A5)": "9",
+		"SUBTOTAL(2,A1:A5)":   "5",
+		"SUBTOTAL(102,A1:A5)": "3",
+		"SUBTOTAL(4,A1:A5)":   "5",
+		"SUBTOTAL(104,A2:A4)": "3",
+		"SUBTOTAL(1,A1:A5)":   "3",
+		"SUBTOTAL(101,A1:A5)": "3",
+		"SUBTOTAL(101,A1:A4)": "2",
+		"SUBTOTAL(109,A:A)":   "9",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcArrayConstant(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{2, "b"}})
+	formulaList := map[string]string{
+		"SUM({1,2,3})":                         "6",
+		"SUM({1,2;3,4})":                       "10",
+		"SUM({-1,2;3,-4})":                     "0",
+		"SUM({1,2}*2)":                         "6",
+		"SUM({1,2,3})+1":                       "7",
+		"MATCH(A1,{1,2,3},0)":                  "2",
+		"MATCH(B1,{\"a\",\"b\",\"c\"},0)":      "2",
+		"INDEX({10,20,30},3)":                  "30",
+		"INDEX({1,2;3,4},2,1)":                 "3",
+		"VLOOKUP(2,{1,\"x\";2,\"y\"},2,FALSE)": "y",
+		"{5,6,7}":                              "5",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		result, err := f.CalcCellValue("Sheet1", "C1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	for _, formula := range []string{"SUM({1,2;3})", "SUM({A1,2})"} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "C1", formula))
+		_, err := f.CalcCellValue("Sheet1", "C1")
+		assert.Equal(t, ErrInvalidFormula, err, formula)
+	}
+}
+
+func TestCalcImplicitIntersection(t *testing.T) {
+	f := prepareCalcData([][]interface{}{{10}, {20}, {30}, {40}, {50}, {60}})
+	formulaList := map[string]string{
+		"@A:A":                  "50",
+		"_xlfn.SINGLE(A:A)":     "50",
+		"@A$1:A$6*2":            "100",
+		"SUM(@A:A,1)":           "51",
+		"@Sheet1!A:A":           "50",
+		"@A1":                   "10",
+		"@{7,8,9}":              "7",
+		"_xlfn.SINGLE(A1:A6)+1": "51",
+	}
+	for formula, expected := range formulaList {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B5", formula))
+		result, err := f.CalcCellValue("Sheet1", "B5")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// The formula row is outside of the range
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B5", "@A1:A3"))
+	result, err := f.CalcCellValue("Sheet1", "B5")
+	assert.EqualError(t, err, formulaErrorVALUE)
+	assert.Equal(t, formulaErrorVALUE, result)
+
+	// The dependency graph resolves the intersection operand as a reference
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A5", "A4+5"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "B5", "@A:A"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A4", 100))
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	result, err = f.GetCellValue("Sheet1", "B5")
+	assert.NoError(t, err)
+	assert.Equal(t, "105", result)
+}
+
 func TestCalcAVERAGEIF(t *testing.T) {
 	f := prepareCalcData([][]interface{}{
 		{"Monday", 500},
@@ -5401,6 +5523,52 @@ func TestCalcFILTER(t *testing.T) {
 	assert.Equal(t, "1,3", result)
 }
 
+func TestCalcFILTERSpill(t *testing.T) {
+	cellData := [][]interface{}{
+		{"Apple", true},
+		{"Banana", false},
+		{"Cherry", true},
+		{"Date", true},
+	}
+	f := prepareCalcData(cellData)
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "FILTER(A1:A4,B1:B4)"))
+	result, err := f.CalcCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Apple", result)
+
+	// The spill range D1:D3 is blocked by a value
+	f.calcCache.Clear()
+	assert.NoError(t, f.SetCellValue("Sheet1", "D3", "x"))
+	result, err = f.CalcCellValue("Sheet1", "D1")
+	assert.EqualError(t, err, formulaErrorSPILL)
+	assert.Equal(t, formulaErrorSPILL, result)
+
+	// Blocked by a formula, the nested dynamic array doesn't spill
+	f = prepareCalcData(cellData)
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "FILTER(A1:A4,B1:B4)"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D2", "1+1"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "E2", "TEXTJOIN(\",\",TRUE,FILTER(A1:A4,B1:B4))"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "E3", "y"))
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	result, err = f.GetCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, formulaErrorSPILL, result)
+	result, err = f.GetCellValue("Sheet1", "E2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Apple,Cherry,Date", result)
+
+	// The cells within the stored range of the array formula hold the former
+	// spilled values
+	f = prepareCalcData(cellData)
+	formulaType, ref := STCellFormulaTypeArray, "D1:D3"
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "FILTER(A1:A4,B1:B4)", FormulaOpts{Type: &formulaType, Ref: &ref}))
+	assert.NoError(t, f.SetCellValue("Sheet1", "D2", "Cherry"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "D3", "Date"))
+	result, err = f.CalcCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Apple", result)
+}
+
 func TestCalcFILTERErrors(t *testing.T) {
 	// Test error cases
 	cellData := [][]interface{}{
@@ -6994,6 +7162,40 @@ func TestCalcCellValueCache(t *testing.T) {
 	})
 }
 
+func TestCalcLOOKUPForms(t *testing.T) {
+	f := NewFile()
+	for i, row := range [][]interface{}{
+		{nil, "Bands"},
+		{10, "low"},
+		{20, "mid"},
+		{},
+		{40, "high"},
+	} {
+		cell, err := CoordinatesToCellName(1, i+1)
+		assert.NoError(t, err)
+		assert.NoError(t, f.SetSheetRow("Sheet1", cell, &row))
+	}
+	assert.NoError(t, f.SetSheetRow("Sheet1", "D1", &[]interface{}{1, 5, 9}))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "D2", &[]interface{}{"one", "five", "nine"}))
+	for formula, expected := range map[string]string{
+		// 查找列中的空单元格被跳过
+		"LOOKUP(15,A:A,B:B)":       "low",
+		"LOOKUP(45,A1:A10,B1:B10)": "high",
+		"LOOKUP(30,A2:A5,B2:B5)":   "mid",
+		"LOOKUP(5,A1:A5,B1:B5)":    "#N/A",
+		// 数组形式返回最后一列或最后一行
+		"LOOKUP(20,A2:B5)": "mid",
+		"LOOKUP(6,D1:F2)":  "five",
+		// 横向的查找向量和结果向量
+		"LOOKUP(9,D1:F1,D2:F2)": "nine",
+		"LOOKUP(4,D1:F1,D2:F2)": "one",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "H1", formula))
+		result, _ := f.CalcCellValue("Sheet1", "H1")
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
 func TestCalcLookupCol(t *testing.T) {
 	result := lookupCol(formulaArg{
 		Type: ArgMatrix,
@@ -7393,3 +7595,135 @@ func TestCalcMAXCrossSheetDateFormulas(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "45976", max, "MAX should return the latest date (45976)")
 }
+
+func TestCalcCELLandINFO(t *testing.T) {
+	cellData := [][]interface{}{
+		{1, "Apple"},
+		{2.5, nil},
+	}
+	f := prepareCalcData(cellData)
+	_, err := f.NewSheet("Sheet 2")
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetCellValue("Sheet 2", "C3", "Pear"))
+	for formula, expected := range map[string]string{
+		"CELL(\"address\",B2)":            "$B$2",
+		"CELL(\"ADDRESS\",A1:B2)":         "$A$1",
+		"CELL(\"address\",'Sheet 2'!C3)":  "'Sheet 2'!$C$3",
+		"CELL(\"row\",B2)":                "2",
+		"CELL(\"col\",'Sheet 2'!C3)":      "3",
+		"CELL(\"contents\",A2)":           "2.5",
+		"CELL(\"contents\",B1:B2)":        "Apple",
+		"CELL(\"contents\",'Sheet 2'!C3)": "Pear",
+		"CELL(\"contents\",B2)":           "0",
+		"CELL(\"contents\",A1)+1":         "2",
+		"CELL(\"type\",B1)":               "l",
+		"CELL(\"type\",A1)":               "v",
+		"CELL(\"type\",B2)":               "b",
+		"CELL(\"row\")":                   "1",
+		"INFO(\"numfile\")":               "2",
+		"INFO(\"recalc\")":                "Automatic",
+		"INFO(\"system\")":                "pcdos",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		f.calcCache.Clear()
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	for formula, expected := range map[string][]string{
+		"CELL()":              {formulaErrorVALUE, "CELL requires 1 or 2 arguments"},
+		"CELL(\"row\",1)":     {formulaErrorVALUE, "CELL requires a reference"},
+		"CELL(\"width\",A1)":  {formulaErrorNA, "CELL does not support info_type width"},
+		"CELL(\"x\",A1)":      {formulaErrorVALUE, "invalid CELL info_type x"},
+		"CELL(NA(),A1)":       {formulaErrorNA, formulaErrorNA},
+		"INFO()":              {formulaErrorVALUE, "INFO requires 1 argument"},
+		"INFO(\"osversion\")": {formulaErrorNA, "INFO does not support type_text osversion"},
+		"INFO(\"x\")":         {formulaErrorVALUE, "invalid INFO type_text x"},
+		"INFO(NA())":          {formulaErrorNA, formulaErrorNA},
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "D1", formula))
+		f.calcCache.Clear()
+		result, err := f.CalcCellValue("Sheet1", "D1")
+		assert.EqualError(t, err, expected[1], formula)
+		assert.Equal(t, expected[0], result, formula)
+	}
+
+	// The referenced cell is a dependency of the formula
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D1", "CELL(\"contents\",A1)*10"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "D2", "CELL(\"address\",'Sheet 2'!C3)"))
+	assert.NoError(t, f.RecalculateAllWithDependency())
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", 4))
+	assert.NoError(t, f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}))
+	result, err := f.GetCellValue("Sheet1", "D1")
+	assert.NoError(t, err)
+	assert.Equal(t, "40", result)
+	result, err = f.GetCellValue("Sheet1", "D2")
+	assert.NoError(t, err)
+	assert.Equal(t, "'Sheet 2'!$C$3", result)
+}
+
+func TestCalcConcatenationNumberFormat(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", 1234.5))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A2", 22483598035))
+	for formula, expected := range map[string]string{
+		`A1&"x"`:              "1234.5x",
+		`"x"&A1`:              "x1234.5",
+		`A2&""`:               "22483598035",
+		`(0.1+0.2)&"x"`:       "0.3x",
+		`1/3&""`:              "0.333333333333333",
+		`10^20&""`:            "1E+20",
+		`TRUE&"x"`:            "TRUEx",
+		`CONCAT(0.1+0.2,"x")`: "0.3x",
+		`CONCATENATE(A1,"x")`: "1234.5x",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
+
+func TestCalcErrorPropagation(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A1", "NA()"))
+	assert.NoError(t, f.SetCellFormula("Sheet1", "A2", "1/0"))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A3", 5))
+	assert.NoError(t, f.SetSheetRow("Sheet1", "C1", &[]interface{}{"x", 10}))
+	// 运算数为错误值时，结果为该错误值，并沿整个表达式向外传播
+	for formula, expected := range map[string]string{
+		"A1+1":                   formulaErrorNA,
+		"1+A1":                   formulaErrorNA,
+		"A3-A1":                  formulaErrorNA,
+		"A1*2":                   formulaErrorNA,
+		"2*A2":                   formulaErrorDIV,
+		"(A3+1)*A1":              formulaErrorNA,
+		"A1^2":                   formulaErrorNA,
+		"-A1":                    formulaErrorNA,
+		"A1%":                    formulaErrorNA,
+		`A1&"x"`:                 formulaErrorNA,
+		"A1=A1":                  formulaErrorNA,
+		"A2+A1":                  formulaErrorDIV,
+		"IF(A1>0,1,2)":           formulaErrorNA,
+		"IF(A2,1,2)":             formulaErrorDIV,
+		"IF(A3>0,A1,2)":          formulaErrorNA,
+		`SUMIFS(D:D,C:C,"x")+A1`: formulaErrorNA,
+		`SUMIFS(D:D,C:C,"x")*A2`: formulaErrorDIV,
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.EqualError(t, err, expected, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+	// 未取到的分支中的错误值、以及被 IFERROR 捕获的错误值不传播
+	for formula, expected := range map[string]string{
+		"IF(A3>0,1,A1)":   "1",
+		"IFERROR(A1+1,0)": "0",
+		"ISNA(A1*2)":      "TRUE",
+	} {
+		assert.NoError(t, f.SetCellFormula("Sheet1", "B1", formula))
+		result, err := f.CalcCellValue("Sheet1", "B1")
+		assert.NoError(t, err, formula)
+		assert.Equal(t, expected, result, formula)
+	}
+}
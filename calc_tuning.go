@@ -0,0 +1,260 @@
+package excelize
+
+import (
+	"runtime"
+	"strings"
+)
+
+// CalcTuning defines the tuning options of the dependency-aware batch
+// calculation engine. The zero value keeps the default Excel-compatible
+// behavior.
+//
+// LookupKeyNormalizer specifies an optional function applied to both the
+// lookup keys and the looked-up values when the INDEX-MATCH and VLOOKUP batch
+// calculators build and query their lookup maps. For example, trimming
+// whitespace and stripping leading zeros lets "007" match "7". The default is
+// identity.
+//
+// CleanLookupKeys specifies if the lookup keys and the looked-up values of the
+// INDEX-MATCH and VLOOKUP batch calculators are cleaned like
+// TRIM(CLEAN(key)) before matching, and before LookupKeyNormalizer if both are
+// set: the non-printing characters are removed, the leading and trailing
+// spaces are trimmed and the runs of inner spaces are reduced to one, so a key
+// with a trailing space or a line break matches the key without. The
+// non-breaking spaces are treated as spaces.
+//
+// FailOnCircularDependency specifies if RecalculateAllWithDependency returns a
+// *CircularDependencyError without calculating when the formulas contain
+// circular references. By default, the formulas in cycles are calculated in a
+// final level after all other formulas and only a warning is logged.
+//
+// ShadowOutput specifies if the dependency based recalculations, including
+// the incremental ones like RecalculateAffectedByCells, keep the values
+// stored in the formula cells and put the computed values into a shadow map,
+// which can be read by GetComputedValue. The OnCellCalculated callback is not
+// called and the computed values are not left in the calculation cache. This
+// allows comparing the values as stored in the file with the recomputed
+// values.
+//
+// UseCalcChain specifies if RecalculateAllWithDependency seeds the dependency
+// levels from the calculation chain saved by Excel instead of parsing all
+// formulas, which is faster on large workbooks. Only the formulas missing from
+// the chain are parsed, and the stale entries of the chain are skipped. The
+// full graph is built if the workbook has no calculation chain, or the chain
+// has no dependency levels. The circular references among the formulas of
+// the chain are not detected in this mode.
+//
+// NewWorksheetCacheBackend specifies an optional function creating the
+// storage of the worksheet cache shared by the levels of a recalculation, such
+// as NewDiskWorksheetCacheBackend, so the preloaded source data can spill to
+// disk. It's called once per recalculation and the backend is closed when the
+// recalculation completes. The values are kept in memory by default, or if the
+// function returns an error.
+//
+// WorksheetCacheMaxBytes specifies the approximate maximum size in bytes of
+// the cell values the worksheet cache of a recalculation keeps in memory, the
+// least recently used columns of the sheets are evicted and read from the
+// worksheets again on demand when it's exceeded. The default 0 doesn't bound
+// the cache. It's ignored if NewWorksheetCacheBackend creates a backend.
+//
+// MaxMergeCostRatio specifies the maximum ratio between the estimated costs
+// of the most expensive formulas of two dependency levels which can be merged
+// into one level. The cost of a formula is estimated from the size of the
+// ranges it references. Merging a level of a few formulas aggregating large
+// ranges with a level of trivial formulas makes the workers of the merged
+// level badly balanced. The default 0 merges the independent levels
+// regardless of their costs.
+//
+// StreamRowsThreshold specifies the number of rows of a source sheet from
+// which the batch SUMIFS patterns stream the rows of the worksheet to the
+// scanning workers, instead of decoding the whole sheet into memory like
+// GetRows. The smaller sheets are decoded at once and shared by the patterns
+// of a recalculation. The default 0 streams the sheets of 100000 rows or more,
+// and a negative value never streams.
+//
+// AuditTrail specifies if the dependency based recalculations record the
+// values computed at each level, and the optimizer which produced them, into
+// an append-only log read by CalcAuditTrail. Each recalculation starts a new
+// trail. AuditTrailLimit specifies the maximum number of the recorded values,
+// the later values are dropped. The default 0 records up to 100000 values,
+// and a negative value doesn't bound the trail.
+//
+// BlankEqualsEmptyString specifies which cells the "" criterion of COUNTIF,
+// COUNTIFS, SUMIF, SUMIFS, AVERAGEIF, AVERAGEIFS, MAXIFS and MINIFS matches.
+// The default BlankMatchesBoth matches the blank cells and the cells of the
+// formulas returning an empty string like Excel, BlankMatchesBlankOnly
+// matches only the blank cells and BlankMatchesEmptyStringOnly only the empty
+// strings.
+//
+// FailFast specifies if RecalculateAllWithDependency stops on the first
+// formula failing with an internal error, such as an invalid formula, and
+// returns a *FormulaCalcError with the cell of the formula. The workers
+// finish the formulas being calculated and skip the others. The formulas
+// producing Excel error values like #DIV/0! don't stop the recalculation. By
+// default, the failed formulas keep their previous values and the
+// recalculation continues.
+//
+// RetainSubExpressions specifies if the dependency based recalculations keep
+// the results of the SUMIFS, AVERAGEIFS and INDEX-MATCH sub-expressions of the
+// composite formulas, like SUMIFS(...)+B2, and reuse them in the later
+// recalculations while the cells and ranges referenced by the sub-expressions
+// are unchanged. This saves the batch scans of the source data in the
+// incremental recalculations of the formulas affected by other operands. The
+// referenced source data is hashed in each recalculation to detect the
+// changes. The retained results are dropped by SetCalcTuning and
+// ClearFormulaCache.
+//
+// ForceIncremental specifies if the incremental recalculations, such as
+// RecalculateAffectedByCells and RecalculateAffectedByColumns, always
+// calculate only the affected formulas, and never fall back to a full
+// recalculation when the affected formulas exceed the threshold set by
+// SetIncrementalFallbackThreshold or the volatile formulas dominate the
+// workbook. Set it if the full recalculation is known to be much more
+// expensive than calculating the affected formulas, whatever their share.
+//
+// Profile specifies if the dependency based recalculations time each formula
+// calculated one by one and each batch pattern, read by SlowestFormulas. Each
+// recalculation starts a new profile. The profile costs two clock readings
+// per formula, so it's disabled by default.
+type CalcTuning struct {
+	LookupKeyNormalizer      func(string) string
+	CleanLookupKeys          bool
+	FailOnCircularDependency bool
+	ShadowOutput             bool
+	UseCalcChain             bool
+	NewWorksheetCacheBackend func() (WorksheetCacheBackend, error)
+	WorksheetCacheMaxBytes   int64
+	MaxMergeCostRatio        float64
+	StreamRowsThreshold      int
+	AuditTrail               bool
+	AuditTrailLimit          int
+	BlankEqualsEmptyString   BlankCriteriaMode
+	FailFast                 bool
+	RetainSubExpressions     bool
+	ForceIncremental         bool
+	Profile                  bool
+}
+
+// BlankCriteriaMode is the type of the cells matched by the "" criterion of the
+// conditional functions, see CalcTuning.BlankEqualsEmptyString.
+type BlankCriteriaMode byte
+
+// The cells matched by the "" criterion of the conditional functions.
+const (
+	BlankMatchesBoth BlankCriteriaMode = iota
+	BlankMatchesBlankOnly
+	BlankMatchesEmptyStringOnly
+)
+
+// SetCalcTuning sets the tuning options of the batch calculation engine. It
+// should not be called while a recalculation is running.
+func (f *File) SetCalcTuning(tuning CalcTuning) {
+	f.calcTuning = tuning
+	f.retainedSubExprs.clear()
+}
+
+// GetCalcTuning returns the tuning options of the batch calculation engine.
+func (f *File) GetCalcTuning() CalcTuning {
+	return f.calcTuning
+}
+
+// SetCalcConcurrency sets the maximum number of the goroutines calculating
+// formulas in parallel, which bounds the workers of the DAG scheduler, the
+// pre-pass of the simple formulas, the batch pattern scans and the concurrent
+// calculation functions. The default 0 uses runtime.NumCPU(), which reports
+// the cores of the host rather than the CPU limit in a container, so set it to
+// the CPU limit to avoid oversubscription. It should not be called while a
+// recalculation is running. For example, limit the calculation to 2 workers:
+//
+//	f.SetCalcConcurrency(2)
+func (f *File) SetCalcConcurrency(n int) {
+	if n < 0 {
+		n = 0
+	}
+	f.calcConcurrency = n
+}
+
+// The default fractions of the affected formulas above which the incremental
+// recalculations fall back to a full recalculation, see
+// SetIncrementalFallbackThreshold.
+const (
+	defaultColumnFallbackThreshold = 0.5
+	defaultCellFallbackThreshold   = 0.7
+)
+
+// SetIncrementalFallbackThreshold sets the fraction of the formulas above
+// which the incremental recalculations fall back to a full recalculation when
+// that many formulas are affected. By default, RecalculateAffectedByColumns
+// falls back above 0.5 and RecalculateAffectedByCells above 0.7, and 0
+// restores the defaults. The incremental recalculation builds a dependency
+// graph of the affected formulas and clears their cached values one by one,
+// the full recalculation clears all cached values and reuses the cached
+// graph of the workbook, so it's cheaper once most formulas are affected. Set
+// a higher fraction if the full recalculation is disproportionately
+// expensive, a fraction of 1 or more never falls back. It should not be called
+// while a recalculation is running. For example:
+//
+//	f.SetIncrementalFallbackThreshold(0.9)
+func (f *File) SetIncrementalFallbackThreshold(fraction float64) {
+	if !(fraction > 0) {
+		fraction = 0
+	}
+	f.fallbackThreshold = fraction
+}
+
+// incrementalFallback reports whether an incremental recalculation of the
+// affected formulas falls back to a full recalculation. The defaultThreshold
+// is the fraction of the recalculation used if no threshold is set.
+func (f *File) incrementalFallback(affected, total int, defaultThreshold float64) bool {
+	if f.calcTuning.ForceIncremental {
+		return false
+	}
+	threshold := f.fallbackThreshold
+	if threshold == 0 {
+		threshold = defaultThreshold
+	}
+	return float64(affected) > float64(total)*threshold
+}
+
+// calcWorkers returns the maximum number of the calculation workers.
+func (f *File) calcWorkers() int {
+	if f.calcConcurrency > 0 {
+		return f.calcConcurrency
+	}
+	return runtime.NumCPU()
+}
+
+// normalizeLookupKey applies the configured lookup key cleanup and normalizer.
+func (f *File) normalizeLookupKey(key string) string {
+	if f.calcTuning.CleanLookupKeys {
+		key = cleanLookupKey(key)
+	}
+	if f.calcTuning.LookupKeyNormalizer == nil {
+		return key
+	}
+	return f.calcTuning.LookupKeyNormalizer(key)
+}
+
+// cleanLookupKey removes the non-printing characters of the key like CLEAN,
+// then trims the spaces like TRIM, the non-breaking spaces count as spaces.
+func cleanLookupKey(key string) string {
+	if !strings.ContainsFunc(key, func(r rune) bool { return r < 33 || r == '\u00a0' }) {
+		return key
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range key {
+		switch {
+		case r == ' ' || r == '\u00a0':
+			space = b.Len() > 0
+		case r < 32:
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,280 @@
+package excelize
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCalcTuningLookupKeyNormalizer(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if _, err := f.NewSheet("Codes"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{{"007", "seven"}, {" 012 ", "twelve"}, {"100", "hundred"}} {
+		cell, _ := CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Codes", cell, &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	formulas := make(map[string]string)
+	for i, key := range []string{"7", "12", "100"} {
+		cell, _ := CoordinatesToCellName(1, i+1)
+		if err := f.SetCellStr("Sheet1", cell, key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		formula := "INDEX(Codes!$B:$B,MATCH(" + cell + ",Codes!$A:$A,0))"
+		target, _ := CoordinatesToCellName(2, i+1)
+		formulas["Sheet1!"+target] = formula
+	}
+
+	// Default identity normalizer: only exact keys match, a missed lookup gives #N/A
+	results := f.batchCalculateINDEXMATCHWithCache(formulas, NewWorksheetCache())
+	if results["Sheet1!B1"] != formulaErrorNA || results["Sheet1!B2"] != formulaErrorNA || results["Sheet1!B3"] != "hundred" {
+		t.Fatalf("unexpected results without normalizer: %v", results)
+	}
+
+	f.SetCalcTuning(CalcTuning{LookupKeyNormalizer: func(key string) string {
+		key = strings.TrimLeft(strings.TrimSpace(key), "0")
+		if key == "" {
+			return "0"
+		}
+		return key
+	}})
+	results = f.batchCalculateINDEXMATCHWithCache(formulas, NewWorksheetCache())
+	for cell, expected := range map[string]string{"Sheet1!B1": "seven", "Sheet1!B2": "twelve", "Sheet1!B3": "hundred"} {
+		if results[cell] != expected {
+			t.Errorf("%s: expected %q, got %q", cell, expected, results[cell])
+		}
+	}
+	if f.GetCalcTuning().LookupKeyNormalizer == nil {
+		t.Fatalf("expected normalizer to be kept")
+	}
+}
+
+func TestCalcTuningCleanLookupKeys(t *testing.T) {
+	for key, want := range map[string]string{
+		"A-1": "A-1", "A-1 ": "A-1", "  A  1\t": "A 1", "B\n2": "B2", "\u00a0C\u00a0 3": "C 3", " ": "", "\x00": "",
+	} {
+		if got := cleanLookupKey(key); got != want {
+			t.Fatalf("cleanLookupKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Codes"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	for i, row := range [][]interface{}{{"A-1 ", "first"}, {"B\n2", "second"}, {"C  3", "third"}} {
+		cell, _ := CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Codes", cell, &row); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	indexMatch, vlookup := make(map[string]string), make(map[string]string)
+	for i, key := range []string{"A-1", "B2", " C 3"} {
+		cell, _ := CoordinatesToCellName(1, i+1)
+		if err := f.SetCellStr("Sheet1", cell, key); err != nil {
+			t.Fatalf("set key: %v", err)
+		}
+		indexMatch[fmt.Sprintf("Sheet1!B%d", i+1)] = "INDEX(Codes!$B:$B,MATCH(" + cell + ",Codes!$A:$A,0))"
+		vlookup[fmt.Sprintf("Sheet1!C%d", i+1)] = "VLOOKUP(" + cell + ",Codes!$A:$B,2,FALSE)"
+	}
+
+	// 默认按原值匹配，末尾空格的键找不到
+	results := f.batchCalculateINDEXMATCHWithCache(indexMatch, NewWorksheetCache())
+	if results["Sheet1!B1"] != formulaErrorNA {
+		t.Fatalf("unexpected results without cleanup: %v", results)
+	}
+	f.SetCalcTuning(CalcTuning{CleanLookupKeys: true})
+	results = f.batchCalculateINDEXMATCHWithCache(indexMatch, NewWorksheetCache())
+	vlookupResults, _ := f.batchCalculateVLOOKUPWithCache(vlookup, NewWorksheetCache())
+	for i, want := range []string{"first", "second", "third"} {
+		if got := results[fmt.Sprintf("Sheet1!B%d", i+1)]; got != want {
+			t.Fatalf("unexpected INDEX-MATCH result %q of row %d, want %q", got, i+1, want)
+		}
+		if got := vlookupResults[fmt.Sprintf("Sheet1!C%d", i+1)]; got != want {
+			t.Fatalf("unexpected VLOOKUP result %q of row %d, want %q", got, i+1, want)
+		}
+	}
+
+	// 清理后再应用自定义的规范化函数
+	f.SetCalcTuning(CalcTuning{CleanLookupKeys: true, LookupKeyNormalizer: strings.ToUpper})
+	if got := f.normalizeLookupKey(" a-1\r\n"); got != "A-1" {
+		t.Fatalf("unexpected normalized key %q", got)
+	}
+}
+
+func TestSetCalcConcurrency(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	if f.calcWorkers() != runtime.NumCPU() {
+		t.Fatalf("expected %d workers by default, got %d", runtime.NumCPU(), f.calcWorkers())
+	}
+	f.SetCalcConcurrency(-1)
+	if f.calcWorkers() != runtime.NumCPU() {
+		t.Fatalf("expected negative concurrency to use the default, got %d", f.calcWorkers())
+	}
+
+	for row := 1; row <= 20; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	logger := &recordingLogger{}
+	f.SetLogger(logger)
+	f.SetCalcConcurrency(3)
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if !strings.Contains(strings.Join(logger.debug, "\n"), "Using 3 workers") {
+		t.Fatalf("expected the DAG calculation to use 3 workers, got %v", logger.debug)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "B20"); value != "40" {
+		t.Fatalf("expected B20=40, got %q", value)
+	}
+}
+
+func TestCalcTuningBlankEqualsEmptyString(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("create sheet: %v", err)
+	}
+	// A1、A4 为空白单元格（A4 仅有样式），A2 为返回空字符串的公式
+	if err := f.SetCellFormula("Data", "A2", `""`); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if err := f.SetCellStyle("Data", "A4", "A4", 0); err != nil {
+		t.Fatalf("set style: %v", err)
+	}
+	for cell, value := range map[string]string{"A3": "x", "A5": "y"} {
+		if err := f.SetCellValue("Data", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for row := 1; row <= 5; row++ {
+		if err := f.SetCellValue("Data", fmt.Sprintf("B%d", row), 1<<row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("A%d", row), `SUMIFS(Data!$B:$B,Data!$A:$A,"",Data!$B:$B,">0")`); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	for _, c := range []struct {
+		mode                BlankCriteriaMode
+		count, sum, average string
+	}{
+		{BlankMatchesBoth, "3", "22", "7.33333333333333"},
+		{BlankMatchesBlankOnly, "2", "18", "9"},
+		{BlankMatchesEmptyStringOnly, "1", "4", "4"},
+	} {
+		f.SetCalcTuning(CalcTuning{BlankEqualsEmptyString: c.mode})
+		for formula, expected := range map[string]string{
+			`COUNTIFS(Data!A1:A5,"")`:             c.count,
+			`COUNTIF(Data!A1:A5,"")`:              c.count,
+			`SUMIFS(Data!B1:B5,Data!A1:A5,"")`:    c.sum,
+			`SUMIF(Data!A1:A5,"",Data!B1:B5)`:     c.sum,
+			`AVERAGEIF(Data!A1:A5,"",Data!B1:B5)`: c.average,
+			`COUNTIFS(Data!A1:A5,"x")`:            "1",
+		} {
+			if err := f.SetCellFormula("Sheet1", "C1", formula); err != nil {
+				t.Fatalf("set formula: %v", err)
+			}
+			if result, err := f.CalcCellValue("Sheet1", "C1"); err != nil || result != expected {
+				t.Fatalf("mode %d: expected %s = %s, got %q, %v", c.mode, formula, expected, result, err)
+			}
+		}
+		// 批量 SUMIFS 对 "" 条件逐个单元格计算
+		if err := f.RecalculateAllWithDependency(); err != nil {
+			t.Fatalf("recalculate: %v", err)
+		}
+		for row := 1; row <= 12; row++ {
+			if result, err := f.GetCellValue("Sheet1", fmt.Sprintf("A%d", row)); err != nil || result != c.sum {
+				t.Fatalf("mode %d: expected A%d = %s, got %q, %v", c.mode, row, c.sum, result, err)
+			}
+		}
+	}
+}
+
+func TestSetIncrementalFallbackThreshold(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+
+	for row := 1; row <= 10; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "C1", "SUM(B1:B6)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	for row := 1; row <= 4; row++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("D%d", row), fmt.Sprintf("E%d+1", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	// 全量重算计算所有 15 个公式，增量重算只计算受影响的公式
+	updatedCells := map[string]bool{}
+	for row := 1; row <= 6; row++ {
+		updatedCells[fmt.Sprintf("Sheet1!A%d", row)] = true
+	}
+	for _, c := range []struct {
+		threshold float64
+		force     bool
+		columns   bool
+		formulas  int
+	}{
+		{formulas: 7},                  // 7/15 受影响，未超过默认的 70%
+		{threshold: 0.4, formulas: 15}, // 超过 40% 回退到全量重算
+		{threshold: 0.4, force: true, formulas: 7},
+		{columns: true, formulas: 15}, // 11/15 受影响，超过默认的 50%
+		{threshold: 0.9, columns: true, formulas: 11},
+		{columns: true, force: true, formulas: 11},
+	} {
+		f.SetIncrementalFallbackThreshold(c.threshold)
+		f.SetCalcTuning(CalcTuning{ForceIncremental: c.force})
+		var err error
+		if c.columns {
+			err = f.RecalculateAffectedByColumns(map[string]bool{"Sheet1!A": true})
+		} else {
+			err = f.RecalculateAffectedByCells(updatedCells)
+		}
+		if err != nil {
+			t.Fatalf("recalculate affected: %v", err)
+		}
+		plan, err := f.LastCalcPlan()
+		if err != nil {
+			t.Fatalf("last calc plan: %v", err)
+		}
+		if plan.Formulas != c.formulas {
+			t.Errorf("threshold %v, force %v, columns %v: expected %d formulas calculated, got %d",
+				c.threshold, c.force, c.columns, c.formulas, plan.Formulas)
+		}
+		if value, _ := f.GetCellValue("Sheet1", "C1"); value != "42" {
+			t.Fatalf("expected C1=42, got %q", value)
+		}
+	}
+
+	f.SetIncrementalFallbackThreshold(-1)
+	if f.fallbackThreshold != 0 {
+		t.Fatalf("expected negative threshold to use the defaults, got %v", f.fallbackThreshold)
+	}
+}
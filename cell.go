@@ -207,6 +207,9 @@ func (f *File) removeFormula(c *xlsxC, ws *xlsxWorksheet, sheet string) error {
 		f.calcCache.Clear()
 		f.rangeCache.Clear()
 	}
+	if c.F != nil {
+		f.invalidateDependencyGraph()
+	}
 	if c.F != nil && c.Vm == nil {
 		sheetID := f.getSheetID(sheet)
 		if err := f.deleteCalcChain(sheetID, c.R); err != nil {
@@ -846,6 +849,7 @@ func (f *File) SetCellFormula(sheet, cell, formula string, opts ...FormulaOpts)
 	}
 	// Use fine-grained cache clearing for single cell formula changes
 	f.clearCellCache(sheet, cell)
+	f.invalidateDependencyGraph()
 	if formula == "" {
 		ws.deleteSharedFormula(c)
 		c.F = nil
@@ -916,7 +920,8 @@ func (f *File) SetCellFormulaWithValue(sheet, cell, formula, value string) error
 		return err
 	}
 
-	// 设置公式（不清除缓存）
+	// 设置公式（不清除缓存），但依赖图需要重建
+	f.invalidateDependencyGraph()
 	if formula == "" {
 		ws.deleteSharedFormula(c)
 		c.F = nil
@@ -952,7 +957,8 @@ func (f *File) SetCellFormulaWithValue(sheet, cell, formula, value string) error
 // normal formula and set cells in this range to the formula as the normal
 // formula.
 func (ws *xlsxWorksheet) setArrayFormula(sheet string, formula *xlsxF, definedNames []DefinedName) error {
-	if len(strings.Split(formula.Ref, ":")) < 2 {
+	// 动态数组公式在锚点计算整个数组，溢出区域中的单元格只保存值
+	if len(strings.Split(formula.Ref, ":")) < 2 || isDynamicArrayFormula(formula.Content) {
 		return nil
 	}
 	coordinates, err := rangeRefToCoordinates(formula.Ref)
@@ -2,7 +2,6 @@ package excelize
 
 import (
 	"fmt"
-	"runtime"
 	"strconv"
 	"sync"
 )
@@ -67,7 +66,7 @@ func (f *File) GetRangeValuesConcurrent(sheet, rangeAddr string, opts ...Options
 	}
 
 	// Split into chunks by rows
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numRows < numWorkers {
 		numWorkers = numRows
 	}
@@ -267,7 +266,7 @@ func (f *File) GetRangeDataConcurrent(sheet, rangeAddr string, opts ...Options)
 	}
 
 	// Split into chunks by rows
-	numWorkers := runtime.NumCPU()
+	numWorkers := f.calcWorkers()
 	if numRows < numWorkers {
 		numWorkers = numRows
 	}
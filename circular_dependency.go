@@ -0,0 +1,285 @@
+package excelize
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/efp"
+)
+
+// CircularDependencyError is returned by RecalculateAllWithDependency when
+// CalcTuning.FailOnCircularDependency is enabled and the formulas contain
+// circular references. Each cycle lists the sorted "Sheet!Cell" references
+// of the formulas involved. It matches ErrCircularDependency with errors.Is.
+type CircularDependencyError struct {
+	Cycles [][]string
+}
+
+// Error returns the error message of the circular dependencies.
+func (e *CircularDependencyError) Error() string {
+	if len(e.Cycles) == 0 {
+		return ErrCircularDependency.Error()
+	}
+	msg := fmt.Sprintf("%s: %s", ErrCircularDependency, strings.Join(e.Cycles[0], ", "))
+	if len(e.Cycles) > 1 {
+		msg += fmt.Sprintf(" and %d more cycles", len(e.Cycles)-1)
+	}
+	return msg
+}
+
+// Unwrap returns ErrCircularDependency.
+func (e *CircularDependencyError) Unwrap() error {
+	return ErrCircularDependency
+}
+
+// DetectCircularReferences builds the dependency graph of all formulas and
+// returns the circular references. Each cycle is a strongly connected group
+// of formula cells, which contains more than one cell or a single cell that
+// references itself. A range reference depends on the formula cells within
+// the range, the references built at calculation time by OFFSET and INDIRECT
+// are not followed. For example:
+//
+//	cycles, err := f.DetectCircularReferences()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for _, cells := range cycles {
+//	    fmt.Println(cells)
+//	}
+func (f *File) DetectCircularReferences() ([][]string, error) {
+	graph, err := f.buildDependencyGraphWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return graph.circularReferences(), nil
+}
+
+// circularReferences returns the strongly connected components of the
+// dependency graph which form a cycle, using an iterative Tarjan algorithm
+// to avoid deep recursion on long formula chains.
+func (g *dependencyGraph) circularReferences() [][]string {
+	cells := make([]string, 0, len(g.nodes))
+	for cell := range g.nodes {
+		cells = append(cells, cell)
+	}
+	sort.Strings(cells)
+
+	// 单元格编号为 0..n-1，范围依赖使用按行排序的列公式单元格上的线段树虚拟节点，
+	// 每个范围只连接 O(log n) 个节点，避免展开成 依赖者 x 范围内公式 条边
+	ids := make(map[string]int, len(cells))
+	for i, cell := range cells {
+		ids[cell] = i
+	}
+	columns := make(map[string][]*circularColumn)
+	byKey := make(map[string]*circularColumn)
+	for i, cell := range cells {
+		idx := strings.LastIndex(cell, "!")
+		if idx == -1 {
+			continue
+		}
+		sheet := cell[:idx]
+		col, row, err := CellNameToCoordinates(cell[idx+1:])
+		if err != nil {
+			continue
+		}
+		key := sheet + "!" + strconv.Itoa(col)
+		column, ok := byKey[key]
+		if !ok {
+			column = &circularColumn{col: col, segments: make(map[[2]int]int)}
+			byKey[key] = column
+			columns[sheet] = append(columns[sheet], column)
+		}
+		column.rows = append(column.rows, row)
+		column.ids = append(column.ids, i)
+	}
+	for _, column := range byKey {
+		sort.Sort(column)
+	}
+	adj := make([][]int, len(cells))
+	var segment func(column *circularColumn, l, r int) int
+	segment = func(column *circularColumn, l, r int) int {
+		if r-l == 1 {
+			return column.ids[l]
+		}
+		if id, ok := column.segments[[2]int{l, r}]; ok {
+			return id
+		}
+		id := len(adj)
+		adj = append(adj, nil)
+		column.segments[[2]int{l, r}] = id
+		m := (l + r) / 2
+		left, right := segment(column, l, m), segment(column, m, r)
+		adj[id] = []int{left, right}
+		return id
+	}
+	var cover func(column *circularColumn, l, r, ql, qr int, out []int) []int
+	cover = func(column *circularColumn, l, r, ql, qr int, out []int) []int {
+		if qr <= l || r <= ql {
+			return out
+		}
+		if ql <= l && r <= qr {
+			return append(out, segment(column, l, r))
+		}
+		m := (l + r) / 2
+		return cover(column, m, r, ql, qr, cover(column, l, m, ql, qr, out))
+	}
+	selfLoop := make([]bool, len(cells))
+	for i, cell := range cells {
+		node := g.nodes[cell]
+		// 单元格依赖按原样连接，OFFSET 和 INDIRECT 的整列虚拟依赖不是静态引用，不参与检测
+		for _, dep := range node.dependencies {
+			if id, exists := ids[dep]; exists {
+				adj[i] = append(adj[i], id)
+				if id == i {
+					selfLoop[i] = true
+				}
+			}
+		}
+		for _, rng := range formulaRangeReferences(node.formula, cell[:max(strings.LastIndex(cell, "!"), 0)]) {
+			for _, column := range columns[rng.sheet] {
+				if column.col < rng.startCol || column.col > rng.endCol {
+					continue
+				}
+				lo, hi := sort.SearchInts(column.rows, rng.startRow), sort.SearchInts(column.rows, rng.endRow+1)
+				for _, id := range cover(column, 0, len(column.rows), lo, hi, nil) {
+					adj[i] = append(adj[i], id)
+					if id == i {
+						selfLoop[i] = true
+					}
+				}
+			}
+		}
+	}
+
+	index := make([]int, len(adj)) // 0 表示未访问
+	low := make([]int, len(adj))
+	onStack := make([]bool, len(adj))
+	var stack []int
+	type frame struct{ node, next int }
+	counter := 0
+	var cycles [][]string
+	visit := func(node int) {
+		counter++
+		index[node], low[node] = counter, counter
+		stack = append(stack, node)
+		onStack[node] = true
+	}
+	for root := range adj {
+		if index[root] != 0 {
+			continue
+		}
+		visit(root)
+		callStack := []frame{{node: root}}
+		for len(callStack) > 0 {
+			top := &callStack[len(callStack)-1]
+			node := top.node
+			if top.next < len(adj[node]) {
+				next := adj[node][top.next]
+				top.next++
+				if index[next] == 0 {
+					visit(next)
+					callStack = append(callStack, frame{node: next})
+				} else if onStack[next] {
+					low[node] = min(low[node], index[next])
+				}
+				continue
+			}
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].node
+				low[parent] = min(low[parent], low[node])
+			}
+			if low[node] != index[node] {
+				continue
+			}
+			// 弹出一个强连通分量
+			size := 0
+			var component []string
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				size++
+				if member < len(cells) {
+					component = append(component, cells[member])
+				}
+				if member == node {
+					break
+				}
+			}
+			if len(component) > 0 && (size > 1 || selfLoop[node]) {
+				sort.Strings(component)
+				cycles = append(cycles, component)
+			}
+		}
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// circularColumn is the formula cells of a column sorted by row, and the
+// virtual nodes of its row segments in the graph of circularReferences.
+type circularColumn struct {
+	col      int
+	rows     []int
+	ids      []int
+	segments map[[2]int]int // 行区间 [l, r) -> 虚拟节点编号
+}
+
+func (c *circularColumn) Len() int           { return len(c.rows) }
+func (c *circularColumn) Less(i, j int) bool { return c.rows[i] < c.rows[j] }
+func (c *circularColumn) Swap(i, j int) {
+	c.rows[i], c.rows[j] = c.rows[j], c.rows[i]
+	c.ids[i], c.ids[j] = c.ids[j], c.ids[i]
+}
+
+// formulaRange is a range referenced by a formula, the whole column and
+// whole row ranges span all rows or columns of the sheet.
+type formulaRange struct {
+	sheet                              string
+	startCol, startRow, endCol, endRow int
+}
+
+// formulaRangeReferences returns the ranges referenced by the formula, the
+// ranges without a sheet name are on currentSheet.
+func formulaRangeReferences(formula, currentSheet string) []formulaRange {
+	var ranges []formulaRange
+	ps := efp.ExcelParser()
+	for _, token := range expandImplicitIntersection(ps.Parse(formula)) {
+		if token.TType != efp.TokenTypeOperand || token.TSubType != efp.TokenSubTypeRange {
+			continue
+		}
+		sheet, ref, ok := splitSheetReference(token.TValue)
+		if !ok {
+			sheet = currentSheet
+		}
+		start, end, isRange := strings.Cut(strings.ReplaceAll(ref, "$", ""), ":")
+		if !isRange {
+			continue
+		}
+		rng := formulaRange{sheet: sheet}
+		var err1, err2 error
+		if rng.startCol, rng.startRow, err1 = CellNameToCoordinates(start); err1 == nil {
+			rng.endCol, rng.endRow, err2 = CellNameToCoordinates(end)
+		} else if startRow, err := strconv.Atoi(start); err == nil {
+			rng.startCol, rng.endCol, rng.startRow = 1, MaxColumns, startRow
+			rng.endRow, err2 = strconv.Atoi(end)
+		} else {
+			rng.startRow, rng.endRow = 1, TotalRows
+			if rng.startCol, err1 = ColumnNameToNumber(start); err1 == nil {
+				rng.endCol, err2 = ColumnNameToNumber(end)
+			}
+		}
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rng.startCol, rng.endCol = min(rng.startCol, rng.endCol), max(rng.startCol, rng.endCol)
+		rng.startRow, rng.endRow = min(rng.startRow, rng.endRow), max(rng.startRow, rng.endRow)
+		ranges = append(ranges, rng)
+	}
+	return ranges
+}
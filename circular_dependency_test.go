@@ -0,0 +1,139 @@
+package excelize
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDetectCircularReferences(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1": "A1*2",
+		// B2 -> C2 -> D2 -> B2
+		"B2": "C2+1",
+		"C2": "D2+1",
+		"D2": "B2+1",
+		// E1 依赖环但不在环中
+		"E1": "D2*2",
+		// 自引用
+		"F1": "F1+A1",
+		// G1 引用整列 H，H1 引用 G1
+		"G1": "SUM(H:H)",
+		"H1": "G1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	cycles, err := f.DetectCircularReferences()
+	if err != nil {
+		t.Fatalf("DetectCircularReferences failed: %v", err)
+	}
+	want := [][]string{
+		{"Sheet1!B2", "Sheet1!C2", "Sheet1!D2"},
+		{"Sheet1!F1"},
+		{"Sheet1!G1", "Sheet1!H1"},
+	}
+	if !reflect.DeepEqual(cycles, want) {
+		t.Fatalf("unexpected cycles %v, want %v", cycles, want)
+	}
+
+	f.SetCalcTuning(CalcTuning{FailOnCircularDependency: true})
+	err = f.RecalculateAllWithDependency()
+	if !errors.Is(err, ErrCircularDependency) {
+		t.Fatalf("expected ErrCircularDependency, got %v", err)
+	}
+	var cycleErr *CircularDependencyError
+	if !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Cycles, want) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err.Error() != "circular dependency between formulas: Sheet1!B2, Sheet1!C2, Sheet1!D2 and 2 more cycles" {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+
+	// 默认保持原有行为：计算环以外的公式，不返回错误
+	g := NewFile()
+	t.Cleanup(func() { _ = g.Close() })
+	for cell, formula := range map[string]string{"A2": "1+1", "B2": "B2+A2"} {
+		if err := g.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := g.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate with default tuning: %v", err)
+	}
+	if value, _ := g.GetCellValue("Sheet1", "A2"); value != "2" {
+		t.Fatalf("unexpected A2 value %q", value)
+	}
+
+	// 无环时正常计算
+	g.SetCalcTuning(CalcTuning{FailOnCircularDependency: true})
+	if err := g.SetCellFormula("Sheet1", "B2", "A2*2"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if cycles, err := g.DetectCircularReferences(); err != nil || len(cycles) != 0 {
+		t.Fatalf("expected no cycles, got %v, %v", cycles, err)
+	}
+	if err := g.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate without cycles: %v", err)
+	}
+	if value, _ := g.GetCellValue("Sheet1", "B2"); value != "4" {
+		t.Fatalf("unexpected B2 value %q", value)
+	}
+}
+
+func TestDetectCircularReferencesWithoutFalseCycles(t *testing.T) {
+	// OFFSET 的动态引用不参与检测，范围只依赖其中的公式单元格
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	f.SetCalcTuning(CalcTuning{FailOnCircularDependency: true})
+	if err := f.SetCellValue("Sheet1", "A1", 7); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1":   "OFFSET(Sheet1!A1,0,0)",
+		"C1":   "A1+1",
+		"D1":   "SUM(C1:C200)",
+		"C500": "D1*2",
+		"E1":   "SUM(Sheet1!$E$2:$E$3)",
+		"E2":   "E1+1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	cycles, err := f.DetectCircularReferences()
+	if err != nil {
+		t.Fatalf("detect circular references: %v", err)
+	}
+	if want := [][]string{{"Sheet1!E1", "Sheet1!E2"}}; !reflect.DeepEqual(cycles, want) {
+		t.Fatalf("expected cycles %v, got %v", want, cycles)
+	}
+
+	if err := f.SetCellFormula("Sheet1", "E1", "SUM(Sheet1!$E$3:$E$4)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	if cycles, err := f.DetectCircularReferences(); err != nil || len(cycles) != 0 {
+		t.Fatalf("expected no cycles, got %v, %v", cycles, err)
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate without cycles: %v", err)
+	}
+	for cell, want := range map[string]string{"B1": "7", "D1": "8", "C500": "16"} {
+		if value, _ := f.GetCellValue("Sheet1", cell); value != want {
+			t.Fatalf("unexpected %s value %q, want %q", cell, value, want)
+		}
+	}
+}
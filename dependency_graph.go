@@ -0,0 +1,98 @@
+package excelize
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is a read-only view of the formula dependency graph used by
+// the dependency based recalculation. Cells are identified as "Sheet!Cell",
+// e.g. "Sheet1!A1". All methods return copies, modifying the returned slices
+// doesn't affect the graph.
+type DependencyGraph struct {
+	graph      *dependencyGraph
+	dependents map[string][]string // "Sheet!Cell" or "Sheet!Col" -> dependent formula cells
+}
+
+// BuildDependencyGraph analyzes all formulas of the workbook and returns the
+// dependency graph, formulas are grouped into the levels in the same order
+// as a recalculation calculates them. For example:
+//
+//	graph, err := f.BuildDependencyGraph()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	for level, cells := range graph.Levels() {
+//	    fmt.Println(level, cells)
+//	}
+func (f *File) BuildDependencyGraph() (*DependencyGraph, error) {
+	graph, err := f.buildDependencyGraphWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return newDependencyGraph(graph), nil
+}
+
+// newDependencyGraph creates the exported view of the dependency graph and
+// builds the reverse dependency index.
+func newDependencyGraph(graph *dependencyGraph) *DependencyGraph {
+	dg := &DependencyGraph{graph: graph, dependents: make(map[string][]string)}
+	for cell, node := range graph.nodes {
+		for _, dep := range node.dependencies {
+			// 列依赖 "COLUMN:Sheet!Col" 以 "Sheet!Col" 为键，查询时按单元格所在列匹配
+			dep = strings.TrimPrefix(dep, "COLUMN:")
+			dg.dependents[dep] = append(dg.dependents[dep], cell)
+		}
+	}
+	return dg
+}
+
+// Levels returns the formula cells grouped by dependency level. The formulas
+// of a level only depend on the formulas of the former levels, cells within
+// a level are sorted.
+func (dg *DependencyGraph) Levels() [][]string {
+	levels := make([][]string, len(dg.graph.levels))
+	for i, cells := range dg.graph.levels {
+		levels[i] = append([]string(nil), cells...)
+		sort.Strings(levels[i])
+	}
+	return levels
+}
+
+// Dependencies returns the references the formula of the given cell depends
+// on. A single cell reference is returned as "Sheet!Cell", and a column range
+// reference that covers the formula cells of a column is returned as
+// "COLUMN:Sheet!Col". It returns nil if the cell has no formula.
+func (dg *DependencyGraph) Dependencies(cell string) []string {
+	node, ok := dg.graph.nodes[cell]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), node.dependencies...)
+}
+
+// Dependents returns the sorted formula cells which directly depend on the
+// given cell, either by a reference to the cell or by a column range
+// reference which covers it.
+func (dg *DependencyGraph) Dependents(cell string) []string {
+	seen := make(map[string]bool)
+	var dependents []string
+	add := func(cells []string) {
+		for _, c := range cells {
+			if !seen[c] {
+				seen[c] = true
+				dependents = append(dependents, c)
+			}
+		}
+	}
+	add(dg.dependents[cell])
+	if idx := strings.LastIndex(cell, "!"); idx != -1 {
+		if col, _, err := SplitCellName(cell[idx+1:]); err == nil {
+			add(dg.dependents[cell[:idx]+"!"+col])
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
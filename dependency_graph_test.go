@@ -0,0 +1,93 @@
+package excelize
+
+import (
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBuildDependencyGraph(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{
+		"B1": "A1*2",
+		"C1": "B1+1",
+		"D1": "SUM(B:B)",
+		"E1": "C1+D1",
+	} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	graph, err := f.BuildDependencyGraph()
+	if err != nil {
+		t.Fatalf("BuildDependencyGraph failed: %v", err)
+	}
+
+	levels := graph.Levels()
+	want := [][]string{{"Sheet1!B1"}, {"Sheet1!C1", "Sheet1!D1"}, {"Sheet1!E1"}}
+	if !reflect.DeepEqual(levels, want) {
+		t.Fatalf("unexpected levels %v, want %v", levels, want)
+	}
+	if deps := graph.Dependencies("Sheet1!C1"); !reflect.DeepEqual(deps, []string{"Sheet1!B1"}) {
+		t.Fatalf("unexpected dependencies of C1: %v", deps)
+	}
+	if deps := graph.Dependencies("Sheet1!D1"); !reflect.DeepEqual(deps, []string{"COLUMN:Sheet1!B"}) {
+		t.Fatalf("unexpected dependencies of D1: %v", deps)
+	}
+	if deps := graph.Dependencies("Sheet1!A1"); deps != nil {
+		t.Fatalf("expected no dependencies for a value cell, got %v", deps)
+	}
+	if dependents := graph.Dependents("Sheet1!B1"); !reflect.DeepEqual(dependents, []string{"Sheet1!C1", "Sheet1!D1"}) {
+		t.Fatalf("unexpected dependents of B1: %v", dependents)
+	}
+	if dependents := graph.Dependents("Sheet1!A1"); !reflect.DeepEqual(dependents, []string{"Sheet1!B1"}) {
+		t.Fatalf("unexpected dependents of A1: %v", dependents)
+	}
+	if dependents := graph.Dependents("Sheet1!E1"); len(dependents) != 0 {
+		t.Fatalf("expected no dependents of E1, got %v", dependents)
+	}
+
+	// The returned slices are copies
+	levels[0][0] = "changed"
+	graph.Dependencies("Sheet1!C1")[0] = "changed"
+	graph.Dependents("Sheet1!B1")[0] = "changed"
+	if graph.Levels()[0][0] != "Sheet1!B1" || graph.Dependencies("Sheet1!C1")[0] != "Sheet1!B1" ||
+		graph.Dependents("Sheet1!B1")[0] != "Sheet1!C1" {
+		t.Fatalf("modifying the returned slices changed the graph")
+	}
+}
+
+func TestDependencyGraphDependentsSheetNameWithExclamation(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	// 工作表名称可以包含感叹号，单元格引用在最后一个感叹号之后
+	if _, err := f.NewSheet("Q1!2024"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	if err := f.SetCellValue("Q1!2024", "B5", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.SetCellFormula("Sheet1", "A1", "SUM('Q1!2024'!B:B)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+	graph, err := f.BuildDependencyGraph()
+	if err != nil {
+		t.Fatalf("BuildDependencyGraph failed: %v", err)
+	}
+	if dependents := graph.Dependents("Q1!2024!B5"); !reflect.DeepEqual(dependents, []string{"Sheet1!A1"}) {
+		t.Fatalf("unexpected dependents of Q1!2024!B5: %v", dependents)
+	}
+}
@@ -24,6 +24,10 @@ var (
 	// ErrAttrValBool defined the error message on marshal and unmarshal
 	// boolean type XML attribute.
 	ErrAttrValBool = errors.New("unexpected child of attrValBool")
+	// ErrCalcCacheFingerprint defined the error message on loading a
+	// calculation cache saved for a workbook with different formulas, input
+	// cell values or defined names.
+	ErrCalcCacheFingerprint = errors.New("calc cache was saved for a different workbook")
 	// ErrCellCharsLength defined the error message for receiving a cell
 	// characters length that exceeds the limit.
 	ErrCellCharsLength = fmt.Errorf("cell value must be 0-%d characters", TotalCellChars)
@@ -38,8 +42,14 @@ var (
 	// ErrCoordinates defined the error message on invalid coordinates tuples
 	// length.
 	ErrCoordinates = errors.New("coordinates length must be 4")
+	// ErrCircularDependency defined the error message on formulas with
+	// circular references in the dependency based recalculation.
+	ErrCircularDependency = errors.New("circular dependency between formulas")
 	// ErrCustomNumFmt defined the error message on receive the empty custom number format.
 	ErrCustomNumFmt = errors.New("custom number format can not be empty")
+	// ErrDependencyGraphNotBuilt defined the error message on inspecting the
+	// dependency graph before any dependency recalculation.
+	ErrDependencyGraphNotBuilt = errors.New("dependency graph has not been built")
 	// ErrDataValidationFormulaLength defined the error message for receiving a
 	// data validation formula length that exceeds the limit.
 	ErrDataValidationFormulaLength = fmt.Errorf("data validation must be 0-%d characters", MaxFieldLength)
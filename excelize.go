@@ -23,49 +23,65 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/net/html/charset"
 )
 
 // File define a populated spreadsheet file struct.
 type File struct {
-	mu               sync.Mutex
-	recalcMu         sync.Mutex // Mutex for RecalculateAllWithDependency to prevent concurrent recalculation
-	checked          sync.Map
-	formulaChecked   bool
-	inBatchMode      bool
-	zip64Entries     []string
-	options          *Options
-	sharedStringItem [][]uint
-	sharedStringsMap map[string]int
-	sharedStringTemp *os.File
-	sheetMap         map[string]string
-	streams          map[string]*StreamWriter
-	tempFiles        sync.Map
-	xmlAttr          sync.Map
-	calcCache        sync.Map
-	rangeCache       *lruCache // LRU cache for range matrices to limit memory usage
-	matchIndexCache  sync.Map  // Cache for MATCH hash indexes: key -> map[string]int
-	ifsMatchCache    sync.Map  // Cache for SUMIFS/COUNTIFS criteria matching: key -> []cellRef
-	rangeIndexCache  sync.Map  // Cache for range value indexes: rangeKey -> map[value][]cellRef
-	CalcChain        *xlsxCalcChain
-	CharsetReader    func(charset string, input io.Reader) (rdr io.Reader, err error)
-	Comments         map[string]*xlsxComments
-	ContentTypes     *xlsxTypes
-	DecodeVMLDrawing map[string]*decodeVmlDrawing
-	DecodeCellImages *decodeCellImages
-	Drawings         sync.Map
-	Path             string
-	Pkg              sync.Map
-	Relationships    sync.Map
-	SharedStrings    *xlsxSST
-	Sheet            sync.Map
-	SheetCount       int
-	Styles           *xlsxStyleSheet
-	Theme            *decodeTheme
-	VMLDrawing       map[string]*vmlDrawing
-	VolatileDeps     *xlsxVolTypes
-	WorkBook         *xlsxWorkbook
+	mu                sync.Mutex
+	recalcMu          sync.Mutex // Mutex for RecalculateAllWithDependency to prevent concurrent recalculation
+	checked           sync.Map
+	formulaChecked    bool
+	inBatchMode       bool
+	zip64Entries      []string
+	options           *Options
+	sharedStringItem  [][]uint
+	sharedStringsMap  map[string]int
+	sharedStringTemp  *os.File
+	sheetMap          map[string]string
+	streams           map[string]*StreamWriter
+	tempFiles         sync.Map
+	xmlAttr           sync.Map
+	calcCache         sync.Map
+	rangeCache        *lruCache                         // LRU cache for range matrices to limit memory usage
+	matchIndexCache   sync.Map                          // Cache for MATCH hash indexes: key -> map[string]int
+	ifsMatchCache     sync.Map                          // Cache for SUMIFS/COUNTIFS criteria matching: key -> []cellRef
+	rangeIndexCache   sync.Map                          // Cache for range value indexes: rangeKey -> map[value][]cellRef
+	sheetDataCache    atomic.Pointer[SheetDataCache]    // Raw rows shared by batch patterns during a recalculation
+	calcTuning        CalcTuning                        // Tuning options of the batch calculation engine
+	calcConcurrency   int                               // Maximum number of calculation workers, runtime.NumCPU() if 0
+	fallbackThreshold float64                           // Fraction of affected formulas above which incremental recalculations fall back to full, the defaults if 0
+	levelHistogram    atomic.Pointer[[]int]             // Formulas per level of the last dependency graph build
+	lastCalcPlan      atomic.Pointer[CalcPlan]          // Plan and batch pattern counts of the last completed dependency recalculation
+	computedValues    atomic.Pointer[map[string]string] // Computed formula values of the last shadow recalculation
+	shadow            atomic.Pointer[shadowOutput]      // Computed formula values of the running shadow recalculation
+	calcAudit         atomic.Pointer[calcAuditLog]      // Audit trail of the last recalculation with CalcTuning.AuditTrail
+	calcProfile       atomic.Pointer[calcProfile]       // Formula and batch pattern timings of the last recalculation with CalcTuning.Profile
+	depGraphCache     dependencyGraphCache              // Dependency graph reused by the incremental recalculations, guarded by recalcMu
+	formulaGeneration atomic.Uint64                     // Incremented when the formulas change, invalidates depGraphCache
+	retainedSubExprs  retainedSubExprCache              // Sub-expression results kept between recalculations with CalcTuning.RetainSubExpressions
+	calcLogger        Logger                            // Logger of the batch calculation engine, no-op if nil
+	calcTracer        Tracer                            // Tracer of the batch calculation engine, no-op if nil
+	CalcChain         *xlsxCalcChain
+	CharsetReader     func(charset string, input io.Reader) (rdr io.Reader, err error)
+	Comments          map[string]*xlsxComments
+	ContentTypes      *xlsxTypes
+	DecodeVMLDrawing  map[string]*decodeVmlDrawing
+	DecodeCellImages  *decodeCellImages
+	Drawings          sync.Map
+	Path              string
+	Pkg               sync.Map
+	Relationships     sync.Map
+	SharedStrings     *xlsxSST
+	Sheet             sync.Map
+	SheetCount        int
+	Styles            *xlsxStyleSheet
+	Theme             *decodeTheme
+	VMLDrawing        map[string]*vmlDrawing
+	VolatileDeps      *xlsxVolTypes
+	WorkBook          *xlsxWorkbook
 	// OnCellCalculated is an optional callback invoked when a formula
 	// calculation writes a new value to a cell. It is only triggered when
 	// the value actually changes. Callers must ensure concurrency safety
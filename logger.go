@@ -0,0 +1,44 @@
+package excelize
+
+// Logger is the interface of the logger of the dependency-aware batch
+// calculation engine. Infof receives the progress of a recalculation, such as
+// the start and the end of the dependency analysis and of each level, and
+// warnings. Debugf receives the details, such as batch pattern statistics and
+// cache usage.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+}
+
+// nopLogger is the default logger which discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+
+func (nopLogger) Infof(string, ...interface{}) {}
+
+// SetLogger sets the logger of the calculation engine, the messages are
+// discarded by default or if logger is nil. It should not be called while a
+// recalculation is running. For example, print the progress with the standard
+// library logger:
+//
+//	type stdLogger struct{}
+//
+//	func (stdLogger) Debugf(format string, args ...interface{}) {}
+//
+//	func (stdLogger) Infof(format string, args ...interface{}) {
+//	    log.Printf(format, args...)
+//	}
+//
+//	f.SetLogger(stdLogger{})
+func (f *File) SetLogger(logger Logger) {
+	f.calcLogger = logger
+}
+
+// logger returns the logger of the calculation engine.
+func (f *File) logger() Logger {
+	if f.calcLogger == nil {
+		return nopLogger{}
+	}
+	return f.calcLogger
+}
@@ -0,0 +1,86 @@
+package excelize
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger records the formatted messages by level
+type recordingLogger struct {
+	mu    sync.Mutex
+	debug []string
+	info  []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.info = append(l.info, fmt.Sprintf(format, args...))
+}
+
+func TestSetLogger(t *testing.T) {
+	var stdout bytes.Buffer
+	log.SetOutput(&stdout)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	for cell, formula := range map[string]string{"B1": "A1*2", "C1": "B1+1"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	// 默认不输出任何日志
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output by default, got %q", stdout.String())
+	}
+
+	logger := &recordingLogger{}
+	f.SetLogger(logger)
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output with a logger, got %q", stdout.String())
+	}
+	info, debug := strings.Join(logger.info, "\n"), strings.Join(logger.debug, "\n")
+	for _, want := range []string{"[RecalculateAll] Starting", "[DAG Calculation] Completed", "[Level 1] Processing"} {
+		if !strings.Contains(info, want) {
+			t.Fatalf("expected info message %q, got %v", want, logger.info)
+		}
+	}
+	if !strings.Contains(debug, "[Dependency Analysis]") {
+		t.Fatalf("expected debug messages of the dependency analysis, got %v", logger.debug)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "C1"); value != "5" {
+		t.Fatalf("expected C1=5, got %q", value)
+	}
+
+	// 设置为 nil 时恢复为不输出日志
+	f.SetLogger(nil)
+	count := len(logger.info) + len(logger.debug)
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if len(logger.info)+len(logger.debug) != count || stdout.Len() != 0 {
+		t.Fatalf("expected no messages after resetting the logger")
+	}
+}
@@ -68,6 +68,7 @@ func (f *File) MoveRow(sheet string, fromRow, toRow int) error {
 	// Clear caches
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 
 	return nil
 }
@@ -399,6 +400,7 @@ func (f *File) MoveCol(sheet string, fromCol, toCol string) error {
 	// Clear caches
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 
 	return nil
 }
@@ -688,6 +690,7 @@ func (f *File) MoveRows(sheet string, fromRow, count, toRow int) error {
 	// Clear caches
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 
 	return nil
 }
@@ -1010,6 +1013,7 @@ func (f *File) MoveCols(sheet string, fromCol string, count int, toCol string) e
 	// Clear caches
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 
 	return nil
 }
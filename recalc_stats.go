@@ -0,0 +1,141 @@
+package excelize
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// RecalcStats directly maps the batch optimization statistics of a dependency
+// based recalculation, returned by RecalculateAllWithDependencyStats.
+//
+// Levels is the number of the calculated dependency levels, UnmergedLevels the
+// number of levels before the independent levels were merged, and
+// MergedLevelReduction the percentage of the levels removed by merging.
+//
+// Optimized counts the formulas matching a batch pattern, including the
+// composite formulas using a batch calculated subexpression, and
+// UnoptimizedShapes counts the other formulas by their outermost function,
+// such as "IF" or "SUM", or "=" for the formulas without a function call
+// like "A1*2". BatchedSUMIFS and BatchedINDEXMATCH count the formulas which
+// are a single SUMIFS/AVERAGEIFS and the formulas containing INDEX-MATCH.
+//
+// CacheHits counts the formulas whose value the DAG scheduler found already
+// calculated by the simple formula pre-pass or the batch patterns, and
+// CacheMisses the formulas it calculated one by one. CacheHitRate is the
+// percentage of the hits.
+type RecalcStats struct {
+	Formulas             int
+	Levels               int
+	UnmergedLevels       int
+	MergedLevelReduction float64
+	Optimized            int
+	Unoptimized          int
+	UnoptimizedShapes    map[string]int
+	BatchedSUMIFS        int
+	BatchedINDEXMATCH    int
+	CacheHits            int64
+	CacheMisses          int64
+	CacheHitRate         float64
+	LevelTimings         []LevelTiming
+	Duration             time.Duration
+}
+
+// LevelTiming directly maps the statistics of a dependency level of a
+// recalculation. PreCalc is the time calculating the simple formulas, Batch
+// the time of the batch patterns and DAG the time of the DAG scheduler.
+type LevelTiming struct {
+	Level     int
+	Formulas  int
+	Optimized int
+	PreCalc   time.Duration
+	Batch     time.Duration
+	DAG       time.Duration
+	Total     time.Duration
+}
+
+// recalcStatsKey is the context key of the RecalcStats of a recalculation.
+type recalcStatsKey struct{}
+
+// recalcStatsFromContext returns the RecalcStats carried by ctx, or nil.
+func recalcStatsFromContext(ctx context.Context) *RecalcStats {
+	stats, _ := ctx.Value(recalcStatsKey{}).(*RecalcStats)
+	return stats
+}
+
+// startGraph records the levels of the dependency graph of a recalculation.
+func (s *RecalcStats) startGraph(graph *dependencyGraph) {
+	s.Levels = len(graph.levels)
+	s.UnmergedLevels = graph.unmergedLevels
+	if s.UnmergedLevels < s.Levels {
+		s.UnmergedLevels = s.Levels
+	}
+	if s.UnmergedLevels > 0 {
+		s.MergedLevelReduction = float64(s.UnmergedLevels-s.Levels) * 100 / float64(s.UnmergedLevels)
+	}
+	s.UnoptimizedShapes = make(map[string]int)
+}
+
+// addLevel records the formulas and the timing of a calculated level.
+func (s *RecalcStats) addLevel(timing LevelTiming, levelCells []string, graph *dependencyGraph, plan CalcPlan) {
+	for _, cell := range levelCells {
+		node, ok := graph.nodes[cell]
+		if !ok {
+			continue
+		}
+		if batchPatternOf(node.formula) != "" {
+			timing.Optimized++
+			continue
+		}
+		s.Unoptimized++
+		s.UnoptimizedShapes[formulaShape(node.formula)]++
+	}
+	s.Formulas += timing.Formulas
+	s.Optimized += timing.Optimized
+	s.BatchedSUMIFS += plan.PureSUMIFS
+	s.BatchedINDEXMATCH += plan.INDEXMATCHFormulas
+	s.LevelTimings = append(s.LevelTimings, timing)
+}
+
+// finish computes the cache hit rate and records the total duration.
+func (s *RecalcStats) finish(duration time.Duration) {
+	if total := s.CacheHits + s.CacheMisses; total > 0 {
+		s.CacheHitRate = float64(s.CacheHits) * 100 / float64(total)
+	}
+	s.Duration = duration
+}
+
+// formulaShape returns the outermost function name of a formula, or "=" if
+// the formula doesn't start with a function call.
+func formulaShape(formula string) string {
+	formula = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(formula), "="))
+	name, _, ok := strings.Cut(formula, "(")
+	if !ok || name == "" || strings.IndexFunc(name, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_')
+	}) != -1 {
+		return "="
+	}
+	return strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(name, "_xlfn."), "_xlws."))
+}
+
+// RecalculateAllWithDependencyStats recalculates all formulas like
+// RecalculateAllWithDependency and returns the batch optimization statistics,
+// which show how well the batch patterns covered the workbook and which
+// formula shapes were calculated one by one. The statistics of the levels
+// calculated before an error are returned with the error. For example:
+//
+//	stats, err := f.RecalculateAllWithDependencyStats()
+//	if err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	fmt.Printf("optimized %d of %d formulas, cache hit rate %.1f%%\n",
+//	    stats.Optimized, stats.Formulas, stats.CacheHitRate)
+//	for shape, count := range stats.UnoptimizedShapes {
+//	    fmt.Println(shape, count)
+//	}
+func (f *File) RecalculateAllWithDependencyStats() (*RecalcStats, error) {
+	stats := &RecalcStats{}
+	err := f.RecalculateAllWithDependencyContext(context.WithValue(context.Background(), recalcStatsKey{}, stats))
+	return stats, err
+}
@@ -0,0 +1,94 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestRecalculateAllWithDependencyStats(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	// 12 个可批量计算的纯 SUMIFS，以及不能批量计算的 SUM、IF 和算术公式组成的计算链
+	for row := 1; row <= 12; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{row % 3, row}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row%3); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,$A%d)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{"D1": "SUM(B1:B12)", "D2": "D1*2", "D3": `IF(D2>100,"high","low")`, "D4": "_xlfn.CONCAT(D3,D1)"} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+
+	stats, err := f.RecalculateAllWithDependencyStats()
+	if err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "D3"); value != "high" {
+		t.Fatalf("unexpected D3 value %q, want high", value)
+	}
+	if stats.Formulas != 16 || stats.Optimized != 12 || stats.Unoptimized != 4 || stats.BatchedSUMIFS != 12 || stats.BatchedINDEXMATCH != 0 {
+		t.Fatalf("unexpected formula counts %+v", stats)
+	}
+	for shape, want := range map[string]int{"SUM": 1, "=": 1, "IF": 1, "CONCAT": 1} {
+		if stats.UnoptimizedShapes[shape] != want {
+			t.Fatalf("unexpected unoptimized shapes %v", stats.UnoptimizedShapes)
+		}
+	}
+	if stats.Levels == 0 || stats.UnmergedLevels < stats.Levels || len(stats.LevelTimings) != stats.Levels {
+		t.Fatalf("unexpected levels %d, unmerged %d, timings %d", stats.Levels, stats.UnmergedLevels, len(stats.LevelTimings))
+	}
+	if want := float64(stats.UnmergedLevels-stats.Levels) * 100 / float64(stats.UnmergedLevels); stats.MergedLevelReduction != want {
+		t.Fatalf("unexpected merged level reduction %g, want %g", stats.MergedLevelReduction, want)
+	}
+	formulas, optimized := 0, 0
+	for _, timing := range stats.LevelTimings {
+		formulas += timing.Formulas
+		optimized += timing.Optimized
+		if timing.Total < timing.DAG || timing.Total < timing.Batch {
+			t.Fatalf("unexpected level timing %+v", timing)
+		}
+	}
+	if formulas != stats.Formulas || optimized != stats.Optimized {
+		t.Fatalf("unexpected level timings %+v", stats.LevelTimings)
+	}
+	// 批量结果和预计算的简单公式都由 DAG 调度器直接读取
+	if stats.CacheHits+stats.CacheMisses != int64(stats.Formulas) || stats.CacheHits < 12 || stats.CacheHitRate <= 0 || stats.Duration <= 0 {
+		t.Fatalf("unexpected cache statistics %+v", stats)
+	}
+
+	// 不请求统计信息的重算不受影响
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+}
+
+func TestFormulaShape(t *testing.T) {
+	for formula, want := range map[string]string{
+		"SUM(A1:A3)":                "SUM",
+		"=if(A1>1,1,2)":             "IF",
+		"_xlfn.XLOOKUP(A1,B:B,C:C)": "XLOOKUP",
+		"A1*2":                      "=",
+		"(A1+B1)*2":                 "=",
+		"Sheet1!A1+SUM(B:B)":        "=",
+	} {
+		if got := formulaShape(formula); got != want {
+			t.Fatalf("formulaShape(%q) = %q, want %q", formula, got, want)
+		}
+	}
+}
@@ -67,6 +67,11 @@ func (f *File) GetRows(sheet string, opts ...Options) ([][]string, error) {
 	results, cur, maxVal := make([][]string, 0, 1024), 0, 0
 	for rows.Next() {
 		cur++
+		if rows.curRow > rows.seekRow {
+			// 跳过两个 row 元素之间的空行，空行由下一个非空行补齐
+			cur, rows.seekRow = rows.curRow-1, rows.curRow-1
+			continue
+		}
 		row, err := rows.Columns(opts...)
 		if err != nil {
 			break
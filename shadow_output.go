@@ -0,0 +1,183 @@
+package excelize
+
+import (
+	"strings"
+	"sync"
+)
+
+// shadowOutput collects the computed values of the formula cells during a
+// dependency based recalculation with CalcTuning.ShadowOutput enabled.
+// setFormulaValue puts the values here instead of the cells, and the raw rows
+// read by the batch patterns are overlaid by them, so later levels read the
+// computed values the same way as in the default mode.
+type shadowOutput struct {
+	mu     sync.RWMutex
+	values map[string]map[string]string // sheet -> cell -> computed value
+}
+
+// set records the computed value of a formula cell, and reports if the value
+// changed.
+func (s *shadowOutput) set(sheet, cell, value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells, ok := s.values[sheet]
+	if !ok {
+		cells = make(map[string]string)
+		s.values[sheet] = cells
+	}
+	if oldValue, ok := cells[cell]; ok && oldValue == value {
+		return false
+	}
+	cells[cell] = value
+	return true
+}
+
+// value returns the computed value of a formula cell.
+func (s *shadowOutput) value(sheet, cell string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[sheet][cell]
+	return value, ok
+}
+
+// sheetArgs returns the computed values of the formula cells of a worksheet
+// as string arguments keeping the raw values, like the overlays of the
+// worksheet cache.
+func (s *shadowOutput) sheetArgs(sheet string) map[string]formulaArg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	args := make(map[string]formulaArg, len(s.values[sheet]))
+	for cell, value := range s.values[sheet] {
+		args[cell] = newStringFormulaArg(value)
+	}
+	return args
+}
+
+// GetComputedValue returns the value of a formula cell computed by the last
+// dependency based recalculation with CalcTuning.ShadowOutput enabled, while
+// GetCellValue keeps returning the value stored in the workbook. The ok result
+// is false if the cell was not recalculated in shadow mode. For example, list
+// the formulas whose stored value differs from the recomputed one:
+//
+//	f.SetCalcTuning(excelize.CalcTuning{ShadowOutput: true})
+//	if err := f.RecalculateAllWithDependency(); err != nil {
+//	    fmt.Println(err)
+//	    return
+//	}
+//	stored, _ := f.GetCellValue("Sheet1", "B2")
+//	if computed, ok := f.GetComputedValue("Sheet1", "B2"); ok && computed != stored {
+//	    fmt.Printf("B2: stored %s, computed %s\n", stored, computed)
+//	}
+func (f *File) GetComputedValue(sheet, cell string) (string, bool) {
+	values := f.computedValues.Load()
+	if values == nil {
+		return "", false
+	}
+	value, ok := (*values)[sheet+"!"+strings.ReplaceAll(cell, "$", "")]
+	return value, ok
+}
+
+// beginShadowOutput starts collecting the computed values of a recalculation
+// apart from the cells. It returns nil if CalcTuning.ShadowOutput is disabled
+// or a shadow recalculation is already running.
+func (f *File) beginShadowOutput() *shadowOutput {
+	if !f.calcTuning.ShadowOutput {
+		return nil
+	}
+	shadow := &shadowOutput{values: make(map[string]map[string]string)}
+	if !f.shadow.CompareAndSwap(nil, shadow) {
+		return nil
+	}
+	return shadow
+}
+
+// endShadowOutput publishes the computed values of a shadow recalculation
+// for GetComputedValue, merged into the values of the former recalculations.
+// The results of the recalculated cells are dropped from the calculation
+// cache, so CalcCellValue keeps calculating from the stored values.
+func (f *File) endShadowOutput(shadow *shadowOutput, graph *dependencyGraph) {
+	f.shadow.CompareAndSwap(shadow, nil)
+	computed := make(map[string]string)
+	if previous := f.computedValues.Load(); previous != nil {
+		for ref, value := range *previous {
+			computed[ref] = value
+		}
+	}
+	shadow.mu.RLock()
+	for sheet, cells := range shadow.values {
+		for cell, value := range cells {
+			computed[sheet+"!"+cell] = value
+		}
+	}
+	shadow.mu.RUnlock()
+	f.computedValues.Store(&computed)
+
+	recalculated := func(ref string) bool {
+		if _, ok := graph.nodes[ref]; ok {
+			return true
+		}
+		_, ok := computed[ref]
+		return ok
+	}
+	f.calcCache.Range(func(key, _ interface{}) bool {
+		if k, ok := key.(string); ok {
+			ref := k
+			if idx := strings.Index(k, "!subexpr:"); idx != -1 {
+				ref = k[:idx]
+			} else {
+				ref = strings.TrimSuffix(strings.TrimSuffix(k, "!raw=true"), "!raw=false")
+			}
+			if recalculated(ref) {
+				f.calcCache.Delete(key)
+			}
+		}
+		return true
+	})
+	f.logger().Debugf("  🔍 [Shadow Output] Kept %d computed values apart from the stored values", len(computed))
+}
+
+// mergeShadowIntoRows overlays the raw rows of a worksheet with the computed
+// values of the running shadow recalculation, the rows are returned as is
+// otherwise.
+func (f *File) mergeShadowIntoRows(sheet string, rows [][]string) [][]string {
+	shadow := f.shadow.Load()
+	if shadow == nil {
+		return rows
+	}
+	return mergeSheetCacheIntoRows(rows, shadow.sheetArgs(sheet))
+}
+
+// mergeShadowIntoOverlay returns the overlay of the streamed rows of a
+// worksheet with the computed values of the running shadow recalculation,
+// the values in overlay take precedence.
+func (f *File) mergeShadowIntoOverlay(sheet string, overlay map[string]formulaArg) map[string]formulaArg {
+	shadow := f.shadow.Load()
+	if shadow == nil {
+		return overlay
+	}
+	merged := shadow.sheetArgs(sheet)
+	for cell, arg := range overlay {
+		merged[cell] = arg
+	}
+	return merged
+}
+
+// shadowCellType returns the type of a cell at the given coordinates, or the
+// type of its computed value while a shadow recalculation is running, so the
+// batch patterns tell the text and logical values apart as if the value was
+// stored.
+func (f *File) shadowCellType(sheet string, col, row int, c *xlsxC) string {
+	shadow := f.shadow.Load()
+	if shadow == nil {
+		return c.T
+	}
+	cell, err := CoordinatesToCellName(col, row)
+	if err != nil {
+		return c.T
+	}
+	value, ok := shadow.value(sheet, cell)
+	if !ok {
+		return c.T
+	}
+	return inferXMLCellType(value)
+}
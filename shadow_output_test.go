@@ -0,0 +1,131 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShadowOutputKeepsStoredValues(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for cell, value := range map[string]interface{}{"A1": 1, "A2": "x"} {
+		if err := f.SetCellValue("Sheet1", cell, value); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+	}
+	for cell, formula := range map[string]string{"B1": "A1*2", "C1": "B1+1", "B2": `A2&"y"`} {
+		if err := f.SetCellFormula("Sheet1", cell, formula); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if _, ok := f.GetComputedValue("Sheet1", "B1"); ok {
+		t.Fatal("expected no computed value without shadow output")
+	}
+
+	// Change the inputs and recalculate in shadow mode
+	if err := f.SetCellValue("Sheet1", "A1", 10); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	if err := f.SetCellValue("Sheet1", "A2", "z"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	f.SetCalcTuning(CalcTuning{ShadowOutput: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for cell, want := range map[string][2]string{"B1": {"2", "20"}, "C1": {"3", "21"}, "B2": {"xy", "zy"}} {
+		if stored, _ := f.GetCellValue("Sheet1", cell); stored != want[0] {
+			t.Fatalf("%s: stored value changed to %q, want %q", cell, stored, want[0])
+		}
+		if computed, ok := f.GetComputedValue("Sheet1", cell); !ok || computed != want[1] {
+			t.Fatalf("%s: computed value %q (%t), want %q", cell, computed, ok, want[1])
+		}
+	}
+	if _, ok := f.GetComputedValue("Sheet1", "A1"); ok {
+		t.Fatal("expected no computed value for a constant cell")
+	}
+
+	// The stored values are saved to the file
+	path := filepath.Join(t.TempDir(), "shadow.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	saved, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer saved.Close()
+	if value, _ := saved.GetCellValue("Sheet1", "C1"); value != "3" {
+		t.Fatalf("saved C1: got %q, want %q", value, "3")
+	}
+}
+
+func TestShadowOutputIncrementalRecalculation(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 12; row++ {
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), row%3); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("A%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("E%d", row), fmt.Sprintf("SUMIF($A$1:$A$12,A%d,$B$1:$B$12)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	var calculated []string
+	f.OnCellCalculated = func(sheet, cell, oldValue, newValue string) {
+		calculated = append(calculated, sheet+"!"+cell)
+	}
+
+	// Change a key and recalculate the affected formulas in shadow mode
+	if err := f.SetCellValue("Sheet1", "A1", 2); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	f.SetCalcTuning(CalcTuning{ShadowOutput: true, AuditTrail: true})
+	if err := f.RecalculateAffectedByCells(map[string]bool{"Sheet1!A1": true}); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if len(calculated) > 0 {
+		t.Fatalf("expected no OnCellCalculated calls, got %v", calculated)
+	}
+	// The keys are 2,2,0,1,2,0,1,2,0,1,2,0 and the values are twice the keys
+	for cell, want := range map[string][2]string{"B1": {"2", "4"}, "E1": {"8", "20"}, "E2": {"16", "20"}, "E4": {"8", "6"}} {
+		if stored, _ := f.GetCellValue("Sheet1", cell); stored != want[0] {
+			t.Fatalf("%s: stored value changed to %q, want %q", cell, stored, want[0])
+		}
+		if computed, ok := f.GetComputedValue("Sheet1", cell); !ok || computed != want[1] {
+			t.Fatalf("%s: computed value %q (%t), want %q", cell, computed, ok, want[1])
+		}
+	}
+	batched := false
+	for _, entry := range f.CalcAuditTrail() {
+		batched = batched || entry.Optimizer == "SUMIFS"
+	}
+	if !batched {
+		t.Fatalf("expected the SUMIF formulas to be batched, got %v", f.CalcAuditTrail())
+	}
+	if _, ok := f.calcCache.Load("Sheet1!B1!raw=true"); ok {
+		t.Fatal("expected no computed value left in the calculation cache")
+	}
+	if value, err := f.CalcCellValue("Sheet1", "B1"); err != nil || value != "4" {
+		t.Fatalf("B1: got %q (%v), want %q", value, err, "4")
+	}
+}
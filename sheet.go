@@ -391,6 +391,7 @@ func (f *File) SetSheetName(source, target string) error {
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	wb, _ := f.workbookReader()
 	for k, v := range wb.Sheets.Sheet {
 		if v.Name == source {
@@ -689,6 +690,7 @@ func (f *File) DeleteSheet(sheet string) error {
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	wb, _ := f.workbookReader()
 	wbRels, _ := f.relsReader(f.getWorkbookRelsPath())
 	activeSheetName := f.GetSheetName(f.GetActiveSheetIndex())
@@ -974,6 +976,7 @@ func (f *File) copySheet(from, to int) error {
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	worksheet := f.deepCopyWorksheet(sheet)
 	toSheetID := strconv.Itoa(f.getSheetID(f.GetSheetName(to)))
 	sheetXMLPath := "xl/worksheets/sheet" + toSheetID + ".xml"
@@ -1978,6 +1981,7 @@ func (f *File) SetDefinedName(definedName *DefinedName) error {
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	d := xlsxDefinedName{
 		Name:    definedName.Name,
 		Comment: definedName.Comment,
@@ -2022,6 +2026,7 @@ func (f *File) DeleteDefinedName(definedName *DefinedName) error {
 	}
 	f.calcCache.Clear()
 	f.rangeCache.Clear()
+	f.invalidateDependencyGraph()
 	if wb.DefinedNames != nil {
 		for idx, dn := range wb.DefinedNames.DefinedName {
 			scope := "Workbook"
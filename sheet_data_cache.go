@@ -10,12 +10,14 @@ import (
 type SheetDataCache struct {
 	mu    sync.RWMutex
 	cache map[string][][]string // sheet name -> rows data
+	gen   map[string]uint64     // sheet name -> invalidation generation
 }
 
 // NewSheetDataCache creates a new sheet data cache
 func NewSheetDataCache() *SheetDataCache {
 	return &SheetDataCache{
 		cache: make(map[string][][]string),
+		gen:   make(map[string]uint64),
 	}
 }
 
@@ -27,10 +29,11 @@ func (c *SheetDataCache) GetRows(f *File, sheet string) ([][]string, error) {
 		c.mu.RUnlock()
 		return rows, nil
 	}
+	gen := c.gen[sheet]
 	c.mu.RUnlock()
 
 	// Read from file (no lock during I/O)
-	rows, err := f.GetRows(sheet, Options{RawCellValue: true})
+	rows, err := f.getUsedRows(sheet, Options{RawCellValue: true})
 	if err != nil {
 		return nil, err
 	}
@@ -42,17 +45,37 @@ func (c *SheetDataCache) GetRows(f *File, sheet string) ([][]string, error) {
 		c.mu.Unlock()
 		return existing, nil
 	}
-	c.cache[sheet] = rows
+	// Skip caching if the sheet was written while it was being read
+	if c.gen[sheet] == gen {
+		c.cache[sheet] = rows
+	}
 	c.mu.Unlock()
 
 	return rows, nil
 }
 
+// Has reports whether the rows of a sheet are cached
+func (c *SheetDataCache) Has(sheet string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.cache[sheet]
+	return ok
+}
+
+// Invalidate drops the cached rows of a sheet after it has been written
+func (c *SheetDataCache) Invalidate(sheet string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.cache, sheet)
+	c.gen[sheet]++
+}
+
 // Clear clears the cache
 func (c *SheetDataCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.cache = make(map[string][][]string)
+	c.gen = make(map[string]uint64)
 }
 
 // Len returns the number of cached sheets
@@ -61,3 +84,79 @@ func (c *SheetDataCache) Len() int {
 	defer c.mu.RUnlock()
 	return len(c.cache)
 }
+
+// getCachedRawRows returns the raw cell values of the used rows of a worksheet
+// like getUsedRows(sheet, Options{RawCellValue: true}). While a dependency
+// recalculation is running the decoded rows are shared by all batch patterns
+// through the recalculation's SheetDataCache, so callers must treat the
+// returned rows as read-only.
+func (f *File) getCachedRawRows(sheet string) ([][]string, error) {
+	if c := f.sheetDataCache.Load(); c != nil {
+		return c.GetRows(f, sheet)
+	}
+	return f.getUsedRows(sheet, Options{RawCellValue: true})
+}
+
+// getUsedRows returns the rows of a worksheet like GetRows, without the
+// trailing rows whose cells are all empty. GetRows returns the rows of the
+// formula cells even if their values are empty, so a sheet with the formulas
+// filled down to a bloated dimension would inflate the work of the batch
+// scanners. The rows are overlaid by the computed values of a running shadow
+// recalculation.
+func (f *File) getUsedRows(sheet string, opts ...Options) ([][]string, error) {
+	rows, err := f.GetRows(sheet, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return f.mergeShadowIntoRows(sheet, trimEmptyTailRows(rows)), nil
+}
+
+// trimEmptyTailRows trims the trailing rows whose cells are all empty.
+func trimEmptyTailRows(rows [][]string) [][]string {
+	used := len(rows)
+	for ; used > 0; used-- {
+		empty := true
+		for _, value := range rows[used-1] {
+			if value != "" {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			break
+		}
+	}
+	return rows[:used]
+}
+
+// mergeSheetCacheIntoRows overlays calculated formula results from the
+// worksheet cache onto raw rows. The input rows are not modified: the outer
+// slice and every touched row are copied before writing.
+func mergeSheetCacheIntoRows(rows [][]string, sheetData map[string]formulaArg) [][]string {
+	if len(sheetData) == 0 {
+		return rows
+	}
+	merged := make([][]string, len(rows))
+	copy(merged, rows)
+	copied := make(map[int]bool)
+	for cellRef, argValue := range sheetData {
+		col, row, err := CellNameToCoordinates(cellRef)
+		if err != nil {
+			continue
+		}
+		// Ensure rows array is large enough
+		for len(merged) < row {
+			merged = append(merged, make([]string, 0))
+		}
+		if !copied[row-1] {
+			merged[row-1] = append(make([]string, 0, max(len(merged[row-1]), col)), merged[row-1]...)
+			copied[row-1] = true
+		}
+		// Ensure row is large enough
+		for len(merged[row-1]) < col {
+			merged[row-1] = append(merged[row-1], "")
+		}
+		merged[row-1][col-1] = argValue.Value()
+	}
+	return merged
+}
@@ -0,0 +1,183 @@
+package excelize
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestSheetDataCacheInvalidate(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", "old"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+
+	c := NewSheetDataCache()
+	rows, err := c.GetRows(f, "Sheet1")
+	if err != nil {
+		t.Fatalf("GetRows failed: %v", err)
+	}
+	again, _ := c.GetRows(f, "Sheet1")
+	if &rows[0] != &again[0] {
+		t.Fatalf("expected second read to reuse the cached rows")
+	}
+
+	if err := f.SetCellValue("Sheet1", "A1", "new"); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+	c.Invalidate("Sheet1")
+	if c.Len() != 0 {
+		t.Fatalf("expected invalidated sheet to be dropped, got %d cached sheets", c.Len())
+	}
+	rows, _ = c.GetRows(f, "Sheet1")
+	if rows[0][0] != "new" {
+		t.Fatalf("expected fresh value after invalidation, got %q", rows[0][0])
+	}
+}
+
+func TestGetCachedRawRowsDuringRecalc(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if err := f.SetCellValue("Sheet1", "A1", 1); err != nil {
+		t.Fatalf("set value: %v", err)
+	}
+
+	// Outside a recalculation every call decodes the sheet again
+	first, _ := f.getCachedRawRows("Sheet1")
+	second, _ := f.getCachedRawRows("Sheet1")
+	if &first[0] == &second[0] {
+		t.Fatalf("expected no sharing outside a recalculation")
+	}
+
+	f.sheetDataCache.Store(NewSheetDataCache())
+	defer f.sheetDataCache.Store(nil)
+	first, _ = f.getCachedRawRows("Sheet1")
+	second, _ = f.getCachedRawRows("Sheet1")
+	if &first[0] != &second[0] {
+		t.Fatalf("expected rows to be shared during a recalculation")
+	}
+
+	// A formula result write invalidates the sheet
+	f.setFormulaValue("Sheet1", "B1", "2")
+	third, _ := f.getCachedRawRows("Sheet1")
+	if &first[0] == &third[0] || len(third[0]) != 2 || third[0][1] != "2" {
+		t.Fatalf("expected fresh rows after write, got %v", third)
+	}
+}
+
+func TestMergeSheetCacheIntoRows(t *testing.T) {
+	rows := [][]string{{"a", "b"}, {"c"}}
+	merged := mergeSheetCacheIntoRows(rows, map[string]formulaArg{
+		"B2": newStringFormulaArg("x"),
+		"A3": newNumberFormulaArg(3),
+	})
+	if rows[1][0] != "c" || len(rows[1]) != 1 || len(rows) != 2 {
+		t.Fatalf("input rows must not be modified: %v", rows)
+	}
+	if len(merged) != 3 || merged[1][1] != "x" || merged[2][0] != "3" || merged[0][1] != "b" {
+		t.Fatalf("unexpected merged rows: %v", merged)
+	}
+}
+
+func BenchmarkRecalcMultiPatternSharedSource(b *testing.B) {
+	f := NewFile()
+	defer f.Close()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := f.NewSheet("Data"); err != nil {
+		b.Fatalf("create sheet: %v", err)
+	}
+	for r := 1; r <= 20000; r++ {
+		row := []interface{}{fmt.Sprintf("SKU%d", r%100), fmt.Sprintf("Region%d", r%4), r, r * 2, fmt.Sprintf("K%d", r)}
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", r), &row); err != nil {
+			b.Fatalf("set row: %v", err)
+		}
+	}
+	// Several independent patterns over the same source sheet
+	for r := 1; r <= 50; r++ {
+		cells := []interface{}{
+			fmt.Sprintf("SKU%d", r), "Region1", fmt.Sprintf("K%d", r),
+			benchFormula(fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,$A%d,Data!$B:$B,$B%d)+0", r, r)),
+			benchFormula(fmt.Sprintf("SUMIFS(Data!$D:$D,Data!$A:$A,$A%d,Data!$B:$B,$B%d)+0", r, r)),
+			benchFormula(fmt.Sprintf("INDEX(Data!$C:$C,MATCH($C%d,Data!$E:$E,0))", r)),
+			benchFormula(fmt.Sprintf("INDEX(Data!$D:$D,MATCH($C%d,Data!$E:$E,0))", r)),
+		}
+		for i, v := range cells {
+			cell, _ := CoordinatesToCellName(i+1, r)
+			if fc, ok := v.(benchFormula); ok {
+				if err := f.SetCellFormula("Sheet1", cell, string(fc)); err != nil {
+					b.Fatalf("set formula: %v", err)
+				}
+				continue
+			}
+			if err := f.SetCellValue("Sheet1", cell, v); err != nil {
+				b.Fatalf("set value: %v", err)
+			}
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := f.RecalculateAllWithDependency(); err != nil {
+			b.Fatalf("recalc failed: %v", err)
+		}
+	}
+}
+
+// benchFormula marks a benchmark cell value as a formula
+type benchFormula string
+
+func TestGetUsedRows(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	for row := 1; row <= 10; row++ {
+		if err := f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("k%d", row), row}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+	}
+	// 公式填充到第 1000 行，值为空
+	for row := 11; row <= 1000; row++ {
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf(`IF(A%d="","",A%d)`, row, row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetSheetDimension("Sheet1", "A1:C1000"); err != nil {
+		t.Fatalf("set dimension: %v", err)
+	}
+	if rows, _ := f.GetRows("Sheet1", Options{RawCellValue: true}); len(rows) != 1000 {
+		t.Fatalf("expected GetRows to return the 1000 rows of the dimension, got %d", len(rows))
+	}
+
+	rows, err := f.getUsedRows("Sheet1", Options{RawCellValue: true})
+	if err != nil {
+		t.Fatalf("get used rows: %v", err)
+	}
+	if len(rows) != 10 || rows[9][0] != "k10" {
+		t.Fatalf("expected 10 used rows, got %d", len(rows))
+	}
+	if rows, _ := f.getCachedRawRows("Sheet1"); len(rows) != 10 {
+		t.Fatalf("expected the scanners to get 10 used rows, got %d", len(rows))
+	}
+	f.SetCalcTuning(CalcTuning{StreamRowsThreshold: 1})
+	stream, err := f.streamRawRows("Sheet1", [2]int{1, TotalRows}, []int{1, 2}, 0, nil)
+	if err != nil {
+		t.Fatalf("stream rows: %v", err)
+	}
+	streamed := 0
+	for chunk := range stream {
+		streamed += len(chunk)
+	}
+	if streamed != 10 {
+		t.Fatalf("expected 10 streamed rows, got %d", streamed)
+	}
+	if _, err := f.getUsedRows("Missing"); err == nil {
+		t.Fatalf("expected an error reading a missing sheet")
+	}
+	if got := trimEmptyTailRows([][]string{{""}, {"a"}, {"", ""}, nil}); len(got) != 2 {
+		t.Fatalf("unexpected trimmed rows %q", got)
+	}
+}
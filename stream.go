@@ -717,6 +717,7 @@ func (sw *StreamWriter) Flush() error {
 	sw.file.Sheet.Delete(sheetPath)
 	sw.file.checked.Delete(sheetPath)
 	sw.file.Pkg.Delete(sheetPath)
+	sw.file.invalidateDependencyGraph()
 
 	return nil
 }
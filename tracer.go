@@ -0,0 +1,96 @@
+package excelize
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer is the interface of the tracer of the dependency-aware batch
+// calculation engine, which maps onto an OpenTelemetry tracer. StartSpan
+// starts a span as a child of the span in ctx, if any, and returns a context
+// holding the new span, the engine ends each span it starts. The spans and
+// their attributes are:
+//
+//   - "excelize.build_dependency_graph": the dependency analysis, "formulas"
+//     and "levels" of the graph;
+//   - "excelize.calculate": the calculation of a dependency graph, "formulas"
+//     and "levels";
+//   - "excelize.level": a level of the calculation, "level" and "formulas";
+//   - "excelize.level.precalc", "excelize.level.batch" and
+//     "excelize.level.dag": the phases of a level, the "formulas" calculated
+//     one by one before the batch optimization, recognized by the batch
+//     patterns, and scheduled by the DAG scheduler;
+//   - "excelize.batch.<pattern>": the scan of a batch pattern in the batch
+//     phase, like "excelize.batch.sumifs" or "excelize.batch.vlookup", the
+//     "formulas" of the pattern.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value int)
+	End()
+}
+
+// nopTracer is the default tracer which starts no spans.
+type nopTracer struct{}
+
+func (nopTracer) StartSpan(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+// nopSpan is the span of nopTracer.
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, int) {}
+
+func (nopSpan) End() {}
+
+// SetTracer sets the tracer of the calculation engine, no spans are started
+// by default or if tracer is nil. It should not be called while a
+// recalculation is running. For example, adapt an OpenTelemetry tracer:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, excelize.Span) {
+//	    ctx, span := t.Start(ctx, name)
+//	    return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value int) {
+//	    s.SetAttributes(attribute.Int(key, value))
+//	}
+//
+//	func (s otelSpan) End() { s.Span.End() }
+//
+//	f.SetTracer(otelTracer{otel.Tracer("excelize")})
+func (f *File) SetTracer(tracer Tracer) {
+	f.calcTracer = tracer
+}
+
+// tracer returns the tracer of the calculation engine.
+func (f *File) tracer() Tracer {
+	if f.calcTracer == nil {
+		return nopTracer{}
+	}
+	return f.calcTracer
+}
+
+// tracedBatchTask wraps a batch pattern task of a level in a
+// "excelize.batch.<pattern>" span, and adds its time to the profile of the
+// recalculation with CalcTuning.Profile.
+func (f *File) tracedBatchTask(ctx context.Context, pattern string, formulas int, task func()) func() {
+	return func() {
+		_, span := f.tracer().StartSpan(ctx, "excelize.batch."+pattern)
+		defer span.End()
+		span.SetAttribute("formulas", formulas)
+		start := time.Now()
+		task()
+		if profile := calcProfileFromContext(ctx); profile != nil {
+			profile.addPattern(pattern, formulas, time.Since(start))
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package excelize
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// recordingTracer records the started spans
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+// recordingSpan is a span started by recordingTracer
+type recordingSpan struct {
+	tracer     *recordingTracer
+	name       string
+	parent     *recordingSpan
+	attributes map[string]int
+	ended      int
+}
+
+type recordingSpanKey struct{}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parent, _ := ctx.Value(recordingSpanKey{}).(*recordingSpan)
+	span := &recordingSpan{tracer: t, name: name, parent: parent, attributes: make(map[string]int)}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+func (s *recordingSpan) SetAttribute(key string, value int) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *recordingSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.ended++
+}
+
+// named returns the recorded spans with the name.
+func (t *recordingTracer) named(name string) []*recordingSpan {
+	var spans []*recordingSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestSetTracer(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 12; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("k%d", row), row * 10}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("k%d", row)); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("VLOOKUP(A%d,Data!$A:$B,2,FALSE)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	if err := f.SetCellFormula("Sheet1", "C1", "SUM(B1:B12)"); err != nil {
+		t.Fatalf("set formula: %v", err)
+	}
+
+	// 默认不启动任何 span
+	if _, ok := f.tracer().(nopTracer); !ok {
+		t.Fatalf("expected the no-op tracer by default, got %T", f.tracer())
+	}
+	tracer := &recordingTracer{}
+	f.SetTracer(tracer)
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if value, _ := f.GetCellValue("Sheet1", "C1"); value != "780" {
+		t.Fatalf("unexpected C1 value %q, want 780", value)
+	}
+
+	for _, span := range tracer.spans {
+		if span.ended != 1 {
+			t.Fatalf("span %s ended %d times", span.name, span.ended)
+		}
+	}
+	graphSpans, calcSpans := tracer.named("excelize.build_dependency_graph"), tracer.named("excelize.calculate")
+	if len(graphSpans) != 1 || graphSpans[0].attributes["formulas"] != 13 || graphSpans[0].attributes["levels"] != 2 {
+		t.Fatalf("unexpected dependency graph spans %+v", graphSpans)
+	}
+	if len(calcSpans) != 1 || calcSpans[0].attributes["formulas"] != 13 {
+		t.Fatalf("unexpected calculation spans %+v", calcSpans)
+	}
+	levelSpans := tracer.named("excelize.level")
+	if len(levelSpans) != 2 {
+		t.Fatalf("expected 2 level spans, got %d", len(levelSpans))
+	}
+	for i, span := range levelSpans {
+		if span.parent != calcSpans[0] || span.attributes["level"] != i {
+			t.Fatalf("unexpected level span %+v", span)
+		}
+	}
+	for _, name := range []string{"excelize.level.precalc", "excelize.level.batch", "excelize.level.dag"} {
+		spans := tracer.named(name)
+		if len(spans) != 2 {
+			t.Fatalf("expected 2 %s spans, got %d", name, len(spans))
+		}
+		for i, span := range spans {
+			if span.parent != levelSpans[i] {
+				t.Fatalf("expected %s span under level %d", name, i)
+			}
+		}
+	}
+	if batch := tracer.named("excelize.level.batch")[0]; batch.attributes["formulas"] != 12 {
+		t.Fatalf("unexpected batch formulas %d, want 12", batch.attributes["formulas"])
+	}
+	vlookupSpans := tracer.named("excelize.batch.vlookup")
+	if len(vlookupSpans) != 1 || vlookupSpans[0].attributes["formulas"] != 12 ||
+		vlookupSpans[0].parent != tracer.named("excelize.level.batch")[0] {
+		t.Fatalf("unexpected VLOOKUP pattern spans %+v", vlookupSpans)
+	}
+
+	// 清除 tracer 后不再启动 span
+	f.SetTracer(nil)
+	count := len(tracer.spans)
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	if len(tracer.spans) != count {
+		t.Fatalf("expected no spans after removing the tracer")
+	}
+}
@@ -3,14 +3,24 @@ package excelize
 import (
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 // WorksheetCache 统一的工作表缓存，按 sheet 组织
 // 用于存储所有单元格的值（包括原始值和计算结果）
 // Phase 1 重构：改为存储 formulaArg 以保留类型信息
+// 设置 backend 时单元格的值编码后存储在 backend 中，不使用 cache
+// 设置 maxBytes 时按列淘汰最久未使用的单元格，使 cache 的近似大小不超过 maxBytes
 type WorksheetCache struct {
-	mu    sync.RWMutex
-	cache map[string]map[string]formulaArg // map[sheetName]map[cellRef]formulaArg
+	mu        sync.RWMutex
+	cache     map[string]map[string]formulaArg // map[sheetName]map[cellRef]formulaArg
+	backend   WorksheetCacheBackend
+	size      int64 // cache 中单元格的近似字节数
+	maxBytes  int64
+	ranges    map[worksheetCacheRangeKey]*worksheetCacheRange // 仅在设置 maxBytes 时记录
+	tick      atomic.Int64
+	evictions int
+	spills    sync.Map // "Sheet!Cell" -> 本次重算中锚点公式返回的动态数组
 }
 
 // NewWorksheetCache 创建新的工作表缓存
@@ -23,11 +33,21 @@ func NewWorksheetCache() *WorksheetCache {
 // Get 获取单元格的值
 // 返回 formulaArg 和是否存在的标志
 func (wc *WorksheetCache) Get(sheet, cell string) (formulaArg, bool) {
+	if wc.backend != nil {
+		data, ok := wc.backend.Load(sheet, cell)
+		if !ok {
+			return newEmptyFormulaArg(), false
+		}
+		return decodeWorksheetCacheValue(data), true
+	}
 	wc.mu.RLock()
 	defer wc.mu.RUnlock()
 
 	if sheetCache, ok := wc.cache[sheet]; ok {
 		value, exists := sheetCache[cell]
+		if exists && wc.ranges != nil {
+			wc.touch(sheet, cell)
+		}
 		return value, exists
 	}
 	return newEmptyFormulaArg(), false
@@ -35,23 +55,49 @@ func (wc *WorksheetCache) Get(sheet, cell string) (formulaArg, bool) {
 
 // Set 设置单元格的值
 func (wc *WorksheetCache) Set(sheet, cell string, value formulaArg) {
+	if wc.backend != nil {
+		wc.backend.Store(sheet, cell, encodeWorksheetCacheValue(value))
+		return
+	}
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
 
 	if _, ok := wc.cache[sheet]; !ok {
 		wc.cache[sheet] = make(map[string]formulaArg)
 	}
+	delta := worksheetCacheValueSize(cell, value)
+	if old, ok := wc.cache[sheet][cell]; ok {
+		delta -= worksheetCacheValueSize(cell, old)
+	}
 	wc.cache[sheet][cell] = value
+	wc.size += delta
+	if wc.ranges != nil {
+		wc.track(sheet, cell, delta)
+	}
 }
 
 // GetSheet 获取整个 sheet 的数据（用于批量操作）
-// 返回 map[cellRef]formulaArg
+// 返回 map[cellRef]formulaArg 的快照：在读锁下复制，之后的 Set 不会改变它，
+// 同一层级并发执行的批量任务可以在其他任务写入时安全地遍历和修改快照
 func (wc *WorksheetCache) GetSheet(sheet string) map[string]formulaArg {
+	if wc.backend != nil {
+		result := make(map[string]formulaArg, wc.backend.Len(sheet))
+		wc.backend.Range(sheet, func(cell string, value []byte) bool {
+			result[cell] = decodeWorksheetCacheValue(value)
+			return true
+		})
+		return result
+	}
 	wc.mu.RLock()
 	defer wc.mu.RUnlock()
 
+	for key, r := range wc.ranges {
+		if key.sheet == sheet {
+			r.lastUse.Store(wc.tick.Add(1))
+		}
+	}
 	if sheetCache, ok := wc.cache[sheet]; ok {
-		// 返回副本，避免并发修改
+		// 返回副本，调用方遍历时不持有锁
 		result := make(map[string]formulaArg, len(sheetCache))
 		for k, v := range sheetCache {
 			result[k] = v
@@ -63,11 +109,19 @@ func (wc *WorksheetCache) GetSheet(sheet string) map[string]formulaArg {
 
 // GetCacheStats 返回缓存统计信息（用于调试）
 func (wc *WorksheetCache) GetCacheStats() map[string]int {
+	stats := make(map[string]int)
+	total := 0
+	if wc.backend != nil {
+		for _, sheet := range wc.backend.Sheets() {
+			stats[sheet] = wc.backend.Len(sheet)
+			total += stats[sheet]
+		}
+		stats["_total"] = total
+		return stats
+	}
 	wc.mu.RLock()
 	defer wc.mu.RUnlock()
 
-	stats := make(map[string]int)
-	total := 0
 	for sheet, sheetCache := range wc.cache {
 		count := len(sheetCache)
 		stats[sheet] = count
@@ -110,11 +164,13 @@ func inferCellValueType(val string, cellType CellType) formulaArg {
 // Phase 1 改进：读取时立即转换为 formulaArg，保留类型信息
 func (wc *WorksheetCache) LoadSheet(f *File, sheet string) error {
 	// 先确保 map 初始化
-	wc.mu.Lock()
-	if _, ok := wc.cache[sheet]; !ok {
-		wc.cache[sheet] = make(map[string]formulaArg)
+	if wc.backend == nil {
+		wc.mu.Lock()
+		if _, ok := wc.cache[sheet]; !ok {
+			wc.cache[sheet] = make(map[string]formulaArg)
+		}
+		wc.mu.Unlock()
 	}
-	wc.mu.Unlock()
 
 	ws, err := f.workSheetReader(sheet)
 	if err != nil || ws == nil || ws.SheetData.Row == nil {
@@ -151,20 +207,47 @@ func (wc *WorksheetCache) LoadSheet(f *File, sheet string) error {
 
 // Clear 清空缓存
 func (wc *WorksheetCache) Clear() {
+	if wc.backend != nil {
+		wc.backend.Clear()
+		return
+	}
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
 	wc.cache = make(map[string]map[string]formulaArg)
+	wc.size = 0
+	if wc.ranges != nil {
+		wc.ranges = make(map[worksheetCacheRangeKey]*worksheetCacheRange)
+	}
 }
 
 // ClearSheet 清空指定 sheet 的缓存
 func (wc *WorksheetCache) ClearSheet(sheet string) {
+	if wc.backend != nil {
+		wc.backend.DeleteSheet(sheet)
+		return
+	}
 	wc.mu.Lock()
 	defer wc.mu.Unlock()
+	for cell, value := range wc.cache[sheet] {
+		wc.size -= worksheetCacheValueSize(cell, value)
+	}
 	delete(wc.cache, sheet)
+	for key := range wc.ranges {
+		if key.sheet == sheet {
+			delete(wc.ranges, key)
+		}
+	}
 }
 
 // Len 返回总的缓存单元格数量
 func (wc *WorksheetCache) Len() int {
+	if wc.backend != nil {
+		total := 0
+		for _, sheet := range wc.backend.Sheets() {
+			total += wc.backend.Len(sheet)
+		}
+		return total
+	}
 	wc.mu.RLock()
 	defer wc.mu.RUnlock()
 
@@ -177,6 +260,9 @@ func (wc *WorksheetCache) Len() int {
 
 // SheetLen 返回指定 sheet 的缓存单元格数量
 func (wc *WorksheetCache) SheetLen(sheet string) int {
+	if wc.backend != nil {
+		return wc.backend.Len(sheet)
+	}
 	wc.mu.RLock()
 	defer wc.mu.RUnlock()
 
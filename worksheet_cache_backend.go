@@ -0,0 +1,247 @@
+package excelize
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// WorksheetCacheBackend is the storage of the cell values of the worksheet
+// cache shared by the levels of a dependency based recalculation. The values
+// are kept in memory by default, a backend lets them spill to another store,
+// such as a temporary file, for workbooks whose source data exceeds the
+// memory. The values are opaque encoded bytes. A backend must be safe for
+// concurrent use, it's closed when the recalculation completes.
+type WorksheetCacheBackend interface {
+	// Load returns the value of the cell and whether it exists.
+	Load(sheet, cell string) ([]byte, bool)
+	// Store sets the value of the cell, the backend shouldn't keep value.
+	Store(sheet, cell string, value []byte)
+	// Range calls fn for each cell of the sheet until fn returns false.
+	Range(sheet string, fn func(cell string, value []byte) bool)
+	// Sheets returns the names of the sheets which have cells.
+	Sheets() []string
+	// Len returns the number of cells of the sheet.
+	Len(sheet string) int
+	// DeleteSheet removes the cells of the sheet.
+	DeleteSheet(sheet string)
+	// Clear removes all cells.
+	Clear()
+	// Close releases the resources of the backend, and returns the first
+	// error the backend failed to store a value with, if any.
+	Close() error
+}
+
+// NewWorksheetCacheWithBackend creates a worksheet cache which stores the cell
+// values in the given backend.
+func NewWorksheetCacheWithBackend(backend WorksheetCacheBackend) *WorksheetCache {
+	wc := NewWorksheetCache()
+	wc.backend = backend
+	return wc
+}
+
+// Close closes the backend of the worksheet cache, if any.
+func (wc *WorksheetCache) Close() error {
+	if wc.backend == nil {
+		return nil
+	}
+	return wc.backend.Close()
+}
+
+// encodeWorksheetCacheValue encodes a cell value of the worksheet cache for a
+// backend: a type byte followed by the number or the string.
+func encodeWorksheetCacheValue(arg formulaArg) []byte {
+	switch arg.Type {
+	case ArgNumber:
+		buf := make([]byte, 9)
+		if buf[0] = 'n'; arg.Boolean {
+			buf[0] = 'b'
+		}
+		binary.LittleEndian.PutUint64(buf[1:], math.Float64bits(arg.Number))
+		return buf
+	case ArgError:
+		return append(append(append([]byte{'e'}, arg.String...), 0), arg.Error...)
+	case ArgEmpty:
+		return []byte{'z'}
+	}
+	return append([]byte{'s'}, arg.Value()...)
+}
+
+// decodeWorksheetCacheValue decodes a cell value encoded by
+// encodeWorksheetCacheValue.
+func decodeWorksheetCacheValue(data []byte) formulaArg {
+	if len(data) == 0 {
+		return newEmptyFormulaArg()
+	}
+	switch data[0] {
+	case 'n', 'b':
+		if len(data) == 9 {
+			return formulaArg{Type: ArgNumber, Number: math.Float64frombits(binary.LittleEndian.Uint64(data[1:])), Boolean: data[0] == 'b'}
+		}
+	case 'e':
+		value, msg, _ := strings.Cut(string(data[1:]), "\x00")
+		return newErrorFormulaArg(value, msg)
+	case 's':
+		return newStringFormulaArg(string(data[1:]))
+	}
+	return newEmptyFormulaArg()
+}
+
+// diskWorksheetCacheFlushSize is the size of the values buffered in memory
+// before they are written to the file
+const diskWorksheetCacheFlushSize = 1 << 20
+
+// diskWorksheetCacheEntry is the location of a value in the file or buffer
+type diskWorksheetCacheEntry struct {
+	offset int64
+	size   int
+}
+
+// diskWorksheetCacheBackend stores the values in an append-only temporary
+// file, only the index of the cells is kept in memory.
+type diskWorksheetCacheBackend struct {
+	mu      sync.RWMutex
+	file    *os.File
+	flushed int64  // 已写入文件的字节数
+	pending []byte // 尚未写入文件的值，偏移从 flushed 开始
+	index   map[string]map[string]diskWorksheetCacheEntry
+	err     error // 第一个写入错误
+}
+
+// NewDiskWorksheetCacheBackend creates a worksheet cache backend which stores
+// the values in a temporary file in the directory dir, or the default
+// directory for temporary files if dir is empty. The file is removed when the
+// backend is closed. For example, let the cache of the recalculations spill to
+// disk:
+//
+//	f.SetCalcTuning(excelize.CalcTuning{
+//	    NewWorksheetCacheBackend: func() (excelize.WorksheetCacheBackend, error) {
+//	        return excelize.NewDiskWorksheetCacheBackend("")
+//	    },
+//	})
+func NewDiskWorksheetCacheBackend(dir string) (WorksheetCacheBackend, error) {
+	file, err := os.CreateTemp(dir, "excelize-worksheet-cache-*")
+	if err != nil {
+		return nil, err
+	}
+	return &diskWorksheetCacheBackend{
+		file:  file,
+		index: make(map[string]map[string]diskWorksheetCacheEntry),
+	}, nil
+}
+
+// Load returns the value of the cell.
+func (b *diskWorksheetCacheBackend) Load(sheet, cell string) ([]byte, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	entry, ok := b.index[sheet][cell]
+	if !ok {
+		return nil, false
+	}
+	return b.read(entry)
+}
+
+// read reads the value of an entry from the buffer or the file, the caller
+// must hold the lock.
+func (b *diskWorksheetCacheBackend) read(entry diskWorksheetCacheEntry) ([]byte, bool) {
+	value := make([]byte, entry.size)
+	if entry.offset >= b.flushed {
+		copy(value, b.pending[entry.offset-b.flushed:])
+		return value, true
+	}
+	if _, err := b.file.ReadAt(value, entry.offset); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Store appends the value of the cell, the previous value is left unused in
+// the file.
+func (b *diskWorksheetCacheBackend) Store(sheet, cell string, value []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.index[sheet] == nil {
+		b.index[sheet] = make(map[string]diskWorksheetCacheEntry)
+	}
+	b.index[sheet][cell] = diskWorksheetCacheEntry{offset: b.flushed + int64(len(b.pending)), size: len(value)}
+	if b.pending = append(b.pending, value...); len(b.pending) >= diskWorksheetCacheFlushSize {
+		b.flush()
+	}
+}
+
+// flush writes the buffered values to the file, the caller must hold the
+// lock.
+func (b *diskWorksheetCacheBackend) flush() {
+	if len(b.pending) == 0 || b.err != nil {
+		return
+	}
+	if _, err := b.file.WriteAt(b.pending, b.flushed); err != nil {
+		b.err = err
+		return
+	}
+	b.flushed += int64(len(b.pending))
+	b.pending = b.pending[:0]
+}
+
+// Range calls fn for each cell of the sheet.
+func (b *diskWorksheetCacheBackend) Range(sheet string, fn func(cell string, value []byte) bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for cell, entry := range b.index[sheet] {
+		if value, ok := b.read(entry); ok && !fn(cell, value) {
+			return
+		}
+	}
+}
+
+// Sheets returns the names of the sheets which have cells.
+func (b *diskWorksheetCacheBackend) Sheets() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	sheets := make([]string, 0, len(b.index))
+	for sheet := range b.index {
+		sheets = append(sheets, sheet)
+	}
+	return sheets
+}
+
+// Len returns the number of cells of the sheet.
+func (b *diskWorksheetCacheBackend) Len(sheet string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.index[sheet])
+}
+
+// DeleteSheet removes the cells of the sheet from the index.
+func (b *diskWorksheetCacheBackend) DeleteSheet(sheet string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.index, sheet)
+}
+
+// Clear removes all cells and truncates the file.
+func (b *diskWorksheetCacheBackend) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.index = make(map[string]map[string]diskWorksheetCacheEntry)
+	b.pending, b.flushed = b.pending[:0], 0
+	if err := b.file.Truncate(0); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// Close closes and removes the file.
+func (b *diskWorksheetCacheBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.err
+	if closeErr := b.file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(b.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package excelize
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// worksheetCacheEntryOverhead is the approximate size of a cell of the
+// in-memory worksheet cache besides its strings: the value and the map entry
+const worksheetCacheEntryOverhead = int64(unsafe.Sizeof(formulaArg{})) + 48
+
+// worksheetCacheRangeKey identifies a column of a sheet, the unit evicted from
+// a worksheet cache with a byte budget
+type worksheetCacheRangeKey struct {
+	sheet  string
+	column string
+}
+
+// worksheetCacheRange is the cells of a column of a sheet in the cache
+type worksheetCacheRange struct {
+	cells   map[string]struct{}
+	bytes   int64
+	lastUse atomic.Int64 // 最近一次读写的序号，越小越久未使用
+}
+
+// NewWorksheetCacheWithMaxBytes creates a worksheet cache which keeps the
+// approximate size of the cell values in memory within maxBytes by evicting
+// the least recently used columns of the sheets. The evicted cells are read
+// from the worksheets again on demand, like PreloadColumnRange does for the
+// cells missing from the cache. A maxBytes of 0 or less doesn't bound the
+// cache.
+func NewWorksheetCacheWithMaxBytes(maxBytes int64) *WorksheetCache {
+	wc := NewWorksheetCache()
+	if maxBytes > 0 {
+		wc.maxBytes = maxBytes
+		wc.ranges = make(map[worksheetCacheRangeKey]*worksheetCacheRange)
+	}
+	return wc
+}
+
+// Size returns the approximate size in bytes of the cell values kept in
+// memory, the values stored in a backend are not counted.
+func (wc *WorksheetCache) Size() int64 {
+	wc.mu.RLock()
+	defer wc.mu.RUnlock()
+	return wc.size
+}
+
+// worksheetCacheValueSize returns the approximate size of a cell of the cache
+func worksheetCacheValueSize(cell string, value formulaArg) int64 {
+	return worksheetCacheEntryOverhead + int64(len(cell)+len(value.String)+len(value.Error))
+}
+
+// worksheetCacheColumn returns the column letters of a cell reference
+func worksheetCacheColumn(cell string) string {
+	start := 0
+	if start < len(cell) && cell[start] == '$' {
+		start++
+	}
+	end := start
+	for end < len(cell) && (cell[end] >= 'A' && cell[end] <= 'Z' || cell[end] >= 'a' && cell[end] <= 'z') {
+		end++
+	}
+	return cell[start:end]
+}
+
+// touch marks the column of a cached cell as recently used, the caller must
+// hold the read or write lock.
+func (wc *WorksheetCache) touch(sheet, cell string) {
+	if r := wc.ranges[worksheetCacheRangeKey{sheet: sheet, column: worksheetCacheColumn(cell)}]; r != nil {
+		r.lastUse.Store(wc.tick.Add(1))
+	}
+}
+
+// track accounts the change of the size of a cell to its column and evicts
+// the least recently used columns if the budget is exceeded, the caller must
+// hold the write lock.
+func (wc *WorksheetCache) track(sheet, cell string, delta int64) {
+	key := worksheetCacheRangeKey{sheet: sheet, column: worksheetCacheColumn(cell)}
+	r := wc.ranges[key]
+	if r == nil {
+		r = &worksheetCacheRange{cells: make(map[string]struct{})}
+		wc.ranges[key] = r
+	}
+	r.cells[cell] = struct{}{}
+	r.bytes += delta
+	r.lastUse.Store(wc.tick.Add(1))
+	for wc.size > wc.maxBytes {
+		// 正在写入的列不淘汰，单列超出预算时允许超出
+		var victim worksheetCacheRangeKey
+		var oldest *worksheetCacheRange
+		for k, candidate := range wc.ranges {
+			if k != key && (oldest == nil || candidate.lastUse.Load() < oldest.lastUse.Load()) {
+				victim, oldest = k, candidate
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		wc.evict(victim, oldest)
+	}
+}
+
+// evict removes the cells of a column from the cache, the caller must hold
+// the write lock.
+func (wc *WorksheetCache) evict(key worksheetCacheRangeKey, r *worksheetCacheRange) {
+	if sheetCache := wc.cache[key.sheet]; sheetCache != nil {
+		for cell := range r.cells {
+			delete(sheetCache, cell)
+		}
+	}
+	wc.size -= r.bytes
+	wc.evictions++
+	delete(wc.ranges, key)
+}
+
+// Evictions returns the number of columns evicted from the cache to keep it
+// within its byte budget.
+func (wc *WorksheetCache) Evictions() int {
+	wc.mu.RLock()
+	defer wc.mu.RUnlock()
+	return wc.evictions
+}
@@ -1,6 +1,11 @@
 package excelize
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -121,3 +126,322 @@ func TestInferCellValueType(t *testing.T) {
 		})
 	}
 }
+
+func TestWorksheetCacheDiskBackend(t *testing.T) {
+	backend, err := NewDiskWorksheetCacheBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("create backend: %v", err)
+	}
+	wc := NewWorksheetCacheWithBackend(backend)
+	// 超过缓冲区大小，部分值从文件读取
+	long := strings.Repeat("x", 1024)
+	for row := 1; row <= 2048; row++ {
+		wc.Set("SheetA", fmt.Sprintf("A%d", row), newNumberFormulaArg(float64(row)))
+		wc.Set("SheetA", fmt.Sprintf("B%d", row), newStringFormulaArg(long+strconv.Itoa(row)))
+	}
+	wc.Set("SheetB", "A1", newBoolFormulaArg(true))
+	wc.Set("SheetB", "A2", newErrorFormulaArg(formulaErrorNA, "not found"))
+	wc.Set("SheetB", "A3", newEmptyFormulaArg())
+	wc.Set("SheetA", "A1", newNumberFormulaArg(-1.5))
+
+	if got, ok := wc.Get("SheetA", "A1"); !ok || got.Type != ArgNumber || got.Number != -1.5 {
+		t.Fatalf("Get overwritten number: ok=%v value=%v", ok, got.Value())
+	}
+	if got, ok := wc.Get("SheetA", "A10"); !ok || got.Number != 10 {
+		t.Fatalf("Get flushed number: ok=%v value=%v", ok, got.Value())
+	}
+	if got, ok := wc.Get("SheetA", "B2048"); !ok || got.String != long+"2048" {
+		t.Fatalf("Get pending string: ok=%v", ok)
+	}
+	if got, _ := wc.Get("SheetB", "A1"); got.Type != ArgNumber || !got.Boolean || got.Value() != "TRUE" {
+		t.Fatalf("Get bool: %v", got.Value())
+	}
+	if got, _ := wc.Get("SheetB", "A2"); got.Type != ArgError || got.String != formulaErrorNA || got.Error != "not found" {
+		t.Fatalf("Get error: %q %q", got.String, got.Error)
+	}
+	if got, ok := wc.Get("SheetB", "A3"); !ok || got.Type != ArgEmpty {
+		t.Fatalf("Get empty: ok=%v type=%v", ok, got.Type)
+	}
+	if _, ok := wc.Get("SheetB", "Z1"); ok {
+		t.Fatal("expected missing cell")
+	}
+	if stats := wc.GetCacheStats(); stats["SheetA"] != 4096 || stats["_total"] != 4099 || wc.Len() != 4099 {
+		t.Fatalf("unexpected stats %v, len %d", stats, wc.Len())
+	}
+	if sheet := wc.GetSheet("SheetB"); len(sheet) != 3 || sheet["A1"].Value() != "TRUE" {
+		t.Fatalf("unexpected sheet copy %v", sheet)
+	}
+
+	wc.ClearSheet("SheetA")
+	if wc.SheetLen("SheetA") != 0 || wc.SheetLen("SheetB") != 3 {
+		t.Fatalf("unexpected lengths after ClearSheet: %d, %d", wc.SheetLen("SheetA"), wc.SheetLen("SheetB"))
+	}
+	wc.Clear()
+	wc.Set("SheetA", "A1", newStringFormulaArg("again"))
+	if got, _ := wc.Get("SheetA", "A1"); wc.Len() != 1 || got.String != "again" {
+		t.Fatalf("unexpected cache after Clear: %d, %q", wc.Len(), got.String)
+	}
+
+	name := backend.(*diskWorksheetCacheBackend).file.Name()
+	if err := wc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("expected the cache file removed, got %v", err)
+	}
+}
+
+func TestRecalculateWithDiskWorksheetCacheBackend(t *testing.T) {
+	build := func() *File {
+		f := NewFile()
+		if _, err := f.NewSheet("Data"); err != nil {
+			t.Fatalf("new sheet: %v", err)
+		}
+		for row := 1; row <= 2000; row++ {
+			if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", row%50), row % 7, float64(row) / 4}); err != nil {
+				t.Fatalf("set row: %v", err)
+			}
+		}
+		for row := 1; row <= 200; row++ {
+			for cell, formula := range map[string]string{
+				"A": fmt.Sprintf(`"K%d"`, row%60),
+				"B": fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,A%d,Data!$B:$B,\">2\")", row),
+				"C": fmt.Sprintf("INDEX(Data!$C:$C,MATCH(A%d,Data!$A:$A,0))", row),
+				"D": fmt.Sprintf("B%d*2+Data!C%d", row, row),
+			} {
+				if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", cell, row), formula); err != nil {
+					t.Fatalf("set formula: %v", err)
+				}
+			}
+		}
+		return f
+	}
+	memory, disk := build(), build()
+	t.Cleanup(func() { _ = memory.Close(); _ = disk.Close() })
+	dir := t.TempDir()
+	disk.SetCalcTuning(CalcTuning{NewWorksheetCacheBackend: func() (WorksheetCacheBackend, error) {
+		return NewDiskWorksheetCacheBackend(dir)
+	}})
+	for _, f := range []*File{memory, disk} {
+		if err := f.RecalculateAllWithDependency(); err != nil {
+			t.Fatalf("recalculate: %v", err)
+		}
+	}
+	for row := 1; row <= 200; row++ {
+		for _, col := range []string{"A", "B", "C", "D"} {
+			cell := fmt.Sprintf("%s%d", col, row)
+			want, _ := memory.GetCellValue("Sheet1", cell)
+			if got, _ := disk.GetCellValue("Sheet1", cell); got != want {
+				t.Fatalf("unexpected %s value %q with the disk backend, want %q", cell, got, want)
+			}
+		}
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected the cache files removed: %v, %v", entries, err)
+	}
+}
+
+func TestWorksheetCacheMaxBytes(t *testing.T) {
+	unbounded := NewWorksheetCache()
+	unbounded.Set("SheetA", "A1", newStringFormulaArg("abc"))
+	size := unbounded.Size()
+	if size != worksheetCacheValueSize("A1", newStringFormulaArg("abc")) {
+		t.Fatalf("unexpected size %d", size)
+	}
+	unbounded.Set("SheetA", "A1", newStringFormulaArg("abcdef"))
+	if unbounded.Size() != size+3 {
+		t.Fatalf("unexpected size %d after overwrite, want %d", unbounded.Size(), size+3)
+	}
+	unbounded.ClearSheet("SheetA")
+	if unbounded.Size() != 0 {
+		t.Fatalf("unexpected size %d after ClearSheet", unbounded.Size())
+	}
+
+	// 预算约为 3 列，每列 10 个单元格
+	cellSize := worksheetCacheValueSize("A10", newNumberFormulaArg(1))
+	wc := NewWorksheetCacheWithMaxBytes(cellSize * 32)
+	for _, col := range []string{"A", "B", "C"} {
+		for row := 1; row <= 10; row++ {
+			wc.Set("SheetA", fmt.Sprintf("%s%d", col, row), newNumberFormulaArg(float64(row)))
+		}
+	}
+	if wc.Evictions() != 0 || wc.Len() != 30 {
+		t.Fatalf("unexpected evictions %d, len %d", wc.Evictions(), wc.Len())
+	}
+	// A 列最近被读取，最久未使用的 B 列被淘汰
+	if _, ok := wc.Get("SheetA", "A1"); !ok {
+		t.Fatal("expected cached A1")
+	}
+	for row := 1; row <= 10; row++ {
+		wc.Set("SheetB", fmt.Sprintf("$D%d", row), newNumberFormulaArg(float64(row)))
+	}
+	if wc.Evictions() != 1 || wc.Size() > cellSize*32 {
+		t.Fatalf("unexpected evictions %d, size %d", wc.Evictions(), wc.Size())
+	}
+	if _, ok := wc.Get("SheetA", "B5"); ok {
+		t.Fatal("expected evicted B5")
+	}
+	for _, cell := range []string{"A10", "C1"} {
+		if _, ok := wc.Get("SheetA", cell); !ok {
+			t.Fatalf("expected cached %s", cell)
+		}
+	}
+	if got, ok := wc.Get("SheetB", "$D10"); !ok || got.Number != 10 {
+		t.Fatalf("unexpected $D10 %v, %v", got.Value(), ok)
+	}
+	// 单列超出预算时保留正在写入的列
+	for row := 1; row <= 40; row++ {
+		wc.Set("SheetC", fmt.Sprintf("E%d", row), newNumberFormulaArg(float64(row)))
+	}
+	if wc.Len() != 40 || wc.SheetLen("SheetC") != 40 {
+		t.Fatalf("unexpected len %d", wc.Len())
+	}
+	wc.Clear()
+	if wc.Size() != 0 || wc.Len() != 0 {
+		t.Fatalf("unexpected size %d after Clear", wc.Size())
+	}
+}
+
+func TestRecalculateWithWorksheetCacheMaxBytes(t *testing.T) {
+	build := func() *File {
+		f := NewFile()
+		if _, err := f.NewSheet("Data"); err != nil {
+			t.Fatalf("new sheet: %v", err)
+		}
+		for row := 1; row <= 1000; row++ {
+			if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", row%50), row % 7, float64(row) / 4}); err != nil {
+				t.Fatalf("set row: %v", err)
+			}
+		}
+		for row := 1; row <= 200; row++ {
+			for cell, formula := range map[string]string{
+				"A": fmt.Sprintf(`"K%d"`, row%60),
+				"B": fmt.Sprintf("SUMIFS(Data!$C:$C,Data!$A:$A,A%d,Data!$B:$B,\">2\")", row),
+				"C": fmt.Sprintf("INDEX(Data!$C:$C,MATCH(A%d,Data!$A:$A,0))", row),
+				"D": fmt.Sprintf("B%d*2+Data!C%d", row, row),
+				"E": fmt.Sprintf("D%d+C%d", row, row),
+			} {
+				if err := f.SetCellFormula("Sheet1", fmt.Sprintf("%s%d", cell, row), formula); err != nil {
+					t.Fatalf("set formula: %v", err)
+				}
+			}
+		}
+		return f
+	}
+	unbounded, bounded := build(), build()
+	t.Cleanup(func() { _ = unbounded.Close(); _ = bounded.Close() })
+	bounded.SetCalcTuning(CalcTuning{WorksheetCacheMaxBytes: 4096})
+	for _, f := range []*File{unbounded, bounded} {
+		if err := f.RecalculateAllWithDependency(); err != nil {
+			t.Fatalf("recalculate: %v", err)
+		}
+	}
+	for row := 1; row <= 200; row++ {
+		for _, col := range []string{"A", "B", "C", "D", "E"} {
+			cell := fmt.Sprintf("%s%d", col, row)
+			want, _ := unbounded.GetCellValue("Sheet1", cell)
+			if got, _ := bounded.GetCellValue("Sheet1", cell); got != want {
+				t.Fatalf("unexpected %s value %q with the bounded cache, want %q", cell, got, want)
+			}
+		}
+	}
+}
+
+func TestWorksheetCacheGetSheetConcurrentWrites(t *testing.T) {
+	backend, err := NewDiskWorksheetCacheBackend(t.TempDir())
+	if err != nil {
+		t.Fatalf("create backend: %v", err)
+	}
+	disk := NewWorksheetCacheWithBackend(backend)
+	t.Cleanup(func() { _ = disk.Close() })
+	for name, wc := range map[string]*WorksheetCache{
+		"memory": NewWorksheetCache(),
+		"budget": NewWorksheetCacheWithMaxBytes(1 << 20),
+		"disk":   disk,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for row := 1; row <= 500; row++ {
+						wc.Set("Data", fmt.Sprintf("%c%d", 'A'+w, row), newNumberFormulaArg(float64(row)))
+					}
+				}(w)
+			}
+			// 并发写入时读取的快照在读取后不再变化
+			for i := 0; i < 50; i++ {
+				snapshot := wc.GetSheet("Data")
+				size := len(snapshot)
+				for cell := range snapshot {
+					snapshot[cell] = newEmptyFormulaArg()
+				}
+				if len(snapshot) != size {
+					t.Fatalf("snapshot changed from %d to %d cells", size, len(snapshot))
+				}
+			}
+			wg.Wait()
+			if got := len(wc.GetSheet("Data")); got != 2000 {
+				t.Fatalf("unexpected %d cells", got)
+			}
+		})
+	}
+}
+
+func TestRecalculateINDEXMATCHWithConcurrentWrites(t *testing.T) {
+	f := NewFile()
+	t.Cleanup(func() { _ = f.Close() })
+	if _, err := f.NewSheet("Data"); err != nil {
+		t.Fatalf("new sheet: %v", err)
+	}
+	for row := 1; row <= 500; row++ {
+		if err := f.SetSheetRow("Data", fmt.Sprintf("A%d", row), &[]interface{}{fmt.Sprintf("K%d", row), row}); err != nil {
+			t.Fatalf("set row: %v", err)
+		}
+		// 与 INDEX-MATCH 同一层级写入数据工作表的公式
+		if err := f.SetCellFormula("Data", fmt.Sprintf("C%d", row), fmt.Sprintf("B%d*2", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellValue("Sheet1", fmt.Sprintf("C%d", row), fmt.Sprintf("K%d", row)); err != nil {
+			t.Fatalf("set value: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("A%d", row), fmt.Sprintf("INDEX(Data!$B:$B,MATCH(C%d,Data!$A:$A,0))", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("B%d", row), fmt.Sprintf("Data!B%d+1", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		// 同一层级中 SUMIFS 写入 INDEX-MATCH 正在读取的工作表
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("D%d", row), fmt.Sprintf("SUMIFS(Data!$B:$B,Data!$A:$A,C%d)", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+		if err := f.SetCellFormula("Sheet1", fmt.Sprintf("E%d", row), fmt.Sprintf("INDEX(Sheet1!$C:$C,MATCH(C%d,Sheet1!$C:$C,0))", row)); err != nil {
+			t.Fatalf("set formula: %v", err)
+		}
+	}
+	f.SetCalcConcurrency(8)
+	f.SetCalcTuning(CalcTuning{AuditTrail: true})
+	if err := f.RecalculateAllWithDependency(); err != nil {
+		t.Fatalf("recalculate: %v", err)
+	}
+	for row := 1; row <= 500; row++ {
+		for cell, want := range map[string]string{
+			fmt.Sprintf("Data!C%d", row): strconv.Itoa(row * 2), fmt.Sprintf("Sheet1!A%d", row): strconv.Itoa(row),
+			fmt.Sprintf("Sheet1!B%d", row): strconv.Itoa(row + 1), fmt.Sprintf("Sheet1!D%d", row): strconv.Itoa(row),
+			fmt.Sprintf("Sheet1!E%d", row): fmt.Sprintf("K%d", row),
+		} {
+			sheet, ref, _ := strings.Cut(cell, "!")
+			if got, _ := f.GetCellValue(sheet, ref); got != want {
+				t.Fatalf("unexpected %s value %q, want %s", cell, got, want)
+			}
+		}
+	}
+	optimizers := make(map[string]string)
+	for _, entry := range f.CalcAuditTrail() {
+		optimizers[entry.Cell] = entry.Optimizer
+	}
+	if optimizers["Sheet1!A1"] != "INDEX-MATCH" || optimizers["Sheet1!E1"] != "INDEX-MATCH" || optimizers["Sheet1!D1"] != "SUMIFS" {
+		t.Fatalf("unexpected optimizers A1 %s, D1 %s, E1 %s", optimizers["Sheet1!A1"], optimizers["Sheet1!D1"], optimizers["Sheet1!E1"])
+	}
+}